@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
-	"sync"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -17,6 +19,10 @@ const MaxConnections = 5
 
 var ctx = context.Background()
 
+// apiKey guards the endpoints that show who is online and what was said.
+// Without one they are refused.
+var apiKey = os.Getenv("API_KEY")
+
 type Message struct {
 	Type      string    `json:"type"` // "welcome", "user_joined", "user_left", "message", "error", "user_list"
 	Username  string    `json:"username"`
@@ -40,12 +46,24 @@ type Client struct {
 	Send     chan Message
 }
 
+// Notice is a message for one client only.
+type Notice struct {
+	Client  *Client
+	Message Message
+}
+
+// Hub owns the set of connected clients. Only the Run goroutine reads or
+// changes Clients, or sends on and closes their Send channels; everything
+// else talks to it over the channels.
 type Hub struct {
-	Clients     map[*Client]bool
-	Broadcast   chan Message
-	Register    chan *Client
-	Unregister  chan *Client
-	mu          sync.RWMutex
+	Clients    map[*Client]bool
+	Broadcast  chan Message
+	Register   chan *Client
+	Unregister chan *Client
+	// Notify queues a message for one client, if it is still connected
+	Notify chan Notice
+	// Status asks Run for the usernames currently connected
+	Status      chan chan []string
 	redisClient *redis.Client
 }
 
@@ -103,10 +121,8 @@ func (h *Hub) pushMessageToRedis(username, content string, timestamp time.Time)
 	return nil
 }
 
+// getUserList must only be called from Run.
 func (h *Hub) getUserList() []string {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-
 	userList := make([]string, 0, len(h.Clients))
 	for client := range h.Clients {
 		userList = append(userList, client.Username)
@@ -114,136 +130,90 @@ func (h *Hub) getUserList() []string {
 	return userList
 }
 
+// send queues message for every client, dropping any client whose buffer
+// is full. It must only be called from Run.
+func (h *Hub) send(message Message) {
+	for client := range h.Clients {
+		select {
+		case client.Send <- message:
+			log.Printf("✅ %s delivered to: %s", message.Type, client.Username)
+		default:
+			log.Printf("❌ Failed to deliver %s to: %s, dropping client", message.Type, client.Username)
+			close(client.Send)
+			delete(h.Clients, client)
+		}
+	}
+}
+
 func (h *Hub) Run() {
 	for {
 		select {
 		case client := <-h.Register:
-			h.mu.Lock()
-			currentCount := len(h.Clients)
-
-			if currentCount < MaxConnections {
-				h.Clients[client] = true
-				newCount := len(h.Clients)
-				h.mu.Unlock()
-
-				userList := h.getUserList()
-
-				log.Printf("📝 Sending welcome message to: %s", client.Username)
-
-				// Send welcome message to the new user only
-				welcomeMsg := Message{
-					Type:      "welcome",
-					Username:  client.Username,
-					Content:   fmt.Sprintf("Welcome to the chat, %s! 🎉", client.Username),
-					UserCount: newCount,
-					MaxUsers:  MaxConnections,
-					UserList:  userList,
-					Timestamp: time.Now(),
-				}
-
-				// Send welcome message immediately to this specific client
-				select {
-				case client.Send <- welcomeMsg:
-					log.Printf("✅ Welcome message sent to: %s", client.Username)
-				default:
-					log.Printf("❌ Failed to send welcome message to: %s", client.Username)
-				}
-
-				// Small delay to ensure welcome is processed first
-				time.Sleep(100 * time.Millisecond)
-
-				// Broadcast to ALL users (including new one) that a new user joined
-				log.Printf("📢 Broadcasting user joined message for: %s", client.Username)
-				joinMsg := Message{
-					Type:      "user_joined",
-					Username:  client.Username,
-					Content:   fmt.Sprintf("%s joined the chat", client.Username),
-					UserCount: newCount,
-					MaxUsers:  MaxConnections,
-					UserList:  userList,
-					Timestamp: time.Now(),
-				}
-
-				// Broadcast to all clients
-				h.mu.RLock()
-				for c := range h.Clients {
-					select {
-					case c.Send <- joinMsg:
-						log.Printf("✅ Join notification sent to: %s", c.Username)
-					default:
-						log.Printf("❌ Failed to send join notification to: %s", c.Username)
-					}
-				}
-				h.mu.RUnlock()
-
-				fmt.Printf("✅ Client registered: %s (Total: %d/%d)\n", client.Username, newCount, MaxConnections)
-			} else {
-				h.mu.Unlock()
+			if len(h.Clients) >= MaxConnections {
 				log.Printf("❌ Rejecting client (room full): %s", client.Username)
+				go reject(client, len(h.Clients))
+				continue
+			}
 
-				// Send rejection message DIRECTLY through the connection (not through channel)
-				rejectionMsg := Message{
-					Type:      "error",
-					Content:   "Chat room is full. Maximum 5 users allowed. Please wait for someone to leave.",
-					Timestamp: time.Now(),
-				}
-
-				// Write directly to WebSocket connection synchronously
-				err := client.Conn.WriteJSON(rejectionMsg)
-				if err != nil {
-					log.Printf("❌ Failed to send rejection message to %s: %v", client.Username, err)
-				} else {
-					log.Printf("✅ Rejection message sent to: %s", client.Username)
-				}
-
-				// Small delay to ensure message is received before closing
-				time.Sleep(200 * time.Millisecond)
-
-				// Close the connection
-				client.Conn.Close()
-				close(client.Send)
-
-				fmt.Printf("❌ Connection rejected: %s (Room full: %d/%d)\n", client.Username, currentCount, MaxConnections)
+			h.Clients[client] = true
+			newCount := len(h.Clients)
+			userList := h.getUserList()
+
+			log.Printf("📝 Sending welcome message to: %s", client.Username)
+
+			// The welcome is queued before the join notice, so it is
+			// always written first
+			welcomeMsg := Message{
+				Type:      "welcome",
+				Username:  client.Username,
+				Content:   fmt.Sprintf("Welcome to the chat, %s! 🎉", client.Username),
+				UserCount: newCount,
+				MaxUsers:  MaxConnections,
+				UserList:  userList,
+				Timestamp: time.Now(),
+			}
+			select {
+			case client.Send <- welcomeMsg:
+				log.Printf("✅ Welcome message sent to: %s", client.Username)
+			default:
+				log.Printf("❌ Failed to send welcome message to: %s", client.Username)
 			}
 
-		case client := <-h.Unregister:
-			h.mu.Lock()
-			if _, ok := h.Clients[client]; ok {
-				delete(h.Clients, client)
-				close(client.Send)
-				newCount := len(h.Clients)
-				h.mu.Unlock()
-
-				userList := h.getUserList()
-
-				log.Printf("📢 Broadcasting user left message for: %s", client.Username)
-
-				// Broadcast to all remaining users that someone left
-				leaveMsg := Message{
-					Type:      "user_left",
-					Username:  client.Username,
-					Content:   fmt.Sprintf("%s left the chat", client.Username),
-					UserCount: newCount,
-					MaxUsers:  MaxConnections,
-					UserList:  userList,
-					Timestamp: time.Now(),
-				}
+			// Broadcast to ALL users (including new one) that a new user joined
+			log.Printf("📢 Broadcasting user joined message for: %s", client.Username)
+			h.send(Message{
+				Type:      "user_joined",
+				Username:  client.Username,
+				Content:   fmt.Sprintf("%s joined the chat", client.Username),
+				UserCount: newCount,
+				MaxUsers:  MaxConnections,
+				UserList:  userList,
+				Timestamp: time.Now(),
+			})
 
-				h.mu.RLock()
-				for c := range h.Clients {
-					select {
-					case c.Send <- leaveMsg:
-						log.Printf("✅ Leave notification sent to: %s", c.Username)
-					default:
-						log.Printf("❌ Failed to send leave notification to: %s", c.Username)
-					}
-				}
-				h.mu.RUnlock()
+			fmt.Printf("✅ Client registered: %s (Total: %d/%d)\n", client.Username, len(h.Clients), MaxConnections)
 
-				fmt.Printf("👋 Client unregistered: %s (Total: %d/%d)\n", client.Username, newCount, MaxConnections)
-			} else {
-				h.mu.Unlock()
+		case client := <-h.Unregister:
+			// A client dropped as a slow consumer is already gone
+			if _, ok := h.Clients[client]; !ok {
+				continue
 			}
+			delete(h.Clients, client)
+			close(client.Send)
+
+			// Broadcast to all remaining users that someone left
+			log.Printf("📢 Broadcasting user left message for: %s", client.Username)
+			h.send(Message{
+				Type:      "user_left",
+				Username:  client.Username,
+				Content:   fmt.Sprintf("%s left the chat", client.Username),
+				UserCount: len(h.Clients),
+				MaxUsers:  MaxConnections,
+				UserList:  h.getUserList(),
+				Timestamp: time.Now(),
+			})
+
+			fmt.Printf("👋 Client unregistered: %s (Total: %d/%d)\n", client.Username, len(h.Clients), MaxConnections)
 
 		case message := <-h.Broadcast:
 			log.Printf("📢 Broadcasting message from: %s", message.Username)
@@ -256,22 +226,52 @@ func (h *Hub) Run() {
 				}
 			}
 
-			h.mu.RLock()
-			for client := range h.Clients {
-				select {
-				case client.Send <- message:
-					log.Printf("✅ Message delivered to: %s", client.Username)
-				default:
-					log.Printf("❌ Failed to deliver message to: %s", client.Username)
-					close(client.Send)
-					delete(h.Clients, client)
-				}
+			h.send(message)
+
+		case notice := <-h.Notify:
+			// A client dropped as a slow consumer has its Send closed
+			if _, ok := h.Clients[notice.Client]; !ok {
+				continue
 			}
-			h.mu.RUnlock()
+			select {
+			case notice.Client.Send <- notice.Message:
+			default:
+				log.Printf("❌ Failed to deliver %s to: %s", notice.Message.Type, notice.Client.Username)
+			}
+
+		case reply := <-h.Status:
+			reply <- h.getUserList()
 		}
 	}
 }
 
+// reject tells a client the room is full and closes its connection. It
+// runs on its own goroutine so the hub keeps serving while it waits; the
+// client never joined Clients, so nothing else closes its Send channel.
+func reject(client *Client, currentCount int) {
+	rejectionMsg := Message{
+		Type:      "error",
+		Content:   "Chat room is full. Maximum 5 users allowed. Please wait for someone to leave.",
+		Timestamp: time.Now(),
+	}
+
+	// Write directly to WebSocket connection synchronously
+	err := client.Conn.WriteJSON(rejectionMsg)
+	if err != nil {
+		log.Printf("❌ Failed to send rejection message to %s: %v", client.Username, err)
+	} else {
+		log.Printf("✅ Rejection message sent to: %s", client.Username)
+	}
+
+	// Small delay to ensure message is received before closing
+	time.Sleep(200 * time.Millisecond)
+
+	client.Conn.Close()
+	close(client.Send)
+
+	fmt.Printf("❌ Connection rejected: %s (Room full: %d/%d)\n", client.Username, currentCount, MaxConnections)
+}
+
 func (c *Client) ReadPump() {
 	defer func() {
 		hub.Unregister <- c
@@ -279,8 +279,8 @@ func (c *Client) ReadPump() {
 	}()
 
 	for {
-		var msg Message
-		err := c.Conn.ReadJSON(&msg)
+		var frame Message
+		err := c.Conn.ReadJSON(&frame)
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("error: %v", err)
@@ -288,11 +288,20 @@ func (c *Client) ReadPump() {
 			break
 		}
 
-		msg.Username = c.Username
-		msg.Timestamp = time.Now()
-		msg.Type = "message"
+		// Identity comes from the connection; a frame claiming another
+		// username is rejected and only the content is kept
+		if frame.Username != "" && frame.Username != c.Username {
+			log.Printf("🚫 Frame from %s claimed username %q", c.Username, frame.Username)
+			hub.Notify <- Notice{Client: c, Message: Message{Type: "error", Content: "username is set by the server", Timestamp: time.Now()}}
+			continue
+		}
 
-		hub.Broadcast <- msg
+		hub.Broadcast <- Message{
+			Type:      "message",
+			Username:  c.Username,
+			Content:   frame.Content,
+			Timestamp: time.Now(),
+		}
 	}
 }
 
@@ -343,22 +352,44 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 	go client.ReadPump()
 }
 
+// authorized reports whether the request carries the API key, as
+// "Authorization: Bearer <key>" or X-API-Key.
+func authorized(r *http.Request) bool {
+	key := r.Header.Get("X-API-Key")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		key = bearer
+	}
+	return apiKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1
+}
+
+// requireAPIKey refuses requests without the API key.
+func requireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			http.Error(w, "API key required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// statusHandler reports how many users are online to anyone, and who they
+// are to callers with the API key.
 func statusHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
 
-	hub.mu.RLock()
-	clientCount := len(hub.Clients)
-	usernames := make([]string, 0, clientCount)
-	for client := range hub.Clients {
-		usernames = append(usernames, client.Username)
-	}
-	hub.mu.RUnlock()
+	reply := make(chan []string)
+	hub.Status <- reply
+	usernames := <-reply
 
 	response := map[string]interface{}{
-		"current_connections": clientCount,
+		"current_connections": len(usernames),
 		"max_connections":     MaxConnections,
-		"users":               usernames,
+	}
+	if authorized(r) {
+		response["users"] = usernames
 	}
 
 	json.NewEncoder(w).Encode(response)
@@ -406,6 +437,8 @@ func main() {
 		Broadcast:   make(chan Message),
 		Register:    make(chan *Client),
 		Unregister:  make(chan *Client),
+		Notify:      make(chan Notice),
+		Status:      make(chan chan []string),
 		redisClient: rdb,
 	}
 
@@ -423,7 +456,10 @@ func main() {
 	http.HandleFunc("/api/status", statusHandler)
 
 	// Messages endpoint (to retrieve Redis queue messages)
-	http.HandleFunc("/api/messages", messagesHandler)
+	http.HandleFunc("/api/messages", requireAPIKey(messagesHandler))
+	if apiKey == "" {
+		log.Println("⚠️ API_KEY not set, /api/messages is refused and /api/status lists no users")
+	}
 
 	fmt.Println("🚀 WebSocket server starting on http://localhost:8080")
 	fmt.Println("📱 Visit http://localhost:8080 to access the chat UI")
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestHub starts a hub whose Redis is unreachable, so chat is broadcast
+// without being stored.
+func newTestHub(t *testing.T) *Hub {
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 10 * time.Millisecond, MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+	h := &Hub{
+		Clients:     make(map[*Client]bool),
+		Broadcast:   make(chan Message),
+		Register:    make(chan *Client),
+		Unregister:  make(chan *Client),
+		Notify:      make(chan Notice),
+		Status:      make(chan chan []string),
+		redisClient: rdb,
+	}
+	go h.Run()
+	return h
+}
+
+func (h *Hub) connected() []string {
+	reply := make(chan []string)
+	h.Status <- reply
+	return <-reply
+}
+
+// drain reads client's messages until the hub closes Send.
+func drain(client *Client) []Message {
+	var received []Message
+	for msg := range client.Send {
+		received = append(received, msg)
+	}
+	return received
+}
+
+// TestHubConcurrentRegisterBroadcastUnregister hammers the hub from many
+// goroutines at once; run it with -race.
+func TestHubConcurrentRegisterBroadcastUnregister(t *testing.T) {
+	const rounds, broadcasters, messages = 20, 3, 10
+	h := newTestHub(t)
+
+	var wg sync.WaitGroup
+	for i := range MaxConnections {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for round := range rounds {
+				client := &Client{Username: fmt.Sprintf("user%d", i), Send: make(chan Message, 256)}
+				done := make(chan []Message)
+				go func() { done <- drain(client) }()
+
+				h.Register <- client
+				h.connected()
+				h.Unregister <- client
+
+				received := <-done
+				if len(received) == 0 || received[0].Type != "welcome" {
+					t.Errorf("user%d round %d: first message = %+v, want the welcome", i, round, received)
+				}
+			}
+		}()
+	}
+	for i := range broadcasters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range messages {
+				h.Broadcast <- Message{Type: "notice", Username: fmt.Sprintf("bot%d", i), Content: fmt.Sprint(n)}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if users := h.connected(); len(users) != 0 {
+		t.Errorf("connected after every client unregistered = %v", users)
+	}
+}
+
+func TestHubBroadcastReachesEveryClient(t *testing.T) {
+	h := newTestHub(t)
+
+	clients := make([]*Client, MaxConnections)
+	results := make([]chan []Message, MaxConnections)
+	for i := range clients {
+		clients[i] = &Client{Username: fmt.Sprintf("user%d", i), Send: make(chan Message, 256)}
+		results[i] = make(chan []Message, 1)
+		go func() { results[i] <- drain(clients[i]) }()
+		h.Register <- clients[i]
+	}
+
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.Broadcast <- Message{Type: "message", Username: clients[i].Username, Content: "hello", Timestamp: time.Now()}
+		}()
+	}
+	wg.Wait()
+	for _, client := range clients {
+		h.Unregister <- client
+	}
+
+	for i, result := range results {
+		chat := 0
+		for _, msg := range <-result {
+			if msg.Type == "message" {
+				chat++
+			}
+		}
+		if chat != MaxConnections {
+			t.Errorf("user%d got %d chat messages, want %d", i, chat, MaxConnections)
+		}
+	}
+}
+
+func TestHubDropsSlowClient(t *testing.T) {
+	h := newTestHub(t)
+
+	// Room for the welcome and the join notice only
+	slow := &Client{Username: "slow", Send: make(chan Message, 2)}
+	h.Register <- slow
+	for n := range 3 {
+		h.Broadcast <- Message{Type: "notice", Content: fmt.Sprint(n)}
+	}
+
+	if users := h.connected(); len(users) != 0 {
+		t.Errorf("connected after the slow client fell behind = %v", users)
+	}
+	// Unregistering or notifying a dropped client must not send on or
+	// close its Send
+	h.Unregister <- slow
+	h.Notify <- Notice{Client: slow, Message: Message{Type: "error", Content: "username is set by the server"}}
+	if received := drain(slow); len(received) != 2 {
+		t.Errorf("slow client got %d messages, want 2", len(received))
+	}
+}
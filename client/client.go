@@ -0,0 +1,664 @@
+// Package client connects bots, tools and integration tests to a chat
+// server's WebSocket API.
+//
+//	seat, err := client.Login(ctx, "http://localhost:8080", "bot@example.com", "")
+//	c, err := client.Connect(ctx, "http://localhost:8080", seat, client.Options{
+//		OnMessage: func(msg models.Message) { log.Println(msg.Content) },
+//	})
+//	defer c.Close()
+//	c.SendMessage("hello")
+//
+// A Client stays connected across drops: it redials with backoff, resumes
+// from the last seq it saw so the lobby replays what it missed, and resends
+// the messages the server hadn't acked. Callers see each message once.
+package client
+
+import (
+	"bytes"
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// ErrLobbyEnded is the Err of a client whose lobby was ended
+	ErrLobbyEnded = errors.New("the lobby ended")
+	// ErrClosed is returned by the sends of a closed client
+	ErrClosed = errors.New("client is closed")
+	// ErrNotConnected is returned by Send while the client is reconnecting
+	ErrNotConnected = errors.New("not connected")
+	// ErrLoggedInElsewhere is the Err of a client whose seat was connected
+	// again elsewhere, which the server closes the older connection for
+	ErrLoggedInElsewhere = errors.New("logged in elsewhere")
+)
+
+// Defaults of the Options that are left zero.
+const (
+	DefaultMinBackoff   = 500 * time.Millisecond
+	DefaultMaxBackoff   = 30 * time.Second
+	DefaultPingInterval = 15 * time.Second
+)
+
+// Seat is a member's place in a lobby, as a login answers it.
+type Seat struct {
+	Email    string
+	LobbyID  string
+	TenantID string
+	// Token is the reconnect token the login issued, which gets the
+	// connect ticket of every connection. Logging in again while seated
+	// doesn't issue another, so keep it.
+	Token string
+}
+
+// Error is an error answer of the server, to a login or a connection.
+type Error struct {
+	Status  int
+	Code    models.ErrorCode
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// permanent reports whether retrying can't help: the seat is gone, was
+// never valid, or the lobby ended.
+func permanent(err error) bool {
+	var serverErr *Error
+	if errors.As(err, &serverErr) {
+		return serverErr.Status >= 400 && serverErr.Status < 500 && serverErr.Status != http.StatusTooManyRequests
+	}
+	return errors.Is(err, ErrLobbyEnded) || errors.Is(err, ErrLoggedInElsewhere)
+}
+
+// closeError turns the close frame the server ended a connection with into
+// the error the client stops with. Other closes, such as a server restart
+// or falling behind, are worth a reconnect.
+func closeError(err error) error {
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		return err
+	}
+	switch closeErr.Code {
+	case models.CloseLobbyEnded:
+		return ErrLobbyEnded
+	case models.CloseLoggedInElsewhere:
+		return ErrLoggedInElsewhere
+	case models.ErrorKicked.CloseCode():
+		return &Error{Status: models.ErrorKicked.Status(), Code: models.ErrorKicked, Message: closeErr.Text}
+	case models.ErrorUnauthorized.CloseCode():
+		return &Error{Status: models.ErrorUnauthorized.Status(), Code: models.ErrorUnauthorized, Message: closeErr.Text}
+	}
+	return err
+}
+
+// Login logs email in to the server at baseURL under tenantID, "" for the
+// default tenant, without a password, which only a server with OPEN_LOGIN
+// accepts. If the lobby is full it waits in its queue until a seat frees up
+// or ctx is done.
+func Login(ctx context.Context, baseURL, email, tenantID string) (Seat, error) {
+	return LoginWithPassword(ctx, baseURL, email, "", tenantID)
+}
+
+// LoginWithPassword is Login for the account of email.
+func LoginWithPassword(ctx context.Context, baseURL, email, password, tenantID string) (Seat, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	credentials := map[string]string{"email": email}
+	if password != "" {
+		credentials["password"] = password
+	}
+	body, _ := json.Marshal(credentials)
+	resp, err := request(ctx, "POST", baseURL+"/api/login", tenantID, body)
+	if err != nil {
+		return Seat{}, err
+	}
+	defer resp.Body.Close()
+
+	var login struct {
+		Message        string           `json:"message"`
+		Code           models.ErrorCode `json:"code"`
+		LobbyID        string           `json:"lobby_id"`
+		ReconnectToken string           `json:"reconnect_token"`
+		Ticket         string           `json:"ticket"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		return Seat{}, &Error{Status: resp.StatusCode, Code: models.ErrorCodeForStatus(resp.StatusCode), Message: "invalid login response"}
+	}
+
+	seat := Seat{Email: email, TenantID: tenantID, LobbyID: login.LobbyID, Token: login.ReconnectToken}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return seat, nil
+	case http.StatusAccepted:
+		return waitInQueue(ctx, baseURL, seat, login.Ticket)
+	default:
+		return Seat{}, &Error{Status: resp.StatusCode, Code: login.Code, Message: login.Message}
+	}
+}
+
+func waitInQueue(ctx context.Context, baseURL string, seat Seat, ticket string) (Seat, error) {
+	poll := time.NewTicker(time.Second)
+	defer poll.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return Seat{}, ctx.Err()
+		case <-poll.C:
+		}
+
+		resp, err := request(ctx, "GET", baseURL+"/api/queue?ticket="+url.QueryEscape(ticket), seat.TenantID, nil)
+		if err != nil {
+			return Seat{}, err
+		}
+		var status struct {
+			Admitted       bool   `json:"admitted"`
+			LobbyID        string `json:"lobby_id"`
+			ReconnectToken string `json:"reconnect_token"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return Seat{}, responseError(resp.StatusCode, nil)
+		}
+		if err != nil {
+			return Seat{}, err
+		}
+		if status.Admitted {
+			seat.LobbyID, seat.Token = status.LobbyID, status.ReconnectToken
+			return seat, nil
+		}
+	}
+}
+
+func request(ctx context.Context, method, target, tenantID string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if tenantID != "" {
+		req.Header.Set(config.TenantHeader, tenantID)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// connectTicket gets a one-time ticket for the next connection with the
+// seat's reconnect token.
+func (c *Client) connectTicket() (string, error) {
+	query := url.Values{}
+	query.Set("email", c.seat.Email)
+	query.Set("token", c.seat.Token)
+	target := c.baseURL + "/api/lobbies/" + url.PathEscape(c.seat.LobbyID) + "/connect-ticket?" + query.Encode()
+	resp, err := request(c.ctx, "POST", target, c.seat.TenantID, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", responseError(resp.StatusCode, body)
+	}
+	var answer struct {
+		ConnectTicket string `json:"connect_ticket"`
+	}
+	if err := json.Unmarshal(body, &answer); err != nil {
+		return "", err
+	}
+	return answer.ConnectTicket, nil
+}
+
+// responseError reads an error answer of the REST API.
+func responseError(status int, body []byte) *Error {
+	var answer models.ErrorResponse
+	if json.Unmarshal(body, &answer) != nil || answer.Code == "" {
+		answer.Code = models.ErrorCodeForStatus(status)
+	}
+	if answer.Error == "" {
+		answer.Error = http.StatusText(status)
+	}
+	return &Error{Status: status, Code: answer.Code, Message: answer.Error}
+}
+
+// Options tune a Client. Callbacks run on the client's reader, one at a
+// time and in the lobby's order; a slow callback holds up reading.
+type Options struct {
+	// OnMessage gets chat: messages, replies and ideas
+	OnMessage func(models.Message)
+	// OnPresence gets joins, leaves, timeouts, presence changes and roster
+	// digests
+	OnPresence func(models.Message)
+	// OnFrame gets every frame, those above included
+	OnFrame func(models.Message)
+	// OnReconnect is called after each reconnect, with the error that
+	// dropped the previous connection
+	OnReconnect func(err error)
+
+	// Dialer dials the WebSocket; websocket.DefaultDialer if nil
+	Dialer *websocket.Dialer
+	// Reconnects back off exponentially from MinBackoff to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// PingInterval is how often the client pings; a connection silent for
+	// three intervals is taken to be dead
+	PingInterval time.Duration
+}
+
+// Client is a member's connection to its lobby.
+type Client struct {
+	baseURL string
+	seat    Seat
+	opts    Options
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	lastSeq int64
+	// reliable is set while the lobby has reliable delivery, which wants
+	// each frame acked; acked is the seq acked last
+	reliable bool
+	acked    int64
+	// pending are the chat messages not acked yet, in the order sent
+	pending []models.Message
+	err     error
+}
+
+// Connect connects seat to its lobby on the server at baseURL. It returns
+// once the lobby welcomed the client, and keeps it connected until Close,
+// ctx is done or the seat is lost.
+func Connect(ctx context.Context, baseURL string, seat Seat, opts Options) (*Client, error) {
+	if opts.Dialer == nil {
+		opts.Dialer = websocket.DefaultDialer
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(DefaultMaxBackoff, opts.MinBackoff)
+	}
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = DefaultPingInterval
+	}
+
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		seat:    seat,
+		opts:    opts,
+		done:    make(chan struct{}),
+	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	conn, err := c.dial()
+	if err != nil {
+		c.cancel()
+		return nil, err
+	}
+	go c.run(conn)
+	return c, nil
+}
+
+// Seat is the seat the client is connected with.
+func (c *Client) Seat() Seat {
+	return c.seat
+}
+
+// LastSeq is the newest seq of the lobby the client has seen.
+func (c *Client) LastSeq() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastSeq
+}
+
+// SendMessage sends a chat message and returns its client message ID. While
+// reconnecting it is queued; it is sent again after every reconnect until
+// the server acks it, and the server stores it once.
+func (c *Client) SendMessage(content string) (string, error) {
+	msg := models.Message{Action: models.MessageTypeChat, Content: content, ClientMsgID: newClientMsgID()}
+	if c.ctx.Err() != nil {
+		return "", ErrClosed
+	}
+	c.mu.Lock()
+	c.pending = append(c.pending, msg)
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn != nil {
+		// A failed write is retried after the reconnect it leads to
+		c.write(conn, msg)
+	}
+	return msg.ClientMsgID, nil
+}
+
+// Send sends any frame, such as a vote or a lobby command, as is. Unlike
+// SendMessage it isn't retried; it fails with ErrNotConnected while the
+// client is reconnecting.
+func (c *Client) Send(frame models.Message) error {
+	if c.ctx.Err() != nil {
+		return ErrClosed
+	}
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+	return c.write(conn, frame)
+}
+
+// Close disconnects the client and waits for its reader to stop.
+func (c *Client) Close() error {
+	c.cancel()
+	<-c.done
+	return nil
+}
+
+// Done is closed once the client stopped for good.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err says why the client stopped: nil after Close, ErrLobbyEnded,
+// ErrLoggedInElsewhere, or the server's *Error refusing to reconnect, e.g. after a
+// kick.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) write(conn *websocket.Conn, frame models.Message) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(config.WriteTimeout))
+	return conn.WriteJSON(frame)
+}
+
+// run reads the connection and reconnects whenever it drops.
+func (c *Client) run(conn *websocket.Conn) {
+	defer close(c.done)
+	for {
+		err := c.read(conn)
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		conn.Close()
+
+		if c.ctx.Err() != nil {
+			return
+		}
+		if permanent(err) {
+			c.stop(err)
+			return
+		}
+		if conn, err = c.reconnect(err); err != nil {
+			if c.ctx.Err() == nil {
+				c.stop(err)
+			}
+			return
+		}
+	}
+}
+
+func (c *Client) stop(err error) {
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+	c.cancel()
+}
+
+// reconnect redials with backoff until it gets through, the seat turns out
+// to be lost, or the client is closed.
+func (c *Client) reconnect(cause error) (*websocket.Conn, error) {
+	backoff := c.opts.MinBackoff
+	for {
+		// Jitter spreads out the clients of a restarted server
+		wait := backoff/2 + rand.N(backoff/2+1)
+		select {
+		case <-c.ctx.Done():
+			return nil, c.ctx.Err()
+		case <-time.After(wait):
+		}
+
+		conn, err := c.dial()
+		if err == nil {
+			if c.opts.OnReconnect != nil {
+				c.opts.OnReconnect(cause)
+			}
+			return conn, nil
+		}
+		if permanent(err) || c.ctx.Err() != nil {
+			return nil, err
+		}
+		backoff = min(2*backoff, c.opts.MaxBackoff)
+	}
+}
+
+// dial connects from the last seq seen, waits for the welcome and resends
+// the messages not acked yet.
+func (c *Client) dial() (*websocket.Conn, error) {
+	ticket, err := c.connectTicket()
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("ticket", ticket)
+	params.Set("last_seq", strconv.FormatInt(c.LastSeq(), 10))
+	params.Set("replay", "batched")
+	wsURL := "ws" + strings.TrimPrefix(c.baseURL, "http") + "/ws?" + params.Encode()
+
+	header := http.Header{}
+	if c.seat.TenantID != "" {
+		header.Set(config.TenantHeader, c.seat.TenantID)
+	}
+	conn, resp, err := c.opts.Dialer.DialContext(c.ctx, wsURL, header)
+	if err != nil {
+		if resp != nil {
+			defer resp.Body.Close()
+			var body bytes.Buffer
+			body.ReadFrom(resp.Body)
+			return nil, responseError(resp.StatusCode, body.Bytes())
+		}
+		return nil, err
+	}
+
+	// The lobby only delivers to the connection once it has welcomed it
+	conn.SetReadDeadline(time.Now().Add(3 * c.opts.PingInterval))
+	for {
+		var frame models.Message
+		if err := conn.ReadJSON(&frame); err != nil {
+			conn.Close()
+			return nil, closeError(err)
+		}
+		c.dispatch(frame)
+		if frame.SystemAction != nil && *frame.SystemAction == models.SystemActionWelcome {
+			break
+		}
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	pending := append([]models.Message(nil), c.pending...)
+	c.mu.Unlock()
+	for _, msg := range pending {
+		if err := c.write(conn, msg); err != nil {
+			break
+		}
+	}
+	return conn, nil
+}
+
+// read dispatches the frames of a connection until it fails, pinging it
+// meanwhile.
+func (c *Client) read(conn *websocket.Conn) error {
+	stopPing := make(chan struct{})
+	defer close(stopPing)
+	go c.ping(conn, stopPing)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(3 * c.opts.PingInterval))
+		var frame models.Message
+		if err := conn.ReadJSON(&frame); err != nil {
+			return closeError(err)
+		}
+		if frame.SystemAction != nil && *frame.SystemAction == models.SystemActionLobbyEnded {
+			c.dispatch(frame)
+			return ErrLobbyEnded
+		}
+		if frame.Type == models.MessageTypeHistoryBatch {
+			if err := c.replayBatch(conn, frame); err != nil {
+				return err
+			}
+		} else {
+			c.dispatch(frame)
+		}
+		if err := c.ackDelivery(conn); err != nil {
+			return err
+		}
+	}
+}
+
+// ackDelivery acks every frame seen so far, if the lobby wants acks and
+// there is anything new to ack.
+func (c *Client) ackDelivery(conn *websocket.Conn) error {
+	c.mu.Lock()
+	seq := c.lastSeq
+	due := c.reliable && seq > c.acked
+	if due {
+		c.acked = seq
+	}
+	c.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return c.write(conn, models.Message{Action: models.MessageTypeDeliveryAck, Seq: seq})
+}
+
+// replayBatch dispatches the messages of a history batch and acks it, so
+// the server sends the next ones.
+func (c *Client) replayBatch(conn *websocket.Conn, frame models.Message) error {
+	for _, msg := range frame.History {
+		c.dispatch(msg)
+	}
+	if frame.Replay == nil {
+		return nil
+	}
+	return c.write(conn, models.Message{Action: models.MessageTypeHistoryAck, Replay: &models.ReplayProgress{Batch: frame.Replay.Batch}})
+}
+
+// ping sends heartbeats, which the server answers with a pong, so a
+// silent connection shows up as a read timeout.
+func (c *Client) ping(conn *websocket.Conn, stop <-chan struct{}) {
+	ticker := time.NewTicker(c.opts.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-c.ctx.Done():
+			// Closing ends the read, and with it the reader
+			c.writeMu.Lock()
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			c.writeMu.Unlock()
+			conn.Close()
+			return
+		case <-ticker.C:
+			if err := c.write(conn, models.Message{Action: models.MessageTypePing, ClientTs: time.Now().UnixMilli()}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// dispatch hands a frame to the callbacks, skipping chat that a replay
+// delivers again and frames a reliable lobby sent again after they came.
+func (c *Client) dispatch(frame models.Message) {
+	if frame.Type == models.MessageTypePong {
+		return
+	}
+
+	c.mu.Lock()
+	if (isChat(frame) || frame.Redelivered) && frame.Seq > 0 && frame.Seq <= c.lastSeq {
+		c.mu.Unlock()
+		return
+	}
+	if frame.Settings != nil {
+		c.reliable = frame.Settings.ReliableDelivery
+	}
+	if frame.Seq > c.lastSeq {
+		c.lastSeq = frame.Seq
+	}
+	if frame.SystemAction != nil && *frame.SystemAction == models.SystemActionAck {
+		for i, msg := range c.pending {
+			if msg.ClientMsgID == frame.ClientMsgID {
+				c.pending = append(c.pending[:i], c.pending[i+1:]...)
+				break
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	if c.opts.OnFrame != nil {
+		c.opts.OnFrame(frame)
+	}
+	switch {
+	case isChat(frame):
+		if c.opts.OnMessage != nil {
+			c.opts.OnMessage(frame)
+		}
+	case isPresence(frame):
+		if c.opts.OnPresence != nil {
+			c.opts.OnPresence(frame)
+		}
+	}
+}
+
+func isChat(frame models.Message) bool {
+	switch frame.Type {
+	case models.MessageTypeChat, models.MessageTypeReply, models.MessageTypeIdea:
+		return true
+	}
+	return false
+}
+
+func isPresence(frame models.Message) bool {
+	if frame.SystemAction == nil {
+		return false
+	}
+	switch *frame.SystemAction {
+	case models.SystemActionUserJoined, models.SystemActionUserLeft, models.SystemActionUserTimedOut,
+		models.SystemActionPresence, models.SystemActionRosterDigest:
+		return true
+	}
+	return false
+}
+
+func newClientMsgID() string {
+	id := make([]byte, 16)
+	if _, err := cryptorand.Read(id); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,108 @@
+// Command codecbench compares the WebSocket frame encodings: the cost of
+// encoding and decoding typical frames, and their size on the wire.
+//
+//	go run ./cmd/codecbench
+package main
+
+import (
+	"chat-integrated/codec"
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"fmt"
+	"os"
+	"testing"
+	"text/tabwriter"
+	"time"
+)
+
+func main() {
+	frames := []struct {
+		name string
+		msg  models.Message
+	}{
+		{"chat", chatFrame()},
+		{"welcome", welcomeFrame()},
+	}
+	codecs := []codec.Codec{codec.JSON, codec.MessagePack, codec.Protobuf}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(out, "frame\tcodec\tbytes\tencode ns/op\tencode allocs\tdecode ns/op\tdecode allocs\t")
+	for _, frame := range frames {
+		for _, c := range codecs {
+			data, err := c.Marshal(frame.msg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", c.Subprotocol(), err)
+				os.Exit(1)
+			}
+			var decoded models.Message
+			if err := c.Unmarshal(data, &decoded); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", c.Subprotocol(), err)
+				os.Exit(1)
+			}
+
+			encode := testing.Benchmark(func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					c.Marshal(frame.msg)
+				}
+			})
+			decode := testing.Benchmark(func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					var msg models.Message
+					c.Unmarshal(data, &msg)
+				}
+			})
+			fmt.Fprintf(out, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t\n", frame.name, c.Subprotocol(), len(data),
+				encode.NsPerOp(), encode.AllocsPerOp(), decode.NsPerOp(), decode.AllocsPerOp())
+		}
+	}
+	out.Flush()
+}
+
+// chatFrame is a chat message as members receive it.
+func chatFrame() models.Message {
+	return models.Message{
+		Type:        models.MessageTypeChat,
+		Username:    "m_3f2a9c0d1b7e4a65",
+		DisplayName: "Alice",
+		Content:     "What if the onboarding checklist lived in the lobby sidebar?",
+		LobbyID:     "lobby-01JAZ6Q8M3X4T2R9V5K7N1P0WB",
+		Mentions:    []string{"m_9b1c2d3e4f5a6b7c"},
+		Profiles: map[string]models.Profile{
+			"m_3f2a9c0d1b7e4a65": {DisplayName: "Alice", AvatarURL: "https://example.com/alice.png"},
+		},
+		Seq:       42,
+		Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// welcomeFrame is the welcome message of a full lobby, the largest frame
+// most clients get.
+func welcomeFrame() models.Message {
+	welcome := models.SystemActionWelcome
+	msg := models.Message{
+		Type:         models.MessageTypeSystemAction,
+		SystemAction: &welcome,
+		Content:      "Welcome to the lobby!",
+		LobbyID:      "lobby-01JAZ6Q8M3X4T2R9V5K7N1P0WB",
+		UserCount:    config.MaxUsersPerLobby,
+		MaxUsers:     config.MaxUsersPerLobby,
+		Roles:        make(map[string]models.Role),
+		Profiles:     make(map[string]models.Profile),
+		Pinned:       []int64{3, 17},
+		Reactions:    map[string]int{"👍": 4, "🎉": 2},
+		Threads:      map[int64]int{3: 2, 17: 5},
+		Budget:       &models.BudgetStatus{BudgetSeconds: 3600, ElapsedSeconds: 1260, Percent: 35, HourlyRate: 120, Cost: 42},
+		MemberID:     "m_3f2a9c0d1b7e4a65",
+		Seq:          42,
+		Timestamp:    time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	for i := 0; i < config.MaxUsersPerLobby; i++ {
+		id := fmt.Sprintf("m_%016x", i+1)
+		msg.UserList = append(msg.UserList, id)
+		msg.Roles[id] = models.RoleUser
+		msg.Profiles[id] = models.Profile{DisplayName: fmt.Sprintf("Member %d", i+1)}
+	}
+	return msg
+}
@@ -0,0 +1,464 @@
+// Command loadtest runs simulated users against a chat server: each logs in,
+// connects to /ws, chats at a steady rate and now and then drops its
+// connection and resumes it, like a flaky network would. It reports the
+// connect and send-to-ack latency percentiles and the errors seen.
+//
+//	go run ./cmd/loadtest -url http://localhost:8080 -clients 50 -duration 2m
+//
+// Users are spread over -tenants tenants, each running its own lobby; users
+// beyond a lobby's seats wait in its queue until one frees up. Point it at a
+// server started for the test with OPEN_LOGIN=true, since the users have no
+// accounts, and as the users and their messages are kept.
+package main
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/handlers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	baseURL    = flag.String("url", "http://localhost:8080", "base URL of the server")
+	clients    = flag.Int("clients", 20, "number of simulated users")
+	tenants    = flag.Int("tenants", 0, "tenants to spread the users over, 0 for one per lobby's worth of users")
+	duration   = flag.Duration("duration", time.Minute, "how long to run")
+	rampUp     = flag.Duration("ramp", 5*time.Second, "time over which the users start")
+	rate       = flag.Float64("rate", 0.5, "chat messages per second per user")
+	dropRate   = flag.Float64("drop", 0.01, "chance per second that a user's connection drops")
+	ackTimeout = flag.Duration("ack-timeout", 5*time.Second, "how long a message may wait for its ack")
+	report     = flag.Duration("report", 5*time.Second, "interval of progress lines, 0 for none")
+)
+
+// stats collects what every user measured.
+type stats struct {
+	mu       sync.Mutex
+	connects []time.Duration
+	acks     []time.Duration
+	errors   map[string]int
+
+	sent, acked, received, drops, queued atomic.Int64
+}
+
+func (s *stats) fail(kind string) {
+	s.mu.Lock()
+	s.errors[kind]++
+	s.mu.Unlock()
+}
+
+func (s *stats) observe(samples *[]time.Duration, d time.Duration) {
+	s.mu.Lock()
+	*samples = append(*samples, d)
+	s.mu.Unlock()
+}
+
+// user is one simulated member.
+type user struct {
+	email    string
+	tenantID string
+	stats    *stats
+
+	lobbyID string
+	token   string
+	lastSeq atomic.Int64
+
+	mu      sync.Mutex
+	pending map[string]pendingMessage
+}
+
+// pendingMessage is a chat frame waiting for its ack. It is sent again
+// after a reconnect with the same client message ID.
+type pendingMessage struct {
+	frame  []byte
+	sentAt time.Time
+}
+
+func main() {
+	flag.Parse()
+	if *tenants <= 0 {
+		*tenants = (*clients + config.MaxUsersPerLobby - 1) / config.MaxUsersPerLobby
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	st := &stats{errors: make(map[string]int)}
+	runID := time.Now().Unix()
+	fmt.Printf("🚀 %d users over %d tenants against %s for %s (%.2f msg/s each, %.1f%% drops/s)\n",
+		*clients, *tenants, *baseURL, *duration, *rate, *dropRate*100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < *clients; i++ {
+		u := &user{
+			email:    fmt.Sprintf("load-%d-%d@loadtest.local", runID, i),
+			tenantID: fmt.Sprintf("load-%d-%d", runID, i%*tenants),
+			stats:    st,
+			pending:  make(map[string]pendingMessage),
+		}
+		delay := time.Duration(0)
+		if *clients > 1 {
+			delay = *rampUp * time.Duration(i) / time.Duration(*clients-1)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-time.After(delay):
+				u.run(ctx)
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	if *report > 0 {
+		go progress(ctx, st)
+	}
+	wg.Wait()
+	summarize(st)
+}
+
+// run logs the user in and keeps them chatting until ctx is done,
+// reconnecting after every drop.
+func (u *user) run(ctx context.Context) {
+	if err := u.login(ctx); err != nil {
+		if ctx.Err() == nil {
+			u.stats.fail("login: " + err.Error())
+		}
+		return
+	}
+
+	for ctx.Err() == nil {
+		started := time.Now()
+		conn, err := u.dial(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				u.stats.fail("dial: " + err.Error())
+				sleep(ctx, time.Second)
+			}
+			continue
+		}
+		dropped := u.chat(ctx, conn, started)
+		conn.Close()
+		if dropped {
+			u.stats.drops.Add(1)
+			sleep(ctx, time.Duration(rand.Int64N(int64(2*time.Second))))
+		}
+	}
+}
+
+// login takes a seat, waiting in the queue if the lobby is full.
+func (u *user) login(ctx context.Context) error {
+	body, _ := json.Marshal(handlers.LoginRequest{Email: u.email})
+	req, _ := http.NewRequestWithContext(ctx, "POST", *baseURL+"/api/login", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(config.TenantHeader, u.tenantID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var login handlers.LoginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		u.lobbyID, u.token = login.LobbyID, login.ReconnectToken
+		return nil
+	case http.StatusAccepted:
+		u.stats.queued.Add(1)
+		return u.waitInQueue(ctx, login.Ticket)
+	default:
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+}
+
+func (u *user) waitInQueue(ctx context.Context, ticket string) error {
+	for {
+		if !sleep(ctx, time.Second) {
+			return ctx.Err()
+		}
+		req, _ := http.NewRequestWithContext(ctx, "GET", *baseURL+"/api/queue?ticket="+url.QueryEscape(ticket), nil)
+		req.Header.Set(config.TenantHeader, u.tenantID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		var status services.QueueStatus
+		err = json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || err != nil {
+			return fmt.Errorf("queue status %d", resp.StatusCode)
+		}
+		if status.Admitted {
+			u.lobbyID, u.token = status.LobbyID, status.ReconnectToken
+			return nil
+		}
+	}
+}
+
+// connectTicket gets the one-time ticket of the next connection.
+func (u *user) connectTicket(ctx context.Context) (string, error) {
+	query := url.Values{}
+	query.Set("email", u.email)
+	query.Set("token", u.token)
+	req, _ := http.NewRequestWithContext(ctx, "POST", *baseURL+"/api/lobbies/"+url.PathEscape(u.lobbyID)+"/connect-ticket?"+query.Encode(), nil)
+	req.Header.Set(config.TenantHeader, u.tenantID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var ticket handlers.ConnectTicketResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ticket); err != nil || resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("connect ticket status %d", resp.StatusCode)
+	}
+	return ticket.ConnectTicket, nil
+}
+
+func (u *user) dial(ctx context.Context) (*websocket.Conn, error) {
+	ticket, err := u.connectTicket(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("ticket", ticket)
+	params.Set("last_seq", fmt.Sprint(u.lastSeq.Load()))
+	wsURL := "ws" + strings.TrimPrefix(*baseURL, "http") + "/ws?" + params.Encode()
+
+	header := http.Header{}
+	header.Set(config.TenantHeader, u.tenantID)
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, header)
+	return conn, err
+}
+
+// chat sends messages on conn until ctx is done or the connection drops,
+// reporting whether it was dropped on purpose or by the server.
+func (u *user) chat(ctx context.Context, conn *websocket.Conn, started time.Time) bool {
+	welcomed := make(chan struct{})
+	closed := make(chan struct{})
+	go u.read(conn, started, welcomed, closed)
+
+	select {
+	case <-welcomed:
+	case <-closed:
+		u.stats.fail("closed before welcome")
+		return true
+	case <-ctx.Done():
+		return false
+	}
+	if err := u.resend(conn); err != nil {
+		u.stats.fail("write: " + err.Error())
+		return true
+	}
+
+	interval := time.Duration(float64(time.Second) / max(*rate, 0.001))
+	send := time.NewTicker(interval)
+	defer send.Stop()
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+
+	for n := 0; ; {
+		select {
+		case <-ctx.Done():
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			return false
+		case <-closed:
+			u.stats.fail("connection closed by server")
+			return true
+		case <-tick.C:
+			u.expireAcks()
+			if rand.Float64() < *dropRate {
+				return true
+			}
+		case <-send.C:
+			n++
+			clientMsgID := fmt.Sprintf("%s-%d-%d", u.email, time.Now().UnixNano(), n)
+			frame, _ := json.Marshal(models.Message{
+				Action:      models.MessageTypeChat,
+				Content:     fmt.Sprintf("load message %d from %s", n, u.email),
+				ClientMsgID: clientMsgID,
+			})
+			u.mu.Lock()
+			u.pending[clientMsgID] = pendingMessage{frame: frame, sentAt: time.Now()}
+			u.mu.Unlock()
+			conn.SetWriteDeadline(time.Now().Add(*ackTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+				u.stats.fail("write: " + err.Error())
+				return true
+			}
+			u.stats.sent.Add(1)
+		}
+	}
+}
+
+// read handles the frames of one connection: the welcome, acks of the
+// user's messages and error notices.
+func (u *user) read(conn *websocket.Conn, started time.Time, welcomed, closed chan struct{}) {
+	defer close(closed)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg models.Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			u.stats.fail("invalid frame")
+			continue
+		}
+		u.stats.received.Add(1)
+		if msg.Seq > u.lastSeq.Load() {
+			u.lastSeq.Store(msg.Seq)
+		}
+		if msg.SystemAction == nil {
+			continue
+		}
+
+		switch *msg.SystemAction {
+		case models.SystemActionWelcome:
+			u.stats.observe(&u.stats.connects, time.Since(started))
+			close(welcomed)
+		case models.SystemActionAck:
+			u.mu.Lock()
+			pending, ok := u.pending[msg.ClientMsgID]
+			delete(u.pending, msg.ClientMsgID)
+			u.mu.Unlock()
+			if ok {
+				u.stats.acked.Add(1)
+				u.stats.observe(&u.stats.acks, time.Since(pending.sentAt))
+			}
+		case models.SystemActionError, models.SystemActionModerated, models.SystemActionSlowMode:
+			u.stats.fail("server: " + msg.Content)
+		case models.SystemActionCooldown:
+			// The lobby-wide notice has no code; a held message has
+			if msg.Code != "" {
+				u.stats.fail("server: " + msg.Content)
+			}
+		}
+	}
+}
+
+// resend writes the messages still waiting for an ack on a new connection.
+// Their latency keeps counting from the first send.
+func (u *user) resend(conn *websocket.Conn) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, pending := range u.pending {
+		conn.SetWriteDeadline(time.Now().Add(*ackTimeout))
+		if err := conn.WriteMessage(websocket.TextMessage, pending.frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireAcks counts the messages that waited longer than -ack-timeout.
+func (u *user) expireAcks() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for id, pending := range u.pending {
+		if time.Since(pending.sentAt) > *ackTimeout {
+			delete(u.pending, id)
+			u.stats.fail("ack timeout")
+		}
+	}
+}
+
+func progress(ctx context.Context, st *stats) {
+	ticker := time.NewTicker(*report)
+	defer ticker.Stop()
+	started := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			st.mu.Lock()
+			failures := 0
+			for _, count := range st.errors {
+				failures += count
+			}
+			connected := len(st.connects)
+			st.mu.Unlock()
+			fmt.Printf("⏱️ %5s  connects %d  sent %d  acked %d  received %d  drops %d  errors %d\n",
+				time.Since(started).Round(time.Second), connected, st.sent.Load(), st.acked.Load(), st.received.Load(), st.drops.Load(), failures)
+		}
+	}
+}
+
+func summarize(st *stats) {
+	fmt.Println()
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(out, "latency\tcount\tp50\tp90\tp99\tmax\t")
+	for _, row := range []struct {
+		name    string
+		samples []time.Duration
+	}{
+		{"connect", st.connects},
+		{"send→ack", st.acks},
+	} {
+		sort.Slice(row.samples, func(i, j int) bool { return row.samples[i] < row.samples[j] })
+		fmt.Fprintf(out, "%s\t%d\t%s\t%s\t%s\t%s\t\n", row.name, len(row.samples),
+			percentile(row.samples, 50), percentile(row.samples, 90), percentile(row.samples, 99), percentile(row.samples, 100))
+	}
+	out.Flush()
+
+	fmt.Printf("\nsent %d, acked %d, frames received %d, drops %d, queued logins %d\n",
+		st.sent.Load(), st.acked.Load(), st.received.Load(), st.drops.Load(), st.queued.Load())
+	if len(st.errors) == 0 {
+		fmt.Println("✅ no errors")
+		return
+	}
+	kinds := make([]string, 0, len(st.errors))
+	for kind := range st.errors {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return st.errors[kinds[i]] > st.errors[kinds[j]] })
+	fmt.Println("❌ errors:")
+	for _, kind := range kinds {
+		fmt.Printf("  %6d  %s\n", st.errors[kind], kind)
+	}
+	if st.acked.Load() < st.sent.Load()/2 {
+		os.Exit(1)
+	}
+}
+
+// percentile reads the p-th percentile of sorted samples.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p + 99) / 100
+	return sorted[max(0, min(i, len(sorted))-1)].Round(10 * time.Microsecond)
+}
+
+// sleep waits for d, reporting false if ctx ended first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
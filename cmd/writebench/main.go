@@ -0,0 +1,245 @@
+// Command writebench compares the writes WritePump makes for a burst of
+// queued frames with and without batched writes: WebSocket messages, socket
+// writes (one syscall each) and bytes, over a real loopback connection.
+//
+//	go run ./cmd/writebench
+package main
+
+import (
+	"chat-integrated/codec"
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/server"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var burstSize = flag.Int("burst", 200, "frames queued per burst")
+
+const lobbyID = "lobby-writebench"
+
+func main() {
+	flag.Parse()
+	log.SetOutput(io.Discard)
+
+	cfg := server.DefaultConfig()
+	cfg.Name = "writebench"
+	cfg.StoreBackend = "memory"
+	cfg.ProbeEnabled = false
+	cfg.GRPCAddr = ""
+	hub := server.NewHub(cfg)
+	defer hub.Close()
+	wsc := controllers.NewWSController(hub.Lobbies, hub.Policy, hub.Invites, nil)
+
+	bursts := []struct {
+		name   string
+		frames []models.Message
+	}{
+		{"chat", chatBurst(*burstSize)},
+		{"presence", presenceBurst(*burstSize)},
+		{"mixed", mixedBurst(*burstSize)},
+	}
+	codecs := []codec.Codec{codec.JSON, codec.MessagePack, codec.Protobuf}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(out, "burst\tcodec\tmode\tframes\tmessages\tsyscalls\tbytes\ttime\t")
+	for _, burst := range bursts {
+		for _, c := range codecs {
+			for _, batched := range []bool{false, true} {
+				res, err := run(wsc, c, batched, burst.frames)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s %s: %v\n", burst.name, c.Subprotocol(), err)
+					os.Exit(1)
+				}
+				mode := "single"
+				if batched {
+					mode = "batched"
+				}
+				fmt.Fprintf(out, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\t\n", burst.name, c.Subprotocol(), mode,
+					res.frames, res.messages, res.syscalls, res.bytes, res.elapsed.Round(time.Microsecond))
+			}
+		}
+	}
+	out.Flush()
+}
+
+type result struct {
+	frames, messages int
+	syscalls, bytes  int64
+	elapsed          time.Duration
+}
+
+// run queues frames for one connection, closes its queue and lets
+// WritePump drain it, counting the writes on the server's socket after the
+// handshake.
+func run(wsc *controllers.WSController, c codec.Codec, batched bool, frames []models.Message) (result, error) {
+	var res result
+	var writes, written atomic.Int64
+	done := make(chan struct{})
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsc.UpgradeConnection(w, r)
+		if err != nil {
+			return
+		}
+		client := &models.Client{
+			Email:         "reader@example.com",
+			LobbyID:       lobbyID,
+			Conn:          conn,
+			Send:          make(chan models.Message, len(frames)),
+			Encoding:      conn.Subprotocol(),
+			BatchedWrites: batched,
+		}
+		for _, frame := range frames {
+			client.Send <- frame
+		}
+		close(client.Send)
+		writes.Store(0)
+		written.Store(0)
+		started := time.Now()
+		wsc.WritePump(client)
+		res.elapsed = time.Since(started)
+		close(done)
+	}))
+	srv.Listener = countingListener{srv.Listener, &writes, &written}
+	srv.Start()
+	defer srv.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{c.Subprotocol()}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		return res, err
+	}
+	defer conn.Close()
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		res.messages++
+		res.frames += frameCount(c, data)
+	}
+	<-done
+	res.syscalls, res.bytes = writes.Load(), written.Load()
+	return res, nil
+}
+
+// frameCount is the number of frames in a message: the length of a batch
+// array, 1 for a single frame.
+func frameCount(c codec.Codec, data []byte) int {
+	switch {
+	case c == codec.JSON && len(data) > 0 && data[0] == '[':
+		var batch []json.RawMessage
+		if err := json.Unmarshal(data, &batch); err == nil {
+			return len(batch)
+		}
+	case c == codec.MessagePack && len(data) > 0:
+		switch b := data[0]; {
+		case b&0xf0 == 0x90:
+			return int(b & 0x0f)
+		case b == 0xdc && len(data) >= 3:
+			return int(binary.BigEndian.Uint16(data[1:]))
+		case b == 0xdd && len(data) >= 5:
+			return int(binary.BigEndian.Uint32(data[1:]))
+		}
+	}
+	return 1
+}
+
+// countingListener counts the writes made on the connections it accepts.
+type countingListener struct {
+	net.Listener
+	writes, written *atomic.Int64
+}
+
+func (l countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{conn, l.writes, l.written}, nil
+}
+
+type countingConn struct {
+	net.Conn
+	writes, written *atomic.Int64
+}
+
+func (c countingConn) Write(b []byte) (int, error) {
+	c.writes.Add(1)
+	c.written.Add(int64(len(b)))
+	return c.Conn.Write(b)
+}
+
+var members = []string{"alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com", "erin@example.com"}
+
+// chatBurst is n chat messages, as a busy lobby queues them.
+func chatBurst(n int) []models.Message {
+	frames := make([]models.Message, n)
+	for i := range frames {
+		frames[i] = models.Message{
+			Type:      models.MessageTypeChat,
+			Username:  members[i%len(members)],
+			Content:   fmt.Sprintf("Idea %d: what if the onboarding checklist lived in the lobby sidebar?", i),
+			LobbyID:   lobbyID,
+			Seq:       int64(i + 1),
+			Timestamp: time.Now(),
+		}
+	}
+	return frames
+}
+
+// presenceBurst is n presence_changed frames of members flapping between
+// away and online, as when many tabs are hidden and shown.
+func presenceBurst(n int) []models.Message {
+	frames := make([]models.Message, n)
+	for i := range frames {
+		frames[i] = presenceFrame(members[i%len(members)], i/len(members))
+	}
+	return frames
+}
+
+// mixedBurst alternates chat messages and presence changes.
+func mixedBurst(n int) []models.Message {
+	chat, presence := chatBurst(n/2), presenceBurst(n-n/2)
+	frames := make([]models.Message, 0, n)
+	for i := range presence {
+		if i < len(chat) {
+			frames = append(frames, chat[i])
+		}
+		frames = append(frames, presence[i])
+	}
+	return frames
+}
+
+func presenceFrame(email string, round int) models.Message {
+	action := models.SystemActionPresence
+	presence := models.PresenceOnline
+	if round%2 == 0 {
+		presence = models.PresenceAway
+	}
+	return models.Message{
+		Type:         models.MessageTypeSystemAction,
+		SystemAction: &action,
+		Username:     email,
+		Target:       email,
+		Presence:     presence,
+		Content:      fmt.Sprintf("%s is %s", email, presence),
+		LobbyID:      lobbyID,
+		Timestamp:    time.Now(),
+	}
+}
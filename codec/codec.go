@@ -0,0 +1,81 @@
+// Package codec frames WebSocket messages. Clients pick an encoding by
+// offering its subprotocol in the handshake; JSON is used when they offer
+// none.
+package codec
+
+import (
+	"bytes"
+	"chat-integrated/models"
+	"encoding/json"
+)
+
+// Codec encodes frames for one encoding.
+type Codec interface {
+	// Subprotocol is the Sec-WebSocket-Protocol value that selects it
+	Subprotocol() string
+	// MessageType is the WebSocket message type frames are sent as
+	MessageType() int
+	Marshal(msg models.Message) ([]byte, error)
+	Unmarshal(data []byte, msg *models.Message) error
+}
+
+// Batcher is implemented by codecs that can send several encoded frames as
+// one WebSocket message, for connections that asked for batched frames.
+type Batcher interface {
+	Batch(frames [][]byte) []byte
+}
+
+// JSON is the default encoding, readable by any client.
+var JSON Codec = jsonCodec{}
+
+// MessagePack encodes frames as MessagePack maps with the JSON field names.
+var MessagePack Codec = msgpackCodec{}
+
+// Protobuf encodes frames as the Frame message of frame.proto.
+var Protobuf Codec = protobufCodec{}
+
+// codecs lists every encoding served. A client offering several gets the
+// first of its own list.
+var codecs = []Codec{Protobuf, MessagePack, JSON}
+
+// Subprotocols lists the subprotocols the server accepts.
+func Subprotocols() []string {
+	names := make([]string, len(codecs))
+	for i, codec := range codecs {
+		names[i] = codec.Subprotocol()
+	}
+	return names
+}
+
+// ForSubprotocol returns the codec a negotiated subprotocol selects, JSON
+// if none was.
+func ForSubprotocol(subprotocol string) Codec {
+	for _, codec := range codecs {
+		if codec.Subprotocol() == subprotocol {
+			return codec
+		}
+	}
+	return JSON
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Subprotocol() string { return "chat.json" }
+func (jsonCodec) MessageType() int    { return models.TextMessage }
+
+func (jsonCodec) Marshal(msg models.Message) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (jsonCodec) Unmarshal(data []byte, msg *models.Message) error {
+	return json.Unmarshal(data, msg)
+}
+
+// Batch joins the frames into a JSON array.
+func (jsonCodec) Batch(frames [][]byte) []byte {
+	var b bytes.Buffer
+	b.WriteByte('[')
+	b.Write(bytes.Join(frames, []byte{','}))
+	b.WriteByte(']')
+	return b.Bytes()
+}
@@ -0,0 +1,155 @@
+package codec
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// field is a struct field as the codecs see it: named by its json tag and
+// numbered by its proto tag.
+type field struct {
+	index     []int
+	name      string
+	omitEmpty bool
+	omitZero  bool
+	number    protowire.Number
+}
+
+// structFields caches the fields of each struct type.
+var structFields sync.Map
+
+func fieldsOf(t reflect.Type) []field {
+	if cached, ok := structFields.Load(t); ok {
+		return cached.([]field)
+	}
+	fields := appendFields(nil, t, nil)
+	structFields.Store(t, fields)
+	return fields
+}
+
+// appendFields follows encoding/json: "-" skips a field, untagged embedded
+// structs are flattened and unexported fields are left out.
+func appendFields(fields []field, t reflect.Type, index []int) []field {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, options, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		fieldIndex := append(append([]int(nil), index...), i)
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			fields = appendFields(fields, sf.Type, fieldIndex)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		number, _ := strconv.Atoi(sf.Tag.Get("proto"))
+		fields = append(fields, field{
+			index:     fieldIndex,
+			name:      name,
+			omitEmpty: strings.Contains(options, "omitempty"),
+			omitZero:  strings.Contains(options, "omitzero"),
+			number:    protowire.Number(number),
+		})
+	}
+	return fields
+}
+
+// fieldNamed finds the field encoding/json would decode name into: an
+// exact match, or else one differing only in case.
+func fieldNamed(fields []field, name string) (field, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// omitted reports whether the field's options leave v out, as in
+// encoding/json.
+func (f field) omitted(v reflect.Value) bool {
+	return (f.omitEmpty && isEmpty(v)) || (f.omitZero && v.IsZero())
+}
+
+// isEmpty reports whether omitempty leaves v out, as in encoding/json.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// sortedKeys returns a map's keys in a stable order, so equal frames encode
+// to equal bytes.
+func sortedKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return mapKey(keys[i]) < mapKey(keys[j])
+	})
+	return keys
+}
+
+// mapKey spells a map key the way encoding/json does.
+func mapKey(key reflect.Value) string {
+	switch key.Kind() {
+	case reflect.String:
+		return key.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(key.Uint(), 10)
+	}
+	return ""
+}
+
+// setMapKey parses a map key spelled by mapKey into key.
+func setMapKey(key reflect.Value, s string) error {
+	switch key.Kind() {
+	case reflect.String:
+		key.SetString(s)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || key.OverflowInt(n) {
+			return errInvalidKey(s, key.Type())
+		}
+		key.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || key.OverflowUint(n) {
+			return errInvalidKey(s, key.Type())
+		}
+		key.SetUint(n)
+		return nil
+	}
+	return errInvalidKey(s, key.Type())
+}
+
+func errInvalidKey(key string, t reflect.Type) error {
+	return fmt.Errorf("codec: cannot use %q as a %s map key", key, t)
+}
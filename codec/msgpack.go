@@ -0,0 +1,513 @@
+package codec
+
+import (
+	"chat-integrated/models"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// maxDepth bounds how deeply a received frame may nest
+const maxDepth = 32
+
+// timestampExt is the MessagePack extension type for timestamps
+const timestampExt int8 = -1
+
+var errTruncated = errors.New("codec: truncated MessagePack data")
+
+// msgpackCodec encodes frames as maps keyed by the JSON field names, so a
+// frame decodes to the same object it would as JSON. Times use the
+// timestamp extension.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Subprotocol() string { return "chat.msgpack" }
+func (msgpackCodec) MessageType() int    { return models.BinaryMessage }
+
+func (msgpackCodec) Marshal(msg models.Message) ([]byte, error) {
+	return appendMsgpack(make([]byte, 0, 256), reflect.ValueOf(msg))
+}
+
+func (msgpackCodec) Unmarshal(data []byte, msg *models.Message) error {
+	value, rest, err := decodeMsgpack(data, 0)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errors.New("codec: trailing data after MessagePack frame")
+	}
+	return assign(reflect.ValueOf(msg).Elem(), value)
+}
+
+// Batch puts the frames in a MessagePack array.
+func (msgpackCodec) Batch(frames [][]byte) []byte {
+	size := 5
+	for _, frame := range frames {
+		size += len(frame)
+	}
+	b := appendHeader(make([]byte, 0, size), len(frames), 0x90, 0, 0xdc, 0xdd)
+	for _, frame := range frames {
+		b = append(b, frame...)
+	}
+	return b
+}
+
+func appendMsgpack(b []byte, v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return append(b, 0xc0), nil
+	}
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		b = append(b, 0xc7, 12, 0xff) // ext 8 of type -1
+		b = binary.BigEndian.AppendUint32(b, uint32(t.Nanosecond()))
+		return binary.BigEndian.AppendUint64(b, uint64(t.Unix())), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		return appendMsgpack(b, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendInt(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendUint(b, v.Uint()), nil
+	case reflect.Float32:
+		return binary.BigEndian.AppendUint32(append(b, 0xca), math.Float32bits(float32(v.Float()))), nil
+	case reflect.Float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v.Float())), nil
+	case reflect.String:
+		return appendString(b, v.String()), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return append(appendHeader(b, v.Len(), 0, 0xc4, 0xc5, 0xc6), v.Bytes()...), nil
+		}
+		b = appendHeader(b, v.Len(), 0x90, 0, 0xdc, 0xdd)
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			if b, err = appendMsgpack(b, v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		b = appendHeader(b, v.Len(), 0x80, 0, 0xde, 0xdf)
+		var err error
+		for _, key := range sortedKeys(v) {
+			b = appendString(b, mapKey(key))
+			if b, err = appendMsgpack(b, v.MapIndex(key)); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Struct:
+		var present []field
+		for _, f := range fieldsOf(v.Type()) {
+			if !f.omitted(v.FieldByIndex(f.index)) {
+				present = append(present, f)
+			}
+		}
+		b = appendHeader(b, len(present), 0x80, 0, 0xde, 0xdf)
+		var err error
+		for _, f := range present {
+			b = appendString(b, f.name)
+			if b, err = appendMsgpack(b, v.FieldByIndex(f.index)); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("codec: MessagePack cannot encode %s", v.Type())
+}
+
+func appendInt(b []byte, n int64) []byte {
+	switch {
+	case n >= 0:
+		return appendUint(b, uint64(n))
+	case n >= -32:
+		return append(b, byte(int8(n)))
+	case n >= math.MinInt8:
+		return append(b, 0xd0, byte(int8(n)))
+	case n >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(int16(n)))
+	case n >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(int32(n)))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(n))
+}
+
+func appendUint(b []byte, n uint64) []byte {
+	switch {
+	case n < 128:
+		return append(b, byte(n))
+	case n <= math.MaxUint8:
+		return append(b, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcf), n)
+}
+
+func appendString(b []byte, s string) []byte {
+	if len(s) < 32 {
+		return append(append(b, 0xa0|byte(len(s))), s...)
+	}
+	return append(appendHeader(b, len(s), 0, 0xd9, 0xda, 0xdb), s...)
+}
+
+// appendHeader writes a length in the smallest form its type offers: the
+// fix form under 16, then 8, 16 or 32 bits. A 0 marker means the type has
+// no such form.
+func appendHeader(b []byte, n int, fix, len8, len16, len32 byte) []byte {
+	switch {
+	case fix != 0 && n < 16:
+		return append(b, fix|byte(n))
+	case len8 != 0 && n <= math.MaxUint8:
+		return append(b, len8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, len16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, len32), uint32(n))
+}
+
+// decodeMsgpack decodes one value into nil, bool, int64, uint64, float64,
+// string, []byte, time.Time, []interface{} or map[string]interface{}.
+func decodeMsgpack(b []byte, depth int) (interface{}, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, errTruncated
+	}
+	if depth > maxDepth {
+		return nil, nil, errors.New("codec: MessagePack frame nested too deeply")
+	}
+	c, b := b[0], b[1:]
+
+	switch {
+	case c < 0x80:
+		return int64(c), b, nil
+	case c >= 0xe0:
+		return int64(int8(c)), b, nil
+	case c&0xf0 == 0x80:
+		return decodeMap(b, int(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return decodeArray(b, int(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return decodeString(b, int(c&0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, b, nil
+	case 0xc2:
+		return false, b, nil
+	case 0xc3:
+		return true, b, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, b, err := readLength(b, 1<<(c-0xc4))
+		if err != nil {
+			return nil, nil, err
+		}
+		data, b, err := readBytes(b, n)
+		return append([]byte(nil), data...), b, err
+	case 0xc7, 0xc8, 0xc9:
+		n, b, err := readLength(b, 1<<(c-0xc7))
+		if err != nil {
+			return nil, nil, err
+		}
+		return decodeExt(b, n)
+	case 0xca:
+		bits, b, err := readUint(b, 4)
+		return float64(math.Float32frombits(uint32(bits))), b, err
+	case 0xcb:
+		bits, b, err := readUint(b, 8)
+		return math.Float64frombits(bits), b, err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return readUint(b, 1<<(c-0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		n, b, err := readUint(b, size)
+		// Sign extend from the value's width
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, b, err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return decodeExt(b, 1<<(c-0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, b, err := readLength(b, 1<<(c-0xd9))
+		if err != nil {
+			return nil, nil, err
+		}
+		return decodeString(b, n)
+	case 0xdc, 0xdd:
+		n, b, err := readLength(b, 2<<(c-0xdc))
+		if err != nil {
+			return nil, nil, err
+		}
+		return decodeArray(b, n, depth)
+	case 0xde, 0xdf:
+		n, b, err := readLength(b, 2<<(c-0xde))
+		if err != nil {
+			return nil, nil, err
+		}
+		return decodeMap(b, n, depth)
+	}
+	return nil, nil, fmt.Errorf("codec: invalid MessagePack byte 0x%02x", c)
+}
+
+func decodeString(b []byte, n int) (interface{}, []byte, error) {
+	data, b, err := readBytes(b, n)
+	return string(data), b, err
+}
+
+func decodeArray(b []byte, n int, depth int) (interface{}, []byte, error) {
+	// Every element takes at least a byte, which bounds what a forged
+	// length can allocate
+	if n > len(b) {
+		return nil, nil, errTruncated
+	}
+	items := make([]interface{}, n)
+	for i := range items {
+		var err error
+		if items[i], b, err = decodeMsgpack(b, depth+1); err != nil {
+			return nil, nil, err
+		}
+	}
+	return items, b, nil
+}
+
+func decodeMap(b []byte, n int, depth int) (interface{}, []byte, error) {
+	if 2*n > len(b) {
+		return nil, nil, errTruncated
+	}
+	object := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, rest, err := decodeMsgpack(b, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		var name string
+		switch key := key.(type) {
+		case string:
+			name = key
+		case int64:
+			name = strconv.FormatInt(key, 10)
+		case uint64:
+			name = strconv.FormatUint(key, 10)
+		default:
+			return nil, nil, fmt.Errorf("codec: MessagePack map key of type %T", key)
+		}
+		if object[name], b, err = decodeMsgpack(rest, depth+1); err != nil {
+			return nil, nil, err
+		}
+	}
+	return object, b, nil
+}
+
+// decodeExt decodes a timestamp in any of its three sizes; other
+// extensions are refused.
+func decodeExt(b []byte, n int) (interface{}, []byte, error) {
+	data, b, err := readBytes(b, n+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if int8(data[0]) != timestampExt {
+		return nil, nil, fmt.Errorf("codec: unknown MessagePack extension %d", int8(data[0]))
+	}
+	data = data[1:]
+	switch len(data) {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), b, nil
+	case 8:
+		packed := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(packed&(1<<34-1)), int64(packed>>34)).UTC(), b, nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data))).UTC(), b, nil
+	}
+	return nil, nil, fmt.Errorf("codec: MessagePack timestamp of %d bytes", len(data))
+}
+
+func readUint(b []byte, size int) (uint64, []byte, error) {
+	if len(b) < size {
+		return 0, nil, errTruncated
+	}
+	var n uint64
+	for _, c := range b[:size] {
+		n = n<<8 | uint64(c)
+	}
+	return n, b[size:], nil
+}
+
+func readLength(b []byte, size int) (int, []byte, error) {
+	n, b, err := readUint(b, size)
+	return int(n), b, err
+}
+
+func readBytes(b []byte, n int) ([]byte, []byte, error) {
+	if n > len(b) {
+		return nil, nil, errTruncated
+	}
+	return b[:n], b[n:], nil
+}
+
+// assign stores a decoded value into v, converting it the way encoding/json
+// would convert the equivalent JSON.
+func assign(v reflect.Value, x interface{}) error {
+	if x == nil {
+		return nil
+	}
+	mismatch := func() error {
+		return fmt.Errorf("codec: cannot decode %T into %s", x, v.Type())
+	}
+
+	if v.Type() == timeType {
+		switch x := x.(type) {
+		case time.Time:
+			v.Set(reflect.ValueOf(x))
+		case string:
+			t, err := time.Parse(time.RFC3339, x)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(t))
+		default:
+			return mismatch()
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return assign(v.Elem(), x)
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return mismatch()
+		}
+		v.Set(reflect.ValueOf(x))
+	case reflect.Bool:
+		b, ok := x.(bool)
+		if !ok {
+			return mismatch()
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch x := x.(type) {
+		case int64:
+			n = x
+		case uint64:
+			if x > math.MaxInt64 {
+				return mismatch()
+			}
+			n = int64(x)
+		default:
+			return mismatch()
+		}
+		if v.OverflowInt(n) {
+			return mismatch()
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch x := x.(type) {
+		case uint64:
+			n = x
+		case int64:
+			if x < 0 {
+				return mismatch()
+			}
+			n = uint64(x)
+		default:
+			return mismatch()
+		}
+		if v.OverflowUint(n) {
+			return mismatch()
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		switch x := x.(type) {
+		case float64:
+			v.SetFloat(x)
+		case int64:
+			v.SetFloat(float64(x))
+		case uint64:
+			v.SetFloat(float64(x))
+		default:
+			return mismatch()
+		}
+	case reflect.String:
+		s, ok := x.(string)
+		if !ok {
+			return mismatch()
+		}
+		v.SetString(s)
+	case reflect.Slice:
+		if data, ok := x.([]byte); ok && v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes(data)
+			return nil
+		}
+		items, ok := x.([]interface{})
+		if !ok {
+			return mismatch()
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := assign(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		object, ok := x.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(object)))
+		}
+		for name, item := range object {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := setMapKey(key, name); err != nil {
+				return err
+			}
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := assign(value, item); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, value)
+		}
+	case reflect.Struct:
+		object, ok := x.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		fields := fieldsOf(v.Type())
+		for name, item := range object {
+			if f, found := fieldNamed(fields, name); found {
+				if err := assign(v.FieldByIndex(f.index), item); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return mismatch()
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package codec
+
+import (
+	"chat-integrated/models"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protobufCodec encodes frames as the Frame message of frame.proto, the
+// fields numbered by their proto tags. Fields without one are not sent.
+// Times are Unix milliseconds, 0 when unset, and maps are repeated
+// entries as protoc generates them.
+type protobufCodec struct{}
+
+func (protobufCodec) Subprotocol() string { return "chat.protobuf" }
+func (protobufCodec) MessageType() int    { return models.BinaryMessage }
+
+func (protobufCodec) Marshal(msg models.Message) ([]byte, error) {
+	return appendMessage(make([]byte, 0, 256), reflect.ValueOf(msg))
+}
+
+func (protobufCodec) Unmarshal(data []byte, msg *models.Message) error {
+	return decodeMessage(data, reflect.ValueOf(msg).Elem(), 0)
+}
+
+func appendMessage(b []byte, v reflect.Value) ([]byte, error) {
+	var err error
+	for _, f := range fieldsOf(v.Type()) {
+		if f.number == 0 {
+			continue
+		}
+		if b, err = appendField(b, f.number, v.FieldByIndex(f.index), false); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendField encodes v as field num. Zero values are left out unless
+// forced, as repeated and map values must be written.
+func appendField(b []byte, num protowire.Number, v reflect.Value, forced bool) ([]byte, error) {
+	if v.Type() == timeType || isScalar(v.Type()) {
+		if !forced && isZero(v) {
+			return b, nil
+		}
+		return appendScalar(protowire.AppendTag(b, num, wireType(v.Type())), v), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return b, nil
+		}
+		// A set pointer is sent even when zero, like a proto3 optional
+		return appendField(b, num, v.Elem(), true)
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if !forced && v.Len() == 0 {
+				return b, nil
+			}
+			return protowire.AppendBytes(protowire.AppendTag(b, num, protowire.BytesType), v.Bytes()), nil
+		}
+		if v.Len() == 0 {
+			return b, nil
+		}
+		if elem := v.Type().Elem(); elem == timeType || isScalar(elem) && elem.Kind() != reflect.String {
+			// Repeated numbers are packed, as proto3 does by default
+			var packed []byte
+			for i := 0; i < v.Len(); i++ {
+				packed = appendScalar(packed, v.Index(i))
+			}
+			return protowire.AppendBytes(protowire.AppendTag(b, num, protowire.BytesType), packed), nil
+		}
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			if b, err = appendField(b, num, v.Index(i), true); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Map:
+		for _, key := range sortedKeys(v) {
+			entry, err := appendField(nil, 1, key, true)
+			if err != nil {
+				return nil, err
+			}
+			if entry, err = appendField(entry, 2, v.MapIndex(key), true); err != nil {
+				return nil, err
+			}
+			b = protowire.AppendBytes(protowire.AppendTag(b, num, protowire.BytesType), entry)
+		}
+		return b, nil
+	case reflect.Struct:
+		nested, err := appendMessage(nil, v)
+		if err != nil {
+			return nil, err
+		}
+		if !forced && len(nested) == 0 {
+			return b, nil
+		}
+		return protowire.AppendBytes(protowire.AppendTag(b, num, protowire.BytesType), nested), nil
+	}
+	return nil, fmt.Errorf("codec: protobuf cannot encode %s", v.Type())
+}
+
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func isZero(v reflect.Value) bool {
+	if v.Type() == timeType {
+		return millis(v) == 0
+	}
+	return v.IsZero()
+}
+
+func wireType(t reflect.Type) protowire.Type {
+	switch {
+	case t == timeType:
+		return protowire.VarintType
+	case t.Kind() == reflect.String:
+		return protowire.BytesType
+	case t.Kind() == reflect.Float64:
+		return protowire.Fixed64Type
+	case t.Kind() == reflect.Float32:
+		return protowire.Fixed32Type
+	}
+	return protowire.VarintType
+}
+
+// appendScalar encodes a scalar's value without its tag.
+func appendScalar(b []byte, v reflect.Value) []byte {
+	if v.Type() == timeType {
+		return protowire.AppendVarint(b, uint64(millis(v)))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return protowire.AppendString(b, v.String())
+	case reflect.Bool:
+		return protowire.AppendVarint(b, protowire.EncodeBool(v.Bool()))
+	case reflect.Float64:
+		return protowire.AppendFixed64(b, math.Float64bits(v.Float()))
+	case reflect.Float32:
+		return protowire.AppendFixed32(b, math.Float32bits(float32(v.Float())))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return protowire.AppendVarint(b, uint64(v.Int()))
+	}
+	return protowire.AppendVarint(b, v.Uint())
+}
+
+func millis(v reflect.Value) int64 {
+	t := v.Interface().(time.Time)
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func decodeMessage(b []byte, v reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("codec: protobuf frame nested too deeply")
+	}
+	fields := fieldsOf(v.Type())
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f, known := fieldNumbered(fields, num)
+		if !known {
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		n, err := decodeField(b, typ, v.FieldByIndex(f.index), depth)
+		if err != nil {
+			return fmt.Errorf("codec: field %s: %w", f.name, err)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func fieldNumbered(fields []field, num protowire.Number) (field, bool) {
+	for _, f := range fields {
+		if f.number == num {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// decodeField decodes one record of a field into v and returns its
+// length. Repeated and map fields gain an element per record.
+func decodeField(b []byte, typ protowire.Type, v reflect.Value, depth int) (int, error) {
+	if v.Type() == timeType || isScalar(v.Type()) {
+		return decodeScalar(b, typ, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeField(b, typ, v.Elem(), depth)
+	case reflect.Slice:
+		elem := v.Type().Elem()
+		if elem.Kind() == reflect.Uint8 {
+			data, n, err := consumeBytes(b, typ)
+			if err != nil {
+				return 0, err
+			}
+			v.SetBytes(append([]byte(nil), data...))
+			return n, nil
+		}
+		if typ == protowire.BytesType && (elem == timeType || isScalar(elem) && elem.Kind() != reflect.String) {
+			packed, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return 0, protowire.ParseError(n)
+			}
+			for len(packed) > 0 {
+				item := reflect.New(elem).Elem()
+				m, err := decodeScalar(packed, wireType(elem), item)
+				if err != nil {
+					return 0, err
+				}
+				packed = packed[m:]
+				v.Set(reflect.Append(v, item))
+			}
+			return n, nil
+		}
+		item := reflect.New(elem).Elem()
+		n, err := decodeField(b, typ, item, depth)
+		if err != nil {
+			return 0, err
+		}
+		v.Set(reflect.Append(v, item))
+		return n, nil
+	case reflect.Map:
+		entry, n, err := consumeBytes(b, typ)
+		if err != nil {
+			return 0, err
+		}
+		key := reflect.New(v.Type().Key()).Elem()
+		value := reflect.New(v.Type().Elem()).Elem()
+		for len(entry) > 0 {
+			num, entryType, m := protowire.ConsumeTag(entry)
+			if m < 0 {
+				return 0, protowire.ParseError(m)
+			}
+			entry = entry[m:]
+			switch num {
+			case 1:
+				m, err = decodeField(entry, entryType, key, depth+1)
+			case 2:
+				m, err = decodeField(entry, entryType, value, depth+1)
+			default:
+				if m = protowire.ConsumeFieldValue(num, entryType, entry); m < 0 {
+					err = protowire.ParseError(m)
+				}
+			}
+			if err != nil {
+				return 0, err
+			}
+			entry = entry[m:]
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		v.SetMapIndex(key, value)
+		return n, nil
+	case reflect.Struct:
+		nested, n, err := consumeBytes(b, typ)
+		if err != nil {
+			return 0, err
+		}
+		return n, decodeMessage(nested, v, depth+1)
+	}
+	return 0, fmt.Errorf("cannot decode into %s", v.Type())
+}
+
+func consumeBytes(b []byte, typ protowire.Type) ([]byte, int, error) {
+	if typ != protowire.BytesType {
+		return nil, 0, fmt.Errorf("wire type %d for a length-delimited field", typ)
+	}
+	data, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return nil, 0, protowire.ParseError(n)
+	}
+	return data, n, nil
+}
+
+func decodeScalar(b []byte, typ protowire.Type, v reflect.Value) (int, error) {
+	if want := wireType(v.Type()); typ != want {
+		return 0, fmt.Errorf("wire type %d for %s", typ, v.Type())
+	}
+
+	switch typ {
+	case protowire.BytesType:
+		s, n := protowire.ConsumeString(b)
+		if n < 0 {
+			return 0, protowire.ParseError(n)
+		}
+		v.SetString(s)
+		return n, nil
+	case protowire.Fixed64Type:
+		bits, n := protowire.ConsumeFixed64(b)
+		if n < 0 {
+			return 0, protowire.ParseError(n)
+		}
+		v.SetFloat(math.Float64frombits(bits))
+		return n, nil
+	case protowire.Fixed32Type:
+		bits, n := protowire.ConsumeFixed32(b)
+		if n < 0 {
+			return 0, protowire.ParseError(n)
+		}
+		v.SetFloat(float64(math.Float32frombits(bits)))
+		return n, nil
+	}
+
+	x, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	if v.Type() == timeType {
+		if x != 0 {
+			v.Set(reflect.ValueOf(time.UnixMilli(int64(x)).UTC()))
+		}
+		return n, nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(protowire.DecodeBool(x))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(x))
+	default:
+		v.SetUint(x)
+	}
+	return n, nil
+}
@@ -1,8 +1,494 @@
 package config
 
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
 const (
-	MaxUsersPerLobby = 5
-	ServerPort       = ":8080"
-	RedisAddr        = "localhost:6379"
-	RedisDB          = 0
+	// MaxUsersLimit bounds what a lobby owner may raise max users to
+	MaxUsersLimit = 50
+	// MessageHistoryLimit caps the in-memory history per lobby
+	MessageHistoryLimit = 200
+	// MaxHistoryLimit, MaxSlowModeSeconds and MaxEphemeralSeconds bound the
+	// lobby settings
+	MaxHistoryLimit     = 1000
+	MaxSlowModeSeconds  = 600
+	MaxEphemeralSeconds = 7 * 24 * 60 * 60
+	// RosterDigestInterval is how often lobbies in digest mode report who
+	// joined and left
+	RosterDigestInterval = 30 * time.Second
+	ServerPort           = ":8080"
+	RedisAddr            = "localhost:6379"
+	RedisDB              = 0
+
+	// Redis resilience: connection retries back off exponentially and the
+	// circuit breaker buffers message writes in memory during an outage
+	RedisConnectAttempts  = 5
+	RedisRetryBaseDelay   = 500 * time.Millisecond
+	RedisRetryMaxDelay    = 30 * time.Second
+	RedisBreakerThreshold = 3
+	RedisOutageBuffer     = 1000
+	// Commands refused during a sentinel failover or cluster resharding are
+	// retried RedisFailoverRetries times, backing off from
+	// RedisFailoverRetryDelay, before they count against the breaker
+	RedisFailoverRetries    = 4
+	RedisFailoverRetryDelay = 250 * time.Millisecond
+
+	// NATSTimeout bounds each JetStream request of the NATS backend
+	NATSTimeout = 5 * time.Second
+
+	// Service lifecycle
+	ServiceName     = "integrated-chat"
+	ShutdownTimeout = 15 * time.Second
+	// ReadinessTimeout bounds each /readyz check
+	ReadinessTimeout = 2 * time.Second
+
+	// Synthetic monitoring probe
+	ProbeEnabled  = false
+	ProbeInterval = 30 * time.Second
+	ProbeTimeout  = 5 * time.Second
+	ProbeLobbyID  = "lobby-health"
+	ProbeEmail    = "probe@health.local"
+
+	// Attachments
+	AttachmentDir     = "./uploads"
+	MaxAttachmentSize = 10 << 20
+	ScanTimeout       = 30 * time.Second
+
+	// Transcript import: a prior session loaded as read-only context
+	MaxImportMessages = 500
+	MaxImportSize     = 5 << 20
+
+	// ArchiveTimeout bounds the upload of an ended session's transcript;
+	// MaxArchiveURLTTL is the longest an S3 presigned URL may last
+	ArchiveTimeout   = time.Minute
+	MaxArchiveURLTTL = 7 * 24 * time.Hour
+
+	// Scheduled sessions open at most MaxScheduleAhead from when they are
+	// booked; due ones are opened every ScheduleCheckInterval
+	MaxScheduleAhead      = 90 * 24 * time.Hour
+	ScheduleCheckInterval = 15 * time.Second
+
+	// MaxClientMsgIDLength bounds the ID a client gives its chat messages
+	MaxClientMsgIDLength = 64
+
+	// SeqReserveBlock is how many sequence numbers a lobby reserves in the
+	// store at a time, so a restart resumes above any it handed out
+	SeqReserveBlock = 100
+
+	// ModerationTimeout bounds calls to the external moderation API, which
+	// run inside the lobby broadcast loop
+	ModerationTimeout = 2 * time.Second
+
+	// Delivery: each connection has its own writer, so broadcasts never wait
+	// on a socket. WriteTimeout bounds one socket write; ReplayTimeout bounds
+	// how long the lobby waits for room in a connection's queue while
+	// replaying the welcome and history, before dropping it.
+	WriteTimeout  = 10 * time.Second
+	ReplayTimeout = 2 * time.Second
+
+	// Batched replay: a connection that asks for it gets its history in
+	// frames of HistoryBatchSize messages, at most HistoryReplayWindow of
+	// them waiting for an ack. A client that acks none for
+	// HistoryAckTimeout, or falls MaxReplayBacklog messages behind, is
+	// dropped like any slow connection.
+	HistoryBatchSize    = 50
+	HistoryReplayWindow = 2
+	HistoryAckTimeout   = 30 * time.Second
+	MaxReplayBacklog    = 5000
+
+	// Batched writes: a connection that asks for them gets the frames
+	// queued for it written as one array message, of at most
+	// WriteBatchMaxFrames frames and about WriteBatchMaxBytes bytes
+	WriteBatchMaxFrames = 64
+	WriteBatchMaxBytes  = 64 << 10
+
+	// Reliable delivery: a lobby with it keeps up to DeliveryWindow unacked
+	// frames per member, and gives up on a frame sent DeliveryMaxAttempts
+	// times without an ack
+	DeliveryWindow      = 512
+	DeliveryMaxAttempts = 5
+
+	// MaxAuditPage bounds the events one audit log query returns
+	MaxAuditPage = 1000
+
+	// MaxInviteTTL bounds the lifetime of a lobby invite
+	MaxInviteTTL = 30 * 24 * time.Hour
+
+	// DashboardRateMinutes is how many minutes of message rates the admin
+	// dashboard reports, by the minute
+	DashboardRateMinutes = 5
+
+	// Link previews: the first LinkPreviewMaxLinks links of a chat message
+	// are fetched, reading at most LinkPreviewMaxBytes of each page within
+	// LinkPreviewTimeout; up to LinkPreviewCacheSize previews are cached
+	LinkPreviewMaxLinks  = 3
+	LinkPreviewMaxBytes  = 512 << 10
+	LinkPreviewTimeout   = 5 * time.Second
+	LinkPreviewCacheSize = 1000
+)
+
+// Tenancy
+const (
+	DefaultTenantID    = "default"
+	DefaultProductName = "Integrated Chat"
+	TenantHeader       = "X-Tenant-ID"
+	AdminKeyHeader     = "X-Admin-Key"
+	RequestIDHeader    = "X-Request-ID"
+	// APIKeyPrefix starts every API key, which tells it from a bot key in
+	// the Authorization header
+	APIKeyPrefix = "ck_"
+)
+
+// Outbound webhooks
+const (
+	WebhookSignatureHeader = "X-Chat-Signature"
+	WebhookEventHeader     = "X-Chat-Event"
+	WebhookMaxAttempts     = 5
+	WebhookInitialBackoff  = time.Second
+	WebhookTimeout         = 10 * time.Second
+	WebhookQueueSize       = 256
+	WebhookWorkers         = 4
+)
+
+// MailQueueSize is how many notification emails wait to be sent
+const MailQueueSize = 256
+
+// Sessions
+const (
+	SessionCookieName = "chat_session"
+	SessionTTL        = 24 * time.Hour
+	OAuthStateCookie  = "oauth_state"
+	OAuthTenantCookie = "oauth_tenant"
+
+	// ConnectTicketTTL is how long the one-time ticket /ws is opened with
+	// stays valid
+	ConnectTicketTTL = 60 * time.Second
+
+	// Account passwords; bcrypt only reads the first 72 bytes
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+
+	// Guests join guest-friendly lobbies without an account, on top of the
+	// lobby's member seats
+	GuestSessionTTL   = 2 * time.Hour
+	MaxGuestsPerLobby = 5
+
+	// Profiles: display names and avatars shown to other members in place
+	// of emails
+	MaxDisplayNameLength = 40
+	MaxAvatarURLLength   = 512
+
+	// MaxAnnouncementLength caps admin announcements sent to every lobby
+	MaxAnnouncementLength = 2000
+
+	// Custom emoji: small images registered per tenant or lobby under a
+	// :shortcode:
+	MaxEmojiSize    = 256 << 10
+	MaxEmojiPerPack = 100
+
+	// Time budgets a facilitator can give a session, and the highest cost
+	// per participant hour
+	MaxBudgetMinutes = 24 * 60
+	MaxHourlyRate    = 10000
+
+	// MaxQueueLength is how many users can wait for a seat per tenant
+	MaxQueueLength = 100
+
+	// Matchmaking: the interest tags a login may name, how long each may
+	// be, and how often the pools are checked for users who waited too long
+	MaxInterestTags          = 5
+	MaxInterestTagLength     = 32
+	MatchmakingCheckInterval = time.Second
+
+	// MaxActionItems bounds the messages a lobby can have tagged as action
+	// items
+	MaxActionItems = 200
+
+	// Whisper groups: how many channels a lobby may have open and how long
+	// a channel's name may be
+	MaxChannelsPerLobby  = 20
+	MaxChannelNameLength = 40
+
+	// MaxRequestBodySize bounds the JSON bodies read for validation against
+	// the OpenAPI document; imports have MaxImportSize
+	MaxRequestBodySize = 1 << 20
+)
+
+// OAuth2 providers are configured from the environment; a provider without
+// a client ID is disabled.
+var (
+	GoogleClientID       = getEnv("GOOGLE_CLIENT_ID", "")
+	GoogleClientSecret   = getSecretEnv("GOOGLE_CLIENT_SECRET")
+	GitHubClientID       = getEnv("GITHUB_CLIENT_ID", "")
+	GitHubClientSecret   = getSecretEnv("GITHUB_CLIENT_SECRET")
+	OAuthRedirectBaseURL = getEnv("OAUTH_REDIRECT_BASE_URL", "http://localhost:8080")
+
+	// Upload scanning: "none", "clamav" or "http"
+	ScannerBackend = getEnv("SCANNER_BACKEND", "none")
+	ClamAVAddr     = getEnv("CLAMAV_ADDR", "localhost:3310")
+	ScannerURL     = getEnv("SCANNER_URL", "")
+
+	// Transcript archiving: "none" or "s3". When a session ends its
+	// transcript is uploaded as gzipped JSON lines under ArchivePrefix. The
+	// s3 backend works with AWS, MinIO or GCS (ARCHIVE_ENDPOINT
+	// https://storage.googleapis.com with HMAC keys); ARCHIVE_ENDPOINT
+	// defaults to the AWS endpoint of ArchiveRegion. Downloads are presigned
+	// for ArchiveURLTTL
+	ArchiveBackend         = getEnv("ARCHIVE_BACKEND", "none")
+	ArchiveEndpoint        = getEnv("ARCHIVE_ENDPOINT", "")
+	ArchiveRegion          = getEnv("ARCHIVE_REGION", "us-east-1")
+	ArchiveBucket          = getEnv("ARCHIVE_BUCKET", "")
+	ArchivePrefix          = getEnv("ARCHIVE_PREFIX", "transcripts/")
+	ArchiveAccessKeyID     = getEnv("ARCHIVE_ACCESS_KEY_ID", "")
+	ArchiveSecretAccessKey = getSecretEnv("ARCHIVE_SECRET_ACCESS_KEY")
+	ArchiveURLTTL          = getDurationEnv("ARCHIVE_URL_TTL", 15*time.Minute)
+
+	// Notification email: "none", "log" (written to the log) or "smtp".
+	// Links in mail point at MailLinkBaseURL
+	MailerBackend   = getEnv("MAILER_BACKEND", "none")
+	SMTPAddr        = getEnv("SMTP_ADDR", "localhost:587")
+	SMTPUsername    = getEnv("SMTP_USERNAME", "")
+	SMTPPassword    = getSecretEnv("SMTP_PASSWORD")
+	MailFrom        = getEnv("MAIL_FROM", "chat@localhost")
+	MailLinkBaseURL = getEnv("MAIL_LINK_BASE_URL", "http://localhost:8080")
+
+	// AccessLog logs every HTTP request; GzipResponses compresses API
+	// responses for clients that accept gzip
+	AccessLog     = getEnv("ACCESS_LOG", "true") == "true"
+	GzipResponses = getEnv("GZIP_RESPONSES", "false") == "true"
+
+	// ProtocolVersions lists the WebSocket message protocol versions served
+	ProtocolVersions = []int{1}
+
+	// Tracing exports OpenTelemetry traces over OTLP/HTTP to the collector
+	// of the standard OTEL_EXPORTER_OTLP_ENDPOINT (default localhost:4318)
+	Tracing = getEnv("TRACING", "false") == "true"
+
+	// GRPCAddr enables the gRPC API on this address (e.g. ":9090") when set
+	GRPCAddr = getEnv("GRPC_ADDR", "")
+
+	// AdminAPIKey guards /api/admin; the admin API is disabled when empty
+	AdminAPIKey = getSecretEnv("ADMIN_API_KEY")
+
+	// MemberIDSecret keys the member IDs that stand in for emails in
+	// broadcasts; when empty a random key is drawn at startup, so IDs change
+	// across restarts
+	MemberIDSecret = getSecretEnv("MEMBER_ID_SECRET")
+
+	// InviteSecret signs lobby invite tokens; when empty a random key is
+	// drawn at startup, so invites stop working on restart. Invites last
+	// InviteTTL unless their creator asks for another lifetime, up to
+	// MaxInviteTTL
+	InviteSecret = getSecretEnv("INVITE_SECRET")
+	InviteTTL    = getDurationEnv("INVITE_TTL", 24*time.Hour)
+
+	// OpenLogin lets /api/login seat any email without a password or login
+	// link, as before accounts; for demos and load tests only
+	OpenLogin = getEnv("OPEN_LOGIN", "false") == "true"
+
+	// MagicLinkTTL and PasswordResetTTL are how long the links mailed to
+	// log in and to reset a password stay valid
+	MagicLinkTTL     = getDurationEnv("MAGIC_LINK_TTL", 15*time.Minute)
+	PasswordResetTTL = getDurationEnv("PASSWORD_RESET_TTL", time.Hour)
+
+	// ReconnectTokenTTL is how long the token an email login gets stays
+	// valid for connecting to its lobby
+	ReconnectTokenTTL = getDurationEnv("RECONNECT_TOKEN_TTL", 24*time.Hour)
+
+	// WriteBatchWindow is how long the writer of a batched connection waits
+	// for more frames after the first before writing; 0 only batches what
+	// is already queued
+	WriteBatchWindow = getDurationEnv("WRITE_BATCH_WINDOW", 0)
+
+	// ClientMsgIDTTL is how long a sender's ClientMsgID is remembered, so a
+	// retransmission within it is recognized
+	ClientMsgIDTTL = getDurationEnv("CLIENT_MSG_ID_TTL", 10*time.Minute)
+
+	// Retention: every RetentionInterval, stored and in-memory history is
+	// pruned of messages older than RetentionMaxAge and of those beyond the
+	// newest RetentionMaxMessages of each lobby; 0 keeps them. Lobby
+	// settings can override both
+	RetentionMaxAge      = getDurationEnv("RETENTION_MAX_AGE", 0)
+	RetentionMaxMessages = getIntEnv("RETENTION_MAX_MESSAGES", 0)
+	RetentionInterval    = getDurationEnv("RETENTION_INTERVAL", time.Hour)
+
+	// ExpiryInterval is how often lobbies in ephemeral mode remove the
+	// messages that expired; ended sessions drop theirs with retention
+	ExpiryInterval = getDurationEnv("EXPIRY_INTERVAL", 5*time.Second)
+
+	// InboxMaxMessages caps the inbox of messages kept for each member with
+	// no connection, delivered when they reconnect even if retention pruned
+	// them from the history; 0 keeps no inboxes
+	InboxMaxMessages = getIntEnv("INBOX_MAX_MESSAGES", 500)
+
+	// Audit log: the newest AuditMaxEvents administrative and lifecycle
+	// events are kept in the store, and all of them appended to AuditLogFile
+	// as JSON lines when it is set
+	AuditMaxEvents = getIntEnv("AUDIT_MAX_EVENTS", 10000)
+	AuditLogFile   = getEnv("AUDIT_LOG_FILE", "")
+
+	// LinkPreviews fetches the OpenGraph metadata of pages linked from chat,
+	// for LinkPreviewCacheTTL; pages on private and loopback addresses are
+	// only fetched with LinkPreviewAllowPrivate
+	LinkPreviews            = getEnv("LINK_PREVIEWS", "true") == "true"
+	LinkPreviewAllowPrivate = getEnv("LINK_PREVIEW_ALLOW_PRIVATE", "false") == "true"
+	LinkPreviewCacheTTL     = getDurationEnv("LINK_PREVIEW_CACHE_TTL", time.Hour)
+
+	// DashboardInterval is how often /ws/admin pushes a dashboard snapshot
+	DashboardInterval = getDurationEnv("DASHBOARD_INTERVAL", 2*time.Second)
+
+	// WebSocket compression: with WSCompression, permessage-deflate is
+	// negotiated with clients that offer it, and their messages of at least
+	// WSCompressionThreshold bytes are compressed at WSCompressionLevel (1
+	// fastest to 9 smallest)
+	WSCompression          = getEnv("WS_COMPRESSION", "true") == "true"
+	WSCompressionLevel     = getIntEnv("WS_COMPRESSION_LEVEL", 1)
+	WSCompressionThreshold = getIntEnv("WS_COMPRESSION_THRESHOLD", 512)
+
+	// RequireSession rejects WebSocket upgrades without a valid session cookie
+	RequireSession = getEnv("REQUIRE_SESSION", "false") == "true"
+
+	// MaxConnectionsPerIP caps the /ws connections open from one address at
+	// once; 0 turns the cap off. With TrustProxy the address is the last
+	// hop of X-Forwarded-For, as set by the proxy in front of the server
+	MaxConnectionsPerIP = getIntEnv("MAX_CONNECTIONS_PER_IP", 10)
+	TrustProxy          = getEnv("TRUST_PROXY", "false") == "true"
+
+	// Presence: a connected user whose tab has been hidden for AwayAfterHidden
+	// turns away; lobbies re-check every PresenceCheckInterval
+	AwayAfterHidden       = getDurationEnv("AWAY_AFTER_HIDDEN", 5*time.Minute)
+	PresenceCheckInterval = getDurationEnv("PRESENCE_CHECK_INTERVAL", 15*time.Second)
+
+	// LagThreshold is the round trip a client's pings may report before its
+	// lobby's facilitators are told it is lagging; 0 flags no one
+	LagThreshold = getDurationEnv("LAG_THRESHOLD", time.Second)
+
+	// BudgetCheckInterval is how often lobbies with a time budget accrue
+	// elapsed time and check its milestones
+	BudgetCheckInterval = getDurationEnv("BUDGET_CHECK_INTERVAL", 10*time.Second)
+
+	// Idle seats: a user without a connection for IdleTimeout, whether they
+	// never connected after logging in or left, loses their seat. Lobbies
+	// check every IdleCheckInterval; 0 disables the timeout
+	IdleTimeout       = getDurationEnv("IDLE_TIMEOUT", 10*time.Minute)
+	IdleCheckInterval = getDurationEnv("IDLE_CHECK_INTERVAL", 30*time.Second)
+
+	// Reconnect grace: a member whose connection dropped keeps their seat,
+	// and isn't announced as gone, for ReconnectGrace. Lobbies check every
+	// ReconnectCheckInterval; 0 announces the departure at once
+	ReconnectGrace         = getDurationEnv("RECONNECT_GRACE", 15*time.Second)
+	ReconnectCheckInterval = getDurationEnv("RECONNECT_CHECK_INTERVAL", time.Second)
+
+	// Reliable delivery: a frame not acked for DeliveryAckTimeout is sent
+	// again; lobbies check every DeliveryCheckInterval
+	DeliveryAckTimeout    = getDurationEnv("DELIVERY_ACK_TIMEOUT", 5*time.Second)
+	DeliveryCheckInterval = getDurationEnv("DELIVERY_CHECK_INTERVAL", time.Second)
+
+	// QueueTicketTTL is how long a user waiting for a seat stays in line
+	// without checking their place, and how long an admitted user's ticket
+	// still reports the lobby it got them into
+	QueueTicketTTL = getDurationEnv("QUEUE_TICKET_TTL", 2*time.Minute)
+
+	// Matchmaking: a login with interest tags waits up to MatchmakingTimeout
+	// for MatchmakingMinUsers users sharing a tag, who then get a lobby of
+	// that topic; otherwise it falls back to the tenant's lobby
+	MatchmakingTimeout  = getDurationEnv("MATCHMAKING_TIMEOUT", 30*time.Second)
+	MatchmakingMinUsers = getIntEnv("MATCHMAKING_MIN_USERS", 2)
+
+	// MaxUsersPerLobby is the capacity new lobbies start with; owners
+	// change it per lobby at runtime
+	MaxUsersPerLobby = getIntEnv("MAX_USERS_PER_LOBBY", 5)
+
+	// Persistence backend: "redis", "bolt" for an embedded single-file
+	// store that needs no external services, "nats" for NATS JetStream, or
+	// "memory" for tests and local development, which keeps nothing across
+	// restarts
+	StoreBackend = getEnv("STORE_BACKEND", "redis")
+	BoltPath     = getEnv("BOLT_PATH", "./data/chat.db")
+	NATSURL      = getEnv("NATS_URL", "nats://localhost:4222")
+
+	// Redis topology: "single" uses RedisAddr, "sentinel" follows the master
+	// named REDIS_SENTINEL_MASTER through the comma separated
+	// REDIS_SENTINEL_ADDRS, and "cluster" spreads lobbies over the cluster
+	// seeded by the comma separated REDIS_CLUSTER_ADDRS
+	RedisMode           = getEnv("REDIS_MODE", "single")
+	RedisSentinelMaster = getEnv("REDIS_SENTINEL_MASTER", "mymaster")
+	RedisSentinelAddrs  = getEnv("REDIS_SENTINEL_ADDRS", "")
+	RedisClusterAddrs   = getEnv("REDIS_CLUSTER_ADDRS", "")
+
+	// IDFormat is what new lobby and message IDs are made of after their
+	// lobby- and msg_ prefixes: "ulid" or "uuid" (version 7)
+	IDFormat = getEnv("ID_FORMAT", "ulid")
+
+	// Moderation filter chain: comma separated masked words, a JSON file of
+	// regex rules and an external moderation API
+	ModerationWords     = getEnv("MODERATION_WORDS", "")
+	ModerationRulesFile = getEnv("MODERATION_RULES_FILE", "")
+	ModerationAPIURL    = getEnv("MODERATION_API_URL", "")
+
+	// Lobby quota: a lobby takes LobbyMessageRate chat messages a second
+	// across all its members, in bursts of up to LobbyMessageBurst; 0 turns
+	// the quota off. A lobby over it cools down for LobbyCooldown, when only
+	// owners, moderators and bots may chat
+	LobbyMessageRate  = getIntEnv("LOBBY_MESSAGE_RATE", 20)
+	LobbyMessageBurst = getIntEnv("LOBBY_MESSAGE_BURST", 40)
+	LobbyCooldown     = getDurationEnv("LOBBY_COOLDOWN", 10*time.Second)
+
+	// Spam detection: within SpamWindow a sender may repeat a message
+	// SpamMaxDuplicates times, post SpamMaxLinks links and mention
+	// SpamMaxMentions members; 0 turns a check off. Each offence is a strike,
+	// forgotten after SpamStrikeTTL: the first warns, the second mutes for
+	// SpamMuteDuration and the third kicks
+	SpamWindow        = getDurationEnv("SPAM_WINDOW", time.Minute)
+	SpamMaxDuplicates = getIntEnv("SPAM_MAX_DUPLICATES", 3)
+	SpamMaxLinks      = getIntEnv("SPAM_MAX_LINKS", 5)
+	SpamMaxMentions   = getIntEnv("SPAM_MAX_MENTIONS", 10)
+	SpamMuteDuration  = getDurationEnv("SPAM_MUTE_DURATION", 2*time.Minute)
+	SpamStrikeTTL     = getDurationEnv("SPAM_STRIKE_TTL", 30*time.Minute)
+
+	// MessageEncryptionKeys turns on AES-256-GCM encryption of stored
+	// messages: comma separated "id:base64 key" pairs, the first sealing new
+	// messages and the rest opening those sealed before a rotation
+	MessageEncryptionKeys = getSecretEnv("MESSAGE_ENCRYPTION_KEYS")
+
+	// PolicyFile replaces the default role × action authorization matrix
+	PolicyFile = getEnv("POLICY_FILE", "")
+
+	// TLS is enabled by a cert/key pair or by AUTOCERT_DOMAINS (comma
+	// separated, certificates from Let's Encrypt). HTTPS is then served on
+	// TLSAddr and ServerPort only redirects to it and answers ACME challenges.
+	TLSCertFile      = getEnv("TLS_CERT_FILE", "")
+	TLSKeyFile       = getEnv("TLS_KEY_FILE", "")
+	TLSAddr          = getEnv("TLS_ADDR", ":8443")
+	AutocertDomains  = getEnv("AUTOCERT_DOMAINS", "")
+	AutocertEmail    = getEnv("AUTOCERT_EMAIL", "")
+	AutocertCacheDir = getEnv("AUTOCERT_CACHE_DIR", "./data/autocert")
 )
+
+func getEnv(key, fallback string) string {
+	return lookupEnv(key, fallback, false)
+}
+
+// getIntEnv reads an integer, keeping fallback when the value is invalid.
+func getIntEnv(key string, fallback int) int {
+	value, err := strconv.Atoi(getEnv(key, strconv.Itoa(fallback)))
+	if err != nil {
+		log.Printf("⚠️ Invalid %s, using %d: %v", key, fallback, err)
+		return fallback
+	}
+	return value
+}
+
+// getDurationEnv reads a duration such as "90s", keeping fallback when the
+// value is invalid.
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	duration, err := time.ParseDuration(getEnv(key, fallback.String()))
+	if err != nil {
+		log.Printf("⚠️ Invalid %s, using %s: %v", key, fallback, err)
+		return fallback
+	}
+	return duration
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"sort"
+	"sync"
+)
+
+// Source tells where an effective setting came from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceEnv     Source = "env"
+	// SourceHub marks a value set by the embedding program in server.Config
+	SourceHub Source = "hub"
+)
+
+const redacted = "[REDACTED]"
+
+// Setting is one effective configuration value and its origin.
+type Setting struct {
+	Name   string      `json:"name"`
+	Value  interface{} `json:"value"`
+	Source Source      `json:"source"`
+	Secret bool        `json:"secret,omitempty"`
+}
+
+var (
+	settings   = make(map[string]Setting)
+	settingsMu sync.RWMutex
+)
+
+func init() {
+	// Compiled-in limits, reported so their origin is visible too
+	for name, value := range map[string]interface{}{
+		"MaxUsersLimit":         MaxUsersLimit,
+		"MessageHistoryLimit":   MessageHistoryLimit,
+		"MaxHistoryLimit":       MaxHistoryLimit,
+		"MaxSlowModeSeconds":    MaxSlowModeSeconds,
+		"RosterDigestInterval":  RosterDigestInterval.String(),
+		"ServerPort":            ServerPort,
+		"RedisAddr":             RedisAddr,
+		"RedisDB":               RedisDB,
+		"NATSTimeout":           NATSTimeout.String(),
+		"ProbeEnabled":          ProbeEnabled,
+		"AttachmentDir":         AttachmentDir,
+		"MaxAttachmentSize":     MaxAttachmentSize,
+		"MaxImportMessages":     MaxImportMessages,
+		"MaxClientMsgIDLength":  MaxClientMsgIDLength,
+		"SeqReserveBlock":       SeqReserveBlock,
+		"MaxScheduleAhead":      MaxScheduleAhead.String(),
+		"ScheduleCheckInterval": ScheduleCheckInterval.String(),
+		"SessionTTL":            SessionTTL.String(),
+		"GuestSessionTTL":       GuestSessionTTL.String(),
+		"MaxGuestsPerLobby":     MaxGuestsPerLobby,
+		"MaxDisplayNameLength":  MaxDisplayNameLength,
+		"MaxAvatarURLLength":    MaxAvatarURLLength,
+		"MaxAnnouncementLength": MaxAnnouncementLength,
+		"MaxEmojiSize":          MaxEmojiSize,
+		"MaxEmojiPerPack":       MaxEmojiPerPack,
+		"MaxBudgetMinutes":      MaxBudgetMinutes,
+		"MaxHourlyRate":         MaxHourlyRate,
+		"MaxQueueLength":        MaxQueueLength,
+		"MaxRequestBodySize":    MaxRequestBodySize,
+		"MailQueueSize":         MailQueueSize,
+		"ShutdownTimeout":       ShutdownTimeout.String(),
+	} {
+		record(Setting{Name: name, Value: value, Source: SourceDefault})
+	}
+}
+
+func record(setting Setting) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	settings[setting.Name] = setting
+}
+
+// lookupEnv reads key, falling back when it is unset or empty, and records
+// the outcome for Settings.
+func lookupEnv(key, fallback string, secret bool) string {
+	if value := os.Getenv(key); value != "" {
+		record(Setting{Name: key, Value: value, Source: SourceEnv, Secret: secret})
+		return value
+	}
+	record(Setting{Name: key, Value: fallback, Source: SourceDefault, Secret: secret})
+	return fallback
+}
+
+func getSecretEnv(key string) string {
+	return lookupEnv(key, "", true)
+}
+
+// SourceOf returns where the named setting came from.
+func SourceOf(name string) Source {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+
+	if setting, exists := settings[name]; exists {
+		return setting.Source
+	}
+	return SourceDefault
+}
+
+// Settings returns every recorded setting sorted by name, with secret
+// values replaced by a marker (or left empty when unset).
+func Settings() []Setting {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+
+	out := make([]Setting, 0, len(settings))
+	for _, setting := range settings {
+		if setting.Secret && setting.Value != "" {
+			setting.Value = redacted
+		}
+		out = append(out, setting)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
@@ -2,6 +2,9 @@ package controllers
 
 import (
 	"chat-integrated/services"
+	"errors"
+	"log"
+	"net/http"
 )
 
 type APIController struct {
@@ -9,8 +12,27 @@ type APIController struct {
 	lobbyService *services.LobbyService
 }
 
-func NewAPIController(lobbyService *services.LobbyService) *APIController {
+func NewAPIController(lobbyService *services.LobbyService, policyService *services.PolicyService, sessionService *services.SessionService, apiKeyService *services.APIKeyService, pathPrefix string) *APIController {
 	return &APIController{
+		BaseController: BaseController{
+			PathPrefix: pathPrefix,
+			Policy:     policyService,
+			Sessions:   sessionService,
+			APIKeys:    apiKeyService,
+		},
 		lobbyService: lobbyService,
 	}
 }
+
+// AuthorizeSession is AuthorizeMember for a live or archived session by ID,
+// so members can still read a session after it ended. A session it doesn't
+// know has no members, so only an admin gets past it to the handler's 404.
+func (c *APIController) AuthorizeSession(w http.ResponseWriter, r *http.Request, lobbyID string, action services.Action) (string, bool) {
+	record, err := c.lobbyService.FindSession(lobbyID)
+	if err != nil && !errors.Is(err, services.ErrUnknownSession) {
+		log.Printf("❌ Failed to load session %s: %v", lobbyID, err)
+		c.RespondError(w, http.StatusInternalServerError, "Failed to load the lobby")
+		return "", false
+	}
+	return c.AuthorizeMember(w, r, record, action)
+}
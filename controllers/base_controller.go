@@ -1,16 +1,45 @@
 package controllers
 
 import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"regexp"
+	"strings"
 )
 
-type BaseController struct{}
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+var (
+	ErrInvalidAdminKey = errors.New("invalid admin key")
+	ErrNotLoggedIn     = errors.New("login required")
+)
+
+type BaseController struct {
+	// PathPrefix is where the owning hub is mounted, e.g. "/staging"; empty
+	// when it is served at the root.
+	PathPrefix string
+	Policy     *services.PolicyService
+	// Sessions, when set, lets a session cookie or a reconnect token grant
+	// RoleUser to REST calls
+	Sessions *services.SessionService
+	// APIKeys, when set, lets an "Authorization: Bearer ck_..." API key
+	// grant its role
+	APIKeys *services.APIKeyService
+}
+
+// callerRoleKey holds the role Authenticate resolved in a request's context.
+type callerRoleKey struct{}
 
 func (bc *BaseController) SetCommonHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Tenant-ID, X-Admin-Key")
 	w.Header().Set("Content-Type", "application/json")
 }
 
@@ -20,8 +49,15 @@ func (bc *BaseController) RespondJSON(w http.ResponseWriter, statusCode int, dat
 	json.NewEncoder(w).Encode(data)
 }
 
+// RespondError answers with an ErrorResponse carrying the generic code of
+// statusCode.
 func (bc *BaseController) RespondError(w http.ResponseWriter, statusCode int, message string) {
-	bc.RespondJSON(w, statusCode, map[string]string{"error": message})
+	bc.RespondJSON(w, statusCode, models.ErrorResponse{Error: message, Code: models.ErrorCodeForStatus(statusCode)})
+}
+
+// RespondCode answers with an ErrorResponse for code, at its HTTP status.
+func (bc *BaseController) RespondCode(w http.ResponseWriter, code models.ErrorCode, message string) {
+	bc.RespondJSON(w, code.Status(), models.ErrorResponse{Error: message, Code: code})
 }
 
 func (bc *BaseController) HandlePreflight(w http.ResponseWriter, r *http.Request) bool {
@@ -32,3 +68,211 @@ func (bc *BaseController) HandlePreflight(w http.ResponseWriter, r *http.Request
 	}
 	return false
 }
+
+// TenantID resolves the tenant of a request from the X-Tenant-ID header or
+// the tenant query parameter, falling back to the default tenant.
+func (bc *BaseController) TenantID(r *http.Request) string {
+	tenantID := r.Header.Get(config.TenantHeader)
+	if tenantID == "" {
+		tenantID = r.URL.Query().Get("tenant")
+	}
+	if !IsValidTenantID(tenantID) {
+		return config.DefaultTenantID
+	}
+	return tenantID
+}
+
+func IsValidTenantID(tenantID string) bool {
+	return tenantIDPattern.MatchString(tenantID)
+}
+
+// Authenticate resolves the caller of every request once, before it is
+// routed. A wrong admin key or API key is refused here with a 401; other
+// requests go on with the role found, which RequestRole returns.
+func (bc *BaseController) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, err := bc.resolveRole(r)
+		if err != nil {
+			bc.RespondError(w, http.StatusUnauthorized, credentialError(err))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerRoleKey{}, role)))
+	})
+}
+
+// RequestRole resolves who is calling: the admin key grants RoleAdmin, an
+// API key its role, and a valid session cookie or reconnect token RoleUser.
+// A wrong admin or API key is an error rather than a silent downgrade.
+func (bc *BaseController) RequestRole(r *http.Request) (models.Role, error) {
+	if role, ok := r.Context().Value(callerRoleKey{}).(models.Role); ok {
+		return role, nil
+	}
+	return bc.resolveRole(r)
+}
+
+func (bc *BaseController) resolveRole(r *http.Request) (models.Role, error) {
+	if key := r.Header.Get(config.AdminKeyHeader); key != "" {
+		if config.AdminAPIKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(config.AdminAPIKey)) != 1 {
+			return models.RoleAnonymous, ErrInvalidAdminKey
+		}
+		return models.RoleAdmin, nil
+	}
+
+	// Other bearer tokens are bot keys, which the bot API checks itself
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && services.IsAPIKey(token) {
+		if bc.APIKeys == nil {
+			return models.RoleAnonymous, services.ErrInvalidAPIKey
+		}
+		apiKey, err := bc.APIKeys.Authenticate(token)
+		if err != nil {
+			return models.RoleAnonymous, err
+		}
+		return apiKey.Role, nil
+	}
+
+	if cookie, err := r.Cookie(config.SessionCookieName); err == nil && bc.Sessions != nil {
+		if session, err := bc.Sessions.GetSession(cookie.Value); err == nil {
+			if session.Guest {
+				return models.RoleGuest, nil
+			}
+			return models.RoleUser, nil
+		}
+	}
+
+	if _, err := bc.RequestUser(r); err == nil {
+		return models.RoleUser, nil
+	}
+	return models.RoleAnonymous, nil
+}
+
+// credentialError is the 401 message for a credential RequestRole refused.
+func credentialError(err error) string {
+	if errors.Is(err, services.ErrInvalidAPIKey) {
+		return "Invalid API key"
+	}
+	return "Invalid admin key"
+}
+
+// RequestUser resolves the email of the user calling: a session cookie's,
+// or for an email login the email query parameter with the reconnect token
+// it was issued as token.
+func (bc *BaseController) RequestUser(r *http.Request) (string, error) {
+	if bc.Sessions == nil {
+		return "", ErrNotLoggedIn
+	}
+	if cookie, err := r.Cookie(config.SessionCookieName); err == nil {
+		session, err := bc.Sessions.GetSession(cookie.Value)
+		if err != nil {
+			return "", ErrNotLoggedIn
+		}
+		return session.Email, nil
+	}
+
+	email := r.URL.Query().Get("email")
+	tokenEmail, err := bc.Sessions.ReconnectEmail(r.URL.Query().Get("token"))
+	if err != nil || email == "" || tokenEmail != email {
+		return "", ErrNotLoggedIn
+	}
+	return email, nil
+}
+
+// RequestActor names the caller as the owner of what they upload: the user
+// RequestUser finds, "admin" for the admin key or "api_key:<id>" for an
+// API key.
+func (bc *BaseController) RequestActor(r *http.Request) (string, error) {
+	if _, err := bc.RequestRole(r); err != nil {
+		return "", err
+	}
+	if r.Header.Get(config.AdminKeyHeader) != "" {
+		return string(models.RoleAdmin), nil
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && services.IsAPIKey(token) && bc.APIKeys != nil {
+		apiKey, err := bc.APIKeys.Authenticate(token)
+		if err != nil {
+			return "", err
+		}
+		return "api_key:" + apiKey.ID, nil
+	}
+	return bc.RequestUser(r)
+}
+
+// Authorize checks the caller's role against the policy for action and
+// writes an error response if it is not allowed.
+func (bc *BaseController) Authorize(w http.ResponseWriter, r *http.Request, action services.Action) bool {
+	role, err := bc.RequestRole(r)
+	if err != nil {
+		bc.RespondError(w, http.StatusUnauthorized, credentialError(err))
+		return false
+	}
+	if !bc.Policy.Allowed(role, action) {
+		bc.RespondError(w, http.StatusForbidden, "Not allowed to "+string(action))
+		return false
+	}
+	return true
+}
+
+// Roster is a live lobby or a session's record, whichever a read names.
+type Roster interface {
+	IsUserInLobby(email string) bool
+}
+
+// AuthorizeMember checks the caller's role against the policy for action,
+// as Authorize does, and that the caller is a member of lobby: a user may
+// only read the lobbies they are seated in. The admin key or an admin API
+// key may read any lobby. It returns who is reading, "admin" for an admin.
+func (bc *BaseController) AuthorizeMember(w http.ResponseWriter, r *http.Request, lobby Roster, action services.Action) (string, bool) {
+	role, err := bc.RequestRole(r)
+	if err != nil {
+		bc.RespondError(w, http.StatusUnauthorized, credentialError(err))
+		return "", false
+	}
+	if !bc.Policy.Allowed(role, action) {
+		bc.RespondError(w, http.StatusForbidden, "Not allowed to "+string(action))
+		return "", false
+	}
+	if role == models.RoleAdmin {
+		return string(models.RoleAdmin), true
+	}
+
+	email, err := bc.RequestUser(r)
+	if err != nil {
+		bc.RespondError(w, http.StatusUnauthorized, "Login required")
+		return "", false
+	}
+	if !lobby.IsUserInLobby(email) {
+		bc.RespondError(w, http.StatusForbidden, "Not a member of this lobby")
+		return "", false
+	}
+	return email, true
+}
+
+// AuthorizeLobby checks a REST call against the caller's lobby role, as
+// management frames are: the admin key or an admin API key is always
+// allowed, anyone else needs a session whose user's role in lobby grants
+// action. It returns who is acting, "admin" for an admin key.
+func (bc *BaseController) AuthorizeLobby(w http.ResponseWriter, r *http.Request, lobby *models.Lobby, action services.Action) (string, bool) {
+	role, err := bc.RequestRole(r)
+	if err != nil {
+		bc.RespondError(w, http.StatusUnauthorized, credentialError(err))
+		return "", false
+	}
+	if role == models.RoleAdmin {
+		return string(models.RoleAdmin), true
+	}
+
+	cookie, err := r.Cookie(config.SessionCookieName)
+	if err != nil || bc.Sessions == nil {
+		bc.RespondError(w, http.StatusUnauthorized, "Login required")
+		return "", false
+	}
+	session, err := bc.Sessions.GetSession(cookie.Value)
+	if err != nil {
+		bc.RespondError(w, http.StatusUnauthorized, "Invalid or expired session")
+		return "", false
+	}
+	if !bc.Policy.Allowed(lobby.GetUserRole(session.Email), action) {
+		bc.RespondError(w, http.StatusForbidden, "Not allowed to "+string(action))
+		return "", false
+	}
+	return session.Email, true
+}
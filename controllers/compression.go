@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"bufio"
+	"chat-integrated/config"
+	"chat-integrated/services"
+	"compress/flate"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// Compression negotiates permessage-deflate (RFC 7692) on WebSocket
+// upgrades. Messages of at least Threshold bytes go out compressed at
+// Level; smaller ones aren't worth the CPU. The bytes it saves are counted
+// in Metrics.
+type Compression struct {
+	Level     int
+	Threshold int
+	Metrics   *services.MetricsService
+}
+
+// NewCompression configures compression from config.WSCompression and its
+// knobs. It returns nil, which upgrades without compression, when it is
+// turned off.
+func NewCompression(metricsService *services.MetricsService) *Compression {
+	if !config.WSCompression {
+		return nil
+	}
+	level := config.WSCompressionLevel
+	if level < flate.BestSpeed || level > flate.BestCompression {
+		log.Printf("⚠️ Invalid WS_COMPRESSION_LEVEL %d, using %d", level, flate.BestSpeed)
+		level = flate.BestSpeed
+	}
+	return &Compression{Level: level, Threshold: config.WSCompressionThreshold, Metrics: metricsService}
+}
+
+// Upgrade upgrades a request through u, with compression if the client
+// offered it. The connection it returns counts the bytes it writes, so
+// WriteMessage can tell what compression saved.
+func (c *Compression) Upgrade(u websocket.Upgrader, w http.ResponseWriter, r *http.Request) (*CompressedConn, error) {
+	if c == nil {
+		conn, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &CompressedConn{Conn: conn}, nil
+	}
+
+	u.EnableCompression = true
+	counter := &countingHijacker{ResponseWriter: w}
+	conn, err := u.Upgrade(counter, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	compressed := &CompressedConn{Conn: conn, wire: counter.conn}
+	// gorilla/websocket accepts the extension whenever the client offers it
+	if offersDeflate(r) {
+		compressed.compression = c
+		conn.SetCompressionLevel(c.Level)
+		c.Metrics.IncCounter("ws_compressed_connections_total")
+	}
+	return compressed, nil
+}
+
+// CompressedConn is a gorilla/websocket connection whose WriteMessage
+// compresses by size, if permessage-deflate was negotiated.
+type CompressedConn struct {
+	*websocket.Conn
+	// compression is nil when the connection isn't compressed
+	compression *Compression
+	wire        *countingConn
+}
+
+// Compressed tells whether the connection negotiated permessage-deflate.
+func (cc *CompressedConn) Compressed() bool {
+	return cc.compression != nil
+}
+
+func (cc *CompressedConn) WriteMessage(messageType int, data []byte) error {
+	c := cc.compression
+	if c == nil || messageType == websocket.CloseMessage || messageType == websocket.PingMessage || messageType == websocket.PongMessage {
+		return cc.Conn.WriteMessage(messageType, data)
+	}
+
+	compress := len(data) >= c.Threshold
+	cc.Conn.EnableWriteCompression(compress)
+	before := cc.wire.written.Load()
+	err := cc.Conn.WriteMessage(messageType, data)
+	if err != nil || !compress {
+		return err
+	}
+
+	// The wire bytes include the frame headers, a few bytes per frame
+	wire := cc.wire.written.Load() - before
+	c.Metrics.IncCounter("ws_compressed_messages_total")
+	c.Metrics.AddCounter("ws_compression_input_bytes_total", float64(len(data)))
+	c.Metrics.AddCounter("ws_compression_output_bytes_total", float64(wire))
+	if saved := int64(len(data)) - wire; saved > 0 {
+		c.Metrics.AddCounter("ws_compression_saved_bytes_total", float64(saved))
+	}
+	return nil
+}
+
+func offersDeflate(r *http.Request) bool {
+	for _, extensions := range r.Header.Values("Sec-WebSocket-Extensions") {
+		for _, extension := range strings.Split(extensions, ",") {
+			name, _, _ := strings.Cut(extension, ";")
+			if strings.EqualFold(strings.TrimSpace(name), "permessage-deflate") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// countingHijacker hands the upgrader a connection that counts the bytes
+// written to it.
+type countingHijacker struct {
+	http.ResponseWriter
+	conn *countingConn
+}
+
+func (ch *countingHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := ch.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	ch.conn = &countingConn{Conn: conn}
+	return ch.conn, rw, nil
+}
+
+type countingConn struct {
+	net.Conn
+	written atomic.Int64
+}
+
+func (cc *countingConn) Write(data []byte) (int, error) {
+	n, err := cc.Conn.Write(data)
+	cc.written.Add(int64(n))
+	return n, err
+}
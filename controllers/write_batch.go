@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"chat-integrated/codec"
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"log"
+	"time"
+)
+
+// collect waits for the next frame queued for client, then takes the ones
+// queued behind it without waiting, up to WriteBatchMaxFrames. A batched
+// connection also waits up to WriteBatchWindow for more. closed reports
+// that the lobby closed Send, after the frames returned.
+func collect(client *models.Client, rooms <-chan models.Message) (batch []models.Message, closed bool) {
+	select {
+	case msg, ok := <-client.Send:
+		if !ok {
+			return nil, true
+		}
+		batch = append(batch, msg)
+	case msg := <-rooms:
+		batch = append(batch, msg)
+	}
+
+	var window <-chan time.Time
+	if client.BatchedWrites && config.WriteBatchWindow > 0 {
+		timer := time.NewTimer(config.WriteBatchWindow)
+		defer timer.Stop()
+		window = timer.C
+	}
+	for len(batch) < config.WriteBatchMaxFrames {
+		msg, ok := models.Message{}, true
+		if window == nil {
+			select {
+			case msg, ok = <-client.Send:
+			case msg = <-rooms:
+			default:
+				return batch, false
+			}
+		} else {
+			select {
+			case msg, ok = <-client.Send:
+			case msg = <-rooms:
+			case <-window:
+				return batch, false
+			}
+		}
+		if !ok {
+			return batch, true
+		}
+		batch = append(batch, msg)
+	}
+	return batch, false
+}
+
+// coalesce drops the updates a later frame of the batch supersedes: a
+// presence_changed followed by another about the same member of the same
+// lobby, and a user_list followed by another of the same lobby. The
+// frames left keep their order.
+func coalesce(batch []models.Message) []models.Message {
+	if len(batch) < 2 {
+		return batch
+	}
+	type update struct {
+		lobbyID string
+		action  models.SystemActionType
+		target  string
+	}
+	updateOf := func(msg models.Message) (update, bool) {
+		if msg.Type != models.MessageTypeSystemAction || msg.SystemAction == nil {
+			return update{}, false
+		}
+		switch *msg.SystemAction {
+		case models.SystemActionPresence:
+			return update{msg.LobbyID, *msg.SystemAction, msg.Target}, true
+		case models.SystemActionUserList:
+			return update{msg.LobbyID, *msg.SystemAction, ""}, true
+		}
+		return update{}, false
+	}
+
+	latest := make(map[update]int)
+	for i, msg := range batch {
+		if key, ok := updateOf(msg); ok {
+			latest[key] = i
+		}
+	}
+	kept := batch[:0]
+	for i, msg := range batch {
+		if key, ok := updateOf(msg); ok && latest[key] != i {
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	return kept
+}
+
+// writeBatch encodes the frames and writes them: as array messages of up to
+// WriteBatchMaxBytes when the connection batches and its codec can, one
+// message per frame otherwise. It returns the write error that ends the
+// connection, if any.
+func (wsc *WSController) writeBatch(client *models.Client, frames codec.Codec, batch []models.Message) error {
+	encoded := make([][]byte, 0, len(batch))
+	for _, message := range batch {
+		data, err := frames.Marshal(wsc.lobbyService.PublicMessage(message))
+		if err != nil {
+			log.Printf("❌ Failed to encode message for %s: %v", client.Email, err)
+			continue
+		}
+		encoded = append(encoded, data)
+	}
+
+	batcher, ok := frames.(codec.Batcher)
+	if !client.BatchedWrites || !ok {
+		for _, data := range encoded {
+			if err := writeFrame(client, frames.MessageType(), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for len(encoded) > 0 {
+		n, size := 1, len(encoded[0])
+		for n < len(encoded) && size+len(encoded[n]) <= config.WriteBatchMaxBytes {
+			size += len(encoded[n])
+			n++
+		}
+		if err := writeFrame(client, frames.MessageType(), batcher.Batch(encoded[:n])); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+func writeFrame(client *models.Client, messageType int, data []byte) error {
+	client.Conn.SetWriteDeadline(time.Now().Add(config.WriteTimeout))
+	return client.Conn.WriteMessage(messageType, data)
+}
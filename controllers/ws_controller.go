@@ -1,77 +1,251 @@
 package controllers
 
 import (
+	"chat-integrated/codec"
+	"chat-integrated/config"
 	"chat-integrated/models"
 	"chat-integrated/services"
+	"chat-integrated/telemetry"
+	"context"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type WSController struct {
 	BaseController
-	lobbyService *services.LobbyService
-	upgrader     websocket.Upgrader
+	lobbyService  *services.LobbyService
+	inviteService *services.InviteService
+	// Upgrader accepts WebSocket connections; it defaults to gorilla/websocket
+	Upgrader models.Upgrader
 }
 
-func NewWSController(lobbyService *services.LobbyService) *WSController {
+// NewWSController accepts connections with compression, or without it for
+// a nil compression.
+func NewWSController(lobbyService *services.LobbyService, policyService *services.PolicyService, inviteService *services.InviteService, compression *Compression) *WSController {
 	return &WSController{
-		lobbyService: lobbyService,
-		upgrader: websocket.Upgrader{
+		BaseController: BaseController{Policy: policyService},
+		lobbyService:   lobbyService,
+		inviteService:  inviteService,
+		Upgrader: gorillaUpgrader{websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
+			Subprotocols:    codec.Subprotocols(),
 			CheckOrigin: func(r *http.Request) bool {
 				return true
 			},
-		},
+		}, compression},
 	}
 }
 
 // Add this public method to expose upgrader functionality
-func (wsc *WSController) UpgradeConnection(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
-	return wsc.upgrader.Upgrade(w, r, nil)
+func (wsc *WSController) UpgradeConnection(w http.ResponseWriter, r *http.Request) (models.Conn, error) {
+	return wsc.Upgrader.Upgrade(w, r)
+}
+
+// gorillaUpgrader adapts gorilla/websocket, whose *Conn is a models.Conn.
+type gorillaUpgrader struct {
+	websocket.Upgrader
+	compression *Compression
+}
+
+func (u gorillaUpgrader) Upgrade(w http.ResponseWriter, r *http.Request) (models.Conn, error) {
+	conn, err := u.compression.Upgrade(u.Upgrader, w, r)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
 }
 
 func (wsc *WSController) ReadPump(client *models.Client) {
+	// A connection dropped for a bad frame is left for the writer to close,
+	// so the close frame saying why gets out first
+	closeNow := true
 	defer func() {
-		wsc.lobbyService.Unregister <- client
-		client.Conn.Close()
+		wsc.lobbyService.LeaveRooms(client)
+		wsc.lobbyService.Unregister(client)
+		if closeNow {
+			client.Conn.Close()
+		}
 	}()
 
+	frames := codec.ForSubprotocol(client.Encoding)
 	for {
-		var msg models.Message
-		err := client.Conn.ReadJSON(&msg)
+		_, data, err := client.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				log.Printf("WebSocket error [%s]: %v", client.RequestID, err)
 			}
 			break
 		}
+		var frame models.Message
+		if err := frames.Unmarshal(data, &frame); err != nil {
+			log.Printf("WebSocket error [%s]: invalid frame from %s: %v", client.RequestID, client.Email, err)
+			client.SetCloseStatus(models.ErrorBadRequest.CloseCode(), "Invalid frame")
+			closeNow = false
+			break
+		}
+
+		if !wsc.dispatch(client, frame) {
+			break
+		}
+	}
+}
 
-		msg.Type = models.MessageTypeChat
-		msg.Username = client.Email
-		msg.LobbyID = client.LobbyID
-		msg.Timestamp = time.Now()
+// dispatch routes a client frame by its action: joins and leaves to the
+// connection's rooms, chat to the lobby's broadcast, and control and admin
+// frames to the lobby worker as commands. It returns false once the
+// connection should close.
+func (wsc *WSController) dispatch(client *models.Client, frame models.Message) bool {
+	frame, route, err := routeFrame(frame)
+	if err != nil {
+		wsc.rejectFrame(client, frame.Type, models.ErrorBadRequest, err.Error())
+		return true
+	}
+
+	// Joining and leaving other lobbies is up to the connection's role
+	if frame.Type == models.MessageTypeJoin || frame.Type == models.MessageTypeLeave {
+		wsc.followLobby(client, frame)
+		return true
+	}
 
-		wsc.lobbyService.Broadcast <- services.BroadcastMessage{
-			LobbyID: client.LobbyID,
-			Message: msg,
+	// A frame naming a lobby the connection joined goes to that lobby
+	target := wsc.lobbyService.Room(client, frame.LobbyID)
+	if target == nil {
+		wsc.rejectFrame(client, frame.Type, models.ErrorNotFound, services.ErrNotJoined.Error())
+		return true
+	}
+
+	// Either the connection role or the user's lobby role may grant a frame
+	action := services.FrameAction(frame.Type)
+	if !wsc.Policy.Allowed(target.Role, action) && !wsc.Policy.Allowed(wsc.lobbyService.UserRole(target), action) {
+		reason := "You are not allowed to send this message"
+		if route.class == frameAdmin {
+			reason = "You are not allowed to manage this lobby"
 		}
+		wsc.rejectFrame(target, frame.Type, models.ErrorForbidden, reason)
+		return true
+	}
+
+	// Identity always comes from the connection, never from the frame
+	msg, err := services.ClientFrame(target, frame)
+	if err != nil {
+		wsc.rejectFrame(target, frame.Type, services.ErrorCodeOf(err), err.Error())
+		return true
+	}
+
+	if route.class != frameChat {
+		wsc.lobbyService.SendCommand(services.LobbyCommand{Client: target, Frame: msg})
+		return true
+	}
+
+	// Each message starts its own trace, through to its fan-out
+	ctx, span := telemetry.Tracer.Start(context.Background(), "ws.receive", trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(
+		attribute.String("lobby.id", target.LobbyID),
+		attribute.String("message.type", string(msg.Type)),
+		attribute.String("request.id", client.RequestID),
+	))
+	err = wsc.lobbyService.Broadcast(ctx, services.BroadcastMessage{
+		LobbyID: target.LobbyID,
+		Message: msg,
+	})
+	span.End()
+	if err != nil && target == client {
+		return false
+	}
+	if err != nil {
+		wsc.rejectFrame(client, frame.Type, services.ErrorCodeOf(err), err.Error())
+	}
+	return true
+}
+
+// followLobby handles a join or leave frame for another lobby. A join with
+// an invite takes a seat in the invite's lobby first.
+func (wsc *WSController) followLobby(client *models.Client, frame models.Message) {
+	if !wsc.Policy.Allowed(client.Role, services.ActionLobbyJoin) {
+		wsc.rejectFrame(client, frame.Type, models.ErrorForbidden, "You are not allowed to send this message")
+		return
+	}
+	var err error
+	switch {
+	case frame.Type == models.MessageTypeJoin && frame.Invite != "":
+		err = wsc.joinInvited(client, frame)
+	case frame.Type == models.MessageTypeJoin:
+		err = wsc.lobbyService.JoinRoom(client, frame.LobbyID)
+	default:
+		err = wsc.lobbyService.LeaveRoom(client, frame.LobbyID)
+	}
+	if err != nil {
+		wsc.rejectFrame(client, frame.Type, services.ErrorCodeOf(err), err.Error())
+	}
+}
+
+// joinInvited joins the lobby of a join frame with the invite it carries,
+// which has to be for that lobby.
+func (wsc *WSController) joinInvited(client *models.Client, frame models.Message) error {
+	invite, err := wsc.inviteService.Verify(frame.Invite)
+	if err != nil {
+		return err
+	}
+	if invite.LobbyID != frame.LobbyID {
+		return services.ErrInvalidInvite
+	}
+	return wsc.lobbyService.JoinRoomInvited(client, invite, func() error {
+		return wsc.inviteService.Redeem(invite)
+	})
+}
+
+// rejectFrame tells the client a frame was refused.
+func (wsc *WSController) rejectFrame(client *models.Client, frameType models.MessageType, code models.ErrorCode, reason string) {
+	log.Printf("🚫 Frame %q from %s rejected: %s", frameType, client.Email, reason)
+
+	errorAction := models.SystemActionError
+	select {
+	case client.Send <- models.Message{
+		Type:         models.MessageTypeSystemAction,
+		SystemAction: &errorAction,
+		Code:         code,
+		Content:      reason,
+		LobbyID:      client.LobbyID,
+		Timestamp:    time.Now(),
+	}:
+	default:
 	}
 }
 
 func (wsc *WSController) WritePump(client *models.Client) {
 	defer func() {
 		client.Conn.Close()
-		log.Printf("🔌 WritePump closed for: %s", client.Email)
+		client.MarkGone()
+		log.Printf("🔌 [%s] WritePump closed for: %s", client.RequestID, client.Email)
 	}()
 
-	for message := range client.Send {
-		err := client.Conn.WriteJSON(message)
-		if err != nil {
-			log.Printf("❌ Write error for %s: %v", client.Email, err)
+	// A write that can't finish in WriteTimeout closes the connection, which
+	// ends ReadPump and unregisters the client. Members only ever see each
+	// other's member IDs and profiles, never emails
+	frames := codec.ForSubprotocol(client.Encoding)
+	// Frames of the other lobbies the connection joined come from its rooms
+	var rooms <-chan models.Message
+	if client.Rooms != nil {
+		rooms = client.Rooms.Out
+	}
+	// Frames queued together are written together: superseded presence
+	// updates are dropped, and a batched connection gets them as arrays
+	for {
+		batch, closed := collect(client, rooms)
+		if err := wsc.writeBatch(client, frames, coalesce(batch)); err != nil {
+			log.Printf("❌ [%s] Write error for %s: %v", client.RequestID, client.Email, err)
+			return
+		}
+		if closed {
+			// Closed by the lobby, which picked the close frame
+			code, reason := client.CloseStatus()
+			client.Conn.SetWriteDeadline(time.Now().Add(config.WriteTimeout))
+			client.Conn.WriteMessage(models.CloseMessage, models.FormatCloseMessage(code, reason))
 			return
 		}
 	}
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"chat-integrated/models"
+	"errors"
+	"fmt"
+)
+
+var ErrConflictingAction = errors.New("action and type name different actions")
+
+// frameClass separates what client frames do. Chat is moderated, stored and
+// broadcast; control frames change the sender's own state, such as their
+// presence, reactions or the lobbies they follow; admin frames manage the
+// lobby and are for its owner and moderators.
+type frameClass int
+
+const (
+	frameChat frameClass = iota
+	frameControl
+	frameAdmin
+)
+
+// frameRoute is how dispatch handles one action.
+type frameRoute struct {
+	class frameClass
+	// validate checks the fields the action needs, before the frame
+	// reaches the policy or the lobby
+	validate func(frame models.Message) error
+}
+
+// frameRoutes lists every action a client may send. Anything else is
+// refused rather than taken for chat.
+var frameRoutes = map[models.MessageType]frameRoute{
+	models.MessageTypeChat:  {frameChat, requireContent},
+	models.MessageTypeReply: {frameChat, validateReply},
+	models.MessageTypeIdea:  {frameChat, requireContent},
+
+	models.MessageTypeJoin:        {frameControl, requireLobbyID},
+	models.MessageTypeLeave:       {frameControl, requireLobbyID},
+	models.MessageTypePing:        {frameControl, nil},
+	models.MessageTypeHistoryAck:  {frameControl, requireReplayBatch},
+	models.MessageTypeDeliveryAck: {frameControl, requireSeq},
+	models.MessageTypeVisibility:  {frameControl, nil},
+	models.MessageTypeMessageRead: {frameControl, requireMessageID},
+	models.MessageTypeReact:       {frameControl, validateReact},
+	models.MessageTypeVote:        {frameControl, requireTargetSeq},
+
+	models.MessageTypeChannelCreate: {frameControl, validateChannelCreate},
+	models.MessageTypeChannelLeave:  {frameControl, requireChannelID},
+
+	models.MessageTypeEndLobby:        {frameAdmin, nil},
+	models.MessageTypeKick:            {frameAdmin, requireTarget},
+	models.MessageTypePin:             {frameAdmin, requirePinnedSeq},
+	models.MessageTypeUnpin:           {frameAdmin, requirePinnedSeq},
+	models.MessageTypeSetMaxUsers:     {frameAdmin, validateMaxUsers},
+	models.MessageTypeSetRole:         {frameAdmin, validateSetRole},
+	models.MessageTypeSetSystemEvents: {frameAdmin, validateSystemEvents},
+	models.MessageTypeSetGuestAccess:  {frameAdmin, nil},
+	models.MessageTypeSetBudget:       {frameAdmin, nil},
+	models.MessageTypeSetSlowMode:     {frameAdmin, nil},
+}
+
+// frameAction returns the action a client frame names in action, or in
+// type for older clients. A frame naming neither is chat.
+func frameAction(frame models.Message) (models.MessageType, error) {
+	if frame.Action != "" && frame.Type != "" && frame.Action != frame.Type {
+		return "", ErrConflictingAction
+	}
+	switch {
+	case frame.Action != "":
+		return frame.Action, nil
+	case frame.Type != "":
+		return frame.Type, nil
+	default:
+		return models.MessageTypeChat, nil
+	}
+}
+
+// routeFrame returns the route of a frame's action, with the frame's Type
+// set to it, or why the frame was refused.
+func routeFrame(frame models.Message) (models.Message, frameRoute, error) {
+	action, err := frameAction(frame)
+	if err != nil {
+		return frame, frameRoute{}, err
+	}
+	frame.Type = action
+	route, ok := frameRoutes[action]
+	if !ok {
+		return frame, frameRoute{}, fmt.Errorf("unknown action %q", action)
+	}
+	if route.validate != nil {
+		if err := route.validate(frame); err != nil {
+			return frame, frameRoute{}, err
+		}
+	}
+	return frame, route, nil
+}
+
+func requireContent(frame models.Message) error {
+	if frame.Content == "" {
+		return errors.New("content is required")
+	}
+	return nil
+}
+
+func validateReply(frame models.Message) error {
+	if frame.ParentMessageID <= 0 {
+		return errors.New("parent_message_id is required")
+	}
+	return requireContent(frame)
+}
+
+func requireLobbyID(frame models.Message) error {
+	if frame.LobbyID == "" {
+		return errors.New("lobby_id is required")
+	}
+	return nil
+}
+
+func requireMessageID(frame models.Message) error {
+	if frame.MessageID == "" {
+		return errors.New("message_id is required")
+	}
+	return nil
+}
+
+func requireTargetSeq(frame models.Message) error {
+	if frame.TargetSeq <= 0 {
+		return errors.New("target_seq is required")
+	}
+	return nil
+}
+
+func requireReplayBatch(frame models.Message) error {
+	if frame.Replay == nil || frame.Replay.Batch <= 0 {
+		return errors.New("replay.batch is required")
+	}
+	return nil
+}
+
+func requireSeq(frame models.Message) error {
+	if frame.Seq <= 0 {
+		return errors.New("seq is required")
+	}
+	return nil
+}
+
+func validateChannelCreate(frame models.Message) error {
+	if frame.Channel == nil || frame.Channel.Name == "" {
+		return errors.New("channel.name is required")
+	}
+	return nil
+}
+
+func requireChannelID(frame models.Message) error {
+	if frame.ChannelID == "" {
+		return errors.New("channel_id is required")
+	}
+	return nil
+}
+
+func validateReact(frame models.Message) error {
+	if frame.Reaction == "" {
+		return errors.New("reaction is required")
+	}
+	return requireTargetSeq(frame)
+}
+
+func requireTarget(frame models.Message) error {
+	if frame.Target == "" {
+		return errors.New("target is required")
+	}
+	return nil
+}
+
+func requirePinnedSeq(frame models.Message) error {
+	if frame.PinnedSeq <= 0 {
+		return errors.New("pinned_seq is required")
+	}
+	return nil
+}
+
+func validateMaxUsers(frame models.Message) error {
+	if frame.MaxUsers <= 0 {
+		return errors.New("max_users is required")
+	}
+	return nil
+}
+
+func validateSetRole(frame models.Message) error {
+	if frame.Role == "" {
+		return errors.New("role is required")
+	}
+	return requireTarget(frame)
+}
+
+func validateSystemEvents(frame models.Message) error {
+	if frame.SystemEvents == "" {
+		return errors.New("system_events is required")
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package grpc
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// connectTicketHeader carries a connect ticket from
+// /api/lobbies/{id}/connect-ticket, as /ws takes it in its query.
+const connectTicketHeader = "x-connect-ticket"
+
+var errCredentialsRequired = status.Error(codes.Unauthenticated, "a session token, connect ticket or API key is required")
+
+// caller is whom a call's credential stands for.
+type caller struct {
+	// email is the member the credential belongs to; empty for a service
+	email string
+	// lobbyID is the lobby a connect ticket was issued for
+	lobbyID string
+	// service is an admin key or admin API key, which acts for the member
+	// a request names
+	service bool
+	// role is the policy role of the credential, as the HTTP API has it
+	role models.Role
+}
+
+// authenticate resolves the credential in the call's metadata the way the
+// HTTP API does: the admin key or an admin API key, a connect ticket, which
+// is used up, or a session token as "authorization: Bearer <token>". It
+// returns errCredentialsRequired when there is none.
+func (s *Server) authenticate(ctx context.Context) (caller, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	if key := first(strings.ToLower(config.AdminKeyHeader)); key != "" {
+		if config.AdminAPIKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(config.AdminAPIKey)) != 1 {
+			return caller{}, status.Error(codes.Unauthenticated, "invalid admin key")
+		}
+		return caller{service: true, role: models.RoleAdmin}, nil
+	}
+
+	if ticket := first(connectTicketHeader); ticket != "" {
+		redeemed, err := s.sessionService.RedeemConnectTicket(ticket)
+		if errors.Is(err, services.ErrInvalidTicket) {
+			return caller{}, status.Error(codes.Unauthenticated, "invalid or expired connect ticket")
+		}
+		if err != nil {
+			log.Printf("❌ Failed to redeem gRPC connect ticket: %v", err)
+			return caller{}, status.Error(codes.Unavailable, "failed to check the connect ticket")
+		}
+		return caller{email: redeemed.Email, lobbyID: redeemed.LobbyID, role: models.RoleUser}, nil
+	}
+
+	token, ok := strings.CutPrefix(first("authorization"), "Bearer ")
+	if !ok || token == "" {
+		return caller{}, errCredentialsRequired
+	}
+	if services.IsAPIKey(token) {
+		apiKey, err := s.apiKeyService.Authenticate(token)
+		if err != nil {
+			return caller{}, status.Error(codes.Unauthenticated, "invalid API key")
+		}
+		if apiKey.Role != models.RoleAdmin {
+			return caller{}, status.Error(codes.PermissionDenied, "API key may not act for members")
+		}
+		return caller{service: true, role: models.RoleAdmin}, nil
+	}
+
+	session, err := s.sessionService.GetSession(token)
+	if errors.Is(err, services.ErrSessionNotFound) {
+		return caller{}, status.Error(codes.Unauthenticated, "invalid or expired session")
+	}
+	if err != nil {
+		log.Printf("❌ Failed to look up gRPC session: %v", err)
+		return caller{}, status.Error(codes.Unavailable, "failed to check the session")
+	}
+	role := models.RoleUser
+	if session.Guest {
+		role = models.RoleGuest
+	}
+	return caller{email: session.Email, role: role}, nil
+}
+
+// member resolves whom a call acts as. A member's credential speaks for
+// them alone, so a request naming someone else is refused; a service names
+// the member in the request.
+func (c caller) member(requested string) (string, error) {
+	if c.service {
+		if requested == "" {
+			return "", status.Error(codes.InvalidArgument, "email is required")
+		}
+		return requested, nil
+	}
+	if requested != "" && !strings.EqualFold(requested, c.email) {
+		return "", status.Error(codes.PermissionDenied, "credential does not belong to "+requested)
+	}
+	return c.email, nil
+}
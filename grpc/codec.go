@@ -0,0 +1,38 @@
+package grpc
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/encoding"
+)
+
+type wireMessage interface {
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+}
+
+// codec serves the hand-written chat.proto types under the standard "proto"
+// content subtype, so stock protobuf clients need no special configuration.
+type codec struct{}
+
+var _ encoding.Codec = codec{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(wireMessage)
+	if !ok {
+		return nil, fmt.Errorf("grpc: cannot marshal %T", v)
+	}
+	return msg.Marshal()
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(wireMessage)
+	if !ok {
+		return fmt.Errorf("grpc: cannot unmarshal into %T", v)
+	}
+	return msg.Unmarshal(data)
+}
+
+func (codec) Name() string {
+	return "proto"
+}
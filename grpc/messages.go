@@ -0,0 +1,348 @@
+package grpc
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The types below are hand-written counterparts of chat.proto. They encode
+// the standard protobuf wire format, so clients generated from chat.proto
+// interoperate with them directly.
+
+type Message struct {
+	Type            string
+	SystemAction    string
+	Username        string
+	Content         string
+	LobbyID         string
+	Seq             int64
+	TimestampUnixMs int64
+	UserList        []string
+	Mentions        []string
+}
+
+func (m *Message) Marshal() ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, m.Type)
+	b = appendString(b, 2, m.SystemAction)
+	b = appendString(b, 3, m.Username)
+	b = appendString(b, 4, m.Content)
+	b = appendString(b, 5, m.LobbyID)
+	b = appendVarint(b, 6, uint64(m.Seq))
+	b = appendVarint(b, 7, uint64(m.TimestampUnixMs))
+	for _, user := range m.UserList {
+		b = appendRepeatedString(b, 8, user)
+	}
+	for _, mention := range m.Mentions {
+		b = appendRepeatedString(b, 9, mention)
+	}
+	return b, nil
+}
+
+func (m *Message) Unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, value []byte, varint uint64) error {
+		switch num {
+		case 1:
+			m.Type = string(value)
+		case 2:
+			m.SystemAction = string(value)
+		case 3:
+			m.Username = string(value)
+		case 4:
+			m.Content = string(value)
+		case 5:
+			m.LobbyID = string(value)
+		case 6:
+			m.Seq = int64(varint)
+		case 7:
+			m.TimestampUnixMs = int64(varint)
+		case 8:
+			m.UserList = append(m.UserList, string(value))
+		case 9:
+			m.Mentions = append(m.Mentions, string(value))
+		}
+		return nil
+	})
+}
+
+type User struct {
+	Email    string
+	LobbyID  string
+	IsActive bool
+}
+
+func (m *User) Marshal() ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, m.Email)
+	b = appendString(b, 2, m.LobbyID)
+	b = appendBool(b, 3, m.IsActive)
+	return b, nil
+}
+
+func (m *User) Unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, value []byte, varint uint64) error {
+		switch num {
+		case 1:
+			m.Email = string(value)
+		case 2:
+			m.LobbyID = string(value)
+		case 3:
+			m.IsActive = varint != 0
+		}
+		return nil
+	})
+}
+
+type Lobby struct {
+	ID        string
+	UserCount int32
+	MaxUsers  int32
+	Users     []*User
+}
+
+func (m *Lobby) Marshal() ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, m.ID)
+	b = appendVarint(b, 2, uint64(m.UserCount))
+	b = appendVarint(b, 3, uint64(m.MaxUsers))
+	for _, user := range m.Users {
+		userBytes, _ := user.Marshal()
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, userBytes)
+	}
+	return b, nil
+}
+
+func (m *Lobby) Unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, value []byte, varint uint64) error {
+		switch num {
+		case 1:
+			m.ID = string(value)
+		case 2:
+			m.UserCount = int32(varint)
+		case 3:
+			m.MaxUsers = int32(varint)
+		case 4:
+			user := &User{}
+			if err := user.Unmarshal(value); err != nil {
+				return err
+			}
+			m.Users = append(m.Users, user)
+		}
+		return nil
+	})
+}
+
+type CreateLobbyRequest struct {
+	TenantID string
+}
+
+func (m *CreateLobbyRequest) Marshal() ([]byte, error) {
+	return appendString(nil, 1, m.TenantID), nil
+}
+
+func (m *CreateLobbyRequest) Unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, value []byte, varint uint64) error {
+		if num == 1 {
+			m.TenantID = string(value)
+		}
+		return nil
+	})
+}
+
+type JoinRequest struct {
+	Email    string
+	TenantID string
+}
+
+func (m *JoinRequest) Marshal() ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, m.Email)
+	b = appendString(b, 2, m.TenantID)
+	return b, nil
+}
+
+func (m *JoinRequest) Unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, value []byte, varint uint64) error {
+		switch num {
+		case 1:
+			m.Email = string(value)
+		case 2:
+			m.TenantID = string(value)
+		}
+		return nil
+	})
+}
+
+type JoinResponse struct {
+	Success      bool
+	Message      string
+	LobbyID      string
+	Reconnecting bool
+}
+
+func (m *JoinResponse) Marshal() ([]byte, error) {
+	var b []byte
+	b = appendBool(b, 1, m.Success)
+	b = appendString(b, 2, m.Message)
+	b = appendString(b, 3, m.LobbyID)
+	b = appendBool(b, 4, m.Reconnecting)
+	return b, nil
+}
+
+func (m *JoinResponse) Unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, value []byte, varint uint64) error {
+		switch num {
+		case 1:
+			m.Success = varint != 0
+		case 2:
+			m.Message = string(value)
+		case 3:
+			m.LobbyID = string(value)
+		case 4:
+			m.Reconnecting = varint != 0
+		}
+		return nil
+	})
+}
+
+type SendMessageRequest struct {
+	Email   string
+	LobbyID string
+	Content string
+}
+
+func (m *SendMessageRequest) Marshal() ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, m.Email)
+	b = appendString(b, 2, m.LobbyID)
+	b = appendString(b, 3, m.Content)
+	return b, nil
+}
+
+func (m *SendMessageRequest) Unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, value []byte, varint uint64) error {
+		switch num {
+		case 1:
+			m.Email = string(value)
+		case 2:
+			m.LobbyID = string(value)
+		case 3:
+			m.Content = string(value)
+		}
+		return nil
+	})
+}
+
+type SendMessageResponse struct {
+	Accepted bool
+}
+
+func (m *SendMessageResponse) Marshal() ([]byte, error) {
+	return appendBool(nil, 1, m.Accepted), nil
+}
+
+func (m *SendMessageResponse) Unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, value []byte, varint uint64) error {
+		if num == 1 {
+			m.Accepted = varint != 0
+		}
+		return nil
+	})
+}
+
+type StreamMessagesRequest struct {
+	Email   string
+	LobbyID string
+	LastSeq int64
+}
+
+func (m *StreamMessagesRequest) Marshal() ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, m.Email)
+	b = appendString(b, 2, m.LobbyID)
+	b = appendVarint(b, 3, uint64(m.LastSeq))
+	return b, nil
+}
+
+func (m *StreamMessagesRequest) Unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, value []byte, varint uint64) error {
+		switch num {
+		case 1:
+			m.Email = string(value)
+		case 2:
+			m.LobbyID = string(value)
+		case 3:
+			m.LastSeq = int64(varint)
+		}
+		return nil
+	})
+}
+
+// Proto3 omits zero-valued scalar fields on the wire.
+
+func appendString(b []byte, num protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	return appendRepeatedString(b, num, value)
+}
+
+func appendRepeatedString(b []byte, num protowire.Number, value string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+func appendVarint(b []byte, num protowire.Number, value uint64) []byte {
+	if value == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, value)
+}
+
+func appendBool(b []byte, num protowire.Number, value bool) []byte {
+	if !value {
+		return b
+	}
+	return appendVarint(b, num, 1)
+}
+
+// decodeFields walks the fields of an encoded message, handing length
+// delimited values and varints to field and skipping anything else.
+func decodeFields(b []byte, field func(num protowire.Number, value []byte, varint uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			if err := field(num, nil, v); err != nil {
+				return err
+			}
+			b = b[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			if err := field(num, v, 0); err != nil {
+				return err
+			}
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+	return nil
+}
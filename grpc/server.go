@@ -0,0 +1,339 @@
+// Package grpc exposes LobbyService over gRPC for bots and backend services
+// that would rather not speak the WebSocket protocol. The wire contract is
+// defined in chat.proto.
+package grpc
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LobbyServer is the service interface described by chat.proto.
+type LobbyServer interface {
+	CreateLobby(ctx context.Context, req *CreateLobbyRequest) (*Lobby, error)
+	Join(ctx context.Context, req *JoinRequest) (*JoinResponse, error)
+	SendMessage(ctx context.Context, req *SendMessageRequest) (*SendMessageResponse, error)
+	StreamMessages(req *StreamMessagesRequest, stream grpclib.ServerStream) error
+}
+
+type Server struct {
+	lobbyService   *services.LobbyService
+	sessionService *services.SessionService
+	apiKeyService  *services.APIKeyService
+	policyService  *services.PolicyService
+	grpcServer     *grpclib.Server
+}
+
+func NewServer(lobbyService *services.LobbyService, sessionService *services.SessionService, apiKeyService *services.APIKeyService, policyService *services.PolicyService) *Server {
+	s := &Server{
+		lobbyService:   lobbyService,
+		sessionService: sessionService,
+		apiKeyService:  apiKeyService,
+		policyService:  policyService,
+		grpcServer:     grpclib.NewServer(grpclib.ForceServerCodec(codec{})),
+	}
+	s.grpcServer.RegisterService(&serviceDesc, s)
+	return s
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("🛰️ gRPC API listening on %s", addr)
+	return s.grpcServer.Serve(listener)
+}
+
+func (s *Server) Stop() {
+	s.grpcServer.GracefulStop()
+}
+
+// Shutdown stops gracefully, cancelling open streams once ctx expires.
+func (s *Server) Shutdown(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+	}
+}
+
+// CreateLobby returns the tenant's open lobby, opening one if there is
+// none, for a credential whose role may create lobbies, as POST
+// /api/lobbies requires.
+func (s *Server) CreateLobby(ctx context.Context, req *CreateLobbyRequest) (*Lobby, error) {
+	caller, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !s.policyService.Allowed(caller.role, services.ActionLobbyCreate) {
+		return nil, status.Error(codes.PermissionDenied, "not allowed to "+string(services.ActionLobbyCreate))
+	}
+
+	tenantID := req.TenantID
+	if tenantID == "" {
+		tenantID = config.DefaultTenantID
+	}
+
+	lobby := s.lobbyService.GetOrCreateLobby(tenantID)
+	if lobby == nil {
+		return nil, status.Error(codes.Unavailable, services.ErrSessionInProgress.Error())
+	}
+	return toLobby(lobby), nil
+}
+
+// Join seats the member a call's credential acts as, like a login; the
+// email alone is enough only with OPEN_LOGIN.
+func (s *Server) Join(ctx context.Context, req *JoinRequest) (*JoinResponse, error) {
+	email, err := s.joiner(ctx, req.Email)
+	if err != nil {
+		return nil, err
+	}
+	tenantID := req.TenantID
+	if tenantID == "" {
+		tenantID = config.DefaultTenantID
+	}
+
+	lobby, reconnecting, err := s.lobbyService.JoinLobby(email, tenantID)
+	if errors.Is(err, services.ErrSessionInProgress) || errors.Is(err, services.ErrLobbyFull) || errors.Is(err, services.ErrKickedFromLobby) {
+		return &JoinResponse{Success: false, Message: err.Error()}, nil
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &JoinResponse{
+		Success:      true,
+		Message:      "joined",
+		LobbyID:      lobby.ID,
+		Reconnecting: reconnecting,
+	}, nil
+}
+
+func (s *Server) SendMessage(ctx context.Context, req *SendMessageRequest) (*SendMessageResponse, error) {
+	if strings.TrimSpace(req.Content) == "" {
+		return nil, status.Error(codes.InvalidArgument, "content is required")
+	}
+	email, err := s.memberLobby(ctx, req.Email, req.LobbyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same server-side stamping as WSController.ReadPump
+	msg := models.Message{
+		Type:      models.MessageTypeChat,
+		Username:  email,
+		Content:   req.Content,
+		LobbyID:   req.LobbyID,
+		Timestamp: time.Now(),
+	}
+
+	err = s.lobbyService.Broadcast(ctx, services.BroadcastMessage{LobbyID: req.LobbyID, Message: msg})
+	if errors.Is(err, services.ErrLobbyNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return &SendMessageResponse{Accepted: true}, nil
+}
+
+// StreamMessages registers the caller as a lobby client and forwards every
+// message delivered to it until the stream ends.
+func (s *Server) StreamMessages(req *StreamMessagesRequest, stream grpclib.ServerStream) error {
+	email, err := s.memberLobby(stream.Context(), req.Email, req.LobbyID)
+	if err != nil {
+		return err
+	}
+
+	client := &models.Client{
+		Email:    email,
+		LobbyID:  req.LobbyID,
+		Send:     make(chan models.Message, 256),
+		JoinedAt: time.Now(),
+		LastSeq:  req.LastSeq,
+	}
+	s.lobbyService.Register(client)
+	log.Printf("🛰️ gRPC stream opened for: %s in lobby: %s", email, req.LobbyID)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			s.lobbyService.Unregister(client)
+			return nil
+		case msg, ok := <-client.Send:
+			if !ok {
+				// The lobby service dropped us as a slow consumer
+				return status.Error(codes.ResourceExhausted, "stream fell too far behind")
+			}
+			if err := stream.SendMsg(toMessage(s.lobbyService.PublicMessage(msg))); err != nil {
+				s.lobbyService.Unregister(client)
+				return err
+			}
+		}
+	}
+}
+
+// joiner resolves whom a Join is for: the member of its credential, the
+// member a service names, or, with OPEN_LOGIN and no credential, the email
+// requested.
+func (s *Server) joiner(ctx context.Context, requested string) (string, error) {
+	caller, err := s.authenticate(ctx)
+	if errors.Is(err, errCredentialsRequired) && config.OpenLogin {
+		if requested == "" {
+			return "", status.Error(codes.InvalidArgument, "email is required")
+		}
+		return requested, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return caller.member(requested)
+}
+
+// memberLobby resolves the member a call's credential acts as and checks
+// they are in lobbyID. requested is the email the request names, which only
+// a service credential may choose.
+func (s *Server) memberLobby(ctx context.Context, requested, lobbyID string) (string, error) {
+	if lobbyID == "" {
+		return "", status.Error(codes.InvalidArgument, "lobby_id is required")
+	}
+	caller, err := s.authenticate(ctx)
+	if err != nil {
+		return "", err
+	}
+	email, err := caller.member(requested)
+	if err != nil {
+		return "", err
+	}
+	if caller.lobbyID != "" && caller.lobbyID != lobbyID {
+		return "", status.Error(codes.PermissionDenied, "connect ticket is for another lobby")
+	}
+
+	lobby := s.lobbyService.GetLobby(lobbyID)
+	if lobby == nil {
+		return "", status.Error(codes.NotFound, "lobby not found")
+	}
+	if !lobby.IsUserInLobby(email) {
+		return "", status.Error(codes.PermissionDenied, "user not authorized for this lobby")
+	}
+	return email, nil
+}
+
+func toLobby(lobby *models.Lobby) *Lobby {
+	result := &Lobby{
+		ID:        lobby.ID,
+		UserCount: int32(lobby.GetUserCount()),
+		MaxUsers:  int32(lobby.MaxUsers),
+	}
+	for _, email := range lobby.GetMemberEmails() {
+		result.Users = append(result.Users, &User{
+			Email:    email,
+			LobbyID:  lobby.ID,
+			IsActive: lobby.IsUserActive(email),
+		})
+	}
+	return result
+}
+
+func toMessage(msg models.Message) *Message {
+	result := &Message{
+		Type:            string(msg.Type),
+		Username:        msg.Username,
+		Content:         msg.Content,
+		LobbyID:         msg.LobbyID,
+		Seq:             msg.Seq,
+		TimestampUnixMs: msg.Timestamp.UnixMilli(),
+		UserList:        msg.UserList,
+		Mentions:        msg.Mentions,
+	}
+	if msg.SystemAction != nil {
+		result.SystemAction = string(*msg.SystemAction)
+	}
+	return result
+}
+
+var serviceDesc = grpclib.ServiceDesc{
+	ServiceName: "chat.v1.LobbyService",
+	HandlerType: (*LobbyServer)(nil),
+	Methods: []grpclib.MethodDesc{
+		{
+			MethodName: "CreateLobby",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpclib.UnaryServerInterceptor) (interface{}, error) {
+				req := &CreateLobbyRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				return unary(srv, ctx, req, "CreateLobby", interceptor, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(LobbyServer).CreateLobby(ctx, req.(*CreateLobbyRequest))
+				})
+			},
+		},
+		{
+			MethodName: "Join",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpclib.UnaryServerInterceptor) (interface{}, error) {
+				req := &JoinRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				return unary(srv, ctx, req, "Join", interceptor, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(LobbyServer).Join(ctx, req.(*JoinRequest))
+				})
+			},
+		},
+		{
+			MethodName: "SendMessage",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpclib.UnaryServerInterceptor) (interface{}, error) {
+				req := &SendMessageRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				return unary(srv, ctx, req, "SendMessage", interceptor, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(LobbyServer).SendMessage(ctx, req.(*SendMessageRequest))
+				})
+			},
+		},
+	},
+	Streams: []grpclib.StreamDesc{
+		{
+			StreamName:    "StreamMessages",
+			ServerStreams: true,
+			Handler: func(srv interface{}, stream grpclib.ServerStream) error {
+				req := &StreamMessagesRequest{}
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(LobbyServer).StreamMessages(req, stream)
+			},
+		},
+	},
+	Metadata: "chat.proto",
+}
+
+func unary(srv interface{}, ctx context.Context, req interface{}, method string, interceptor grpclib.UnaryServerInterceptor, handler grpclib.UnaryHandler) (interface{}, error) {
+	if interceptor == nil {
+		return handler(ctx, req)
+	}
+	info := &grpclib.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.v1.LobbyService/" + method,
+	}
+	return interceptor(ctx, req, info, handler)
+}
@@ -0,0 +1,70 @@
+package grpc_test
+
+import (
+	chatgrpc "chat-integrated/grpc"
+	"chat-integrated/server"
+	"context"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestServer is the gRPC API of a hub on the memory store, called
+// directly rather than over a listener.
+func newTestServer(t *testing.T) (*chatgrpc.Server, *server.Hub) {
+	cfg := server.DefaultConfig()
+	cfg.Name = "test"
+	cfg.StoreBackend = "memory"
+	cfg.AttachmentDir = t.TempDir()
+	cfg.ProbeEnabled = false
+	cfg.GRPCAddr = ""
+	hub := server.NewHub(cfg)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		hub.Shutdown(ctx)
+		hub.Close()
+	})
+	return chatgrpc.NewServer(hub.Lobbies, hub.Sessions, hub.APIKeys, hub.Policy), hub
+}
+
+func TestCreateLobbyNeedsCredential(t *testing.T) {
+	s, hub := newTestServer(t)
+	req := &chatgrpc.CreateLobbyRequest{TenantID: "acme"}
+	open := len(hub.Lobbies.GetLobbies())
+
+	if _, err := s.CreateLobby(context.Background(), req); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("CreateLobby without metadata = %v, want %v", err, codes.Unauthenticated)
+	}
+	bogus := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer not-a-session"))
+	if _, err := s.CreateLobby(bogus, req); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("CreateLobby with an unknown session = %v, want %v", err, codes.Unauthenticated)
+	}
+	if len(hub.Lobbies.GetLobbies()) != open {
+		t.Error("a refused CreateLobby opened a lobby")
+	}
+
+	session, err := hub.Sessions.CreateSession("alice@acme.test", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+session.Token))
+	lobby, err := s.CreateLobby(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateLobby with a session = %v", err)
+	}
+	if lobby.ID == "" {
+		t.Errorf("CreateLobby returned %+v", lobby)
+	}
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+type AccountHandler struct {
+	controller     *controllers.APIController
+	accountService *services.AccountService
+	sessionService *services.SessionService
+}
+
+func NewAccountHandler(controller *controllers.APIController, accountService *services.AccountService, sessionService *services.SessionService) *AccountHandler {
+	return &AccountHandler{
+		controller:     controller,
+		accountService: accountService,
+		sessionService: sessionService,
+	}
+}
+
+type RegisterRequest struct {
+	Email    string `json:"email" openapi:"required"`
+	Password string `json:"password" openapi:"required"`
+}
+
+type AccountResponse struct {
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EmailRequest asks for a login or password reset link mailed to Email.
+type EmailRequest struct {
+	Email string `json:"email" openapi:"required"`
+}
+
+type PasswordResetRequest struct {
+	Token    string `json:"token" openapi:"required"`
+	Password string `json:"password" openapi:"required"`
+}
+
+type LinkSentResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// SessionInfo is a session as its user sees it in the list of their
+// sessions, by ID rather than token.
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	Provider  string    `json:"provider"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Current is the session the list was asked with
+	Current bool `json:"current"`
+}
+
+type SessionsResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+// Register handles POST /api/register and creates an account, which then
+// logs in with /api/login.
+func (ah *AccountHandler) Register(w http.ResponseWriter, r *http.Request) {
+	if ah.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "POST" {
+		ah.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ah.controller.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	account, err := ah.accountService.Register(req.Email, req.Password)
+	switch {
+	case errors.Is(err, services.ErrInvalidEmail), errors.Is(err, services.ErrInvalidPassword):
+		ah.controller.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, services.ErrAccountExists):
+		ah.controller.RespondError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		log.Printf("❌ Failed to register %s: %v", req.Email, err)
+		ah.controller.RespondError(w, http.StatusInternalServerError, "Failed to create account")
+		return
+	}
+
+	ah.controller.RespondJSON(w, http.StatusCreated, AccountResponse{Email: account.Email, CreatedAt: account.CreatedAt})
+}
+
+// MagicLink handles POST /api/login/magic-link and mails the account a
+// link that logs it in. It answers the same whether or not the account
+// exists.
+func (ah *AccountHandler) MagicLink(w http.ResponseWriter, r *http.Request) {
+	ah.sendLink(w, r, ah.accountService.SendMagicLink, "If an account exists for this email, a login link is on its way.")
+}
+
+// PasswordReset handles POST /api/password-reset and mails the account a
+// link to choose a new password, answering the same whether or not it
+// exists.
+func (ah *AccountHandler) PasswordReset(w http.ResponseWriter, r *http.Request) {
+	ah.sendLink(w, r, ah.accountService.SendPasswordReset, "If an account exists for this email, a password reset link is on its way.")
+}
+
+func (ah *AccountHandler) sendLink(w http.ResponseWriter, r *http.Request, send func(email string) error, message string) {
+	if ah.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "POST" {
+		ah.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		ah.controller.RespondError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	if err := send(req.Email); err != nil {
+		log.Printf("❌ Failed to send a link to %s: %v", req.Email, err)
+		ah.controller.RespondError(w, http.StatusInternalServerError, "Failed to send the link")
+		return
+	}
+	ah.controller.RespondJSON(w, http.StatusAccepted, LinkSentResponse{Success: true, Message: message})
+}
+
+// ConfirmPasswordReset handles POST /api/password-reset/confirm with the
+// token of a reset link and the new password. Every session of the account
+// is logged out.
+func (ah *AccountHandler) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if ah.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "POST" {
+		ah.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ah.controller.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	account, err := ah.accountService.ResetPassword(req.Token, req.Password)
+	switch {
+	case errors.Is(err, services.ErrInvalidPassword):
+		ah.controller.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, services.ErrInvalidLink):
+		ah.controller.RespondCode(w, models.ErrorUnauthorized, "This password reset link is invalid or has expired.")
+		return
+	case err != nil:
+		log.Printf("❌ Failed to reset a password: %v", err)
+		ah.controller.RespondError(w, http.StatusInternalServerError, "Failed to reset the password")
+		return
+	}
+
+	if err := ah.sessionService.RevokeSessions(account.Email); err != nil {
+		log.Printf("⚠️ Failed to log out the sessions of %s: %v", account.Email, err)
+	}
+	ah.controller.RespondJSON(w, http.StatusOK, LinkSentResponse{Success: true, Message: "Your password was changed. Please log in again."})
+}
+
+// Sessions handles GET /api/sessions, the caller's live sessions.
+func (ah *AccountHandler) Sessions(w http.ResponseWriter, r *http.Request) {
+	if ah.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		ah.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	current, ok := ah.currentSession(w, r)
+	if !ok {
+		return
+	}
+	sessions, err := ah.sessionService.ListSessions(current.Email)
+	if err != nil {
+		log.Printf("❌ Failed to list the sessions of %s: %v", current.Email, err)
+		ah.controller.RespondError(w, http.StatusInternalServerError, "Failed to list sessions")
+		return
+	}
+
+	response := SessionsResponse{Sessions: make([]SessionInfo, 0, len(sessions))}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, SessionInfo{
+			ID:        services.SessionID(session.Token),
+			Provider:  session.Provider,
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+			Current:   session.Token == current.Token,
+		})
+	}
+	ah.controller.RespondJSON(w, http.StatusOK, response)
+}
+
+// RevokeSession handles DELETE /api/sessions/{id}, which logs out one of
+// the caller's sessions.
+func (ah *AccountHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if ah.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "DELETE" {
+		ah.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	current, ok := ah.currentSession(w, r)
+	if !ok {
+		return
+	}
+	if err := ah.sessionService.RevokeSession(current.Email, r.PathValue("id")); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			ah.controller.RespondError(w, http.StatusNotFound, "Session not found")
+			return
+		}
+		log.Printf("❌ Failed to revoke a session of %s: %v", current.Email, err)
+		ah.controller.RespondError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Logout handles POST /api/logout: the caller's session ends and its
+// cookie is cleared.
+func (ah *AccountHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if ah.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "POST" {
+		ah.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	current, ok := ah.currentSession(w, r)
+	if !ok {
+		return
+	}
+	err := ah.sessionService.RevokeSession(current.Email, services.SessionID(current.Token))
+	if errors.Is(err, services.ErrSessionNotFound) {
+		// A session older than its user's session index
+		err = ah.sessionService.DeleteSession(current.Token)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to log out %s: %v", current.Email, err)
+		ah.controller.RespondError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: config.SessionCookieName, Path: ah.controller.PathPrefix + "/", MaxAge: -1})
+	log.Printf("👋 %s logged out", current.Email)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// currentSession returns the session of the caller's cookie, answering the
+// request if there is none.
+func (ah *AccountHandler) currentSession(w http.ResponseWriter, r *http.Request) (*models.Session, bool) {
+	cookie, err := r.Cookie(config.SessionCookieName)
+	if err != nil {
+		ah.controller.RespondError(w, http.StatusUnauthorized, "Login required")
+		return nil, false
+	}
+	session, err := ah.sessionService.GetSession(cookie.Value)
+	if err != nil {
+		ah.controller.RespondError(w, http.StatusUnauthorized, "Invalid or expired session")
+		return nil, false
+	}
+	return session, true
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"chat-integrated/controllers"
+	"chat-integrated/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type AnnounceHandler struct {
+	controller   *controllers.APIController
+	lobbyService *services.LobbyService
+}
+
+func NewAnnounceHandler(controller *controllers.APIController, lobbyService *services.LobbyService) *AnnounceHandler {
+	return &AnnounceHandler{
+		controller:   controller,
+		lobbyService: lobbyService,
+	}
+}
+
+type AnnounceRequest struct {
+	Content string `json:"content" openapi:"required"`
+}
+
+// Announce handles POST /api/admin/announce and sends a notice, such as
+// upcoming maintenance, to every connected client in every lobby.
+func (ah *AnnounceHandler) Announce(w http.ResponseWriter, r *http.Request) {
+	if ah.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if !ah.controller.Authorize(w, r, services.ActionAdminAnnounce) {
+		return
+	}
+
+	if r.Method != "POST" {
+		ah.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req AnnounceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ah.controller.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	lobbies, err := ah.lobbyService.Announce(r.Context(), req.Content)
+	if errors.Is(err, services.ErrInvalidAnnouncement) {
+		ah.controller.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		ah.controller.RespondError(w, http.StatusServiceUnavailable, "Announcement interrupted")
+		return
+	}
+
+	ah.controller.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"lobbies": lobbies,
+	})
+}
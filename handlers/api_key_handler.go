@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type APIKeyHandler struct {
+	controller    *controllers.APIController
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler(controller *controllers.APIController, apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		controller:    controller,
+		apiKeyService: apiKeyService,
+	}
+}
+
+type CreateAPIKeyRequest struct {
+	Name string      `json:"name" openapi:"required"`
+	Role models.Role `json:"role" openapi:"required"`
+}
+
+// AdminKeys handles GET (list) and POST (create) /api/admin/api-keys. The
+// key itself is only returned by POST.
+func (akh *APIKeyHandler) AdminKeys(w http.ResponseWriter, r *http.Request) {
+	if akh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if !akh.controller.Authorize(w, r, services.ActionAdminAPIKeys) {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		akh.controller.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"api_keys": akh.apiKeyService.ListKeys(),
+		})
+
+	case "POST":
+		var req CreateAPIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			akh.controller.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		apiKey, key, err := akh.apiKeyService.CreateKey(req.Name, req.Role)
+		if errors.Is(err, services.ErrInvalidAPIKeyName) || errors.Is(err, services.ErrInvalidAPIKeyRole) {
+			akh.controller.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			akh.controller.RespondError(w, http.StatusInternalServerError, "Failed to create API key")
+			return
+		}
+		akh.controller.RespondJSON(w, http.StatusCreated, map[string]interface{}{
+			"api_key": apiKey,
+			"key":     key,
+		})
+
+	default:
+		akh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// DeleteKey handles DELETE /api/admin/api-keys/{id}, which revokes the key.
+func (akh *APIKeyHandler) DeleteKey(w http.ResponseWriter, r *http.Request) {
+	if akh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if !akh.controller.Authorize(w, r, services.ActionAdminAPIKeys) {
+		return
+	}
+
+	if r.Method != "DELETE" {
+		akh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	err := akh.apiKeyService.DeleteKey(r.PathValue("id"))
+	if errors.Is(err, services.ErrAPIKeyNotFound) {
+		akh.controller.RespondError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		akh.controller.RespondError(w, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
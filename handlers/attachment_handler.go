@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+)
+
+// inlineContentTypes are the uploaded types served for display in the
+// page: raster images, which can't run script. Anything else, SVG and HTML
+// included, downloads as application/octet-stream.
+var inlineContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/avif": true,
+	"image/bmp":  true,
+}
+
+type AttachmentHandler struct {
+	controller        *controllers.APIController
+	attachmentService *services.AttachmentService
+}
+
+func NewAttachmentHandler(controller *controllers.APIController, attachmentService *services.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{
+		controller:        controller,
+		attachmentService: attachmentService,
+	}
+}
+
+// InfectedResponse refuses an upload the malware scanner flagged, naming
+// the Signature it matched.
+type InfectedResponse struct {
+	models.ErrorResponse
+	Signature string `json:"signature"`
+}
+
+// Upload accepts a multipart "file" field and returns the stored attachment.
+// Identical uploads return the same URL.
+func (ah *AttachmentHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	if ah.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "POST" {
+		ah.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !ah.controller.Authorize(w, r, services.ActionAttachmentUpload) {
+		return
+	}
+	owner, err := ah.controller.RequestActor(r)
+	if err != nil {
+		ah.controller.RespondError(w, http.StatusUnauthorized, "Login required")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, config.MaxAttachmentSize+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		ah.controller.RespondError(w, http.StatusBadRequest, "A file field is required")
+		return
+	}
+	defer file.Close()
+
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	attachment, err := ah.attachmentService.Store(file, owner, header.Filename, contentType)
+	if errors.Is(err, services.ErrAttachmentTooLarge) {
+		ah.controller.RespondError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	var infected *services.InfectedError
+	if errors.As(err, &infected) {
+		ah.controller.RespondJSON(w, http.StatusUnprocessableEntity, InfectedResponse{
+			ErrorResponse: models.ErrorResponse{Error: "Attachment rejected by malware scan", Code: models.ErrorInfected},
+			Signature:     infected.Signature,
+		})
+		return
+	}
+	if errors.Is(err, services.ErrScanFailed) {
+		ah.controller.RespondCode(w, models.ErrorUnavailable, "Attachment could not be scanned. Please try again later.")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to store attachment %s: %v", header.Filename, err)
+		ah.controller.RespondError(w, http.StatusInternalServerError, "Failed to store attachment")
+		return
+	}
+
+	ah.controller.RespondJSON(w, http.StatusOK, attachment)
+}
+
+// Delete releases one of the caller's references to
+// /api/attachments/{hash}. Content the caller didn't upload is not found.
+func (ah *AttachmentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if ah.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "DELETE" {
+		ah.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !ah.controller.Authorize(w, r, services.ActionAttachmentDelete) {
+		return
+	}
+	owner, err := ah.controller.RequestActor(r)
+	if err != nil {
+		ah.controller.RespondError(w, http.StatusUnauthorized, "Login required")
+		return
+	}
+
+	if err := ah.attachmentService.Release(r.PathValue("hash"), owner); err != nil {
+		if errors.Is(err, services.ErrAttachmentNotFound) {
+			ah.controller.RespondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		ah.controller.RespondError(w, http.StatusInternalServerError, "Failed to delete attachment")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Serve streams the content stored at /attachments/{hash}.
+func (ah *AttachmentHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	attachment, err := ah.attachmentService.Get(hash)
+	if err != nil {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+
+	file, err := ah.attachmentService.Open(hash)
+	if err != nil {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	// The type is the uploader's word, and the content is served from the
+	// app's origin
+	contentType, _, _ := mime.ParseMediaType(attachment.ContentType)
+	if inlineContentTypes[contentType] {
+		w.Header().Set("Content-Type", contentType)
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})
+		if disposition == "" {
+			disposition = "attachment"
+		}
+		w.Header().Set("Content-Disposition", disposition)
+	}
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Content-addressed, so the bytes behind a URL never change
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+hash+`"`)
+	io.Copy(w, file)
+}
+
+// ServeThumbnail streams /attachments/{hash}/thumbnail.
+func (ah *AttachmentHandler) ServeThumbnail(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	file, err := ah.attachmentService.OpenThumbnail(hash)
+	if err != nil {
+		http.Error(w, "Thumbnail not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+hash+`-thumb"`)
+	io.Copy(w, file)
+}
@@ -0,0 +1,73 @@
+package handlers_test
+
+import (
+	"bytes"
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"testing"
+)
+
+// upload posts content as m's attachment.
+func (ts *testServer) upload(t *testing.T, m member, content string) models.Attachment {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	form.Close()
+
+	req, err := http.NewRequest("POST", ts.URL+"/api/attachments", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.AddCookie(&http.Cookie{Name: config.SessionCookieName, Value: m.session})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("upload by %s got %d", m.Email, resp.StatusCode)
+	}
+	var attachment models.Attachment
+	if err := json.NewDecoder(resp.Body).Decode(&attachment); err != nil {
+		t.Fatal(err)
+	}
+	return attachment
+}
+
+func TestAttachmentDeleteReleasesOnlyOwnReference(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.seat(t, "acme", "alice")
+	bob := ts.seat(t, "acme", "bob")
+	mallory := ts.seat(t, "evil", "mallory")
+
+	attachment := ts.upload(t, alice, "shared notes")
+	if shared := ts.upload(t, bob, "shared notes"); shared.Hash != attachment.Hash {
+		t.Fatalf("identical uploads got hashes %s and %s", attachment.Hash, shared.Hash)
+	}
+	if attachment.Owners != nil {
+		t.Errorf("upload answer names its owners: %v", attachment.Owners)
+	}
+
+	path := "/api/attachments/" + attachment.Hash
+	if status, body := ts.call(t, mallory, "DELETE", path, nil); status != http.StatusNotFound {
+		t.Errorf("delete by a non-uploader got %d, want 404: %s", status, body)
+	}
+	if status, body := ts.call(t, bob, "DELETE", path, nil); status != http.StatusNoContent {
+		t.Errorf("delete by bob got %d, want 204: %s", status, body)
+	}
+	if status, body := ts.call(t, bob, "DELETE", path, nil); status != http.StatusNotFound {
+		t.Errorf("second delete by bob got %d, want 404: %s", status, body)
+	}
+	if status, _ := ts.get(t, alice, attachment.URL); status != http.StatusOK {
+		t.Errorf("alice's attachment got %d after the others deleted, want 200", status)
+	}
+}
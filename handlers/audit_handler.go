@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const defaultAuditPage = 100
+
+type AuditHandler struct {
+	controller   *controllers.APIController
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(controller *controllers.APIController, auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		controller:   controller,
+		auditService: auditService,
+	}
+}
+
+// AuditResponse is a page of the audit log, newest first.
+type AuditResponse struct {
+	Events []models.AuditEvent `json:"events"`
+}
+
+// Events handles GET /api/admin/audit?lobby_id=&actor=&from=&to=&limit= and
+// returns the newest audit events that match.
+func (ah *AuditHandler) Events(w http.ResponseWriter, r *http.Request) {
+	if ah.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		ah.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !ah.controller.Authorize(w, r, services.ActionAdminAudit) {
+		return
+	}
+
+	params := r.URL.Query()
+	filter := services.AuditFilter{
+		LobbyID: params.Get("lobby_id"),
+		Actor:   params.Get("actor"),
+		Limit:   defaultAuditPage,
+	}
+
+	var err error
+	if filter.Since, err = parseTimeParam(params.Get("from")); err != nil {
+		ah.controller.RespondError(w, http.StatusBadRequest, "from must be an RFC 3339 timestamp")
+		return
+	}
+	if filter.Until, err = parseTimeParam(params.Get("to")); err != nil {
+		ah.controller.RespondError(w, http.StatusBadRequest, "to must be an RFC 3339 timestamp")
+		return
+	}
+	if raw := params.Get("limit"); raw != "" {
+		filter.Limit, err = strconv.Atoi(raw)
+		if err != nil || filter.Limit < 1 || filter.Limit > config.MaxAuditPage {
+			ah.controller.RespondError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", config.MaxAuditPage))
+			return
+		}
+	}
+
+	events, err := ah.auditService.Events(filter)
+	if err != nil {
+		log.Printf("❌ Failed to read the audit log: %v", err)
+		ah.controller.RespondError(w, http.StatusInternalServerError, "Failed to read the audit log")
+		return
+	}
+	ah.controller.RespondJSON(w, http.StatusOK, AuditResponse{Events: events})
+}
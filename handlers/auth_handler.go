@@ -3,33 +3,83 @@ package handlers
 import (
 	"chat-integrated/config"
 	"chat-integrated/controllers"
+	"chat-integrated/models"
 	"chat-integrated/services"
+	"chat-integrated/telemetry"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type AuthHandler struct {
-	controller   *controllers.APIController
-	lobbyService *services.LobbyService
+	controller         *controllers.APIController
+	lobbyService       *services.LobbyService
+	oauthService       *services.OAuthService
+	sessionService     *services.SessionService
+	inviteService      *services.InviteService
+	accountService     *services.AccountService
+	matchmakingService *services.MatchmakingService
 }
 
-func NewAuthHandler(controller *controllers.APIController, lobbyService *services.LobbyService) *AuthHandler {
+func NewAuthHandler(controller *controllers.APIController, lobbyService *services.LobbyService, oauthService *services.OAuthService, sessionService *services.SessionService, inviteService *services.InviteService, accountService *services.AccountService, matchmakingService *services.MatchmakingService) *AuthHandler {
 	return &AuthHandler{
-		controller:   controller,
-		lobbyService: lobbyService,
+		controller:         controller,
+		lobbyService:       lobbyService,
+		oauthService:       oauthService,
+		sessionService:     sessionService,
+		inviteService:      inviteService,
+		accountService:     accountService,
+		matchmakingService: matchmakingService,
 	}
 }
 
+// LoginRequest logs in an account with its password or a login link's
+// token (magic), which stands in for the email. A session cookie from an
+// earlier login needs neither, and with OPEN_LOGIN the email alone will do.
 type LoginRequest struct {
-	Email string `json:"email"`
+	Email    string `json:"email,omitempty"`
+	Password string `json:"password,omitempty"`
+	Magic    string `json:"magic,omitempty"`
+	// Invite is an invite link's token, which seats the user in its lobby
+	// even while another session is in progress or the lobby is full
+	Invite string `json:"invite,omitempty"`
+	// Tags are the user's interests, most wanted first, which matchmaking
+	// seats them by instead of in the tenant's lobby
+	Tags []string `json:"tags,omitempty"`
 }
 
 type LoginResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
-	LobbyID string `json:"lobby_id,omitempty"`
-	Email   string `json:"email,omitempty"`
+	// Code says why a login didn't take a seat
+	Code    models.ErrorCode `json:"code,omitempty"`
+	LobbyID string           `json:"lobby_id,omitempty"`
+	Email   string           `json:"email,omitempty"`
+	// ReconnectToken is issued with a new seat; logging in again while
+	// seated does not issue another. With the email it gets the client
+	// connect tickets from /api/lobbies/{id}/connect-ticket
+	ReconnectToken string `json:"reconnect_token,omitempty"`
+	// ConnectTicket opens /ws to the lobby once, within ConnectTicketTTL.
+	// It comes with a reconnect token
+	ConnectTicket string `json:"connect_ticket,omitempty"`
+	// A user who found the lobby full waits in line with Ticket
+	Queued   bool   `json:"queued,omitempty"`
+	Ticket   string `json:"ticket,omitempty"`
+	Position int    `json:"position,omitempty"`
+	// A user with tags who wasn't matched at once looks for a match with
+	// Ticket
+	Matching bool `json:"matching,omitempty"`
+	// StartsAt is when the scheduled session an invitee logged in to early
+	// opens
+	StartsAt time.Time `json:"starts_at,omitzero"`
 }
 
 func (ah *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -48,68 +98,401 @@ func (ah *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Email == "" {
+	_, span := telemetry.Tracer.Start(r.Context(), "auth.login")
+	defer span.End()
+
+	signedIn := ah.sessionEmail(r)
+	if req.Email == "" && req.Magic == "" && signedIn == "" {
 		ah.controller.RespondError(w, http.StatusBadRequest, "Email is required")
 		return
 	}
+	tags, err := services.NormalizeTags(req.Tags)
+	if err != nil {
+		ah.controller.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	email, provider, err := ah.authenticate(req, signedIn)
+	if err != nil {
+		log.Printf("🔒 Login refused for %q: %v", req.Email, err)
+		span.SetAttributes(attribute.Int("login.status", http.StatusUnauthorized))
+		ah.controller.RespondJSON(w, http.StatusUnauthorized, LoginResponse{
+			Success: false,
+			Message: err.Error(),
+			Code:    models.ErrorUnauthorized,
+		})
+		return
+	}
+	if provider != "" && !ah.startSession(w, email, provider) {
+		return
+	}
+	req.Email = email
+	verified := provider != "" || email == signedIn
 
 	log.Printf("📧 Login request from: %s", req.Email)
 
-	// FIRST: Check if user already exists in any lobby (for reconnection)
-	existingLobby := ah.lobbyService.FindLobbyByUserEmail(req.Email)
+	if req.Invite != "" {
+		statusCode, response := ah.joinInvited(req.Email, req.Invite, verified)
+		traceLogin(span, provider, statusCode, response)
+		ah.controller.RespondJSON(w, statusCode, response)
+		return
+	}
 
-	if existingLobby != nil {
-		// User is reconnecting to their existing lobby
-		user := existingLobby.AddUser(req.Email) // This will reactivate the user
-		log.Printf("🔄 User reconnecting to existing lobby: %s → %s", req.Email, existingLobby.ID)
+	statusCode, response := ah.joinLobby(req.Email, ah.controller.TenantID(r), tags, verified)
+	traceLogin(span, provider, statusCode, response)
+	ah.controller.RespondJSON(w, statusCode, response)
+}
 
-		response := LoginResponse{
-			Success: true,
-			Message: "Reconnecting to your lobby. You'll see all previous messages.",
-			LobbyID: existingLobby.ID,
-			Email:   user.Email,
+// traceLogin notes how a login went on its span; emails stay out of traces.
+func traceLogin(span trace.Span, provider string, statusCode int, response LoginResponse) {
+	span.SetAttributes(
+		attribute.Int("login.status", statusCode),
+		attribute.String("login.provider", provider),
+		attribute.Bool("login.queued", response.Queued),
+		attribute.String("lobby.id", response.LobbyID),
+	)
+	if statusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, response.Message)
+	}
+}
+
+// authenticate resolves whom a login is for: the account of a login link,
+// an account whose password matches, signedIn, the user already logged in
+// on the session, or, with OPEN_LOGIN, anyone. provider names how a fresh
+// login authenticated and is empty when it needs no new session.
+func (ah *AuthHandler) authenticate(req LoginRequest, signedIn string) (email, provider string, err error) {
+	switch {
+	case req.Magic != "":
+		account, err := ah.accountService.RedeemMagicLink(req.Magic)
+		if err != nil {
+			return "", "", services.ErrInvalidLink
 		}
-		ah.controller.RespondJSON(w, http.StatusOK, response)
-		return
+		return account.Email, "magic_link", nil
+	case req.Password != "":
+		account, err := ah.accountService.Authenticate(req.Email, req.Password)
+		if err != nil && !errors.Is(err, services.ErrInvalidCredentials) {
+			log.Printf("❌ Failed to authenticate %s: %v", req.Email, err)
+		}
+		if err != nil {
+			return "", "", services.ErrInvalidCredentials
+		}
+		return account.Email, "password", nil
+	}
+
+	if signedIn != "" && (req.Email == "" || strings.EqualFold(req.Email, signedIn)) {
+		return signedIn, "", nil
+	}
+	if config.OpenLogin && req.Email != "" {
+		return req.Email, "", nil
 	}
+	return "", "", services.ErrPasswordRequired
+}
 
-	// User is joining for the first time - get or create a lobby
-	lobby := ah.lobbyService.GetOrCreateLobby()
+// sessionEmail is the user of the request's session cookie, "" without a
+// live one; guests have no account to log in to.
+func (ah *AuthHandler) sessionEmail(r *http.Request) string {
+	cookie, err := r.Cookie(config.SessionCookieName)
+	if err != nil {
+		return ""
+	}
+	session, err := ah.sessionService.GetSession(cookie.Value)
+	if err != nil || session.Guest {
+		return ""
+	}
+	return session.Email
+}
 
-	// If lobby is nil, it means there's an active session and we can't create new lobby
-	if lobby == nil {
-		log.Printf("❌ No available lobby for: %s (Active session in progress)", req.Email)
-		response := LoginResponse{
+// startSession issues the session cookie of a fresh login, answering the
+// request if it can't.
+func (ah *AuthHandler) startSession(w http.ResponseWriter, email, provider string) bool {
+	session, err := ah.sessionService.CreateSession(email, provider)
+	if err != nil {
+		ah.controller.RespondError(w, http.StatusInternalServerError, "Failed to create session")
+		return false
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.SessionCookieName,
+		Value:    session.Token,
+		Path:     ah.controller.PathPrefix + "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return true
+}
+
+// joinLobby places an email in its existing lobby (reconnection), in a
+// lobby of one of its tags, or in the lobby new users of the tenant are
+// currently assigned to. If there is no seat, the user waits in line for
+// one. verified says the login proved it is email's, rather than just
+// naming it under OPEN_LOGIN.
+func (ah *AuthHandler) joinLobby(email, tenantID string, tags []string, verified bool) (int, LoginResponse) {
+	if len(tags) > 0 && ah.lobbyService.FindLobbyByUserEmail(email) == nil {
+		return ah.matchmake(email, tenantID, tags)
+	}
+
+	lobby, reconnecting, err := ah.lobbyService.JoinLobby(email, tenantID)
+	if errors.Is(err, services.ErrSessionInProgress) || errors.Is(err, services.ErrLobbyFull) {
+		return ah.enqueue(email, tenantID)
+	}
+
+	var notStarted *services.NotStartedError
+	switch {
+	case errors.As(err, &notStarted):
+		return tooEarly(email, notStarted)
+	case errors.Is(err, services.ErrKickedFromLobby):
+		return http.StatusForbidden, LoginResponse{
 			Success: false,
-			Message: "A chat session is currently in progress. Please wait for it to complete or try again later.",
+			Message: "You were removed from this lobby and cannot rejoin it.",
+			Code:    models.ErrorKicked,
 		}
-		ah.controller.RespondJSON(w, http.StatusServiceUnavailable, response)
-		return
+	case reconnecting:
+		return ah.reconnected(email, lobby, verified)
 	}
 
-	log.Printf("📦 Got lobby for new user: %s (Current users: %d/%d)", lobby.ID, lobby.GetUserCount(), config.MaxUsersPerLobby)
+	return ah.seated(email, lobby)
+}
 
-	// Check if lobby can accept new users
-	if !lobby.CanAcceptNewUsers() {
-		log.Printf("❌ Lobby full, rejecting: %s", req.Email)
-		response := LoginResponse{
+// joinInvited places an email in the lobby of an invite token, whatever
+// the tenant's current session.
+func (ah *AuthHandler) joinInvited(email, token string, verified bool) (int, LoginResponse) {
+	invite, err := ah.inviteService.Verify(token)
+	if err != nil {
+		return http.StatusForbidden, LoginResponse{
 			Success: false,
-			Message: "Lobby is full. Please wait for the current session to complete.",
+			Message: "This invite link is invalid or has expired.",
+			Code:    models.ErrorInvalidInvite,
 		}
-		ah.controller.RespondJSON(w, http.StatusServiceUnavailable, response)
-		return
 	}
 
-	// Add user to lobby
-	user := lobby.AddUser(req.Email)
-	log.Printf("✅ New user added to lobby: %s (Now: %d/%d users)", req.Email, lobby.GetUserCount(), config.MaxUsersPerLobby)
+	lobby, reconnecting, err := ah.lobbyService.JoinInvited(email, invite, func() error {
+		return ah.inviteService.Redeem(invite)
+	})
+	switch {
+	case errors.Is(err, services.ErrInviteUsed):
+		return http.StatusForbidden, LoginResponse{
+			Success: false,
+			Message: "This invite link has already been used.",
+			Code:    models.ErrorInvalidInvite,
+		}
+	case errors.Is(err, services.ErrLobbyNotFound):
+		return http.StatusNotFound, LoginResponse{
+			Success: false,
+			Message: "The lobby of this invite has ended.",
+			Code:    models.ErrorNotFound,
+		}
+	case errors.Is(err, services.ErrSessionInProgress):
+		return http.StatusConflict, LoginResponse{
+			Success: false,
+			Message: "You are already in another lobby.",
+			Code:    models.ErrorConflict,
+		}
+	case errors.Is(err, services.ErrKickedFromLobby):
+		return http.StatusForbidden, LoginResponse{
+			Success: false,
+			Message: "You were removed from this lobby and cannot rejoin it.",
+			Code:    models.ErrorKicked,
+		}
+	case err != nil:
+		log.Printf("❌ Failed to redeem invite %s for %s: %v", invite.ID, email, err)
+		return http.StatusInternalServerError, LoginResponse{
+			Success: false,
+			Message: "Failed to redeem the invite",
+			Code:    models.ErrorInternal,
+		}
+	case reconnecting:
+		return ah.reconnected(email, lobby, verified)
+	}
+
+	return ah.seated(email, lobby)
+}
 
+// reconnected answers the login of a user who already has a seat. A
+// verified login gets a reconnect token of its own, so a second browser or
+// device can take the seat over; its connection closes the other one as
+// logged in elsewhere. Otherwise the client connects with the token it got
+// when it took the seat, or anyone naming the email could.
+func (ah *AuthHandler) reconnected(email string, lobby *models.Lobby, verified bool) (int, LoginResponse) {
 	response := LoginResponse{
 		Success: true,
-		Message: "User registered successfully",
+		Message: "Reconnecting to your lobby. You'll see all previous messages.",
 		LobbyID: lobby.ID,
-		Email:   user.Email,
+		Email:   email,
 	}
+	if !verified {
+		return http.StatusOK, response
+	}
+	token, ticket, err := ah.credentials(email, lobby.ID)
+	if err != nil {
+		return http.StatusInternalServerError, LoginResponse{
+			Success: false,
+			Message: "Failed to create reconnect token",
+			Code:    models.ErrorInternal,
+		}
+	}
+	response.ReconnectToken, response.ConnectTicket = token, ticket
+	return http.StatusOK, response
+}
 
-	ah.controller.RespondJSON(w, http.StatusOK, response)
+// seated issues the reconnect token and connect ticket of a user who just
+// took a seat.
+func (ah *AuthHandler) seated(email string, lobby *models.Lobby) (int, LoginResponse) {
+	token, ticket, err := ah.credentials(email, lobby.ID)
+	if err != nil {
+		return http.StatusInternalServerError, LoginResponse{
+			Success: false,
+			Message: "Failed to create reconnect token",
+			Code:    models.ErrorInternal,
+		}
+	}
+
+	return http.StatusOK, LoginResponse{
+		Success:        true,
+		Message:        "User registered successfully",
+		LobbyID:        lobby.ID,
+		Email:          email,
+		ReconnectToken: token,
+		ConnectTicket:  ticket,
+	}
+}
+
+// credentials issues a user seated in lobbyID a reconnect token and a
+// connect ticket for their first connection.
+func (ah *AuthHandler) credentials(email, lobbyID string) (token, ticket string, err error) {
+	if token, err = ah.sessionService.CreateReconnectToken(email); err != nil {
+		return "", "", err
+	}
+	if ticket, err = ah.sessionService.CreateConnectTicket(email, lobbyID); err != nil {
+		return "", "", err
+	}
+	return token, ticket, nil
+}
+
+// ConnectTicketResponse carries a fresh connect ticket for /ws.
+type ConnectTicketResponse struct {
+	ConnectTicket string    `json:"connect_ticket"`
+	LobbyID       string    `json:"lobby_id"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// ConnectTicket handles POST /api/lobbies/{id}/connect-ticket: a member
+// with a session, or the email and reconnect token of an email login, gets
+// a ticket to open /ws to the lobby with, as when reconnecting.
+func (ah *AuthHandler) ConnectTicket(w http.ResponseWriter, r *http.Request) {
+	if ah.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "POST" {
+		ah.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	email, err := ah.controller.RequestUser(r)
+	if err != nil {
+		ah.controller.RespondError(w, http.StatusUnauthorized, "Login required")
+		return
+	}
+
+	lobby := ah.lobbyService.GetLobby(r.PathValue("id"))
+	if lobby == nil {
+		ah.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+	if lobby.IsBanned(email) {
+		ah.controller.RespondCode(w, models.ErrorKicked, services.ErrKickedFromLobby.Error())
+		return
+	}
+	if !lobby.IsUserInLobby(email) {
+		ah.controller.RespondError(w, http.StatusForbidden, "Not a member of this lobby")
+		return
+	}
+
+	ticket, err := ah.sessionService.CreateConnectTicket(email, lobby.ID)
+	if err != nil {
+		ah.controller.RespondError(w, http.StatusInternalServerError, "Failed to create connect ticket")
+		return
+	}
+	ah.controller.RespondJSON(w, http.StatusOK, ConnectTicketResponse{
+		ConnectTicket: ticket,
+		LobbyID:       lobby.ID,
+		ExpiresAt:     time.Now().Add(config.ConnectTicketTTL),
+	})
+}
+
+// matchmake seats a user in a lobby of their tags, or has them look for a
+// match with a ticket.
+func (ah *AuthHandler) matchmake(email, tenantID string, tags []string) (int, LoginResponse) {
+	status, err := ah.matchmakingService.Join(email, tenantID, tags)
+	var notStarted *services.NotStartedError
+	switch {
+	case errors.As(err, &notStarted):
+		return tooEarly(email, notStarted)
+	case errors.Is(err, services.ErrQueueFull):
+		return http.StatusServiceUnavailable, LoginResponse{
+			Success: false,
+			Message: "Too many users are looking for a match. Please try again later.",
+			Code:    models.ErrorLobbyFull,
+		}
+	case err != nil:
+		log.Printf("❌ Failed to matchmake %s: %v", email, err)
+		return http.StatusInternalServerError, LoginResponse{
+			Success: false,
+			Message: "Failed to look for a match",
+			Code:    models.ErrorInternal,
+		}
+	}
+	if lobby := ah.lobbyService.GetLobby(status.LobbyID); status.Admitted && lobby != nil {
+		return ah.seated(email, lobby)
+	}
+
+	return http.StatusAccepted, LoginResponse{
+		Success:  false,
+		Message:  fmt.Sprintf("Looking for others interested in %s...", strings.Join(tags, ", ")),
+		Email:    email,
+		Matching: true,
+		Ticket:   status.Ticket,
+	}
+}
+
+// tooEarly answers the login of an invitee before their scheduled session
+// opens.
+func tooEarly(email string, notStarted *services.NotStartedError) (int, LoginResponse) {
+	return http.StatusTooEarly, LoginResponse{
+		Success:  false,
+		Message:  fmt.Sprintf("Your session starts at %s.", notStarted.Lobby.StartsAt.Format(time.RFC3339)),
+		Code:     models.ErrorNotStarted,
+		LobbyID:  notStarted.Lobby.ID,
+		Email:    email,
+		StartsAt: notStarted.Lobby.StartsAt,
+	}
+}
+
+func (ah *AuthHandler) enqueue(email, tenantID string) (int, LoginResponse) {
+	status, err := ah.lobbyService.Enqueue(email, tenantID)
+	if errors.Is(err, services.ErrQueueFull) {
+		return http.StatusServiceUnavailable, LoginResponse{
+			Success: false,
+			Message: "Lobby is full. Please wait for the current session to complete.",
+			Code:    models.ErrorLobbyFull,
+		}
+	}
+	if err != nil {
+		log.Printf("❌ Failed to queue %s: %v", email, err)
+		return http.StatusInternalServerError, LoginResponse{
+			Success: false,
+			Message: "Failed to join the queue",
+			Code:    models.ErrorInternal,
+		}
+	}
+
+	return http.StatusAccepted, LoginResponse{
+		Success:  false,
+		Message:  fmt.Sprintf("Lobby is full. You are number %d in line and will be let in when a seat frees up.", status.Position),
+		Code:     models.ErrorLobbyFull,
+		Email:    email,
+		Queued:   true,
+		Ticket:   status.Ticket,
+		Position: status.Position,
+	}
 }
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const maxBotMessageLength = 4000
+
+type BotHandler struct {
+	controller   *controllers.APIController
+	lobbyService *services.LobbyService
+	botService   *services.BotService
+}
+
+func NewBotHandler(controller *controllers.APIController, lobbyService *services.LobbyService, botService *services.BotService) *BotHandler {
+	return &BotHandler{
+		controller:   controller,
+		lobbyService: lobbyService,
+		botService:   botService,
+	}
+}
+
+type BotMessageRequest struct {
+	Content string               `json:"content" openapi:"required"`
+	Format  models.ContentFormat `json:"format,omitempty"`
+}
+
+type CreateBotRequest struct {
+	Name    string `json:"name" openapi:"required"`
+	LobbyID string `json:"lobby_id,omitempty"`
+}
+
+// BotMessage handles POST /api/lobbies/{id}/bot-message, authenticated with
+// "Authorization: Bearer <bot API key>".
+func (bh *BotHandler) BotMessage(w http.ResponseWriter, r *http.Request) {
+	if bh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "POST" {
+		bh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	bot, err := bh.botService.Authenticate(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if err != nil {
+		bh.controller.RespondError(w, http.StatusUnauthorized, "Invalid bot API key")
+		return
+	}
+
+	lobby := bh.lobbyService.GetLobby(r.PathValue("id"))
+	if lobby == nil || lobby.Internal {
+		bh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+	if !bh.controller.Policy.Allowed(models.RoleBot, services.ActionBotPost) || !bh.botService.CanPost(bot, lobby) {
+		bh.controller.RespondError(w, http.StatusForbidden, services.ErrBotForbidden.Error())
+		return
+	}
+
+	var req BotMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		bh.controller.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" || len(req.Content) > maxBotMessageLength {
+		bh.controller.RespondError(w, http.StatusBadRequest, "content must be 1-4000 characters")
+		return
+	}
+	if !req.Format.Valid() {
+		bh.controller.RespondError(w, http.StatusBadRequest, services.ErrInvalidFormat.Error())
+		return
+	}
+
+	log.Printf("🤖 Bot message from %s into lobby %s", bot.Name, lobby.ID)
+	err = bh.lobbyService.Broadcast(r.Context(), services.BroadcastMessage{
+		LobbyID: lobby.ID,
+		Message: models.Message{
+			Type:      models.MessageTypeChat,
+			Username:  bot.Name,
+			Content:   req.Content,
+			Format:    req.Format,
+			LobbyID:   lobby.ID,
+			IsBot:     true,
+			Timestamp: time.Now(),
+		},
+	})
+	if errors.Is(err, services.ErrLobbyNotFound) {
+		bh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	bh.controller.RespondJSON(w, http.StatusAccepted, map[string]bool{"success": true})
+}
+
+// AdminBots handles GET (list) and POST (create) /api/admin/bots for the
+// requesting tenant. The API key is only returned by POST.
+func (bh *BotHandler) AdminBots(w http.ResponseWriter, r *http.Request) {
+	if bh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if !bh.controller.Authorize(w, r, services.ActionAdminBots) {
+		return
+	}
+
+	tenantID := bh.controller.TenantID(r)
+
+	switch r.Method {
+	case "GET":
+		bh.controller.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"bots": bh.botService.ListBots(tenantID),
+		})
+
+	case "POST":
+		var req CreateBotRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			bh.controller.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		bot, key, err := bh.botService.CreateBot(req.Name, tenantID, req.LobbyID)
+		if errors.Is(err, services.ErrInvalidBotName) {
+			bh.controller.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			bh.controller.RespondError(w, http.StatusInternalServerError, "Failed to create bot")
+			return
+		}
+		bh.controller.RespondJSON(w, http.StatusCreated, map[string]interface{}{
+			"bot":     bot,
+			"api_key": key,
+		})
+
+	default:
+		bh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// DeleteBot handles DELETE /api/admin/bots/{id}.
+func (bh *BotHandler) DeleteBot(w http.ResponseWriter, r *http.Request) {
+	if bh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if !bh.controller.Authorize(w, r, services.ActionAdminBots) {
+		return
+	}
+
+	if r.Method != "DELETE" {
+		bh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	err := bh.botService.DeleteBot(r.PathValue("id"))
+	if errors.Is(err, services.ErrBotNotFound) {
+		bh.controller.RespondError(w, http.StatusNotFound, "Bot not found")
+		return
+	}
+	if err != nil {
+		bh.controller.RespondError(w, http.StatusInternalServerError, "Failed to delete bot")
+		return
+	}
+	bh.controller.RespondJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type BrandingHandler struct {
+	controller      *controllers.APIController
+	brandingService *services.BrandingService
+}
+
+func NewBrandingHandler(controller *controllers.APIController, brandingService *services.BrandingService) *BrandingHandler {
+	return &BrandingHandler{
+		controller:      controller,
+		brandingService: brandingService,
+	}
+}
+
+// GetBranding returns the branding of the requesting tenant for the UI.
+func (bh *BrandingHandler) GetBranding(w http.ResponseWriter, r *http.Request) {
+	if bh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	bh.controller.RespondJSON(w, http.StatusOK, bh.brandingService.GetBranding(bh.controller.TenantID(r)))
+}
+
+// AdminBranding handles GET and PUT /api/admin/tenants/{tenant}/branding.
+func (bh *BrandingHandler) AdminBranding(w http.ResponseWriter, r *http.Request) {
+	if bh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if !bh.controller.Authorize(w, r, services.ActionAdminBranding) {
+		return
+	}
+
+	tenantID := r.PathValue("tenant")
+	if !controllers.IsValidTenantID(tenantID) {
+		bh.controller.RespondError(w, http.StatusBadRequest, "Invalid tenant ID")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		bh.controller.RespondJSON(w, http.StatusOK, bh.brandingService.GetBranding(tenantID))
+
+	case "PUT":
+		var branding models.Branding
+		if err := json.NewDecoder(r.Body).Decode(&branding); err != nil {
+			bh.controller.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		branding.TenantID = tenantID
+
+		saved, err := bh.brandingService.SetBranding(branding)
+		if errors.Is(err, services.ErrInvalidColor) || errors.Is(err, services.ErrInvalidLogoURL) || errors.Is(err, services.ErrInvalidProduct) {
+			bh.controller.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			bh.controller.RespondError(w, http.StatusInternalServerError, "Failed to save branding")
+			return
+		}
+		bh.controller.RespondJSON(w, http.StatusOK, saved)
+
+	default:
+		bh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
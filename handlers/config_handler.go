@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/controllers"
+	"chat-integrated/services"
+	"net/http"
+)
+
+type ConfigHandler struct {
+	controller      *controllers.APIController
+	brandingService *services.BrandingService
+	oauthService    *services.OAuthService
+	hubSettings     []config.Setting
+}
+
+func NewConfigHandler(controller *controllers.APIController, brandingService *services.BrandingService, oauthService *services.OAuthService, hubSettings []config.Setting) *ConfigHandler {
+	return &ConfigHandler{
+		controller:      controller,
+		brandingService: brandingService,
+		oauthService:    oauthService,
+		hubSettings:     hubSettings,
+	}
+}
+
+// GetConfig returns the non-secret runtime configuration a client needs to
+// bootstrap, so the frontend doesn't hardcode URLs or feature assumptions.
+func (ch *ConfigHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	if ch.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	wsScheme := "ws"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		wsScheme = "wss"
+	}
+
+	response := map[string]interface{}{
+		"ws_url":            wsScheme + "://" + r.Host + ch.controller.PathPrefix + "/ws",
+		"max_users":         config.MaxUsersPerLobby,
+		"protocol_versions": config.ProtocolVersions,
+		"branding":          ch.brandingService.GetBranding(ch.controller.TenantID(r)),
+		"features": map[string]interface{}{
+			"oauth_providers":     ch.oauthService.EnabledProviders(),
+			"session_required":    config.RequireSession,
+			"open_login":          config.OpenLogin,
+			"attachments":         true,
+			"max_attachment_size": config.MaxAttachmentSize,
+			"mentions":            true,
+			"search":              true,
+			"export_formats":      []string{"json", "csv", "txt"},
+			"resume":              true,
+		},
+	}
+
+	ch.controller.RespondJSON(w, http.StatusOK, response)
+}
+
+// AdminConfig handles GET /api/admin/config: the effective settings of the
+// process and of this hub, each with the source it came from. Secrets are
+// redacted.
+func (ch *ConfigHandler) AdminConfig(w http.ResponseWriter, r *http.Request) {
+	if ch.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if !ch.controller.Authorize(w, r, services.ActionAdminConfig) {
+		return
+	}
+
+	if r.Method != "GET" {
+		ch.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	ch.controller.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"hub":      ch.hubSettings,
+		"settings": config.Settings(),
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/controllers"
+	"chat-integrated/lifecycle"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+type DashboardHandler struct {
+	controller   *controllers.APIController
+	wsController *controllers.WSController
+	lobbyService *services.LobbyService
+}
+
+func NewDashboardHandler(controller *controllers.APIController, wsController *controllers.WSController, lobbyService *services.LobbyService) *DashboardHandler {
+	return &DashboardHandler{
+		controller:   controller,
+		wsController: wsController,
+		lobbyService: lobbyService,
+	}
+}
+
+// Dashboard handles GET /api/admin/dashboard and returns a snapshot of
+// every lobby, the waiting lines and the store.
+func (dh *DashboardHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
+	if dh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		dh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !dh.controller.Authorize(w, r, services.ActionAdminDashboard) {
+		return
+	}
+
+	dh.controller.RespondJSON(w, http.StatusOK, dh.lobbyService.Dashboard(r.Context()))
+}
+
+// Stream handles the /ws/admin WebSocket, which pushes a dashboard snapshot
+// every config.DashboardInterval until the client goes away. Browsers can't
+// set headers on a WebSocket, so the admin key may come as admin_key.
+func (dh *DashboardHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(config.AdminKeyHeader) == "" {
+		if key := r.URL.Query().Get("admin_key"); key != "" {
+			r.Header.Set(config.AdminKeyHeader, key)
+		}
+	}
+	if !dh.controller.Authorize(w, r, services.ActionAdminDashboard) {
+		return
+	}
+
+	conn, err := dh.wsController.UpgradeConnection(w, r)
+	if err != nil {
+		log.Printf("❌ Dashboard WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+	log.Printf("📊 Dashboard stream opened from: %s", r.RemoteAddr)
+
+	// The client only ever sends its close frame
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(config.DashboardInterval)
+	defer ticker.Stop()
+	for {
+		if lifecycle.CurrentState() == lifecycle.StateDraining {
+			conn.SetWriteDeadline(time.Now().Add(config.WriteTimeout))
+			conn.WriteMessage(models.CloseMessage, models.FormatCloseMessage(models.CloseGoingAway, "The server is restarting"))
+			return
+		}
+
+		data, err := json.Marshal(dh.lobbyService.Dashboard(context.Background()))
+		if err != nil {
+			log.Printf("❌ Failed to encode dashboard: %v", err)
+			return
+		}
+		conn.SetWriteDeadline(time.Now().Add(config.WriteTimeout))
+		if err := conn.WriteMessage(models.TextMessage, data); err != nil {
+			log.Printf("❌ Dashboard write error: %v", err)
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-gone:
+			log.Printf("📊 Dashboard stream closed from: %s", r.RemoteAddr)
+			return
+		}
+	}
+}
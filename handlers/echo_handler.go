@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	echoMaxFrameSize = 64 * 1024
+	echoIdleTimeout  = 60 * time.Second
+)
+
+type EchoHandler struct {
+	controller *controllers.WSController
+}
+
+func NewEchoHandler(controller *controllers.WSController) *EchoHandler {
+	return &EchoHandler{
+		controller: controller,
+	}
+}
+
+type EchoResponse struct {
+	Type       string    `json:"type"`
+	Payload    string    `json:"payload"`
+	Size       int       `json:"size"`
+	ReceivedAt time.Time `json:"received_at"`
+	SentAt     time.Time `json:"sent_at"`
+}
+
+// HandleEcho upgrades to a lobby-less WebSocket that echoes every text frame
+// back with server receive/send timestamps, so clients can check their
+// network path and WebSocket support independently of lobby state.
+func (eh *EchoHandler) HandleEcho(w http.ResponseWriter, r *http.Request) {
+	conn, err := eh.controller.UpgradeConnection(w, r)
+	if err != nil {
+		log.Printf("❌ Echo WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	log.Printf("🔁 Echo connection opened from: %s", r.RemoteAddr)
+	conn.SetReadLimit(echoMaxFrameSize)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(echoIdleTimeout))
+		messageType, payload, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("Echo WebSocket error: %v", err)
+			}
+			break
+		}
+		receivedAt := time.Now()
+
+		// Binary frames are echoed untouched
+		if messageType == models.BinaryMessage {
+			err = conn.WriteMessage(models.BinaryMessage, payload)
+		} else {
+			var response []byte
+			response, err = json.Marshal(EchoResponse{
+				Type:       "echo",
+				Payload:    string(payload),
+				Size:       len(payload),
+				ReceivedAt: receivedAt,
+				SentAt:     time.Now(),
+			})
+			if err == nil {
+				err = conn.WriteMessage(models.TextMessage, response)
+			}
+		}
+		if err != nil {
+			log.Printf("❌ Echo write error: %v", err)
+			break
+		}
+	}
+
+	log.Printf("🔁 Echo connection closed from: %s", r.RemoteAddr)
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"errors"
+	"log"
+	"net/http"
+)
+
+type EmojiHandler struct {
+	controller   *controllers.APIController
+	lobbyService *services.LobbyService
+	emojiService *services.EmojiService
+}
+
+func NewEmojiHandler(controller *controllers.APIController, lobbyService *services.LobbyService, emojiService *services.EmojiService) *EmojiHandler {
+	return &EmojiHandler{
+		controller:   controller,
+		lobbyService: lobbyService,
+		emojiService: emojiService,
+	}
+}
+
+// LobbyEmoji handles GET /api/lobbies/{id}/emoji, which lists the emoji
+// usable in the lobby including its tenant's, and POST, which lets the
+// lobby's owner register one of its own from a multipart "name" and "file".
+func (eh *EmojiHandler) LobbyEmoji(w http.ResponseWriter, r *http.Request) {
+	if eh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	lobby := eh.lobbyService.GetLobby(r.PathValue("id"))
+
+	switch r.Method {
+	case "GET":
+		if !eh.controller.Authorize(w, r, services.ActionLobbyEmoji) {
+			return
+		}
+		if lobby == nil {
+			eh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+			return
+		}
+		eh.controller.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"lobby_id": lobby.ID,
+			"emoji":    eh.lobbyService.EmojiPack(lobby),
+		})
+
+	case "POST":
+		if lobby == nil {
+			eh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+			return
+		}
+		actor, ok := eh.controller.AuthorizeLobby(w, r, lobby, services.ActionManageEmoji)
+		if !ok {
+			return
+		}
+		eh.upload(w, r, models.EmojiScopeLobby, lobby.ID, actor)
+
+	default:
+		eh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// DeleteLobbyEmoji handles DELETE /api/lobbies/{id}/emoji/{name}.
+func (eh *EmojiHandler) DeleteLobbyEmoji(w http.ResponseWriter, r *http.Request) {
+	if eh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "DELETE" {
+		eh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	lobby := eh.lobbyService.GetLobby(r.PathValue("id"))
+	if lobby == nil {
+		eh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+	actor, ok := eh.controller.AuthorizeLobby(w, r, lobby, services.ActionManageEmoji)
+	if !ok {
+		return
+	}
+	eh.remove(w, r, models.EmojiScopeLobby, lobby.ID, actor)
+}
+
+// AdminEmoji handles GET and POST /api/admin/tenants/{tenant}/emoji, the
+// emoji every lobby of the tenant can use.
+func (eh *EmojiHandler) AdminEmoji(w http.ResponseWriter, r *http.Request) {
+	if eh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if !eh.controller.Authorize(w, r, services.ActionAdminEmoji) {
+		return
+	}
+
+	tenantID := r.PathValue("tenant")
+	if !controllers.IsValidTenantID(tenantID) {
+		eh.controller.RespondError(w, http.StatusBadRequest, "Invalid tenant ID")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		eh.controller.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"tenant_id": tenantID,
+			"emoji":     eh.emojiService.ScopePack(models.EmojiScopeTenant, tenantID),
+		})
+
+	case "POST":
+		eh.upload(w, r, models.EmojiScopeTenant, tenantID, string(models.RoleAdmin))
+
+	default:
+		eh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// DeleteAdminEmoji handles DELETE /api/admin/tenants/{tenant}/emoji/{name}.
+func (eh *EmojiHandler) DeleteAdminEmoji(w http.ResponseWriter, r *http.Request) {
+	if eh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "DELETE" {
+		eh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !eh.controller.Authorize(w, r, services.ActionAdminEmoji) {
+		return
+	}
+
+	tenantID := r.PathValue("tenant")
+	if !controllers.IsValidTenantID(tenantID) {
+		eh.controller.RespondError(w, http.StatusBadRequest, "Invalid tenant ID")
+		return
+	}
+	eh.remove(w, r, models.EmojiScopeTenant, tenantID, string(models.RoleAdmin))
+}
+
+func (eh *EmojiHandler) upload(w http.ResponseWriter, r *http.Request, scope models.EmojiScope, ownerID, actor string) {
+	r.Body = http.MaxBytesReader(w, r.Body, config.MaxEmojiSize+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		eh.controller.RespondError(w, http.StatusBadRequest, "A file field is required")
+		return
+	}
+	defer file.Close()
+
+	emoji, err := eh.lobbyService.AddEmoji(r.Context(), scope, ownerID, r.FormValue("name"), file, actor)
+	if errors.Is(err, services.ErrInvalidEmojiName) || errors.Is(err, services.ErrInvalidEmojiImage) {
+		eh.controller.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, services.ErrEmojiTooLarge) {
+		eh.controller.RespondError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if errors.Is(err, services.ErrEmojiPackFull) {
+		eh.controller.RespondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	var infected *services.InfectedError
+	if errors.As(err, &infected) {
+		eh.controller.RespondJSON(w, http.StatusUnprocessableEntity, InfectedResponse{
+			ErrorResponse: models.ErrorResponse{Error: "Attachment rejected by malware scan", Code: models.ErrorInfected},
+			Signature:     infected.Signature,
+		})
+		return
+	}
+	if errors.Is(err, services.ErrScanFailed) {
+		eh.controller.RespondCode(w, models.ErrorUnavailable, "Attachment could not be scanned. Please try again later.")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to add emoji to %s %s: %v", scope, ownerID, err)
+		eh.controller.RespondError(w, http.StatusInternalServerError, "Failed to add emoji")
+		return
+	}
+
+	eh.controller.RespondJSON(w, http.StatusOK, emoji)
+}
+
+func (eh *EmojiHandler) remove(w http.ResponseWriter, r *http.Request, scope models.EmojiScope, ownerID, actor string) {
+	err := eh.lobbyService.RemoveEmoji(r.Context(), scope, ownerID, r.PathValue("name"), actor)
+	if errors.Is(err, services.ErrEmojiNotFound) {
+		eh.controller.RespondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		eh.controller.RespondError(w, http.StatusInternalServerError, "Failed to remove emoji")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
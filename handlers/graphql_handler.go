@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// graphqlMaxDepth bounds how deeply a query may nest its selections.
+const graphqlMaxDepth = 8
+
+//go:embed graphql_schema.graphql
+var graphqlSchema string
+
+// GraphQLHandler serves the GraphQL API, over HTTP for queries and
+// mutations and over WebSocket for subscriptions.
+type GraphQLHandler struct {
+	controller     *controllers.APIController
+	lobbyService   *services.LobbyService
+	sessionService *services.SessionService
+	schema         *graphql.Schema
+	upgrader       websocket.Upgrader
+	compression    *controllers.Compression
+}
+
+func NewGraphQLHandler(controller *controllers.APIController, lobbyService *services.LobbyService, sessionService *services.SessionService, authHandler *AuthHandler, compression *controllers.Compression) *GraphQLHandler {
+	resolver := &graphqlResolver{
+		controller:   controller,
+		lobbyService: lobbyService,
+		authHandler:  authHandler,
+	}
+	return &GraphQLHandler{
+		controller:     controller,
+		lobbyService:   lobbyService,
+		sessionService: sessionService,
+		schema:         graphql.MustParseSchema(graphqlSchema, resolver, graphql.UseFieldResolvers(), graphql.MaxDepth(graphqlMaxDepth)),
+		compression:    compression,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			Subprotocols:    []string{graphqlWSProtocol},
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+		},
+	}
+}
+
+// GraphQLRequest is a GraphQL operation posted to /graphql.
+type GraphQLRequest struct {
+	Query         string                 `json:"query" openapi:"required"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// graphqlViewer is who an operation runs as: a member by email, the admin
+// key, or nobody.
+type graphqlViewer struct {
+	email    string
+	admin    bool
+	tenantID string
+}
+
+type graphqlViewerKey struct{}
+
+func withViewer(ctx context.Context, viewer graphqlViewer) context.Context {
+	return context.WithValue(ctx, graphqlViewerKey{}, viewer)
+}
+
+func viewerFrom(ctx context.Context) graphqlViewer {
+	viewer, _ := ctx.Value(graphqlViewerKey{}).(graphqlViewer)
+	return viewer
+}
+
+// ServeGraphQL handles POST /graphql, and WebSocket upgrades of GET
+// /graphql for subscriptions.
+func (gh *GraphQLHandler) ServeGraphQL(w http.ResponseWriter, r *http.Request) {
+	if gh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	upgrade := r.Method == "GET" && websocket.IsWebSocketUpgrade(r)
+	if r.Method != "POST" && !upgrade {
+		gh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	role, err := gh.controller.RequestRole(r)
+	if err != nil {
+		gh.controller.RespondError(w, http.StatusUnauthorized, "Invalid admin key")
+		return
+	}
+	viewer := graphqlViewer{admin: role == models.RoleAdmin, tenantID: gh.controller.TenantID(r)}
+	if email, err := gh.controller.RequestUser(r); err == nil {
+		viewer.email = email
+	}
+
+	if upgrade {
+		gh.serveWebSocket(w, r, viewer)
+		return
+	}
+
+	var req GraphQLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		gh.controller.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Query == "" {
+		gh.controller.RespondError(w, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	response := gh.schema.Exec(withViewer(r.Context(), viewer), req.Query, req.OperationName, req.Variables)
+	gh.controller.RespondJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+var (
+	errGraphQLNotMember       = errors.New("not a member of this lobby")
+	errGraphQLNeedsWebSocket  = errors.New("subscriptions are served over WebSocket")
+	errGraphQLNeedsMembership = errors.New("subscriptions need a member's email and token")
+)
+
+// graphqlResolver is the root of the GraphQL schema. It answers from
+// LobbyService the way the REST handlers and WebSocket frames do.
+type graphqlResolver struct {
+	controller   *controllers.APIController
+	lobbyService *services.LobbyService
+	authHandler  *AuthHandler
+}
+
+type gqlLobby struct {
+	ID        graphql.ID
+	TenantID  string
+	UserCount int32
+	MaxUsers  int32
+	LastSeq   int32
+	CreatedAt graphql.Time
+}
+
+type gqlUser struct {
+	ID          graphql.ID
+	DisplayName string
+	AvatarURL   string
+	Role        string
+	Presence    string
+}
+
+type gqlMessage struct {
+	MessageID   string
+	Seq         int32
+	Type        string
+	UserID      graphql.ID
+	DisplayName string
+	Content     string
+	Format      string
+	ParentSeq   int32
+	ClientMsgID string
+	Timestamp   graphql.Time
+}
+
+type gqlPresenceEvent struct {
+	Action      string
+	UserID      graphql.ID
+	DisplayName string
+	Presence    string
+	Timestamp   graphql.Time
+}
+
+type gqlLoginResult struct {
+	Success        bool
+	Message        string
+	LobbyID        *graphql.ID
+	Email          *string
+	ReconnectToken *string
+	ConnectTicket  *string
+	Queued         bool
+	Ticket         *string
+	Position       int32
+	StartsAt       *graphql.Time
+}
+
+// lobby returns a live lobby the viewer may use for action: any one for
+// the admin key, otherwise one they are a member of whose connection or
+// lobby role the policy allows action. An empty action only needs
+// membership.
+func (gr *graphqlResolver) lobby(viewer graphqlViewer, id graphql.ID, action services.Action) (*models.Lobby, error) {
+	lobby := gr.lobbyService.GetLobby(string(id))
+	if lobby == nil {
+		return nil, services.ErrLobbyNotFound
+	}
+	if viewer.admin {
+		return lobby, nil
+	}
+	if viewer.email == "" {
+		return nil, controllers.ErrNotLoggedIn
+	}
+	if !lobby.IsUserInLobby(viewer.email) {
+		return nil, errGraphQLNotMember
+	}
+	if action != "" && !gr.controller.Policy.Allowed(memberRole(lobby, viewer.email), action) && !gr.controller.Policy.Allowed(lobby.GetUserRole(viewer.email), action) {
+		return nil, errors.New("not allowed to " + string(action))
+	}
+	return lobby, nil
+}
+
+// memberRole is the connection role a member's /ws connection would have.
+func memberRole(lobby *models.Lobby, email string) models.Role {
+	if lobby.IsGuest(email) {
+		return models.RoleGuest
+	}
+	return models.RoleUser
+}
+
+func (gr *graphqlResolver) Lobbies(ctx context.Context) ([]*gqlLobby, error) {
+	viewer := viewerFrom(ctx)
+	if !viewer.admin && viewer.email == "" {
+		return nil, controllers.ErrNotLoggedIn
+	}
+
+	lobbies := []*gqlLobby{}
+	for _, lobby := range gr.lobbyService.GetLobbies() {
+		if viewer.admin || lobby.IsUserInLobby(viewer.email) {
+			lobbies = append(lobbies, toGQLLobby(lobby))
+		}
+	}
+	return lobbies, nil
+}
+
+func (gr *graphqlResolver) Lobby(ctx context.Context, args struct{ ID graphql.ID }) (*gqlLobby, error) {
+	lobby, err := gr.lobby(viewerFrom(ctx), args.ID, "")
+	if errors.Is(err, services.ErrLobbyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGQLLobby(lobby), nil
+}
+
+func (gr *graphqlResolver) Messages(ctx context.Context, args struct {
+	LobbyID graphql.ID
+	Before  *int32
+	Limit   *int32
+}) ([]*gqlMessage, error) {
+	lobby, err := gr.lobby(viewerFrom(ctx), args.LobbyID, services.ActionLobbyHistory)
+	if err != nil {
+		return nil, err
+	}
+
+	var before int64
+	if args.Before != nil {
+		if *args.Before < 0 {
+			return nil, errors.New("before must be a sequence number")
+		}
+		before = int64(*args.Before)
+	}
+	limit := maxHistoryPage
+	if args.Limit != nil {
+		if *args.Limit < 1 || *args.Limit > maxHistoryPage {
+			return nil, errors.New("limit must be between 1 and 100")
+		}
+		limit = int(*args.Limit)
+	}
+
+	page, err := gr.lobbyService.GetHistoryPage(lobby, before, limit)
+	if err != nil {
+		log.Printf("❌ GraphQL history page failed for lobby %s: %v", lobby.ID, err)
+		return nil, errors.New("failed to load history")
+	}
+	messages := make([]*gqlMessage, 0, len(page))
+	for _, msg := range page {
+		messages = append(messages, gr.toGQLMessage(msg))
+	}
+	return messages, nil
+}
+
+func (gr *graphqlResolver) Users(ctx context.Context, args struct{ LobbyID graphql.ID }) ([]*gqlUser, error) {
+	lobby, err := gr.lobby(viewerFrom(ctx), args.LobbyID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	emails := lobby.GetMemberEmails()
+	users := make([]*gqlUser, 0, len(emails))
+	for _, email := range emails {
+		profile := gr.lobbyService.Profile(email)
+		users = append(users, &gqlUser{
+			ID:          graphql.ID(gr.lobbyService.MemberID(email)),
+			DisplayName: profile.DisplayName,
+			AvatarURL:   profile.AvatarURL,
+			Role:        string(lobby.GetUserRole(email)),
+			Presence:    string(lobby.GetPresence(email)),
+		})
+	}
+	return users, nil
+}
+
+func (gr *graphqlResolver) Login(ctx context.Context, args struct {
+	Email    string
+	Password *string
+}) (*gqlLoginResult, error) {
+	if args.Email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	// A GraphQL login sets no cookie, so it starts no session
+	req := LoginRequest{Email: args.Email}
+	if args.Password != nil {
+		req.Password = *args.Password
+	}
+	email, provider, err := gr.authHandler.authenticate(req, viewerFrom(ctx).email)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("📧 GraphQL login request from: %s", email)
+	statusCode, response := gr.authHandler.joinLobby(email, viewerFrom(ctx).tenantID, nil, provider != "" || email == viewerFrom(ctx).email)
+	if statusCode >= http.StatusInternalServerError {
+		return nil, errors.New(response.Message)
+	}
+
+	result := &gqlLoginResult{
+		Success:  response.Success,
+		Message:  response.Message,
+		Queued:   response.Queued,
+		Position: int32(response.Position),
+	}
+	if response.LobbyID != "" {
+		lobbyID := graphql.ID(response.LobbyID)
+		result.LobbyID = &lobbyID
+	}
+	if response.Email != "" {
+		result.Email = &response.Email
+	}
+	if response.ReconnectToken != "" {
+		result.ReconnectToken = &response.ReconnectToken
+	}
+	if response.ConnectTicket != "" {
+		result.ConnectTicket = &response.ConnectTicket
+	}
+	if response.Ticket != "" {
+		result.Ticket = &response.Ticket
+	}
+	if !response.StartsAt.IsZero() {
+		result.StartsAt = &graphql.Time{Time: response.StartsAt}
+	}
+	return result, nil
+}
+
+func (gr *graphqlResolver) SendMessage(ctx context.Context, args struct {
+	LobbyID     graphql.ID
+	Content     string
+	ParentSeq   *int32
+	ClientMsgID *string
+	Format      *string
+}) (bool, error) {
+	viewer := viewerFrom(ctx)
+	if viewer.email == "" {
+		return false, controllers.ErrNotLoggedIn
+	}
+	lobby, err := gr.lobby(viewer, args.LobbyID, services.ActionMessageSend)
+	if err != nil {
+		return false, err
+	}
+	if strings.TrimSpace(args.Content) == "" {
+		return false, errors.New("content is required")
+	}
+
+	frame := models.Message{Type: models.MessageTypeChat, Content: args.Content}
+	if args.ParentSeq != nil {
+		frame.Type = models.MessageTypeReply
+		frame.ParentMessageID = int64(*args.ParentSeq)
+	}
+	if args.ClientMsgID != nil {
+		frame.ClientMsgID = *args.ClientMsgID
+	}
+	if args.Format != nil {
+		frame.Format = models.ContentFormat(*args.Format)
+	}
+	// Same stamping as a frame from the member's own connection
+	msg, err := services.ClientFrame(&models.Client{Email: viewer.email, LobbyID: lobby.ID}, frame)
+	if err != nil {
+		return false, err
+	}
+
+	if err := gr.lobbyService.Broadcast(ctx, services.BroadcastMessage{LobbyID: lobby.ID, Message: msg}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (gr *graphqlResolver) EndLobby(ctx context.Context, args struct{ LobbyID graphql.ID }) (bool, error) {
+	viewer := viewerFrom(ctx)
+	lobby, err := gr.lobby(viewer, args.LobbyID, "")
+	if err != nil {
+		return false, err
+	}
+
+	actor := viewer.email
+	if viewer.admin {
+		actor = string(models.RoleAdmin)
+	} else if !gr.controller.Policy.Allowed(lobby.GetUserRole(viewer.email), services.ActionManageEnd) {
+		return false, errors.New("not allowed to " + string(services.ActionManageEnd))
+	}
+
+	if err := gr.lobbyService.EndLobby(ctx, lobby.ID, actor); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (gr *graphqlResolver) NewMessage(ctx context.Context, args struct{ LobbyID graphql.ID }) (<-chan *gqlMessage, error) {
+	frames, err := gr.subscribe(ctx, args.LobbyID, services.ActionLobbyHistory)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *gqlMessage)
+	go func() {
+		defer close(events)
+		for msg := range frames {
+			isAnnouncement := msg.SystemAction != nil && *msg.SystemAction == models.SystemActionAnnouncement
+			if msg.Type != models.MessageTypeChat && msg.Type != models.MessageTypeReply && msg.Type != models.MessageTypeIdea && !isAnnouncement {
+				continue
+			}
+			select {
+			case events <- gr.toGQLMessage(msg):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (gr *graphqlResolver) PresenceChanged(ctx context.Context, args struct{ LobbyID graphql.ID }) (<-chan *gqlPresenceEvent, error) {
+	frames, err := gr.subscribe(ctx, args.LobbyID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *gqlPresenceEvent)
+	go func() {
+		defer close(events)
+		for msg := range frames {
+			for _, event := range gr.toGQLPresenceEvents(msg) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// subscribe checks the viewer may follow a lobby and returns the frames
+// delivered to their connection to it.
+func (gr *graphqlResolver) subscribe(ctx context.Context, id graphql.ID, action services.Action) (<-chan models.Message, error) {
+	conn := graphqlConnFrom(ctx)
+	if conn == nil {
+		return nil, errGraphQLNeedsWebSocket
+	}
+	viewer := viewerFrom(ctx)
+	if viewer.email == "" {
+		return nil, errGraphQLNeedsMembership
+	}
+	lobby, err := gr.lobby(viewer, id, action)
+	if err != nil {
+		return nil, err
+	}
+	return conn.subscribe(ctx, lobby), nil
+}
+
+func toGQLLobby(lobby *models.Lobby) *gqlLobby {
+	return &gqlLobby{
+		ID:        graphql.ID(lobby.ID),
+		TenantID:  lobby.TenantID,
+		UserCount: int32(lobby.GetUserCount()),
+		MaxUsers:  int32(lobby.MaxUsers),
+		LastSeq:   int32(lobby.GetLastSeq()),
+		CreatedAt: graphql.Time{Time: lobby.CreatedAt},
+	}
+}
+
+func (gr *graphqlResolver) toGQLMessage(msg models.Message) *gqlMessage {
+	msg = gr.lobbyService.PublicMessage(msg)
+	format := msg.Format
+	if format == "" {
+		format = models.ContentFormatPlain
+	}
+	return &gqlMessage{
+		MessageID:   msg.MessageID,
+		Seq:         int32(msg.Seq),
+		Type:        string(msg.Type),
+		UserID:      graphql.ID(msg.Username),
+		DisplayName: msg.DisplayName,
+		Content:     msg.Content,
+		Format:      string(format),
+		ParentSeq:   int32(msg.ParentMessageID),
+		ClientMsgID: msg.ClientMsgID,
+		Timestamp:   graphql.Time{Time: msg.Timestamp},
+	}
+}
+
+// toGQLPresenceEvents turns a frame into the presence changes it reports:
+// one for a join, leave or presence change, one per member for a roster
+// digest, and none for anything else.
+func (gr *graphqlResolver) toGQLPresenceEvents(msg models.Message) []*gqlPresenceEvent {
+	if msg.Type != models.MessageTypeSystemAction || msg.SystemAction == nil {
+		return nil
+	}
+	msg = gr.lobbyService.PublicMessage(msg)
+	event := func(action models.SystemActionType, memberID string, presence models.Presence) *gqlPresenceEvent {
+		return &gqlPresenceEvent{
+			Action:      string(action),
+			UserID:      graphql.ID(memberID),
+			DisplayName: msg.Profiles[memberID].DisplayName,
+			Presence:    string(presence),
+			Timestamp:   graphql.Time{Time: msg.Timestamp},
+		}
+	}
+
+	switch action := *msg.SystemAction; action {
+	case models.SystemActionUserJoined:
+		return []*gqlPresenceEvent{event(action, msg.Username, models.PresenceOnline)}
+	case models.SystemActionUserLeft, models.SystemActionUserTimedOut:
+		return []*gqlPresenceEvent{event(action, msg.Username, models.PresenceOffline)}
+	case models.SystemActionPresence:
+		return []*gqlPresenceEvent{event(action, msg.Target, msg.Presence)}
+	case models.SystemActionRosterDigest:
+		var events []*gqlPresenceEvent
+		for _, memberID := range msg.Joined {
+			events = append(events, event(models.SystemActionUserJoined, memberID, models.PresenceOnline))
+		}
+		for _, memberID := range msg.Left {
+			events = append(events, event(models.SystemActionUserLeft, memberID, models.PresenceOffline))
+		}
+		return events
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// graphqlWSProtocol is the subprotocol of the graphql-ws library's
+// graphql-transport-ws protocol, which subscriptions are served over.
+const graphqlWSProtocol = "graphql-transport-ws"
+
+const (
+	graphqlMaxFrameSize = 64 * 1024
+	// graphqlInitTimeout is how long a connection may take to send its
+	// connection_init
+	graphqlInitTimeout = 10 * time.Second
+)
+
+// graphqlWSMessage is a graphql-transport-ws frame.
+type graphqlWSMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// graphqlInitPayload is a connection_init's payload: an email login's
+// email with the reconnect token it was given, for clients that can't use
+// the query string or a session cookie.
+type graphqlInitPayload struct {
+	Email string `json:"email"`
+	Token string `json:"token"`
+}
+
+// graphqlConn is one subscriptions WebSocket. Its subscriptions to a lobby
+// share one lobby client, registered with the first of them and released
+// with the last, just as a gRPC stream is.
+type graphqlConn struct {
+	handler *GraphQLHandler
+	ws      *controllers.CompressedConn
+	viewer  graphqlViewer
+	writeMu sync.Mutex
+
+	mu            sync.Mutex
+	feeds         map[string]*lobbyFeed
+	subscriptions map[string]context.CancelFunc
+}
+
+// lobbyFeed fans out the frames delivered to a connection's lobby client
+// to its subscriptions of that lobby.
+type lobbyFeed struct {
+	client *models.Client
+	subs   map[*feedSub]struct{}
+}
+
+type feedSub struct {
+	ctx    context.Context
+	frames chan models.Message
+}
+
+type graphqlConnKey struct{}
+
+func graphqlConnFrom(ctx context.Context) *graphqlConn {
+	conn, _ := ctx.Value(graphqlConnKey{}).(*graphqlConn)
+	return conn
+}
+
+// serveWebSocket runs the graphql-transport-ws protocol on an upgraded
+// /graphql request until the client goes away.
+func (gh *GraphQLHandler) serveWebSocket(w http.ResponseWriter, r *http.Request, viewer graphqlViewer) {
+	ws, err := gh.compression.Upgrade(gh.upgrader, w, r)
+	if err != nil {
+		log.Printf("❌ GraphQL WebSocket upgrade failed: %v", err)
+		return
+	}
+	if ws.Subprotocol() != graphqlWSProtocol {
+		ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseProtocolError, "Subprotocol "+graphqlWSProtocol+" is required"))
+		ws.Close()
+		return
+	}
+	ws.SetReadLimit(graphqlMaxFrameSize)
+
+	conn := &graphqlConn{
+		handler:       gh,
+		ws:            ws,
+		viewer:        viewer,
+		feeds:         make(map[string]*lobbyFeed),
+		subscriptions: make(map[string]context.CancelFunc),
+	}
+	ctx, cancel := context.WithCancel(context.WithValue(r.Context(), graphqlConnKey{}, conn))
+	defer func() {
+		cancel()
+		ws.Close()
+	}()
+
+	log.Printf("🔌 GraphQL WebSocket opened for: %q", viewer.email)
+	conn.run(ctx)
+	log.Printf("🔌 GraphQL WebSocket closed for: %q", viewer.email)
+}
+
+func (c *graphqlConn) run(ctx context.Context) {
+	c.ws.SetReadDeadline(time.Now().Add(graphqlInitTimeout))
+	acknowledged := false
+
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			if !acknowledged {
+				c.close(4408, "Connection initialisation timeout")
+			}
+			return
+		}
+		var msg graphqlWSMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.close(4400, "Invalid message")
+			return
+		}
+
+		switch msg.Type {
+		case "connection_init":
+			if acknowledged {
+				c.close(4429, "Too many initialisation requests")
+				return
+			}
+			if !c.init(msg.Payload) {
+				c.close(4403, "Forbidden")
+				return
+			}
+			acknowledged = true
+			c.ws.SetReadDeadline(time.Time{})
+			c.write(graphqlWSMessage{Type: "connection_ack"})
+
+		case "ping":
+			c.write(graphqlWSMessage{Type: "pong"})
+
+		case "pong":
+
+		case "subscribe":
+			if !acknowledged {
+				c.close(4401, "Unauthorized")
+				return
+			}
+			if !c.start(ctx, msg) {
+				return
+			}
+
+		case "complete":
+			c.mu.Lock()
+			stop := c.subscriptions[msg.ID]
+			c.mu.Unlock()
+			if stop != nil {
+				stop()
+			}
+
+		default:
+			c.close(4400, "Unknown message type "+msg.Type)
+			return
+		}
+	}
+}
+
+// init authenticates the connection by its connection_init payload, if it
+// names an email; otherwise the handshake's credentials stand.
+func (c *graphqlConn) init(payload json.RawMessage) bool {
+	var init graphqlInitPayload
+	if len(payload) > 0 && string(payload) != "null" {
+		if err := json.Unmarshal(payload, &init); err != nil {
+			return false
+		}
+	}
+	if init.Email == "" {
+		return true
+	}
+
+	tokenEmail, err := c.handler.sessionService.ReconnectEmail(init.Token)
+	if err != nil || tokenEmail != init.Email {
+		log.Printf("❌ GraphQL WebSocket rejected: invalid reconnect token for %s", init.Email)
+		return false
+	}
+	c.viewer.email = init.Email
+	return true
+}
+
+// start runs a subscribe frame's operation and streams its results as next
+// frames. It returns false if the connection was closed for a reused ID.
+func (c *graphqlConn) start(ctx context.Context, msg graphqlWSMessage) bool {
+	var req GraphQLRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil || msg.ID == "" {
+		c.close(4400, "Invalid subscribe message")
+		return false
+	}
+
+	c.mu.Lock()
+	if _, exists := c.subscriptions[msg.ID]; exists {
+		c.mu.Unlock()
+		c.close(4409, "Subscriber for "+msg.ID+" already exists")
+		return false
+	}
+	subCtx, stop := context.WithCancel(ctx)
+	c.subscriptions[msg.ID] = stop
+	c.mu.Unlock()
+
+	responses, err := c.handler.schema.Subscribe(withViewer(subCtx, c.viewer), req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		c.finish(msg.ID, stop)
+		c.writeErrors(msg.ID, err.Error())
+		return true
+	}
+
+	go func() {
+		defer c.finish(msg.ID, stop)
+		first := true
+		for response := range responses {
+			result, ok := response.(*graphql.Response)
+			if !ok {
+				continue
+			}
+			// An operation that fails before it runs reports an error frame
+			if first && len(result.Errors) > 0 && len(result.Data) == 0 {
+				payload, _ := json.Marshal(result.Errors)
+				c.write(graphqlWSMessage{ID: msg.ID, Type: "error", Payload: payload})
+				return
+			}
+			first = false
+			payload, _ := json.Marshal(result)
+			c.write(graphqlWSMessage{ID: msg.ID, Type: "next", Payload: payload})
+		}
+		if subCtx.Err() == nil {
+			c.write(graphqlWSMessage{ID: msg.ID, Type: "complete"})
+		}
+	}()
+	return true
+}
+
+// finish forgets a subscription that ended on either side.
+func (c *graphqlConn) finish(id string, stop context.CancelFunc) {
+	stop()
+	c.mu.Lock()
+	delete(c.subscriptions, id)
+	c.mu.Unlock()
+}
+
+func (c *graphqlConn) writeErrors(id, message string) {
+	payload, _ := json.Marshal([]map[string]string{{"message": message}})
+	c.write(graphqlWSMessage{ID: id, Type: "error", Payload: payload})
+}
+
+func (c *graphqlConn) write(msg graphqlWSMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(config.WriteTimeout))
+	if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+		// The read loop fails next and tears the connection down
+		c.ws.Close()
+	}
+}
+
+func (c *graphqlConn) close(code int, reason string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(config.WriteTimeout))
+}
+
+// subscribe returns the frames delivered to the connection's client in
+// lobby until ctx ends or the lobby drops the client, registering the
+// client if this is the connection's first subscription to the lobby.
+func (c *graphqlConn) subscribe(ctx context.Context, lobby *models.Lobby) <-chan models.Message {
+	sub := &feedSub{ctx: ctx, frames: make(chan models.Message, 16)}
+
+	c.mu.Lock()
+	feed, exists := c.feeds[lobby.ID]
+	if !exists {
+		client := &models.Client{
+			Email:    c.viewer.email,
+			LobbyID:  lobby.ID,
+			Send:     make(chan models.Message, 256),
+			JoinedAt: time.Now(),
+			// Subscriptions only see what happens from now on
+			LastSeq: lobby.GetLastSeq(),
+			Role:    memberRole(lobby, c.viewer.email),
+			Profile: c.handler.lobbyService.Profile(c.viewer.email),
+		}
+		feed = &lobbyFeed{client: client, subs: make(map[*feedSub]struct{})}
+		c.feeds[lobby.ID] = feed
+	}
+	feed.subs[sub] = struct{}{}
+	c.mu.Unlock()
+
+	if !exists {
+		go c.pump(lobby.ID, feed)
+		c.handler.lobbyService.Register(feed.client)
+		log.Printf("🔌 GraphQL subscriptions opened for: %s in lobby: %s", c.viewer.email, lobby.ID)
+	}
+
+	go func() {
+		<-ctx.Done()
+		c.mu.Lock()
+		delete(feed.subs, sub)
+		last := len(feed.subs) == 0 && c.feeds[lobby.ID] == feed
+		if last {
+			delete(c.feeds, lobby.ID)
+		}
+		c.mu.Unlock()
+		if last {
+			c.handler.lobbyService.Unregister(feed.client)
+		}
+	}()
+	return sub.frames
+}
+
+// pump hands each frame of a feed's client to its subscriptions and ends
+// them once the lobby closes the client's queue.
+func (c *graphqlConn) pump(lobbyID string, feed *lobbyFeed) {
+	for msg := range feed.client.Send {
+		c.mu.Lock()
+		subs := make([]*feedSub, 0, len(feed.subs))
+		for sub := range feed.subs {
+			subs = append(subs, sub)
+		}
+		c.mu.Unlock()
+
+		for _, sub := range subs {
+			select {
+			case sub.frames <- msg:
+			case <-sub.ctx.Done():
+			}
+		}
+	}
+
+	c.mu.Lock()
+	if c.feeds[lobbyID] == feed {
+		delete(c.feeds, lobbyID)
+	}
+	subs := feed.subs
+	feed.subs = make(map[*feedSub]struct{})
+	c.mu.Unlock()
+	for sub := range subs {
+		close(sub.frames)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+type GuestHandler struct {
+	controller     *controllers.APIController
+	lobbyService   *services.LobbyService
+	sessionService *services.SessionService
+}
+
+func NewGuestHandler(controller *controllers.APIController, lobbyService *services.LobbyService, sessionService *services.SessionService) *GuestHandler {
+	return &GuestHandler{
+		controller:     controller,
+		lobbyService:   lobbyService,
+		sessionService: sessionService,
+	}
+}
+
+type GuestRequest struct {
+	LobbyID string `json:"lobby_id" openapi:"required"`
+}
+
+type GuestResponse struct {
+	Success     bool   `json:"success"`
+	LobbyID     string `json:"lobby_id"`
+	DisplayName string `json:"display_name"`
+	// Email is the display name, for clients that connect with ?email=
+	Email     string    `json:"email"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Join handles POST /api/guest: joins a guest-friendly lobby under a
+// generated display name and issues a short-lived guest session, returned
+// as token and set as the session cookie.
+func (gh *GuestHandler) Join(w http.ResponseWriter, r *http.Request) {
+	if gh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "POST" {
+		gh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req GuestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.LobbyID == "" {
+		gh.controller.RespondError(w, http.StatusBadRequest, "lobby_id is required")
+		return
+	}
+
+	lobby, name, err := gh.lobbyService.JoinAsGuest(req.LobbyID)
+	switch {
+	case errors.Is(err, services.ErrLobbyNotFound):
+		gh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	case errors.Is(err, services.ErrGuestsNotAllowed):
+		gh.controller.RespondError(w, http.StatusForbidden, err.Error())
+		return
+	case errors.Is(err, services.ErrGuestsFull):
+		gh.controller.RespondCode(w, models.ErrorLobbyFull, err.Error())
+		return
+	case err != nil:
+		gh.controller.RespondError(w, http.StatusInternalServerError, "Failed to join as guest")
+		return
+	}
+
+	session, err := gh.sessionService.CreateGuestSession(name)
+	if err != nil {
+		gh.controller.RespondError(w, http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.SessionCookieName,
+		Value:    session.Token,
+		Path:     gh.controller.PathPrefix + "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	log.Printf("🎭 Guest session issued for %s in lobby %s", name, lobby.ID)
+	gh.controller.RespondJSON(w, http.StatusOK, GuestResponse{
+		Success:     true,
+		LobbyID:     lobby.ID,
+		DisplayName: name,
+		Email:       name,
+		Token:       session.Token,
+		ExpiresAt:   session.ExpiresAt,
+	})
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/controllers"
+	"chat-integrated/lifecycle"
+	"chat-integrated/services"
+	"context"
+	"net/http"
+	"time"
+)
+
+type HealthHandler struct {
+	controller   *controllers.APIController
+	lobbyService *services.LobbyService
+	store        services.Store
+}
+
+func NewHealthHandler(controller *controllers.APIController, lobbyService *services.LobbyService, store services.Store) *HealthHandler {
+	return &HealthHandler{
+		controller:   controller,
+		lobbyService: lobbyService,
+		store:        store,
+	}
+}
+
+// HealthCheck is the outcome of one readiness check.
+type HealthCheck struct {
+	OK        bool    `json:"ok"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Healthz handles GET /healthz: the process is up and serving HTTP.
+func (hh *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	hh.controller.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "ok",
+		"state":  lifecycle.CurrentState(),
+	})
+}
+
+// Readyz handles GET /readyz: the store answers a ping, every lobby worker
+// picks up work and the process isn't starting or draining. It returns 503
+// with the failing checks otherwise.
+func (hh *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]HealthCheck{
+		"store": runHealthCheck(r.Context(), hh.store.Ping),
+		"hub":   runHealthCheck(r.Context(), hh.lobbyService.Ping),
+	}
+
+	state := lifecycle.CurrentState()
+	ready := state == lifecycle.StateReady
+	for _, check := range checks {
+		ready = ready && check.OK
+	}
+
+	status, code := "ok", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	hh.controller.RespondJSON(w, code, map[string]interface{}{
+		"status": status,
+		"state":  state,
+		"checks": checks,
+	})
+}
+
+func runHealthCheck(ctx context.Context, check func(context.Context) error) HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, config.ReadinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := HealthCheck{
+		OK:        err == nil,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type InviteHandler struct {
+	controller    *controllers.APIController
+	lobbyService  *services.LobbyService
+	inviteService *services.InviteService
+}
+
+func NewInviteHandler(controller *controllers.APIController, lobbyService *services.LobbyService, inviteService *services.InviteService) *InviteHandler {
+	return &InviteHandler{
+		controller:    controller,
+		lobbyService:  lobbyService,
+		inviteService: inviteService,
+	}
+}
+
+type InviteRequest struct {
+	// ExpiresInSeconds defaults to INVITE_TTL
+	ExpiresInSeconds int  `json:"expires_in_seconds,omitempty"`
+	SingleUse        bool `json:"single_use,omitempty"`
+}
+
+type InviteResponse struct {
+	models.Invite
+	Token string `json:"token"`
+	// URL opens the web client, which logs in with the token
+	URL string `json:"url"`
+}
+
+// Create handles POST /api/lobbies/{id}/invites and issues an invite link
+// to the lobby, for its owner or moderators or the admin key.
+func (ih *InviteHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if ih.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "POST" {
+		ih.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	lobby := ih.lobbyService.GetLobby(r.PathValue("id"))
+	if lobby == nil || lobby.Internal {
+		ih.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+	actor, ok := ih.controller.AuthorizeLobby(w, r, lobby, services.ActionManageInvites)
+	if !ok {
+		return
+	}
+
+	var req InviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ih.controller.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ttl := time.Duration(req.ExpiresInSeconds) * time.Second
+	invite, token, err := ih.inviteService.Create(lobby, actor, ttl, req.SingleUse)
+	if errors.Is(err, services.ErrInviteTooLong) {
+		ih.controller.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to create invite to lobby %s: %v", lobby.ID, err)
+		ih.controller.RespondError(w, http.StatusInternalServerError, "Failed to create invite")
+		return
+	}
+
+	ih.controller.RespondJSON(w, http.StatusCreated, InviteResponse{
+		Invite: invite,
+		Token:  token,
+		URL:    config.MailLinkBaseURL + ih.controller.PathPrefix + "/?invite=" + url.QueryEscape(token),
+	})
+}
@@ -0,0 +1,990 @@
+package handlers
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	maxSearchContext = 10
+	maxHistoryPage   = 100
+	defaultTopLimit  = 5
+	maxTopLimit      = 50
+)
+
+type LobbyHandler struct {
+	controller    *controllers.APIController
+	lobbyService  *services.LobbyService
+	searchService *services.SearchService
+}
+
+func NewLobbyHandler(controller *controllers.APIController, lobbyService *services.LobbyService, searchService *services.SearchService) *LobbyHandler {
+	return &LobbyHandler{
+		controller:    controller,
+		lobbyService:  lobbyService,
+		searchService: searchService,
+	}
+}
+
+// Search handles GET /api/lobbies/{id}/search?q=&case_sensitive=&sender=&from=&to=&context=
+func (lh *LobbyHandler) Search(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, ok := lh.controller.AuthorizeSession(w, r, r.PathValue("id"), services.ActionLobbySearch); !ok {
+		return
+	}
+
+	lobbyID := r.PathValue("id")
+	params := r.URL.Query()
+
+	query := services.SearchQuery{
+		Text:          params.Get("q"),
+		CaseSensitive: params.Get("case_sensitive") == "true",
+		Sender:        params.Get("sender"),
+	}
+	if query.Text == "" && query.Sender == "" {
+		lh.controller.RespondError(w, http.StatusBadRequest, "q or sender is required")
+		return
+	}
+
+	var err error
+	if query.From, err = parseTimeParam(params.Get("from")); err != nil {
+		lh.controller.RespondError(w, http.StatusBadRequest, "from must be an RFC 3339 timestamp")
+		return
+	}
+	if query.To, err = parseTimeParam(params.Get("to")); err != nil {
+		lh.controller.RespondError(w, http.StatusBadRequest, "to must be an RFC 3339 timestamp")
+		return
+	}
+	if raw := params.Get("context"); raw != "" {
+		query.Context, err = strconv.Atoi(raw)
+		if err != nil || query.Context < 0 || query.Context > maxSearchContext {
+			lh.controller.RespondError(w, http.StatusBadRequest, "context must be between 0 and 10")
+			return
+		}
+	}
+
+	results, err := lh.searchService.Search(lobbyID, query)
+	if err != nil {
+		log.Printf("❌ Search failed for lobby %s: %v", lobbyID, err)
+		lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to search messages")
+		return
+	}
+
+	names := lh.lobbyService.PublicNames(lobbyID)
+	lh.controller.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"lobby_id":      lobbyID,
+		"query":         query.Text,
+		"total_matches": len(results),
+		"results":       names.SearchResults(results),
+		"profiles":      names.Profiles,
+	})
+}
+
+// HistoryResponse is a page of a lobby's history, oldest first.
+type HistoryResponse struct {
+	LobbyID  string           `json:"lobby_id"`
+	Messages []models.Message `json:"messages"`
+	HasMore  bool             `json:"has_more"`
+}
+
+// History handles GET /api/lobbies/{id}/history?before=&limit= and returns a
+// page of messages older than the given sequence number.
+func (lh *LobbyHandler) History(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, ok := lh.controller.AuthorizeSession(w, r, r.PathValue("id"), services.ActionLobbyHistory); !ok {
+		return
+	}
+
+	lobby := lh.lobbyService.GetLobby(r.PathValue("id"))
+	if lobby == nil {
+		lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+
+	params := r.URL.Query()
+	var before int64
+	if raw := params.Get("before"); raw != "" {
+		var err error
+		before, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || before < 0 {
+			lh.controller.RespondError(w, http.StatusBadRequest, "before must be a sequence number")
+			return
+		}
+	}
+	limit := maxHistoryPage
+	if raw := params.Get("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxHistoryPage {
+			lh.controller.RespondError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+	}
+
+	messages, err := lh.lobbyService.GetHistoryPage(lobby, before, limit)
+	if err != nil {
+		log.Printf("❌ History page failed for lobby %s: %v", lobby.ID, err)
+		lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to load history")
+		return
+	}
+
+	lh.controller.RespondJSON(w, http.StatusOK, HistoryResponse{
+		LobbyID:  lobby.ID,
+		Messages: lh.lobbyService.PublicMessages(messages),
+		HasMore:  len(messages) == limit && messages[0].Seq > 1,
+	})
+}
+
+// Top handles GET /api/lobbies/{id}/top?limit= and ranks the lobby's
+// messages by reactions and votes, the most recent first on ties.
+func (lh *LobbyHandler) Top(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, ok := lh.controller.AuthorizeSession(w, r, r.PathValue("id"), services.ActionLobbyTop); !ok {
+		return
+	}
+
+	lobby := lh.lobbyService.GetLobby(r.PathValue("id"))
+	if lobby == nil {
+		lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+
+	limit := defaultTopLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxTopLimit {
+			lh.controller.RespondError(w, http.StatusBadRequest, "limit must be between 1 and 50")
+			return
+		}
+	}
+
+	top, err := lh.lobbyService.TopMessages(lobby, limit)
+	if err != nil {
+		log.Printf("❌ Top messages failed for lobby %s: %v", lobby.ID, err)
+		lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to rank messages")
+		return
+	}
+
+	names := lh.lobbyService.PublicNames(lobby.ID)
+	lh.controller.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"lobby_id": lobby.ID,
+		"top":      names.TopMessages(top),
+		"profiles": names.Profiles,
+	})
+}
+
+// IdeasResponse lists a lobby's idea cards, best voted first.
+type IdeasResponse struct {
+	LobbyID  string                    `json:"lobby_id"`
+	Ideas    []models.Idea             `json:"ideas"`
+	Profiles map[string]models.Profile `json:"profiles"`
+}
+
+// Ideas handles GET /api/lobbies/{id}/ideas and returns the lobby's ideas
+// ranked by their votes, all of them unless limit is given.
+func (lh *LobbyHandler) Ideas(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, ok := lh.controller.AuthorizeSession(w, r, r.PathValue("id"), services.ActionLobbyIdeas); !ok {
+		return
+	}
+
+	lobby := lh.lobbyService.GetLobby(r.PathValue("id"))
+	if lobby == nil {
+		lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			lh.controller.RespondError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+	}
+
+	ideas, err := lh.lobbyService.RankedIdeas(lobby, limit)
+	if err != nil {
+		log.Printf("❌ Ranking ideas failed for lobby %s: %v", lobby.ID, err)
+		lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to rank ideas")
+		return
+	}
+
+	names := lh.lobbyService.PublicNames(lobby.ID)
+	lh.controller.RespondJSON(w, http.StatusOK, IdeasResponse{LobbyID: lobby.ID, Ideas: names.Ideas(ideas), Profiles: names.Profiles})
+}
+
+// Thread handles GET /api/lobbies/{id}/threads/{messageID}, where messageID
+// is the seq of a chat message, and returns the message with its replies.
+func (lh *LobbyHandler) Thread(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, ok := lh.controller.AuthorizeSession(w, r, r.PathValue("id"), services.ActionLobbyThreads); !ok {
+		return
+	}
+
+	lobby := lh.lobbyService.GetLobby(r.PathValue("id"))
+	if lobby == nil {
+		lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+
+	parentSeq, err := strconv.ParseInt(r.PathValue("messageID"), 10, 64)
+	if err != nil || parentSeq < 1 {
+		lh.controller.RespondError(w, http.StatusBadRequest, "messageID must be a sequence number")
+		return
+	}
+
+	parent, replies, err := lh.lobbyService.GetThread(lobby, parentSeq)
+	if errors.Is(err, services.ErrParentNotFound) || errors.Is(err, services.ErrNestedReply) {
+		lh.controller.RespondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Thread %d failed for lobby %s: %v", parentSeq, lobby.ID, err)
+		lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to load thread")
+		return
+	}
+
+	lh.controller.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"lobby_id": lobby.ID,
+		"parent":   lh.lobbyService.PublicMessage(parent),
+		"replies":  lh.lobbyService.PublicMessages(replies),
+	})
+}
+
+// Export handles GET /api/lobbies/{id}/export?format=json|csv|txt and streams
+// the lobby transcript as a download.
+func (lh *LobbyHandler) Export(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, ok := lh.controller.AuthorizeSession(w, r, r.PathValue("id"), services.ActionLobbyExport); !ok {
+		return
+	}
+
+	lobbyID := r.PathValue("id")
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" && format != "txt" {
+		lh.controller.RespondError(w, http.StatusBadRequest, "format must be json, csv or txt")
+		return
+	}
+
+	transcript, err := lh.lobbyService.GetTranscript(lobbyID)
+	if err != nil {
+		log.Printf("❌ Export failed for lobby %s: %v", lobbyID, err)
+		lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to load transcript")
+		return
+	}
+	if len(transcript) == 0 && lh.lobbyService.GetLobby(lobbyID) == nil {
+		lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+
+	names := lh.lobbyService.PublicNames(lobbyID)
+	transcript = names.Records(transcript)
+
+	lh.controller.SetCommonHeaders(w)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, lobbyID, format))
+
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"seq", "timestamp", "username", "display_name", "content"})
+		for _, msg := range transcript {
+			writer.Write([]string{
+				strconv.FormatInt(msg.Seq, 10),
+				msg.Timestamp.Format(time.RFC3339),
+				msg.Username,
+				names.Profiles[msg.Username].DisplayName,
+				msg.Content,
+			})
+		}
+		writer.Flush()
+	case "txt":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, msg := range transcript {
+			fmt.Fprintf(w, "[%s] %s: %s\n", msg.Timestamp.Format(time.RFC3339), names.Profiles[msg.Username].DisplayName, msg.Content)
+		}
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"lobby_id":       lobbyID,
+			"exported_at":    time.Now(),
+			"total_messages": len(transcript),
+			"messages":       transcript,
+			"profiles":       names.Profiles,
+		})
+	}
+
+	log.Printf("📤 Exported %d messages from lobby %s as %s", len(transcript), lobbyID, format)
+}
+
+// Unread handles GET /api/lobbies/{id}/unread: the calling member's
+// last-read pointer and how many messages came after it.
+func (lh *LobbyHandler) Unread(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, ok := lh.controller.AuthorizeSession(w, r, r.PathValue("id"), services.ActionLobbyUnread); !ok {
+		return
+	}
+
+	email, err := lh.controller.RequestUser(r)
+	if err != nil {
+		lh.controller.RespondError(w, http.StatusUnauthorized, "Login required")
+		return
+	}
+
+	lobby := lh.lobbyService.GetLobby(r.PathValue("id"))
+	if lobby == nil {
+		lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+	if lobby.GetUserRole(email) == "" {
+		lh.controller.RespondError(w, http.StatusForbidden, "Not a member of this lobby")
+		return
+	}
+
+	status, err := lh.lobbyService.GetReadStatus(lobby, email)
+	if err != nil {
+		log.Printf("❌ Unread count failed for %s in lobby %s: %v", email, lobby.ID, err)
+		lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to count unread messages")
+		return
+	}
+	lh.controller.RespondJSON(w, http.StatusOK, status)
+}
+
+// PresenceResponse lists a lobby's members with their presence and
+// latency.
+type PresenceResponse struct {
+	LobbyID string                    `json:"lobby_id"`
+	Members []services.MemberPresence `json:"members"`
+}
+
+// Presence handles GET /api/lobbies/{id}/presence: who is online, away or
+// offline, and the round trip each connected client last measured.
+func (lh *LobbyHandler) Presence(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, ok := lh.controller.AuthorizeSession(w, r, r.PathValue("id"), services.ActionLobbyPresence); !ok {
+		return
+	}
+
+	lobby := lh.lobbyService.GetLobby(r.PathValue("id"))
+	if lobby == nil {
+		lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+
+	lh.controller.RespondJSON(w, http.StatusOK, PresenceResponse{
+		LobbyID: lobby.ID,
+		Members: lh.lobbyService.LobbyPresence(lobby),
+	})
+}
+
+// CreateLobbyRequest starts a follow-up of an earlier session.
+type CreateLobbyRequest struct {
+	ParentSessionID string `json:"parent_session_id" openapi:"required"`
+}
+
+// Create handles POST /api/lobbies with a parent_session_id and opens the
+// tenant's next session linked to it.
+func (lh *LobbyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "POST" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !lh.controller.Authorize(w, r, services.ActionLobbyCreate) {
+		return
+	}
+
+	var req CreateLobbyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ParentSessionID == "" {
+		lh.controller.RespondError(w, http.StatusBadRequest, "parent_session_id is required")
+		return
+	}
+
+	lobby, err := lh.lobbyService.CreateFollowUpLobby(lh.controller.TenantID(r), req.ParentSessionID)
+	switch {
+	case errors.Is(err, services.ErrUnknownSession):
+		lh.controller.RespondError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, services.ErrSessionInProgress):
+		lh.controller.RespondCode(w, models.ErrorSessionActive, err.Error())
+		return
+	case err != nil:
+		log.Printf("❌ Follow-up of %s failed: %v", req.ParentSessionID, err)
+		lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to create lobby")
+		return
+	}
+
+	lh.controller.RespondJSON(w, http.StatusCreated, map[string]interface{}{
+		"lobby_id":          lobby.ID,
+		"parent_session_id": lobby.ParentID,
+		"carried_over":      len(lobby.GetImportedContext()),
+	})
+}
+
+// ScheduleRequest books a session for a later time.
+type ScheduleRequest struct {
+	StartsAt     time.Time `json:"starts_at" openapi:"required"`
+	Participants []string  `json:"participants" openapi:"required"`
+}
+
+// Schedule handles POST /api/lobbies/schedule, which books a session of
+// the tenant, and GET, which lists the ones that haven't opened yet.
+func (lh *LobbyHandler) Schedule(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" && r.Method != "POST" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !lh.controller.Authorize(w, r, services.ActionLobbySchedule) {
+		return
+	}
+
+	tenantID := lh.controller.TenantID(r)
+	if r.Method == "GET" {
+		lh.controller.RespondJSON(w, http.StatusOK, lh.lobbyService.ScheduledLobbies(tenantID))
+		return
+	}
+
+	var req ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		lh.controller.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	scheduled, err := lh.lobbyService.ScheduleLobby(tenantID, req.StartsAt, req.Participants)
+	switch {
+	case errors.Is(err, services.ErrStartInPast), errors.Is(err, services.ErrStartTooFar),
+		errors.Is(err, services.ErrNoParticipants), errors.Is(err, services.ErrTooManyInvitees):
+		lh.controller.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		log.Printf("❌ Scheduling a lobby failed: %v", err)
+		lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to schedule lobby")
+		return
+	}
+
+	lh.controller.RespondJSON(w, http.StatusCreated, scheduled)
+}
+
+// Sessions handles GET /api/lobbies/{id}/sessions: the chain of sessions
+// leading up to the lobby, oldest first.
+func (lh *LobbyHandler) Sessions(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, ok := lh.controller.AuthorizeSession(w, r, r.PathValue("id"), services.ActionLobbySessions); !ok {
+		return
+	}
+
+	lobbyID := r.PathValue("id")
+	chain, err := lh.lobbyService.SessionChain(lobbyID)
+	if errors.Is(err, services.ErrUnknownSession) {
+		lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Session chain failed for lobby %s: %v", lobbyID, err)
+		lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to load sessions")
+		return
+	}
+
+	names := lh.lobbyService.PublicNames(lobbyID)
+	lh.controller.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"lobby_id": lobbyID,
+		"sessions": names.Sessions(chain),
+		"profiles": names.Profiles,
+	})
+}
+
+// SummaryResponse is an ended session's summary, naming its members by
+// member ID.
+type SummaryResponse struct {
+	models.LobbySummary
+	Profiles map[string]models.Profile `json:"profiles"`
+}
+
+// Summary handles GET /api/lobbies/{id}/summary: the summary saved when the
+// session ended.
+func (lh *LobbyHandler) Summary(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, ok := lh.controller.AuthorizeSession(w, r, r.PathValue("id"), services.ActionLobbySummary); !ok {
+		return
+	}
+
+	lobbyID := r.PathValue("id")
+	summary, err := lh.lobbyService.GetSummary(lobbyID)
+	if errors.Is(err, services.ErrNoSummary) {
+		if lobby := lh.lobbyService.GetLobby(lobbyID); lobby != nil && !lobby.Internal {
+			lh.controller.RespondError(w, http.StatusConflict, "Lobby is still in session")
+			return
+		}
+		lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Summary failed for lobby %s: %v", lobbyID, err)
+		lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to load summary")
+		return
+	}
+
+	names := lh.lobbyService.PublicNames(lobbyID)
+	lh.controller.RespondJSON(w, http.StatusOK, SummaryResponse{LobbySummary: names.Summary(summary), Profiles: names.Profiles})
+}
+
+// ArchiveResponse links to an ended session's archived transcript.
+type ArchiveResponse struct {
+	models.TranscriptArchive
+	// URL downloads the gzipped JSON lines without credentials until
+	// ExpiresAt
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Archive handles GET /api/lobbies/{id}/archive, a presigned download link
+// to the transcript an ended session was archived with.
+func (lh *LobbyHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, ok := lh.controller.AuthorizeSession(w, r, r.PathValue("id"), services.ActionLobbyArchive); !ok {
+		return
+	}
+
+	lobbyID := r.PathValue("id")
+	url, archive, expiresAt, err := lh.lobbyService.ArchiveURL(lobbyID)
+	switch {
+	case errors.Is(err, services.ErrUnknownSession):
+		lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	case errors.Is(err, services.ErrNoArchive):
+		if lobby := lh.lobbyService.GetLobby(lobbyID); lobby != nil && !lobby.Internal {
+			lh.controller.RespondError(w, http.StatusConflict, "Lobby is still in session")
+			return
+		}
+		lh.controller.RespondError(w, http.StatusNotFound, "This session has no archived transcript")
+		return
+	case err != nil:
+		log.Printf("❌ Archive link failed for lobby %s: %v", lobbyID, err)
+		lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to link the archive")
+		return
+	}
+
+	lh.controller.RespondJSON(w, http.StatusOK, ArchiveResponse{TranscriptArchive: archive, URL: url, ExpiresAt: expiresAt})
+}
+
+// ImportRequest is the JSON export of an earlier session.
+type ImportRequest struct {
+	LobbyID  string                `json:"lobby_id" openapi:"required"`
+	Messages []models.RedisMessage `json:"messages" openapi:"required"`
+}
+
+// Import handles POST /api/lobbies/{id}/import with a transcript from
+// /export?format=json, shown to the lobby as read-only prior context.
+func (lh *LobbyHandler) Import(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "POST" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !lh.controller.Authorize(w, r, services.ActionLobbyImport) {
+		return
+	}
+
+	lobby := lh.lobbyService.GetLobby(r.PathValue("id"))
+	if lobby == nil || lobby.Internal {
+		lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+	actor, ok := lh.controller.AuthorizeLobby(w, r, lobby, services.ActionManageImport)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, config.MaxImportSize)
+	var req ImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		lh.controller.RespondError(w, http.StatusBadRequest, "Invalid transcript")
+		return
+	}
+	if !services.IsLobbyID(req.LobbyID) || req.LobbyID == lobby.ID {
+		lh.controller.RespondError(w, http.StatusBadRequest, "lobby_id must name the earlier session")
+		return
+	}
+
+	// Only a session the importer could read themselves may be shown
+	source, err := lh.lobbyService.FindSession(req.LobbyID)
+	if err != nil && !errors.Is(err, services.ErrUnknownSession) {
+		log.Printf("❌ Failed to load session %s for an import: %v", req.LobbyID, err)
+		lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to load the earlier session")
+		return
+	}
+	if actor != string(models.RoleAdmin) && !source.IsUserInLobby(actor) {
+		lh.controller.RespondError(w, http.StatusForbidden, "Not a member of the earlier session")
+		return
+	}
+
+	err = lh.lobbyService.ImportTranscript(lobby, req.LobbyID, req.Messages)
+	if errors.Is(err, services.ErrTooManyImported) {
+		lh.controller.RespondError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Import failed for lobby %s: %v", lobby.ID, err)
+		lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to import transcript")
+		return
+	}
+
+	lh.controller.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"lobby_id":       lobby.ID,
+		"imported_from":  req.LobbyID,
+		"total_messages": len(req.Messages),
+	})
+}
+
+// SettingsRequest changes the settings it names and leaves the rest.
+type SettingsRequest struct {
+	MaxUsers        *int  `json:"max_users"`
+	HistoryLimit    *int  `json:"history_limit"`
+	ReadOnly        *bool `json:"read_only"`
+	AllowGuests     *bool `json:"allow_guests"`
+	SlowModeSeconds *int  `json:"slow_mode_seconds"`
+	// RetentionSeconds and RetentionMessages override the server's history
+	// retention, 0 for its default
+	RetentionSeconds  *int `json:"retention_seconds"`
+	RetentionMessages *int `json:"retention_messages"`
+	// EphemeralSeconds turns on ephemeral mode, 0 turns it off
+	EphemeralSeconds *int `json:"ephemeral_seconds"`
+	// ReliableDelivery turns acked, retransmitted delivery on or off
+	ReliableDelivery *bool `json:"reliable_delivery"`
+}
+
+// Settings handles GET /api/lobbies/{id}/settings and PATCH, which lets the
+// lobby's owner change them.
+func (lh *LobbyHandler) Settings(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	lobby := lh.lobbyService.GetLobby(r.PathValue("id"))
+
+	switch r.Method {
+	case "GET":
+		if _, ok := lh.controller.AuthorizeSession(w, r, r.PathValue("id"), services.ActionLobbySettings); !ok {
+			return
+		}
+		if lobby == nil || lobby.Internal {
+			lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+			return
+		}
+		lh.controller.RespondJSON(w, http.StatusOK, lobby.GetSettings())
+
+	case "PATCH":
+		if lobby == nil || lobby.Internal {
+			lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+			return
+		}
+		actor, ok := lh.controller.AuthorizeLobby(w, r, lobby, services.ActionManageSettings)
+		if !ok {
+			return
+		}
+
+		var req SettingsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			lh.controller.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		settings := lobby.GetSettings()
+		if req.MaxUsers != nil {
+			settings.MaxUsers = *req.MaxUsers
+		}
+		if req.HistoryLimit != nil {
+			settings.HistoryLimit = *req.HistoryLimit
+		}
+		if req.ReadOnly != nil {
+			settings.ReadOnly = *req.ReadOnly
+		}
+		if req.AllowGuests != nil {
+			settings.AllowGuests = *req.AllowGuests
+		}
+		if req.SlowModeSeconds != nil {
+			settings.SlowModeSeconds = *req.SlowModeSeconds
+		}
+		if req.RetentionSeconds != nil {
+			settings.RetentionSeconds = *req.RetentionSeconds
+		}
+		if req.RetentionMessages != nil {
+			settings.RetentionMessages = *req.RetentionMessages
+		}
+		if req.EphemeralSeconds != nil {
+			settings.EphemeralSeconds = *req.EphemeralSeconds
+		}
+		if req.ReliableDelivery != nil {
+			settings.ReliableDelivery = *req.ReliableDelivery
+		}
+
+		settings, err := lh.lobbyService.UpdateSettings(r.Context(), lobby, actor, settings)
+		if errors.Is(err, services.ErrInvalidSettings) {
+			lh.controller.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			log.Printf("❌ Settings update failed for lobby %s: %v", lobby.ID, err)
+			lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to update settings")
+			return
+		}
+		lh.controller.RespondJSON(w, http.StatusOK, settings)
+
+	default:
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// ActionItemsRequest names the messages to tag as action items.
+type ActionItemsRequest struct {
+	MessageIDs []string `json:"message_ids" openapi:"required"`
+}
+
+// ActionItemsResponse lists a lobby's action items, oldest tag first.
+type ActionItemsResponse struct {
+	LobbyID     string                    `json:"lobby_id"`
+	ActionItems []models.ActionItem       `json:"action_items"`
+	Profiles    map[string]models.Profile `json:"profiles"`
+}
+
+// Actions handles GET /api/lobbies/{id}/actions, the action items of a lobby
+// or an ended session as JSON or CSV, and POST, which lets the owner or a
+// moderator tag messages as action items.
+func (lh *LobbyHandler) Actions(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	lobbyID := r.PathValue("id")
+
+	switch r.Method {
+	case "GET":
+		if _, ok := lh.controller.AuthorizeSession(w, r, lobbyID, services.ActionLobbyActions); !ok {
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "json" && format != "csv" {
+			lh.controller.RespondError(w, http.StatusBadRequest, "format must be json or csv")
+			return
+		}
+		items, err := lh.lobbyService.ActionItems(lobbyID)
+		if errors.Is(err, services.ErrUnknownSession) {
+			lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+			return
+		}
+		if err != nil {
+			log.Printf("❌ Action items failed for lobby %s: %v", lobbyID, err)
+			lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to load action items")
+			return
+		}
+		names := lh.lobbyService.PublicNames(lobbyID)
+		items = names.ActionItems(items)
+		if format != "csv" {
+			lh.controller.RespondJSON(w, http.StatusOK, ActionItemsResponse{LobbyID: lobbyID, ActionItems: items, Profiles: names.Profiles})
+			return
+		}
+
+		lh.controller.SetCommonHeaders(w)
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-actions.csv"`, lobbyID))
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"message_id", "seq", "username", "display_name", "content", "tagged_by", "tagged_at"})
+		for _, item := range items {
+			writer.Write([]string{
+				item.MessageID,
+				strconv.FormatInt(item.Seq, 10),
+				item.Username,
+				names.Profiles[item.Username].DisplayName,
+				item.Content,
+				item.TaggedBy,
+				item.TaggedAt.Format(time.RFC3339),
+			})
+		}
+		writer.Flush()
+
+	case "POST":
+		lobby := lh.lobbyService.GetLobby(lobbyID)
+		if lobby == nil || lobby.Internal {
+			lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+			return
+		}
+		actor, ok := lh.controller.AuthorizeLobby(w, r, lobby, services.ActionManageActions)
+		if !ok {
+			return
+		}
+
+		var req ActionItemsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.MessageIDs) == 0 {
+			lh.controller.RespondError(w, http.StatusBadRequest, "message_ids is required")
+			return
+		}
+		if _, err := lh.lobbyService.TagActionItems(r.Context(), lobby, actor, req.MessageIDs); err != nil {
+			switch {
+			case errors.Is(err, services.ErrUnknownMessage):
+				lh.controller.RespondError(w, http.StatusNotFound, err.Error())
+			case errors.Is(err, services.ErrTooManyActionItems):
+				lh.controller.RespondError(w, http.StatusConflict, err.Error())
+			default:
+				log.Printf("❌ Tagging action items failed for lobby %s: %v", lobbyID, err)
+				lh.controller.RespondError(w, http.StatusInternalServerError, "Failed to tag action items")
+			}
+			return
+		}
+		names := lh.lobbyService.PublicNames(lobbyID)
+		lh.controller.RespondJSON(w, http.StatusOK, ActionItemsResponse{LobbyID: lobbyID, ActionItems: names.ActionItems(lobby.GetActionItems()), Profiles: names.Profiles})
+
+	default:
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// UntagAction handles DELETE /api/lobbies/{id}/actions/{messageID}, which
+// lets the owner or a moderator take a message off the action items.
+func (lh *LobbyHandler) UntagAction(w http.ResponseWriter, r *http.Request) {
+	if lh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "DELETE" {
+		lh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	lobby := lh.lobbyService.GetLobby(r.PathValue("id"))
+	if lobby == nil || lobby.Internal {
+		lh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
+		return
+	}
+	actor, ok := lh.controller.AuthorizeLobby(w, r, lobby, services.ActionManageActions)
+	if !ok {
+		return
+	}
+
+	if err := lh.lobbyService.UntagActionItem(r.Context(), lobby, actor, r.PathValue("messageID")); errors.Is(err, services.ErrNotActionItem) {
+		lh.controller.RespondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseTimeParam(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
@@ -0,0 +1,284 @@
+package handlers_test
+
+import (
+	"bytes"
+	"chat-integrated/client"
+	"chat-integrated/config"
+	"chat-integrated/middleware"
+	"chat-integrated/models"
+	"chat-integrated/server"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+const password = "handler-test-password"
+
+// testServer is a hub on the memory store behind an httptest server, with
+// the real routes and middleware.
+type testServer struct {
+	*httptest.Server
+	hub *server.Hub
+}
+
+func newTestServer(t *testing.T) *testServer {
+	cfg := server.DefaultConfig()
+	cfg.Name = "test"
+	cfg.StoreBackend = "memory"
+	cfg.AttachmentDir = t.TempDir()
+	cfg.ProbeEnabled = false
+	cfg.GRPCAddr = ""
+	hub := server.NewHub(cfg)
+	mux := http.NewServeMux()
+	server.NewServer(hub).Mount(mux)
+	hub.Start()
+	srv := httptest.NewServer(middleware.Stack(mux))
+	t.Cleanup(func() {
+		srv.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		hub.Shutdown(ctx)
+		hub.Close()
+	})
+	return &testServer{Server: srv, hub: hub}
+}
+
+// member is a seated user with a session cookie.
+type member struct {
+	client.Seat
+	session string
+}
+
+// seat registers name in tenant, logs them in and starts their session.
+func (ts *testServer) seat(t *testing.T, tenant, name string) member {
+	t.Helper()
+	email := fmt.Sprintf("%s@%s.test", name, tenant)
+	if _, err := ts.hub.Accounts.Register(email, password); err != nil {
+		t.Fatalf("register %s: %v", email, err)
+	}
+	seat, err := client.LoginWithPassword(context.Background(), ts.URL, email, password, tenant)
+	if err != nil {
+		t.Fatalf("login %s: %v", email, err)
+	}
+	session, err := ts.hub.Sessions.CreateSession(email, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return member{Seat: seat, session: session.Token}
+}
+
+// call sends a request to path as m, with their session cookie.
+func (ts *testServer) call(t *testing.T, m member, method, path string, body any) (int, []byte) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, ts.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.AddCookie(&http.Cookie{Name: config.SessionCookieName, Value: m.session})
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, data
+}
+
+func (ts *testServer) get(t *testing.T, m member, path string) (int, []byte) {
+	t.Helper()
+	return ts.call(t, m, "GET", path, nil)
+}
+
+// lobbyReads are the lobby reads that need the caller to be a member.
+var lobbyReads = []string{
+	"/search?q=hello",
+	"/history",
+	"/top",
+	"/ideas",
+	"/threads/1",
+	"/export?format=txt",
+	"/unread",
+	"/presence",
+	"/sessions",
+	"/summary",
+	"/archive",
+	"/settings",
+	"/actions",
+}
+
+func TestLobbyReadsNeedMembership(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.seat(t, "acme", "alice")
+	mallory := ts.seat(t, "evil", "mallory")
+	if alice.LobbyID == mallory.LobbyID {
+		t.Fatalf("tenants share lobby %s", alice.LobbyID)
+	}
+
+	for _, read := range lobbyReads {
+		t.Run(read, func(t *testing.T) {
+			if status, body := ts.get(t, mallory, "/api/lobbies/"+alice.LobbyID+read); status != http.StatusForbidden {
+				t.Errorf("another tenant's member got %d, want 403: %s", status, body)
+			}
+			if status, body := ts.get(t, mallory, "/api/lobbies/lobby-unknown"+read); status != http.StatusForbidden {
+				t.Errorf("unknown lobby got %d, want 403: %s", status, body)
+			}
+			if status, body := ts.get(t, alice, "/api/lobbies/"+alice.LobbyID+read); status == http.StatusForbidden || status == http.StatusUnauthorized {
+				t.Errorf("member got %d: %s", status, body)
+			}
+		})
+	}
+}
+
+func TestImportNeedsOwnerOfBothSessions(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.seat(t, "acme", "alice")
+	mallory := ts.seat(t, "evil", "mallory")
+
+	// alice's first session ends and she starts the next
+	earlier := alice.LobbyID
+	if err := ts.hub.Lobbies.EndLobby(context.Background(), earlier, alice.Email); err != nil {
+		t.Fatal(err)
+	}
+	seat, err := client.LoginWithPassword(context.Background(), ts.URL, alice.Email, password, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice.Seat = seat
+	if alice.LobbyID == earlier {
+		t.Fatalf("alice was seated in the ended lobby %s again", earlier)
+	}
+
+	// Exports name alice by her member ID
+	transcript := func(lobbyID string) map[string]any {
+		return map[string]any{
+			"lobby_id": lobbyID,
+			"messages": []map[string]any{{"username": ts.hub.Lobbies.MemberID(alice.Email), "content": "earlier", "timestamp": time.Now()}},
+		}
+	}
+	tests := []struct {
+		name       string
+		caller     member
+		into, from string
+		want       int
+	}{
+		{"owner from their earlier session", alice, alice.LobbyID, earlier, http.StatusOK},
+		{"outsider into another tenant's lobby", mallory, alice.LobbyID, mallory.LobbyID, http.StatusForbidden},
+		{"owner from a session they weren't in", alice, alice.LobbyID, mallory.LobbyID, http.StatusForbidden},
+		{"owner from an unknown session", alice, alice.LobbyID, "lobby-01J00000000000000000000000", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, body := ts.call(t, tt.caller, "POST", "/api/lobbies/"+tt.into+"/import", transcript(tt.from)); status != tt.want {
+				t.Errorf("import got %d, want %d: %s", status, tt.want, body)
+			}
+		})
+	}
+
+	// The import stores her by email again
+	imported := ts.hub.Lobbies.GetLobby(alice.LobbyID).GetImportedContext()
+	if len(imported) != 1 || imported[0].Username != alice.Email {
+		t.Errorf("imported context = %+v, want alice's message by email", imported)
+	}
+}
+
+func TestLobbyReadsNameMembersByID(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.seat(t, "acme", "alice")
+	ts.seat(t, "acme", "bob")
+
+	// alice says hello, shares an idea and votes for it, and tags the hello
+	// as an action item
+	received := make(chan models.Message, 3)
+	conn, err := client.Connect(context.Background(), ts.URL, alice.Seat, client.Options{OnMessage: func(msg models.Message) {
+		received <- msg
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	next := func() models.Message {
+		t.Helper()
+		select {
+		case msg := <-received:
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("alice's message never came back")
+		}
+		return models.Message{}
+	}
+	conn.SendMessage("hello everyone")
+	hello := next()
+	conn.Send(models.Message{Action: models.MessageTypeIdea, Content: "an idea"})
+	idea := next()
+	conn.Send(models.Message{Action: models.MessageTypeVote, TargetSeq: idea.Seq, Vote: 1})
+	// The lobby handles frames in order, so the vote counts by the echo
+	conn.SendMessage("voted")
+	next()
+	path := "/api/lobbies/" + alice.LobbyID
+	if status, body := ts.call(t, alice, "POST", path+"/actions", map[string]any{"message_ids": []string{hello.MessageID}}); status != http.StatusOK {
+		t.Fatalf("tagging an action item got %d: %s", status, body)
+	}
+
+	aliceID := ts.hub.Lobbies.MemberID(alice.Email)
+	check := func(t *testing.T, read string) {
+		t.Helper()
+		status, body := ts.get(t, alice, path+read)
+		if status != http.StatusOK {
+			t.Fatalf("got %d: %s", status, body)
+		}
+		if strings.Contains(string(body), "@acme.test") {
+			t.Errorf("answer names members by email: %s", body)
+		}
+		// Text exports name her by display name
+		if !strings.Contains(string(body), aliceID) && !strings.Contains(string(body), "alice:") {
+			t.Errorf("answer doesn't name alice: %s", body)
+		}
+	}
+	reads := []string{
+		"/history",
+		"/search?q=hello",
+		fmt.Sprintf("/threads/%d", hello.Seq),
+		"/export?format=json",
+		"/export?format=csv",
+		"/export?format=txt",
+		"/top",
+		"/ideas",
+		"/sessions",
+		"/actions",
+		"/actions?format=csv",
+		"/presence",
+	}
+	for _, read := range reads {
+		t.Run(read, func(t *testing.T) { check(t, read) })
+	}
+
+	// The summary and export of the ended session don't either
+	if err := ts.hub.Lobbies.EndLobby(context.Background(), alice.LobbyID, alice.Email); err != nil {
+		t.Fatal(err)
+	}
+	for _, read := range []string{"/summary", "/export?format=json"} {
+		t.Run("ended"+read, func(t *testing.T) { check(t, read) })
+	}
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"chat-integrated/controllers"
+	"chat-integrated/services"
+	"net/http"
+)
+
+type MetricsHandler struct {
+	controller     *controllers.APIController
+	metricsService *services.MetricsService
+}
+
+func NewMetricsHandler(controller *controllers.APIController, metricsService *services.MetricsService) *MetricsHandler {
+	return &MetricsHandler{
+		controller:     controller,
+		metricsService: metricsService,
+	}
+}
+
+func (mh *MetricsHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	mh.metricsService.WritePrometheus(w)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"chat-integrated/controllers"
+	"chat-integrated/services"
+	"net/http"
+)
+
+type ModerationHandler struct {
+	controller        *controllers.APIController
+	moderationService *services.ModerationService
+}
+
+func NewModerationHandler(controller *controllers.APIController, moderationService *services.ModerationService) *ModerationHandler {
+	return &ModerationHandler{
+		controller:        controller,
+		moderationService: moderationService,
+	}
+}
+
+// Violations handles GET /api/admin/moderation/violations and returns the
+// number of masked or blocked messages per user.
+func (mh *ModerationHandler) Violations(w http.ResponseWriter, r *http.Request) {
+	if mh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if !mh.controller.Authorize(w, r, services.ActionAdminModeration) {
+		return
+	}
+
+	if r.Method != "GET" {
+		mh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	mh.controller.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"violations": mh.moderationService.Violations(),
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/controllers"
+	"chat-integrated/services"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// OAuthLogin starts the authorization code flow for /auth/{provider}/login.
+func (ah *AuthHandler) OAuthLogin(w http.ResponseWriter, r *http.Request) {
+	provider, err := ah.oauthService.GetProvider(r.PathValue("provider"))
+	if err != nil {
+		ah.controller.RespondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	state, err := services.GenerateToken()
+	if err != nil {
+		ah.controller.RespondError(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.OAuthStateCookie,
+		Value:    state,
+		Path:     ah.controller.PathPrefix + "/auth/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// Remember the tenant across the provider round trip
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.OAuthTenantCookie,
+		Value:    ah.controller.TenantID(r),
+		Path:     ah.controller.PathPrefix + "/auth/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	log.Printf("🔐 Redirecting to %s for login", provider.Name)
+	http.Redirect(w, r, ah.oauthService.AuthCodeURL(provider, state), http.StatusFound)
+}
+
+// OAuthCallback completes /auth/{provider}/callback: it verifies the state,
+// resolves the verified email, assigns a lobby and issues a session cookie.
+func (ah *AuthHandler) OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	provider, err := ah.oauthService.GetProvider(r.PathValue("provider"))
+	if err != nil {
+		ah.controller.RespondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	stateCookie, err := r.Cookie(config.OAuthStateCookie)
+	if err != nil || stateCookie.Value == "" || stateCookie.Value != r.URL.Query().Get("state") {
+		ah.controller.RespondError(w, http.StatusBadRequest, "Invalid OAuth state")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: config.OAuthStateCookie, Path: ah.controller.PathPrefix + "/auth/", MaxAge: -1})
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		ah.controller.RespondError(w, http.StatusBadRequest, "Missing authorization code")
+		return
+	}
+
+	email, err := ah.oauthService.Exchange(r.Context(), provider, code)
+	if err != nil {
+		log.Printf("❌ OAuth exchange failed for %s: %v", provider.Name, err)
+		ah.controller.RespondError(w, http.StatusUnauthorized, "Login with "+provider.Name+" failed")
+		return
+	}
+
+	log.Printf("📧 OAuth login from: %s (via %s)", email, provider.Name)
+
+	tenantID := config.DefaultTenantID
+	if tenantCookie, err := r.Cookie(config.OAuthTenantCookie); err == nil && controllers.IsValidTenantID(tenantCookie.Value) {
+		tenantID = tenantCookie.Value
+	}
+
+	statusCode, response := ah.joinLobby(email, tenantID, nil, true)
+	if response.Queued {
+		// The UI waits in line with the ticket
+		params := url.Values{}
+		params.Set("queue", response.Ticket)
+		http.Redirect(w, r, ah.controller.PathPrefix+"/?"+params.Encode(), http.StatusFound)
+		return
+	}
+	if !response.Success {
+		ah.controller.RespondJSON(w, statusCode, response)
+		return
+	}
+
+	if !ah.startSession(w, email, provider.Name) {
+		return
+	}
+
+	// Hand the lobby assignment back to the UI
+	params := url.Values{}
+	params.Set("email", response.Email)
+	params.Set("lobby_id", response.LobbyID)
+	http.Redirect(w, r, ah.controller.PathPrefix+"/?"+params.Encode(), http.StatusFound)
+}
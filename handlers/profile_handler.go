@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type ProfileHandler struct {
+	controller     *controllers.APIController
+	lobbyService   *services.LobbyService
+	sessionService *services.SessionService
+}
+
+func NewProfileHandler(controller *controllers.APIController, lobbyService *services.LobbyService, sessionService *services.SessionService) *ProfileHandler {
+	return &ProfileHandler{
+		controller:     controller,
+		lobbyService:   lobbyService,
+		sessionService: sessionService,
+	}
+}
+
+type ProfileRequest struct {
+	// Email names the user when there is no session cookie, which only
+	// OPEN_LOGIN allows
+	Email       string `json:"email,omitempty"`
+	DisplayName string `json:"display_name" openapi:"required"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+}
+
+// UpdateProfile handles PUT /api/profile and sets the caller's display name
+// and avatar. The session cookie is authoritative for who the caller is.
+func (ph *ProfileHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	if ph.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "PUT" {
+		ph.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req ProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ph.controller.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Profiles are kept until replaced, a guest's only as long as its session
+	email := req.Email
+	var ttl time.Duration
+	if cookie, err := r.Cookie(config.SessionCookieName); err == nil {
+		session, err := ph.sessionService.GetSession(cookie.Value)
+		if err != nil {
+			ph.controller.RespondError(w, http.StatusUnauthorized, "Invalid or expired session")
+			return
+		}
+		if email != "" && email != session.Email {
+			ph.controller.RespondError(w, http.StatusForbidden, "Email does not match session")
+			return
+		}
+		email = session.Email
+		if session.Guest {
+			ttl = config.GuestSessionTTL
+		}
+	} else if config.RequireSession || !config.OpenLogin {
+		// Only open logins name themselves by email
+		ph.controller.RespondError(w, http.StatusUnauthorized, "Login required")
+		return
+	}
+
+	if email == "" {
+		ph.controller.RespondError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	saved, err := ph.lobbyService.UpdateProfile(r.Context(), email, models.Profile{
+		DisplayName: req.DisplayName,
+		AvatarURL:   req.AvatarURL,
+	}, ttl)
+	if errors.Is(err, services.ErrInvalidDisplayName) || errors.Is(err, services.ErrInvalidAvatarURL) {
+		ph.controller.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		ph.controller.RespondError(w, http.StatusInternalServerError, "Failed to save profile")
+		return
+	}
+	ph.controller.RespondJSON(w, http.StatusOK, saved)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"chat-integrated/controllers"
+	"chat-integrated/services"
+	"errors"
+	"net/http"
+)
+
+type QueueHandler struct {
+	controller     *controllers.APIController
+	lobbyService   *services.LobbyService
+	sessionService *services.SessionService
+}
+
+func NewQueueHandler(controller *controllers.APIController, lobbyService *services.LobbyService, sessionService *services.SessionService) *QueueHandler {
+	return &QueueHandler{
+		controller:     controller,
+		lobbyService:   lobbyService,
+		sessionService: sessionService,
+	}
+}
+
+// Queue handles GET /api/queue?ticket=..., which reports a waiting user's
+// place in line or the lobby they were admitted to with a reconnect token,
+// and DELETE, which gives the place up.
+func (qh *QueueHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	if qh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	ticket := r.URL.Query().Get("ticket")
+	if ticket == "" {
+		qh.controller.RespondError(w, http.StatusBadRequest, "A ticket is required")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		status, err := qh.lobbyService.CheckQueue(ticket)
+		if errors.Is(err, services.ErrUnknownTicket) {
+			qh.controller.RespondError(w, http.StatusNotFound, "Your place in line expired. Please log in again.")
+			return
+		}
+		if status.Admitted {
+			// The ticket holder took the seat, like a login would
+			token, err := qh.sessionService.CreateReconnectToken(status.Email)
+			if err != nil {
+				qh.controller.RespondError(w, http.StatusInternalServerError, "Failed to create reconnect token")
+				return
+			}
+			ticket, err := qh.sessionService.CreateConnectTicket(status.Email, status.LobbyID)
+			if err != nil {
+				qh.controller.RespondError(w, http.StatusInternalServerError, "Failed to create connect ticket")
+				return
+			}
+			status.ReconnectToken, status.ConnectTicket = token, ticket
+		}
+		qh.controller.RespondJSON(w, http.StatusOK, status)
+
+	case "DELETE":
+		if err := qh.lobbyService.LeaveQueue(ticket); errors.Is(err, services.ErrUnknownTicket) {
+			qh.controller.RespondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		qh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
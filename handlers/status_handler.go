@@ -1,24 +1,43 @@
 package handlers
 
 import (
-	"chat-integrated/config"
 	"chat-integrated/controllers"
 	"chat-integrated/services"
 	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
 )
 
 type StatusHandler struct {
 	controller   *controllers.APIController
 	lobbyService *services.LobbyService
+	store        services.Store
+	startedAt    time.Time
 }
 
-func NewStatusHandler(controller *controllers.APIController, lobbyService *services.LobbyService) *StatusHandler {
+func NewStatusHandler(controller *controllers.APIController, lobbyService *services.LobbyService, store services.Store) *StatusHandler {
 	return &StatusHandler{
 		controller:   controller,
 		lobbyService: lobbyService,
+		store:        store,
+		startedAt:    time.Now(),
 	}
 }
 
+// StatusResponse describes the lobby new users are currently assigned to.
+type StatusResponse struct {
+	CurrentUsers int    `json:"current_users"`
+	MaxUsers     int    `json:"max_users"`
+	LobbyID      string `json:"lobby_id"`
+	// Users is only listed to callers allowed lobby.status, such as a
+	// logged-in user; anyone else gets the counts
+	Users []string `json:"users"`
+	// Message is set when no lobby is accepting users
+	Message  string `json:"message,omitempty"`
+	Degraded bool   `json:"degraded"`
+}
+
 func (sh *StatusHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
 	sh.controller.SetCommonHeaders(w)
 
@@ -27,23 +46,131 @@ func (sh *StatusHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
 
 	if availableLobby == nil {
 		// No available lobby - either all are full or no lobbies exist
-		response := map[string]interface{}{
-			"current_users": 0,
-			"max_users":     config.MaxUsersPerLobby,
-			"lobby_id":      "",
-			"users":         []string{},
-			"message":       "No active lobby available. A session may be in progress.",
-		}
-		sh.controller.RespondJSON(w, http.StatusOK, response)
+		sh.controller.RespondJSON(w, http.StatusOK, StatusResponse{
+			MaxUsers: sh.lobbyService.MaxUsers(),
+			Users:    []string{},
+			Message:  "No active lobby available. A session may be in progress.",
+			Degraded: sh.store.Degraded(),
+		})
 		return
 	}
 
-	response := map[string]interface{}{
-		"current_users": availableLobby.GetActiveUserCount(),
-		"max_users":     config.MaxUsersPerLobby,
-		"lobby_id":      availableLobby.ID,
-		"users":         availableLobby.GetActiveUserList(),
+	users := []string{}
+	if role, _ := sh.controller.RequestRole(r); sh.controller.Policy.Allowed(role, services.ActionLobbyStatus) {
+		users = sh.displayNames(availableLobby.GetActiveUserList())
+	}
+	sh.controller.RespondJSON(w, http.StatusOK, StatusResponse{
+		CurrentUsers: availableLobby.GetActiveUserCount(),
+		MaxUsers:     availableLobby.MaxUsers,
+		LobbyID:      availableLobby.ID,
+		Users:        users,
+		Degraded:     sh.store.Degraded(),
+	})
+}
+
+// displayNames lists users by display name, so even members don't learn
+// who is waiting by email.
+func (sh *StatusHandler) displayNames(emails []string) []string {
+	names := make([]string, len(emails))
+	for i, email := range emails {
+		names[i] = sh.lobbyService.Profile(email).DisplayName
+	}
+	return names
+}
+
+// StatusV2Response describes the whole server: its uptime and build, every
+// live lobby, the open connections and the store.
+type StatusV2Response struct {
+	StartedAt     time.Time     `json:"started_at"`
+	UptimeSeconds int64         `json:"uptime_seconds"`
+	Version       string        `json:"version"`
+	Lobbies       []LobbyStatus `json:"lobbies"`
+	// Members counts the active members of all lobbies, Connections their
+	// open /ws connections
+	Members     int         `json:"members"`
+	Connections int         `json:"connections"`
+	Store       HealthCheck `json:"store"`
+	Degraded    bool        `json:"degraded"`
+}
+
+// LobbyStatus is a lobby as GET /api/v2/status shows it. State is "open"
+// while it takes new users, "full" once its seats are taken and
+// "in_progress" once everyone connected.
+type LobbyStatus struct {
+	LobbyID      string `json:"lobby_id"`
+	TenantID     string `json:"tenant_id"`
+	Topic        string `json:"topic,omitempty"`
+	State        string `json:"state"`
+	CurrentUsers int    `json:"current_users"`
+	MaxUsers     int    `json:"max_users"`
+	Connected    int    `json:"connected"`
+}
+
+// GetStatusV2 handles GET /api/v2/status. Unlike GetStatus it covers every
+// lobby, internal ones aside, and gives counts only.
+func (sh *StatusHandler) GetStatusV2(w http.ResponseWriter, r *http.Request) {
+	sh.controller.SetCommonHeaders(w)
+	if r.Method != "GET" {
+		sh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
 	}
 
+	response := StatusV2Response{
+		StartedAt:     sh.startedAt,
+		UptimeSeconds: int64(time.Since(sh.startedAt).Seconds()),
+		Version:       buildVersion(),
+		Lobbies:       []LobbyStatus{},
+		Store:         runHealthCheck(r.Context(), sh.store.Ping),
+		Degraded:      sh.store.Degraded(),
+	}
+	for _, lobby := range sh.lobbyService.GetLobbies() {
+		state := "full"
+		switch {
+		case lobby.IsWebSocketStarted():
+			state = "in_progress"
+		case lobby.CanAcceptNewUsers():
+			state = "open"
+		}
+		status := LobbyStatus{
+			LobbyID:      lobby.ID,
+			TenantID:     lobby.TenantID,
+			Topic:        lobby.Topic,
+			State:        state,
+			CurrentUsers: lobby.GetActiveUserCount(),
+			MaxUsers:     lobby.MaxUsers,
+			Connected:    lobby.GetConnectedClientCount(),
+		}
+		response.Members += status.CurrentUsers
+		response.Connections += status.Connected
+		response.Lobbies = append(response.Lobbies, status)
+	}
 	sh.controller.RespondJSON(w, http.StatusOK, response)
 }
+
+// buildVersion is the main module's version the binary was built at, or
+// its VCS revision when it was built from a checkout.
+var buildVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+})
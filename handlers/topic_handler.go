@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"chat-integrated/controllers"
+	"chat-integrated/services"
+	"errors"
+	"net/http"
+)
+
+type TopicHandler struct {
+	controller         *controllers.APIController
+	matchmakingService *services.MatchmakingService
+	sessionService     *services.SessionService
+}
+
+func NewTopicHandler(controller *controllers.APIController, matchmakingService *services.MatchmakingService, sessionService *services.SessionService) *TopicHandler {
+	return &TopicHandler{
+		controller:         controller,
+		matchmakingService: matchmakingService,
+		sessionService:     sessionService,
+	}
+}
+
+type TopicsResponse struct {
+	Topics []services.Topic `json:"topics"`
+}
+
+// Topics handles GET /api/topics, which lists the caller's tenant's topics
+// with how full their lobbies are and how many users look for a match.
+func (th *TopicHandler) Topics(w http.ResponseWriter, r *http.Request) {
+	if th.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if r.Method != "GET" {
+		th.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	th.controller.RespondJSON(w, http.StatusOK, TopicsResponse{Topics: th.matchmakingService.Topics(th.controller.TenantID(r))})
+}
+
+// Match handles GET /api/matchmaking?ticket=..., which reports whether a
+// user looking for a match was seated, with a reconnect token, or put in
+// line for the tenant's lobby, and DELETE, which stops looking.
+func (th *TopicHandler) Match(w http.ResponseWriter, r *http.Request) {
+	if th.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	ticket := r.URL.Query().Get("ticket")
+	if ticket == "" {
+		th.controller.RespondError(w, http.StatusBadRequest, "A ticket is required")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		status, err := th.matchmakingService.Status(ticket)
+		if errors.Is(err, services.ErrUnknownMatch) {
+			th.controller.RespondError(w, http.StatusNotFound, "Your matchmaking ticket expired. Please log in again.")
+			return
+		}
+		if status.Admitted {
+			// The ticket holder took the seat, like a login would
+			token, err := th.sessionService.CreateReconnectToken(status.Email)
+			if err != nil {
+				th.controller.RespondError(w, http.StatusInternalServerError, "Failed to create reconnect token")
+				return
+			}
+			ticket, err := th.sessionService.CreateConnectTicket(status.Email, status.LobbyID)
+			if err != nil {
+				th.controller.RespondError(w, http.StatusInternalServerError, "Failed to create connect ticket")
+				return
+			}
+			status.ReconnectToken, status.ConnectTicket = token, ticket
+		}
+		th.controller.RespondJSON(w, http.StatusOK, status)
+
+	case "DELETE":
+		if err := th.matchmakingService.Leave(ticket); errors.Is(err, services.ErrUnknownMatch) {
+			th.controller.RespondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		th.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type WebhookHandler struct {
+	controller     *controllers.APIController
+	webhookService *services.WebhookService
+}
+
+func NewWebhookHandler(controller *controllers.APIController, webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		controller:     controller,
+		webhookService: webhookService,
+	}
+}
+
+// Webhooks handles GET (list) and POST (register) /api/admin/webhooks. The
+// signing secret is only returned by POST.
+func (wh *WebhookHandler) Webhooks(w http.ResponseWriter, r *http.Request) {
+	if wh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if !wh.controller.Authorize(w, r, services.ActionAdminWebhooks) {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		wh.controller.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"webhooks": wh.webhookService.List(),
+		})
+
+	case "POST":
+		var webhook models.Webhook
+		if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+			wh.controller.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		registered, err := wh.webhookService.Register(webhook)
+		if errors.Is(err, services.ErrInvalidWebhookURL) || errors.Is(err, services.ErrUnknownEvent) {
+			wh.controller.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			wh.controller.RespondError(w, http.StatusInternalServerError, "Failed to register webhook")
+			return
+		}
+		wh.controller.RespondJSON(w, http.StatusCreated, registered)
+
+	default:
+		wh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// Delete handles DELETE /api/admin/webhooks/{id}.
+func (wh *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if wh.controller.HandlePreflight(w, r) {
+		return
+	}
+
+	if !wh.controller.Authorize(w, r, services.ActionAdminWebhooks) {
+		return
+	}
+
+	if r.Method != "DELETE" {
+		wh.controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	err := wh.webhookService.Delete(r.PathValue("id"))
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		wh.controller.RespondError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		wh.controller.RespondError(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+	wh.controller.RespondJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
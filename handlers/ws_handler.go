@@ -1,33 +1,93 @@
 package handlers
 
 import (
+	"chat-integrated/config"
 	"chat-integrated/controllers"
+	"chat-integrated/middleware"
 	"chat-integrated/models"
 	"chat-integrated/services"
+	"chat-integrated/telemetry"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type WSHandler struct {
-	controller   *controllers.WSController
-	lobbyService *services.LobbyService
+	controller     *controllers.WSController
+	lobbyService   *services.LobbyService
+	sessionService *services.SessionService
 }
 
-func NewWSHandler(controller *controllers.WSController, lobbyService *services.LobbyService) *WSHandler {
+func NewWSHandler(controller *controllers.WSController, lobbyService *services.LobbyService, sessionService *services.SessionService) *WSHandler {
 	return &WSHandler{
-		controller:   controller,
-		lobbyService: lobbyService,
+		controller:     controller,
+		lobbyService:   lobbyService,
+		sessionService: sessionService,
 	}
 }
 
 func (wh *WSHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	email := r.URL.Query().Get("email")
-	lobbyID := r.URL.Query().Get("lobby_id")
+	// The connect ticket a login issued names the user and the lobby, and
+	// works once: a leaked /ws URL can't be replayed, and nobody can name
+	// whom they connect as
+	ticket, err := wh.sessionService.RedeemConnectTicket(r.URL.Query().Get("ticket"))
+	if errors.Is(err, services.ErrInvalidTicket) {
+		log.Println("❌ WebSocket connection rejected: invalid connect ticket")
+		wh.controller.RespondError(w, http.StatusUnauthorized, "Invalid or expired connect ticket")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to redeem connect ticket: %v", err)
+		wh.controller.RespondError(w, http.StatusServiceUnavailable, "Failed to check the connect ticket")
+		return
+	}
+	email, lobbyID := ticket.Email, ticket.LobbyID
+
+	if config.RequireSession && !wh.hasSession(r, email) {
+		log.Printf("❌ WebSocket connection rejected: session required for %s", email)
+		wh.controller.RespondError(w, http.StatusUnauthorized, "Login required")
+		return
+	}
+
+	// Optional resume point: only messages after last_seq are replayed
+	var lastSeq int64
+	if raw := r.URL.Query().Get("last_seq"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			log.Printf("❌ Invalid last_seq for %s: %q", email, raw)
+			wh.controller.RespondError(w, http.StatusBadRequest, "last_seq must be a non-negative integer")
+			return
+		}
+		lastSeq = parsed
+	}
+
+	// Optional batched replay: the history comes in acked history_batch frames
+	batchedReplay := false
+	switch replay := r.URL.Query().Get("replay"); replay {
+	case "":
+	case "batched":
+		batchedReplay = true
+	default:
+		log.Printf("❌ Invalid replay mode for %s: %q", email, replay)
+		wh.controller.RespondError(w, http.StatusBadRequest, "replay must be batched")
+		return
+	}
 
-	if email == "" || lobbyID == "" {
-		log.Println("❌ WebSocket connection rejected: email or lobby_id missing")
-		http.Error(w, "Email and lobby_id are required", http.StatusBadRequest)
+	// Optional batched writes: queued frames come several to a message
+	batchedWrites := false
+	switch frames := r.URL.Query().Get("frames"); frames {
+	case "":
+	case "batched":
+		batchedWrites = true
+	default:
+		log.Printf("❌ Invalid frames mode for %s: %q", email, frames)
+		wh.controller.RespondError(w, http.StatusBadRequest, "frames must be batched")
 		return
 	}
 
@@ -35,44 +95,91 @@ func (wh *WSHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	lobby := wh.lobbyService.GetLobby(lobbyID)
 	if lobby == nil {
 		log.Printf("❌ Lobby not found: %s", lobbyID)
-		http.Error(w, "Lobby not found", http.StatusNotFound)
+		wh.controller.RespondError(w, http.StatusNotFound, "Lobby not found")
 		return
 	}
 
 	// Check if user is in the lobby
+	if lobby.IsBanned(email) {
+		log.Printf("❌ User was removed from lobby %s: %s", lobbyID, email)
+		wh.controller.RespondCode(w, models.ErrorKicked, services.ErrKickedFromLobby.Error())
+		return
+	}
 	if !lobby.IsUserInLobby(email) {
 		log.Printf("❌ User not in lobby: %s", email)
-		http.Error(w, "User not authorized for this lobby", http.StatusForbidden)
+		wh.controller.RespondError(w, http.StatusForbidden, "User not authorized for this lobby")
+		return
+	}
+
+	// One address may only hold so many connections at once
+	remoteIP := middleware.ClientIP(r)
+	if !wh.lobbyService.AcquireConnection(remoteIP) {
+		wh.controller.RespondCode(w, models.ErrorRateLimited, "Too many connections from your address")
 		return
 	}
 
-	log.Printf("🔌 Attempting WebSocket upgrade for user: %s in lobby: %s", email, lobbyID)
+	requestID := middleware.RequestIDFrom(r.Context())
+	log.Printf("🔌 [%s] Attempting WebSocket upgrade for user: %s in lobby: %s from %s", requestID, email, lobbyID, remoteIP)
 
 	// Upgrade connection to WebSocket
+	_, span := telemetry.Tracer.Start(r.Context(), "ws.upgrade", trace.WithAttributes(attribute.String("lobby.id", lobbyID)))
 	conn, err := wh.controller.UpgradeConnection(w, r)
 	if err != nil {
-		log.Printf("❌ WebSocket upgrade failed for %s: %v", email, err)
+		log.Printf("❌ [%s] WebSocket upgrade failed for %s: %v", requestID, email, err)
+		wh.lobbyService.ReleaseConnection(remoteIP)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
 		return
 	}
+	span.SetAttributes(attribute.String("ws.subprotocol", conn.Subprotocol()))
+	span.End()
 
-	log.Printf("✅ WebSocket upgrade successful for user: %s", email)
+	log.Printf("✅ [%s] WebSocket upgrade successful for user: %s", requestID, email)
 
 	client := &models.Client{
-		Email:    email,
-		LobbyID:  lobbyID,
-		Conn:     conn,
-		Send:     make(chan models.Message, 256),
-		JoinedAt: time.Now(),
+		Email:         email,
+		LobbyID:       lobbyID,
+		Conn:          conn,
+		Send:          make(chan models.Message, 256),
+		JoinedAt:      time.Now(),
+		LastSeq:       lastSeq,
+		Role:          models.RoleUser,
+		Profile:       wh.lobbyService.Profile(email),
+		Encoding:      conn.Subprotocol(),
+		RequestID:     requestID,
+		RemoteIP:      remoteIP,
+		UserAgent:     r.UserAgent(),
+		Rooms:         models.NewRooms(),
+		BatchedReplay: batchedReplay,
+		BatchedWrites: batchedWrites,
+	}
+	if lobby.IsGuest(email) {
+		client.Role = models.RoleGuest
 	}
 
 	// CRITICAL FIX: Start goroutines BEFORE registering
 	// This ensures WritePump is listening when messages are sent
 	go wh.controller.WritePump(client)
-	go wh.controller.ReadPump(client)
+	go func() {
+		wh.controller.ReadPump(client)
+		wh.lobbyService.ReleaseConnection(remoteIP)
+	}()
 
 	// Small delay to ensure goroutines are running
 	time.Sleep(50 * time.Millisecond)
 
 	// Now register the client (this will send welcome messages)
-	wh.lobbyService.Register <- client
+	wh.lobbyService.Register(client)
+}
+
+// hasSession reports whether the request carries a live session cookie of
+// email.
+func (wh *WSHandler) hasSession(r *http.Request, email string) bool {
+	cookie, err := r.Cookie(config.SessionCookieName)
+	if err != nil {
+		return false
+	}
+	session, err := wh.sessionService.GetSession(cookie.Value)
+	return err == nil && session.Email == email
 }
@@ -0,0 +1,105 @@
+// Package lifecycle runs the server under an init system: it reports the
+// starting/ready/draining/stopped states to systemd (sd_notify, watchdog)
+// or to the Windows service control manager, and turns stop requests
+// (signals or service control) into a bounded drain.
+package lifecycle
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type State string
+
+const (
+	StateStarting State = "starting"
+	StateReady    State = "ready"
+	StateDraining State = "draining"
+	StateStopped  State = "stopped"
+)
+
+// Service is what Run supervises.
+type Service interface {
+	// Start brings the service up and returns once it is ready to serve.
+	// An error received on the returned channel stops the service.
+	Start() (<-chan error, error)
+	// Stop drains in-flight work, giving up when ctx expires.
+	Stop(ctx context.Context) error
+}
+
+var (
+	currentState = StateStopped
+	stateMu      sync.RWMutex
+)
+
+// CurrentState returns the lifecycle state of the process.
+func CurrentState() State {
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	return currentState
+}
+
+func setState(state State) {
+	stateMu.Lock()
+	currentState = state
+	stateMu.Unlock()
+
+	log.Printf("🔄 Lifecycle state: %s", state)
+	switch state {
+	case StateReady:
+		notifySystemd("READY=1\nSTATUS=Serving")
+	case StateDraining:
+		notifySystemd("STOPPING=1\nSTATUS=Draining connections")
+	}
+}
+
+// Run starts svc and blocks until it is stopped, either by SIGINT/SIGTERM,
+// by the Windows service control manager or by a runtime error.
+// drainTimeout bounds the time given to Stop.
+func Run(name string, svc Service, drainTimeout time.Duration) error {
+	if handled, err := runAsWindowsService(name, svc, drainTimeout); handled {
+		return err
+	}
+
+	setState(StateStarting)
+	errc, err := svc.Start()
+	if err != nil {
+		setState(StateStopped)
+		return err
+	}
+	setState(StateReady)
+
+	stopWatchdog := startWatchdog()
+	defer stopWatchdog()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var runErr error
+	select {
+	case sig := <-signals:
+		log.Printf("🛑 Received %s, shutting down", sig)
+	case runErr = <-errc:
+		log.Printf("❌ Service failed: %v", runErr)
+	}
+
+	if err := drain(svc, drainTimeout); err != nil && runErr == nil {
+		runErr = err
+	}
+	return runErr
+}
+
+func drain(svc Service, drainTimeout time.Duration) error {
+	setState(StateDraining)
+	defer setState(StateStopped)
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	return svc.Stop(ctx)
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package lifecycle
+
+import "time"
+
+func runAsWindowsService(string, Service, time.Duration) (bool, error) {
+	return false, nil
+}
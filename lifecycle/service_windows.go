@@ -0,0 +1,64 @@
+//go:build windows
+
+package lifecycle
+
+import (
+	"log"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// runAsWindowsService hands control to the service control manager when the
+// process was started as a Windows service.
+func runAsWindowsService(name string, service Service, drainTimeout time.Duration) (bool, error) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return false, nil
+	}
+	return true, svc.Run(name, &windowsHandler{service: service, drainTimeout: drainTimeout})
+}
+
+type windowsHandler struct {
+	service      Service
+	drainTimeout time.Duration
+}
+
+func (h *windowsHandler) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	setState(StateStarting)
+
+	errc, err := h.service.Start()
+	if err != nil {
+		log.Printf("❌ Service failed to start: %v", err)
+		setState(StateStopped)
+		return false, 1
+	}
+	setState(StateReady)
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	var exitCode uint32
+loop:
+	for {
+		select {
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				changes <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				log.Printf("🛑 Service control request %d, shutting down", request.Cmd)
+				break loop
+			}
+		case err := <-errc:
+			log.Printf("❌ Service failed: %v", err)
+			exitCode = 1
+			break loop
+		}
+	}
+
+	changes <- svc.Status{State: svc.StopPending, WaitHint: uint32(h.drainTimeout / time.Millisecond)}
+	if err := drain(h.service, h.drainTimeout); err != nil {
+		log.Printf("⚠️ Drain did not complete: %v", err)
+	}
+	return false, exitCode
+}
@@ -0,0 +1,61 @@
+package lifecycle
+
+import (
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// notifySystemd sends an sd_notify message when running under a systemd
+// unit with Type=notify; it is a no-op otherwise.
+func notifySystemd(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		log.Printf("⚠️ Failed to reach systemd notify socket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		log.Printf("⚠️ Failed to notify systemd: %v", err)
+	}
+}
+
+// startWatchdog pings the systemd watchdog at half the interval configured
+// by WatchdogSec, while the service is ready. It returns a stop function.
+func startWatchdog() func() {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return func() {}
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return func() {}
+	}
+
+	interval := time.Duration(usec) * time.Microsecond / 2
+	log.Printf("🐕 systemd watchdog enabled (ping every %s)", interval)
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if CurrentState() == StateReady {
+					notifySystemd("WATCHDOG=1")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
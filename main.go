@@ -2,44 +2,141 @@ package main
 
 import (
 	"chat-integrated/config"
-	"chat-integrated/controllers"
-	"chat-integrated/handlers"
-	"chat-integrated/services"
+	"chat-integrated/lifecycle"
+	"chat-integrated/middleware"
+	"chat-integrated/server"
+	"chat-integrated/telemetry"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 )
 
-func main() {
-	// Initialize services
-	redisService := services.NewRedisService()
-	defer redisService.Close()
+// chatService adapts the hub and its HTTP server to lifecycle.Service.
+type chatService struct {
+	hub        *server.Hub
+	httpServer *http.Server
+	// redirectServer serves the plain HTTP port when TLS is enabled
+	redirectServer *http.Server
+	// dev seeds the demo lobby once the hub is up
+	dev bool
+}
+
+func (cs *chatService) Start() (<-chan error, error) {
+	addr, httpScheme, wsScheme := config.ServerPort, "http", "ws"
+	var tlsConfig *tls.Config
+	if server.TLSEnabled() {
+		var redirectHandler http.Handler
+		var err error
+		tlsConfig, redirectHandler, err = server.NewTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		cs.redirectServer = &http.Server{Addr: config.ServerPort, Handler: redirectHandler}
+		addr, httpScheme, wsScheme = config.TLSAddr, "https", "wss"
+	}
 
-	lobbyService := services.NewLobbyService(redisService)
-	go lobbyService.Run()
+	// Bind before reporting readiness so init systems only route traffic
+	// once the port is open
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	cs.hub.Start()
+	if cs.dev {
+		if _, err := cs.hub.Lobbies.SeedDemo(context.Background()); err != nil {
+			log.Printf("⚠️ Failed to seed the demo lobby: %v", err)
+		}
+	}
 
-	// Initialize controllers
-	apiController := controllers.NewAPIController(lobbyService)
-	wsController := controllers.NewWSController(lobbyService)
+	errc := make(chan error, 2)
+	serve := func(srv *http.Server, listener net.Listener) {
+		if err := srv.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			errc <- err
+		}
+	}
+	go serve(cs.httpServer, listener)
+
+	if cs.redirectServer != nil {
+		redirectListener, err := net.Listen("tcp", config.ServerPort)
+		if err != nil {
+			cs.httpServer.Close()
+			return nil, err
+		}
+		go serve(cs.redirectServer, redirectListener)
+		fmt.Printf("↪️ Redirecting http://localhost%s to HTTPS\n", config.ServerPort)
+	}
+
+	fmt.Printf("🚀 Integrated Chat Server starting on %s://localhost%s\n", httpScheme, addr)
+	fmt.Printf("📱 Visit %s://localhost%s to access the chat UI\n", httpScheme, addr)
+	fmt.Printf("🔌 WebSocket endpoint: %s://localhost%s/ws?ticket=... (from /api/login)\n", wsScheme, addr)
+	fmt.Printf("🔁 Echo test endpoint: %s://localhost%s/ws-echo\n", wsScheme, addr)
+	if cs.dev {
+		fmt.Println("🧪 Dev mode: in-memory store, log in with any email to join the demo lobby")
+	}
+	return errc, nil
+}
 
-	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(apiController, lobbyService)
-	statusHandler := handlers.NewStatusHandler(apiController, lobbyService)
-	wsHandler := handlers.NewWSHandler(wsController, lobbyService)
+func (cs *chatService) Stop(ctx context.Context) error {
+	if cs.redirectServer != nil {
+		cs.redirectServer.Shutdown(ctx)
+	}
+	err := cs.httpServer.Shutdown(ctx)
+	cs.hub.Shutdown(ctx)
+	return err
+}
+
+// devConfig makes cfg run without external dependencies, for working on the
+// static UI: the memory store, open login, seats that never time out and
+// log lines with their source.
+func devConfig(cfg *server.Config) {
+	cfg.StoreBackend = "memory"
+	cfg.ProbeEnabled = false
+	config.OpenLogin = true
+	config.IdleTimeout = 0
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Lshortfile)
+}
+
+func main() {
+	dev := flag.Bool("dev", false, "run without Redis: in-memory store, a seeded demo lobby and verbose logging")
+	flag.Parse()
 
-	// Serve static files
-	fs := http.FileServer(http.Dir("./static"))
-	http.Handle("/", fs)
+	shutdownTracing, err := telemetry.Setup(context.Background())
+	if err != nil {
+		log.Fatalf("❌ Failed to set up tracing: %v", err)
+	}
 
-	// API routes
-	http.HandleFunc("/api/login", authHandler.Login)
-	http.HandleFunc("/api/status", statusHandler.GetStatus)
+	// Initialize the chat hub and its HTTP routes
+	cfg := server.DefaultConfig()
+	if *dev {
+		devConfig(&cfg)
+	}
+	hub := server.NewHub(cfg)
 
-	// WebSocket route
-	http.HandleFunc("/ws", wsHandler.HandleWebSocket)
+	mux := http.NewServeMux()
+	server.NewServer(hub).Mount(mux)
 
-	fmt.Println("🚀 Integrated Chat Server starting on http://localhost:8080")
-	fmt.Println("📱 Visit http://localhost:8080 to access the chat UI")
-	fmt.Println("🔌 WebSocket endpoint: ws://localhost:8080/ws?email=user@example.com&lobby_id=lobby-123")
-	log.Fatal(http.ListenAndServe(config.ServerPort, nil))
+	service := &chatService{
+		hub:        hub,
+		httpServer: &http.Server{Handler: middleware.Stack(mux)},
+		dev:        *dev,
+	}
+	err = lifecycle.Run(config.ServiceName, service, config.ShutdownTimeout)
+	hub.Close()
+	// Flush the last spans, within the shutdown budget
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("⚠️ Failed to flush traces: %v", err)
+	}
+	cancel()
+	if err != nil {
+		log.Fatalf("❌ Server stopped with error: %v", err)
+	}
 }
@@ -0,0 +1,300 @@
+// Package middleware wraps the HTTP handler of the server: request IDs,
+// tracing, access logs, panic recovery and gzip compression of API
+// responses.
+package middleware
+
+import (
+	"bufio"
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"chat-integrated/telemetry"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Stack wraps next in every middleware, outermost first: request IDs,
+// tracing when config.Tracing is set, access logs, panic recovery, then
+// gzip when config.GzipResponses is set.
+func Stack(next http.Handler) http.Handler {
+	if config.GzipResponses {
+		next = Gzip(next)
+	}
+	next = Recover(next)
+	if config.AccessLog {
+		next = AccessLog(next)
+	}
+	if config.Tracing {
+		next = Trace(next)
+	}
+	return RequestID(next)
+}
+
+type requestIDKey struct{}
+
+// requestIDPattern accepts IDs from a proxy in front of the server as long
+// as they are short and safe to log.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID gives every request an ID, taken from the X-Request-ID header
+// when a proxy already set one, and echoes it in the response.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(config.RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(config.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestIDFrom returns the ID of the request ctx belongs to, "" outside
+// of RequestID.
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ClientIP returns the address a request came from, without its port.
+// Behind a proxy, with config.TrustProxy, that is the last address of
+// X-Forwarded-For: the one the proxy appended, which the client can't forge.
+func ClientIP(r *http.Request) string {
+	if config.TrustProxy {
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if ip := net.ParseIP(strings.TrimSpace(forwarded[len(forwarded)-1])); ip != nil {
+			return ip.String()
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// Trace runs each request in a server span, continuing the trace of a
+// traceparent header. The span is named after the route once the mux has
+// matched it.
+func Trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := telemetry.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := telemetry.Tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("request.id", RequestIDFrom(r.Context())),
+		))
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(recorder, r)
+
+		if r.Pattern != "" {
+			route := r.Pattern
+			if _, path, found := strings.Cut(route, " "); found {
+				route = path
+			}
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// AccessLog logs each request once it was answered, with its status, size
+// and latency. WebSocket upgrades are logged when the handshake is done.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			log.Printf("📥 [%s] %s %s %d %dB %s", RequestIDFrom(r.Context()), r.Method, r.URL.Path, status, recorder.written, time.Since(start).Round(time.Microsecond))
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// Recover turns a panicking handler into a 500 JSON error, logged with its
+// stack, instead of a dropped connection.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				// Deliberately aborted; net/http handles it quietly
+				panic(recovered)
+			}
+
+			id := RequestIDFrom(r.Context())
+			log.Printf("💥 [%s] Panic serving %s %s: %v\n%s", id, r.Method, r.URL.Path, recovered, debug.Stack())
+			if recorder.status != 0 || recorder.hijacked {
+				// Too late for an error response
+				return
+			}
+			w.Header().Del("Content-Encoding")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(models.ErrorResponse{Error: "Internal server error", Code: models.ErrorInternal, RequestID: id})
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// Gzip compresses the responses of /api/ endpoints for clients that
+// accept it. Other paths, like attachments and WebSocket upgrades, pass
+// through.
+func Gzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, "/api/") || !acceptsGzip(r) || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		gw := &gzipWriter{ResponseWriter: w}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(coding), ";")
+		if strings.EqualFold(name, "gzip") {
+			return true
+		}
+	}
+	return false
+}
+
+// statusRecorder notes the status and size of a response. It passes
+// Hijack and Flush through so WebSocket upgrades and streamed responses
+// still work.
+type statusRecorder struct {
+	http.ResponseWriter
+	status   int
+	written  int64
+	hijacked bool
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(data []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(data)
+	sr.written += int64(n)
+	return n, err
+}
+
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := sr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		sr.hijacked = true
+		sr.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (sr *statusRecorder) Flush() {
+	if flusher, ok := sr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// gzipWriter compresses the body once the handler writes one; a response
+// without a body, like a 204, is left alone.
+type gzipWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (gw *gzipWriter) WriteHeader(status int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+	if status != http.StatusNoContent && status != http.StatusNotModified && gw.Header().Get("Content-Encoding") == "" {
+		gw.Header().Set("Content-Encoding", "gzip")
+		gw.Header().Del("Content-Length")
+		gw.gz = gzip.NewWriter(gw.ResponseWriter)
+	}
+	gw.ResponseWriter.WriteHeader(status)
+}
+
+func (gw *gzipWriter) Write(data []byte) (int, error) {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+	if gw.gz == nil {
+		return gw.ResponseWriter.Write(data)
+	}
+	return gw.gz.Write(data)
+}
+
+func (gw *gzipWriter) Flush() {
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	if flusher, ok := gw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (gw *gzipWriter) Close() {
+	if gw.gz != nil {
+		gw.gz.Close()
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Account is a registered user, who logs in with a password or a link
+// mailed to their email.
+type Account struct {
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+	// PasswordChangedAt is when the password was last reset
+	PasswordChangedAt time.Time `json:"password_changed_at,omitzero"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ActionItem is a message a moderator tagged as something to follow up on
+// after the session, such as a ticket to open.
+type ActionItem struct {
+	MessageID string    `json:"message_id" proto:"1"`
+	Seq       int64     `json:"seq" proto:"2"`
+	Username  string    `json:"username" proto:"3"`
+	Content   string    `json:"content" proto:"4"`
+	TaggedBy  string    `json:"tagged_by" proto:"5"`
+	TaggedAt  time.Time `json:"tagged_at" proto:"6"`
+}
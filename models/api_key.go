@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// APIKey lets a service call the REST API as Role without a login. Only
+// the SHA-256 hash of the key is kept.
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      Role      `json:"role"`
+	KeyHash   string    `json:"key_hash,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// TranscriptArchive locates the transcript of an ended session in the
+// object store it was archived to, as gzipped JSON lines.
+type TranscriptArchive struct {
+	Backend string `json:"backend"`
+	Bucket  string `json:"bucket"`
+	Key     string `json:"key"`
+	// Messages is how many messages were archived, Size the compressed
+	// length in bytes
+	Messages   int       `json:"messages"`
+	Size       int64     `json:"size"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Attachment is an uploaded file stored under its SHA-256 content address.
+type Attachment struct {
+	Hash         string    `json:"hash"`
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	RefCount     int       `json:"ref_count"`
+	UploadedAt   time.Time `json:"uploaded_at"`
+	// Owners counts the references each uploader or emoji pack holds; they
+	// add up to RefCount. It is kept in the store and left out of API
+	// answers.
+	Owners map[string]int `json:"owners,omitempty"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Audit actions
+const (
+	AuditLobbyCreated    = "lobby_created"
+	AuditLobbyEnded      = "lobby_ended"
+	AuditLobbyArchived   = "lobby_archived"
+	AuditMemberKicked    = "member_kicked"
+	AuditRoleChanged     = "role_changed"
+	AuditMessagePinned   = "message_pinned"
+	AuditMessageUnpinned = "message_unpinned"
+	AuditSettingsChanged = "settings_changed"
+	AuditMessageBlocked  = "message_blocked"
+	AuditSpamWarned      = "spam_warned"
+	AuditMemberMuted     = "member_muted"
+	AuditAnnouncement    = "announcement"
+	AuditInviteCreated   = "invite_created"
+	AuditInviteRedeemed  = "invite_redeemed"
+	AuditHistoryPruned   = "history_pruned"
+	AuditActionTagged    = "action_item_tagged"
+	AuditActionUntagged  = "action_item_untagged"
+	AuditMessagesExpired = "messages_expired"
+	AuditLobbyCooldown   = "lobby_cooldown"
+)
+
+// AuditActorSystem is the actor of events nobody asked for, such as idle
+// lobbies being archived.
+const AuditActorSystem = "system"
+
+// AuditEvent is an entry of the audit log: who did what to which lobby and
+// member. Details depend on the action.
+type AuditEvent struct {
+	Time     time.Time   `json:"time"`
+	Action   string      `json:"action"`
+	LobbyID  string      `json:"lobby_id,omitempty"`
+	TenantID string      `json:"tenant_id,omitempty"`
+	Actor    string      `json:"actor"`
+	Target   string      `json:"target,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+}
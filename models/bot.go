@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Bot is an external integration allowed to post into lobbies through the
+// bot API. Only the SHA-256 hash of its API key is kept; an empty LobbyID
+// lets the bot post into any lobby of its tenant.
+type Bot struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	TenantID  string    `json:"tenant_id"`
+	LobbyID   string    `json:"lobby_id,omitempty"`
+	KeyHash   string    `json:"key_hash,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Branding is the per-tenant look and naming used in system messages and
+// the embedded UI.
+type Branding struct {
+	TenantID     string    `json:"tenant_id"`
+	ProductName  string    `json:"product_name"`
+	LogoURL      string    `json:"logo_url,omitempty"`
+	PrimaryColor string    `json:"primary_color"`
+	AccentColor  string    `json:"accent_color"`
+	UpdatedAt    time.Time `json:"updated_at,omitempty"`
+}
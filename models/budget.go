@@ -0,0 +1,121 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// BudgetMilestones are the shares of a time budget, in percent, announced
+// once each as a session passes them.
+var BudgetMilestones = []int{50, 90, 100}
+
+// Budget is a facilitator's time budget for a session, with an optional
+// hourly rate per participant to put a cost on it.
+type Budget struct {
+	Duration   time.Duration `json:"duration"`
+	HourlyRate float64       `json:"hourly_rate,omitempty"`
+	// Elapsed and PersonHours accrue while the lobby is live, so time the
+	// server was down is not counted
+	Elapsed     time.Duration `json:"elapsed"`
+	PersonHours float64       `json:"person_hours"`
+	// Milestone is the highest of BudgetMilestones already announced
+	Milestone int `json:"milestone,omitempty"`
+	lastTick  time.Time
+}
+
+// BudgetStatus is how a budget is reported to clients and webhooks.
+type BudgetStatus struct {
+	BudgetSeconds  int64   `json:"budget_seconds" proto:"1"`
+	ElapsedSeconds int64   `json:"elapsed_seconds" proto:"2"`
+	Percent        int     `json:"percent" proto:"3"`
+	HourlyRate     float64 `json:"hourly_rate,omitempty" proto:"4"`
+	Cost           float64 `json:"cost,omitempty" proto:"5"`
+	// Milestone is set on budget_milestone broadcasts
+	Milestone int `json:"milestone,omitempty" proto:"6"`
+}
+
+func (b *Budget) percent() int {
+	return int(b.Elapsed * 100 / b.Duration)
+}
+
+func (b *Budget) status() BudgetStatus {
+	return BudgetStatus{
+		BudgetSeconds:  int64(b.Duration / time.Second),
+		ElapsedSeconds: int64(b.Elapsed / time.Second),
+		Percent:        b.percent(),
+		HourlyRate:     b.HourlyRate,
+		Cost:           math.Round(b.PersonHours*b.HourlyRate*100) / 100,
+	}
+}
+
+// passedMilestone returns the highest milestone the budget has reached.
+func (b *Budget) passedMilestone() int {
+	passed := 0
+	for _, milestone := range BudgetMilestones {
+		if b.percent() >= milestone {
+			passed = milestone
+		}
+	}
+	return passed
+}
+
+// SetBudget gives the lobby a time budget, or removes it for a duration of
+// 0. A session that already had one keeps the time it used, and milestones
+// it has passed under the new duration are not announced again.
+func (l *Lobby) SetBudget(duration time.Duration, hourlyRate float64) *BudgetStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if duration <= 0 {
+		l.Budget = nil
+		return nil
+	}
+	if l.Budget == nil {
+		l.Budget = &Budget{}
+	}
+	l.Budget.Duration = duration
+	l.Budget.HourlyRate = hourlyRate
+	l.Budget.Milestone = l.Budget.passedMilestone()
+	status := l.Budget.status()
+	return &status
+}
+
+// TickBudget accrues the time since the previous tick, with participants
+// people connected, and returns the budget's status with the milestone it
+// just passed, or 0 if it passed none.
+func (l *Lobby) TickBudget(now time.Time, participants int) (*BudgetStatus, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.Budget == nil {
+		return nil, 0
+	}
+	b := l.Budget
+	if !b.lastTick.IsZero() && now.After(b.lastTick) {
+		elapsed := now.Sub(b.lastTick)
+		b.Elapsed += elapsed
+		b.PersonHours += float64(participants) * elapsed.Hours()
+	}
+	b.lastTick = now
+
+	status := b.status()
+	passed := b.passedMilestone()
+	if passed <= b.Milestone {
+		return &status, 0
+	}
+	b.Milestone = passed
+	status.Milestone = passed
+	return &status, passed
+}
+
+// GetBudget returns the status of the lobby's budget, or nil if it has none.
+func (l *Lobby) GetBudget() *BudgetStatus {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.Budget == nil {
+		return nil
+	}
+	status := l.Budget.status()
+	return &status
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Channel is a whisper group: a sub-channel of a lobby that some of its
+// members opened, whose messages only its Members get. Members are emails
+// inside the server and member IDs in frames.
+type Channel struct {
+	ID        string    `json:"channel_id" proto:"1"`
+	Name      string    `json:"name" proto:"2"`
+	Members   []string  `json:"members" proto:"3"`
+	CreatedBy string    `json:"created_by,omitempty" proto:"4"`
+	CreatedAt time.Time `json:"created_at" proto:"5"`
+}
+
+// HasMember reports whether email is in the channel.
+func (c Channel) HasMember(email string) bool {
+	return slices.Contains(c.Members, email)
+}
+
+func (c Channel) clone() Channel {
+	c.Members = append([]string(nil), c.Members...)
+	return c
+}
+
+// AddChannel opens a channel in the lobby.
+func (l *Lobby) AddChannel(channel Channel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Channels = append(l.Channels, channel.clone())
+}
+
+// GetChannel returns the channel with the given ID.
+func (l *Lobby) GetChannel(id string) (Channel, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, channel := range l.Channels {
+		if channel.ID == id {
+			return channel.clone(), true
+		}
+	}
+	return Channel{}, false
+}
+
+// GetChannels returns every channel of the lobby, the oldest first.
+func (l *Lobby) GetChannels() []Channel {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	channels := make([]Channel, len(l.Channels))
+	for i, channel := range l.Channels {
+		channels[i] = channel.clone()
+	}
+	return channels
+}
+
+// ChannelsOf returns the channels email is in, the oldest first.
+func (l *Lobby) ChannelsOf(email string) []Channel {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var channels []Channel
+	for _, channel := range l.Channels {
+		if channel.HasMember(email) {
+			channels = append(channels, channel.clone())
+		}
+	}
+	return channels
+}
+
+// LeaveChannel takes email out of a channel and returns what is left of
+// it; a channel its last member leaves is closed. It reports false if
+// email wasn't in the channel.
+func (l *Lobby) LeaveChannel(id, email string) (Channel, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, channel := range l.Channels {
+		if channel.ID == id && channel.HasMember(email) {
+			left := channel.clone()
+			left.Members = slices.DeleteFunc(left.Members, func(member string) bool { return member == email })
+			l.leaveChannelsLocked(email, id)
+			return left, true
+		}
+	}
+	return Channel{}, false
+}
+
+// leaveChannelsLocked takes email out of the channels named by ids, or of
+// every channel for none, and closes those left empty. The caller holds
+// l.mu.
+func (l *Lobby) leaveChannelsLocked(email string, ids ...string) {
+	kept := l.Channels[:0]
+	for _, channel := range l.Channels {
+		if len(ids) == 0 || slices.Contains(ids, channel.ID) {
+			channel.Members = slices.DeleteFunc(channel.Members, func(member string) bool { return member == email })
+		}
+		if len(channel.Members) > 0 {
+			kept = append(kept, channel)
+		}
+	}
+	clear(l.Channels[len(kept):])
+	l.Channels = kept
+}
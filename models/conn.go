@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/binary"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WebSocket message types, as numbered by the RFC 6455 opcodes.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+)
+
+// WebSocket close codes of the disconnects that aren't errors; a connection
+// closed for an error gets its ErrorCode's CloseCode.
+const (
+	// CloseNormal ends a connection its client closed
+	CloseNormal = 1000
+	// CloseGoingAway ends connections when the server shuts down
+	CloseGoingAway = 1001
+	// CloseLobbyEnded ends the connections of a lobby that ended
+	CloseLobbyEnded = 4110
+	// CloseLoggedInElsewhere ends a user's older connection when they
+	// connect again, from another browser or device or after a network
+	// change: a user has one connection per lobby
+	CloseLoggedInElsewhere = 4111
+	// CloseTooSlow ends a connection that couldn't keep up with its lobby
+	CloseTooSlow = 4112
+)
+
+// maxCloseReason is what is left of a control frame's 125 byte payload
+// after the close code.
+const maxCloseReason = 123
+
+// FormatCloseMessage builds the payload of a close frame, cutting reason to
+// fit.
+func FormatCloseMessage(code int, reason string) []byte {
+	if len(reason) > maxCloseReason {
+		reason = strings.ToValidUTF8(reason[:maxCloseReason], "")
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return append(payload, reason...)
+}
+
+// Conn is a client's WebSocket connection. gorilla/websocket's *Conn
+// satisfies it as is; other libraries, or fakes, plug in behind a small
+// adapter.
+type Conn interface {
+	ReadMessage() (messageType int, data []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	SetReadLimit(limit int64)
+	// Subprotocol is the one negotiated in the handshake, "" if none
+	Subprotocol() string
+	Close() error
+}
+
+// Upgrader turns an HTTP request into a WebSocket Conn.
+type Upgrader interface {
+	Upgrade(w http.ResponseWriter, r *http.Request) (Conn, error)
+}
@@ -0,0 +1,33 @@
+package models
+
+// ContentFormat tells clients how to render a chat message's content;
+// empty means plain.
+type ContentFormat string
+
+const (
+	// ContentFormatPlain is text shown as is
+	ContentFormatPlain ContentFormat = "plain"
+	// ContentFormatMarkdown is rendered as Markdown; the server strips raw
+	// HTML and unsafe links from it
+	ContentFormatMarkdown ContentFormat = "markdown"
+	// ContentFormatCode is a code block shown verbatim in a fixed font
+	ContentFormatCode ContentFormat = "code"
+)
+
+// Valid reports whether the format is one clients know, or unset.
+func (f ContentFormat) Valid() bool {
+	switch f {
+	case "", ContentFormatPlain, ContentFormatMarkdown, ContentFormatCode:
+		return true
+	}
+	return false
+}
+
+// LinkPreview is the OpenGraph metadata of a page a chat message links to.
+type LinkPreview struct {
+	URL         string `json:"url" proto:"1"`
+	Title       string `json:"title,omitempty" proto:"2"`
+	Description string `json:"description,omitempty" proto:"3"`
+	Image       string `json:"image,omitempty" proto:"4"`
+	SiteName    string `json:"site_name,omitempty" proto:"5"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// EmojiScope is where a custom emoji is registered. A lobby's own emoji
+// take precedence over its tenant's of the same name.
+type EmojiScope string
+
+const (
+	EmojiScopeTenant EmojiScope = "tenant"
+	EmojiScopeLobby  EmojiScope = "lobby"
+)
+
+// Emoji is a custom image used as :Name: in chat and reactions. The image
+// is an attachment the emoji holds one reference to.
+type Emoji struct {
+	Name string `json:"name" proto:"1"`
+	URL  string `json:"url" proto:"2"`
+	Hash string `json:"hash" proto:"3"`
+	// ContentType lets the image be served again after a restart
+	ContentType string     `json:"content_type" proto:"4"`
+	Scope       EmojiScope `json:"scope" proto:"5"`
+	CreatedBy   string     `json:"created_by,omitempty" proto:"6"`
+	CreatedAt   time.Time  `json:"created_at" proto:"7"`
+}
@@ -0,0 +1,111 @@
+package models
+
+import "net/http"
+
+// ErrorCode identifies an error for clients to branch on. The message that
+// comes with it is for people and may change.
+type ErrorCode string
+
+const (
+	ErrorBadRequest       ErrorCode = "BAD_REQUEST"
+	ErrorUnauthorized     ErrorCode = "UNAUTHORIZED"
+	ErrorForbidden        ErrorCode = "FORBIDDEN"
+	ErrorNotFound         ErrorCode = "NOT_FOUND"
+	ErrorMethodNotAllowed ErrorCode = "METHOD_NOT_ALLOWED"
+	ErrorConflict         ErrorCode = "CONFLICT"
+	ErrorTooLarge         ErrorCode = "TOO_LARGE"
+	ErrorNotStarted       ErrorCode = "NOT_STARTED"
+	ErrorRateLimited      ErrorCode = "RATE_LIMITED"
+	ErrorLobbyFull        ErrorCode = "LOBBY_FULL"
+	ErrorSessionActive    ErrorCode = "SESSION_ACTIVE"
+	ErrorKicked           ErrorCode = "KICKED"
+	ErrorReadOnly         ErrorCode = "READ_ONLY"
+	ErrorSpam             ErrorCode = "SPAM"
+	ErrorMuted            ErrorCode = "MUTED"
+	ErrorInvalidInvite    ErrorCode = "INVALID_INVITE"
+	ErrorInfected         ErrorCode = "ATTACHMENT_INFECTED"
+	ErrorUnavailable      ErrorCode = "UNAVAILABLE"
+	ErrorInternal         ErrorCode = "INTERNAL"
+)
+
+// errorCodes maps each code to its HTTP status and WebSocket close code.
+// Close codes are 4000 plus the HTTP status, or 41xx for the lobby's own
+// errors, except where RFC 6455 has one.
+var errorCodes = map[ErrorCode]struct{ status, close int }{
+	ErrorBadRequest:       {http.StatusBadRequest, 4400},
+	ErrorUnauthorized:     {http.StatusUnauthorized, 4401},
+	ErrorForbidden:        {http.StatusForbidden, 4403},
+	ErrorNotFound:         {http.StatusNotFound, 4404},
+	ErrorMethodNotAllowed: {http.StatusMethodNotAllowed, 4405},
+	ErrorConflict:         {http.StatusConflict, 4409},
+	ErrorTooLarge:         {http.StatusRequestEntityTooLarge, 1009},
+	ErrorNotStarted:       {http.StatusTooEarly, 4425},
+	ErrorRateLimited:      {http.StatusTooManyRequests, 4429},
+	ErrorLobbyFull:        {http.StatusServiceUnavailable, 4100},
+	ErrorSessionActive:    {http.StatusConflict, 4101},
+	ErrorKicked:           {http.StatusForbidden, 4102},
+	ErrorReadOnly:         {http.StatusForbidden, 4103},
+	ErrorSpam:             {http.StatusTooManyRequests, 4104},
+	ErrorMuted:            {http.StatusForbidden, 4105},
+	ErrorInvalidInvite:    {http.StatusForbidden, 4106},
+	ErrorInfected:         {http.StatusUnprocessableEntity, 4422},
+	ErrorUnavailable:      {http.StatusServiceUnavailable, 1013},
+	ErrorInternal:         {http.StatusInternalServerError, 1011},
+}
+
+// ErrorCodeForStatus is the generic code of an HTTP error status.
+func ErrorCodeForStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrorBadRequest
+	case http.StatusUnauthorized:
+		return ErrorUnauthorized
+	case http.StatusForbidden:
+		return ErrorForbidden
+	case http.StatusNotFound:
+		return ErrorNotFound
+	case http.StatusMethodNotAllowed:
+		return ErrorMethodNotAllowed
+	case http.StatusConflict:
+		return ErrorConflict
+	case http.StatusRequestEntityTooLarge:
+		return ErrorTooLarge
+	case http.StatusTooEarly:
+		return ErrorNotStarted
+	case http.StatusTooManyRequests:
+		return ErrorRateLimited
+	case http.StatusServiceUnavailable:
+		return ErrorUnavailable
+	}
+	if status >= 500 {
+		return ErrorInternal
+	}
+	return ErrorBadRequest
+}
+
+// Status is the HTTP status the code is answered with.
+func (c ErrorCode) Status() int {
+	if codes, ok := errorCodes[c]; ok {
+		return codes.status
+	}
+	return http.StatusBadRequest
+}
+
+// CloseCode is the WebSocket close code a connection closed for the error
+// gets.
+func (c ErrorCode) CloseCode() int {
+	if codes, ok := errorCodes[c]; ok {
+		return codes.close
+	}
+	return 4400
+}
+
+// ErrorResponse is the body of every REST error: a Code to branch on and
+// an Error message for people.
+type ErrorResponse struct {
+	Error string    `json:"error"`
+	Code  ErrorCode `json:"code"`
+	// RequestID names the request in the server's logs, for errors a
+	// client can't fix itself
+	RequestID string `json:"request_id,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Idea is an idea card of a lobby with its votes. Score is its upvotes
+// minus its downvotes; reactions don't count.
+type Idea struct {
+	IdeaID    string    `json:"idea_id"`
+	Seq       int64     `json:"seq"`
+	Username  string    `json:"username"`
+	Content   string    `json:"content"`
+	Score     int       `json:"score"`
+	Upvotes   int       `json:"upvotes"`
+	Downvotes int       `json:"downvotes"`
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Invite is what an invite token carries, signed by the server: the lobby
+// it lets its holder into, who created it and until when. A single use
+// invite is spent by its first redemption.
+type Invite struct {
+	ID        string    `json:"id"`
+	LobbyID   string    `json:"lobby_id"`
+	CreatedBy string    `json:"created_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+	SingleUse bool      `json:"single_use,omitempty"`
+}
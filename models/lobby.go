@@ -1,42 +1,319 @@
 package models
 
 import (
+	"slices"
+	"sort"
 	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 type Client struct {
 	Email    string
 	LobbyID  string
-	Conn     *websocket.Conn
+	Conn     Conn
 	Send     chan Message
 	JoinedAt time.Time
+	// LastSeq is the highest sequence number the client already has; only
+	// messages after it are replayed on register.
+	LastSeq int64
+	// Role is checked against the authorization policy for every frame
+	Role Role
+	// Profile is the client's display name and avatar as of connecting
+	Profile Profile
+	// Encoding is the negotiated subprotocol, which names the codec frames
+	// are sent in
+	Encoding string
+	// RequestID is the ID of the upgrade request, for log lines about the
+	// connection
+	RequestID string
+	// RemoteIP and UserAgent are where the connection came from, as of the
+	// upgrade; JoinedAt is when it was made
+	RemoteIP  string
+	UserAgent string
+	// Rooms are the other lobbies a /ws connection follows; nil for
+	// connections that can't join others
+	Rooms *Rooms
+	// BatchedReplay is set for connections that asked for their history in
+	// acked history_batch frames; Replay is their catch-up while it lasts
+	BatchedReplay bool
+	Replay        *HistoryReplay
+	// BatchedWrites is set for connections that take several frames in one
+	// array message
+	BatchedWrites bool
+
+	// The round trip the client last measured with a ping, and whether it
+	// was above the lag threshold
+	latencyMu  sync.Mutex
+	rtt        time.Duration
+	measuredAt time.Time
+	lagging    bool
+
+	// The close frame the connection ends with, the first one set winning,
+	// and whether its writer stopped
+	closeMu     sync.Mutex
+	closeCode   int
+	closeReason string
+	gone        chan struct{}
+	isGone      bool
+}
+
+// Rooms are the lobbies a connection joined besides its own. Each has a
+// Client of its own on the connection's Conn, whose frames are handed to
+// Out for the connection's writer.
+type Rooms struct {
+	mu      sync.Mutex
+	clients map[string]*Client
+	closed  bool
+	// Out carries the joined lobbies' frames to the connection's writer;
+	// Done is closed once the connection is gone
+	Out  chan Message
+	Done chan struct{}
+}
+
+func NewRooms() *Rooms {
+	return &Rooms{
+		clients: make(map[string]*Client),
+		Out:     make(chan Message),
+		Done:    make(chan struct{}),
+	}
+}
+
+// Get returns the connection's client in a joined lobby, or nil.
+func (r *Rooms) Get(lobbyID string) *Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.clients[lobbyID]
+}
+
+// Add records a joined lobby's client, reporting false if the lobby was
+// already joined or the connection is gone.
+func (r *Rooms) Add(client *Client) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, joined := r.clients[client.LobbyID]; joined || r.closed {
+		return false
+	}
+	r.clients[client.LobbyID] = client
+	return true
+}
+
+// Remove forgets a joined lobby's client, reporting false if it was no
+// longer joined.
+func (r *Rooms) Remove(client *Client) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.clients[client.LobbyID] != client {
+		return false
+	}
+	delete(r.clients, client.LobbyID)
+	return true
+}
+
+// Close marks the connection gone and returns the clients still joined.
+func (r *Rooms) Close() []*Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	close(r.Done)
+	clients := make([]*Client, 0, len(r.clients))
+	for _, client := range r.clients {
+		clients = append(clients, client)
+	}
+	r.clients = make(map[string]*Client)
+	return clients
+}
+
+// RecordRTT saves a round-trip time the client measured and reports
+// whether it just took the client above lagAfter; 0 never flags it.
+func (c *Client) RecordRTT(rtt, lagAfter time.Duration, now time.Time) bool {
+	c.latencyMu.Lock()
+	defer c.latencyMu.Unlock()
+
+	wasLagging := c.lagging
+	c.rtt, c.measuredAt = rtt, now
+	c.lagging = lagAfter > 0 && rtt > lagAfter
+	return c.lagging && !wasLagging
+}
+
+// Latency returns the client's last round-trip time, when it was measured
+// (zero if it never was) and whether it is lagging.
+func (c *Client) Latency() (time.Duration, time.Time, bool) {
+	c.latencyMu.Lock()
+	defer c.latencyMu.Unlock()
+	return c.rtt, c.measuredAt, c.lagging
+}
+
+// CloseWith closes the client's Send channel, after which its writer ends
+// the connection with a close frame carrying code and reason. Like
+// close(Send), it may only be called once, by whoever detached the client.
+func (c *Client) CloseWith(code int, reason string) {
+	c.SetCloseStatus(code, reason)
+	close(c.Send)
+}
+
+// SetCloseStatus picks the close frame of a connection that is about to
+// end, unless one was picked already.
+func (c *Client) SetCloseStatus(code int, reason string) {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+	if c.closeCode == 0 {
+		c.closeCode, c.closeReason = code, reason
+	}
+}
+
+// CloseStatus returns the code and reason the connection ends with,
+// CloseNormal if none was picked.
+func (c *Client) CloseStatus() (int, string) {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+	if c.closeCode == 0 {
+		return CloseNormal, ""
+	}
+	return c.closeCode, c.closeReason
+}
+
+// MarkGone tells those waiting on Gone that the connection's writer
+// stopped.
+func (c *Client) MarkGone() {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+	if !c.isGone {
+		c.isGone = true
+		if c.gone != nil {
+			close(c.gone)
+		}
+	}
+}
+
+// Gone returns a channel that is closed once the connection's writer
+// stopped, having written its close frame if it could.
+func (c *Client) Gone() <-chan struct{} {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+	if c.gone == nil {
+		c.gone = make(chan struct{})
+		if c.isGone {
+			close(c.gone)
+		}
+	}
+	return c.gone
 }
 
+// SystemEvents is how a lobby announces users joining and leaving.
+type SystemEvents string
+
+const (
+	// SystemEventsAll broadcasts every join and leave
+	SystemEventsAll SystemEvents = "all"
+	// SystemEventsDigest replaces them with a periodic roster digest, for
+	// large or drop-in lobbies
+	SystemEventsDigest SystemEvents = "digest"
+)
+
 type Lobby struct {
 	ID               string
+	TenantID         string
 	Users            map[string]*User
 	Clients          map[string]*Client
 	MaxUsers         int
 	IsActive         bool
 	CreatedAt        time.Time
 	WebSocketStarted bool
-	MessageHistory   []Message
-	mu               sync.RWMutex
+	// Internal lobbies (e.g. the health probe lobby) are never handed out
+	// to real users and keep no history.
+	Internal bool
+	// MessageHistory holds the most recent chat messages; older ones are
+	// only in the store.
+	MessageHistory *MessageRing
+	// ParentID links a follow-up session to the one before it; FollowUps
+	// lists the sessions that continue this one
+	ParentID  string
+	FollowUps []string
+	// Topic is the interest tag matchmaking opened the lobby for; a topic
+	// lobby runs alongside the tenant's own session
+	Topic string
+	// ImportedContext is a prior session's transcript shown before the
+	// lobby's own history; it has no sequence numbers and is never counted
+	ImportedContext []Message
+	// Scores tallies reactions and votes for the top ideas leaderboard
+	Scores *Scoreboard
+	// Pinned holds the sequence numbers of pinned messages
+	Pinned []int64
+	// ActionItems are the messages moderators tagged for follow-up, in
+	// the order they were tagged
+	ActionItems []ActionItem
+	// Channels are the lobby's whisper groups, the oldest first
+	Channels []Channel
+	// Threads counts the replies to each message that has any, by seq
+	Threads map[int64]int
+	// Banned users were kicked and may not rejoin
+	Banned       map[string]bool
+	SystemEvents SystemEvents
+	// GuestFriendly lobbies accept guests on top of MaxUsers
+	GuestFriendly bool
+	// Budget is the facilitator's time budget, nil if none was set
+	Budget *Budget
+	// ReadOnly and SlowModeSeconds are lobby settings, see LobbySettings
+	ReadOnly        bool
+	SlowModeSeconds int
+	// RetentionSeconds and RetentionMessages are the lobby's overrides of
+	// the history retention, see LobbySettings
+	RetentionSeconds  int
+	RetentionMessages int
+	// EphemeralSeconds is how long chat lives in ephemeral mode, see
+	// LobbySettings
+	EphemeralSeconds int
+	// ReliableDelivery is a lobby setting, see LobbySettings
+	ReliableDelivery bool
+	// lastChat is when each user last sent chat, for slow mode
+	lastChat map[string]time.Time
+	// mutedUntil is when each user muted for spamming may chat again
+	mutedUntil map[string]time.Time
+	// quota is the lobby's chat throughput across its members
+	quota chatQuota
+	// outboxes holds, in reliable lobbies, the frames each member hasn't
+	// acked yet, in seq order
+	outboxes map[string][]outboxFrame
+	// departing is when each member whose connection dropped lost it,
+	// while their seat is held for a reconnect
+	departing map[string]time.Time
+	// startNoticeSent is set once members were mailed that the lobby filled
+	startNoticeSent bool
+	// rosterChanges holds the joins (true) and leaves (false) not yet
+	// reported in a roster digest
+	rosterChanges map[string]bool
+	lastSeq       int64
+	// seqCeiling is the highest sequence number reserved in the store
+	seqCeiling int64
+	mu         sync.RWMutex
 }
 
-func NewLobby(id string, maxUsers int) *Lobby {
+func NewLobby(id string, maxUsers, historyLimit int) *Lobby {
 	return &Lobby{
+
 		ID:               id,
 		Users:            make(map[string]*User),
 		Clients:          make(map[string]*Client),
+		Banned:           make(map[string]bool),
+		SystemEvents:     SystemEventsAll,
+		rosterChanges:    make(map[string]bool),
+		lastChat:         make(map[string]time.Time),
+		mutedUntil:       make(map[string]time.Time),
+		departing:        make(map[string]time.Time),
+		outboxes:         make(map[string][]outboxFrame),
+		Threads:          make(map[int64]int),
 		MaxUsers:         maxUsers,
 		IsActive:         false,
 		CreatedAt:        time.Now(),
 		WebSocketStarted: false,
-		MessageHistory:   make([]Message, 0),
+		MessageHistory:   NewMessageRing(historyLimit),
+		Scores:           NewScoreboard(),
 	}
 }
 
@@ -50,17 +327,443 @@ func (l *Lobby) AddUser(email string) *User {
 		return user
 	}
 
+	// The first user to join created the lobby and owns it
+	role := RoleParticipant
+	if l.memberCountLocked() == 0 {
+		role = RoleOwner
+	}
+
 	user := &User{
 		Email:    email,
 		LobbyID:  l.ID,
 		JoinedAt: time.Now(),
 		IsActive: true,
 		LastSeen: time.Now(),
+		Role:     role,
 	}
 	l.Users[email] = user
 	return user
 }
 
+// AddGuest adds a guest under its generated display name, reporting false
+// if the name is taken. Guests are always participants and don't take
+// member seats.
+func (l *Lobby) AddGuest(name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, exists := l.Users[name]; exists {
+		return false
+	}
+	l.Users[name] = &User{
+		Email:    name,
+		LobbyID:  l.ID,
+		JoinedAt: time.Now(),
+		IsActive: true,
+		LastSeen: time.Now(),
+		Role:     RoleParticipant,
+		Guest:    true,
+	}
+	return true
+}
+
+func (l *Lobby) IsGuest(email string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	user, exists := l.Users[email]
+	return exists && user.Guest
+}
+
+// SetHidden records a client's visibility hint. HiddenSince keeps the time
+// the tab was first hidden.
+func (l *Lobby) SetHidden(email string, hidden bool, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	user, exists := l.Users[email]
+	if !exists {
+		return
+	}
+	if !hidden {
+		user.HiddenSince = time.Time{}
+	} else if user.HiddenSince.IsZero() {
+		user.HiddenSince = now
+	}
+}
+
+// RefreshPresence derives every user's presence: offline without a
+// connection, away once hidden for awayAfter, online otherwise. It returns
+// the users who switched between online and away; going offline or coming
+// back online from offline is announced by leave and join messages instead.
+func (l *Lobby) RefreshPresence(awayAfter time.Duration, now time.Time) map[string]Presence {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	changes := make(map[string]Presence)
+	for email, user := range l.Users {
+		presence := PresenceOnline
+		if _, connected := l.Clients[email]; !connected {
+			presence = PresenceOffline
+		} else if !user.HiddenSince.IsZero() && now.Sub(user.HiddenSince) >= awayAfter {
+			presence = PresenceAway
+		}
+
+		if presence != user.Presence && presence != PresenceOffline && user.Presence != PresenceOffline && user.Presence != "" {
+			changes[email] = presence
+		}
+		user.Presence = presence
+	}
+	return changes
+}
+
+// GetPresence returns a member's presence, offline while they have no
+// connection.
+func (l *Lobby) GetPresence(email string) Presence {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	user, exists := l.Users[email]
+	if _, connected := l.Clients[email]; !exists || !connected {
+		return PresenceOffline
+	}
+	if user.Presence == PresenceAway {
+		return PresenceAway
+	}
+	return PresenceOnline
+}
+
+// GetAwayUsers returns the users whose presence is away, sorted.
+func (l *Lobby) GetAwayUsers() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var away []string
+	for email, user := range l.Users {
+		if user.Presence == PresenceAway {
+			away = append(away, email)
+		}
+	}
+	sort.Strings(away)
+	return away
+}
+
+// GetGuests returns the display names of the lobby's guests, sorted.
+func (l *Lobby) GetGuests() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var guests []string
+	for email, user := range l.Users {
+		if user.Guest {
+			guests = append(guests, email)
+		}
+	}
+	sort.Strings(guests)
+	return guests
+}
+
+func (l *Lobby) SetGuestFriendly(guestFriendly bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.GuestFriendly = guestFriendly
+}
+
+func (l *Lobby) IsGuestFriendly() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.GuestFriendly
+}
+
+// CanAcceptGuests reports whether the lobby takes guests and has fewer than
+// maxGuests.
+func (l *Lobby) CanAcceptGuests(maxGuests int) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.GuestFriendly && len(l.Users)-l.memberCountLocked() < maxGuests
+}
+
+func (l *Lobby) GetGuestCount() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.Users) - l.memberCountLocked()
+}
+
+// memberCountLocked counts the users who aren't guests. The caller holds
+// l.mu.
+func (l *Lobby) memberCountLocked() int {
+	count := 0
+	for _, user := range l.Users {
+		if !user.Guest {
+			count++
+		}
+	}
+	return count
+}
+
+// ReleaseIdleUsers takes out of the lobby every user without a connection
+// who was last seen over timeout ago, freeing their seats. If the owner is
+// among them, the member who joined first becomes the new owner, returned
+// as newOwner.
+func (l *Lobby) ReleaseIdleUsers(timeout time.Duration, now time.Time) (released []string, newOwner string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ownerLeft := false
+	for email, user := range l.Users {
+		if _, connected := l.Clients[email]; connected || now.Sub(user.LastSeen) < timeout {
+			continue
+		}
+		released = append(released, email)
+		ownerLeft = ownerLeft || user.Role == RoleOwner
+		delete(l.Users, email)
+		l.leaveChannelsLocked(email)
+		delete(l.outboxes, email)
+	}
+	sort.Strings(released)
+
+	if ownerLeft {
+		var successor *User
+		for _, user := range l.Users {
+			if user.Guest {
+				continue
+			}
+			if successor == nil || user.JoinedAt.Before(successor.JoinedAt) {
+				successor = user
+			}
+		}
+		if successor != nil {
+			successor.Role = RoleOwner
+			newOwner = successor.Email
+		}
+	}
+	return released, newOwner
+}
+
+// RemoveUser takes a user out of the lobby entirely, e.g. when kicked,
+// and out of its channels.
+func (l *Lobby) RemoveUser(email string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.Users, email)
+	delete(l.Clients, email)
+	l.leaveChannelsLocked(email)
+	delete(l.outboxes, email)
+}
+
+func (l *Lobby) Ban(email string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Banned[email] = true
+}
+
+func (l *Lobby) IsBanned(email string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.Banned[email]
+}
+
+// Mute keeps a user from chatting until the given time.
+func (l *Lobby) Mute(email string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.mutedUntil[email] = until
+}
+
+// MutedFor returns how long a user is still muted, 0 if not.
+func (l *Lobby) MutedFor(email string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	wait := l.mutedUntil[email].Sub(now)
+	if wait <= 0 {
+		delete(l.mutedUntil, email)
+		return 0
+	}
+	return wait
+}
+
+// GetUserRole returns the user's lobby role, or "" if not a member.
+func (l *Lobby) GetUserRole(email string) Role {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if user, exists := l.Users[email]; exists {
+		return user.Role
+	}
+	return ""
+}
+
+func (l *Lobby) SetUserRole(email string, role Role) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	user, exists := l.Users[email]
+	if !exists {
+		return false
+	}
+	user.Role = role
+	return true
+}
+
+// GetRoles returns the lobby role of every member.
+func (l *Lobby) GetRoles() map[string]Role {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	roles := make(map[string]Role, len(l.Users))
+	for email, user := range l.Users {
+		roles[email] = user.Role
+	}
+	return roles
+}
+
+func (l *Lobby) SetMaxUsers(maxUsers int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.MaxUsers = maxUsers
+}
+
+func (l *Lobby) SetSystemEvents(systemEvents SystemEvents) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.SystemEvents = systemEvents
+}
+
+func (l *Lobby) GetSystemEvents() SystemEvents {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.SystemEvents
+}
+
+// NoteRosterChange records a join or leave for the next roster digest. A
+// leave cancels an unreported join and vice versa.
+func (l *Lobby) NoteRosterChange(email string, joined bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if pending, exists := l.rosterChanges[email]; exists && pending != joined {
+		delete(l.rosterChanges, email)
+		return
+	}
+	l.rosterChanges[email] = joined
+}
+
+// TakeRosterChanges returns and clears the users who joined and left since
+// the last call, sorted by email.
+func (l *Lobby) TakeRosterChanges() (joined, left []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for email, hasJoined := range l.rosterChanges {
+		if hasJoined {
+			joined = append(joined, email)
+		} else {
+			left = append(left, email)
+		}
+	}
+	clear(l.rosterChanges)
+	sort.Strings(joined)
+	sort.Strings(left)
+	return joined, left
+}
+
+// SetPinned pins or unpins a message and reports whether anything changed.
+func (l *Lobby) SetPinned(seq int64, pinned bool) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, pinnedSeq := range l.Pinned {
+		if pinnedSeq == seq {
+			if !pinned {
+				l.Pinned = append(l.Pinned[:i], l.Pinned[i+1:]...)
+			}
+			return !pinned
+		}
+	}
+	if pinned {
+		l.Pinned = append(l.Pinned, seq)
+	}
+	return pinned
+}
+
+func (l *Lobby) GetPinned() []int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]int64(nil), l.Pinned...)
+}
+
+// AddActionItems tags items that aren't tagged yet and returns those it
+// added.
+func (l *Lobby) AddActionItems(items []ActionItem) []ActionItem {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var added []ActionItem
+	for _, item := range items {
+		if !slices.ContainsFunc(l.ActionItems, func(tagged ActionItem) bool { return tagged.MessageID == item.MessageID }) {
+			l.ActionItems = append(l.ActionItems, item)
+			added = append(added, item)
+		}
+	}
+	return added
+}
+
+// RemoveActionItem untags a message and reports whether it was tagged.
+func (l *Lobby) RemoveActionItem(messageID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, item := range l.ActionItems {
+		if item.MessageID == messageID {
+			l.ActionItems = append(l.ActionItems[:i], l.ActionItems[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Lobby) GetActionItems() []ActionItem {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]ActionItem(nil), l.ActionItems...)
+}
+
+// AddReply counts a reply to the message with seq parentSeq and returns
+// the thread's new reply count.
+func (l *Lobby) AddReply(parentSeq int64) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Threads[parentSeq]++
+	return l.Threads[parentSeq]
+}
+
+// GetThreads returns a copy of the reply counts by parent seq.
+func (l *Lobby) GetThreads() map[int64]int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	threads := make(map[int64]int, len(l.Threads))
+	for seq, count := range l.Threads {
+		threads[seq] = count
+	}
+	return threads
+}
+
+func (l *Lobby) AddFollowUp(lobbyID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.FollowUps = append(l.FollowUps, lobbyID)
+}
+
+func (l *Lobby) SetImportedContext(messages []Message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ImportedContext = messages
+}
+
+func (l *Lobby) GetImportedContext() []Message {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]Message(nil), l.ImportedContext...)
+}
+
 func (l *Lobby) AddClient(email string, client *Client) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -73,6 +776,20 @@ func (l *Lobby) RemoveClient(email string) {
 	delete(l.Clients, email)
 }
 
+// DetachClient removes client if it is still the registered connection for
+// its email and reports whether it was. A client already dropped (slow,
+// kicked, replaced by a reconnect) must not have its Send channel closed
+// twice.
+func (l *Lobby) DetachClient(client *Client) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.Clients[client.Email] != client {
+		return false
+	}
+	delete(l.Clients, client.Email)
+	return true
+}
+
 func (l *Lobby) MarkUserInactive(email string) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -82,6 +799,13 @@ func (l *Lobby) MarkUserInactive(email string) {
 	}
 }
 
+func (l *Lobby) IsUserActive(email string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	user, exists := l.Users[email]
+	return exists && user.IsActive
+}
+
 func (l *Lobby) GetActiveUserCount() int {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
@@ -114,6 +838,18 @@ func (l *Lobby) GetActiveUserList() []string {
 	return userList
 }
 
+// GetMemberEmails returns every user of the lobby, active or not.
+func (l *Lobby) GetMemberEmails() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	emails := make([]string, 0, len(l.Users))
+	for email := range l.Users {
+		emails = append(emails, email)
+	}
+	return emails
+}
+
 func (l *Lobby) GetAllClients() map[string]*Client {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
@@ -131,15 +867,77 @@ func (l *Lobby) GetMessageHistory() []Message {
 	defer l.mu.RUnlock()
 
 	// Return a copy
-	history := make([]Message, len(l.MessageHistory))
-	copy(history, l.MessageHistory)
-	return history
+	return l.MessageHistory.Slice()
+}
+
+// IsHistoryTruncated reports whether older messages have been evicted from
+// the in-memory history and must be read from the store.
+func (l *Lobby) IsHistoryTruncated() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.MessageHistory.Dropped()
 }
 
 func (l *Lobby) AddMessageToHistory(msg Message) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	l.MessageHistory = append(l.MessageHistory, msg)
+	l.MessageHistory.Push(msg)
+}
+
+// SetPreviews attaches link previews to the history message of seq, if it
+// is still in the history.
+func (l *Lobby) SetPreviews(seq int64, previews []LinkPreview) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.MessageHistory.Update(seq, func(msg *Message) {
+		msg.Previews = previews
+	})
+}
+
+// GetMessageHistorySince returns a copy of the history messages with a
+// sequence number greater than seq.
+func (l *Lobby) GetMessageHistorySince(seq int64) []Message {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	history := make([]Message, 0)
+	for _, msg := range l.MessageHistory.Slice() {
+		if msg.Seq > seq {
+			history = append(history, msg)
+		}
+	}
+	return history
+}
+
+// NextSeq takes the next sequence number for a broadcast message. reserve
+// is true once it passes the block reserved in the store, which must then
+// be extended before the number is used.
+func (l *Lobby) NextSeq() (seq int64, reserve bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastSeq++
+	return l.lastSeq, l.lastSeq > l.seqCeiling
+}
+
+// ReserveSeqs records that the numbers up to ceiling are reserved.
+func (l *Lobby) ReserveSeqs(ceiling int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seqCeiling = max(l.seqCeiling, ceiling)
+}
+
+// ResumeSeq moves the counter past seq, the highest number an earlier run
+// may have handed out.
+func (l *Lobby) ResumeSeq(seq int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastSeq = max(l.lastSeq, seq)
+}
+
+func (l *Lobby) GetLastSeq() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.lastSeq
 }
 
 func (l *Lobby) StartWebSocket() {
@@ -149,16 +947,28 @@ func (l *Lobby) StartWebSocket() {
 	l.IsActive = true
 }
 
+// MarkStartNoticeSent reports whether the lobby's start notice is still
+// due, marking it sent.
+func (l *Lobby) MarkStartNoticeSent() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	due := !l.startNoticeSent
+	l.startNoticeSent = true
+	return due
+}
+
 func (l *Lobby) IsWebSocketStarted() bool {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 	return l.WebSocketStarted
 }
 
+// CanAcceptNewUsers reports whether a member seat is left; guests don't
+// take member seats.
 func (l *Lobby) CanAcceptNewUsers() bool {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
-	return len(l.Users) < l.MaxUsers
+	return l.memberCountLocked() < l.MaxUsers
 }
 
 func (l *Lobby) IsUserInLobby(email string) bool {
@@ -171,11 +981,12 @@ func (l *Lobby) IsUserInLobby(email string) bool {
 func (l *Lobby) IsFull() bool {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
-	return len(l.Users) >= l.MaxUsers
+	return l.memberCountLocked() >= l.MaxUsers
 }
 
+// GetUserCount counts the lobby's members, guests excluded.
 func (l *Lobby) GetUserCount() int {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
-	return len(l.Users)
+	return l.memberCountLocked()
 }
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// HoldSeat notes that email's connection dropped at now. The member stays
+// active, and isn't announced as gone, until TakeDepartures finds the
+// grace window over without a ReclaimSeat.
+func (l *Lobby) HoldSeat(email string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if user, exists := l.Users[email]; exists {
+		user.LastSeen = now
+		l.departing[email] = now
+	}
+}
+
+// ReclaimSeat ends the hold on email's seat and reports whether there was
+// one, i.e. whether the member is reconnecting within the grace window.
+func (l *Lobby) ReclaimSeat(email string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, held := l.departing[email]
+	delete(l.departing, email)
+	return held
+}
+
+// TakeDepartures returns the members whose seat has been held for grace
+// without a reconnect, sorted by email, and marks them inactive. Idle time
+// still counts from when their connection dropped. Members who left the
+// lobby or connected again meanwhile are dropped silently.
+func (l *Lobby) TakeDepartures(grace time.Duration, now time.Time) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var departed []string
+	for email, since := range l.departing {
+		if now.Sub(since) < grace {
+			continue
+		}
+		delete(l.departing, email)
+		user, exists := l.Users[email]
+		if _, connected := l.Clients[email]; !exists || connected {
+			continue
+		}
+		user.IsActive = false
+		departed = append(departed, email)
+	}
+	sort.Strings(departed)
+	return departed
+}
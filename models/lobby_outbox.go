@@ -0,0 +1,116 @@
+package models
+
+import "time"
+
+// outboxFrame is a frame of a reliable lobby kept for a member until they
+// ack it. sentAt is zero while the member has no connection to send it to.
+type outboxFrame struct {
+	msg      Message
+	sentAt   time.Time
+	attempts int
+}
+
+// RetainFrame keeps msg for email until they ack it, noting it was just
+// sent if sent is set. Beyond window frames, the oldest is given up on; it
+// reports whether one was.
+func (l *Lobby) RetainFrame(email string, msg Message, sent bool, now time.Time, window int) (overflowed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	frame := outboxFrame{msg: msg}
+	if sent {
+		frame.sentAt, frame.attempts = now, 1
+	}
+	outbox := append(l.outboxes[email], frame)
+	if len(outbox) > window {
+		outbox = outbox[len(outbox)-window:]
+		overflowed = true
+	}
+	l.outboxes[email] = outbox
+	return overflowed
+}
+
+// AckFrames drops the frames kept for email up to seq and returns how many
+// there were.
+func (l *Lobby) AckFrames(email string, seq int64) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	outbox := l.outboxes[email]
+	acked := 0
+	for acked < len(outbox) && outbox[acked].msg.Seq <= seq {
+		acked++
+	}
+	l.outboxes[email] = outbox[acked:]
+	return acked
+}
+
+// DueFrames returns the frames sent to email at least timeout ago without
+// an ack, marked Redelivered, oldest first. A frame already sent
+// maxAttempts times is given up on instead; abandoned counts those. The
+// caller notes the frames it did send with MarkSent.
+func (l *Lobby) DueFrames(email string, now time.Time, timeout time.Duration, maxAttempts int) (due []Message, abandoned int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	outbox := l.outboxes[email]
+	kept := outbox[:0]
+	for _, frame := range outbox {
+		if frame.sentAt.IsZero() || now.Sub(frame.sentAt) < timeout {
+			kept = append(kept, frame)
+			continue
+		}
+		if frame.attempts >= maxAttempts {
+			abandoned++
+			continue
+		}
+		kept = append(kept, frame)
+		redelivered := frame.msg
+		redelivered.Redelivered = true
+		due = append(due, redelivered)
+	}
+	l.outboxes[email] = kept
+	return due, abandoned
+}
+
+// MarkSent notes the frames kept for email with the given seqs were sent
+// again at now.
+func (l *Lobby) MarkSent(email string, seqs []int64, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sent := make(map[int64]bool, len(seqs))
+	for _, seq := range seqs {
+		sent[seq] = true
+	}
+	outbox := l.outboxes[email]
+	for i := range outbox {
+		if sent[outbox[i].msg.Seq] {
+			outbox[i].sentAt = now
+			outbox[i].attempts++
+		}
+	}
+}
+
+// UnackedFrames returns the frames kept for email after afterSeq, marked
+// Redelivered, for a connection resuming from afterSeq, and notes they are
+// sent again. Those up to afterSeq the connection says it has, so they are
+// dropped.
+func (l *Lobby) UnackedFrames(email string, afterSeq int64, now time.Time) []Message {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	outbox := l.outboxes[email]
+	for len(outbox) > 0 && outbox[0].msg.Seq <= afterSeq {
+		outbox = outbox[1:]
+	}
+	unacked := make([]Message, len(outbox))
+	for i := range outbox {
+		outbox[i].sentAt = now
+		outbox[i].attempts++
+		unacked[i] = outbox[i].msg
+		unacked[i].Redelivered = true
+	}
+	l.outboxes[email] = outbox
+	return unacked
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// chatQuota is a token bucket of the chat a lobby takes, shared by its
+// members, and the cooldown it is in after running dry.
+type chatQuota struct {
+	tokens        float64
+	refilled      time.Time
+	cooldownUntil time.Time
+}
+
+// TakeChatQuota spends one message of the lobby's quota of rate messages a
+// second, in bursts of up to burst. A message that finds the quota spent
+// starts a cooldown; while it lasts, messages that aren't exempt are held
+// back. It returns how long a held message's cooldown still lasts, and
+// whether the message started it. Exempt messages are spent from the quota
+// but never held.
+func (l *Lobby) TakeChatQuota(now time.Time, rate, burst int, cooldown time.Duration, exempt bool) (wait time.Duration, started bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	q := &l.quota
+	if q.refilled.IsZero() {
+		q.tokens = float64(burst)
+	} else {
+		q.tokens = min(float64(burst), q.tokens+now.Sub(q.refilled).Seconds()*float64(rate))
+	}
+	q.refilled = now
+
+	if wait := q.cooldownUntil.Sub(now); wait > 0 && !exempt {
+		return wait, false
+	}
+	if q.tokens >= 1 {
+		q.tokens--
+		return 0, false
+	}
+	if exempt {
+		return 0, false
+	}
+	q.cooldownUntil = now.Add(cooldown)
+	return cooldown, true
+}
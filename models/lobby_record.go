@@ -0,0 +1,176 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// LobbyRecord is the persisted form of a lobby, enough to rebuild it with
+// all members inactive after a restart.
+type LobbyRecord struct {
+	ID        string          `json:"id"`
+	TenantID  string          `json:"tenant_id"`
+	MaxUsers  int             `json:"max_users"`
+	CreatedAt time.Time       `json:"created_at"`
+	Members   []string        `json:"members"`
+	Roles     map[string]Role `json:"roles,omitempty"`
+	Pinned    []int64         `json:"pinned,omitempty"`
+	// ActionItems stay on the archived record, for the session's summary
+	ActionItems []ActionItem  `json:"action_items,omitempty"`
+	Channels    []Channel     `json:"channels,omitempty"`
+	Threads     map[int64]int `json:"threads,omitempty"`
+	Banned      []string      `json:"banned,omitempty"`
+	LastSeq     int64         `json:"last_seq"`
+	ParentID    string        `json:"parent_id,omitempty"`
+	FollowUps   []string      `json:"follow_ups,omitempty"`
+	Topic       string        `json:"topic,omitempty"`
+	// SystemEvents is empty in records saved before it was configurable
+	SystemEvents  SystemEvents `json:"system_events,omitempty"`
+	GuestFriendly bool         `json:"guest_friendly,omitempty"`
+	Guests        []string     `json:"guests,omitempty"`
+	Budget        *Budget      `json:"budget,omitempty"`
+	// HistoryLimit is 0 in records of lobbies that kept the default
+	HistoryLimit    int  `json:"history_limit,omitempty"`
+	ReadOnly        bool `json:"read_only,omitempty"`
+	SlowModeSeconds int  `json:"slow_mode_seconds,omitempty"`
+	// RetentionSeconds and RetentionMessages also apply to the messages of
+	// an archived session
+	RetentionSeconds  int  `json:"retention_seconds,omitempty"`
+	RetentionMessages int  `json:"retention_messages,omitempty"`
+	EphemeralSeconds  int  `json:"ephemeral_seconds,omitempty"`
+	ReliableDelivery  bool `json:"reliable_delivery,omitempty"`
+	// EndedAt is set on the archived record of an ended session
+	EndedAt time.Time `json:"ended_at,omitzero"`
+	// Archive is set on the archived record once its transcript was
+	// uploaded to the object store
+	Archive *TranscriptArchive `json:"archive,omitempty"`
+}
+
+// Record snapshots the lobby for the lobby registry.
+func (l *Lobby) Record() LobbyRecord {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	members := make([]string, 0, len(l.Users))
+	roles := make(map[string]Role, len(l.Users))
+	var guests []string
+	for email, user := range l.Users {
+		members = append(members, email)
+		roles[email] = user.Role
+		if user.Guest {
+			guests = append(guests, email)
+		}
+	}
+	threads := make(map[int64]int, len(l.Threads))
+	for seq, count := range l.Threads {
+		threads[seq] = count
+	}
+	var budget *Budget
+	if l.Budget != nil {
+		budgetCopy := *l.Budget
+		budget = &budgetCopy
+	}
+	channels := make([]Channel, len(l.Channels))
+	for i, channel := range l.Channels {
+		channels[i] = channel.clone()
+	}
+	banned := make([]string, 0, len(l.Banned))
+	for email := range l.Banned {
+		banned = append(banned, email)
+	}
+	return LobbyRecord{
+		ID:                l.ID,
+		TenantID:          l.TenantID,
+		MaxUsers:          l.MaxUsers,
+		CreatedAt:         l.CreatedAt,
+		Members:           members,
+		Roles:             roles,
+		Pinned:            append([]int64(nil), l.Pinned...),
+		ActionItems:       append([]ActionItem(nil), l.ActionItems...),
+		Channels:          channels,
+		Threads:           threads,
+		Banned:            banned,
+		LastSeq:           l.lastSeq,
+		ParentID:          l.ParentID,
+		FollowUps:         append([]string(nil), l.FollowUps...),
+		Topic:             l.Topic,
+		SystemEvents:      l.SystemEvents,
+		GuestFriendly:     l.GuestFriendly,
+		Guests:            guests,
+		Budget:            budget,
+		HistoryLimit:      l.MessageHistory.Cap(),
+		ReadOnly:          l.ReadOnly,
+		SlowModeSeconds:   l.SlowModeSeconds,
+		RetentionSeconds:  l.RetentionSeconds,
+		RetentionMessages: l.RetentionMessages,
+		EphemeralSeconds:  l.EphemeralSeconds,
+		ReliableDelivery:  l.ReliableDelivery,
+	}
+}
+
+// IsUserInLobby reports whether email was a member of the session.
+func (r LobbyRecord) IsUserInLobby(email string) bool {
+	return slices.Contains(r.Members, email)
+}
+
+// RestoreLobby rebuilds a lobby from its record. Members come back inactive
+// and history holds the given messages, which must be ordered by Seq. The
+// record's history limit, if it has one, overrides historyLimit.
+func RestoreLobby(record LobbyRecord, historyLimit int, history []Message) *Lobby {
+	if record.HistoryLimit > 0 {
+		historyLimit = record.HistoryLimit
+	}
+	lobby := NewLobby(record.ID, record.MaxUsers, historyLimit)
+	lobby.TenantID = record.TenantID
+	lobby.CreatedAt = record.CreatedAt
+	lobby.lastSeq = record.LastSeq
+	lobby.Pinned = record.Pinned
+	lobby.ActionItems = record.ActionItems
+	lobby.Channels = record.Channels
+	lobby.ParentID = record.ParentID
+	lobby.FollowUps = record.FollowUps
+	lobby.Topic = record.Topic
+	lobby.GuestFriendly = record.GuestFriendly
+	lobby.Budget = record.Budget
+	lobby.ReadOnly = record.ReadOnly
+	lobby.SlowModeSeconds = record.SlowModeSeconds
+	lobby.RetentionSeconds = record.RetentionSeconds
+	lobby.RetentionMessages = record.RetentionMessages
+	lobby.EphemeralSeconds = record.EphemeralSeconds
+	lobby.ReliableDelivery = record.ReliableDelivery
+	if record.SystemEvents != "" {
+		lobby.SystemEvents = record.SystemEvents
+	}
+	for _, email := range record.Banned {
+		lobby.Banned[email] = true
+	}
+	for seq, count := range record.Threads {
+		lobby.Threads[seq] = count
+	}
+
+	guests := make(map[string]bool, len(record.Guests))
+	for _, name := range record.Guests {
+		guests[name] = true
+	}
+	// The idle timeout of restored members starts over, as the server being
+	// down is not their idling
+	restoredAt := time.Now()
+	for _, email := range record.Members {
+		lobby.Users[email] = &User{
+			Email:    email,
+			LobbyID:  record.ID,
+			JoinedAt: record.CreatedAt,
+			LastSeen: restoredAt,
+			Role:     record.Roles[email],
+			Guest:    guests[email],
+		}
+		if lobby.Users[email].Role == "" {
+			lobby.Users[email].Role = RoleParticipant
+		}
+	}
+	for _, msg := range history {
+		lobby.MessageHistory.Push(msg)
+		lobby.lastSeq = max(lobby.lastSeq, msg.Seq)
+	}
+	return lobby
+}
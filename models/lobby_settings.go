@@ -0,0 +1,113 @@
+package models
+
+import "time"
+
+// LobbySettings are the options a lobby owner changes through the settings
+// API.
+type LobbySettings struct {
+	MaxUsers int `json:"max_users" proto:"1"`
+	// HistoryLimit is how many recent messages the lobby keeps in memory
+	HistoryLimit int `json:"history_limit" proto:"2"`
+	// ReadOnly lobbies only take chat from owners and moderators
+	ReadOnly    bool `json:"read_only" proto:"3"`
+	AllowGuests bool `json:"allow_guests" proto:"4"`
+	// SlowModeSeconds is the least time between two chat messages of one
+	// user, 0 for none
+	SlowModeSeconds int `json:"slow_mode_seconds" proto:"5"`
+	// RetentionSeconds and RetentionMessages override the server's history
+	// retention for the lobby, 0 for the server default
+	RetentionSeconds  int `json:"retention_seconds" proto:"6"`
+	RetentionMessages int `json:"retention_messages" proto:"7"`
+	// EphemeralSeconds turns on ephemeral mode: chat sent meanwhile expires
+	// that long after it was sent, everywhere. 0 turns it off; messages
+	// already sent keep their expiry
+	EphemeralSeconds int `json:"ephemeral_seconds" proto:"8"`
+	// ReliableDelivery keeps the frames sent to each member until they ack
+	// them, and sends them again if they don't
+	ReliableDelivery bool `json:"reliable_delivery" proto:"9"`
+}
+
+func (l *Lobby) GetSettings() LobbySettings {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return LobbySettings{
+		MaxUsers:          l.MaxUsers,
+		HistoryLimit:      l.MessageHistory.Cap(),
+		ReadOnly:          l.ReadOnly,
+		AllowGuests:       l.GuestFriendly,
+		SlowModeSeconds:   l.SlowModeSeconds,
+		RetentionSeconds:  l.RetentionSeconds,
+		RetentionMessages: l.RetentionMessages,
+		EphemeralSeconds:  l.EphemeralSeconds,
+		ReliableDelivery:  l.ReliableDelivery,
+	}
+}
+
+// ApplySettings takes settings the caller validated. A smaller history
+// limit drops the oldest messages from memory; the store keeps them.
+func (l *Lobby) ApplySettings(settings LobbySettings) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.MaxUsers = settings.MaxUsers
+	l.MessageHistory.Resize(settings.HistoryLimit)
+	l.ReadOnly = settings.ReadOnly
+	l.GuestFriendly = settings.AllowGuests
+	l.SlowModeSeconds = settings.SlowModeSeconds
+	l.RetentionSeconds = settings.RetentionSeconds
+	l.RetentionMessages = settings.RetentionMessages
+	l.EphemeralSeconds = settings.EphemeralSeconds
+	l.ReliableDelivery = settings.ReliableDelivery
+	if !l.ReliableDelivery {
+		clear(l.outboxes)
+	}
+}
+
+// PruneHistory drops the in-memory messages sent before cutoff, unless it
+// is zero, and those beyond the newest maxMessages, unless it is 0. It
+// returns how many were dropped.
+func (l *Lobby) PruneHistory(cutoff time.Time, maxMessages int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	history := l.MessageHistory.Slice()
+	drop := 0
+	if maxMessages > 0 {
+		drop = max(0, len(history)-maxMessages)
+	}
+	for !cutoff.IsZero() && drop < len(history) && history[drop].Timestamp.Before(cutoff) {
+		drop++
+	}
+	l.MessageHistory.DropOldest(drop)
+	return drop
+}
+
+// ExpireHistory drops the in-memory messages whose ExpireAt is before now
+// and returns them.
+func (l *Lobby) ExpireHistory(now time.Time) []Message {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.MessageHistory.RemoveFunc(func(msg Message) bool {
+		return !msg.ExpireAt.IsZero() && msg.ExpireAt.Before(now)
+	})
+}
+
+func (l *Lobby) IsReadOnly() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.ReadOnly
+}
+
+// HoldChat returns how long slow mode still holds back a user's next chat
+// message. A message that may go now is recorded as the user's latest.
+func (l *Lobby) HoldChat(email string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.SlowModeSeconds > 0 {
+		if wait := l.lastChat[email].Add(time.Duration(l.SlowModeSeconds) * time.Second).Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	l.lastChat[email] = now
+	return 0
+}
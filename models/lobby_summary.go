@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// LobbySummary is the artifact kept for an ended session: who took part,
+// how long it ran, who said how much, and the full transcript.
+type LobbySummary struct {
+	LobbyID         string    `json:"lobby_id"`
+	TenantID        string    `json:"tenant_id,omitempty"`
+	ParentID        string    `json:"parent_id,omitempty"`
+	Participants    []string  `json:"participants"`
+	StartedAt       time.Time `json:"started_at"`
+	EndedAt         time.Time `json:"ended_at"`
+	DurationSeconds int64     `json:"duration_seconds"`
+	// MessageCount counts chat messages; system notices are in the
+	// transcript but not counted
+	MessageCount   int            `json:"message_count"`
+	MessagesByUser map[string]int `json:"messages_by_user"`
+	// ActionItems are the messages moderators tagged for follow-up
+	ActionItems []ActionItem `json:"action_items"`
+	// Ideas are the session's idea cards, best voted first
+	Ideas      []Idea         `json:"ideas"`
+	Transcript []RedisMessage `json:"transcript"`
+}
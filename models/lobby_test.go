@@ -0,0 +1,165 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestLobbySeats(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxUsers   int
+		members    int
+		guests     int
+		wantAccept bool
+		wantFull   bool
+	}{
+		{"empty", 3, 0, 0, true, false},
+		{"one seat left", 3, 2, 0, true, false},
+		{"exactly full", 3, 3, 0, false, true},
+		{"over capacity", 3, 4, 0, false, true},
+		{"guests take no seats", 3, 2, 5, true, false},
+		{"full with guests", 3, 3, 2, false, true},
+		{"single seat taken", 1, 1, 0, false, true},
+		{"no seats", 0, 0, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lobby := NewLobby("lobby-test", tt.maxUsers, 10)
+			for i := range tt.members {
+				lobby.AddUser(fmt.Sprintf("user%d@example.com", i))
+			}
+			for i := range tt.guests {
+				lobby.AddGuest(fmt.Sprintf("guest-%d", i))
+			}
+
+			if got := lobby.CanAcceptNewUsers(); got != tt.wantAccept {
+				t.Errorf("CanAcceptNewUsers() = %t, want %t", got, tt.wantAccept)
+			}
+			if got := lobby.IsFull(); got != tt.wantFull {
+				t.Errorf("IsFull() = %t, want %t", got, tt.wantFull)
+			}
+			if got := lobby.GetUserCount(); got != tt.members {
+				t.Errorf("GetUserCount() = %d, want %d", got, tt.members)
+			}
+		})
+	}
+}
+
+func TestLobbyAddUserTwiceKeepsOneSeat(t *testing.T) {
+	lobby := NewLobby("lobby-test", 2, 10)
+	lobby.AddUser("a@example.com")
+	lobby.MarkUserInactive("a@example.com")
+	lobby.AddUser("a@example.com")
+
+	if got := lobby.GetUserCount(); got != 1 {
+		t.Fatalf("GetUserCount() = %d, want 1", got)
+	}
+	if !lobby.IsUserActive("a@example.com") {
+		t.Error("rejoining user is not active again")
+	}
+	if got := lobby.GetUserRole("a@example.com"); got != RoleOwner {
+		t.Errorf("first user's role = %s, want %s", got, RoleOwner)
+	}
+}
+
+func TestLobbyHistoryIsCopied(t *testing.T) {
+	lobby := NewLobby("lobby-test", 3, 10)
+	lobby.AddMessageToHistory(Message{Seq: 1, Content: "first"})
+	lobby.AddMessageToHistory(Message{Seq: 2, Content: "second"})
+
+	history := lobby.GetMessageHistory()
+	history[0].Content = "changed"
+	history = append(history, Message{Seq: 3})
+	if got := lobby.GetMessageHistory(); len(got) != 2 || got[0].Content != "first" {
+		t.Errorf("history after changing a copy = %+v", got)
+	}
+
+	since := lobby.GetMessageHistorySince(1)
+	if len(since) != 1 || since[0].Seq != 2 {
+		t.Fatalf("GetMessageHistorySince(1) = %+v, want seq 2 only", since)
+	}
+	since[0].Content = "changed"
+	if got := lobby.GetMessageHistory(); got[1].Content != "second" {
+		t.Errorf("history after changing a copy from GetMessageHistorySince = %+v", got)
+	}
+}
+
+func TestLobbyHistoryLimit(t *testing.T) {
+	lobby := NewLobby("lobby-test", 3, 2)
+	for seq := int64(1); seq <= 3; seq++ {
+		lobby.AddMessageToHistory(Message{Seq: seq})
+	}
+
+	history := lobby.GetMessageHistory()
+	if len(history) != 2 || history[0].Seq != 2 || history[1].Seq != 3 {
+		t.Errorf("history = %+v, want seqs 2 and 3", history)
+	}
+	if !lobby.IsHistoryTruncated() {
+		t.Error("IsHistoryTruncated() = false after the oldest message was evicted")
+	}
+}
+
+func TestLobbyClientsAreCopied(t *testing.T) {
+	lobby := NewLobby("lobby-test", 3, 10)
+	client := &Client{Email: "a@example.com"}
+	lobby.AddClient(client.Email, client)
+
+	clients := lobby.GetAllClients()
+	delete(clients, client.Email)
+	clients["b@example.com"] = &Client{Email: "b@example.com"}
+
+	got := lobby.GetAllClients()
+	if len(got) != 1 || got[client.Email] != client {
+		t.Errorf("clients after changing a copy = %v", got)
+	}
+}
+
+// TestLobbyConcurrentMembership interleaves the calls the lobby service
+// makes from connection goroutines and the lobby worker; run it with -race.
+func TestLobbyConcurrentMembership(t *testing.T) {
+	const users, rounds = 20, 50
+	lobby := NewLobby("lobby-test", users, 10)
+
+	var wg sync.WaitGroup
+	for i := range users {
+		email := fmt.Sprintf("user%d@example.com", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				lobby.AddUser(email)
+				lobby.AddClient(email, &Client{Email: email})
+				lobby.GetAllClients()
+				lobby.CanAcceptNewUsers()
+				lobby.RemoveClient(email)
+				lobby.MarkUserInactive(email)
+				lobby.GetActiveUserList()
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for seq := int64(1); seq <= rounds; seq++ {
+			lobby.AddMessageToHistory(Message{Seq: seq})
+			lobby.GetMessageHistory()
+			lobby.IsFull()
+		}
+	}()
+	wg.Wait()
+
+	if got := lobby.GetUserCount(); got != users {
+		t.Errorf("GetUserCount() = %d, want %d", got, users)
+	}
+	if got := lobby.GetConnectedClientCount(); got != 0 {
+		t.Errorf("GetConnectedClientCount() = %d, want 0 once every client left", got)
+	}
+	if got := lobby.GetActiveUserCount(); got != 0 {
+		t.Errorf("GetActiveUserCount() = %d, want 0 once every user went inactive", got)
+	}
+	if lobby.CanAcceptNewUsers() {
+		t.Error("CanAcceptNewUsers() = true with every seat taken")
+	}
+}
@@ -9,26 +9,271 @@ const (
 	MessageTypeSystemAction MessageType = "system_action"
 )
 
+// Lobby management frames sent by clients; the sender's lobby role must be
+// allowed the matching action.
+const (
+	MessageTypeEndLobby    MessageType = "end_lobby"
+	MessageTypeKick        MessageType = "kick"
+	MessageTypePin         MessageType = "pin"
+	MessageTypeUnpin       MessageType = "unpin"
+	MessageTypeSetMaxUsers MessageType = "set_max_users"
+	MessageTypeSetRole     MessageType = "set_role"
+	// MessageTypeSetSystemEvents switches joins and leaves between full
+	// broadcasts and roster digests
+	MessageTypeSetSystemEvents MessageType = "set_system_events"
+	MessageTypeSetGuestAccess  MessageType = "set_guest_access"
+	MessageTypeSetBudget       MessageType = "set_budget"
+	MessageTypeSetSlowMode     MessageType = "set_slow_mode"
+)
+
+// Feedback frames on a chat message, named by TargetSeq.
+const (
+	MessageTypeReact MessageType = "react"
+	MessageTypeVote  MessageType = "vote"
+)
+
+// MessageTypeVisibility is a client hint that its tab was shown or hidden.
+const MessageTypeVisibility MessageType = "visibility"
+
+// MessageTypeReply is chat answering the message named by ParentMessageID.
+const MessageTypeReply MessageType = "reply"
+
+// MessageTypeIdea is an idea card: chat that members vote on and the lobby
+// ranks by its votes.
+const MessageTypeIdea MessageType = "idea"
+
+// MessageTypeMessageRead moves the sender's last-read pointer to the message
+// named by MessageID.
+const MessageTypeMessageRead MessageType = "message_read"
+
+// MessageTypeJoin and MessageTypeLeave start and stop following another
+// lobby, named by LobbyID, on the same connection.
+const (
+	MessageTypeJoin  MessageType = "join"
+	MessageTypeLeave MessageType = "leave"
+)
+
+// MessageTypePing is a client heartbeat with its ClientTs and the RTTMs it
+// measured on the previous pong. MessageTypePong answers it with the same
+// ClientTs and the server's receive time as Timestamp.
+const (
+	MessageTypePing MessageType = "ping"
+	MessageTypePong MessageType = "pong"
+)
+
+// MessageTypeHistoryBatch carries a slice of the history a connection that
+// asked for batched replay is catching up on, with its Replay progress.
+// The client answers each batch with a MessageTypeHistoryAck naming it in
+// Replay.Batch, and the next batches wait for that ack.
+const (
+	MessageTypeHistoryBatch MessageType = "history_batch"
+	MessageTypeHistoryAck   MessageType = "history_ack"
+)
+
+// MessageTypeDeliveryAck acks, in a lobby with reliable delivery, every
+// frame the sender got up to the Seq it names. Frames not acked within the
+// ack timeout are sent again, marked Redelivered.
+const MessageTypeDeliveryAck MessageType = "delivery_ack"
+
+// MessageTypeChannelCreate opens a whisper group of the sender and the
+// Channel's members; MessageTypeChannelLeave leaves the one named by
+// ChannelID. Chat naming a ChannelID only reaches that channel's members.
+const (
+	MessageTypeChannelCreate MessageType = "channel_create"
+	MessageTypeChannelLeave  MessageType = "channel_leave"
+)
+
 type SystemActionType string
 
 const (
 	SystemActionWelcome    SystemActionType = "welcome"
 	SystemActionUserJoined SystemActionType = "user_joined"
 	SystemActionUserLeft   SystemActionType = "user_left"
-	SystemActionError      SystemActionType = "error"
-	SystemActionUserList   SystemActionType = "user_list"
+	// SystemActionUserTimedOut frees the seat of a user who stayed away
+	// longer than the idle timeout
+	SystemActionUserTimedOut SystemActionType = "user_timed_out"
+	SystemActionError        SystemActionType = "error"
+	SystemActionUserList     SystemActionType = "user_list"
+	SystemActionMention      SystemActionType = "mention"
+	SystemActionModerated    SystemActionType = "moderated"
+	SystemActionLobbyEnded   SystemActionType = "lobby_ended"
+	SystemActionKicked       SystemActionType = "kicked"
+	SystemActionMuted        SystemActionType = "muted"
+	SystemActionPinned       SystemActionType = "pinned"
+	SystemActionUnpinned     SystemActionType = "unpinned"
+	SystemActionMaxUsers     SystemActionType = "max_users_changed"
+	SystemActionRoleChange   SystemActionType = "role_changed"
+	SystemActionReaction     SystemActionType = "reaction"
+	SystemActionSystemEvents SystemActionType = "system_events_changed"
+	SystemActionRosterDigest SystemActionType = "roster_digest"
+	SystemActionGuestAccess  SystemActionType = "guest_access_changed"
+	SystemActionPresence     SystemActionType = "presence_changed"
+	SystemActionProfile      SystemActionType = "profile_changed"
+	SystemActionEmoji        SystemActionType = "emoji_changed"
+	SystemActionBudget       SystemActionType = "budget_changed"
+	// SystemActionBudgetMilestone marks a session passing 50%, 90% or 100%
+	// of its time budget
+	SystemActionBudgetMilestone SystemActionType = "budget_milestone"
+	// SystemActionAnnouncement is a server-wide notice from an admin; it is
+	// kept in every lobby's history like chat
+	SystemActionAnnouncement SystemActionType = "announcement"
+	SystemActionSettings     SystemActionType = "settings_changed"
+	// SystemActionSlowMode tells a sender slow mode held their message back
+	SystemActionSlowMode SystemActionType = "slow_mode"
+	// SystemActionCooldown tells the lobby it went over its message quota
+	// and only moderators may chat for RetryAfterMs, and tells a sender
+	// held back meanwhile
+	SystemActionCooldown SystemActionType = "cooldown"
+	// SystemActionAck confirms to its sender that a chat message carrying a
+	// ClientMsgID is stored, with the MessageID and Seq it was given
+	SystemActionAck SystemActionType = "ack"
+	// SystemActionLagging tells facilitators a member's round trip rose
+	// above the lag threshold
+	SystemActionLagging SystemActionType = "client_lagging"
+	// SystemActionMessageEnriched carries the Previews of the links in the
+	// chat message named by MessageID and TargetSeq, fetched after it was
+	// delivered
+	SystemActionMessageEnriched SystemActionType = "message_enriched"
+	// SystemActionActionItems carries the lobby's ActionItems after a
+	// moderator tagged or untagged a message
+	SystemActionActionItems SystemActionType = "action_items"
+	// SystemActionMessageExpired names, in Expired, the messages of an
+	// ephemeral lobby that just expired
+	SystemActionMessageExpired SystemActionType = "message_expired"
+	// SystemActionChannelCreated and SystemActionChannelLeft tell a
+	// channel's members it was opened, or that Target left it, with the
+	// Channel as it is now
+	SystemActionChannelCreated SystemActionType = "channel_created"
+	SystemActionChannelLeft    SystemActionType = "channel_left"
 )
 
+// Message is a WebSocket frame. The proto tags number its fields for the
+// protobuf codec (codec/frame.proto); a new field takes the next number.
 type Message struct {
-	Type         MessageType       `json:"type"`
-	SystemAction *SystemActionType `json:"system_action,omitempty"`
-	Username     string            `json:"username,omitempty"`
-	Content      string            `json:"content"`
-	LobbyID      string            `json:"lobby_id"`
-	UserCount    int               `json:"user_count,omitempty"`
-	MaxUsers     int               `json:"max_users,omitempty"`
-	UserList     []string          `json:"user_list,omitempty"`
-	Timestamp    time.Time         `json:"timestamp"`
+	Type         MessageType       `json:"type" proto:"1"`
+	SystemAction *SystemActionType `json:"system_action,omitempty" proto:"2"`
+	// Username is the sender's email inside the server; frames sent to
+	// clients carry the sender's member ID with their DisplayName and
+	// AvatarURL instead
+	Username    string          `json:"username,omitempty" proto:"3"`
+	DisplayName string          `json:"display_name,omitempty" proto:"4"`
+	AvatarURL   string          `json:"avatar_url,omitempty" proto:"5"`
+	Content     string          `json:"content" proto:"6"`
+	LobbyID     string          `json:"lobby_id" proto:"7"`
+	UserCount   int             `json:"user_count,omitempty" proto:"8"`
+	MaxUsers    int             `json:"max_users,omitempty" proto:"9"`
+	UserList    []string        `json:"user_list,omitempty" proto:"10"`
+	Roles       map[string]Role `json:"roles,omitempty" proto:"11"`
+	// Target is the user a management frame or its broadcast applies to
+	Target string `json:"target,omitempty" proto:"12"`
+	Role   Role   `json:"role,omitempty" proto:"13"`
+	// PinnedSeq names the message a pin frame applies to; Pinned lists the
+	// pinned messages in welcome frames
+	PinnedSeq int64   `json:"pinned_seq,omitempty" proto:"14"`
+	Pinned    []int64 `json:"pinned,omitempty" proto:"15"`
+	// TargetSeq names the message a react or vote frame applies to; the
+	// reaction broadcast carries its new Reactions and Score
+	TargetSeq int64          `json:"target_seq,omitempty" proto:"16"`
+	Reaction  string         `json:"reaction,omitempty" proto:"17"`
+	Vote      int            `json:"vote,omitempty" proto:"18"`
+	Reactions map[string]int `json:"reactions,omitempty" proto:"19"`
+	Score     int            `json:"score,omitempty" proto:"20"`
+	// ParentMessageID is the seq of the message a reply answers; reply
+	// broadcasts carry the thread's new ThreadCount and welcome frames the
+	// reply counts of every thread by parent seq
+	ParentMessageID int64         `json:"parent_message_id,omitempty" proto:"21"`
+	ThreadCount     int           `json:"thread_count,omitempty" proto:"22"`
+	Threads         map[int64]int `json:"threads,omitempty" proto:"23"`
+	// SystemEvents is the lobby's join and leave verbosity in welcome and
+	// set_system_events frames; roster digests list the Joined and Left
+	// users since the previous digest
+	SystemEvents SystemEvents `json:"system_events,omitempty" proto:"24"`
+	Joined       []string     `json:"joined,omitempty" proto:"25"`
+	Left         []string     `json:"left,omitempty" proto:"26"`
+	// GuestFriendly is the lobby's guest access in welcome and
+	// set_guest_access frames; Guests lists the guests among UserList
+	GuestFriendly bool     `json:"guest_friendly,omitempty" proto:"27"`
+	Guests        []string `json:"guests,omitempty" proto:"28"`
+	// BudgetMinutes and HourlyRate set a set_budget frame's time budget and
+	// cost per participant hour; Budget reports it in welcome, budget and
+	// lobby_ended frames
+	BudgetMinutes int           `json:"budget_minutes,omitempty" proto:"29"`
+	HourlyRate    float64       `json:"hourly_rate,omitempty" proto:"30"`
+	Budget        *BudgetStatus `json:"budget,omitempty" proto:"31"`
+	// Visibility is the hint of a visibility frame; presence_changed
+	// broadcasts carry the Target user's new Presence and welcome frames
+	// list the Away users
+	Visibility Visibility `json:"visibility,omitempty" proto:"32"`
+	Presence   Presence   `json:"presence,omitempty" proto:"33"`
+	Away       []string   `json:"away,omitempty" proto:"34"`
+	// Imported marks read-only context loaded from an earlier session's
+	// transcript; ImportedFrom names that session's lobby
+	Imported     bool     `json:"imported,omitempty" proto:"35"`
+	ImportedFrom string   `json:"imported_from,omitempty" proto:"36"`
+	Mentions     []string `json:"mentions,omitempty" proto:"37"`
+	// Emoji maps the custom :shortcodes: used in a chat message to their
+	// image URLs; welcome and emoji_changed frames carry the lobby's whole
+	// EmojiPack
+	Emoji     map[string]string `json:"emoji,omitempty" proto:"38"`
+	EmojiPack map[string]Emoji  `json:"emoji_pack,omitempty" proto:"39"`
+	// Profiles maps the member IDs named in a frame to their profiles;
+	// welcome frames also carry the recipient's own MemberID
+	Profiles  map[string]Profile `json:"profiles,omitempty" proto:"40"`
+	MemberID  string             `json:"member_id,omitempty" proto:"41"`
+	IsBot     bool               `json:"is_bot,omitempty" proto:"42"`
+	Seq       int64              `json:"seq,omitempty" proto:"43"`
+	Timestamp time.Time          `json:"timestamp" proto:"44"`
+	// Settings are the lobby's, in welcome and settings_changed frames
+	Settings *LobbySettings `json:"settings,omitempty" proto:"45"`
+	// SlowModeSeconds sets a set_slow_mode frame's cooldown; slow_mode
+	// frames say how long the sender must still wait in RetryAfterMs
+	SlowModeSeconds int   `json:"slow_mode_seconds,omitempty" proto:"46"`
+	RetryAfterMs    int64 `json:"retry_after_ms,omitempty" proto:"47"`
+	// MessageID is the server's ID of a stored message. ClientMsgID is the
+	// sender's own ID for a chat message; a retransmission with the same
+	// one is acked again instead of being sent twice
+	MessageID   string `json:"message_id,omitempty" proto:"48"`
+	ClientMsgID string `json:"client_msg_id,omitempty" proto:"49"`
+	// Welcome frames carry how many messages the recipient hasn't read and
+	// the MessageID of the last one they read
+	Unread   int    `json:"unread,omitempty" proto:"50"`
+	LastRead string `json:"last_read,omitempty" proto:"51"`
+	// ClientTs is when a ping was sent, on the client's clock in Unix
+	// milliseconds, echoed in the pong; RTTMs is the round trip the client
+	// measured last, which client_lagging notices also carry
+	ClientTs int64 `json:"client_ts,omitempty" proto:"52"`
+	RTTMs    int64 `json:"rtt_ms,omitempty" proto:"53"`
+	// Code is the ErrorCode of an error or slow_mode frame
+	Code ErrorCode `json:"code,omitempty" proto:"54"`
+	// Format is how a chat message's Content is rendered; Previews are the
+	// link previews of chat in history and message_enriched frames
+	Format   ContentFormat `json:"format,omitempty" proto:"55"`
+	Previews []LinkPreview `json:"previews,omitempty" proto:"56"`
+	// Action names what a client frame does. It takes the place of Type on
+	// inbound frames, which older clients still send instead
+	Action MessageType `json:"action,omitempty" proto:"57"`
+	// ActionItems are the lobby's tagged messages, on welcome and
+	// action_items frames
+	ActionItems []ActionItem `json:"action_items,omitempty" proto:"58"`
+	// History and Replay are the messages and progress of a history_batch
+	// frame; history_ack frames name the batch they ack in Replay
+	History []Message       `json:"history,omitempty" proto:"59"`
+	Replay  *ReplayProgress `json:"replay,omitempty" proto:"60"`
+	// ExpireAt is when chat sent in ephemeral mode disappears; Expired are
+	// the MessageIDs a message_expired frame tells clients to remove
+	ExpireAt time.Time `json:"expire_at,omitzero" proto:"61"`
+	Expired  []string  `json:"expired,omitempty" proto:"62"`
+	// ChannelID is the whisper group chat or a channel_leave frame is for;
+	// Channel is the one a channel_create frame opens or a channel notice
+	// is about, and welcome frames list the recipient's Channels
+	ChannelID string    `json:"channel_id,omitempty" proto:"63"`
+	Channel   *Channel  `json:"channel,omitempty" proto:"64"`
+	Channels  []Channel `json:"channels,omitempty" proto:"65"`
+	// Redelivered marks a frame of a reliable lobby sent again because the
+	// member hadn't acked it; delivery_ack frames ack every frame up to Seq
+	Redelivered bool `json:"redelivered,omitempty" proto:"66"`
+	// Invite is the invite token a join frame takes a seat in its lobby with
+	Invite string `json:"invite,omitempty" proto:"67"`
 }
 
 type RedisMessage struct {
@@ -37,4 +282,19 @@ type RedisMessage struct {
 	LobbyID   string    `json:"lobby_id"`
 	Timestamp time.Time `json:"timestamp"`
 	MessageID string    `json:"message_id"`
+	Seq       int64     `json:"seq,omitempty"`
+	IsBot     bool      `json:"is_bot,omitempty"`
+	// SystemAction marks a stored system message such as an announcement;
+	// it is empty for chat
+	SystemAction SystemActionType `json:"system_action,omitempty"`
+	// ParentMessageID is set on replies
+	ParentMessageID int64 `json:"parent_message_id,omitempty"`
+	// Idea marks an idea card
+	Idea bool `json:"idea,omitempty"`
+	// Emoji keeps the custom emoji the message used as they were when it
+	// was sent
+	Emoji       map[string]string `json:"emoji,omitempty"`
+	ClientMsgID string            `json:"client_msg_id,omitempty"`
+	Format      ContentFormat     `json:"format,omitempty"`
+	ExpireAt    time.Time         `json:"expire_at,omitzero"`
 }
@@ -0,0 +1,115 @@
+package models
+
+// MessageRing is a fixed-capacity FIFO of messages. Once full, each push
+// overwrites the oldest message. It is not safe for concurrent use; Lobby
+// guards it with its own mutex.
+type MessageRing struct {
+	buf     []Message
+	start   int
+	size    int
+	dropped bool
+}
+
+func NewMessageRing(capacity int) *MessageRing {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &MessageRing{buf: make([]Message, capacity)}
+}
+
+func (r *MessageRing) Push(msg Message) {
+	if r.size < len(r.buf) {
+		r.buf[(r.start+r.size)%len(r.buf)] = msg
+		r.size++
+		return
+	}
+	r.buf[r.start] = msg
+	r.start = (r.start + 1) % len(r.buf)
+	r.dropped = true
+}
+
+func (r *MessageRing) Len() int {
+	return r.size
+}
+
+func (r *MessageRing) Cap() int {
+	return len(r.buf)
+}
+
+// Resize changes the capacity, keeping the newest messages that fit.
+func (r *MessageRing) Resize(capacity int) {
+	capacity = max(capacity, 1)
+	if capacity == len(r.buf) {
+		return
+	}
+	kept := r.Slice()
+	if len(kept) > capacity {
+		kept = kept[len(kept)-capacity:]
+		r.dropped = true
+	}
+	r.buf = make([]Message, capacity)
+	copy(r.buf, kept)
+	r.start = 0
+	r.size = len(kept)
+}
+
+// DropOldest removes the n oldest messages.
+func (r *MessageRing) DropOldest(n int) {
+	n = min(n, r.size)
+	if n <= 0 {
+		return
+	}
+	for i := 0; i < n; i++ {
+		r.buf[(r.start+i)%len(r.buf)] = Message{}
+	}
+	r.start = (r.start + n) % len(r.buf)
+	r.size -= n
+	r.dropped = true
+}
+
+// RemoveFunc removes the messages remove reports true for, wherever they
+// are, and returns them oldest first.
+func (r *MessageRing) RemoveFunc(remove func(Message) bool) []Message {
+	var removed []Message
+	kept := 0
+	for i := 0; i < r.size; i++ {
+		msg := r.buf[(r.start+i)%len(r.buf)]
+		if remove(msg) {
+			removed = append(removed, msg)
+			continue
+		}
+		r.buf[(r.start+kept)%len(r.buf)] = msg
+		kept++
+	}
+	for i := kept; i < r.size; i++ {
+		r.buf[(r.start+i)%len(r.buf)] = Message{}
+	}
+	r.size = kept
+	return removed
+}
+
+// Dropped reports whether any message has been evicted.
+func (r *MessageRing) Dropped() bool {
+	return r.dropped
+}
+
+// Update applies update to the buffered message of seq and reports whether
+// there was one.
+func (r *MessageRing) Update(seq int64, update func(*Message)) bool {
+	for i := 0; i < r.size; i++ {
+		if msg := &r.buf[(r.start+i)%len(r.buf)]; msg.Seq == seq {
+			update(msg)
+			return true
+		}
+	}
+	return false
+}
+
+// Slice returns the buffered messages, oldest first, as a new slice.
+func (r *MessageRing) Slice() []Message {
+	out := make([]Message, r.size)
+	for i := 0; i < r.size; i++ {
+		out[i] = r.buf[(r.start+i)%len(r.buf)]
+	}
+	return out
+}
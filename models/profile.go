@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Profile is how a user appears to the other members of a lobby, in place
+// of their email.
+type Profile struct {
+	DisplayName string    `json:"display_name" proto:"1"`
+	AvatarURL   string    `json:"avatar_url,omitempty" proto:"2"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty" proto:"3"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// ReplayProgress is how far a batched history replay got. Batch is the
+// number of the batch, from 1, out of Batches so far; Sent of Total
+// messages were sent with it. Messages broadcast during the replay join
+// its end, so Batches and Total can grow until Done.
+type ReplayProgress struct {
+	Batch   int  `json:"batch" proto:"1"`
+	Batches int  `json:"batches,omitempty" proto:"2"`
+	Sent    int  `json:"sent,omitempty" proto:"3"`
+	Total   int  `json:"total,omitempty" proto:"4"`
+	Done    bool `json:"done,omitempty" proto:"5"`
+}
+
+// HistoryReplay is a connection's batched catch-up on its lobby's history.
+// It is only touched on the lobby's worker.
+type HistoryReplay struct {
+	// Pending are the messages not sent yet, live broadcasts after the
+	// history
+	Pending []Message
+	Sent    int
+	Total   int
+	// Batch is the last batch sent and Acked the last one the client acked
+	Batch int
+	Acked int
+	// AckedAt is when the replay started or the client last acked a batch
+	AckedAt time.Time
+}
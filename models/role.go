@@ -0,0 +1,19 @@
+package models
+
+// Role is who is acting, as seen by the authorization policy: resolved from
+// the admin key, a bot API key, a session or a lobby membership.
+type Role string
+
+const (
+	RoleAnonymous Role = "anonymous"
+	RoleUser      Role = "user"
+	RoleBot       Role = "bot"
+	RoleAdmin     Role = "admin"
+	// RoleGuest connects with a short-lived guest session
+	RoleGuest Role = "guest"
+
+	// Lobby roles: the creator owns the lobby, moderators help run it
+	RoleOwner       Role = "owner"
+	RoleModerator   Role = "moderator"
+	RoleParticipant Role = "participant"
+)
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// ScheduledLobby is a session booked for a later time. It is opened as a
+// lobby at StartsAt; until then its Participants can't log in.
+type ScheduledLobby struct {
+	ID           string    `json:"id"`
+	TenantID     string    `json:"tenant_id"`
+	StartsAt     time.Time `json:"starts_at"`
+	Participants []string  `json:"participants"`
+	CreatedAt    time.Time `json:"created_at"`
+}
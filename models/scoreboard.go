@@ -0,0 +1,171 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// MessageScore is the reaction and vote tally of one chat message.
+type MessageScore struct {
+	Seq       int64          `json:"seq"`
+	Score     int            `json:"score"`
+	Upvotes   int            `json:"upvotes"`
+	Downvotes int            `json:"downvotes"`
+	Reactions map[string]int `json:"reactions,omitempty"`
+}
+
+// Scoreboard keeps the reactions and votes of a lobby's messages. Scores
+// are updated on every change rather than recomputed from history: a
+// message scores one point per reaction and per upvote, minus its
+// downvotes.
+type Scoreboard struct {
+	entries map[int64]*scoreEntry
+	mu      sync.RWMutex
+}
+
+type scoreEntry struct {
+	// Reactions maps each reaction to the users who gave it
+	Reactions map[string]map[string]bool `json:"reactions"`
+	// Votes maps each voter to +1 or -1
+	Votes map[string]int `json:"votes"`
+	score int
+}
+
+func NewScoreboard() *Scoreboard {
+	return &Scoreboard{entries: make(map[int64]*scoreEntry)}
+}
+
+func (s *Scoreboard) entry(seq int64) *scoreEntry {
+	entry, exists := s.entries[seq]
+	if !exists {
+		entry = &scoreEntry{
+			Reactions: make(map[string]map[string]bool),
+			Votes:     make(map[string]int),
+		}
+		s.entries[seq] = entry
+	}
+	return entry
+}
+
+// ToggleReaction adds the user's reaction to a message, or removes it if it
+// was already there, and returns the message's new tally.
+func (s *Scoreboard) ToggleReaction(seq int64, user, reaction string) MessageScore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(seq)
+	users, exists := entry.Reactions[reaction]
+	if !exists {
+		users = make(map[string]bool)
+		entry.Reactions[reaction] = users
+	}
+
+	if users[user] {
+		delete(users, user)
+		entry.score--
+		if len(users) == 0 {
+			delete(entry.Reactions, reaction)
+		}
+	} else {
+		users[user] = true
+		entry.score++
+	}
+	return entry.tally(seq)
+}
+
+// Vote sets the user's vote on a message to +1, -1 or 0 (no vote) and
+// returns the message's new tally.
+func (s *Scoreboard) Vote(seq int64, user string, vote int) MessageScore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(seq)
+	entry.score += vote - entry.Votes[user]
+	if vote == 0 {
+		delete(entry.Votes, user)
+	} else {
+		entry.Votes[user] = vote
+	}
+	return entry.tally(seq)
+}
+
+// Tally returns a message's current reactions and votes.
+func (s *Scoreboard) Tally(seq int64) MessageScore {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, exists := s.entries[seq]
+	if !exists {
+		return MessageScore{Seq: seq}
+	}
+	return entry.tally(seq)
+}
+
+// Top returns the n highest scoring messages; equal scores rank the more
+// recent message first.
+func (s *Scoreboard) Top(n int) []MessageScore {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	scores := make([]MessageScore, 0, len(s.entries))
+	for seq, entry := range s.entries {
+		if len(entry.Reactions) > 0 || len(entry.Votes) > 0 {
+			scores = append(scores, entry.tally(seq))
+		}
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].Seq > scores[j].Seq
+	})
+	return scores[:min(n, len(scores))]
+}
+
+func (e *scoreEntry) tally(seq int64) MessageScore {
+	score := MessageScore{Seq: seq, Score: e.score, Reactions: make(map[string]int, len(e.Reactions))}
+	for reaction, users := range e.Reactions {
+		score.Reactions[reaction] = len(users)
+	}
+	for _, vote := range e.Votes {
+		if vote > 0 {
+			score.Upvotes++
+		} else {
+			score.Downvotes++
+		}
+	}
+	return score
+}
+
+func (s *Scoreboard) MarshalJSON() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return json.Marshal(s.entries)
+}
+
+func (s *Scoreboard) UnmarshalJSON(data []byte) error {
+	entries := make(map[int64]*scoreEntry)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Reactions == nil {
+			entry.Reactions = make(map[string]map[string]bool)
+		}
+		if entry.Votes == nil {
+			entry.Votes = make(map[string]int)
+		}
+		for _, users := range entry.Reactions {
+			entry.score += len(users)
+		}
+		for _, vote := range entry.Votes {
+			entry.score += vote
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = entries
+	return nil
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type Session struct {
+	Token     string    `json:"token"`
+	Email     string    `json:"email"`
+	Provider  string    `json:"provider"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Guest sessions belong to a generated display name, not an email
+	Guest bool `json:"guest,omitempty"`
+}
+
+// ConnectTicket is what a one-time /ws ticket stands for: the user and the
+// lobby they may connect to.
+type ConnectTicket struct {
+	Email   string `json:"email"`
+	LobbyID string `json:"lobby_id"`
+}
@@ -2,10 +2,35 @@ package models
 
 import "time"
 
+// Presence is whether a member is watching the session.
+type Presence string
+
+const (
+	PresenceOnline  Presence = "online"
+	PresenceAway    Presence = "away"
+	PresenceOffline Presence = "offline"
+)
+
+// Visibility is the client's hint of whether its tab is shown.
+type Visibility string
+
+const (
+	VisibilityVisible Visibility = "visible"
+	VisibilityHidden  Visibility = "hidden"
+)
+
 type User struct {
 	Email    string    `json:"email"`
 	LobbyID  string    `json:"lobby_id"`
 	JoinedAt time.Time `json:"joined_at"`
 	IsActive bool      `json:"is_active"`
 	LastSeen time.Time `json:"last_seen"`
+	// Role is the user's role within the lobby
+	Role Role `json:"role"`
+	// Guest users joined anonymously under a generated display name
+	Guest bool `json:"guest,omitempty"`
+	// Presence is derived from the connection and HiddenSince, the time the
+	// client last reported its tab hidden
+	Presence    Presence  `json:"presence,omitempty"`
+	HiddenSince time.Time `json:"-"`
 }
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Webhook event names
+const (
+	WebhookEventMessageSent  = "message_sent"
+	WebhookEventUserJoined   = "user_joined"
+	WebhookEventLobbyCreated = "lobby_created"
+	WebhookEventLobbyEnded   = "lobby_ended"
+	// WebhookEventLobbyOpened is a scheduled session opening; its data
+	// lists the participants to notify
+	WebhookEventLobbyOpened = "lobby_opened"
+	// WebhookEventActionItems carries the messages a moderator just tagged
+	// as action items, for opening tickets from them
+	WebhookEventActionItems = "action_items"
+)
+
+// Webhook is an admin-registered endpoint that receives chat events. An empty
+// LobbyID subscribes to every lobby and empty Events to every event.
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url" openapi:"required"`
+	LobbyID   string    `json:"lobby_id,omitempty"`
+	Events    []string  `json:"events,omitempty"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookEvent is the JSON body POSTed to webhooks.
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	LobbyID   string      `json:"lobby_id"`
+	TenantID  string      `json:"tenant_id,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
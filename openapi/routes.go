@@ -0,0 +1,128 @@
+package openapi
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/handlers"
+	"chat-integrated/models"
+	"chat-integrated/services"
+	"net/http"
+)
+
+// Route describes one method of an endpoint. Its request and response
+// schemas come from the types of Body and Response.
+type Route struct {
+	Method  string
+	Path    string
+	Summary string
+	Tag     string
+	// Security lists the credentials that are accepted, any one of
+	// "admin", "apiKey", "session" or "bot"
+	Security []string
+	Query    []string
+
+	// Body is a value of the JSON request body's type
+	Body interface{}
+	// MaxBody bounds the body read for validation, config.MaxRequestBodySize
+	// if 0
+	MaxBody int64
+	// Upload takes a multipart "file" with the Form fields instead
+	Upload bool
+	Form   []string
+
+	// Status is the success status, 200 if 0, with a body of Response's
+	// type, or of media type Produces if it isn't JSON
+	Status   int
+	Response interface{}
+	Produces string
+	// Also lists other success statuses with the same body
+	Also []int
+}
+
+var (
+	member = []string{"session", "apiKey", "admin"}
+	admin  = []string{"admin", "apiKey"}
+)
+
+// Routes lists the HTTP API the server mounts.
+var Routes = []Route{
+	{Method: "POST", Path: "/api/login", Tag: "auth", Summary: "Log in with a password or login link and take a lobby seat, or a place in the waiting queue or matchmaking", Body: handlers.LoginRequest{}, Response: handlers.LoginResponse{}, Also: []int{http.StatusAccepted, http.StatusTooEarly}},
+	{Method: "POST", Path: "/api/login/magic-link", Tag: "auth", Summary: "Mail an account a one-time login link", Body: handlers.EmailRequest{}, Status: http.StatusAccepted, Response: handlers.LinkSentResponse{}},
+	{Method: "POST", Path: "/api/register", Tag: "auth", Summary: "Create an account with a password", Body: handlers.RegisterRequest{}, Status: http.StatusCreated, Response: handlers.AccountResponse{}},
+	{Method: "POST", Path: "/api/password-reset", Tag: "auth", Summary: "Mail an account a password reset link", Body: handlers.EmailRequest{}, Status: http.StatusAccepted, Response: handlers.LinkSentResponse{}},
+	{Method: "POST", Path: "/api/password-reset/confirm", Tag: "auth", Summary: "Set a new password with a reset link's token, logging out every session", Body: handlers.PasswordResetRequest{}, Response: handlers.LinkSentResponse{}},
+	{Method: "POST", Path: "/api/logout", Tag: "auth", Summary: "End the caller's session", Security: []string{"session"}, Status: http.StatusNoContent},
+	{Method: "POST", Path: "/api/lobbies/{id}/connect-ticket", Tag: "auth", Summary: "A one-time ticket to open /ws to a lobby the caller is seated in", Security: []string{"session"}, Query: []string{"email", "token"}, Response: handlers.ConnectTicketResponse{}},
+	{Method: "GET", Path: "/api/sessions", Tag: "auth", Summary: "The caller's live sessions", Security: []string{"session"}, Response: handlers.SessionsResponse{}},
+	{Method: "DELETE", Path: "/api/sessions/{id}", Tag: "auth", Summary: "Log out one of the caller's sessions", Security: []string{"session"}, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/queue", Tag: "auth", Summary: "Report a queue ticket's place in line, or the lobby it was admitted to", Query: []string{"ticket"}, Response: services.QueueStatus{}},
+	{Method: "DELETE", Path: "/api/queue", Tag: "auth", Summary: "Give up a place in the waiting queue", Query: []string{"ticket"}, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/matchmaking", Tag: "auth", Summary: "Report whether a matchmaking ticket was seated in a lobby of its topics, or fell back to the tenant's lobby", Query: []string{"ticket"}, Response: services.MatchStatus{}},
+	{Method: "DELETE", Path: "/api/matchmaking", Tag: "auth", Summary: "Stop looking for a match", Query: []string{"ticket"}, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/topics", Tag: "status", Summary: "The tenant's topics with how full their lobbies are", Response: handlers.TopicsResponse{}},
+	{Method: "POST", Path: "/api/guest", Tag: "auth", Summary: "Join a guest-friendly lobby without an account", Body: handlers.GuestRequest{}, Response: handlers.GuestResponse{}},
+	{Method: "PUT", Path: "/api/profile", Tag: "auth", Summary: "Set the caller's display name and avatar", Security: []string{"session"}, Body: handlers.ProfileRequest{}, Response: models.Profile{}},
+	{Method: "GET", Path: "/auth/{provider}/login", Tag: "auth", Summary: "Start a login with an OAuth provider", Status: http.StatusFound},
+	{Method: "GET", Path: "/auth/{provider}/callback", Tag: "auth", Summary: "Finish an OAuth login", Query: []string{"code", "state"}, Status: http.StatusFound},
+
+	{Method: "GET", Path: "/api/status", Tag: "status", Summary: "Describe the lobby new users join", Response: handlers.StatusResponse{}},
+	{Method: "GET", Path: "/api/v2/status", Tag: "status", Summary: "The server's uptime, build, lobbies, connections and store latency", Response: handlers.StatusV2Response{}},
+	{Method: "GET", Path: "/api/config", Tag: "status", Summary: "Runtime configuration for clients"},
+	{Method: "GET", Path: "/api/branding", Tag: "status", Summary: "The caller's tenant branding", Response: models.Branding{}},
+	{Method: "GET", Path: "/healthz", Tag: "status", Summary: "Liveness"},
+	{Method: "GET", Path: "/readyz", Tag: "status", Summary: "Readiness of the store and lobby workers"},
+	{Method: "GET", Path: "/metrics", Tag: "status", Summary: "Prometheus metrics", Produces: "text/plain"},
+	{Method: "GET", Path: "/api/openapi.json", Tag: "status", Summary: "This document", Produces: "application/json"},
+
+	{Method: "GET", Path: "/api/lobbies/{id}/history", Tag: "messages", Summary: "A page of messages older than before", Security: member, Query: []string{"before", "limit"}, Response: handlers.HistoryResponse{}},
+	{Method: "GET", Path: "/api/lobbies/{id}/search", Tag: "messages", Summary: "Search the lobby's messages", Security: member, Query: []string{"q", "case_sensitive", "sender", "from", "to", "context"}},
+	{Method: "GET", Path: "/api/lobbies/{id}/export", Tag: "messages", Summary: "Download the transcript as JSON, CSV or text", Security: member, Query: []string{"format"}},
+	{Method: "GET", Path: "/api/lobbies/{id}/top", Tag: "messages", Summary: "The lobby's highest scored messages", Security: member, Query: []string{"limit"}},
+	{Method: "GET", Path: "/api/lobbies/{id}/ideas", Tag: "messages", Summary: "The lobby's idea cards, best voted first", Security: member, Query: []string{"limit"}, Response: handlers.IdeasResponse{}},
+	{Method: "GET", Path: "/api/lobbies/{id}/unread", Tag: "messages", Summary: "The calling member's last-read message and unread count", Security: []string{"session"}, Query: []string{"email", "token"}, Response: services.ReadStatus{}},
+	{Method: "GET", Path: "/api/lobbies/{id}/threads/{messageID}", Tag: "messages", Summary: "A message and its replies", Security: member},
+	{Method: "POST", Path: "/api/lobbies/{id}/bot-message", Tag: "messages", Summary: "Post a message as a bot", Security: []string{"bot"}, Body: handlers.BotMessageRequest{}, Status: http.StatusAccepted},
+
+	{Method: "POST", Path: "/api/lobbies", Tag: "lobbies", Summary: "Open a follow-up session of an earlier one", Security: member, Body: handlers.CreateLobbyRequest{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/api/lobbies/schedule", Tag: "lobbies", Summary: "Book a session that opens at a later time for the listed participants", Security: member, Body: handlers.ScheduleRequest{}, Status: http.StatusCreated, Response: models.ScheduledLobby{}},
+	{Method: "GET", Path: "/api/lobbies/schedule", Tag: "lobbies", Summary: "The tenant's booked sessions that haven't opened yet", Security: member},
+	{Method: "GET", Path: "/api/lobbies/{id}/sessions", Tag: "lobbies", Summary: "The chain of sessions leading to the lobby", Security: member},
+	{Method: "POST", Path: "/api/lobbies/{id}/import", Tag: "lobbies", Summary: "Import an earlier session's transcript as context", Security: member, Body: handlers.ImportRequest{}, MaxBody: config.MaxImportSize},
+	{Method: "GET", Path: "/api/lobbies/{id}/settings", Tag: "lobbies", Summary: "The lobby's settings", Security: member, Response: models.LobbySettings{}},
+	{Method: "PATCH", Path: "/api/lobbies/{id}/settings", Tag: "lobbies", Summary: "Change some of the lobby's settings, as its owner", Security: member, Body: handlers.SettingsRequest{}, Response: models.LobbySettings{}},
+	{Method: "GET", Path: "/api/lobbies/{id}/summary", Tag: "lobbies", Summary: "The summary of an ended session: participants, duration, message counts and transcript", Security: member, Response: handlers.SummaryResponse{}},
+	{Method: "GET", Path: "/api/lobbies/{id}/archive", Tag: "lobbies", Summary: "A presigned download link to an ended session's archived transcript", Security: member, Response: handlers.ArchiveResponse{}},
+	{Method: "GET", Path: "/api/lobbies/{id}/presence", Tag: "lobbies", Summary: "The members' presence and the round trip of each connected client", Security: member, Response: handlers.PresenceResponse{}},
+	{Method: "GET", Path: "/api/lobbies/{id}/actions", Tag: "lobbies", Summary: "The action items of a lobby or an ended session, as JSON or CSV", Security: member, Query: []string{"format"}, Response: handlers.ActionItemsResponse{}},
+	{Method: "POST", Path: "/api/lobbies/{id}/actions", Tag: "lobbies", Summary: "Tag messages as action items, as the owner or a moderator", Security: member, Body: handlers.ActionItemsRequest{}, Response: handlers.ActionItemsResponse{}},
+	{Method: "DELETE", Path: "/api/lobbies/{id}/actions/{messageID}", Tag: "lobbies", Summary: "Take a message off the action items, as the owner or a moderator", Security: member, Status: http.StatusNoContent},
+	{Method: "POST", Path: "/api/lobbies/{id}/invites", Tag: "lobbies", Summary: "Issue an invite link to the lobby, as its owner or a moderator", Security: member, Body: handlers.InviteRequest{}, Status: http.StatusCreated, Response: handlers.InviteResponse{}},
+	{Method: "GET", Path: "/api/lobbies/{id}/emoji", Tag: "lobbies", Summary: "The custom emoji usable in the lobby", Security: member},
+	{Method: "POST", Path: "/api/lobbies/{id}/emoji", Tag: "lobbies", Summary: "Register a custom emoji on the lobby", Security: member, Upload: true, Form: []string{"name"}, Response: models.Emoji{}},
+	{Method: "DELETE", Path: "/api/lobbies/{id}/emoji/{name}", Tag: "lobbies", Summary: "Remove a custom emoji from the lobby", Security: member, Status: http.StatusNoContent},
+	{Method: "POST", Path: "/api/attachments", Tag: "lobbies", Summary: "Upload an attachment", Security: member, Upload: true, Response: models.Attachment{}},
+	{Method: "DELETE", Path: "/api/attachments/{hash}", Tag: "lobbies", Summary: "Release an attachment", Security: member},
+	{Method: "GET", Path: "/attachments/{hash}", Tag: "lobbies", Summary: "Download an attachment"},
+	{Method: "GET", Path: "/attachments/{hash}/thumbnail", Tag: "lobbies", Summary: "Download an image attachment's thumbnail"},
+
+	{Method: "POST", Path: "/graphql", Tag: "graphql", Summary: "Run a GraphQL query or mutation; subscriptions are served over a WebSocket upgrade of GET /graphql", Security: member, Body: handlers.GraphQLRequest{}},
+
+	{Method: "GET", Path: "/api/admin/tenants/{tenant}/branding", Tag: "admin", Summary: "A tenant's branding", Security: admin, Response: models.Branding{}},
+	{Method: "PUT", Path: "/api/admin/tenants/{tenant}/branding", Tag: "admin", Summary: "Set a tenant's branding", Security: admin, Body: models.Branding{}, Response: models.Branding{}},
+	{Method: "GET", Path: "/api/admin/tenants/{tenant}/emoji", Tag: "admin", Summary: "A tenant's custom emoji", Security: admin},
+	{Method: "POST", Path: "/api/admin/tenants/{tenant}/emoji", Tag: "admin", Summary: "Register a custom emoji for every lobby of a tenant", Security: admin, Upload: true, Form: []string{"name"}, Response: models.Emoji{}},
+	{Method: "DELETE", Path: "/api/admin/tenants/{tenant}/emoji/{name}", Tag: "admin", Summary: "Remove a tenant's custom emoji", Security: admin, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/admin/config", Tag: "admin", Summary: "The effective settings and where each came from", Security: admin},
+	{Method: "GET", Path: "/api/admin/webhooks", Tag: "admin", Summary: "The registered webhooks", Security: admin},
+	{Method: "POST", Path: "/api/admin/webhooks", Tag: "admin", Summary: "Register a webhook", Security: admin, Body: models.Webhook{}, Status: http.StatusCreated, Response: models.Webhook{}},
+	{Method: "DELETE", Path: "/api/admin/webhooks/{id}", Tag: "admin", Summary: "Remove a webhook", Security: admin},
+	{Method: "GET", Path: "/api/admin/bots", Tag: "admin", Summary: "The registered bots", Security: admin},
+	{Method: "POST", Path: "/api/admin/bots", Tag: "admin", Summary: "Create a bot and its API key", Security: admin, Body: handlers.CreateBotRequest{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/api/admin/bots/{id}", Tag: "admin", Summary: "Remove a bot", Security: admin},
+	{Method: "GET", Path: "/api/admin/api-keys", Tag: "admin", Summary: "The API keys, without the keys", Security: admin},
+	{Method: "POST", Path: "/api/admin/api-keys", Tag: "admin", Summary: "Issue an API key acting as a user or admin", Security: admin, Body: handlers.CreateAPIKeyRequest{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/api/admin/api-keys/{id}", Tag: "admin", Summary: "Revoke an API key", Security: admin, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/admin/moderation/violations", Tag: "admin", Summary: "Recent moderation violations", Security: admin},
+	{Method: "GET", Path: "/api/admin/audit", Tag: "admin", Summary: "Audit log of administrative and lifecycle events", Security: admin, Query: []string{"lobby_id", "actor", "from", "to", "limit"}, Response: handlers.AuditResponse{}},
+	{Method: "GET", Path: "/api/admin/dashboard", Tag: "admin", Summary: "Snapshot of every lobby, the waiting lines and the store", Security: admin, Response: services.Dashboard{}},
+	{Method: "POST", Path: "/api/admin/announce", Tag: "admin", Summary: "Send a notice to every lobby", Security: admin, Body: handlers.AnnounceRequest{}},
+}
@@ -0,0 +1,182 @@
+// Package openapi describes the HTTP API as an OpenAPI 3 document generated
+// from the Go types the handlers decode and encode, and validates request
+// bodies against it.
+package openapi
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Document is an OpenAPI 3.0 document.
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Servers    []ServerURL                     `json:"servers,omitempty"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type ServerURL struct {
+	URL string `json:"url"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+type Operation struct {
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Error is the body of error responses. Validation failures list each
+// problem in Details.
+type Error struct {
+	models.ErrorResponse
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// FieldError is one way a request body doesn't match its schema. Field is
+// the path to the value, like "messages[2].content", or "" for the body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+const apiVersion = "1.0.0"
+
+var pathParamPattern = regexp.MustCompile(`\{([a-zA-Z]+)\}`)
+
+var securitySchemes = map[string]SecurityScheme{
+	"admin":   {Type: "apiKey", In: "header", Name: config.AdminKeyHeader},
+	"session": {Type: "apiKey", In: "cookie", Name: config.SessionCookieName},
+	"bot":     {Type: "http", Scheme: "bearer"},
+	"apiKey":  {Type: "http", Scheme: "bearer"},
+}
+
+// Build generates the document for routes served below prefix.
+func Build(prefix string, routes []Route) *Document {
+	g := newGenerator()
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: config.ServiceName, Version: apiVersion},
+		Servers: []ServerURL{{URL: prefix + "/"}},
+		Paths:   make(map[string]map[string]Operation),
+	}
+
+	for _, route := range routes {
+		op := Operation{
+			Summary:   route.Summary,
+			Tags:      []string{route.Tag},
+			Responses: make(map[string]Response),
+		}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			op.Parameters = append(op.Parameters, Parameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+		for _, name := range route.Query {
+			op.Parameters = append(op.Parameters, Parameter{Name: name, In: "query", Schema: &Schema{Type: "string"}})
+		}
+		for _, name := range route.Security {
+			op.Security = append(op.Security, map[string][]string{name: {}})
+		}
+
+		switch {
+		case route.Body != nil:
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{"application/json": {Schema: g.schemaOf(reflect.TypeOf(route.Body))}},
+			}
+		case route.Upload:
+			form := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+			for _, field := range route.Form {
+				form.Properties[field] = &Schema{Type: "string"}
+			}
+			form.Properties["file"] = &Schema{Type: "string", Format: "binary"}
+			form.Required = append(append(form.Required, route.Form...), "file")
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{"multipart/form-data": {Schema: form}},
+			}
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		response := Response{Description: http.StatusText(status)}
+		switch {
+		case route.Response != nil:
+			response.Content = map[string]MediaType{"application/json": {Schema: g.schemaOf(reflect.TypeOf(route.Response))}}
+		case route.Produces != "":
+			response.Content = map[string]MediaType{route.Produces: {Schema: &Schema{Type: "string"}}}
+		}
+		op.Responses[fmt.Sprint(status)] = response
+		for _, also := range route.Also {
+			op.Responses[fmt.Sprint(also)] = Response{Description: http.StatusText(also), Content: response.Content}
+		}
+		op.Responses["default"] = Response{
+			Description: "Error",
+			Content:     map[string]MediaType{"application/json": {Schema: g.schemaOf(reflect.TypeFor[Error]())}},
+		}
+
+		if doc.Paths[route.Path] == nil {
+			doc.Paths[route.Path] = make(map[string]Operation)
+		}
+		doc.Paths[route.Path][strings.ToLower(route.Method)] = op
+	}
+
+	doc.Components = Components{Schemas: g.schemas, SecuritySchemes: securitySchemes}
+	return doc
+}
+
+// resolve follows a $ref to the component it names.
+func (doc *Document) resolve(schema *Schema) *Schema {
+	for schema.Ref != "" {
+		schema = doc.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
@@ -0,0 +1,203 @@
+package openapi
+
+import (
+	"bytes"
+	"chat-integrated/config"
+	"chat-integrated/controllers"
+	"chat-integrated/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxFieldErrors bounds how many problems one response lists
+const maxFieldErrors = 20
+
+// Handler serves the document as JSON.
+func Handler(controller *controllers.APIController, doc *Document) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if controller.HandlePreflight(w, r) {
+			return
+		}
+		if r.Method != "GET" {
+			controller.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		controller.RespondJSON(w, http.StatusOK, doc)
+	})
+}
+
+// Validate checks the JSON bodies of the routes that take one against the
+// document before next sees them. A body that doesn't match is answered
+// with a 400 listing each problem; requests to other routes pass through.
+func Validate(controller *controllers.APIController, doc *Document, prefix string, routes []Route, next http.Handler) http.Handler {
+	// A mux of its own matches methods and {wildcards} like the server's
+	validators := http.NewServeMux()
+	for _, route := range routes {
+		if route.Body == nil {
+			continue
+		}
+		schema := doc.Paths[route.Path][strings.ToLower(route.Method)].RequestBody.Content["application/json"].Schema
+		maxBody := route.MaxBody
+		if maxBody == 0 {
+			maxBody = config.MaxRequestBodySize
+		}
+		validators.Handle(route.Method+" "+prefix+route.Path, validator(controller, doc, schema, maxBody, next))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler, pattern := validators.Handler(r); pattern != "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validator(controller *controllers.APIController, doc *Document, schema *Schema, maxBody int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			controller.RespondJSON(w, http.StatusRequestEntityTooLarge, Error{ErrorResponse: models.ErrorResponse{Error: "Request body too large", Code: models.ErrorTooLarge}})
+			return
+		}
+		if err != nil {
+			controller.RespondJSON(w, http.StatusBadRequest, Error{ErrorResponse: models.ErrorResponse{Error: "Failed to read request body", Code: models.ErrorBadRequest}})
+			return
+		}
+
+		if problems := doc.validateBody(schema, body); len(problems) > 0 {
+			controller.RespondJSON(w, http.StatusBadRequest, Error{ErrorResponse: models.ErrorResponse{Error: "Invalid request body", Code: models.ErrorBadRequest}, Details: problems})
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (doc *Document) validateBody(schema *Schema, body []byte) []FieldError {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		if errors.Is(err, io.EOF) {
+			return []FieldError{{Message: "a JSON body is required"}}
+		}
+		return []FieldError{{Message: "invalid JSON: " + err.Error()}}
+	}
+	if decoder.More() {
+		return []FieldError{{Message: "the body must be a single JSON value"}}
+	}
+
+	var problems []FieldError
+	doc.validate(schema, value, "", &problems)
+	return problems
+}
+
+// validate checks value against schema, adding each mismatch to problems.
+// Null is accepted anywhere, as encoding/json leaves the field unset.
+func (doc *Document) validate(schema *Schema, value interface{}, field string, problems *[]FieldError) {
+	if len(*problems) >= maxFieldErrors || value == nil {
+		return
+	}
+	schema = doc.resolve(schema)
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch schema.Type {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+		} else if len(s) < schema.MinLength {
+			fail("must not be empty")
+		} else if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				fail("must be an RFC 3339 date-time")
+			}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+		}
+	case "integer":
+		n, ok := value.(json.Number)
+		if _, err := n.Int64(); !ok || err != nil {
+			fail("must be an integer")
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			fail("must be a number")
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, item := range items {
+			doc.validate(schema.Items, item, fmt.Sprintf("%s[%d]", field, i), problems)
+		}
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range schema.Required {
+			if _, value := lookup(object, name); value == nil {
+				*problems = append(*problems, FieldError{Field: join(field, name), Message: "is required"})
+			}
+		}
+		if schema.AdditionalProperties != nil {
+			for _, name := range sortedKeys(object) {
+				doc.validate(schema.AdditionalProperties, object[name], join(field, name), problems)
+			}
+			return
+		}
+		for _, name := range sortedKeys(schema.Properties) {
+			if key, value := lookup(object, name); value != nil {
+				doc.validate(schema.Properties[name], value, join(field, key), problems)
+			}
+		}
+	}
+}
+
+func join(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+// lookup finds the member of object that encoding/json would decode into
+// the field name: an exact match, or else one differing only in case.
+func lookup(object map[string]interface{}, name string) (string, interface{}) {
+	if value, exists := object[name]; exists {
+		return name, value
+	}
+	for _, key := range sortedKeys(object) {
+		if strings.EqualFold(key, name) {
+			return key, object[key]
+		}
+	}
+	return name, nil
+}
+
+// sortedKeys keeps the order of problems stable.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,484 @@
+// The end-to-end scenarios run the whole stack: a hub with the memory store
+// behind an httptest server, and real WebSocket clients driven through
+// login, chat, disconnects, reconnects and the waiting queue, checking what
+// each one receives.
+//
+//	go test ./server -run TestEndToEnd/reconnect -v -e2e.log
+//
+// Every scenario has its own tenant, so its lobby starts empty. They are
+// skipped with -short.
+package server_test
+
+import (
+	"chat-integrated/client"
+	"chat-integrated/middleware"
+	"chat-integrated/models"
+	"chat-integrated/server"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var serverLog = flag.Bool("e2e.log", false, "show the server's log")
+
+const (
+	// scenarioTimeout is how long each scenario may take
+	scenarioTimeout = 20 * time.Second
+	// capacity is the seats per lobby
+	capacity = 3
+)
+
+// password is what every scenario user registers with.
+const password = "e2e-password-1"
+
+// scenario is one end-to-end flow; it returns why it failed.
+type scenario struct {
+	name string
+	run  func(ctx context.Context, h *harness) error
+}
+
+var scenarios = []scenario{
+	{"broadcast ordering", broadcastOrdering},
+	{"history replay", historyReplay},
+	{"reconnect", reconnect},
+	{"lobby capacity", lobbyCapacity},
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if !*serverLog {
+		log.SetOutput(io.Discard)
+	}
+	os.Exit(m.Run())
+}
+
+func TestEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end scenarios don't run with -short")
+	}
+	h := newHarness(t)
+	for _, sc := range scenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), scenarioTimeout)
+			defer cancel()
+			if err := sc.run(ctx, h); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// newHarness starts the server the scenarios run against, stopped when t
+// ends.
+func newHarness(t *testing.T) *harness {
+	cfg := server.DefaultConfig()
+	cfg.Name = "e2e"
+	cfg.StoreBackend = "memory"
+	cfg.MaxUsersPerLobby = capacity
+	cfg.AttachmentDir = t.TempDir()
+	cfg.ProbeEnabled = false
+	cfg.GRPCAddr = ""
+	hub := server.NewHub(cfg)
+	mux := http.NewServeMux()
+	server.NewServer(hub).Mount(mux)
+	hub.Start()
+	srv := httptest.NewServer(middleware.Stack(mux))
+	t.Cleanup(func() {
+		srv.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		hub.Shutdown(ctx)
+		hub.Close()
+	})
+	return &harness{baseURL: srv.URL, hub: hub, runID: time.Now().UnixNano()}
+}
+
+// harness is the server the scenarios run against.
+type harness struct {
+	baseURL string
+	hub     *server.Hub
+	runID   int64
+
+	mu      sync.Mutex
+	tenants int
+}
+
+// tenant returns a tenant no scenario used yet.
+func (h *harness) tenant() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tenants++
+	return fmt.Sprintf("e2e-%d-%d", h.runID, h.tenants)
+}
+
+// seat registers name in tenant and logs them in, waiting in the queue if
+// the lobby is full.
+func (h *harness) seat(ctx context.Context, tenant, name string) (client.Seat, error) {
+	email := fmt.Sprintf("%s@%s.e2e.local", name, tenant)
+	if _, err := h.hub.Accounts.Register(email, password); err != nil {
+		return client.Seat{}, fmt.Errorf("register %s: %w", name, err)
+	}
+	seat, err := client.LoginWithPassword(ctx, h.baseURL, email, password, tenant)
+	if err != nil {
+		return client.Seat{}, fmt.Errorf("login %s: %w", name, err)
+	}
+	return seat, nil
+}
+
+// join seats name in tenant and connects them.
+func (h *harness) join(ctx context.Context, tenant, name string) (*member, error) {
+	seat, err := h.seat(ctx, tenant, name)
+	if err != nil {
+		return nil, err
+	}
+	return h.connect(ctx, name, seat)
+}
+
+// connect opens a client for seat that records the chat it receives, on
+// connections the scenario can cut.
+func (h *harness) connect(ctx context.Context, name string, seat client.Seat) (*member, error) {
+	m := &member{name: name, changed: make(chan struct{}, 1)}
+	dialer := *websocket.DefaultDialer
+	dialer.NetDialContext = m.dial
+	c, err := client.Connect(ctx, h.baseURL, seat, client.Options{
+		Dialer:     &dialer,
+		MinBackoff: 50 * time.Millisecond,
+		MaxBackoff: 200 * time.Millisecond,
+		OnMessage:  m.record,
+		OnReconnect: func(error) {
+			m.mu.Lock()
+			m.reconnects++
+			m.mu.Unlock()
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect %s: %w", name, err)
+	}
+	m.Client = c
+	return m, nil
+}
+
+// member is a connected scenario user and the chat they received.
+type member struct {
+	*client.Client
+	name string
+
+	mu         sync.Mutex
+	received   []models.Message
+	reconnects int
+	conns      []net.Conn
+	changed    chan struct{}
+}
+
+func (m *member) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+	if err == nil {
+		m.mu.Lock()
+		m.conns = append(m.conns, conn)
+		m.mu.Unlock()
+	}
+	return conn, err
+}
+
+func (m *member) record(msg models.Message) {
+	m.mu.Lock()
+	m.received = append(m.received, msg)
+	m.mu.Unlock()
+	select {
+	case m.changed <- struct{}{}:
+	default:
+	}
+}
+
+// cut drops the member's connections at the TCP level, as a flaky network
+// would, leaving the client to reconnect.
+func (m *member) cut() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, conn := range m.conns {
+		conn.Close()
+	}
+	m.conns = nil
+}
+
+// waitFor waits until the member has received n chat messages.
+func (m *member) waitFor(ctx context.Context, n int) ([]models.Message, error) {
+	for {
+		m.mu.Lock()
+		received := slices.Clone(m.received)
+		m.mu.Unlock()
+		if len(received) >= n {
+			return received, nil
+		}
+		select {
+		case <-ctx.Done():
+			return received, fmt.Errorf("%s received %d of %d messages", m.name, len(received), n)
+		case <-m.changed:
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// inOrder checks that msgs have strictly increasing seqs and message IDs.
+func inOrder(name string, msgs []models.Message) error {
+	for i := 1; i < len(msgs); i++ {
+		if msgs[i].Seq <= msgs[i-1].Seq {
+			return fmt.Errorf("%s got seq %d after %d", name, msgs[i].Seq, msgs[i-1].Seq)
+		}
+		if msgs[i].MessageID == "" || msgs[i].MessageID == msgs[i-1].MessageID {
+			return fmt.Errorf("%s got message %d without a new message ID", name, msgs[i].Seq)
+		}
+	}
+	return nil
+}
+
+// sameOrder checks that two members received the same messages in the same
+// order.
+func sameOrder(a, b *member, msgsA, msgsB []models.Message) error {
+	if len(msgsA) != len(msgsB) {
+		return fmt.Errorf("%s received %d messages and %s %d", a.name, len(msgsA), b.name, len(msgsB))
+	}
+	for i := range msgsA {
+		if msgsA[i].MessageID != msgsB[i].MessageID {
+			return fmt.Errorf("message %d is %s for %s but %s for %s", i, msgsA[i].MessageID, a.name, msgsB[i].MessageID, b.name)
+		}
+	}
+	return nil
+}
+
+// send sends n numbered messages from m.
+func send(m *member, n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := m.SendMessage(fmt.Sprintf("%s #%d", m.name, i)); err != nil {
+			return fmt.Errorf("%s send: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
+// closeAll closes the members' clients.
+func closeAll(members ...*member) {
+	for _, m := range members {
+		if m != nil {
+			m.Close()
+		}
+	}
+}
+
+// broadcastOrdering has every member chat at once and checks that all of
+// them receive every message, in one order, with each sender's messages in
+// the order sent.
+func broadcastOrdering(ctx context.Context, h *harness) error {
+	const perMember = 10
+	tenant := h.tenant()
+	var members []*member
+	defer func() { closeAll(members...) }()
+	for _, name := range []string{"ada", "bob", "cy"} {
+		m, err := h.join(ctx, tenant, name)
+		if err != nil {
+			return err
+		}
+		members = append(members, m)
+	}
+
+	errs := make(chan error, len(members))
+	for _, m := range members {
+		go func() { errs <- send(m, perMember) }()
+	}
+	for range members {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+
+	total := perMember * len(members)
+	var first []models.Message
+	for i, m := range members {
+		msgs, err := m.waitFor(ctx, total)
+		if err != nil {
+			return err
+		}
+		if len(msgs) != total {
+			return fmt.Errorf("%s received %d messages, expected %d", m.name, len(msgs), total)
+		}
+		if err := inOrder(m.name, msgs); err != nil {
+			return err
+		}
+		if i == 0 {
+			first = msgs
+		} else if err := sameOrder(members[0], m, first, msgs); err != nil {
+			return err
+		}
+	}
+
+	// Each sender's messages arrive in the order they were sent
+	next := make(map[string]int)
+	for _, msg := range first {
+		sender, n, _ := strings.Cut(msg.Content, " #")
+		if want := fmt.Sprint(next[sender]); n != want {
+			return fmt.Errorf("%s's message %s arrived where %s was expected", sender, n, want)
+		}
+		next[sender]++
+	}
+	return nil
+}
+
+// historyReplay has a member join after the lobby chatted and checks that
+// the replay brings them every message, in order.
+func historyReplay(ctx context.Context, h *harness) error {
+	const sent = 15
+	tenant := h.tenant()
+	ada, err := h.join(ctx, tenant, "ada")
+	if err != nil {
+		return err
+	}
+	defer closeAll(ada)
+	if err := send(ada, sent); err != nil {
+		return err
+	}
+	live, err := ada.waitFor(ctx, sent)
+	if err != nil {
+		return err
+	}
+
+	late, err := h.join(ctx, tenant, "late")
+	if err != nil {
+		return err
+	}
+	defer closeAll(late)
+	replayed, err := late.waitFor(ctx, sent)
+	if err != nil {
+		return err
+	}
+	if err := inOrder(late.name, replayed); err != nil {
+		return err
+	}
+	return sameOrder(ada, late, live, replayed)
+}
+
+// reconnect cuts a member's connection while the lobby chats and checks
+// that after reconnecting they have every message exactly once.
+func reconnect(ctx context.Context, h *harness) error {
+	const before, during = 5, 5
+	tenant := h.tenant()
+	ada, err := h.join(ctx, tenant, "ada")
+	if err != nil {
+		return err
+	}
+	defer closeAll(ada)
+	bob, err := h.join(ctx, tenant, "bob")
+	if err != nil {
+		return err
+	}
+	defer closeAll(bob)
+
+	if err := send(ada, before); err != nil {
+		return err
+	}
+	if _, err := bob.waitFor(ctx, before); err != nil {
+		return err
+	}
+	bob.cut()
+	if err := send(ada, during); err != nil {
+		return err
+	}
+
+	live, err := ada.waitFor(ctx, before+during)
+	if err != nil {
+		return err
+	}
+	resumed, err := bob.waitFor(ctx, before+during)
+	if err != nil {
+		return err
+	}
+	bob.mu.Lock()
+	reconnects := bob.reconnects
+	bob.mu.Unlock()
+	if reconnects == 0 {
+		return errors.New("bob never reconnected")
+	}
+	// Anything replayed twice would show up late
+	time.Sleep(300 * time.Millisecond)
+	resumed, _ = bob.waitFor(ctx, before+during)
+	if err := inOrder(bob.name, resumed); err != nil {
+		return err
+	}
+	return sameOrder(ada, bob, live, resumed)
+}
+
+// lobbyCapacity fills a lobby, checks that the next user waits in the
+// queue, and that they get the seat a kick frees.
+func lobbyCapacity(ctx context.Context, h *harness) error {
+	tenant := h.tenant()
+	var members []*member
+	defer func() { closeAll(members...) }()
+	for i := 0; i < capacity; i++ {
+		m, err := h.join(ctx, tenant, fmt.Sprintf("user%d", i))
+		if err != nil {
+			return err
+		}
+		members = append(members, m)
+	}
+	owner, kicked := members[0], members[len(members)-1]
+
+	admitted := make(chan error, 1)
+	var waiting client.Seat
+	go func() {
+		var err error
+		waiting, err = h.seat(ctx, tenant, "waiting")
+		admitted <- err
+	}()
+	select {
+	case err := <-admitted:
+		if err != nil {
+			return err
+		}
+		return errors.New("a user got a seat in a full lobby")
+	case <-time.After(1500 * time.Millisecond):
+	}
+
+	if err := owner.Send(models.Message{Action: models.MessageTypeKick, Target: kicked.Seat().Email}); err != nil {
+		return fmt.Errorf("kick: %w", err)
+	}
+	select {
+	case <-kicked.Done():
+	case <-ctx.Done():
+		return errors.New("the kicked member was never disconnected")
+	}
+	var kickErr *client.Error
+	if !errors.As(kicked.Err(), &kickErr) || kickErr.Code != models.ErrorKicked {
+		return fmt.Errorf("the kicked member stopped with %v", kicked.Err())
+	}
+
+	select {
+	case err := <-admitted:
+		if err != nil {
+			return err
+		}
+	case <-ctx.Done():
+		return errors.New("the waiting user was never admitted")
+	}
+	if waiting.LobbyID != owner.Seat().LobbyID {
+		return fmt.Errorf("the waiting user was seated in %s, not %s", waiting.LobbyID, owner.Seat().LobbyID)
+	}
+	m, err := h.connect(ctx, "waiting", waiting)
+	if err != nil {
+		return err
+	}
+	members = append(members, m)
+	return nil
+}
@@ -0,0 +1,251 @@
+// Package server assembles the services, controllers and handlers of one
+// chat instance. Several hubs can run in the same process, each mounted on
+// its own path prefix with its own store namespace and metrics labels.
+package server
+
+import (
+	"chat-integrated/config"
+	chatgrpc "chat-integrated/grpc"
+	"chat-integrated/services"
+	"context"
+	"log"
+	"strings"
+)
+
+type Config struct {
+	// Name identifies the hub in logs and as the "hub" metrics label
+	Name string
+	// PathPrefix mounts the hub below a path, e.g. "/staging"; empty serves
+	// it at the root
+	PathPrefix string
+	// StoreBackend is "redis", "bolt", "nats" or "memory"; BoltPath and
+	// NATSURL are only used by bolt and nats
+	StoreBackend string
+	BoltPath     string
+	NATSURL      string
+	RedisAddr    string
+	RedisDB      int
+	// RedisMode is "single", "sentinel" or "cluster"; RedisMasterName and
+	// RedisAddrs, the sentinels or the cluster's seed nodes, are only used
+	// by the latter two
+	RedisMode       string
+	RedisMasterName string
+	RedisAddrs      []string
+	// RedisNamespace prefixes the keys of every store backend
+	RedisNamespace   string
+	MaxUsersPerLobby int
+	HistoryLimit     int
+	AttachmentDir    string
+	StaticDir        string
+	MetricsLabels    map[string]string
+	ProbeEnabled     bool
+	GRPCAddr         string
+	// PolicyFile overrides the default authorization matrix
+	PolicyFile string
+	// MessageKeys supplies the keys stored messages are encrypted with,
+	// e.g. from a KMS; MESSAGE_ENCRYPTION_KEYS is read when it is nil
+	MessageKeys services.KeyProvider
+	// IDs makes the IDs of new lobbies and messages; ID_FORMAT picks one
+	// when it is nil
+	IDs services.IDGenerator
+}
+
+// DefaultConfig is the single-hub configuration the server binary runs with.
+func DefaultConfig() Config {
+	return Config{
+		Name:             "default",
+		StoreBackend:     config.StoreBackend,
+		BoltPath:         config.BoltPath,
+		NATSURL:          config.NATSURL,
+		RedisAddr:        config.RedisAddr,
+		RedisDB:          config.RedisDB,
+		RedisMode:        config.RedisMode,
+		RedisMasterName:  config.RedisSentinelMaster,
+		RedisAddrs:       defaultRedisAddrs(),
+		RedisNamespace:   "chat",
+		MaxUsersPerLobby: config.MaxUsersPerLobby,
+		HistoryLimit:     config.MessageHistoryLimit,
+		AttachmentDir:    config.AttachmentDir,
+		StaticDir:        "./static",
+		ProbeEnabled:     config.ProbeEnabled,
+		GRPCAddr:         config.GRPCAddr,
+		PolicyFile:       config.PolicyFile,
+	}
+}
+
+// defaultRedisAddrs reads the sentinel or cluster addresses REDIS_MODE
+// calls for.
+func defaultRedisAddrs() []string {
+	if config.RedisMode == "cluster" {
+		return services.SplitAddrs(config.RedisClusterAddrs)
+	}
+	return services.SplitAddrs(config.RedisSentinelAddrs)
+}
+
+// Hub owns the service layer of one chat instance.
+type Hub struct {
+	Config Config
+
+	Store       services.Store
+	Lobbies     *services.LobbyService
+	Branding    *services.BrandingService
+	Sessions    *services.SessionService
+	Invites     *services.InviteService
+	Accounts    *services.AccountService
+	OAuth       *services.OAuthService
+	Attachments *services.AttachmentService
+	Search      *services.SearchService
+	Metrics     *services.MetricsService
+	Webhooks    *services.WebhookService
+	Bots        *services.BotService
+	APIKeys     *services.APIKeyService
+	Policy      *services.PolicyService
+	Moderation  *services.ModerationService
+	Profiles    *services.ProfileService
+	Emoji       *services.EmojiService
+	Mailer      *services.MailerService
+	Audit       *services.AuditService
+	Matchmaking *services.MatchmakingService
+
+	grpcServer *chatgrpc.Server
+}
+
+func NewHub(cfg Config) *Hub {
+	cfg.PathPrefix = strings.TrimSuffix(cfg.PathPrefix, "/")
+
+	labels := map[string]string{"hub": cfg.Name}
+	for key, value := range cfg.MetricsLabels {
+		labels[key] = value
+	}
+
+	keyProvider := cfg.MessageKeys
+	if keyProvider == nil {
+		keyProvider = services.EnvKeyProvider{}
+	}
+	messageCipher, err := services.NewMessageCipher(keyProvider)
+	if err != nil {
+		log.Fatalf("❌ Invalid message encryption keys: %v", err)
+	}
+	if messageCipher != nil {
+		log.Printf("🔒 Stored messages are encrypted with key %s", messageCipher.KeyID())
+	}
+
+	ids := cfg.IDs
+	if ids == nil {
+		if ids, err = services.NewIDGenerator(config.IDFormat); err != nil {
+			log.Fatalf("❌ Invalid ID format: %v", err)
+		}
+	}
+
+	var store services.Store
+	switch cfg.StoreBackend {
+	case "bolt":
+		store = services.NewBoltService(cfg.BoltPath, cfg.RedisNamespace, messageCipher, ids)
+	case "nats":
+		store = services.NewNATSService(cfg.NATSURL, cfg.RedisNamespace, messageCipher, ids)
+	case "memory":
+		store = services.NewMemoryStore(cfg.RedisNamespace, ids)
+	case "redis", "":
+		topology := services.RedisTopology{Mode: cfg.RedisMode, Addr: cfg.RedisAddr, DB: cfg.RedisDB, MasterName: cfg.RedisMasterName, Addrs: cfg.RedisAddrs}
+		if store, err = services.NewRedisService(topology, cfg.RedisNamespace, messageCipher, ids); err != nil {
+			log.Fatalf("❌ Invalid Redis topology: %v", err)
+		}
+	default:
+		log.Fatalf("❌ Unknown store backend %q (expected redis, bolt, nats or memory)", cfg.StoreBackend)
+	}
+	grants, err := services.LoadPolicy(cfg.PolicyFile)
+	if err != nil {
+		log.Fatalf("❌ Failed to load authorization policy: %v", err)
+	}
+
+	filters, err := services.NewModerationFilters()
+	if err != nil {
+		log.Fatalf("❌ Failed to build moderation filters: %v", err)
+	}
+
+	brandingService := services.NewBrandingService(store)
+	metricsService := services.NewMetricsService(labels)
+	webhookService := services.NewWebhookService(store, metricsService)
+	moderationService := services.NewModerationService(filters, metricsService)
+	profileService := services.NewProfileService(store)
+	attachmentService := services.NewAttachmentService(store, cfg.AttachmentDir, cfg.AttachmentDir+"/quarantine", config.MaxAttachmentSize, services.NewScanner())
+	emojiService := services.NewEmojiService(store, attachmentService)
+	auditService := services.NewAuditService(store, config.AuditLogFile)
+	archiveBackend, err := services.NewArchiveBackend()
+	if err != nil {
+		log.Fatalf("❌ Failed to set up transcript archiving: %v", err)
+	}
+	mailerService := services.NewMailerService(services.NewMailer(), config.MailLinkBaseURL+cfg.PathPrefix)
+	lobbyService := services.NewLobbyService(store, brandingService, webhookService, moderationService, profileService, emojiService, mailerService, auditService, services.NewArchiveService(archiveBackend, config.ArchivePrefix), ids, cfg.MaxUsersPerLobby, cfg.HistoryLimit)
+
+	return &Hub{
+		Config:      cfg,
+		Store:       store,
+		Lobbies:     lobbyService,
+		Branding:    brandingService,
+		Sessions:    services.NewSessionService(store),
+		Invites:     services.NewInviteService(store, auditService),
+		Accounts:    services.NewAccountService(store, mailerService),
+		OAuth:       services.NewOAuthService(config.OAuthRedirectBaseURL + cfg.PathPrefix),
+		Attachments: attachmentService,
+		Search:      services.NewSearchService(store),
+		Metrics:     metricsService,
+		Webhooks:    webhookService,
+		Bots:        services.NewBotService(store),
+		APIKeys:     services.NewAPIKeyService(store),
+		Policy:      services.NewPolicyService(grants),
+		Moderation:  moderationService,
+		Profiles:    profileService,
+		Emoji:       emojiService,
+		Mailer:      mailerService,
+		Audit:       auditService,
+		Matchmaking: services.NewMatchmakingService(lobbyService),
+	}
+}
+
+// Start launches the hub's background goroutines.
+func (h *Hub) Start() {
+	if err := h.Lobbies.RestoreLobbies(); err != nil {
+		log.Printf("⚠️ Failed to restore lobbies: %v", err)
+	}
+	h.Lobbies.LoadEmojiPacks()
+	go h.Webhooks.Run()
+	go h.Mailer.Run()
+	go h.Lobbies.RunScheduler()
+	go h.Lobbies.RunRetention()
+	go h.Matchmaking.Run()
+
+	if h.Config.ProbeEnabled {
+		wsURL := "ws://localhost" + config.ServerPort + h.Config.PathPrefix + "/ws"
+		if TLSEnabled() {
+			wsURL = "wss://localhost" + config.TLSAddr + h.Config.PathPrefix + "/ws"
+		}
+		probeService := services.NewProbeService(h.Lobbies, h.Sessions, h.Metrics, wsURL)
+		go probeService.Run()
+	}
+
+	if h.Config.GRPCAddr != "" {
+		h.grpcServer = chatgrpc.NewServer(h.Lobbies, h.Sessions, h.APIKeys, h.Policy)
+		go func() {
+			if err := h.grpcServer.ListenAndServe(h.Config.GRPCAddr); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	log.Printf("🏁 Hub %q started (prefix: %q, store: %s, namespace: %q)", h.Config.Name, h.Config.PathPrefix, h.Config.StoreBackend, h.Config.RedisNamespace)
+}
+
+// Shutdown closes the hub's WebSocket connections and stops its listeners,
+// waiting for open gRPC streams until ctx expires.
+func (h *Hub) Shutdown(ctx context.Context) {
+	h.Lobbies.DisconnectAll(ctx)
+	if h.grpcServer != nil {
+		h.grpcServer.Shutdown(ctx)
+	}
+}
+
+func (h *Hub) Close() {
+	h.Audit.Close()
+	h.Store.Close()
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"chat-integrated/controllers"
+	"chat-integrated/handlers"
+	"chat-integrated/openapi"
+	"net/http"
+)
+
+// Server routes HTTP and WebSocket traffic for one hub below its prefix.
+type Server struct {
+	hub *Hub
+	mux *http.ServeMux
+	// handler is mux behind request validation
+	handler http.Handler
+}
+
+func NewServer(hub *Hub) *Server {
+	s := &Server{
+		hub: hub,
+		mux: http.NewServeMux(),
+	}
+	s.routes()
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Mount registers the server on mux below its hub's path prefix.
+func (s *Server) Mount(mux *http.ServeMux) {
+	mux.Handle(s.hub.Config.PathPrefix+"/", s)
+}
+
+func (s *Server) routes() {
+	hub := s.hub
+	prefix := hub.Config.PathPrefix
+
+	// Initialize controllers
+	apiController := controllers.NewAPIController(hub.Lobbies, hub.Policy, hub.Sessions, hub.APIKeys, prefix)
+	compression := controllers.NewCompression(hub.Metrics)
+	wsController := controllers.NewWSController(hub.Lobbies, hub.Policy, hub.Invites, compression)
+
+	// Initialize handlers
+	authHandler := handlers.NewAuthHandler(apiController, hub.Lobbies, hub.OAuth, hub.Sessions, hub.Invites, hub.Accounts, hub.Matchmaking)
+	accountHandler := handlers.NewAccountHandler(apiController, hub.Accounts, hub.Sessions)
+	statusHandler := handlers.NewStatusHandler(apiController, hub.Lobbies, hub.Store)
+	wsHandler := handlers.NewWSHandler(wsController, hub.Lobbies, hub.Sessions)
+	metricsHandler := handlers.NewMetricsHandler(apiController, hub.Metrics)
+	echoHandler := handlers.NewEchoHandler(wsController)
+	attachmentHandler := handlers.NewAttachmentHandler(apiController, hub.Attachments)
+	brandingHandler := handlers.NewBrandingHandler(apiController, hub.Branding)
+	configHandler := handlers.NewConfigHandler(apiController, hub.Branding, hub.OAuth, hub.Config.Settings())
+	lobbyHandler := handlers.NewLobbyHandler(apiController, hub.Lobbies, hub.Search)
+	webhookHandler := handlers.NewWebhookHandler(apiController, hub.Webhooks)
+	botHandler := handlers.NewBotHandler(apiController, hub.Lobbies, hub.Bots)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiController, hub.APIKeys)
+	moderationHandler := handlers.NewModerationHandler(apiController, hub.Moderation)
+	auditHandler := handlers.NewAuditHandler(apiController, hub.Audit)
+	healthHandler := handlers.NewHealthHandler(apiController, hub.Lobbies, hub.Store)
+	inviteHandler := handlers.NewInviteHandler(apiController, hub.Lobbies, hub.Invites)
+	guestHandler := handlers.NewGuestHandler(apiController, hub.Lobbies, hub.Sessions)
+	profileHandler := handlers.NewProfileHandler(apiController, hub.Lobbies, hub.Sessions)
+	announceHandler := handlers.NewAnnounceHandler(apiController, hub.Lobbies)
+	emojiHandler := handlers.NewEmojiHandler(apiController, hub.Lobbies, hub.Emoji)
+	queueHandler := handlers.NewQueueHandler(apiController, hub.Lobbies, hub.Sessions)
+	topicHandler := handlers.NewTopicHandler(apiController, hub.Matchmaking, hub.Sessions)
+	dashboardHandler := handlers.NewDashboardHandler(apiController, wsController, hub.Lobbies)
+	graphqlHandler := handlers.NewGraphQLHandler(apiController, hub.Lobbies, hub.Sessions, authHandler, compression)
+
+	// Serve static files
+	fs := http.FileServer(http.Dir(hub.Config.StaticDir))
+	s.mux.Handle(prefix+"/", http.StripPrefix(prefix, fs))
+
+	// API routes
+	s.mux.HandleFunc(prefix+"/api/login", authHandler.Login)
+	s.mux.HandleFunc(prefix+"/api/login/magic-link", accountHandler.MagicLink)
+	s.mux.HandleFunc(prefix+"/api/register", accountHandler.Register)
+	s.mux.HandleFunc(prefix+"/api/password-reset", accountHandler.PasswordReset)
+	s.mux.HandleFunc(prefix+"/api/password-reset/confirm", accountHandler.ConfirmPasswordReset)
+	s.mux.HandleFunc(prefix+"/api/logout", accountHandler.Logout)
+	s.mux.HandleFunc(prefix+"/api/sessions", accountHandler.Sessions)
+	s.mux.HandleFunc(prefix+"/api/sessions/{id}", accountHandler.RevokeSession)
+	s.mux.HandleFunc(prefix+"/api/queue", queueHandler.Queue)
+	s.mux.HandleFunc(prefix+"/api/matchmaking", topicHandler.Match)
+	s.mux.HandleFunc(prefix+"/api/topics", topicHandler.Topics)
+	s.mux.HandleFunc(prefix+"/api/guest", guestHandler.Join)
+	s.mux.HandleFunc(prefix+"/api/profile", profileHandler.UpdateProfile)
+	s.mux.HandleFunc(prefix+"/auth/{provider}/login", authHandler.OAuthLogin)
+	s.mux.HandleFunc(prefix+"/auth/{provider}/callback", authHandler.OAuthCallback)
+	s.mux.HandleFunc(prefix+"/api/status", statusHandler.GetStatus)
+	s.mux.HandleFunc(prefix+"/api/v2/status", statusHandler.GetStatusV2)
+	s.mux.HandleFunc(prefix+"/healthz", healthHandler.Healthz)
+	s.mux.HandleFunc(prefix+"/readyz", healthHandler.Readyz)
+	s.mux.HandleFunc(prefix+"/metrics", metricsHandler.GetMetrics)
+	s.mux.HandleFunc(prefix+"/api/config", configHandler.GetConfig)
+	s.mux.HandleFunc(prefix+"/api/branding", brandingHandler.GetBranding)
+	s.mux.HandleFunc(prefix+"/api/admin/tenants/{tenant}/branding", brandingHandler.AdminBranding)
+	s.mux.HandleFunc(prefix+"/api/admin/tenants/{tenant}/emoji", emojiHandler.AdminEmoji)
+	s.mux.HandleFunc(prefix+"/api/admin/tenants/{tenant}/emoji/{name}", emojiHandler.DeleteAdminEmoji)
+	s.mux.HandleFunc(prefix+"/api/admin/config", configHandler.AdminConfig)
+	s.mux.HandleFunc(prefix+"/api/admin/webhooks", webhookHandler.Webhooks)
+	s.mux.HandleFunc(prefix+"/api/admin/webhooks/{id}", webhookHandler.Delete)
+	s.mux.HandleFunc(prefix+"/api/admin/bots", botHandler.AdminBots)
+	s.mux.HandleFunc(prefix+"/api/admin/bots/{id}", botHandler.DeleteBot)
+	s.mux.HandleFunc(prefix+"/api/admin/api-keys", apiKeyHandler.AdminKeys)
+	s.mux.HandleFunc(prefix+"/api/admin/api-keys/{id}", apiKeyHandler.DeleteKey)
+	s.mux.HandleFunc(prefix+"/api/admin/moderation/violations", moderationHandler.Violations)
+	s.mux.HandleFunc(prefix+"/api/admin/audit", auditHandler.Events)
+	s.mux.HandleFunc(prefix+"/api/admin/announce", announceHandler.Announce)
+	s.mux.HandleFunc(prefix+"/api/admin/dashboard", dashboardHandler.Dashboard)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/search", lobbyHandler.Search)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/export", lobbyHandler.Export)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/history", lobbyHandler.History)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/top", lobbyHandler.Top)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/ideas", lobbyHandler.Ideas)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/threads/{messageID}", lobbyHandler.Thread)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/emoji", emojiHandler.LobbyEmoji)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/emoji/{name}", emojiHandler.DeleteLobbyEmoji)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/import", lobbyHandler.Import)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/sessions", lobbyHandler.Sessions)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/settings", lobbyHandler.Settings)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/summary", lobbyHandler.Summary)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/archive", lobbyHandler.Archive)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/unread", lobbyHandler.Unread)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/connect-ticket", authHandler.ConnectTicket)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/presence", lobbyHandler.Presence)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/actions", lobbyHandler.Actions)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/actions/{messageID}", lobbyHandler.UntagAction)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/invites", inviteHandler.Create)
+	s.mux.HandleFunc(prefix+"/api/lobbies", lobbyHandler.Create)
+	s.mux.HandleFunc(prefix+"/api/lobbies/schedule", lobbyHandler.Schedule)
+	s.mux.HandleFunc(prefix+"/api/lobbies/{id}/bot-message", botHandler.BotMessage)
+	s.mux.HandleFunc(prefix+"/api/attachments", attachmentHandler.Upload)
+	s.mux.HandleFunc(prefix+"/api/attachments/{hash}", attachmentHandler.Delete)
+	s.mux.HandleFunc(prefix+"/attachments/{hash}", attachmentHandler.Serve)
+	s.mux.HandleFunc(prefix+"/attachments/{hash}/thumbnail", attachmentHandler.ServeThumbnail)
+	s.mux.HandleFunc(prefix+"/graphql", graphqlHandler.ServeGraphQL)
+
+	// WebSocket route
+	s.mux.HandleFunc(prefix+"/ws", wsHandler.HandleWebSocket)
+	s.mux.HandleFunc(prefix+"/ws-echo", echoHandler.HandleEcho)
+	s.mux.HandleFunc(prefix+"/ws/admin", dashboardHandler.Stream)
+
+	// The API description, which request bodies are checked against
+	spec := openapi.Build(prefix, openapi.Routes)
+	s.mux.Handle(prefix+"/api/openapi.json", openapi.Handler(apiController, spec))
+	s.handler = apiController.Authenticate(openapi.Validate(apiController, spec, prefix, openapi.Routes, s.mux))
+}
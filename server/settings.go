@@ -0,0 +1,69 @@
+package server
+
+import (
+	"chat-integrated/config"
+	"fmt"
+	"reflect"
+)
+
+// Settings describes the hub configuration for /api/admin/config. A field
+// still equal to DefaultConfig inherits the source of the package setting
+// it was read from; anything else was overridden by the embedding program.
+func (c Config) Settings() []config.Setting {
+	defaults := DefaultConfig()
+	fields := []struct {
+		name, origin   string
+		value, initial interface{}
+	}{
+		{"Name", "", c.Name, defaults.Name},
+		{"PathPrefix", "", c.PathPrefix, defaults.PathPrefix},
+		{"StoreBackend", "STORE_BACKEND", c.StoreBackend, defaults.StoreBackend},
+		{"BoltPath", "BOLT_PATH", c.BoltPath, defaults.BoltPath},
+		{"NATSURL", "NATS_URL", c.NATSURL, defaults.NATSURL},
+		{"RedisAddr", "RedisAddr", c.RedisAddr, defaults.RedisAddr},
+		{"RedisDB", "RedisDB", c.RedisDB, defaults.RedisDB},
+		{"RedisMode", "REDIS_MODE", c.RedisMode, defaults.RedisMode},
+		{"RedisMasterName", "REDIS_SENTINEL_MASTER", c.RedisMasterName, defaults.RedisMasterName},
+		{"RedisAddrs", redisAddrsOrigin(), c.RedisAddrs, defaults.RedisAddrs},
+		{"RedisNamespace", "", c.RedisNamespace, defaults.RedisNamespace},
+		{"MaxUsersPerLobby", "MAX_USERS_PER_LOBBY", c.MaxUsersPerLobby, defaults.MaxUsersPerLobby},
+		{"HistoryLimit", "MessageHistoryLimit", c.HistoryLimit, defaults.HistoryLimit},
+		{"AttachmentDir", "AttachmentDir", c.AttachmentDir, defaults.AttachmentDir},
+		{"StaticDir", "", c.StaticDir, defaults.StaticDir},
+		{"MetricsLabels", "", c.MetricsLabels, defaults.MetricsLabels},
+		{"ProbeEnabled", "ProbeEnabled", c.ProbeEnabled, defaults.ProbeEnabled},
+		{"GRPCAddr", "GRPC_ADDR", c.GRPCAddr, defaults.GRPCAddr},
+		{"PolicyFile", "POLICY_FILE", c.PolicyFile, defaults.PolicyFile},
+		{"MessageKeys", "", typeName(c.MessageKeys), typeName(defaults.MessageKeys)},
+	}
+
+	settings := make([]config.Setting, 0, len(fields))
+	for _, field := range fields {
+		source := config.SourceHub
+		if reflect.DeepEqual(field.value, field.initial) {
+			source = config.SourceDefault
+			if field.origin != "" {
+				source = config.SourceOf(field.origin)
+			}
+		}
+		settings = append(settings, config.Setting{Name: field.name, Value: field.value, Source: source})
+	}
+	return settings
+}
+
+// redisAddrsOrigin is the variable the default RedisAddrs are read from.
+func redisAddrsOrigin() string {
+	if config.RedisMode == "cluster" {
+		return "REDIS_CLUSTER_ADDRS"
+	}
+	return "REDIS_SENTINEL_ADDRS"
+}
+
+// typeName names the implementation behind an interface field without
+// showing its contents, "" when it is nil.
+func typeName(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%T", value)
+}
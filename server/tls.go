@@ -0,0 +1,55 @@
+package server
+
+import (
+	"chat-integrated/config"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSEnabled reports whether the server is configured to serve HTTPS.
+func TLSEnabled() bool {
+	return config.AutocertDomains != "" || (config.TLSCertFile != "" && config.TLSKeyFile != "")
+}
+
+// NewTLSConfig builds the HTTPS configuration, either from the configured
+// certificate files or from a Let's Encrypt autocert manager. It also
+// returns the handler for the plain HTTP listener, which redirects to HTTPS
+// and, in autocert mode, answers ACME HTTP-01 challenges.
+func NewTLSConfig() (*tls.Config, http.Handler, error) {
+	redirect := http.HandlerFunc(redirectToHTTPS)
+
+	if config.AutocertDomains != "" {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(strings.Split(config.AutocertDomains, ",")...),
+			Cache:      autocert.DirCache(config.AutocertCacheDir),
+			Email:      config.AutocertEmail,
+		}
+		return manager.TLSConfig(), manager.HTTPHandler(redirect), nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, redirect, nil
+}
+
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(r.Host); err == nil {
+		host = h
+	}
+	if _, port, err := net.SplitHostPort(config.TLSAddr); err == nil && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrAccountExists      = errors.New("an account with this email already exists")
+	ErrInvalidEmail       = errors.New("email is not a valid address")
+	ErrInvalidPassword    = fmt.Errorf("passwords must be %d to %d bytes long", config.MinPasswordLength, config.MaxPasswordLength)
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidLink        = errors.New("link is invalid or has expired")
+	ErrPasswordRequired   = errors.New("a password or login link is required")
+)
+
+// dummyHash is compared against when an email has no account, so a failed
+// login takes as long whether or not the account exists.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("no account has this password"), bcrypt.DefaultCost)
+
+// AccountService keeps the registered users, keyed by their lowercased
+// email, and the single use tokens of the login and password reset links
+// it mails them.
+type AccountService struct {
+	store         Store
+	mailerService *MailerService
+	// mu serializes registrations and password changes on this server
+	mu sync.Mutex
+}
+
+func NewAccountService(store Store, mailerService *MailerService) *AccountService {
+	return &AccountService{
+		store:         store,
+		mailerService: mailerService,
+	}
+}
+
+// Register creates an account for email with password.
+func (as *AccountService) Register(email, password string) (models.Account, error) {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return models.Account{}, ErrInvalidEmail
+	}
+	if len(password) < config.MinPasswordLength || len(password) > config.MaxPasswordLength {
+		return models.Account{}, ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return models.Account{}, err
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if _, err := as.Get(email); err == nil {
+		return models.Account{}, ErrAccountExists
+	} else if !errors.Is(err, ErrKeyNotFound) {
+		return models.Account{}, err
+	}
+
+	account := models.Account{
+		Email:        email,
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now(),
+	}
+	if err := as.save(account); err != nil {
+		return models.Account{}, err
+	}
+	log.Printf("👤 Account registered: %s", email)
+	return account, nil
+}
+
+// Get returns the account of email, whatever its case, or ErrKeyNotFound.
+func (as *AccountService) Get(email string) (models.Account, error) {
+	accountJSON, err := as.store.Get(as.accountKey(email))
+	if err != nil {
+		return models.Account{}, err
+	}
+	var account models.Account
+	err = json.Unmarshal([]byte(accountJSON), &account)
+	return account, err
+}
+
+// Authenticate returns the account of email if password is its password.
+func (as *AccountService) Authenticate(email, password string) (models.Account, error) {
+	account, err := as.Get(email)
+	if errors.Is(err, ErrKeyNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return models.Account{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.Account{}, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(password)) != nil {
+		return models.Account{}, ErrInvalidCredentials
+	}
+	return account, nil
+}
+
+// SendMagicLink mails the account of email a link that logs it in once.
+// An email without an account gets nothing, and the caller can't tell.
+func (as *AccountService) SendMagicLink(email string) error {
+	account, err := as.Get(email)
+	if errors.Is(err, ErrKeyNotFound) {
+		log.Printf("✉️ Login link asked for unknown account: %s", email)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	token, err := as.issueLink("magic", account.Email, config.MagicLinkTTL)
+	if err != nil {
+		return err
+	}
+	as.mailerService.MagicLink(account.Email, token)
+	log.Printf("✉️ Login link sent to %s", account.Email)
+	return nil
+}
+
+// RedeemMagicLink spends a login link and returns the account it logs in.
+func (as *AccountService) RedeemMagicLink(token string) (models.Account, error) {
+	email, err := as.redeemLink("magic", token)
+	if err != nil {
+		return models.Account{}, err
+	}
+	return as.Get(email)
+}
+
+// SendPasswordReset mails the account of email a link that sets a new
+// password. As with login links, unknown emails get nothing.
+func (as *AccountService) SendPasswordReset(email string) error {
+	account, err := as.Get(email)
+	if errors.Is(err, ErrKeyNotFound) {
+		log.Printf("✉️ Password reset asked for unknown account: %s", email)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	token, err := as.issueLink("reset", account.Email, config.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+	as.mailerService.PasswordReset(account.Email, token)
+	log.Printf("✉️ Password reset link sent to %s", account.Email)
+	return nil
+}
+
+// ResetPassword spends a password reset link and sets the password of its
+// account, which it returns.
+func (as *AccountService) ResetPassword(token, password string) (models.Account, error) {
+	if len(password) < config.MinPasswordLength || len(password) > config.MaxPasswordLength {
+		return models.Account{}, ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return models.Account{}, err
+	}
+	email, err := as.redeemLink("reset", token)
+	if err != nil {
+		return models.Account{}, err
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	account, err := as.Get(email)
+	if err != nil {
+		return models.Account{}, err
+	}
+	account.PasswordHash = string(hash)
+	account.PasswordChangedAt = time.Now()
+	if err := as.save(account); err != nil {
+		return models.Account{}, err
+	}
+	log.Printf("👤 Password reset for %s", account.Email)
+	return account, nil
+}
+
+func (as *AccountService) issueLink(kind, email string, ttl time.Duration) (string, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	return token, as.store.SetWithTTL(as.store.Key("%s_link:%s", kind, token), []byte(email), ttl)
+}
+
+func (as *AccountService) redeemLink(kind, token string) (string, error) {
+	if token == "" {
+		return "", ErrInvalidLink
+	}
+	// Taken atomically, so of two servers redeeming a link only one gets it
+	email, err := as.store.Take(as.store.Key("%s_link:%s", kind, token))
+	if errors.Is(err, ErrKeyNotFound) {
+		return "", ErrInvalidLink
+	}
+	if err != nil {
+		return "", err
+	}
+	return email, nil
+}
+
+func (as *AccountService) save(account models.Account) error {
+	accountJSON, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	return as.store.SetWithTTL(as.accountKey(account.Email), accountJSON, 0)
+}
+
+func (as *AccountService) accountKey(email string) string {
+	return as.store.Key("account:%s", strings.ToLower(email))
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	ErrTooManyActionItems = fmt.Errorf("a lobby can have at most %d action items", config.MaxActionItems)
+	ErrNotActionItem      = errors.New("this message is not an action item")
+)
+
+// TagActionItems tags the lobby's chat messages with messageIDs as action
+// items on behalf of actor and returns those that weren't tagged yet. The
+// new items go to the action_items webhooks, to open tickets from.
+func (ls *LobbyService) TagActionItems(ctx context.Context, lobby *models.Lobby, actor string, messageIDs []string) ([]models.ActionItem, error) {
+	now := time.Now()
+	items := make([]models.ActionItem, 0, len(messageIDs))
+	for _, messageID := range messageIDs {
+		msg, err := ls.messageByID(lobby, messageID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, messageID)
+		}
+		if msg.SystemAction != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownMessage, messageID)
+		}
+		items = append(items, models.ActionItem{
+			MessageID: msg.MessageID,
+			Seq:       msg.Seq,
+			Username:  msg.Username,
+			Content:   msg.Content,
+			TaggedBy:  actor,
+			TaggedAt:  now,
+		})
+	}
+	if len(lobby.GetActionItems())+len(items) > config.MaxActionItems {
+		return nil, ErrTooManyActionItems
+	}
+
+	added := lobby.AddActionItems(items)
+	if len(added) == 0 {
+		return added, nil
+	}
+	ls.saveLobby(lobby)
+	log.Printf("✅ %s tagged %d action items in lobby %s", actor, len(added), lobby.ID)
+	ls.audit(lobby, models.AuditActionTagged, actor, "", added)
+	ls.webhookService.Emit(models.WebhookEventActionItems, lobby, map[string]interface{}{
+		"tagged_by":    actor,
+		"action_items": added,
+	})
+	content := fmt.Sprintf("%s tagged a message as an action item", actor)
+	if len(added) > 1 {
+		content = fmt.Sprintf("%s tagged %d messages as action items", actor, len(added))
+	}
+	ls.announceActionItems(ctx, lobby, actor, content)
+	return added, nil
+}
+
+// UntagActionItem removes the message with messageID from the lobby's
+// action items on behalf of actor.
+func (ls *LobbyService) UntagActionItem(ctx context.Context, lobby *models.Lobby, actor, messageID string) error {
+	if !lobby.RemoveActionItem(messageID) {
+		return ErrNotActionItem
+	}
+	ls.saveLobby(lobby)
+	log.Printf("↩️ %s untagged action item %s in lobby %s", actor, messageID, lobby.ID)
+	ls.audit(lobby, models.AuditActionUntagged, actor, "", map[string]string{"message_id": messageID})
+	ls.announceActionItems(ctx, lobby, actor, fmt.Sprintf("%s removed an action item", actor))
+	return nil
+}
+
+// ActionItems returns the action items of a live lobby or an ended
+// session.
+func (ls *LobbyService) ActionItems(lobbyID string) ([]models.ActionItem, error) {
+	record, err := ls.FindSession(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+	if record.ActionItems == nil {
+		return []models.ActionItem{}, nil
+	}
+	return record.ActionItems, nil
+}
+
+// announceActionItems broadcasts the action_items system action with the
+// lobby's current action items.
+func (ls *LobbyService) announceActionItems(ctx context.Context, lobby *models.Lobby, actor, content string) {
+	notice := ls.systemMessage(lobby, models.SystemActionActionItems, actor, content)
+	notice.ActionItems = lobby.GetActionItems()
+	if err := ls.Broadcast(ctx, BroadcastMessage{LobbyID: lobby.ID, Message: notice}); err != nil && !errors.Is(err, ErrLobbyNotFound) {
+		log.Printf("⚠️ Failed to announce the action items of lobby %s: %v", lobby.ID, err)
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrInvalidAnnouncement = fmt.Errorf("content must be 1-%d characters", config.MaxAnnouncementLength)
+
+// isAnnouncement reports whether msg is an admin announcement, which lobby
+// histories keep alongside chat.
+func isAnnouncement(msg models.Message) bool {
+	return msg.SystemAction != nil && *msg.SystemAction == models.SystemActionAnnouncement
+}
+
+// Announce sends an announcement system message to every live lobby, where
+// it is sequenced and stored like chat. It returns how many lobbies took it;
+// lobbies that ended meanwhile are skipped.
+func (ls *LobbyService) Announce(ctx context.Context, content string) (int, error) {
+	content = strings.TrimSpace(content)
+	if content == "" || utf8.RuneCountInString(content) > config.MaxAnnouncementLength {
+		return 0, ErrInvalidAnnouncement
+	}
+
+	ls.mu.RLock()
+	lobbyIDs := make([]string, 0, len(ls.lobbies))
+	for lobbyID, lobby := range ls.lobbies {
+		if !lobby.Internal {
+			lobbyIDs = append(lobbyIDs, lobbyID)
+		}
+	}
+	ls.mu.RUnlock()
+
+	announced := 0
+	for _, lobbyID := range lobbyIDs {
+		announcementAction := models.SystemActionAnnouncement
+		err := ls.Broadcast(ctx, BroadcastMessage{LobbyID: lobbyID, Message: models.Message{
+			Type:         models.MessageTypeSystemAction,
+			SystemAction: &announcementAction,
+			Content:      content,
+			LobbyID:      lobbyID,
+			Timestamp:    time.Now(),
+		}})
+		if errors.Is(err, ErrLobbyNotFound) {
+			continue
+		}
+		if err != nil {
+			return announced, err
+		}
+		announced++
+	}
+
+	ls.auditService.Record(models.AuditEvent{
+		Action:  models.AuditAnnouncement,
+		Actor:   string(models.RoleAdmin),
+		Details: map[string]interface{}{"content": content, "lobbies": announced},
+	})
+	log.Printf("📢 Announcement sent to %d lobbies", announced)
+	return announced, nil
+}
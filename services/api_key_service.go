@@ -0,0 +1,171 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAPIKeyNotFound    = errors.New("API key not found")
+	ErrInvalidAPIKey     = errors.New("invalid API key")
+	ErrInvalidAPIKeyName = errors.New("API key name must be 1-32 letters, digits, spaces, '-' or '_'")
+	ErrInvalidAPIKeyRole = errors.New("API key role must be user or admin")
+)
+
+// APIKeyService manages the API keys services call the REST API with. A
+// user key reads lobbies like a participant; an admin key stands in for
+// ADMIN_API_KEY.
+type APIKeyService struct {
+	store Store
+	keys  map[string]models.APIKey
+	mu    sync.RWMutex
+}
+
+func NewAPIKeyService(store Store) *APIKeyService {
+	aks := &APIKeyService{
+		store: store,
+		keys:  make(map[string]models.APIKey),
+	}
+	aks.load()
+	return aks
+}
+
+// IsAPIKey reports whether a bearer token is shaped like an API key rather
+// than, say, a bot's.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, config.APIKeyPrefix)
+}
+
+// CreateKey issues an API key acting as role and returns it with the key.
+// The key is not stored and cannot be retrieved again.
+func (aks *APIKeyService) CreateKey(name string, role models.Role) (models.APIKey, string, error) {
+	// Keys are named like bots
+	name = strings.TrimSpace(name)
+	if !botNamePattern.MatchString(name) {
+		return models.APIKey{}, "", ErrInvalidAPIKeyName
+	}
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return models.APIKey{}, "", ErrInvalidAPIKeyRole
+	}
+
+	id, err := GenerateToken()
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+	key, err := GenerateToken()
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+	key = config.APIKeyPrefix + key
+
+	apiKey := models.APIKey{
+		ID:        id[:16],
+		Name:      name,
+		Role:      role,
+		KeyHash:   hashKey(key),
+		CreatedAt: time.Now(),
+	}
+
+	aks.mu.Lock()
+	defer aks.mu.Unlock()
+
+	aks.keys[apiKey.ID] = apiKey
+	if err := aks.saveLocked(); err != nil {
+		delete(aks.keys, apiKey.ID)
+		return models.APIKey{}, "", err
+	}
+
+	log.Printf("🔑 Created %s API key %s (%s)", role, apiKey.Name, apiKey.ID)
+	apiKey.KeyHash = ""
+	return apiKey, key, nil
+}
+
+// Authenticate returns the API key matching key.
+func (aks *APIKeyService) Authenticate(key string) (models.APIKey, error) {
+	if !IsAPIKey(key) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	hash := hashKey(key)
+
+	aks.mu.RLock()
+	defer aks.mu.RUnlock()
+
+	for _, apiKey := range aks.keys {
+		if subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(hash)) == 1 {
+			apiKey.KeyHash = ""
+			return apiKey, nil
+		}
+	}
+	return models.APIKey{}, ErrInvalidAPIKey
+}
+
+// ListKeys returns the API keys without their hashes, oldest first.
+func (aks *APIKeyService) ListKeys() []models.APIKey {
+	aks.mu.RLock()
+	defer aks.mu.RUnlock()
+
+	keys := make([]models.APIKey, 0, len(aks.keys))
+	for _, apiKey := range aks.keys {
+		apiKey.KeyHash = ""
+		keys = append(keys, apiKey)
+	}
+	slices.SortFunc(keys, func(a, b models.APIKey) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return keys
+}
+
+// DeleteKey revokes an API key.
+func (aks *APIKeyService) DeleteKey(id string) error {
+	aks.mu.Lock()
+	defer aks.mu.Unlock()
+
+	if _, exists := aks.keys[id]; !exists {
+		return ErrAPIKeyNotFound
+	}
+	delete(aks.keys, id)
+
+	log.Printf("🗑️ Revoked API key %s", id)
+	return aks.saveLocked()
+}
+
+func (aks *APIKeyService) load() {
+	keysJSON, err := aks.store.Get(aks.store.Key("api_keys"))
+	if err != nil {
+		if err != ErrKeyNotFound {
+			log.Printf("⚠️ Failed to load API keys: %v", err)
+		}
+		return
+	}
+
+	var keys []models.APIKey
+	if err := json.Unmarshal([]byte(keysJSON), &keys); err != nil {
+		log.Printf("⚠️ Invalid API keys stored: %v", err)
+		return
+	}
+	for _, apiKey := range keys {
+		aks.keys[apiKey.ID] = apiKey
+	}
+}
+
+// saveLocked persists the API keys; aks.mu must be held.
+func (aks *APIKeyService) saveLocked() error {
+	keys := make([]models.APIKey, 0, len(aks.keys))
+	for _, apiKey := range aks.keys {
+		keys = append(keys, apiKey)
+	}
+
+	keysJSON, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	return aks.store.SetWithTTL(aks.store.Key("api_keys"), keysJSON, 0)
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"bytes"
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+var ErrNoArchive = errors.New("no archived transcript for this lobby")
+
+// ArchiveBackend is the object store ended sessions' transcripts are
+// uploaded to.
+type ArchiveBackend interface {
+	Name() string
+	Bucket() string
+	Put(ctx context.Context, key, contentType string, body []byte) error
+	// PresignGet returns a URL that downloads key, named filename, for ttl
+	PresignGet(key, filename string, ttl time.Duration) (string, error)
+}
+
+// NewArchiveBackend returns the backend selected by config.ArchiveBackend,
+// nil for "none".
+func NewArchiveBackend() (ArchiveBackend, error) {
+	switch config.ArchiveBackend {
+	case "none", "":
+		return nil, nil
+	case "s3":
+		return NewS3Backend(config.ArchiveEndpoint, config.ArchiveRegion, config.ArchiveBucket, config.ArchiveAccessKeyID, config.ArchiveSecretAccessKey)
+	default:
+		return nil, fmt.Errorf("unknown archive backend %q (expected none or s3)", config.ArchiveBackend)
+	}
+}
+
+// ArchiveService writes the transcripts of ended sessions to an archive
+// backend as gzipped JSON lines, one stored message per line, and hands
+// out presigned links to them.
+type ArchiveService struct {
+	backend ArchiveBackend
+	prefix  string
+}
+
+// NewArchiveService archives to backend; with a nil backend archiving is
+// off.
+func NewArchiveService(backend ArchiveBackend, prefix string) *ArchiveService {
+	if backend != nil {
+		log.Printf("🗃️ Archiving transcripts to %s bucket %s under %q", backend.Name(), backend.Bucket(), prefix)
+	}
+	return &ArchiveService{backend: backend, prefix: prefix}
+}
+
+func (as *ArchiveService) Enabled() bool {
+	return as.backend != nil
+}
+
+// Archive uploads the transcript of the session of record and returns
+// where it went.
+func (as *ArchiveService) Archive(ctx context.Context, record models.LobbyRecord, transcript []models.RedisMessage) (*models.TranscriptArchive, error) {
+	if as.backend == nil {
+		return nil, ErrNoArchive
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	encoder := json.NewEncoder(writer)
+	for _, msg := range transcript {
+		if err := encoder.Encode(msg); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	tenantID := record.TenantID
+	if tenantID == "" {
+		tenantID = config.DefaultTenantID
+	}
+	key := fmt.Sprintf("%s%s/%s.jsonl.gz", as.prefix, tenantID, record.ID)
+	if err := as.backend.Put(ctx, key, "application/gzip", compressed.Bytes()); err != nil {
+		return nil, err
+	}
+	return &models.TranscriptArchive{
+		Backend:    as.backend.Name(),
+		Bucket:     as.backend.Bucket(),
+		Key:        key,
+		Messages:   len(transcript),
+		Size:       int64(compressed.Len()),
+		ArchivedAt: time.Now(),
+	}, nil
+}
+
+// DownloadURL presigns a download of an archived transcript lasting
+// config.ArchiveURLTTL, and returns it with when it expires.
+func (as *ArchiveService) DownloadURL(lobbyID string, archive models.TranscriptArchive) (string, time.Time, error) {
+	if as.backend == nil || archive.Backend != as.backend.Name() || archive.Bucket != as.backend.Bucket() {
+		// Archived somewhere this server no longer has credentials for
+		return "", time.Time{}, ErrNoArchive
+	}
+	ttl := min(config.ArchiveURLTTL, config.MaxArchiveURLTTL)
+	url, err := as.backend.PresignGet(archive.Key, lobbyID+".jsonl.gz", ttl)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return url, time.Now().Add(ttl), nil
+}
+
+// archiveTranscript uploads the transcript of an ended session in the
+// background and records where it went on the session's archived record.
+func (ls *LobbyService) archiveTranscript(record models.LobbyRecord) {
+	if !ls.archiveService.Enabled() {
+		return
+	}
+	go func() {
+		transcript, err := ls.store.GetMessages(record.ID)
+		if err != nil {
+			log.Printf("⚠️ Failed to load the transcript of %s for archiving: %v", record.ID, err)
+			return
+		}
+		// Messages sent in ephemeral mode are never archived
+		transcript = slices.DeleteFunc(transcript, func(msg models.RedisMessage) bool { return !msg.ExpireAt.IsZero() })
+		ctx, cancel := context.WithTimeout(context.Background(), config.ArchiveTimeout)
+		defer cancel()
+		archive, err := ls.archiveService.Archive(ctx, record, transcript)
+		if err != nil {
+			log.Printf("⚠️ Failed to archive the transcript of %s: %v", record.ID, err)
+			return
+		}
+
+		// Reloaded, as follow-ups may have been linked in the meantime
+		archived, err := ls.FindSession(record.ID)
+		if err != nil {
+			log.Printf("⚠️ Failed to load the archived record of %s: %v", record.ID, err)
+			return
+		}
+		archived.Archive = archive
+		if err := ls.saveArchive(archived); err != nil {
+			log.Printf("⚠️ Failed to record the transcript archive of %s: %v", record.ID, err)
+			return
+		}
+		log.Printf("🗃️ Archived %d messages of %s to %s (%d bytes)", archive.Messages, record.ID, archive.Key, archive.Size)
+	}()
+}
+
+// ArchiveURL returns a presigned download link to the archived transcript
+// of an ended session and when it expires. It fails with ErrUnknownSession
+// for a lobby it knows nothing of and ErrNoArchive for a live one or one
+// that wasn't archived.
+func (ls *LobbyService) ArchiveURL(lobbyID string) (string, models.TranscriptArchive, time.Time, error) {
+	record, err := ls.FindSession(lobbyID)
+	if err != nil {
+		return "", models.TranscriptArchive{}, time.Time{}, err
+	}
+	if record.Archive == nil {
+		return "", models.TranscriptArchive{}, time.Time{}, ErrNoArchive
+	}
+	url, expiresAt, err := ls.archiveService.DownloadURL(lobbyID, *record.Archive)
+	return url, *record.Archive, expiresAt, err
+}
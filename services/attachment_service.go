@@ -0,0 +1,355 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrAttachmentTooLarge = errors.New("attachment exceeds maximum size")
+	ErrScanFailed         = errors.New("attachment could not be scanned")
+)
+
+// InfectedError is returned when the scanner flags an upload.
+type InfectedError struct {
+	Signature string
+}
+
+func (e *InfectedError) Error() string {
+	return fmt.Sprintf("attachment rejected by malware scan: %s", e.Signature)
+}
+
+// AttachmentService stores uploads by content address so identical files
+// are kept once and shared through a reference count. Each reference
+// belongs to an owner, the uploader or an emoji pack, who alone may release
+// it. Each attachment's record, references included, is kept in the store,
+// so URLs in the history keep working across restarts.
+type AttachmentService struct {
+	store         Store
+	dir           string
+	quarantineDir string
+	maxSize       int64
+	scanner       Scanner
+	// attachments caches the records read from or written to the store
+	attachments map[string]*models.Attachment
+	// adopted are the files Retain rebuilt a record for in this process
+	adopted map[string]bool
+	mu      sync.Mutex
+}
+
+func NewAttachmentService(store Store, dir, quarantineDir string, maxSize int64, scanner Scanner) *AttachmentService {
+	for _, d := range []string{dir, quarantineDir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			log.Fatalf("❌ Failed to create attachment directory %s: %v", d, err)
+		}
+	}
+
+	return &AttachmentService{
+		store:         store,
+		dir:           dir,
+		quarantineDir: quarantineDir,
+		maxSize:       maxSize,
+		scanner:       scanner,
+		attachments:   make(map[string]*models.Attachment),
+		adopted:       make(map[string]bool),
+	}
+}
+
+// Store writes the upload to disk while hashing it and gives owner a
+// reference to it. If the content already exists the temporary copy is
+// discarded and owner takes another reference to the existing attachment.
+func (as *AttachmentService) Store(r io.Reader, owner, filename, contentType string) (*models.Attachment, error) {
+	tmp, err := os.CreateTemp(as.dir, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), io.LimitReader(r, as.maxSize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if size > as.maxSize {
+		return nil, ErrAttachmentTooLarge
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	// Known content was already scanned when it was first uploaded
+	existing, err := as.addReference(hash, owner)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	result, err := as.scanner.Scan(tmp.Name())
+	if err != nil {
+		log.Printf("📝 AUDIT attachment_scan hash=%s filename=%q result=error err=%q", hash, filename, err)
+		return nil, fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+	log.Printf("📝 AUDIT attachment_scan hash=%s filename=%q scanner=%s clean=%t signature=%q", hash, filename, result.Scanner, result.Clean, result.Signature)
+	if !result.Clean {
+		if err := os.Rename(tmp.Name(), filepath.Join(as.quarantineDir, hash)); err != nil {
+			log.Printf("⚠️ Failed to quarantine attachment %s: %v", hash, err)
+		}
+		return nil, &InfectedError{Signature: result.Signature}
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	// An identical upload may have finished while we were scanning
+	if existing := as.lookupLocked(hash); existing != nil {
+		if err := as.referenceLocked(existing, owner, 1); err != nil {
+			return nil, err
+		}
+		return copyAttachment(existing), nil
+	}
+
+	if err := os.Rename(tmp.Name(), as.path(hash)); err != nil {
+		return nil, err
+	}
+
+	attachment := &models.Attachment{
+		Hash:        hash,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        size,
+		URL:         fmt.Sprintf("/attachments/%s", hash),
+		RefCount:    1,
+		UploadedAt:  time.Now(),
+		Owners:      map[string]int{owner: 1},
+	}
+	if strings.HasPrefix(contentType, "image/") {
+		if err := generateThumbnail(as.path(hash), as.thumbnailPath(hash)); err != nil {
+			log.Printf("⚠️ Failed to generate thumbnail for %s: %v", hash, err)
+		} else {
+			attachment.ThumbnailURL = fmt.Sprintf("/attachments/%s/thumbnail", hash)
+		}
+	}
+
+	if err := as.saveLocked(attachment); err != nil {
+		os.Remove(as.path(hash))
+		os.Remove(as.thumbnailPath(hash))
+		return nil, err
+	}
+	log.Printf("📎 Stored attachment %s (%s, %d bytes)", hash, filename, size)
+	return copyAttachment(attachment), nil
+}
+
+// addReference gives owner another reference to known content, returning
+// nil when there is none yet.
+func (as *AttachmentService) addReference(hash, owner string) (*models.Attachment, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	existing := as.lookupLocked(hash)
+	if existing == nil {
+		return nil, nil
+	}
+	if err := as.referenceLocked(existing, owner, 1); err != nil {
+		return nil, err
+	}
+	log.Printf("♻️ Deduplicated attachment %s (%d references)", hash, existing.RefCount)
+	return copyAttachment(existing), nil
+}
+
+// Retain makes sure content an owner like an emoji pack references is
+// tracked. Files stored before their records were kept in the store get
+// one rebuilt from disk, with a reference for every owner that retains them
+// in this process; content with a stored record already counts its owners.
+func (as *AttachmentService) Retain(hash, owner, filename, contentType string) error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	if existing := as.lookupLocked(hash); existing != nil {
+		if !as.adopted[hash] {
+			return nil
+		}
+		return as.referenceLocked(existing, owner, 1)
+	}
+
+	info, err := os.Stat(as.path(hash))
+	if err != nil {
+		return ErrAttachmentNotFound
+	}
+	attachment := &models.Attachment{
+		Hash:        hash,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        info.Size(),
+		URL:         fmt.Sprintf("/attachments/%s", hash),
+		RefCount:    1,
+		UploadedAt:  info.ModTime(),
+		Owners:      map[string]int{owner: 1},
+	}
+	if _, err := os.Stat(as.thumbnailPath(hash)); err == nil {
+		attachment.ThumbnailURL = fmt.Sprintf("/attachments/%s/thumbnail", hash)
+	}
+	if err := as.saveLocked(attachment); err != nil {
+		return err
+	}
+	as.adopted[hash] = true
+	log.Printf("📎 Adopted attachment %s stored before a restart", hash)
+	return nil
+}
+
+func (as *AttachmentService) Get(hash string) (*models.Attachment, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	attachment := as.lookupLocked(hash)
+	if attachment == nil {
+		return nil, ErrAttachmentNotFound
+	}
+	return copyAttachment(attachment), nil
+}
+
+// Open returns the stored content of an attachment.
+func (as *AttachmentService) Open(hash string) (*os.File, error) {
+	if _, err := as.Get(hash); err != nil {
+		return nil, err
+	}
+	return os.Open(as.path(hash))
+}
+
+// OpenThumbnail returns the generated thumbnail of an image attachment.
+func (as *AttachmentService) OpenThumbnail(hash string) (*os.File, error) {
+	attachment, err := as.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if attachment.ThumbnailURL == "" {
+		return nil, ErrAttachmentNotFound
+	}
+	return os.Open(as.thumbnailPath(hash))
+}
+
+// Release drops one of owner's references and deletes the content once
+// unreferenced. Content owner holds no reference to is not found, so no one
+// can release what others still use.
+func (as *AttachmentService) Release(hash, owner string) error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	attachment := as.lookupLocked(hash)
+	if attachment == nil || attachment.Owners[owner] == 0 {
+		return ErrAttachmentNotFound
+	}
+
+	if attachment.RefCount > 1 {
+		if err := as.referenceLocked(attachment, owner, -1); err != nil {
+			return err
+		}
+		log.Printf("📎 Released attachment %s (%d references left)", hash, attachment.RefCount)
+		return nil
+	}
+
+	if err := as.store.Delete(as.recordKey(hash)); err != nil {
+		return err
+	}
+	delete(as.attachments, hash)
+	delete(as.adopted, hash)
+	log.Printf("🗑️ Deleted attachment %s", hash)
+	if attachment.ThumbnailURL != "" {
+		os.Remove(as.thumbnailPath(hash))
+	}
+	return os.Remove(as.path(hash))
+}
+
+// lookupLocked returns the record of hash, reading it from the store when
+// it isn't cached, or nil if there is none. The caller holds as.mu.
+func (as *AttachmentService) lookupLocked(hash string) *models.Attachment {
+	if attachment, cached := as.attachments[hash]; cached {
+		return attachment
+	}
+	recordJSON, err := as.store.Get(as.recordKey(hash))
+	if err != nil {
+		if !errors.Is(err, ErrKeyNotFound) {
+			log.Printf("⚠️ Failed to load attachment %s: %v", hash, err)
+		}
+		return nil
+	}
+	var attachment models.Attachment
+	if err := json.Unmarshal([]byte(recordJSON), &attachment); err != nil {
+		log.Printf("⚠️ Invalid attachment record stored for %s: %v", hash, err)
+		return nil
+	}
+	as.attachments[hash] = &attachment
+	return &attachment
+}
+
+// referenceLocked changes owner's references to attachment by delta and
+// stores it, leaving it unchanged if that fails. The caller holds as.mu.
+func (as *AttachmentService) referenceLocked(attachment *models.Attachment, owner string, delta int) error {
+	if attachment.Owners == nil {
+		attachment.Owners = make(map[string]int)
+	}
+	held := attachment.Owners[owner]
+	setOwner := func(n int) {
+		if n > 0 {
+			attachment.Owners[owner] = n
+		} else {
+			delete(attachment.Owners, owner)
+		}
+	}
+	attachment.RefCount += delta
+	setOwner(held + delta)
+	if err := as.saveLocked(attachment); err != nil {
+		attachment.RefCount -= delta
+		setOwner(held)
+		return err
+	}
+	return nil
+}
+
+// saveLocked stores attachment's record and caches it. The caller holds
+// as.mu.
+func (as *AttachmentService) saveLocked(attachment *models.Attachment) error {
+	recordJSON, err := json.Marshal(attachment)
+	if err != nil {
+		return err
+	}
+	if err := as.store.SetWithTTL(as.recordKey(attachment.Hash), recordJSON, 0); err != nil {
+		log.Printf("❌ Failed to save attachment %s: %v", attachment.Hash, err)
+		return err
+	}
+	as.attachments[attachment.Hash] = attachment
+	return nil
+}
+
+func (as *AttachmentService) recordKey(hash string) string {
+	return as.store.Key("attachment:%s", hash)
+}
+
+func (as *AttachmentService) path(hash string) string {
+	return filepath.Join(as.dir, hash)
+}
+
+func (as *AttachmentService) thumbnailPath(hash string) string {
+	return filepath.Join(as.dir, hash+".thumb.jpg")
+}
+
+// copyAttachment copies attachment for callers, leaving out its owners.
+func copyAttachment(attachment *models.Attachment) *models.Attachment {
+	attachmentCopy := *attachment
+	attachmentCopy.Owners = nil
+	return &attachmentCopy
+}
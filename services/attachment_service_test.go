@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestAttachmentService(t *testing.T, store Store, dir string) *AttachmentService {
+	return NewAttachmentService(store, dir, t.TempDir(), 1<<20, NoopScanner{})
+}
+
+func TestAttachmentSharedByTwoUploaders(t *testing.T) {
+	store := NewMemoryStore("test", &ULIDs{})
+	dir := t.TempDir()
+	as := newTestAttachmentService(t, store, dir)
+
+	first, err := as.Store(strings.NewReader("same bytes"), "alice@example.com", "a.txt", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := as.Store(strings.NewReader("same bytes"), "bob@example.com", "b.txt", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Hash != second.Hash || second.RefCount != 2 {
+		t.Fatalf("second upload = %+v, want hash %s with 2 references", second, first.Hash)
+	}
+	if first.Owners != nil || second.Owners != nil {
+		t.Errorf("Store returned its owners: %v, %v", first.Owners, second.Owners)
+	}
+
+	// Someone without a reference can't release it
+	if err := as.Release(first.Hash, "mallory@example.com"); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Errorf("Release by a non-owner = %v, want %v", err, ErrAttachmentNotFound)
+	}
+	// bob releases his reference once, and only once
+	if err := as.Release(first.Hash, "bob@example.com"); err != nil {
+		t.Fatalf("Release by bob = %v", err)
+	}
+	if err := as.Release(first.Hash, "bob@example.com"); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Errorf("second Release by bob = %v, want %v", err, ErrAttachmentNotFound)
+	}
+
+	// alice's reference survives, also for a service reading the store anew
+	restarted := newTestAttachmentService(t, store, dir)
+	for name, service := range map[string]*AttachmentService{"running": as, "restarted": restarted} {
+		attachment, err := service.Get(first.Hash)
+		if err != nil {
+			t.Fatalf("%s: Get after bob released = %v", name, err)
+		}
+		if attachment.RefCount != 1 {
+			t.Errorf("%s: RefCount = %d, want 1", name, attachment.RefCount)
+		}
+		file, err := service.Open(first.Hash)
+		if err != nil {
+			t.Fatalf("%s: Open after bob released = %v", name, err)
+		}
+		file.Close()
+	}
+
+	if err := restarted.Release(first.Hash, "alice@example.com"); err != nil {
+		t.Fatalf("Release by alice = %v", err)
+	}
+	if _, err := restarted.Open(first.Hash); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Errorf("Open after the last reference was released = %v, want %v", err, ErrAttachmentNotFound)
+	}
+}
+
+func TestAttachmentOwnerHoldsEachUpload(t *testing.T) {
+	as := newTestAttachmentService(t, NewMemoryStore("test", &ULIDs{}), t.TempDir())
+
+	// Uploading the same file twice takes two references
+	for range 2 {
+		if _, err := as.Store(strings.NewReader("twice"), "alice@example.com", "a.txt", "text/plain"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	attachment, err := as.Store(strings.NewReader("twice"), "bob@example.com", "b.txt", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := as.Release(attachment.Hash, "alice@example.com"); err != nil {
+			t.Fatalf("Release by alice = %v", err)
+		}
+	}
+	if err := as.Release(attachment.Hash, "alice@example.com"); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Errorf("third Release by alice = %v, want %v", err, ErrAttachmentNotFound)
+	}
+	if got, err := as.Get(attachment.Hash); err != nil || got.RefCount != 1 {
+		t.Errorf("Get = %+v, %v; want bob's reference left", got, err)
+	}
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditFilter narrows an audit log query; empty fields match every event.
+type AuditFilter struct {
+	LobbyID string
+	Actor   string
+	Since   time.Time
+	Until   time.Time
+	// Limit caps the events returned, the newest first
+	Limit int
+}
+
+// AuditService records administrative and lifecycle events, such as lobbies
+// ending and members being kicked, to a stream in the store and, if
+// configured, to a JSON lines file that keeps them beyond the stream's
+// length.
+type AuditService struct {
+	store Store
+	file  *os.File
+	mu    sync.Mutex
+}
+
+// NewAuditService logs to store and, unless path is empty, appends to the
+// file at path.
+func NewAuditService(store Store, path string) *AuditService {
+	as := &AuditService{store: store}
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			log.Fatalf("❌ Failed to open audit log %s: %v", path, err)
+		}
+		as.file = file
+	}
+	return as
+}
+
+// Record appends an event to the audit log, stamped with the current time
+// unless it has one.
+func (as *AuditService) Record(event models.AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("⚠️ Failed to encode audit event %q: %v", event.Action, err)
+		return
+	}
+	log.Printf("📝 Audit: %s by %s (lobby: %s, target: %s)", event.Action, event.Actor, event.LobbyID, event.Target)
+
+	// Stores without streams rewrite the whole log on each append
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if err := as.store.AppendStream(as.key(), string(eventJSON), int64(config.AuditMaxEvents)); err != nil {
+		log.Printf("⚠️ Failed to store audit event %q: %v", event.Action, err)
+	}
+	if as.file != nil {
+		if _, err := as.file.Write(append(eventJSON, '\n')); err != nil {
+			log.Printf("⚠️ Failed to write audit event %q to %s: %v", event.Action, as.file.Name(), err)
+		}
+	}
+}
+
+// Events returns the stored events that match filter, newest first.
+func (as *AuditService) Events(filter AuditFilter) ([]models.AuditEvent, error) {
+	entries, err := as.store.ReadStream(as.key(), filter.Since, filter.Until)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]models.AuditEvent, 0)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(events) >= filter.Limit {
+			break
+		}
+		var event models.AuditEvent
+		if err := json.Unmarshal([]byte(entries[i]), &event); err != nil {
+			continue
+		}
+		// The store's range is only as fine as its stream IDs
+		if (!filter.Since.IsZero() && event.Time.Before(filter.Since)) || (!filter.Until.IsZero() && event.Time.After(filter.Until)) {
+			continue
+		}
+		if (filter.LobbyID != "" && event.LobbyID != filter.LobbyID) || (filter.Actor != "" && event.Actor != filter.Actor) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// Close closes the audit log file.
+func (as *AuditService) Close() {
+	if as.file != nil {
+		as.file.Close()
+	}
+}
+
+func (as *AuditService) key() string {
+	return as.store.Key("audit")
+}
+
+// audit records an event of a lobby.
+func (ls *LobbyService) audit(lobby *models.Lobby, action, actor, target string, details interface{}) {
+	ls.auditService.Record(models.AuditEvent{
+		Action:   action,
+		LobbyID:  lobby.ID,
+		TenantID: lobby.TenantID,
+		Actor:    actor,
+		Target:   target,
+		Details:  details,
+	})
+}
+
+// auditCommand records a lobby management command that went through.
+func (ls *LobbyService) auditCommand(lobby *models.Lobby, actor string, frame models.Message) {
+	switch frame.Type {
+	case models.MessageTypeKick:
+		ls.audit(lobby, models.AuditMemberKicked, actor, frame.Target, nil)
+	case models.MessageTypeSetRole:
+		ls.audit(lobby, models.AuditRoleChanged, actor, frame.Target, map[string]models.Role{"role": frame.Role})
+	case models.MessageTypePin:
+		ls.audit(lobby, models.AuditMessagePinned, actor, "", map[string]int64{"seq": frame.PinnedSeq})
+	case models.MessageTypeUnpin:
+		ls.audit(lobby, models.AuditMessageUnpinned, actor, "", map[string]int64{"seq": frame.PinnedSeq})
+	case models.MessageTypeSetMaxUsers:
+		ls.audit(lobby, models.AuditSettingsChanged, actor, "", map[string]int{"max_users": frame.MaxUsers})
+	case models.MessageTypeSetSystemEvents:
+		ls.audit(lobby, models.AuditSettingsChanged, actor, "", map[string]models.SystemEvents{"system_events": frame.SystemEvents})
+	case models.MessageTypeSetGuestAccess:
+		ls.audit(lobby, models.AuditSettingsChanged, actor, "", map[string]bool{"guest_friendly": frame.GuestFriendly})
+	case models.MessageTypeSetBudget:
+		ls.audit(lobby, models.AuditSettingsChanged, actor, "", map[string]interface{}{"budget_minutes": frame.BudgetMinutes, "hourly_rate": frame.HourlyRate})
+	case models.MessageTypeSetSlowMode:
+		ls.audit(lobby, models.AuditSettingsChanged, actor, "", map[string]int{"slow_mode_seconds": frame.SlowModeSeconds})
+	}
+}
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"bytes"
+	"chat-integrated/models"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	kvBucket       = []byte("kv")
+	lobbiesBucket  = []byte("lobbies")
+	messagesBucket = []byte("messages")
+)
+
+// BoltService is an embedded Store backed by a single Bolt file, for demos
+// and air-gapped deployments without Redis.
+type BoltService struct {
+	db        *bolt.DB
+	namespace string
+	cipher    *MessageCipher
+	ids       IDGenerator
+}
+
+// NewBoltService opens the Bolt file at path. Messages are sealed with
+// cipher unless it is nil, and those without an ID get one from ids.
+func NewBoltService(path, namespace string, cipher *MessageCipher, ids IDGenerator) *BoltService {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Fatalf("❌ Failed to create Bolt directory: %v", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		log.Fatalf("❌ Failed to open Bolt database %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{kvBucket, lobbiesBucket, messagesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("❌ Failed to initialise Bolt database %s: %v", path, err)
+	}
+
+	log.Printf("✅ Opened Bolt database: %s", path)
+	return &BoltService{
+		db:        db,
+		namespace: namespace,
+		cipher:    cipher,
+		ids:       ids,
+	}
+}
+
+func (bs *BoltService) Key(format string, args ...interface{}) string {
+	return bs.namespace + ":" + fmt.Sprintf(format, args...)
+}
+
+func (bs *BoltService) PushMessage(_ context.Context, msg models.Message) error {
+	msgJSON, err := json.Marshal(toRedisMessage(msg, bs.ids))
+	if err != nil {
+		return err
+	}
+	if msgJSON, err = bs.cipher.Seal(msgJSON); err != nil {
+		return err
+	}
+
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		queue, err := tx.Bucket(messagesBucket).CreateBucketIfNotExists([]byte(bs.Key("lobby:%s:messages", msg.LobbyID)))
+		if err != nil {
+			return err
+		}
+		id, err := queue.NextSequence()
+		if err != nil {
+			return err
+		}
+		return queue.Put(binary.BigEndian.AppendUint64(nil, id), msgJSON)
+	})
+}
+
+func (bs *BoltService) GetMessages(lobbyID string) ([]models.RedisMessage, error) {
+	return bs.GetMessagesRange(lobbyID, 0, -1)
+}
+
+func (bs *BoltService) GetMessagesRange(lobbyID string, start, stop int64) ([]models.RedisMessage, error) {
+	var raw [][]byte
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		queue := tx.Bucket(messagesBucket).Bucket([]byte(bs.Key("lobby:%s:messages", lobbyID)))
+		if queue == nil {
+			return nil
+		}
+		return queue.ForEach(func(_, value []byte) error {
+			raw = append(raw, value)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := lrangeBounds(int64(len(raw)), start, stop)
+	var messages []models.RedisMessage
+	for _, entry := range raw[from:to] {
+		msgJSON, err := bs.cipher.Open(entry)
+		if err != nil {
+			log.Printf("⚠️ Failed to decrypt message: %v", err)
+			continue
+		}
+		var msg models.RedisMessage
+		if err := json.Unmarshal(msgJSON, &msg); err != nil {
+			log.Printf("⚠️ Failed to unmarshal message: %v", err)
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func (bs *BoltService) GetMessagesSince(lobbyID string, seq int64) ([]models.RedisMessage, error) {
+	messages, err := bs.GetMessages(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var missed []models.RedisMessage
+	for _, msg := range messages {
+		if msg.Seq > seq {
+			missed = append(missed, msg)
+		}
+	}
+	return missed, nil
+}
+
+func (bs *BoltService) TrimMessages(lobbyID string, keep int64) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		queue := tx.Bucket(messagesBucket).Bucket([]byte(bs.Key("lobby:%s:messages", lobbyID)))
+		if queue == nil {
+			return nil
+		}
+		cursor := queue.Cursor()
+		for drop := int64(queue.Stats().KeyN) - max(keep, 0); drop > 0; drop-- {
+			if key, _ := cursor.First(); key == nil {
+				break
+			}
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteMessages opens each stored message of the lobby to find the ones
+// to delete.
+func (bs *BoltService) DeleteMessages(lobbyID string, messageIDs []string) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		queue := tx.Bucket(messagesBucket).Bucket([]byte(bs.Key("lobby:%s:messages", lobbyID)))
+		if queue == nil {
+			return nil
+		}
+		// Keys are collected first: deleting under a cursor skips the next
+		var doomed [][]byte
+		err := queue.ForEach(func(key, value []byte) error {
+			if slices.Contains(messageIDs, storedMessageID(bs.cipher, value)) {
+				doomed = append(doomed, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range doomed {
+			if err := queue.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (bs *BoltService) MessageLobbies() ([]string, error) {
+	prefix, suffix := bs.Key("lobby:"), ":messages"
+	var lobbyIDs []string
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(messagesBucket).ForEachBucket(func(name []byte) error {
+			lobbyIDs = append(lobbyIDs, strings.TrimSuffix(strings.TrimPrefix(string(name), prefix), suffix))
+			return nil
+		})
+	})
+	return lobbyIDs, err
+}
+
+// SetWithTTL stores value at key; a zero ttl never expires. Expired keys are
+// dropped lazily on the next Get.
+func (bs *BoltService) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	entryJSON, err := json.Marshal(newExpiringValue(value, ttl))
+	if err != nil {
+		return err
+	}
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(kvBucket).Put([]byte(key), entryJSON)
+	})
+}
+
+func (bs *BoltService) Get(key string) (string, error) {
+	var entry expiringValue
+	found := false
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		entryJSON := tx.Bucket(kvBucket).Get([]byte(key))
+		if entryJSON == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(entryJSON, &entry)
+	})
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", ErrKeyNotFound
+	}
+	if entry.expired() {
+		bs.Delete(key)
+		return "", ErrKeyNotFound
+	}
+	return entry.Value, nil
+}
+
+// Take reads and deletes the key in one write transaction.
+func (bs *BoltService) Take(key string) (string, error) {
+	var entry expiringValue
+	found := false
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(kvBucket)
+		entryJSON := bucket.Get([]byte(key))
+		if entryJSON == nil {
+			return nil
+		}
+		found = true
+		if err := json.Unmarshal(entryJSON, &entry); err != nil {
+			return err
+		}
+		return bucket.Delete([]byte(key))
+	})
+	if err != nil {
+		return "", err
+	}
+	if !found || entry.expired() {
+		return "", ErrKeyNotFound
+	}
+	return entry.Value, nil
+}
+
+func (bs *BoltService) Delete(key string) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(kvBucket).Delete([]byte(key))
+	})
+}
+
+func (bs *BoltService) SaveLobby(record models.LobbyRecord) error {
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(lobbiesBucket).Put([]byte(bs.Key("%s", record.ID)), recordJSON)
+	})
+}
+
+func (bs *BoltService) LoadLobbies() ([]models.LobbyRecord, error) {
+	prefix := []byte(bs.Key(""))
+	var records []models.LobbyRecord
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(lobbiesBucket).Cursor()
+		for key, recordJSON := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, recordJSON = cursor.Next() {
+			var record models.LobbyRecord
+			if err := json.Unmarshal(recordJSON, &record); err != nil {
+				log.Printf("⚠️ Failed to unmarshal lobby record %s: %v", key, err)
+				continue
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, err
+}
+
+func (bs *BoltService) DeleteLobby(lobbyID string) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(lobbiesBucket).Delete([]byte(bs.Key("%s", lobbyID)))
+	})
+}
+
+func (bs *BoltService) SetScore(key, member string, score float64) error {
+	return setJSONScore(bs, key, member, score)
+}
+
+func (bs *BoltService) TopScores(key string, n int) ([]ScoredMember, error) {
+	return topJSONScores(bs, key, n)
+}
+
+func (bs *BoltService) AppendStream(key, entry string, maxLen int64) error {
+	return appendJSONStream(bs, key, entry, maxLen)
+}
+
+func (bs *BoltService) ReadStream(key string, since, until time.Time) ([]string, error) {
+	return readJSONStream(bs, key, since, until)
+}
+
+func (bs *BoltService) PushInbox(key string, msg models.Message, maxLen int64) error {
+	return pushJSONInbox(bs, bs.cipher, bs.ids, key, msg, maxLen)
+}
+
+func (bs *BoltService) DrainInbox(key string) ([]models.RedisMessage, error) {
+	return drainJSONInbox(bs, bs.cipher, key)
+}
+
+// Degraded is always false: the Bolt file is local and has no outages to
+// ride out.
+func (bs *BoltService) Degraded() bool {
+	return false
+}
+
+// Ping runs an empty read transaction, which fails once the file is closed.
+func (bs *BoltService) Ping(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- bs.db.View(func(tx *bolt.Tx) error { return nil })
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (bs *BoltService) Close() {
+	bs.db.Close()
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBotNotFound    = errors.New("bot not found")
+	ErrInvalidBotName = errors.New("bot name must be 1-32 letters, digits, spaces, '-' or '_'")
+	ErrInvalidBotKey  = errors.New("invalid bot API key")
+	ErrBotForbidden   = errors.New("bot is not allowed to post in this lobby")
+)
+
+var botNamePattern = regexp.MustCompile(`^[A-Za-z0-9 _\-]{1,32}$`)
+
+// BotService manages bot accounts and their API keys.
+type BotService struct {
+	store Store
+	bots  map[string]models.Bot
+	mu    sync.RWMutex
+}
+
+func NewBotService(store Store) *BotService {
+	bts := &BotService{
+		store: store,
+		bots:  make(map[string]models.Bot),
+	}
+	bts.load()
+	return bts
+}
+
+// CreateBot registers a bot and returns it with its API key. The key is not
+// stored and cannot be retrieved again.
+func (bts *BotService) CreateBot(name, tenantID, lobbyID string) (models.Bot, string, error) {
+	name = strings.TrimSpace(name)
+	if !botNamePattern.MatchString(name) {
+		return models.Bot{}, "", ErrInvalidBotName
+	}
+
+	id, err := GenerateToken()
+	if err != nil {
+		return models.Bot{}, "", err
+	}
+	key, err := GenerateToken()
+	if err != nil {
+		return models.Bot{}, "", err
+	}
+
+	bot := models.Bot{
+		ID:        id[:16],
+		Name:      name,
+		TenantID:  tenantID,
+		LobbyID:   lobbyID,
+		KeyHash:   hashKey(key),
+		CreatedAt: time.Now(),
+	}
+
+	bts.mu.Lock()
+	defer bts.mu.Unlock()
+
+	bts.bots[bot.ID] = bot
+	if err := bts.saveLocked(); err != nil {
+		delete(bts.bots, bot.ID)
+		return models.Bot{}, "", err
+	}
+
+	log.Printf("🤖 Created bot %s (%s) for tenant %s", bot.Name, bot.ID, tenantID)
+	bot.KeyHash = ""
+	return bot, key, nil
+}
+
+// Authenticate returns the bot owning the API key.
+func (bts *BotService) Authenticate(key string) (models.Bot, error) {
+	if key == "" {
+		return models.Bot{}, ErrInvalidBotKey
+	}
+	hash := hashKey(key)
+
+	bts.mu.RLock()
+	defer bts.mu.RUnlock()
+
+	for _, bot := range bts.bots {
+		if subtle.ConstantTimeCompare([]byte(bot.KeyHash), []byte(hash)) == 1 {
+			bot.KeyHash = ""
+			return bot, nil
+		}
+	}
+	return models.Bot{}, ErrInvalidBotKey
+}
+
+// CanPost reports whether the bot may post into the lobby.
+func (bts *BotService) CanPost(bot models.Bot, lobby *models.Lobby) bool {
+	if bot.LobbyID != "" {
+		return bot.LobbyID == lobby.ID
+	}
+	return bot.TenantID == lobby.TenantID
+}
+
+// ListBots returns the bots of a tenant without their key hashes.
+func (bts *BotService) ListBots(tenantID string) []models.Bot {
+	bts.mu.RLock()
+	defer bts.mu.RUnlock()
+
+	bots := make([]models.Bot, 0)
+	for _, bot := range bts.bots {
+		if bot.TenantID == tenantID {
+			bot.KeyHash = ""
+			bots = append(bots, bot)
+		}
+	}
+	slices.SortFunc(bots, func(a, b models.Bot) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return bots
+}
+
+func (bts *BotService) DeleteBot(id string) error {
+	bts.mu.Lock()
+	defer bts.mu.Unlock()
+
+	if _, exists := bts.bots[id]; !exists {
+		return ErrBotNotFound
+	}
+	delete(bts.bots, id)
+
+	log.Printf("🗑️ Deleted bot %s", id)
+	return bts.saveLocked()
+}
+
+// hashKey is what is kept of bot and API keys.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (bts *BotService) load() {
+	botsJSON, err := bts.store.Get(bts.store.Key("bots"))
+	if err != nil {
+		if err != ErrKeyNotFound {
+			log.Printf("⚠️ Failed to load bots: %v", err)
+		}
+		return
+	}
+
+	var bots []models.Bot
+	if err := json.Unmarshal([]byte(botsJSON), &bots); err != nil {
+		log.Printf("⚠️ Invalid bots stored: %v", err)
+		return
+	}
+	for _, bot := range bots {
+		bts.bots[bot.ID] = bot
+	}
+}
+
+// saveLocked persists the bots; bts.mu must be held.
+func (bts *BotService) saveLocked() error {
+	bots := make([]models.Bot, 0, len(bts.bots))
+	for _, bot := range bts.bots {
+		bots = append(bots, bot)
+	}
+
+	botsJSON, err := json.Marshal(bots)
+	if err != nil {
+		return err
+	}
+	return bts.store.SetWithTTL(bts.store.Key("bots"), botsJSON, 0)
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrInvalidColor   = errors.New("colors must be hex values like #667eea")
+	ErrInvalidLogoURL = errors.New("logo_url must be an absolute http(s) URL")
+	ErrInvalidProduct = errors.New("product_name must be 1-64 characters")
+)
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type BrandingService struct {
+	store Store
+}
+
+func NewBrandingService(store Store) *BrandingService {
+	return &BrandingService{
+		store: store,
+	}
+}
+
+func DefaultBranding(tenantID string) models.Branding {
+	return models.Branding{
+		TenantID:     tenantID,
+		ProductName:  config.DefaultProductName,
+		PrimaryColor: "#667eea",
+		AccentColor:  "#764ba2",
+	}
+}
+
+// GetBranding returns the tenant's branding, or the defaults if the tenant
+// has none configured or the store is unavailable.
+func (bs *BrandingService) GetBranding(tenantID string) models.Branding {
+	brandingJSON, err := bs.store.Get(bs.brandingKey(tenantID))
+	if err != nil {
+		if err != ErrKeyNotFound {
+			log.Printf("⚠️ Failed to load branding for tenant %s: %v", tenantID, err)
+		}
+		return DefaultBranding(tenantID)
+	}
+
+	var branding models.Branding
+	if err := json.Unmarshal([]byte(brandingJSON), &branding); err != nil {
+		log.Printf("⚠️ Invalid branding stored for tenant %s: %v", tenantID, err)
+		return DefaultBranding(tenantID)
+	}
+	return branding
+}
+
+func (bs *BrandingService) SetBranding(branding models.Branding) (models.Branding, error) {
+	if len(branding.ProductName) == 0 || len(branding.ProductName) > 64 {
+		return branding, ErrInvalidProduct
+	}
+	if !hexColorPattern.MatchString(branding.PrimaryColor) || !hexColorPattern.MatchString(branding.AccentColor) {
+		return branding, ErrInvalidColor
+	}
+	if branding.LogoURL != "" {
+		parsed, err := url.Parse(branding.LogoURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return branding, ErrInvalidLogoURL
+		}
+	}
+
+	branding.UpdatedAt = time.Now()
+	brandingJSON, err := json.Marshal(branding)
+	if err != nil {
+		return branding, err
+	}
+
+	if err := bs.store.SetWithTTL(bs.brandingKey(branding.TenantID), brandingJSON, 0); err != nil {
+		return branding, err
+	}
+
+	log.Printf("🎨 Branding updated for tenant: %s", branding.TenantID)
+	return branding, nil
+}
+
+func (bs *BrandingService) brandingKey(tenantID string) string {
+	return bs.store.Key("tenant:%s:branding", tenantID)
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// setBudget gives the session a time budget of minutes, with an optional
+// cost per participant hour; 0 minutes removes it.
+func (ls *LobbyService) setBudget(lobby *models.Lobby, actor string, minutes int, hourlyRate float64) error {
+	if minutes < 0 || minutes > config.MaxBudgetMinutes {
+		return fmt.Errorf("budget must be between 0 and %d minutes", config.MaxBudgetMinutes)
+	}
+	if hourlyRate < 0 || hourlyRate > config.MaxHourlyRate || math.IsNaN(hourlyRate) {
+		return fmt.Errorf("hourly rate must be between 0 and %d", config.MaxHourlyRate)
+	}
+
+	// Count the time up to now at the old settings before they change
+	lobby.TickBudget(time.Now(), lobby.GetConnectedClientCount())
+	status := lobby.SetBudget(time.Duration(minutes)*time.Minute, hourlyRate)
+
+	content := fmt.Sprintf("%s removed the time budget", actor)
+	if status != nil {
+		content = fmt.Sprintf("%s set a %d minute time budget", actor, minutes)
+		// Start the clock now rather than at the next check
+		lobby.TickBudget(time.Now(), lobby.GetConnectedClientCount())
+	}
+	budgetMsg := ls.systemMessage(lobby, models.SystemActionBudget, actor, content)
+	budgetMsg.Budget = status
+	ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: budgetMsg})
+	log.Printf("⏱️ %s set the budget of lobby %s to %d minutes", actor, lobby.ID, minutes)
+	return nil
+}
+
+// checkBudget accrues the session's time and cost and announces each budget
+// milestone it passes.
+func (ls *LobbyService) checkBudget(lobby *models.Lobby) {
+	status, milestone := lobby.TickBudget(time.Now(), lobby.GetConnectedClientCount())
+	if milestone == 0 {
+		return
+	}
+
+	minutes := status.BudgetSeconds / 60
+	content := fmt.Sprintf("%d%% of the %d minute budget is used", milestone, minutes)
+	if milestone >= 100 {
+		content = fmt.Sprintf("The %d minute budget is used up", minutes)
+	}
+	log.Printf("⏱️ Lobby %s passed %d%% of its budget", lobby.ID, milestone)
+	milestoneMsg := ls.systemMessage(lobby, models.SystemActionBudgetMilestone, "", content)
+	milestoneMsg.Budget = status
+	ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: milestoneMsg})
+	ls.saveLobby(lobby)
+}
+
+// sessionStats describes a session's use of its budget for the end of
+// session notice, or returns "" if it had none.
+func sessionStats(status *models.BudgetStatus) string {
+	if status == nil {
+		return ""
+	}
+	stats := fmt.Sprintf(" after %s of a %s budget", time.Duration(status.ElapsedSeconds)*time.Second, time.Duration(status.BudgetSeconds)*time.Second)
+	if status.HourlyRate > 0 {
+		stats += fmt.Sprintf(", costing %.2f", status.Cost)
+	}
+	return stats
+}
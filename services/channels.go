@@ -0,0 +1,128 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrUnknownChannel     = errors.New("no channel with this channel_id")
+	ErrNotInChannel       = errors.New("you aren't in this channel")
+	ErrChannelChatOnly    = errors.New("only messages can be sent to a channel")
+	ErrNotLobbyMember     = errors.New("channel members must be members of the lobby")
+	ErrInvalidChannelName = fmt.Errorf("channel names are 1-%d characters", config.MaxChannelNameLength)
+	ErrTooManyChannels    = fmt.Errorf("a lobby can have at most %d channels", config.MaxChannelsPerLobby)
+)
+
+// createChannel opens a whisper group of actor and the lobby members the
+// frame's Channel names, and tells them. It runs on the lobby's worker.
+func (ls *LobbyService) createChannel(lobby *models.Lobby, actor string, frame models.Message) error {
+	if frame.Channel == nil {
+		return ErrInvalidChannelName
+	}
+	name := strings.TrimSpace(frame.Channel.Name)
+	if name == "" || utf8.RuneCountInString(name) > config.MaxChannelNameLength {
+		return ErrInvalidChannelName
+	}
+	if len(lobby.GetChannels()) >= config.MaxChannelsPerLobby {
+		return ErrTooManyChannels
+	}
+	members := []string{actor}
+	for _, member := range frame.Channel.Members {
+		email := ls.memberEmail(lobby, member)
+		if !lobby.IsUserInLobby(email) {
+			return ErrNotLobbyMember
+		}
+		if !slices.Contains(members, email) {
+			members = append(members, email)
+		}
+	}
+
+	channel := models.Channel{
+		ID:        "ch_" + ls.ids.NewID(),
+		Name:      name,
+		Members:   members,
+		CreatedBy: actor,
+		CreatedAt: time.Now(),
+	}
+	lobby.AddChannel(channel)
+	log.Printf("🤫 %s opened channel %s (%q) in lobby %s with %d members", actor, channel.ID, name, lobby.ID, len(members))
+	ls.notifyChannel(lobby, models.SystemActionChannelCreated, actor, fmt.Sprintf("%s opened the channel %s", actor, name), channel, channel.Members)
+	return nil
+}
+
+// leaveChannel takes actor out of a channel and tells its members, and
+// actor. It runs on the lobby's worker.
+func (ls *LobbyService) leaveChannel(lobby *models.Lobby, actor, channelID string) error {
+	channel, left := lobby.LeaveChannel(channelID, actor)
+	if !left {
+		if _, exists := lobby.GetChannel(channelID); exists {
+			return ErrNotInChannel
+		}
+		return ErrUnknownChannel
+	}
+	log.Printf("🤫 %s left channel %s in lobby %s (%d members left)", actor, channelID, lobby.ID, len(channel.Members))
+	ls.notifyChannel(lobby, models.SystemActionChannelLeft, actor, fmt.Sprintf("%s left the channel %s", actor, channel.Name), channel, append(channel.Members, actor))
+	return nil
+}
+
+// notifyChannel sends a channel notice about channel to the connected
+// recipients only.
+func (ls *LobbyService) notifyChannel(lobby *models.Lobby, action models.SystemActionType, actor, content string, channel models.Channel, recipients []string) {
+	notice := ls.systemMessage(lobby, action, actor, content)
+	notice.Target = actor
+	notice.Channel = &channel
+	clients := lobby.GetAllClients()
+	for _, email := range recipients {
+		client, connected := clients[email]
+		if !connected {
+			continue
+		}
+		select {
+		case client.Send <- notice:
+		default:
+			log.Printf("❌ Failed to deliver channel notice to: %s", email)
+		}
+	}
+}
+
+// whisperChannel returns the channel chat is addressed to, which its
+// sender must be in.
+func whisperChannel(lobby *models.Lobby, msg models.Message) (models.Channel, error) {
+	if msg.Type != models.MessageTypeChat {
+		return models.Channel{}, ErrChannelChatOnly
+	}
+	channel, exists := lobby.GetChannel(msg.ChannelID)
+	if !exists {
+		return models.Channel{}, ErrUnknownChannel
+	}
+	if !channel.HasMember(msg.Username) {
+		return models.Channel{}, ErrNotInChannel
+	}
+	return channel, nil
+}
+
+// rejectWhisper tells the sender why their message didn't go to the
+// channel.
+func (ls *LobbyService) rejectWhisper(lobby *models.Lobby, msg models.Message, err error) {
+	log.Printf("🤫 Message from %s to channel %s in lobby %s rejected: %v", msg.Username, msg.ChannelID, lobby.ID, err)
+	if client, connected := lobby.GetAllClients()[msg.Username]; connected {
+		ls.replyError(client, ErrorCodeOf(err), err.Error())
+	}
+}
+
+// channelIDs returns the IDs of the channels email is in.
+func channelIDs(lobby *models.Lobby, email string) []string {
+	var ids []string
+	for _, channel := range lobby.ChannelsOf(email) {
+		ids = append(ids, channel.ID)
+	}
+	return ids
+}
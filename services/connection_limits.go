@@ -0,0 +1,71 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"log"
+	"sync"
+)
+
+// ConnectionLimits counts the WebSocket connections open from each address,
+// so that one address can't take every connection a lobby has room for. It
+// is safe for concurrent use.
+type ConnectionLimits struct {
+	mu     sync.Mutex
+	max    int
+	counts map[string]int
+}
+
+// NewConnectionLimits allows max connections per address; 0 allows any
+// number.
+func NewConnectionLimits(max int) *ConnectionLimits {
+	return &ConnectionLimits{max: max, counts: make(map[string]int)}
+}
+
+// Acquire counts a new connection from ip, or reports false if ip already
+// has as many open as it may.
+func (cl *ConnectionLimits) Acquire(ip string) bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.max > 0 && cl.counts[ip] >= cl.max {
+		return false
+	}
+	cl.counts[ip]++
+	return true
+}
+
+// Release uncounts a connection from ip that Acquire let through.
+func (cl *ConnectionLimits) Release(ip string) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.counts[ip] <= 1 {
+		delete(cl.counts, ip)
+		return
+	}
+	cl.counts[ip]--
+}
+
+// Counts returns the open connections of each address that has any.
+func (cl *ConnectionLimits) Counts() map[string]int {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	counts := make(map[string]int, len(cl.counts))
+	for ip, count := range cl.counts {
+		counts[ip] = count
+	}
+	return counts
+}
+
+// AcquireConnection counts a /ws connection from ip against
+// config.MaxConnectionsPerIP, and reports false if it is over.
+func (ls *LobbyService) AcquireConnection(ip string) bool {
+	if !ls.connections.Acquire(ip) {
+		log.Printf("🚫 Too many connections from %s (max %d)", ip, config.MaxConnectionsPerIP)
+		return false
+	}
+	return true
+}
+
+// ReleaseConnection uncounts a /ws connection from ip once it closed.
+func (ls *LobbyService) ReleaseConnection(ip string) {
+	ls.connections.Release(ip)
+}
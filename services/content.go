@@ -0,0 +1,47 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"regexp"
+	"strings"
+)
+
+var (
+	// markdownCode matches fenced code blocks and inline code spans, which
+	// are shown verbatim and left alone
+	markdownCode = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+	htmlComment  = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlTag      = regexp.MustCompile(`</?[A-Za-z][A-Za-z0-9-]*(?:\s[^<>]*)?/?>`)
+	// The destinations of inline links, autolinks and link reference
+	// definitions that would run script or embed data
+	unsafeInlineLink    = regexp.MustCompile(`(?i)\]\(\s*<?\s*(?:javascript|vbscript|data|file):(?:[^()]|\([^()]*\))*\)`)
+	unsafeAutolink      = regexp.MustCompile(`(?i)<\s*(?:javascript|vbscript|data|file):[^>]*>`)
+	unsafeReferenceLink = regexp.MustCompile(`(?im)^(\s*\[[^\]]+\]:\s*)<?(?:javascript|vbscript|data|file):\S*`)
+)
+
+// sanitizeContent cleans a chat message's content for its format. Markdown
+// loses raw HTML and links to script or data URLs outside of code; plain
+// text and code are shown as text and kept as they are.
+func sanitizeContent(format models.ContentFormat, content string) string {
+	if format != models.ContentFormatMarkdown {
+		return content
+	}
+
+	var sanitized strings.Builder
+	last := 0
+	for _, code := range markdownCode.FindAllStringIndex(content, -1) {
+		sanitized.WriteString(sanitizeMarkdown(content[last:code[0]]))
+		sanitized.WriteString(content[code[0]:code[1]])
+		last = code[1]
+	}
+	sanitized.WriteString(sanitizeMarkdown(content[last:]))
+	return sanitized.String()
+}
+
+func sanitizeMarkdown(text string) string {
+	text = htmlComment.ReplaceAllString(text, "")
+	text = htmlTag.ReplaceAllString(text, "")
+	text = unsafeInlineLink.ReplaceAllString(text, "](#)")
+	text = unsafeAutolink.ReplaceAllString(text, "")
+	return unsafeReferenceLink.ReplaceAllString(text, "${1}#")
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Dashboard is the facilitator dashboard's snapshot of every lobby, the
+// waiting lines and the store.
+type Dashboard struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	Lobbies     []DashboardLobby `json:"lobbies"`
+	// Queues counts the users waiting for a seat, by tenant
+	Queues map[string]int `json:"queues"`
+	// ConnectionsByIP counts the open /ws connections of each address
+	ConnectionsByIP map[string]int `json:"connections_by_ip"`
+	Store           StoreHealth    `json:"store"`
+}
+
+// DashboardLobby is a lobby as the dashboard shows it. MessagesPerMinute
+// counts its chat messages in each of the last DashboardRateMinutes
+// minutes, the oldest first and the current one last; SendQueue counts the
+// frames waiting in its connections' send buffers.
+type DashboardLobby struct {
+	LobbyID           string           `json:"lobby_id"`
+	TenantID          string           `json:"tenant_id"`
+	CreatedAt         time.Time        `json:"created_at"`
+	Started           bool             `json:"started"`
+	ReadOnly          bool             `json:"read_only"`
+	MaxUsers          int              `json:"max_users"`
+	Connected         int              `json:"connected"`
+	Members           []MemberPresence `json:"members"`
+	MessagesPerMinute []int            `json:"messages_per_minute"`
+	MessagesRecent    int              `json:"messages_recent"`
+	SendQueue         int              `json:"send_queue"`
+	Connections       []Connection     `json:"connections"`
+}
+
+// Connection is where a member's connection to a lobby came from: the
+// address and user agent of its upgrade request and when it was made.
+type Connection struct {
+	MemberID    string    `json:"member_id"`
+	RemoteIP    string    `json:"remote_ip"`
+	UserAgent   string    `json:"user_agent"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+// StoreHealth is the outcome of pinging the store.
+type StoreHealth struct {
+	OK        bool    `json:"ok"`
+	LatencyMs float64 `json:"latency_ms"`
+	Degraded  bool    `json:"degraded"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Dashboard takes a snapshot of the live lobbies, internal ones aside.
+func (ls *LobbyService) Dashboard(ctx context.Context) Dashboard {
+	now := time.Now()
+	lobbies := ls.GetLobbies()
+	sort.Slice(lobbies, func(i, j int) bool {
+		return lobbies[i].CreatedAt.Before(lobbies[j].CreatedAt)
+	})
+
+	entries := make([]DashboardLobby, 0, len(lobbies))
+	for _, lobby := range lobbies {
+		if lobby.Internal {
+			continue
+		}
+		clients := lobby.GetAllClients()
+		entry := DashboardLobby{
+			LobbyID:           lobby.ID,
+			TenantID:          lobby.TenantID,
+			CreatedAt:         lobby.CreatedAt,
+			Started:           lobby.WebSocketStarted,
+			ReadOnly:          lobby.ReadOnly,
+			MaxUsers:          lobby.MaxUsers,
+			Connected:         len(clients),
+			Members:           ls.LobbyPresence(lobby),
+			MessagesPerMinute: ls.rates.PerMinute(lobby.ID, now),
+			Connections:       make([]Connection, 0, len(clients)),
+		}
+		for _, count := range entry.MessagesPerMinute {
+			entry.MessagesRecent += count
+		}
+		for _, client := range clients {
+			entry.SendQueue += len(client.Send)
+			entry.Connections = append(entry.Connections, Connection{
+				MemberID:    ls.MemberID(client.Email),
+				RemoteIP:    client.RemoteIP,
+				UserAgent:   client.UserAgent,
+				ConnectedAt: client.JoinedAt,
+			})
+		}
+		entries = append(entries, entry)
+	}
+
+	return Dashboard{
+		GeneratedAt:     now,
+		Lobbies:         entries,
+		Queues:          ls.queue.Lines(),
+		ConnectionsByIP: ls.connections.Counts(),
+		Store:           ls.storeHealth(ctx),
+	}
+}
+
+func (ls *LobbyService) storeHealth(ctx context.Context) StoreHealth {
+	ctx, cancel := context.WithTimeout(ctx, config.ReadinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ls.store.Ping(ctx)
+	health := StoreHealth{
+		OK:        err == nil,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Degraded:  ls.store.Degraded(),
+	}
+	if err != nil {
+		health.Error = err.Error()
+	}
+	return health
+}
+
+// MessageRates counts the chat messages of each lobby by the minute, over
+// the last config.DashboardRateMinutes minutes. It is safe for concurrent
+// use.
+type MessageRates struct {
+	mu      sync.Mutex
+	lobbies map[string]*minuteCounts
+}
+
+// minuteCounts holds a lobby's counts of the minutes up to newest, a Unix
+// minute; counts[0] is newest's.
+type minuteCounts struct {
+	newest int64
+	counts [config.DashboardRateMinutes]int
+}
+
+func NewMessageRates() *MessageRates {
+	return &MessageRates{lobbies: make(map[string]*minuteCounts)}
+}
+
+// Record counts a chat message of a lobby sent at now.
+func (mr *MessageRates) Record(lobbyID string, now time.Time) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	minutes, exists := mr.lobbies[lobbyID]
+	if !exists {
+		minutes = &minuteCounts{}
+		mr.lobbies[lobbyID] = minutes
+	}
+	minutes.advance(now)
+	minutes.counts[0]++
+}
+
+// PerMinute returns a lobby's counts of the last DashboardRateMinutes
+// minutes, the oldest first.
+func (mr *MessageRates) PerMinute(lobbyID string, now time.Time) []int {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	perMinute := make([]int, config.DashboardRateMinutes)
+	minutes, exists := mr.lobbies[lobbyID]
+	if !exists {
+		return perMinute
+	}
+	minutes.advance(now)
+	for i, count := range minutes.counts {
+		perMinute[len(perMinute)-1-i] = count
+	}
+	return perMinute
+}
+
+// Forget drops the counts of a lobby.
+func (mr *MessageRates) Forget(lobbyID string) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	delete(mr.lobbies, lobbyID)
+}
+
+// advance shifts the counts so that counts[0] is the minute of now.
+func (mc *minuteCounts) advance(now time.Time) {
+	minute := now.Unix() / 60
+	shift := minute - mc.newest
+	if shift <= 0 {
+		return
+	}
+	mc.newest = minute
+	if shift >= int64(len(mc.counts)) {
+		mc.counts = [config.DashboardRateMinutes]int{}
+		return
+	}
+	copy(mc.counts[shift:], mc.counts[:len(mc.counts)-int(shift)])
+	for i := range shift {
+		mc.counts[i] = 0
+	}
+}
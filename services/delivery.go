@@ -0,0 +1,94 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"log"
+	"sort"
+	"time"
+)
+
+// retainDelivered keeps a sequenced broadcast of a reliable lobby for
+// each member it is for, connected or not, until they ack it. It runs on
+// the lobby's worker, before the fan-out.
+func (ls *LobbyService) retainDelivered(lobby *models.Lobby, msg models.Message, whisper *models.Channel) {
+	if msg.Seq == 0 || lobby.Internal || !lobby.GetSettings().ReliableDelivery {
+		return
+	}
+	members := lobby.GetMemberEmails()
+	if whisper != nil {
+		members = whisper.Members
+	}
+	clients := lobby.GetAllClients()
+	now := time.Now()
+	for _, email := range members {
+		_, connected := clients[email]
+		if lobby.RetainFrame(email, msg, connected, now, config.DeliveryWindow) {
+			log.Printf("📮 %s fell %d frames behind on acks in lobby %s, giving up on the oldest", email, config.DeliveryWindow, lobby.ID)
+		}
+	}
+}
+
+// redeliver sends the frames of a reliable lobby that connected members
+// haven't acked within DeliveryAckTimeout again. A connection still
+// catching up on its history is left to finish first, and a full queue
+// waits for the next check.
+func (ls *LobbyService) redeliver(lobby *models.Lobby, now time.Time) {
+	if lobby.Internal || !lobby.GetSettings().ReliableDelivery {
+		return
+	}
+	for email, client := range lobby.GetAllClients() {
+		if client.Replay != nil {
+			continue
+		}
+		due, abandoned := lobby.DueFrames(email, now, config.DeliveryAckTimeout, config.DeliveryMaxAttempts)
+		if abandoned > 0 {
+			log.Printf("📮 %s never acked %d frames of lobby %s after %d attempts, giving up on them", email, abandoned, lobby.ID, config.DeliveryMaxAttempts)
+		}
+		if len(due) == 0 {
+			continue
+		}
+		sent := make([]int64, 0, len(due))
+	send:
+		for _, msg := range due {
+			select {
+			case client.Send <- msg:
+				sent = append(sent, msg.Seq)
+			default:
+				break send
+			}
+		}
+		// Only the frames that made it into the queue count as sent; the
+		// rest are still due at the next check
+		lobby.MarkSent(email, sent, now)
+		log.Printf("📮 Sent %d of %d unacked frames to %s again in lobby %s", len(sent), len(due), email, lobby.ID)
+	}
+}
+
+// withUnacked merges the frames a reliable lobby kept for a registering
+// client into the messages replayed to it, in seq order and without those
+// already there.
+func (ls *LobbyService) withUnacked(lobby *models.Lobby, client *models.Client, missed []models.Message) []models.Message {
+	if lobby.Internal || !lobby.GetSettings().ReliableDelivery {
+		return missed
+	}
+	unacked := lobby.UnackedFrames(client.Email, client.LastSeq, time.Now())
+	if len(unacked) == 0 {
+		return missed
+	}
+
+	replayed := make(map[int64]bool, len(missed))
+	for _, msg := range missed {
+		replayed[msg.Seq] = true
+	}
+	added := 0
+	for _, msg := range unacked {
+		if !replayed[msg.Seq] {
+			missed = append(missed, msg)
+			added++
+		}
+	}
+	sort.SliceStable(missed, func(i, j int) bool { return missed[i].Seq < missed[j].Seq })
+	log.Printf("📮 Resending %d unacked frames to %s in lobby %s, %d not in the history", len(unacked), client.Email, lobby.ID, added)
+	return missed
+}
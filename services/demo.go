@@ -0,0 +1,65 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"context"
+	"fmt"
+	"log"
+)
+
+// demoMembers are the fake users SeedDemo seats, the first as owner.
+var demoMembers = []string{"alice@demo.local", "bob@demo.local", "carol@demo.local"}
+
+// demoHistory is the chat SeedDemo posts, as a member index and a frame.
+var demoHistory = []struct {
+	member int
+	frame  models.Message
+}{
+	{0, models.Message{Content: "Welcome to the demo lobby 👋"}},
+	{1, models.Message{Content: "Hi Alice! What are we brainstorming today?"}},
+	{0, models.Message{Content: "Names for the **new mobile app**. Anything goes.", Format: models.ContentFormatMarkdown}},
+	{2, models.Message{Type: models.MessageTypeIdea, Content: "Pocket Huddle"}},
+	{1, models.Message{Type: models.MessageTypeIdea, Content: "Sparkboard"}},
+	{2, models.Message{Content: "@bob Sparkboard sounds like a whiteboard, I like it"}},
+	{0, models.Message{Content: "Vote on the ideas and we'll pick one at the end."}},
+}
+
+// SeedDemo fills the default tenant's lobby with fake members and chat
+// history for dev mode. It leaves a seat free for whoever logs in next, so
+// it seats fewer members when lobbies are small.
+func (ls *LobbyService) SeedDemo(ctx context.Context) (*models.Lobby, error) {
+	members := demoMembers[:max(0, min(len(demoMembers), ls.maxUsers-1))]
+	if len(members) == 0 {
+		return nil, fmt.Errorf("lobbies of %d seats have no room for demo members", ls.maxUsers)
+	}
+
+	var lobby *models.Lobby
+	for _, email := range members {
+		joined, _, err := ls.JoinLobby(email, config.DefaultTenantID)
+		if err != nil {
+			return nil, fmt.Errorf("seat %s: %w", email, err)
+		}
+		lobby = joined
+	}
+
+	// Chat goes through the lobby worker like any member's, so it is
+	// sequenced, stored and replayed to the next connection
+	posted := 0
+	for _, line := range demoHistory {
+		if line.member >= len(members) {
+			continue
+		}
+		msg, err := ClientFrame(&models.Client{Email: members[line.member], LobbyID: lobby.ID}, line.frame)
+		if err != nil {
+			return nil, err
+		}
+		if err := ls.Broadcast(ctx, BroadcastMessage{LobbyID: lobby.ID, Message: msg}); err != nil {
+			return nil, err
+		}
+		posted++
+	}
+
+	log.Printf("🧪 Seeded demo lobby %s with %d members and %d messages", lobby.ID, len(members), posted)
+	return lobby, nil
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"bytes"
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidEmojiName  = errors.New("emoji names must be 2-30 lowercase letters, digits or underscores")
+	ErrInvalidEmojiImage = errors.New("emoji images must be PNG, GIF, JPEG or WebP")
+	ErrEmojiTooLarge     = fmt.Errorf("emoji images must be at most %d KB", config.MaxEmojiSize>>10)
+	ErrEmojiPackFull     = fmt.Errorf("an emoji pack holds at most %d emoji", config.MaxEmojiPerPack)
+	ErrEmojiNotFound     = errors.New("emoji not found")
+)
+
+// Names are short enough that ":name:" fits in a reaction
+var (
+	emojiNamePattern      = regexp.MustCompile(`^[a-z0-9_]{2,30}$`)
+	emojiShortcodePattern = regexp.MustCompile(`:([a-z0-9_]{2,30}):`)
+)
+
+var emojiContentTypes = map[string]string{
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+}
+
+// EmojiService keeps the custom emoji packs of tenants and lobbies. Images
+// go through the attachment service, so they are scanned and deduplicated
+// like any upload.
+type EmojiService struct {
+	store       Store
+	attachments *AttachmentService
+
+	// mu serializes pack updates and guards the cache of packs by key
+	mu    sync.Mutex
+	cache map[string]map[string]models.Emoji
+}
+
+func NewEmojiService(store Store, attachments *AttachmentService) *EmojiService {
+	return &EmojiService{
+		store:       store,
+		attachments: attachments,
+		cache:       make(map[string]map[string]models.Emoji),
+	}
+}
+
+// Pack returns the emoji usable in a lobby: its tenant's, overridden by the
+// lobby's own of the same name.
+func (es *EmojiService) Pack(tenantID, lobbyID string) map[string]models.Emoji {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	pack := make(map[string]models.Emoji)
+	for name, emoji := range es.loadLocked(es.packKey(models.EmojiScopeTenant, tenantID)) {
+		pack[name] = emoji
+	}
+	for name, emoji := range es.loadLocked(es.packKey(models.EmojiScopeLobby, lobbyID)) {
+		pack[name] = emoji
+	}
+	return pack
+}
+
+// ScopePack returns the emoji registered directly on a tenant or lobby.
+func (es *EmojiService) ScopePack(scope models.EmojiScope, ownerID string) map[string]models.Emoji {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	pack := make(map[string]models.Emoji)
+	for name, emoji := range es.loadLocked(es.packKey(scope, ownerID)) {
+		pack[name] = emoji
+	}
+	return pack
+}
+
+// Add validates image and registers it as :name: on a tenant or lobby,
+// replacing an emoji of the same name there.
+func (es *EmojiService) Add(scope models.EmojiScope, ownerID, name string, image io.Reader, createdBy string) (models.Emoji, error) {
+	if !emojiNamePattern.MatchString(name) {
+		return models.Emoji{}, ErrInvalidEmojiName
+	}
+
+	data, err := io.ReadAll(io.LimitReader(image, config.MaxEmojiSize+1))
+	if err != nil {
+		return models.Emoji{}, err
+	}
+	if len(data) > config.MaxEmojiSize {
+		return models.Emoji{}, ErrEmojiTooLarge
+	}
+	// Trust the bytes, not the uploader's Content-Type
+	contentType := http.DetectContentType(data)
+	ext, allowed := emojiContentTypes[contentType]
+	if !allowed {
+		return models.Emoji{}, ErrInvalidEmojiImage
+	}
+
+	// Scan before taking the lock, which lobbies need to read their packs.
+	// The pack holds its images' references under its key.
+	key := es.packKey(scope, ownerID)
+	attachment, err := es.attachments.Store(bytes.NewReader(data), key, name+ext, contentType)
+	if err != nil {
+		return models.Emoji{}, err
+	}
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	pack := es.loadLocked(key)
+	previous, replacing := pack[name]
+	if !replacing && len(pack) >= config.MaxEmojiPerPack {
+		es.attachments.Release(attachment.Hash, key)
+		return models.Emoji{}, ErrEmojiPackFull
+	}
+
+	emoji := models.Emoji{
+		Name:        name,
+		URL:         attachment.URL,
+		Hash:        attachment.Hash,
+		ContentType: contentType,
+		Scope:       scope,
+		CreatedBy:   createdBy,
+		CreatedAt:   time.Now(),
+	}
+	updated := make(map[string]models.Emoji, len(pack)+1)
+	for existing, e := range pack {
+		updated[existing] = e
+	}
+	updated[name] = emoji
+	if err := es.saveLocked(key, updated); err != nil {
+		es.attachments.Release(attachment.Hash, key)
+		return models.Emoji{}, err
+	}
+	if replacing {
+		es.attachments.Release(previous.Hash, key)
+	}
+
+	log.Printf("😀 Emoji :%s: registered on %s %s", name, scope, ownerID)
+	return emoji, nil
+}
+
+// Remove unregisters :name: from a tenant or lobby and releases its image.
+func (es *EmojiService) Remove(scope models.EmojiScope, ownerID, name string) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	key := es.packKey(scope, ownerID)
+	pack := es.loadLocked(key)
+	emoji, exists := pack[name]
+	if !exists {
+		return ErrEmojiNotFound
+	}
+
+	updated := make(map[string]models.Emoji, len(pack))
+	for existing, e := range pack {
+		if existing != name {
+			updated[existing] = e
+		}
+	}
+	if err := es.saveLocked(key, updated); err != nil {
+		return err
+	}
+	es.attachments.Release(emoji.Hash, key)
+
+	log.Printf("🗑️ Emoji :%s: removed from %s %s", name, scope, ownerID)
+	return nil
+}
+
+// loadLocked returns the cached pack stored under key; callers must not
+// modify it. The caller holds es.mu.
+func (es *EmojiService) loadLocked(key string) map[string]models.Emoji {
+	if pack, cached := es.cache[key]; cached {
+		return pack
+	}
+
+	pack := make(map[string]models.Emoji)
+	packJSON, err := es.store.Get(key)
+	if err != nil {
+		if err != ErrKeyNotFound {
+			// Not cached, so the pack is read again once the store is back
+			log.Printf("⚠️ Failed to load emoji pack %s: %v", key, err)
+			return pack
+		}
+	} else if err := json.Unmarshal([]byte(packJSON), &pack); err != nil {
+		log.Printf("⚠️ Invalid emoji pack stored at %s: %v", key, err)
+	}
+
+	// Images stored before the attachment service kept its records have
+	// none, so the pack takes its references back when it is first loaded
+	for name, emoji := range pack {
+		ext := emojiContentTypes[emoji.ContentType]
+		if err := es.attachments.Retain(emoji.Hash, key, name+ext, emoji.ContentType); err != nil {
+			log.Printf("⚠️ Image of emoji :%s: in %s is missing: %v", name, key, err)
+		}
+	}
+	es.cache[key] = pack
+	return pack
+}
+
+func (es *EmojiService) saveLocked(key string, pack map[string]models.Emoji) error {
+	packJSON, err := json.Marshal(pack)
+	if err != nil {
+		return err
+	}
+	if err := es.store.SetWithTTL(key, packJSON, 0); err != nil {
+		log.Printf("❌ Failed to save emoji pack %s: %v", key, err)
+		return err
+	}
+	es.cache[key] = pack
+	return nil
+}
+
+func (es *EmojiService) packKey(scope models.EmojiScope, ownerID string) string {
+	return es.store.Key("%s:%s:emoji", scope, ownerID)
+}
+
+// customEmoji returns the image URLs of the pack's emoji that content uses
+// as :shortcodes:, or nil if it uses none.
+func customEmoji(content string, pack map[string]models.Emoji) map[string]string {
+	if len(pack) == 0 {
+		return nil
+	}
+
+	var used map[string]string
+	for _, match := range emojiShortcodePattern.FindAllStringSubmatch(content, -1) {
+		emoji, exists := pack[match[1]]
+		if !exists {
+			continue
+		}
+		if used == nil {
+			used = make(map[string]string)
+		}
+		used[emoji.Name] = emoji.URL
+	}
+	return used
+}
+
+// EmojiPack returns the custom emoji usable in a lobby.
+func (ls *LobbyService) EmojiPack(lobby *models.Lobby) map[string]models.Emoji {
+	return ls.emojiService.Pack(lobby.TenantID, lobby.ID)
+}
+
+// AddEmoji registers a custom emoji on a tenant or lobby and sends the new
+// pack to the lobbies that can use it.
+func (ls *LobbyService) AddEmoji(ctx context.Context, scope models.EmojiScope, ownerID, name string, image io.Reader, actor string) (models.Emoji, error) {
+	emoji, err := ls.emojiService.Add(scope, ownerID, name, image, actor)
+	if err != nil {
+		return emoji, err
+	}
+	ls.notifyEmojiChange(ctx, scope, ownerID, actor, fmt.Sprintf("%s added :%s:", actor, name))
+	return emoji, nil
+}
+
+// RemoveEmoji unregisters a custom emoji and sends the new pack to the
+// lobbies that could use it. Its image is deleted once no emoji uses it.
+func (ls *LobbyService) RemoveEmoji(ctx context.Context, scope models.EmojiScope, ownerID, name, actor string) error {
+	if err := ls.emojiService.Remove(scope, ownerID, name); err != nil {
+		return err
+	}
+	ls.notifyEmojiChange(ctx, scope, ownerID, actor, fmt.Sprintf("%s removed :%s:", actor, name))
+	return nil
+}
+
+// LoadEmojiPacks reads the packs of every live lobby and its tenant at
+// startup, so their images are referenced again before any is removed.
+func (ls *LobbyService) LoadEmojiPacks() {
+	ls.mu.RLock()
+	lobbies := make([]*models.Lobby, 0, len(ls.lobbies))
+	for _, lobby := range ls.lobbies {
+		lobbies = append(lobbies, lobby)
+	}
+	ls.mu.RUnlock()
+
+	for _, lobby := range lobbies {
+		ls.EmojiPack(lobby)
+	}
+}
+
+func (ls *LobbyService) notifyEmojiChange(ctx context.Context, scope models.EmojiScope, ownerID, actor, content string) {
+	var lobbies []*models.Lobby
+	ls.mu.RLock()
+	for lobbyID, lobby := range ls.lobbies {
+		if lobby.Internal {
+			continue
+		}
+		if (scope == models.EmojiScopeLobby && lobbyID == ownerID) || (scope == models.EmojiScopeTenant && lobby.TenantID == ownerID) {
+			lobbies = append(lobbies, lobby)
+		}
+	}
+	ls.mu.RUnlock()
+
+	for _, lobby := range lobbies {
+		notice := ls.systemMessage(lobby, models.SystemActionEmoji, actor, content)
+		notice.EmojiPack = ls.EmojiPack(lobby)
+		if err := ls.Broadcast(ctx, BroadcastMessage{LobbyID: lobby.ID, Message: notice}); err != nil && !errors.Is(err, ErrLobbyNotFound) {
+			log.Printf("⚠️ Failed to send emoji pack to lobby %s: %v", lobby.ID, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"log"
+	"slices"
+	"time"
+)
+
+// expiry returns when chat sent at sent disappears from a lobby, or the
+// zero time unless the lobby is in ephemeral mode.
+func expiry(lobby *models.Lobby, sent time.Time) time.Time {
+	seconds := lobby.GetSettings().EphemeralSeconds
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	return sent.Add(time.Duration(seconds) * time.Second)
+}
+
+// expired reports whether a message sent in ephemeral mode is gone by now.
+func expired(msg models.Message, now time.Time) bool {
+	return !msg.ExpireAt.IsZero() && msg.ExpireAt.Before(now)
+}
+
+// expireMessages removes the messages of a live lobby that expired by now
+// from its history and the store, and tells its clients to remove them
+// with a message_expired frame. The store only keeps older messages than
+// the history if the history dropped some; scanStore looks for expired
+// ones among them too. It runs on the lobby's worker.
+func (ls *LobbyService) expireMessages(lobby *models.Lobby, now time.Time, scanStore bool) {
+	var ids []string
+	var seqs []int64
+	for _, msg := range lobby.ExpireHistory(now) {
+		ids = append(ids, msg.MessageID)
+		seqs = append(seqs, msg.Seq)
+	}
+	if scanStore && lobby.IsHistoryTruncated() {
+		stored, err := ls.expiredStored(lobby.ID, now)
+		if err != nil {
+			log.Printf("⚠️ Failed to look for expired messages in the store of lobby %s: %v", lobby.ID, err)
+		}
+		for _, msg := range stored {
+			if !slices.Contains(ids, msg.MessageID) {
+				ids = append(ids, msg.MessageID)
+				seqs = append(seqs, msg.Seq)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	if err := ls.store.DeleteMessages(lobby.ID, ids); err != nil {
+		log.Printf("⚠️ Failed to delete %d expired messages of lobby %s: %v", len(ids), lobby.ID, err)
+	}
+	for _, seq := range lobby.GetPinned() {
+		if slices.Contains(seqs, seq) {
+			lobby.SetPinned(seq, false)
+		}
+	}
+	ls.saveLobby(lobby)
+	log.Printf("⏳ %d messages expired in lobby %s", len(ids), lobby.ID)
+	ls.audit(lobby, models.AuditMessagesExpired, models.AuditActorSystem, "", map[string]int{"messages": len(ids)})
+
+	expiredMsg := ls.systemMessage(lobby, models.SystemActionMessageExpired, "", "")
+	expiredMsg.Expired = ids
+	ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: expiredMsg})
+}
+
+// expiredStored returns a lobby's stored messages that expired by now.
+func (ls *LobbyService) expiredStored(lobbyID string, now time.Time) ([]models.RedisMessage, error) {
+	stored, err := ls.store.GetMessages(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+	var expired []models.RedisMessage
+	for _, msg := range stored {
+		if !msg.ExpireAt.IsZero() && msg.ExpireAt.Before(now) {
+			expired = append(expired, msg)
+		}
+	}
+	return expired, nil
+}
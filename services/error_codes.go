@@ -0,0 +1,44 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"errors"
+)
+
+// ErrorCodeOf is the code clients see for a service error. Errors without
+// a code of their own are taken to be the caller's mistake.
+func ErrorCodeOf(err error) models.ErrorCode {
+	var notStarted *NotStartedError
+	var infected *InfectedError
+	switch {
+	case errors.Is(err, ErrLobbyFull), errors.Is(err, ErrQueueFull), errors.Is(err, ErrGuestsFull):
+		return models.ErrorLobbyFull
+	case errors.Is(err, ErrSessionInProgress):
+		return models.ErrorSessionActive
+	case errors.Is(err, ErrKickedFromLobby):
+		return models.ErrorKicked
+	case errors.As(err, &notStarted):
+		return models.ErrorNotStarted
+	case errors.As(err, &infected):
+		return models.ErrorInfected
+	case errors.Is(err, ErrInvalidInvite), errors.Is(err, ErrInviteUsed):
+		return models.ErrorInvalidInvite
+	case errors.Is(err, ErrInvalidBotKey), errors.Is(err, ErrInvalidCredentials), errors.Is(err, ErrInvalidLink), errors.Is(err, ErrPasswordRequired):
+		return models.ErrorUnauthorized
+	case errors.Is(err, ErrSpoofedIdentity), errors.Is(err, ErrGuestRoom), errors.Is(err, ErrNotMember), errors.Is(err, ErrGuestsNotAllowed), errors.Is(err, ErrBotForbidden),
+		errors.Is(err, ErrNotInChannel):
+		return models.ErrorForbidden
+	case errors.Is(err, ErrLobbyNotFound), errors.Is(err, ErrNotJoined), errors.Is(err, ErrUnknownSession),
+		errors.Is(err, ErrUnknownMessage), errors.Is(err, ErrParentNotFound), errors.Is(err, ErrUnknownTicket),
+		errors.Is(err, ErrSessionNotFound), errors.Is(err, ErrNoSummary), errors.Is(err, ErrNoArchive), errors.Is(err, ErrAttachmentNotFound),
+		errors.Is(err, ErrEmojiNotFound), errors.Is(err, ErrBotNotFound), errors.Is(err, ErrWebhookNotFound), errors.Is(err, ErrUnknownChannel):
+		return models.ErrorNotFound
+	case errors.Is(err, ErrEmojiPackFull), errors.Is(err, ErrAccountExists), errors.Is(err, ErrTooManyChannels):
+		return models.ErrorConflict
+	case errors.Is(err, ErrAttachmentTooLarge), errors.Is(err, ErrEmojiTooLarge), errors.Is(err, ErrTooManyImported):
+		return models.ErrorTooLarge
+	case errors.Is(err, ErrStoreUnavailable), errors.Is(err, ErrScanFailed):
+		return models.ErrorUnavailable
+	}
+	return models.ErrorBadRequest
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrSpoofedIdentity    = errors.New("username and lobby_id are set by the server")
+	ErrInvalidClientMsgID = fmt.Errorf("client_msg_id must be at most %d bytes", config.MaxClientMsgIDLength)
+	ErrInvalidFormat      = fmt.Errorf("format must be %s, %s or %s", models.ContentFormatPlain, models.ContentFormatMarkdown, models.ContentFormatCode)
+)
+
+// ClientFrame rebuilds a frame read from a client's connection with the
+// server's view of who sent it. A frame claiming another username or lobby
+// is rejected; every other field the client may not set is dropped, so
+// nothing downstream can trust a client-supplied is_bot, seq, roles or
+// system action.
+func ClientFrame(client *models.Client, frame models.Message) (models.Message, error) {
+	if (frame.Username != "" && frame.Username != client.Email) || (frame.LobbyID != "" && frame.LobbyID != client.LobbyID) {
+		return models.Message{}, ErrSpoofedIdentity
+	}
+
+	msg := models.Message{
+		Type:      frame.Type,
+		Username:  client.Email,
+		LobbyID:   client.LobbyID,
+		Timestamp: time.Now(),
+	}
+
+	switch frame.Type {
+	case models.MessageTypeEndLobby:
+	case models.MessageTypeKick:
+		msg.Target = frame.Target
+	case models.MessageTypePin, models.MessageTypeUnpin:
+		msg.PinnedSeq = frame.PinnedSeq
+	case models.MessageTypeSetMaxUsers:
+		msg.MaxUsers = frame.MaxUsers
+	case models.MessageTypeSetRole:
+		msg.Target = frame.Target
+		msg.Role = frame.Role
+	case models.MessageTypeSetSystemEvents:
+		msg.SystemEvents = frame.SystemEvents
+	case models.MessageTypeSetGuestAccess:
+		msg.GuestFriendly = frame.GuestFriendly
+	case models.MessageTypeSetBudget:
+		msg.BudgetMinutes = frame.BudgetMinutes
+		msg.HourlyRate = frame.HourlyRate
+	case models.MessageTypeSetSlowMode:
+		msg.SlowModeSeconds = frame.SlowModeSeconds
+	case models.MessageTypeReact:
+		msg.TargetSeq = frame.TargetSeq
+		msg.Reaction = frame.Reaction
+	case models.MessageTypeVote:
+		msg.TargetSeq = frame.TargetSeq
+		msg.Vote = frame.Vote
+	case models.MessageTypeVisibility:
+		msg.Visibility = frame.Visibility
+	case models.MessageTypeMessageRead:
+		msg.MessageID = frame.MessageID
+	case models.MessageTypePing:
+		msg.ClientTs = frame.ClientTs
+		msg.RTTMs = frame.RTTMs
+	case models.MessageTypeHistoryAck:
+		if frame.Replay != nil {
+			msg.Replay = &models.ReplayProgress{Batch: frame.Replay.Batch}
+		}
+	case models.MessageTypeDeliveryAck:
+		msg.Seq = frame.Seq
+	case models.MessageTypeChannelCreate:
+		if frame.Channel != nil {
+			msg.Channel = &models.Channel{Name: frame.Channel.Name, Members: frame.Channel.Members}
+		}
+	case models.MessageTypeChannelLeave:
+		msg.ChannelID = frame.ChannelID
+	case models.MessageTypeReply:
+		msg.Content = frame.Content
+		msg.ParentMessageID = frame.ParentMessageID
+		msg.ClientMsgID = frame.ClientMsgID
+		msg.Format = frame.Format
+		msg.ChannelID = frame.ChannelID
+	case models.MessageTypeIdea:
+		msg.Content = frame.Content
+		msg.ClientMsgID = frame.ClientMsgID
+		msg.Format = frame.Format
+		msg.ChannelID = frame.ChannelID
+	default:
+		// Anything else the policy let through is chat
+		msg.Type = models.MessageTypeChat
+		msg.Content = frame.Content
+		msg.ClientMsgID = frame.ClientMsgID
+		msg.Format = frame.Format
+		msg.ChannelID = frame.ChannelID
+	}
+	if len(msg.ClientMsgID) > config.MaxClientMsgIDLength {
+		return models.Message{}, ErrInvalidClientMsgID
+	}
+	if !msg.Format.Valid() {
+		return models.Message{}, ErrInvalidFormat
+	}
+	return msg, nil
+}
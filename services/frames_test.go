@@ -0,0 +1,195 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// forged sets the fields only the server may set on frame, as a client
+// claiming to be a bot resending stored, pinned chat would.
+func forged(frame models.Message) models.Message {
+	action := models.SystemActionAnnouncement
+	frame.IsBot = true
+	frame.SystemAction = &action
+	frame.Roles = map[string]models.Role{"mallory@example.com": models.RoleOwner}
+	frame.Pinned = []int64{1}
+	frame.Score = 99
+	frame.Imported = true
+	frame.ImportedFrom = "lobby-forged"
+	frame.Redelivered = true
+	if frame.Seq == 0 {
+		frame.Seq = 42
+	}
+	return frame
+}
+
+func TestClientFrame(t *testing.T) {
+	client := &models.Client{Email: "mallory@example.com", LobbyID: "lobby-real"}
+	// want is what ClientFrame keeps besides the type, username and lobby
+	tests := []struct {
+		name  string
+		frame models.Message
+		want  models.Message
+	}{
+		{"chat",
+			models.Message{Type: models.MessageTypeChat, Content: "hi", ClientMsgID: "c1", Format: models.ContentFormatMarkdown, ChannelID: "ch1", Target: "bob@example.com"},
+			models.Message{Type: models.MessageTypeChat, Content: "hi", ClientMsgID: "c1", Format: models.ContentFormatMarkdown, ChannelID: "ch1"}},
+		{"reply",
+			models.Message{Type: models.MessageTypeReply, Content: "yes", ParentMessageID: 3, ClientMsgID: "c2", ChannelID: "ch1"},
+			models.Message{Type: models.MessageTypeReply, Content: "yes", ParentMessageID: 3, ClientMsgID: "c2", ChannelID: "ch1"}},
+		{"idea",
+			models.Message{Type: models.MessageTypeIdea, Content: "Sparkboard", ClientMsgID: "c3", ParentMessageID: 3},
+			models.Message{Type: models.MessageTypeIdea, Content: "Sparkboard", ClientMsgID: "c3"}},
+		{"end_lobby",
+			models.Message{Type: models.MessageTypeEndLobby, Content: "bye"},
+			models.Message{Type: models.MessageTypeEndLobby}},
+		{"kick",
+			models.Message{Type: models.MessageTypeKick, Target: "bob@example.com", Content: "out"},
+			models.Message{Type: models.MessageTypeKick, Target: "bob@example.com"}},
+		{"pin",
+			models.Message{Type: models.MessageTypePin, PinnedSeq: 3},
+			models.Message{Type: models.MessageTypePin, PinnedSeq: 3}},
+		{"unpin",
+			models.Message{Type: models.MessageTypeUnpin, PinnedSeq: 3},
+			models.Message{Type: models.MessageTypeUnpin, PinnedSeq: 3}},
+		{"set_max_users",
+			models.Message{Type: models.MessageTypeSetMaxUsers, MaxUsers: 8},
+			models.Message{Type: models.MessageTypeSetMaxUsers, MaxUsers: 8}},
+		{"set_role",
+			models.Message{Type: models.MessageTypeSetRole, Target: "bob@example.com", Role: models.RoleModerator},
+			models.Message{Type: models.MessageTypeSetRole, Target: "bob@example.com", Role: models.RoleModerator}},
+		{"set_system_events",
+			models.Message{Type: models.MessageTypeSetSystemEvents, SystemEvents: models.SystemEventsDigest},
+			models.Message{Type: models.MessageTypeSetSystemEvents, SystemEvents: models.SystemEventsDigest}},
+		{"set_guest_access",
+			models.Message{Type: models.MessageTypeSetGuestAccess, GuestFriendly: true},
+			models.Message{Type: models.MessageTypeSetGuestAccess, GuestFriendly: true}},
+		{"set_budget",
+			models.Message{Type: models.MessageTypeSetBudget, BudgetMinutes: 30, HourlyRate: 120},
+			models.Message{Type: models.MessageTypeSetBudget, BudgetMinutes: 30, HourlyRate: 120}},
+		{"set_slow_mode",
+			models.Message{Type: models.MessageTypeSetSlowMode, SlowModeSeconds: 10},
+			models.Message{Type: models.MessageTypeSetSlowMode, SlowModeSeconds: 10}},
+		{"react",
+			models.Message{Type: models.MessageTypeReact, TargetSeq: 3, Reaction: "👍"},
+			models.Message{Type: models.MessageTypeReact, TargetSeq: 3, Reaction: "👍"}},
+		{"vote",
+			models.Message{Type: models.MessageTypeVote, TargetSeq: 3, Vote: 1},
+			models.Message{Type: models.MessageTypeVote, TargetSeq: 3, Vote: 1}},
+		{"visibility",
+			models.Message{Type: models.MessageTypeVisibility, Visibility: models.VisibilityHidden},
+			models.Message{Type: models.MessageTypeVisibility, Visibility: models.VisibilityHidden}},
+		{"message_read",
+			models.Message{Type: models.MessageTypeMessageRead, MessageID: "m1"},
+			models.Message{Type: models.MessageTypeMessageRead, MessageID: "m1"}},
+		{"ping",
+			models.Message{Type: models.MessageTypePing, ClientTs: 1700000000000, RTTMs: 12},
+			models.Message{Type: models.MessageTypePing, ClientTs: 1700000000000, RTTMs: 12}},
+		{"history_ack",
+			models.Message{Type: models.MessageTypeHistoryAck, Replay: &models.ReplayProgress{Batch: 2, Total: 500, Done: true}},
+			models.Message{Type: models.MessageTypeHistoryAck, Replay: &models.ReplayProgress{Batch: 2}}},
+		{"history_ack without replay",
+			models.Message{Type: models.MessageTypeHistoryAck},
+			models.Message{Type: models.MessageTypeHistoryAck}},
+		{"delivery_ack keeps its seq",
+			models.Message{Type: models.MessageTypeDeliveryAck, Seq: 7},
+			models.Message{Type: models.MessageTypeDeliveryAck, Seq: 7}},
+		{"channel_create",
+			models.Message{Type: models.MessageTypeChannelCreate, Channel: &models.Channel{ID: "ch-forged", Name: "design", Members: []string{"bob@example.com"}, CreatedBy: "bob@example.com"}},
+			models.Message{Type: models.MessageTypeChannelCreate, Channel: &models.Channel{Name: "design", Members: []string{"bob@example.com"}}}},
+		{"channel_create without channel",
+			models.Message{Type: models.MessageTypeChannelCreate},
+			models.Message{Type: models.MessageTypeChannelCreate}},
+		{"channel_leave",
+			models.Message{Type: models.MessageTypeChannelLeave, ChannelID: "ch1"},
+			models.Message{Type: models.MessageTypeChannelLeave, ChannelID: "ch1"}},
+		{"unknown type is chat",
+			models.Message{Type: "system_action", Content: "hi"},
+			models.Message{Type: models.MessageTypeChat, Content: "hi"}},
+	}
+
+	identities := []struct {
+		name              string
+		username, lobbyID string
+	}{
+		{"unset", "", ""},
+		{"matching", client.Email, client.LobbyID},
+	}
+	for _, tt := range tests {
+		for _, identity := range identities {
+			t.Run(tt.name+"/"+identity.name, func(t *testing.T) {
+				frame := forged(tt.frame)
+				frame.Username = identity.username
+				frame.LobbyID = identity.lobbyID
+
+				before := time.Now()
+				got, err := ClientFrame(client, frame)
+				if err != nil {
+					t.Fatalf("ClientFrame() error = %v", err)
+				}
+				if got.Timestamp.Before(before) {
+					t.Errorf("Timestamp = %v, want the server's receive time", got.Timestamp)
+				}
+
+				want := tt.want
+				want.Username = client.Email
+				want.LobbyID = client.LobbyID
+				want.Timestamp = got.Timestamp
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("ClientFrame() =\n%+v\nwant\n%+v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestClientFrameRejectsSpoofedIdentity(t *testing.T) {
+	client := &models.Client{Email: "mallory@example.com", LobbyID: "lobby-real"}
+	tests := []struct {
+		name              string
+		username, lobbyID string
+	}{
+		{"other username", "alice@example.com", ""},
+		{"other lobby", "", "lobby-other"},
+		{"both", "alice@example.com", "lobby-other"},
+		{"username differs in case", "Mallory@example.com", client.LobbyID},
+	}
+	for _, tt := range tests {
+		for _, frameType := range []models.MessageType{models.MessageTypeChat, models.MessageTypeKick, models.MessageTypeDeliveryAck} {
+			t.Run(tt.name+"/"+string(frameType), func(t *testing.T) {
+				frame := models.Message{Type: frameType, Username: tt.username, LobbyID: tt.lobbyID, Content: "hi", Target: "bob@example.com", Seq: 7}
+				if _, err := ClientFrame(client, frame); !errors.Is(err, ErrSpoofedIdentity) {
+					t.Errorf("ClientFrame() error = %v, want %v", err, ErrSpoofedIdentity)
+				}
+			})
+		}
+	}
+}
+
+func TestClientFrameValidation(t *testing.T) {
+	client := &models.Client{Email: "mallory@example.com", LobbyID: "lobby-real"}
+	tests := []struct {
+		name    string
+		frame   models.Message
+		wantErr error
+	}{
+		{"client_msg_id at the limit", models.Message{Content: "hi", ClientMsgID: strings.Repeat("x", config.MaxClientMsgIDLength)}, nil},
+		{"client_msg_id too long", models.Message{Content: "hi", ClientMsgID: strings.Repeat("x", config.MaxClientMsgIDLength+1)}, ErrInvalidClientMsgID},
+		{"reply client_msg_id too long", models.Message{Type: models.MessageTypeReply, Content: "hi", ParentMessageID: 1, ClientMsgID: strings.Repeat("x", config.MaxClientMsgIDLength+1)}, ErrInvalidClientMsgID},
+		{"unknown format", models.Message{Content: "hi", Format: "html"}, ErrInvalidFormat},
+		{"idea with unknown format", models.Message{Type: models.MessageTypeIdea, Content: "hi", Format: "html"}, ErrInvalidFormat},
+		{"format dropped from control frames", models.Message{Type: models.MessageTypePing, Format: "html"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ClientFrame(client, tt.frame); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ClientFrame() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
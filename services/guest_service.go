@@ -0,0 +1,62 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+)
+
+var (
+	ErrGuestsNotAllowed = errors.New("this lobby does not accept guests")
+	ErrGuestsFull       = fmt.Errorf("this lobby already has %d guests", config.MaxGuestsPerLobby)
+)
+
+var (
+	guestAdjectives = []string{
+		"curious", "brave", "calm", "clever", "eager", "gentle", "happy", "jolly",
+		"kind", "lively", "lucky", "merry", "nimble", "quiet", "quick", "sunny",
+		"swift", "witty", "bold", "bright",
+	}
+	guestAnimals = []string{
+		"otter", "badger", "falcon", "fox", "heron", "koala", "lynx", "marten",
+		"owl", "panda", "puffin", "raven", "seal", "sparrow", "tiger", "walrus",
+		"wombat", "yak", "zebra", "beaver",
+	}
+)
+
+// JoinAsGuest adds a guest with a generated display name, such as
+// "curious-otter-42", to a guest-friendly lobby.
+func (ls *LobbyService) JoinAsGuest(lobbyID string) (*models.Lobby, string, error) {
+	lobby := ls.GetLobby(lobbyID)
+	if lobby == nil || lobby.Internal {
+		return nil, "", ErrLobbyNotFound
+	}
+	if !lobby.IsGuestFriendly() {
+		return nil, "", ErrGuestsNotAllowed
+	}
+	if !lobby.CanAcceptGuests(config.MaxGuestsPerLobby) {
+		return nil, "", ErrGuestsFull
+	}
+
+	name := ls.addGuest(lobby)
+	ls.saveLobby(lobby)
+	log.Printf("🎭 Guest %s joined lobby %s (%d/%d guests)", name, lobby.ID, lobby.GetGuestCount(), config.MaxGuestsPerLobby)
+	return lobby, name, nil
+}
+
+// addGuest adds a guest to the lobby under a display name no lobby member
+// uses yet and returns the name.
+func (ls *LobbyService) addGuest(lobby *models.Lobby) string {
+	for {
+		name := fmt.Sprintf("%s-%s-%d",
+			guestAdjectives[rand.IntN(len(guestAdjectives))],
+			guestAnimals[rand.IntN(len(guestAnimals))],
+			rand.IntN(90)+10)
+		if ls.FindLobbyByUserEmail(name) == nil && lobby.AddGuest(name) {
+			return name
+		}
+	}
+}
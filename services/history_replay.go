@@ -0,0 +1,101 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"errors"
+	"log"
+	"slices"
+	"time"
+)
+
+var ErrUnknownBatch = errors.New("no such history batch was sent")
+
+// startReplay begins the batched catch-up of a connection that just
+// registered on the messages it missed. It reports false if the connection
+// was dropped.
+func (ls *LobbyService) startReplay(lobby *models.Lobby, client *models.Client, backlog []models.Message) bool {
+	log.Printf("📚 Replaying %d history messages to %s in batches of %d", len(backlog), client.Email, config.HistoryBatchSize)
+	client.Replay = &models.HistoryReplay{
+		Pending: slices.Clone(backlog),
+		Total:   len(backlog),
+		AckedAt: time.Now(),
+	}
+	return ls.sendReplayBatches(lobby, client)
+}
+
+// sendReplayBatches sends history batches until HistoryReplayWindow of them
+// wait for an ack, or the replay is done, so the connection's queue never
+// holds more than a few frames of it. It reports false if the connection
+// was dropped.
+func (ls *LobbyService) sendReplayBatches(lobby *models.Lobby, client *models.Client) bool {
+	replay := client.Replay
+	for replay.Batch-replay.Acked < config.HistoryReplayWindow {
+		n := min(config.HistoryBatchSize, len(replay.Pending))
+		batch := replay.Pending[:n:n]
+		replay.Pending = replay.Pending[n:]
+		replay.Sent += n
+		replay.Batch++
+		done := len(replay.Pending) == 0
+		if done {
+			// Broadcasts go straight to the connection from here on
+			client.Replay = nil
+		}
+
+		frame := models.Message{
+			Type:    models.MessageTypeHistoryBatch,
+			LobbyID: lobby.ID,
+			History: batch,
+			Replay: &models.ReplayProgress{
+				Batch:   replay.Batch,
+				Batches: replay.Batch + (len(replay.Pending)+config.HistoryBatchSize-1)/config.HistoryBatchSize,
+				Sent:    replay.Sent,
+				Total:   replay.Total,
+				Done:    done,
+			},
+			Timestamp: time.Now(),
+		}
+		if !ls.replay(lobby, client, frame) {
+			client.Replay = nil
+			return false
+		}
+		if done {
+			log.Printf("✅ History replay to %s done: %d messages in %d batches", client.Email, replay.Total, replay.Batch)
+			return true
+		}
+	}
+	return true
+}
+
+// holdForReplay queues a broadcast behind the history a connection is
+// still catching up on, so it arrives in order. A connection that stopped
+// acking is dropped instead; holdForReplay then reports false.
+func (ls *LobbyService) holdForReplay(lobby *models.Lobby, client *models.Client, msg models.Message) bool {
+	replay := client.Replay
+	if len(replay.Pending) >= config.MaxReplayBacklog || time.Since(replay.AckedAt) > config.HistoryAckTimeout {
+		log.Printf("❌ %s stopped acking its history replay at batch %d, dropping the connection", client.Email, replay.Acked)
+		client.Replay = nil
+		if lobby.DetachClient(client) {
+			client.CloseWith(models.CloseTooSlow, "Too slow to receive the history")
+		}
+		return false
+	}
+	replay.Pending = append(replay.Pending, msg)
+	replay.Total++
+	return true
+}
+
+// ackReplay records a client's ack of a history batch and sends the
+// batches it made room for. Acks after the replay ended are ignored.
+func (ls *LobbyService) ackReplay(lobby *models.Lobby, client *models.Client, batch int) error {
+	replay := client.Replay
+	if replay == nil || batch <= replay.Acked {
+		return nil
+	}
+	if batch > replay.Batch {
+		return ErrUnknownBatch
+	}
+	replay.Acked, replay.AckedAt = batch, time.Now()
+	ls.sendReplayBatches(lobby, client)
+	return nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// rankIdea puts an idea into its lobby's ranking, a sorted set scored by
+// the idea's upvotes minus its downvotes. It runs on the lobby's worker.
+func (ls *LobbyService) rankIdea(lobby *models.Lobby, seq int64, score int) {
+	if err := ls.store.SetScore(ls.ideasKey(lobby.ID), ideaMember(seq), float64(score)); err != nil {
+		log.Printf("⚠️ Failed to rank idea %d of lobby %s: %v", seq, lobby.ID, err)
+	}
+}
+
+// RankedIdeas returns the n best voted ideas of a lobby, all of them for
+// n <= 0. Equal scores rank the newer idea first.
+func (ls *LobbyService) RankedIdeas(lobby *models.Lobby, n int) ([]models.Idea, error) {
+	return ls.rankedIdeas(lobby.ID, n, lobby.Scores, func(seq int64) (models.Message, error) {
+		return ls.messageBySeq(lobby, seq)
+	})
+}
+
+// endedIdeas ranks the ideas of an ended session from its transcript and
+// stored votes, for its summary.
+func (ls *LobbyService) endedIdeas(lobbyID string, transcript []models.RedisMessage) ([]models.Idea, error) {
+	ideas := make(map[int64]models.Message)
+	for _, stored := range transcript {
+		if stored.Idea {
+			ideas[stored.Seq] = fromRedisMessage(stored)
+		}
+	}
+
+	scores := models.NewScoreboard()
+	scoresJSON, err := ls.store.Get(ls.store.Key("lobby:%s:scores", lobbyID))
+	if err == nil {
+		err = json.Unmarshal([]byte(scoresJSON), scores)
+	}
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+
+	return ls.rankedIdeas(lobbyID, 0, scores, func(seq int64) (models.Message, error) {
+		idea, exists := ideas[seq]
+		if !exists {
+			return models.Message{}, fmt.Errorf("no message with seq %d", seq)
+		}
+		return idea, nil
+	})
+}
+
+func (ls *LobbyService) rankedIdeas(lobbyID string, n int, scores *models.Scoreboard, idea func(seq int64) (models.Message, error)) ([]models.Idea, error) {
+	ranked, err := ls.store.TopScores(ls.ideasKey(lobbyID), n)
+	if err != nil {
+		return nil, err
+	}
+
+	ideas := make([]models.Idea, 0, len(ranked))
+	for _, member := range ranked {
+		seq, err := strconv.ParseInt(member.Member, 10, 64)
+		if err != nil {
+			continue
+		}
+		msg, err := idea(seq)
+		if err != nil {
+			// Retention may have pruned it
+			continue
+		}
+		tally := scores.Tally(seq)
+		ideas = append(ideas, models.Idea{
+			IdeaID:    msg.MessageID,
+			Seq:       seq,
+			Username:  msg.Username,
+			Content:   msg.Content,
+			Score:     int(member.Score),
+			Upvotes:   tally.Upvotes,
+			Downvotes: tally.Downvotes,
+			Timestamp: msg.Timestamp,
+		})
+	}
+	return ideas, nil
+}
+
+// ideaMember is an idea's member in its lobby's ranking: its seq padded to
+// a fixed width, so members order like their seqs.
+func ideaMember(seq int64) string {
+	return fmt.Sprintf("%020d", seq)
+}
+
+func (ls *LobbyService) ideasKey(lobbyID string) string {
+	return ls.store.Key("lobby:%s:ideas", lobbyID)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator makes the unique part of the IDs of new lobbies and messages.
+// Implementations must be safe for concurrent use.
+type IDGenerator interface {
+	NewID() string
+}
+
+// NewIDGenerator returns the generator of an ID_FORMAT: "ulid" or "uuid".
+func NewIDGenerator(format string) (IDGenerator, error) {
+	switch format {
+	case "ulid", "":
+		return &ULIDs{}, nil
+	case "uuid":
+		return UUIDs{}, nil
+	default:
+		return nil, fmt.Errorf("unknown ID format %q (expected ulid or uuid)", format)
+	}
+}
+
+// lobbyIDPattern matches the IDs newLobbyIDLocked makes in either format.
+var lobbyIDPattern = regexp.MustCompile(`^lobby-([0-9A-HJKMNP-TV-Z]{26}|[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})$`)
+
+// IsLobbyID reports whether id has the form of a lobby ID.
+func IsLobbyID(id string) bool {
+	return lobbyIDPattern.MatchString(id)
+}
+
+// crockford is the Base32 alphabet of ULIDs, without I, L, O and U.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDs makes ULIDs: 26 characters of Crockford Base32 holding a 48-bit
+// millisecond timestamp and 80 random bits. IDs made in the same
+// millisecond increment the random bits, so they still sort in the order
+// they were made.
+type ULIDs struct {
+	mu      sync.Mutex
+	lastMs  uint64
+	entropy [10]byte
+}
+
+func (g *ULIDs) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+	if ms > g.lastMs || !g.increment() {
+		// A new millisecond, or the random bits ran out within this one
+		g.lastMs = max(ms, g.lastMs+1)
+		rand.Read(g.entropy[:])
+	}
+
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:2], uint16(g.lastMs>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(g.lastMs))
+	copy(id[6:], g.entropy[:])
+
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+	out := make([]byte, 26)
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
+
+// increment adds one to the random bits, or reports false if they
+// overflowed.
+func (g *ULIDs) increment() bool {
+	for i := len(g.entropy) - 1; i >= 0; i-- {
+		g.entropy[i]++
+		if g.entropy[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// UUIDs makes version 7 UUIDs, which also sort by the millisecond they were
+// made in.
+type UUIDs struct{}
+
+func (UUIDs) NewID() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
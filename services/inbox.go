@@ -0,0 +1,72 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"log"
+	"sort"
+)
+
+// fillInboxes keeps a stored message for each member with no connection,
+// so they get it when they reconnect even if retention pruned it from the
+// history meanwhile. It runs on the lobby's worker.
+func (ls *LobbyService) fillInboxes(lobby *models.Lobby, msg models.Message) {
+	// Messages that disappear are never kept apart from the history
+	if config.InboxMaxMessages <= 0 || !msg.ExpireAt.IsZero() {
+		return
+	}
+	clients := lobby.GetAllClients()
+	for _, email := range lobby.GetMemberEmails() {
+		if _, connected := clients[email]; connected {
+			continue
+		}
+		if err := ls.store.PushInbox(ls.inboxKey(lobby.ID, email), msg, int64(config.InboxMaxMessages)); err != nil {
+			log.Printf("⚠️ Failed to keep message %d of lobby %s for %s: %v", msg.Seq, lobby.ID, email, err)
+		}
+	}
+}
+
+// withInbox drains the inbox of a registering client into the messages
+// replayed to it, in seq order and without those already there.
+func (ls *LobbyService) withInbox(lobby *models.Lobby, client *models.Client, missed []models.Message) []models.Message {
+	// GraphQL feeds only deliver what happens from now on, so the inbox
+	// waits for the member's next connection
+	if config.InboxMaxMessages <= 0 || client.Conn == nil {
+		return missed
+	}
+	stored, err := ls.store.DrainInbox(ls.inboxKey(lobby.ID, client.Email))
+	if err != nil {
+		log.Printf("⚠️ Failed to drain the inbox of %s in lobby %s: %v", client.Email, lobby.ID, err)
+		return missed
+	}
+	if len(stored) == 0 {
+		return missed
+	}
+
+	replayed := make(map[int64]bool, len(missed))
+	for _, msg := range missed {
+		replayed[msg.Seq] = true
+	}
+	added := 0
+	for _, storedMsg := range stored {
+		if storedMsg.Seq > client.LastSeq && !replayed[storedMsg.Seq] {
+			replayed[storedMsg.Seq] = true
+			missed = append(missed, fromRedisMessage(storedMsg))
+			added++
+		}
+	}
+	sort.SliceStable(missed, func(i, j int) bool { return missed[i].Seq < missed[j].Seq })
+	log.Printf("📬 Drained %d inbox messages for %s in lobby %s, %d not in the history", len(stored), client.Email, lobby.ID, added)
+	return missed
+}
+
+// dropInbox deletes the inbox of a member who lost their seat.
+func (ls *LobbyService) dropInbox(lobbyID, email string) {
+	if err := ls.store.Delete(ls.inboxKey(lobbyID, email)); err != nil {
+		log.Printf("⚠️ Failed to delete the inbox of %s in lobby %s: %v", email, lobbyID, err)
+	}
+}
+
+func (ls *LobbyService) inboxKey(lobbyID, email string) string {
+	return ls.store.Key("lobby:%s:inbox:%s", lobbyID, email)
+}
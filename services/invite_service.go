@@ -0,0 +1,182 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidInvite = errors.New("invite is invalid or has expired")
+	ErrInviteUsed    = errors.New("invite has already been used")
+	ErrInviteTooLong = fmt.Errorf("invites may last at most %s", config.MaxInviteTTL)
+)
+
+// InviteService issues the tokens of lobby invite links. A token is its
+// invite, signed with the server's secret, so verifying one needs no
+// lookup; only the redemptions of single use invites are stored.
+type InviteService struct {
+	store        Store
+	auditService *AuditService
+	key          []byte
+
+	// mu serializes redemptions, which is what makes single use invites
+	// single use on this server
+	mu sync.Mutex
+}
+
+func NewInviteService(store Store, auditService *AuditService) *InviteService {
+	key := []byte(config.InviteSecret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+		log.Printf("⚠️ INVITE_SECRET not set, invite links will stop working on restart")
+	}
+	return &InviteService{
+		store:        store,
+		auditService: auditService,
+		key:          key,
+	}
+}
+
+// Create issues an invite to lobby lasting ttl, or config.InviteTTL when
+// ttl is zero, and returns it with its token.
+func (is *InviteService) Create(lobby *models.Lobby, createdBy string, ttl time.Duration, singleUse bool) (models.Invite, string, error) {
+	if ttl == 0 {
+		ttl = config.InviteTTL
+	}
+	if ttl < 0 || ttl > config.MaxInviteTTL {
+		return models.Invite{}, "", ErrInviteTooLong
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return models.Invite{}, "", err
+	}
+	invite := models.Invite{
+		ID:        "inv_" + hex.EncodeToString(id),
+		LobbyID:   lobby.ID,
+		CreatedBy: createdBy,
+		ExpiresAt: time.Now().Add(ttl).UTC().Truncate(time.Second),
+		SingleUse: singleUse,
+	}
+	payload, err := json.Marshal(invite)
+	if err != nil {
+		return models.Invite{}, "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	is.auditService.Record(models.AuditEvent{
+		Action:   models.AuditInviteCreated,
+		LobbyID:  lobby.ID,
+		TenantID: lobby.TenantID,
+		Actor:    createdBy,
+		Details: map[string]interface{}{
+			"invite_id":  invite.ID,
+			"expires_at": invite.ExpiresAt,
+			"single_use": invite.SingleUse,
+		},
+	})
+	log.Printf("🎟️ %s created invite %s to lobby %s (expires %s, single use: %v)", createdBy, invite.ID, lobby.ID, invite.ExpiresAt.Format(time.RFC3339), invite.SingleUse)
+	return invite, encoded + "." + is.sign(encoded), nil
+}
+
+// Verify returns the invite a token carries if the server signed it and it
+// hasn't expired. It doesn't tell whether a single use invite was spent.
+func (is *InviteService) Verify(token string) (models.Invite, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(is.sign(encoded))) {
+		return models.Invite{}, ErrInvalidInvite
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return models.Invite{}, ErrInvalidInvite
+	}
+	var invite models.Invite
+	if err := json.Unmarshal(payload, &invite); err != nil || invite.ID == "" || invite.LobbyID == "" {
+		return models.Invite{}, ErrInvalidInvite
+	}
+	if !time.Now().Before(invite.ExpiresAt) {
+		return models.Invite{}, ErrInvalidInvite
+	}
+	return invite, nil
+}
+
+// Redeem spends a single use invite, failing with ErrInviteUsed if it was
+// spent already. Other invites can be redeemed until they expire.
+func (is *InviteService) Redeem(invite models.Invite) error {
+	if !invite.SingleUse {
+		return nil
+	}
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	key := is.store.Key("invite:%s:redeemed", invite.ID)
+	_, err := is.store.Get(key)
+	if err == nil {
+		return ErrInviteUsed
+	}
+	if err != ErrKeyNotFound {
+		return err
+	}
+	// The mark only has to outlive the invite
+	return is.store.SetWithTTL(key, time.Now().Unix(), time.Until(invite.ExpiresAt)+time.Minute)
+}
+
+func (is *InviteService) sign(encoded string) string {
+	mac := hmac.New(sha256.New, is.key)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// JoinInvited seats the holder of an invite in its lobby. The lobby's
+// capacity and the tenant's session in progress don't keep them out, a ban
+// still does; redeem runs once the seat is certain, so a failed join
+// doesn't spend a single use invite. A user already in the lobby just
+// reconnects.
+func (ls *LobbyService) JoinInvited(email string, invite models.Invite, redeem func() error) (*models.Lobby, bool, error) {
+	lobby := ls.GetLobby(invite.LobbyID)
+	if lobby == nil || lobby.Internal {
+		return nil, false, ErrLobbyNotFound
+	}
+
+	if existing := ls.FindLobbyByUserEmail(email); existing != nil {
+		if existing.ID != lobby.ID {
+			log.Printf("❌ %s is seated in lobby %s, not redeeming invite to %s", email, existing.ID, lobby.ID)
+			return nil, false, ErrSessionInProgress
+		}
+		existing.AddUser(email)
+		ls.saveLobby(existing)
+		log.Printf("🔄 Invited user reconnecting to lobby: %s → %s", email, existing.ID)
+		return existing, true, nil
+	}
+
+	if lobby.IsBanned(email) {
+		log.Printf("🚫 Kicked user tried to rejoin lobby %s with an invite: %s", lobby.ID, email)
+		return nil, false, ErrKickedFromLobby
+	}
+	if err := redeem(); err != nil {
+		return nil, false, err
+	}
+
+	lobby.AddUser(email)
+	ls.saveLobby(lobby)
+	ls.worker(lobby.ID)
+	ls.audit(lobby, models.AuditInviteRedeemed, email, "", map[string]string{
+		"invite_id":  invite.ID,
+		"created_by": invite.CreatedBy,
+	})
+	log.Printf("🎟️ %s joined lobby %s with invite %s (Now: %d/%d users)", email, lobby.ID, invite.ID, lobby.GetUserCount(), lobby.MaxUsers)
+	return lobby, false, nil
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"fmt"
+	"log"
+	"time"
+)
+
+// maxReportedRTT bounds the round trips clients may report; anything longer
+// is a clock glitch or a tab that was suspended, not latency.
+const maxReportedRTT = time.Minute
+
+// MemberPresence is a lobby member's presence and, if they are connected
+// and their client pings, its latency.
+type MemberPresence struct {
+	MemberID    string          `json:"member_id"`
+	DisplayName string          `json:"display_name"`
+	Role        models.Role     `json:"role"`
+	Presence    models.Presence `json:"presence"`
+	RTTMs       int64           `json:"rtt_ms,omitempty"`
+	MeasuredAt  time.Time       `json:"measured_at,omitzero"`
+	Lagging     bool            `json:"lagging,omitempty"`
+	Channels    []string        `json:"channels,omitempty"`
+}
+
+// heartbeat answers a client's ping with a pong carrying the client's
+// timestamp and the server's receive time, and records the round trip the
+// client measured on its previous ping. It runs on the lobby's worker.
+func (ls *LobbyService) heartbeat(lobby *models.Lobby, client *models.Client, ping models.Message) {
+	pong := models.Message{
+		Type:      models.MessageTypePong,
+		LobbyID:   lobby.ID,
+		ClientTs:  ping.ClientTs,
+		Timestamp: ping.Timestamp,
+	}
+	select {
+	case client.Send <- pong:
+	default:
+	}
+
+	rtt := time.Duration(ping.RTTMs) * time.Millisecond
+	if rtt <= 0 || rtt > maxReportedRTT {
+		return
+	}
+	if client.RecordRTT(rtt, config.LagThreshold, time.Now()) {
+		ls.flagLagging(lobby, client.Email, rtt)
+	}
+}
+
+// flagLagging tells a lobby's facilitators, its owner and moderators, that
+// a member's round trip rose above config.LagThreshold.
+func (ls *LobbyService) flagLagging(lobby *models.Lobby, email string, rtt time.Duration) {
+	log.Printf("🐢 %s is lagging in lobby %s: %dms round trip", email, lobby.ID, rtt.Milliseconds())
+
+	lagMsg := ls.systemMessage(lobby, models.SystemActionLagging, email, fmt.Sprintf("%s has a slow connection (%dms round trip)", email, rtt.Milliseconds()))
+	lagMsg.Target = email
+	lagMsg.RTTMs = rtt.Milliseconds()
+	for member, client := range lobby.GetAllClients() {
+		if member == email || roleRank(lobby.GetUserRole(member)) == 0 {
+			continue
+		}
+		select {
+		case client.Send <- lagMsg:
+		default:
+			log.Printf("❌ Failed to deliver lag notice to: %s", member)
+		}
+	}
+}
+
+// LobbyPresence lists a lobby's members by member ID with their presence
+// and latency.
+func (ls *LobbyService) LobbyPresence(lobby *models.Lobby) []MemberPresence {
+	clients := lobby.GetAllClients()
+	emails := lobby.GetMemberEmails()
+	members := make([]MemberPresence, 0, len(emails))
+	for _, email := range emails {
+		member := MemberPresence{
+			MemberID:    ls.profileService.MemberID(email),
+			DisplayName: ls.profileService.Get(email).DisplayName,
+			Role:        lobby.GetUserRole(email),
+			Presence:    lobby.GetPresence(email),
+			Channels:    channelIDs(lobby, email),
+		}
+		if client, connected := clients[email]; connected {
+			rtt, measuredAt, lagging := client.Latency()
+			member.RTTMs, member.MeasuredAt, member.Lagging = rtt.Milliseconds(), measuredAt, lagging
+		}
+		members = append(members, member)
+	}
+	return members
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxReactionLength = 32
+
+// TopMessage is a leaderboard entry: a message with its tally.
+type TopMessage struct {
+	models.MessageScore
+	Username  string    `json:"username"`
+	Content   string    `json:"content"`
+	IsBot     bool      `json:"is_bot,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// applyFeedback records a react or vote frame on a chat message and
+// broadcasts the message's new tally.
+func (ls *LobbyService) applyFeedback(lobby *models.Lobby, actor string, frame models.Message) error {
+	target, err := ls.messageBySeq(lobby, frame.TargetSeq)
+	if err != nil {
+		return err
+	}
+
+	var score models.MessageScore
+	switch frame.Type {
+	case models.MessageTypeReact:
+		if frame.Reaction == "" || utf8.RuneCountInString(frame.Reaction) > maxReactionLength || strings.ContainsAny(frame.Reaction, " \t\r\n") {
+			return errors.New("reaction must be an emoji or a :shortcode:")
+		}
+		score = lobby.Scores.ToggleReaction(frame.TargetSeq, actor, frame.Reaction)
+	default:
+		if frame.Vote < -1 || frame.Vote > 1 {
+			return errors.New("vote must be 1, -1 or 0")
+		}
+		score = lobby.Scores.Vote(frame.TargetSeq, actor, frame.Vote)
+		if target.Type == models.MessageTypeIdea {
+			ls.rankIdea(lobby, frame.TargetSeq, score.Upvotes-score.Downvotes)
+		}
+	}
+	ls.saveScores(lobby)
+
+	reactionMsg := ls.systemMessage(lobby, models.SystemActionReaction, actor, "")
+	reactionMsg.TargetSeq = frame.TargetSeq
+	reactionMsg.Reaction = frame.Reaction
+	reactionMsg.Vote = frame.Vote
+	reactionMsg.Reactions = score.Reactions
+	reactionMsg.Score = score.Score
+	// Custom emoji among the reactions are sent with their images
+	pack := ls.EmojiPack(lobby)
+	for reaction := range score.Reactions {
+		for name, url := range customEmoji(reaction, pack) {
+			if reactionMsg.Emoji == nil {
+				reactionMsg.Emoji = make(map[string]string)
+			}
+			reactionMsg.Emoji[name] = url
+		}
+	}
+	ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: reactionMsg})
+	return nil
+}
+
+// TopMessages returns the n best scored messages of a lobby, ties going to
+// the more recent message.
+func (ls *LobbyService) TopMessages(lobby *models.Lobby, n int) ([]TopMessage, error) {
+	scores := lobby.Scores.Top(n)
+	top := make([]TopMessage, 0, len(scores))
+	for _, score := range scores {
+		msg, err := ls.messageBySeq(lobby, score.Seq)
+		if err != nil {
+			return nil, err
+		}
+		top = append(top, TopMessage{
+			MessageScore: score,
+			Username:     msg.Username,
+			Content:      msg.Content,
+			IsBot:        msg.IsBot,
+			Timestamp:    msg.Timestamp,
+		})
+	}
+	return top, nil
+}
+
+// messageBySeq finds a chat message in the in-memory history, falling back
+// to the store for older ones.
+func (ls *LobbyService) messageBySeq(lobby *models.Lobby, seq int64) (models.Message, error) {
+	if seq > 0 {
+		for _, msg := range lobby.GetMessageHistory() {
+			if msg.Seq == seq {
+				return msg, nil
+			}
+		}
+		if lobby.IsHistoryTruncated() {
+			stored, err := ls.store.GetMessagesSince(lobby.ID, seq-1)
+			if err != nil {
+				return models.Message{}, err
+			}
+			if len(stored) > 0 && stored[0].Seq == seq {
+				return fromRedisMessage(stored[0]), nil
+			}
+		}
+	}
+	return models.Message{}, fmt.Errorf("no message with seq %d", seq)
+}
+
+func (ls *LobbyService) saveScores(lobby *models.Lobby) {
+	scoresJSON, err := json.Marshal(lobby.Scores)
+	if err == nil {
+		err = ls.store.SetWithTTL(ls.store.Key("lobby:%s:scores", lobby.ID), scoresJSON, 0)
+	}
+	if err != nil {
+		log.Printf("⚠️ Failed to save scores of lobby %s: %v", lobby.ID, err)
+	}
+}
+
+func (ls *LobbyService) loadScores(lobby *models.Lobby) error {
+	scoresJSON, err := ls.store.Get(ls.store.Key("lobby:%s:scores", lobby.ID))
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(scoresJSON), lobby.Scores)
+}
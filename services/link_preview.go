@@ -0,0 +1,287 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+var ErrPrivateAddress = errors.New("link points to a private address")
+
+// maxPreviewText bounds the title and description kept of a page.
+const maxPreviewText = 300
+
+// LinkPreviewService fetches the OpenGraph metadata of the pages chat links
+// to and caches it for config.LinkPreviewCacheTTL, failures included, so a
+// link posted again isn't fetched again.
+type LinkPreviewService struct {
+	client *http.Client
+	mu     sync.Mutex
+	cache  map[string]cachedPreview
+}
+
+type cachedPreview struct {
+	preview   models.LinkPreview
+	err       error
+	fetchedAt time.Time
+}
+
+func NewLinkPreviewService() *LinkPreviewService {
+	dialer := &net.Dialer{Timeout: config.LinkPreviewTimeout, Control: dialControl}
+	return &LinkPreviewService{
+		client: &http.Client{
+			Timeout: config.LinkPreviewTimeout,
+			// No proxy: the address check has to see the page's own address
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 3 {
+					return errors.New("too many redirects")
+				}
+				return nil
+			},
+		},
+		cache: make(map[string]cachedPreview),
+	}
+}
+
+// dialControl refuses connections to loopback, private and link-local
+// addresses, after DNS resolution and on every redirect, unless
+// config.LinkPreviewAllowPrivate.
+func dialControl(network, address string, _ syscall.RawConn) error {
+	if config.LinkPreviewAllowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// Previews returns the previews of the first config.LinkPreviewMaxLinks
+// links in content that have an HTML page with a title.
+func (lp *LinkPreviewService) Previews(ctx context.Context, content string) []models.LinkPreview {
+	var previews []models.LinkPreview
+	for _, link := range contentLinks(content, config.LinkPreviewMaxLinks) {
+		preview, err := lp.Preview(ctx, link)
+		if err != nil {
+			log.Printf("🔗 No preview of %s: %v", link, err)
+			continue
+		}
+		previews = append(previews, preview)
+	}
+	return previews
+}
+
+// Preview returns the preview of the page at link, from the cache if it was
+// fetched lately.
+func (lp *LinkPreviewService) Preview(ctx context.Context, link string) (models.LinkPreview, error) {
+	now := time.Now()
+	lp.mu.Lock()
+	cached, exists := lp.cache[link]
+	lp.mu.Unlock()
+	if exists && now.Sub(cached.fetchedAt) < config.LinkPreviewCacheTTL {
+		return cached.preview, cached.err
+	}
+
+	preview, err := lp.fetch(ctx, link)
+	if ctx.Err() != nil {
+		// Not the page's fault; try again next time
+		return preview, err
+	}
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	if len(lp.cache) >= config.LinkPreviewCacheSize {
+		for cachedLink, entry := range lp.cache {
+			if now.Sub(entry.fetchedAt) >= config.LinkPreviewCacheTTL || len(lp.cache) >= config.LinkPreviewCacheSize {
+				delete(lp.cache, cachedLink)
+			}
+		}
+	}
+	lp.cache[link] = cachedPreview{preview: preview, err: err, fetchedAt: now}
+	return preview, err
+}
+
+func (lp *LinkPreviewService) fetch(ctx context.Context, link string) (models.LinkPreview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return models.LinkPreview{}, err
+	}
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("User-Agent", config.ServiceName+" link preview")
+
+	resp, err := lp.client.Do(req)
+	if err != nil {
+		return models.LinkPreview{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return models.LinkPreview{}, fmt.Errorf("page answered %s", resp.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" {
+		return models.LinkPreview{}, fmt.Errorf("page is %q, not HTML", resp.Header.Get("Content-Type"))
+	}
+
+	preview := parsePreview(io.LimitReader(resp.Body, config.LinkPreviewMaxBytes), resp.Request.URL)
+	if preview.Title == "" {
+		return models.LinkPreview{}, errors.New("page has no title")
+	}
+	preview.URL = link
+	return preview, nil
+}
+
+// parsePreview reads the OpenGraph properties of a page's head, falling
+// back on its title and description. Image URLs are resolved against base
+// and kept only if they are http(s).
+func parsePreview(body io.Reader, base *url.URL) models.LinkPreview {
+	var preview models.LinkPreview
+	var title, description string
+	tokenizer := html.NewTokenizer(body)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return finishPreview(preview, title, description, base)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "body":
+				return finishPreview(preview, title, description, base)
+			case "title":
+				if tokenizer.Next() == html.TextToken {
+					title = string(tokenizer.Text())
+				}
+			case "meta":
+				key, value := metaProperty(token)
+				switch key {
+				case "og:title":
+					preview.Title = value
+				case "og:description":
+					preview.Description = value
+				case "og:image":
+					preview.Image = value
+				case "og:site_name":
+					preview.SiteName = value
+				case "description":
+					description = value
+				}
+			}
+		case html.EndTagToken:
+			if tokenizer.Token().Data == "head" {
+				return finishPreview(preview, title, description, base)
+			}
+		}
+	}
+}
+
+func metaProperty(token html.Token) (string, string) {
+	var key, value string
+	for _, attr := range token.Attr {
+		switch strings.ToLower(attr.Key) {
+		case "property", "name":
+			key = strings.ToLower(attr.Val)
+		case "content":
+			value = attr.Val
+		}
+	}
+	return key, value
+}
+
+func finishPreview(preview models.LinkPreview, title, description string, base *url.URL) models.LinkPreview {
+	if preview.Title == "" {
+		preview.Title = title
+	}
+	if preview.Description == "" {
+		preview.Description = description
+	}
+	preview.Title = previewText(preview.Title)
+	preview.Description = previewText(preview.Description)
+	preview.SiteName = previewText(preview.SiteName)
+
+	if preview.Image != "" {
+		image, err := base.Parse(preview.Image)
+		if err != nil || (image.Scheme != "http" && image.Scheme != "https") {
+			preview.Image = ""
+		} else {
+			preview.Image = image.String()
+		}
+	}
+	return preview
+}
+
+func previewText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len([]rune(text)) > maxPreviewText {
+		text = string([]rune(text)[:maxPreviewText]) + "…"
+	}
+	return text
+}
+
+// contentLinks returns the first limit distinct http(s) links of content,
+// without the punctuation that usually ends a sentence after a link.
+func contentLinks(content string, limit int) []string {
+	var links []string
+	seen := make(map[string]bool)
+	for _, match := range linkPattern.FindAllString(content, -1) {
+		link := strings.TrimRight(match, ".,;:!?)]}>'\"")
+		if strings.HasPrefix(strings.ToLower(link), "www.") {
+			link = "https://" + link
+		}
+		if parsed, err := url.Parse(link); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			continue
+		}
+		if seen[link] {
+			continue
+		}
+		seen[link] = true
+		links = append(links, link)
+		if len(links) == limit {
+			break
+		}
+	}
+	return links
+}
+
+// enrichLinks fetches the previews of a delivered chat message's links in
+// the background, attaches them to the lobby's history and broadcasts them
+// in a message_enriched frame.
+func (ls *LobbyService) enrichLinks(lobby *models.Lobby, msg models.Message) {
+	if !config.LinkPreviews || msg.Format == models.ContentFormatCode || !linkPattern.MatchString(msg.Content) {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), config.LinkPreviewMaxLinks*config.LinkPreviewTimeout)
+		defer cancel()
+		previews := ls.linkPreviews.Previews(ctx, msg.Content)
+		if len(previews) == 0 {
+			return
+		}
+		log.Printf("🔗 Fetched %d link previews for %s in lobby %s", len(previews), msg.MessageID, lobby.ID)
+		lobby.SetPreviews(msg.Seq, previews)
+
+		enriched := ls.systemMessage(lobby, models.SystemActionMessageEnriched, msg.Username, "")
+		enriched.MessageID = msg.MessageID
+		enriched.TargetSeq = msg.Seq
+		enriched.Previews = previews
+		if err := ls.Broadcast(context.Background(), BroadcastMessage{LobbyID: lobby.ID, Message: enriched}); err != nil {
+			log.Printf("⚠️ Failed to broadcast link previews for %s: %v", msg.MessageID, err)
+		}
+	}()
+}
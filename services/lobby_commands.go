@@ -0,0 +1,335 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var ErrKickedFromLobby = errors.New("you were removed from this lobby")
+
+// LobbyCommand is a lobby management frame from a client whose lobby role
+// the policy already allowed to send it. Commands from an API rather than a
+// connection have no Client and name their LobbyID and Actor instead.
+type LobbyCommand struct {
+	Client  *models.Client
+	LobbyID string
+	Actor   string
+	Frame   models.Message
+}
+
+// UserRole returns the lobby role of a client's user, or "" if the lobby or
+// user is gone.
+func (ls *LobbyService) UserRole(client *models.Client) models.Role {
+	lobby := ls.GetLobby(client.LobbyID)
+	if lobby == nil {
+		return ""
+	}
+	return lobby.GetUserRole(client.Email)
+}
+
+// roleRank orders lobby roles; kicks and role changes need a higher rank
+// than the target's.
+func roleRank(role models.Role) int {
+	switch role {
+	case models.RoleOwner:
+		return 2
+	case models.RoleModerator:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (ls *LobbyService) handleCommand(cmd LobbyCommand) {
+	lobbyID, actor := cmd.LobbyID, cmd.Actor
+	if cmd.Client != nil {
+		lobbyID, actor = cmd.Client.LobbyID, cmd.Client.Email
+	}
+	// Ignore commands from connections that were dropped or replaced
+	lobby := ls.GetLobby(lobbyID)
+	if lobby == nil || (cmd.Client != nil && lobby.GetAllClients()[actor] != cmd.Client) {
+		return
+	}
+
+	// Heartbeats are too frequent to log, and there's nothing to save
+	if cmd.Frame.Type == models.MessageTypePing {
+		ls.heartbeat(lobby, cmd.Client, cmd.Frame)
+		return
+	}
+	// So are history acks, which only pace the connection's replay
+	if cmd.Frame.Type == models.MessageTypeHistoryAck {
+		// An ack naming no batch acks nothing
+		if cmd.Frame.Replay == nil {
+			return
+		}
+		if err := ls.ackReplay(lobby, cmd.Client, cmd.Frame.Replay.Batch); err != nil {
+			ls.replyError(cmd.Client, ErrorCodeOf(err), err.Error())
+		}
+		return
+	}
+	// And delivery acks, which only free the frames a reliable lobby kept
+	if cmd.Frame.Type == models.MessageTypeDeliveryAck {
+		lobby.AckFrames(cmd.Client.Email, cmd.Frame.Seq)
+		return
+	}
+
+	// Clients name other members by member ID
+	cmd.Frame.Target = ls.memberEmail(lobby, cmd.Frame.Target)
+	log.Printf("🛠️ Lobby command %q from %s in lobby %s", cmd.Frame.Type, actor, lobby.ID)
+
+	var err error
+	switch cmd.Frame.Type {
+	case models.MessageTypeEndLobby:
+		ls.endLobby(lobby, actor)
+		return
+	case models.MessageTypeKick:
+		err = ls.kickUser(lobby, actor, cmd.Frame.Target)
+	case models.MessageTypePin, models.MessageTypeUnpin:
+		err = ls.pinMessage(lobby, actor, cmd.Frame.PinnedSeq, cmd.Frame.Type == models.MessageTypePin)
+	case models.MessageTypeSetMaxUsers:
+		err = ls.setMaxUsers(lobby, actor, cmd.Frame.MaxUsers)
+	case models.MessageTypeSetRole:
+		err = ls.setUserRole(lobby, actor, cmd.Frame.Target, cmd.Frame.Role)
+	case models.MessageTypeSetSystemEvents:
+		err = ls.setSystemEvents(lobby, actor, cmd.Frame.SystemEvents)
+	case models.MessageTypeSetGuestAccess:
+		err = ls.setGuestAccess(lobby, actor, cmd.Frame.GuestFriendly)
+	case models.MessageTypeSetBudget:
+		err = ls.setBudget(lobby, actor, cmd.Frame.BudgetMinutes, cmd.Frame.HourlyRate)
+	case models.MessageTypeSetSlowMode:
+		err = ls.setSlowMode(lobby, actor, cmd.Frame.SlowModeSeconds)
+	case models.MessageTypeReact, models.MessageTypeVote:
+		err = ls.applyFeedback(lobby, actor, cmd.Frame)
+	case models.MessageTypeChannelCreate:
+		err = ls.createChannel(lobby, actor, cmd.Frame)
+	case models.MessageTypeChannelLeave:
+		err = ls.leaveChannel(lobby, actor, cmd.Frame.ChannelID)
+	case models.MessageTypeVisibility:
+		// Presence isn't persisted, so there is nothing to save
+		if err = ls.setVisibility(lobby, actor, cmd.Frame.Visibility); err == nil {
+			return
+		}
+	case models.MessageTypeMessageRead:
+		// The pointer is kept apart from the lobby record
+		if err = ls.markRead(lobby, actor, cmd.Frame.MessageID); err == nil {
+			return
+		}
+	default:
+		err = fmt.Errorf("unknown command %q", cmd.Frame.Type)
+	}
+
+	if err != nil {
+		log.Printf("⚠️ Lobby command %q from %s rejected: %v", cmd.Frame.Type, actor, err)
+		if cmd.Client != nil {
+			ls.replyError(cmd.Client, ErrorCodeOf(err), err.Error())
+		}
+		return
+	}
+	ls.auditCommand(lobby, actor, cmd.Frame)
+	ls.saveLobby(lobby)
+}
+
+// endLobby announces the end of the session, disconnects everyone and
+// archives the lobby. Its messages stay in the store.
+func (ls *LobbyService) endLobby(lobby *models.Lobby, actor string) {
+	budget, _ := lobby.TickBudget(time.Now(), lobby.GetConnectedClientCount())
+	endedMsg := ls.systemMessage(lobby, models.SystemActionLobbyEnded, actor, fmt.Sprintf("%s ended the lobby%s", actor, sessionStats(budget)))
+	endedMsg.Budget = budget
+	ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: endedMsg})
+
+	for _, client := range lobby.GetAllClients() {
+		if lobby.DetachClient(client) {
+			client.CloseWith(models.CloseLobbyEnded, "The lobby ended")
+		}
+	}
+
+	ls.audit(lobby, models.AuditLobbyEnded, actor, "", nil)
+	ls.archiveLobby(lobby)
+	ls.webhookService.Emit(models.WebhookEventLobbyEnded, lobby, map[string]interface{}{
+		"members":  lobby.GetMemberEmails(),
+		"ended_by": actor,
+		"budget":   budget,
+	})
+	log.Printf("🏁 Lobby %s ended by %s", lobby.ID, actor)
+	// The users waiting start the next session
+	ls.admitWaiting(lobby.TenantID)
+}
+
+func (ls *LobbyService) kickUser(lobby *models.Lobby, actor, target string) error {
+	if target == actor {
+		return errors.New("you cannot kick yourself")
+	}
+	if !lobby.IsUserInLobby(target) {
+		return fmt.Errorf("%s is not in this lobby", target)
+	}
+	if roleRank(lobby.GetUserRole(actor)) <= roleRank(lobby.GetUserRole(target)) {
+		return fmt.Errorf("you cannot kick %s", target)
+	}
+	ls.removeMember(lobby, actor, target)
+	return nil
+}
+
+// removeMember kicks a member out of the lobby and bans them, on behalf of
+// actor.
+func (ls *LobbyService) removeMember(lobby *models.Lobby, actor, target string) {
+	if client, connected := lobby.GetAllClients()[target]; connected && lobby.DetachClient(client) {
+		select {
+		case client.Send <- ls.systemMessage(lobby, models.SystemActionKicked, target, fmt.Sprintf("You were removed from the lobby by %s", actor)):
+		default:
+		}
+		client.CloseWith(models.ErrorKicked.CloseCode(), ErrKickedFromLobby.Error())
+	}
+	lobby.RemoveUser(target)
+	lobby.Ban(target)
+	ls.dropInbox(lobby.ID, target)
+	ls.spamDetector.Forget(lobby.ID, target)
+
+	kickMsg := ls.systemMessage(lobby, models.SystemActionKicked, actor, fmt.Sprintf("%s removed %s from the lobby", actor, target))
+	kickMsg.Target = target
+	ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: kickMsg})
+	log.Printf("👢 %s kicked %s from lobby %s", actor, target, lobby.ID)
+	ls.admitWaiting(lobby.TenantID)
+}
+
+func (ls *LobbyService) pinMessage(lobby *models.Lobby, actor string, seq int64, pinned bool) error {
+	if seq <= 0 || seq > lobby.GetLastSeq() {
+		return fmt.Errorf("no message with seq %d", seq)
+	}
+	if !lobby.SetPinned(seq, pinned) {
+		return nil
+	}
+
+	action, verb := models.SystemActionPinned, "pinned"
+	if !pinned {
+		action, verb = models.SystemActionUnpinned, "unpinned"
+	}
+	pinMsg := ls.systemMessage(lobby, action, actor, fmt.Sprintf("%s %s a message", actor, verb))
+	pinMsg.PinnedSeq = seq
+	pinMsg.Pinned = lobby.GetPinned()
+	ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: pinMsg})
+	return nil
+}
+
+func (ls *LobbyService) setMaxUsers(lobby *models.Lobby, actor string, maxUsers int) error {
+	if maxUsers < 1 || maxUsers > config.MaxUsersLimit {
+		return fmt.Errorf("max users must be between 1 and %d", config.MaxUsersLimit)
+	}
+	lobby.SetMaxUsers(maxUsers)
+	logOverCapacity(lobby)
+
+	ls.handleBroadcast(BroadcastMessage{
+		LobbyID: lobby.ID,
+		Message: ls.systemMessage(lobby, models.SystemActionMaxUsers, actor, fmt.Sprintf("%s set the lobby size to %d", actor, maxUsers)),
+	})
+	ls.admitWaiting(lobby.TenantID)
+	return nil
+}
+
+func (ls *LobbyService) setUserRole(lobby *models.Lobby, actor, target string, role models.Role) error {
+	if role != models.RoleModerator && role != models.RoleParticipant {
+		return fmt.Errorf("role must be %s or %s", models.RoleModerator, models.RoleParticipant)
+	}
+	if target == actor || !lobby.IsUserInLobby(target) {
+		return fmt.Errorf("cannot change the role of %s", target)
+	}
+	if roleRank(lobby.GetUserRole(actor)) <= roleRank(lobby.GetUserRole(target)) {
+		return fmt.Errorf("cannot change the role of %s", target)
+	}
+	lobby.SetUserRole(target, role)
+
+	roleMsg := ls.systemMessage(lobby, models.SystemActionRoleChange, actor, fmt.Sprintf("%s is now a %s", target, role))
+	roleMsg.Target = target
+	roleMsg.Role = role
+	ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: roleMsg})
+	log.Printf("🎖️ %s made %s a %s in lobby %s", actor, target, role, lobby.ID)
+	return nil
+}
+
+func (ls *LobbyService) setSystemEvents(lobby *models.Lobby, actor string, systemEvents models.SystemEvents) error {
+	if systemEvents != models.SystemEventsAll && systemEvents != models.SystemEventsDigest {
+		return fmt.Errorf("system events must be %s or %s", models.SystemEventsAll, models.SystemEventsDigest)
+	}
+	if lobby.GetSystemEvents() == systemEvents {
+		return nil
+	}
+	// Report what the digest had collected before joins and leaves go out
+	// one by one again
+	if systemEvents == models.SystemEventsAll {
+		ls.sendRosterDigest(lobby)
+	}
+	lobby.SetSystemEvents(systemEvents)
+
+	eventsMsg := ls.systemMessage(lobby, models.SystemActionSystemEvents, actor, fmt.Sprintf("%s set join and leave announcements to %s", actor, systemEvents))
+	eventsMsg.SystemEvents = systemEvents
+	ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: eventsMsg})
+	return nil
+}
+
+// setGuestAccess opens or closes the lobby to new guests; guests already in
+// it stay.
+func (ls *LobbyService) setGuestAccess(lobby *models.Lobby, actor string, guestFriendly bool) error {
+	if lobby.IsGuestFriendly() == guestFriendly {
+		return nil
+	}
+	lobby.SetGuestFriendly(guestFriendly)
+
+	verb := "closed the lobby to guests"
+	if guestFriendly {
+		verb = "opened the lobby to guests"
+	}
+	accessMsg := ls.systemMessage(lobby, models.SystemActionGuestAccess, actor, fmt.Sprintf("%s %s", actor, verb))
+	accessMsg.GuestFriendly = guestFriendly
+	ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: accessMsg})
+	return nil
+}
+
+// sendRosterDigest broadcasts the joins and leaves collected since the last
+// digest, if there were any.
+func (ls *LobbyService) sendRosterDigest(lobby *models.Lobby) {
+	joined, left := lobby.TakeRosterChanges()
+	if len(joined) == 0 && len(left) == 0 {
+		return
+	}
+
+	digestMsg := ls.systemMessage(lobby, models.SystemActionRosterDigest, "", fmt.Sprintf("%d joined, %d left", len(joined), len(left)))
+	digestMsg.Joined = joined
+	digestMsg.Left = left
+	ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: digestMsg})
+}
+
+// systemMessage builds a system action carrying the lobby's current users
+// and roles.
+func (ls *LobbyService) systemMessage(lobby *models.Lobby, action models.SystemActionType, username, content string) models.Message {
+	return models.Message{
+		Type:         models.MessageTypeSystemAction,
+		SystemAction: &action,
+		Username:     username,
+		Content:      content,
+		LobbyID:      lobby.ID,
+		UserCount:    lobby.GetActiveUserCount(),
+		MaxUsers:     lobby.MaxUsers,
+		UserList:     lobby.GetActiveUserList(),
+		Roles:        lobby.GetRoles(),
+		Guests:       lobby.GetGuests(),
+		Timestamp:    time.Now(),
+	}
+}
+
+func (ls *LobbyService) replyError(client *models.Client, code models.ErrorCode, content string) {
+	errorAction := models.SystemActionError
+	select {
+	case client.Send <- models.Message{
+		Type:         models.MessageTypeSystemAction,
+		SystemAction: &errorAction,
+		Code:         code,
+		Content:      content,
+		LobbyID:      client.LobbyID,
+		Timestamp:    time.Now(),
+	}:
+	default:
+	}
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"fmt"
+	"log"
+	"time"
+)
+
+// heldByQuota applies the lobby's throughput quota to a chat message. The
+// message that runs the quota dry starts a cooldown and tells the lobby;
+// until it ends, chat from members other than owners, moderators and bots
+// is dropped before it reaches moderation, the store or the fan-out, and
+// its sender is told how long to wait.
+func (ls *LobbyService) heldByQuota(lobby *models.Lobby, msg models.Message) bool {
+	if config.LobbyMessageRate <= 0 {
+		return false
+	}
+	exempt := msg.IsBot || roleRank(lobby.GetUserRole(msg.Username)) > 0
+	wait, started := lobby.TakeChatQuota(time.Now(), config.LobbyMessageRate, max(config.LobbyMessageBurst, 1), config.LobbyCooldown, exempt)
+	if wait <= 0 {
+		return false
+	}
+
+	if started {
+		log.Printf("🧯 Lobby %s went over %d messages a second, cooling down for %s", lobby.ID, config.LobbyMessageRate, wait)
+		ls.audit(lobby, models.AuditLobbyCooldown, models.AuditActorSystem, "", map[string]string{"duration": wait.String()})
+		notice := ls.systemMessage(lobby, models.SystemActionCooldown, models.AuditActorSystem, fmt.Sprintf("The lobby is busy: only moderators can chat for the next %s", wait.Round(time.Second)))
+		notice.RetryAfterMs = wait.Milliseconds()
+		ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: notice})
+	}
+
+	client, connected := lobby.GetAllClients()[msg.Username]
+	if !connected {
+		return true
+	}
+	cooldownAction := models.SystemActionCooldown
+	select {
+	case client.Send <- models.Message{
+		Type:         models.MessageTypeSystemAction,
+		SystemAction: &cooldownAction,
+		Code:         models.ErrorRateLimited,
+		Content:      fmt.Sprintf("The lobby is cooling down, you can send another message in %s", max(wait.Round(time.Second), time.Second)),
+		LobbyID:      lobby.ID,
+		RetryAfterMs: wait.Milliseconds(),
+		Timestamp:    time.Now(),
+	}:
+	default:
+	}
+	return true
+}
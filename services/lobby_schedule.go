@@ -0,0 +1,205 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrStartInPast     = errors.New("starts_at must be in the future")
+	ErrStartTooFar     = fmt.Errorf("starts_at must be within %s", config.MaxScheduleAhead)
+	ErrNoParticipants  = errors.New("participants must list at least one email")
+	ErrTooManyInvitees = errors.New("participants must fit in the lobby")
+)
+
+// NotStartedError rejects a login to a scheduled session before it opens.
+type NotStartedError struct {
+	Lobby models.ScheduledLobby
+}
+
+func (e *NotStartedError) Error() string {
+	return fmt.Sprintf("this session starts at %s", e.Lobby.StartsAt.Format(time.RFC3339))
+}
+
+// ScheduleLobby books a session of the tenant for startsAt. The scheduler
+// opens it then and tells the participants.
+func (ls *LobbyService) ScheduleLobby(tenantID string, startsAt time.Time, participants []string) (models.ScheduledLobby, error) {
+	now := time.Now()
+	switch {
+	case !startsAt.After(now):
+		return models.ScheduledLobby{}, ErrStartInPast
+	case startsAt.After(now.Add(config.MaxScheduleAhead)):
+		return models.ScheduledLobby{}, ErrStartTooFar
+	}
+
+	var invitees []string
+	for _, email := range participants {
+		email = strings.TrimSpace(email)
+		if email != "" && !slices.Contains(invitees, email) {
+			invitees = append(invitees, email)
+		}
+	}
+	switch {
+	case len(invitees) == 0:
+		return models.ScheduledLobby{}, ErrNoParticipants
+	case len(invitees) > ls.maxUsers:
+		return models.ScheduledLobby{}, ErrTooManyInvitees
+	}
+
+	ls.mu.Lock()
+	scheduled := models.ScheduledLobby{
+		ID:           ls.newLobbyIDLocked(),
+		TenantID:     tenantID,
+		StartsAt:     startsAt.UTC(),
+		Participants: invitees,
+		CreatedAt:    now,
+	}
+	ls.scheduled[scheduled.ID] = scheduled
+	err := ls.saveScheduleLocked()
+	ls.mu.Unlock()
+	if err != nil {
+		return models.ScheduledLobby{}, err
+	}
+
+	log.Printf("📅 Scheduled lobby %s for %s (tenant: %s, %d participants)", scheduled.ID, scheduled.StartsAt.Format(time.RFC3339), tenantID, len(invitees))
+	return scheduled, nil
+}
+
+// ScheduledLobbies lists the tenant's sessions that haven't opened yet,
+// soonest first.
+func (ls *LobbyService) ScheduledLobbies(tenantID string) []models.ScheduledLobby {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	list := []models.ScheduledLobby{}
+	for _, scheduled := range ls.scheduled {
+		if scheduled.TenantID == tenantID {
+			list = append(list, scheduled)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartsAt.Before(list[j].StartsAt) })
+	return list
+}
+
+// scheduledFor returns the unopened session of the tenant that invites
+// email, if any.
+func (ls *LobbyService) scheduledFor(email, tenantID string) (models.ScheduledLobby, bool) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	for _, scheduled := range ls.scheduled {
+		if scheduled.TenantID == tenantID && slices.Contains(scheduled.Participants, email) {
+			return scheduled, true
+		}
+	}
+	return models.ScheduledLobby{}, false
+}
+
+// RunScheduler opens each booked session once it is due.
+func (ls *LobbyService) RunScheduler() {
+	ticker := time.NewTicker(config.ScheduleCheckInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		ls.mu.RLock()
+		var due []models.ScheduledLobby
+		for _, scheduled := range ls.scheduled {
+			if !scheduled.StartsAt.After(now) {
+				due = append(due, scheduled)
+			}
+		}
+		ls.mu.RUnlock()
+
+		for _, scheduled := range due {
+			ls.openScheduled(scheduled)
+		}
+	}
+}
+
+// openScheduled opens a due session as the tenant's lobby, archiving its
+// idle ones like a follow-up does. While another session has active users
+// it waits for the next check.
+func (ls *LobbyService) openScheduled(scheduled models.ScheduledLobby) {
+	ls.mu.Lock()
+	var idle []*models.Lobby
+	for _, lobby := range ls.lobbies {
+		if lobby.Internal || lobby.Topic != "" || lobby.TenantID != scheduled.TenantID {
+			continue
+		}
+		if lobby.GetActiveUserCount() > 0 {
+			ls.mu.Unlock()
+			log.Printf("⏳ Scheduled lobby %s is due but lobby %s is in session", scheduled.ID, lobby.ID)
+			return
+		}
+		idle = append(idle, lobby)
+	}
+
+	lobby := models.NewLobby(scheduled.ID, ls.maxUsers, ls.historyLimit)
+	lobby.TenantID = scheduled.TenantID
+	ls.lobbies[scheduled.ID] = lobby
+	delete(ls.scheduled, scheduled.ID)
+	if err := ls.saveScheduleLocked(); err != nil {
+		log.Printf("⚠️ Failed to save scheduled lobbies: %v", err)
+	}
+	ls.mu.Unlock()
+
+	for _, idleLobby := range idle {
+		ls.archiveLobby(idleLobby)
+	}
+	ls.saveLobby(lobby)
+	ls.audit(lobby, models.AuditLobbyCreated, models.AuditActorSystem, "", map[string][]string{"participants": scheduled.Participants})
+	ls.webhookService.Emit(models.WebhookEventLobbyOpened, lobby, map[string]interface{}{
+		"starts_at":    scheduled.StartsAt,
+		"participants": scheduled.Participants,
+	})
+	ls.mailerService.ScheduledOpen(ls.productName(scheduled.TenantID), scheduled.ID, scheduled.Participants)
+	log.Printf("📅 Opened scheduled lobby %s (tenant: %s)", scheduled.ID, scheduled.TenantID)
+}
+
+func (ls *LobbyService) loadSchedule() error {
+	raw, err := ls.store.Get(ls.scheduleKey())
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []models.ScheduledLobby
+	if err := json.Unmarshal([]byte(raw), &list); err != nil {
+		return err
+	}
+
+	ls.mu.Lock()
+	for _, scheduled := range list {
+		ls.scheduled[scheduled.ID] = scheduled
+	}
+	ls.mu.Unlock()
+	log.Printf("📅 Loaded %d scheduled lobbies", len(list))
+	return nil
+}
+
+// saveScheduleLocked writes the booked sessions to the store. The caller
+// holds ls.mu.
+func (ls *LobbyService) saveScheduleLocked() error {
+	list := make([]models.ScheduledLobby, 0, len(ls.scheduled))
+	for _, scheduled := range ls.scheduled {
+		list = append(list, scheduled)
+	}
+	listJSON, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return ls.store.SetWithTTL(ls.scheduleKey(), listJSON, 0)
+}
+
+func (ls *LobbyService) scheduleKey() string {
+	return ls.store.Key("scheduled_lobbies")
+}
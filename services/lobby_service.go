@@ -3,42 +3,104 @@ package services
 import (
 	"chat-integrated/config"
 	"chat-integrated/models"
+	"chat-integrated/telemetry"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrSessionInProgress = errors.New("a chat session is currently in progress")
+	ErrLobbyFull         = errors.New("lobby is full")
+	ErrTooManyImported   = fmt.Errorf("a transcript may hold at most %d messages", config.MaxImportMessages)
 )
 
 type LobbyService struct {
-	lobbies      map[string]*models.Lobby
-	mu           sync.RWMutex
-	Broadcast    chan BroadcastMessage
-	Register     chan *models.Client
-	Unregister   chan *models.Client
-	redisService *RedisService
+	lobbies           map[string]*models.Lobby
+	scheduled         map[string]models.ScheduledLobby
+	mu                sync.RWMutex
+	workers           map[string]*lobbyWorker
+	store             Store
+	brandingService   *BrandingService
+	webhookService    *WebhookService
+	moderationService *ModerationService
+	profileService    *ProfileService
+	emojiService      *EmojiService
+	mailerService     *MailerService
+	auditService      *AuditService
+	archiveService    *ArchiveService
+	spamDetector      *SpamDetector
+	rates             *MessageRates
+	linkPreviews      *LinkPreviewService
+	queue             *WaitingQueue
+	connections       *ConnectionLimits
+	ids               IDGenerator
+	maxUsers          int
+	historyLimit      int
 }
 
 type BroadcastMessage struct {
 	LobbyID string
 	Message models.Message
+	// Trace is the span the broadcast was sent from, which Broadcast takes
+	// from its context; the lobby worker continues the trace
+	Trace trace.SpanContext
 }
 
-func NewLobbyService(redisService *RedisService) *LobbyService {
+func NewLobbyService(store Store, brandingService *BrandingService, webhookService *WebhookService, moderationService *ModerationService, profileService *ProfileService, emojiService *EmojiService, mailerService *MailerService, auditService *AuditService, archiveService *ArchiveService, ids IDGenerator, maxUsers, historyLimit int) *LobbyService {
 	return &LobbyService{
-		lobbies:      make(map[string]*models.Lobby),
-		Broadcast:    make(chan BroadcastMessage),
-		Register:     make(chan *models.Client),
-		Unregister:   make(chan *models.Client),
-		redisService: redisService,
+		lobbies:           make(map[string]*models.Lobby),
+		scheduled:         make(map[string]models.ScheduledLobby),
+		workers:           make(map[string]*lobbyWorker),
+		store:             store,
+		brandingService:   brandingService,
+		webhookService:    webhookService,
+		moderationService: moderationService,
+		profileService:    profileService,
+		emojiService:      emojiService,
+		mailerService:     mailerService,
+		auditService:      auditService,
+		archiveService:    archiveService,
+		spamDetector:      NewSpamDetector(),
+		rates:             NewMessageRates(),
+		linkPreviews:      NewLinkPreviewService(),
+		queue:             NewWaitingQueue(),
+		connections:       NewConnectionLimits(config.MaxConnectionsPerIP),
+		ids:               ids,
+		maxUsers:          maxUsers,
+		historyLimit:      historyLimit,
 	}
 }
 
-func (ls *LobbyService) GetOrCreateLobby() *models.Lobby {
+// MaxUsers is the capacity of lobbies created by this service.
+func (ls *LobbyService) MaxUsers() int {
+	return ls.maxUsers
+}
+
+// GetOrCreateLobby returns the lobby new users of a tenant should join. Each
+// tenant runs its own single session, besides the topic lobbies of
+// matchmaking.
+func (ls *LobbyService) GetOrCreateLobby(tenantID string) *models.Lobby {
 	ls.mu.Lock()
 	defer ls.mu.Unlock()
 
 	// Find an available lobby that's not full
 	for _, lobby := range ls.lobbies {
+		if lobby.Internal || lobby.Topic != "" || lobby.TenantID != tenantID {
+			continue
+		}
 		if lobby.CanAcceptNewUsers() {
 			log.Printf("📍 Using existing lobby: %s (Users: %d/%d)", lobby.ID, lobby.GetUserCount(), lobby.MaxUsers)
 			return lobby
@@ -48,26 +110,205 @@ func (ls *LobbyService) GetOrCreateLobby() *models.Lobby {
 	// Check if there are any lobbies that are full (active session)
 	// If yes, don't create new lobby - return nil
 	for _, lobby := range ls.lobbies {
-		if lobby.IsFull() {
+		if !lobby.Internal && lobby.Topic == "" && lobby.TenantID == tenantID && lobby.IsFull() {
 			log.Printf("❌ Active session exists. Cannot create new lobby until current session ends.")
 			return nil
 		}
 	}
 
 	// Create new lobby only if NO lobbies exist
-	lobbyID := fmt.Sprintf("lobby-%d", time.Now().Unix())
-	lobby := models.NewLobby(lobbyID, config.MaxUsersPerLobby)
+	lobbyID := ls.newLobbyIDLocked()
+	lobby := models.NewLobby(lobbyID, ls.maxUsers, ls.historyLimit)
+	lobby.TenantID = tenantID
+	ls.lobbies[lobbyID] = lobby
+	ls.saveLobby(lobby)
+	ls.webhookService.Emit(models.WebhookEventLobbyCreated, lobby, nil)
+	ls.audit(lobby, models.AuditLobbyCreated, models.AuditActorSystem, "", nil)
+	log.Printf("🆕 Created new lobby: %s (tenant: %s)", lobbyID, tenantID)
+	return lobby
+}
+
+// GetOrCreateInternalLobby returns the internal lobby with the given ID,
+// creating it on first use. Internal lobbies are hidden from matchmaking.
+func (ls *LobbyService) GetOrCreateInternalLobby(lobbyID string) *models.Lobby {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if lobby, exists := ls.lobbies[lobbyID]; exists {
+		return lobby
+	}
+
+	lobby := models.NewLobby(lobbyID, ls.maxUsers, ls.historyLimit)
+	lobby.TenantID = config.DefaultTenantID
+	lobby.Internal = true
 	ls.lobbies[lobbyID] = lobby
-	log.Printf("🆕 Created new lobby: %s", lobbyID)
+	log.Printf("🆕 Created internal lobby: %s", lobbyID)
 	return lobby
 }
 
+// JoinLobby places an email in its existing lobby (reconnection) or in the
+// lobby new users of the tenant are currently assigned to. The returned bool
+// reports whether the user was reconnecting.
+func (ls *LobbyService) JoinLobby(email, tenantID string) (*models.Lobby, bool, error) {
+	// Seats that freed up go to the users waiting in line, which may
+	// include this one
+	ls.admitWaiting(tenantID)
+
+	// FIRST: Check if user already exists in any lobby (for reconnection)
+	existingLobby := ls.FindLobbyByUserEmail(email)
+
+	if existingLobby != nil {
+		// User is reconnecting to their existing lobby
+		existingLobby.AddUser(email) // This will reactivate the user
+		ls.saveLobby(existingLobby)
+		log.Printf("🔄 User reconnecting to existing lobby: %s → %s", email, existingLobby.ID)
+		return existingLobby, true, nil
+	}
+
+	// Invitees of a scheduled session wait for it to open
+	if scheduled, invited := ls.scheduledFor(email, tenantID); invited {
+		log.Printf("📅 %s logged in before scheduled lobby %s opens", email, scheduled.ID)
+		return nil, false, &NotStartedError{Lobby: scheduled}
+	}
+
+	// Nobody gets ahead of the users already waiting
+	if ls.queue.Waiting(tenantID) > 0 {
+		log.Printf("❌ Users are waiting for a seat, queueing: %s", email)
+		return nil, false, ErrLobbyFull
+	}
+
+	lobby, err := ls.seatUser(email, tenantID)
+	return lobby, false, err
+}
+
+// seatUser adds a user joining for the first time to the tenant's lobby.
+func (ls *LobbyService) seatUser(email, tenantID string) (*models.Lobby, error) {
+	lobby := ls.GetOrCreateLobby(tenantID)
+
+	// If lobby is nil, it means there's an active session and we can't create new lobby
+	if lobby == nil {
+		log.Printf("❌ No available lobby for: %s (Active session in progress)", email)
+		return nil, ErrSessionInProgress
+	}
+	if err := ls.seatIn(email, lobby); err != nil {
+		return nil, err
+	}
+	return lobby, nil
+}
+
+// seatIn adds a user joining for the first time to lobby.
+func (ls *LobbyService) seatIn(email string, lobby *models.Lobby) error {
+	if lobby.IsBanned(email) {
+		log.Printf("🚫 Kicked user tried to rejoin lobby %s: %s", lobby.ID, email)
+		return ErrKickedFromLobby
+	}
+
+	log.Printf("📦 Got lobby for new user: %s (Current users: %d/%d)", lobby.ID, lobby.GetUserCount(), lobby.MaxUsers)
+
+	// Check if lobby can accept new users
+	if !lobby.CanAcceptNewUsers() {
+		log.Printf("❌ Lobby full, rejecting: %s", email)
+		return ErrLobbyFull
+	}
+
+	// Add user to lobby
+	lobby.AddUser(email)
+	ls.saveLobby(lobby)
+	// The worker times the seat out if the user never connects
+	ls.worker(lobby.ID)
+	log.Printf("✅ New user added to lobby: %s (Now: %d/%d users)", email, lobby.GetUserCount(), lobby.MaxUsers)
+	if lobby.GetUserCount() >= lobby.MaxUsers && lobby.MarkStartNoticeSent() {
+		ls.mailerService.LobbyStarting(ls.productName(lobby.TenantID), lobby.ID, lobby.GetMemberEmails())
+	}
+	return nil
+}
+
+// productName is what mail to the tenant's users calls the chat.
+func (ls *LobbyService) productName(tenantID string) string {
+	return ls.brandingService.GetBranding(tenantID).ProductName
+}
+
+// RestoreLobbies reloads the lobbies of the lobby registry with their
+// recent history, and the booked sessions. It must run before Run.
+func (ls *LobbyService) RestoreLobbies() error {
+	records, err := ls.store.LoadLobbies()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		historyLimit := ls.historyLimit
+		if record.HistoryLimit > 0 {
+			historyLimit = record.HistoryLimit
+		}
+		// Load one message more than fits so the ring knows older ones exist
+		stored, err := ls.store.GetMessagesRange(record.ID, -int64(historyLimit+1), -1)
+		if err != nil {
+			return err
+		}
+		history := make([]models.Message, 0, len(stored))
+		for _, storedMsg := range stored {
+			history = append(history, fromRedisMessage(storedMsg))
+		}
+
+		lobby := models.RestoreLobby(record, ls.historyLimit, history)
+		if imported, err := ls.loadImportedContext(record.ID); err != nil {
+			log.Printf("⚠️ Failed to restore imported context of lobby %s: %v", record.ID, err)
+		} else {
+			lobby.SetImportedContext(imported)
+		}
+		if err := ls.loadScores(lobby); err != nil {
+			log.Printf("⚠️ Failed to restore scores of lobby %s: %v", record.ID, err)
+		}
+		if err := ls.resumeSeq(lobby); err != nil {
+			log.Printf("⚠️ Failed to restore the sequence of lobby %s: %v", record.ID, err)
+		}
+
+		ls.mu.Lock()
+		ls.lobbies[record.ID] = lobby
+		ls.mu.Unlock()
+		// Members who don't come back lose their seats
+		ls.worker(record.ID)
+	}
+
+	log.Printf("♻️ Restored %d lobbies from the lobby registry", len(records))
+	if err := ls.loadSchedule(); err != nil {
+		log.Printf("⚠️ Failed to load scheduled lobbies: %v", err)
+	}
+	return nil
+}
+
+func (ls *LobbyService) saveLobby(lobby *models.Lobby) {
+	if lobby.Internal {
+		return
+	}
+	if err := ls.store.SaveLobby(lobby.Record()); err != nil {
+		log.Printf("⚠️ Failed to save lobby %s to the registry: %v", lobby.ID, err)
+	}
+}
+
 func (ls *LobbyService) GetLobby(lobbyID string) *models.Lobby {
 	ls.mu.RLock()
 	defer ls.mu.RUnlock()
 	return ls.lobbies[lobbyID]
 }
 
+// GetLobbies returns the live lobbies of every tenant, oldest first,
+// leaving out internal ones.
+func (ls *LobbyService) GetLobbies() []*models.Lobby {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	lobbies := make([]*models.Lobby, 0, len(ls.lobbies))
+	for _, lobby := range ls.lobbies {
+		if !lobby.Internal {
+			lobbies = append(lobbies, lobby)
+		}
+	}
+	sort.Slice(lobbies, func(i, j int) bool { return lobbies[i].CreatedAt.Before(lobbies[j].CreatedAt) })
+	return lobbies
+}
+
 func (ls *LobbyService) GetMostRecentLobby() *models.Lobby {
 	ls.mu.RLock()
 	defer ls.mu.RUnlock()
@@ -76,7 +317,7 @@ func (ls *LobbyService) GetMostRecentLobby() *models.Lobby {
 	var newestTime time.Time
 
 	for _, lobby := range ls.lobbies {
-		if lobby.CreatedAt.After(newestTime) {
+		if !lobby.Internal && lobby.CreatedAt.After(newestTime) {
 			newestTime = lobby.CreatedAt
 			currentLobby = lobby
 		}
@@ -91,7 +332,7 @@ func (ls *LobbyService) GetAvailableLobby() *models.Lobby {
 
 	// Look for a lobby that can accept new users
 	for _, lobby := range ls.lobbies {
-		if lobby.CanAcceptNewUsers() {
+		if !lobby.Internal && lobby.Topic == "" && lobby.CanAcceptNewUsers() {
 			return lobby
 		}
 	}
@@ -114,19 +355,81 @@ func (ls *LobbyService) FindLobbyByUserEmail(email string) *models.Lobby {
 	return nil
 }
 
-func (ls *LobbyService) Run() {
-	for {
-		select {
-		case client := <-ls.Register:
-			ls.handleRegister(client)
+// GetTranscript returns the full chat history of a lobby from the store,
+// falling back to the in-memory history when the store has nothing for it.
+func (ls *LobbyService) GetTranscript(lobbyID string) ([]models.RedisMessage, error) {
+	messages, err := ls.store.GetMessages(lobbyID)
+	if err == nil && len(messages) > 0 {
+		return messages, nil
+	}
 
-		case client := <-ls.Unregister:
-			ls.handleUnregister(client)
+	lobby := ls.GetLobby(lobbyID)
+	if lobby == nil {
+		return messages, err
+	}
+
+	transcript := make([]models.RedisMessage, 0)
+	for _, msg := range lobby.GetMessageHistory() {
+		transcript = append(transcript, toRedisMessage(msg, ls.ids))
+	}
+	return transcript, nil
+}
+
+// ImportTranscript loads an exported transcript of an earlier session into
+// lobby as read-only context, replacing any previous import. The messages
+// are marked as imported from sourceLobbyID and stay out of the lobby's
+// sequence, history, store queue and search. Senders an export names by
+// member ID are stored by email again if they were members of the source.
+func (ls *LobbyService) ImportTranscript(lobby *models.Lobby, sourceLobbyID string, transcript []models.RedisMessage) error {
+	if len(transcript) > config.MaxImportMessages {
+		return ErrTooManyImported
+	}
 
-		case broadcastMsg := <-ls.Broadcast:
-			ls.handleBroadcast(broadcastMsg)
+	emails := make(map[string]string)
+	if source, err := ls.FindSession(sourceLobbyID); err == nil {
+		for _, email := range source.Members {
+			emails[ls.MemberID(email)] = email
 		}
 	}
+
+	imported := make([]models.Message, 0, len(transcript))
+	for _, redisMsg := range transcript {
+		if email, ok := emails[redisMsg.Username]; ok {
+			redisMsg.Username = email
+		}
+		msg := fromRedisMessage(redisMsg)
+		msg.LobbyID = lobby.ID
+		msg.Seq = 0
+		msg.Imported = true
+		msg.ImportedFrom = sourceLobbyID
+		imported = append(imported, msg)
+	}
+
+	importedJSON, err := json.Marshal(imported)
+	if err != nil {
+		return err
+	}
+	if err := ls.store.SetWithTTL(ls.store.Key("lobby:%s:imported", lobby.ID), importedJSON, 0); err != nil {
+		return err
+	}
+
+	lobby.SetImportedContext(imported)
+	log.Printf("📥 Imported %d messages from %s into lobby %s", len(imported), sourceLobbyID, lobby.ID)
+	return nil
+}
+
+func (ls *LobbyService) loadImportedContext(lobbyID string) ([]models.Message, error) {
+	importedJSON, err := ls.store.Get(ls.store.Key("lobby:%s:imported", lobbyID))
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var imported []models.Message
+	err = json.Unmarshal([]byte(importedJSON), &imported)
+	return imported, err
 }
 
 func (ls *LobbyService) handleRegister(client *models.Client) {
@@ -135,44 +438,104 @@ func (ls *LobbyService) handleRegister(client *models.Client) {
 	lobby := ls.GetLobby(client.LobbyID)
 	if lobby == nil {
 		log.Printf("❌ Lobby not found: %s", client.LobbyID)
-		client.Conn.Close()
+		// Its writer closes the connection, if it has one
+		client.CloseWith(models.ErrorNotFound.CloseCode(), ErrLobbyNotFound.Error())
+		return
+	}
+	// The user may have timed out or been kicked since the handshake
+	if !lobby.IsUserInLobby(client.Email) {
+		log.Printf("❌ User no longer in lobby %s: %s", client.LobbyID, client.Email)
+		ls.replyError(client, models.ErrorUnauthorized, "You are no longer in this lobby, please log in again")
+		client.CloseWith(models.ErrorUnauthorized.CloseCode(), "You are no longer in this lobby")
 		return
 	}
 
-	// Add client to lobby
+	// The newest connection wins; the older one is told why it was dropped
+	if previous, connected := lobby.GetAllClients()[client.Email]; connected && lobby.DetachClient(previous) {
+		log.Printf("📱 [%s] %s logged in elsewhere, closing their connection %s", client.RequestID, client.Email, previous.RequestID)
+		previous.CloseWith(models.CloseLoggedInElsewhere, "You connected to this lobby from another tab or device")
+	}
+
+	// A member back within the grace window never left, so nobody is told
+	// they joined
+	rejoined := lobby.ReclaimSeat(client.Email)
+
+	// Add client to lobby; a new connection starts out visible
 	lobby.AddClient(client.Email, client)
+	lobby.SetHidden(client.Email, false, time.Now())
+	ls.refreshPresence(lobby)
 	connectedCount := lobby.GetConnectedClientCount()
 
-	log.Printf("✅ Client registered in handleRegister: %s (%d/%d)", client.Email, connectedCount, config.MaxUsersPerLobby)
+	log.Printf("✅ [%s] Client registered in handleRegister: %s (%d/%d)", client.RequestID, client.Email, connectedCount, lobby.MaxUsers)
 
 	// Send welcome message to this client
+	branding := ls.brandingService.GetBranding(lobby.TenantID)
+	settings := lobby.GetSettings()
 	welcomeAction := models.SystemActionWelcome
 	welcomeMsg := models.Message{
-		Type:         models.MessageTypeSystemAction,
-		SystemAction: &welcomeAction,
-		Content:      fmt.Sprintf("Welcome back, %s! 🎉", client.Email),
-		LobbyID:      client.LobbyID,
-		UserCount:    lobby.GetActiveUserCount(),
-		MaxUsers:     config.MaxUsersPerLobby,
-		UserList:     lobby.GetActiveUserList(),
-		Timestamp:    time.Now(),
+		Type:          models.MessageTypeSystemAction,
+		SystemAction:  &welcomeAction,
+		Content:       fmt.Sprintf("Welcome back to %s, %s! 🎉", branding.ProductName, client.Email),
+		LobbyID:       client.LobbyID,
+		UserCount:     lobby.GetActiveUserCount(),
+		MaxUsers:      lobby.MaxUsers,
+		UserList:      lobby.GetActiveUserList(),
+		Roles:         lobby.GetRoles(),
+		Guests:        lobby.GetGuests(),
+		Pinned:        lobby.GetPinned(),
+		ActionItems:   lobby.GetActionItems(),
+		Channels:      lobby.ChannelsOf(client.Email),
+		SystemEvents:  lobby.GetSystemEvents(),
+		GuestFriendly: lobby.IsGuestFriendly(),
+		Away:          lobby.GetAwayUsers(),
+		Threads:       lobby.GetThreads(),
+		EmojiPack:     ls.EmojiPack(lobby),
+		Budget:        lobby.GetBudget(),
+		Settings:      &settings,
+		MemberID:      ls.profileService.MemberID(client.Email),
+		Timestamp:     time.Now(),
+	}
+	if readStatus, err := ls.GetReadStatus(lobby, client.Email); err != nil {
+		log.Printf("⚠️ Failed to load the read pointer of %s: %v", client.Email, err)
+	} else {
+		welcomeMsg.Unread = readStatus.Unread
+		welcomeMsg.LastRead = readStatus.LastReadMessageID
 	}
 
 	log.Printf("📝 Sending welcome message to: %s (UserCount: %d)", client.Email, lobby.GetActiveUserCount())
-	client.Send <- welcomeMsg
+	if !ls.replay(lobby, client, welcomeMsg) {
+		return
+	}
 	log.Printf("✅ Welcome message queued for: %s", client.Email)
 
-	// Send message history to reconnecting user
-	messageHistory := lobby.GetMessageHistory()
-	log.Printf("📚 Sending %d history messages to: %s", len(messageHistory), client.Email)
-	for _, historyMsg := range messageHistory {
-		client.Send <- historyMsg
+	// A fresh connection first gets the context imported from a prior session,
+	// then only the messages the reconnecting user missed
+	var backlog []models.Message
+	if client.LastSeq == 0 {
+		backlog = append(backlog, lobby.GetImportedContext()...)
+	}
+	messageHistory := ls.withUnacked(lobby, client, ls.withInbox(lobby, client, ls.missedMessages(lobby, client.LastSeq)))
+	// What expired since the last sweep is not replayed
+	now := time.Now()
+	messageHistory = slices.DeleteFunc(messageHistory, func(msg models.Message) bool { return expired(msg, now) })
+	backlog = append(backlog, messageHistory...)
+	if client.BatchedReplay {
+		if !ls.startReplay(lobby, client, backlog) {
+			return
+		}
+	} else {
+		log.Printf("📚 Sending %d history messages to: %s (since seq %d)", len(messageHistory), client.Email, client.LastSeq)
+		for _, historyMsg := range backlog {
+			if !ls.replay(lobby, client, historyMsg) {
+				return
+			}
+		}
 	}
 
 	// Check if all users are connected
-	if connectedCount == config.MaxUsersPerLobby {
+	if connectedCount >= lobby.MaxUsers {
 		lobby.StartWebSocket()
-		log.Printf("🚀 WebSocket session started for lobby: %s (All %d users connected)", client.LobbyID, config.MaxUsersPerLobby)
+		log.Printf("🚀 WebSocket session started for lobby: %s (All %d users connected)", client.LobbyID, lobby.MaxUsers)
 	}
 
 	// Broadcast user joined to all clients
@@ -184,22 +547,140 @@ func (ls *LobbyService) handleRegister(client *models.Client) {
 		Content:      fmt.Sprintf("%s joined the chat", client.Email),
 		LobbyID:      client.LobbyID,
 		UserCount:    lobby.GetActiveUserCount(),
-		MaxUsers:     config.MaxUsersPerLobby,
+		MaxUsers:     lobby.MaxUsers,
 		UserList:     lobby.GetActiveUserList(),
+		Roles:        lobby.GetRoles(),
+		Guests:       lobby.GetGuests(),
 		Timestamp:    time.Now(),
 	}
 
+	if rejoined {
+		log.Printf("🔁 [%s] %s reconnected to lobby %s within the grace window", client.RequestID, client.Email, client.LobbyID)
+		return
+	}
+	ls.webhookService.Emit(models.WebhookEventUserJoined, lobby, map[string]string{"email": client.Email})
+	if lobby.GetSystemEvents() == models.SystemEventsDigest {
+		lobby.NoteRosterChange(client.Email, true)
+		return
+	}
+
 	log.Printf("📢 Broadcasting user joined for: %s", client.Email)
 
-	// NON-BLOCKING send to avoid deadlock
-	go func() {
-		ls.Broadcast <- BroadcastMessage{
-			LobbyID: client.LobbyID,
-			Message: joinMsg,
+	// Already on the lobby's worker, so broadcast in place
+	ls.handleBroadcast(BroadcastMessage{
+		LobbyID: client.LobbyID,
+		Message: joinMsg,
+	})
+}
+
+// replay queues a welcome or history message for a connection that just
+// registered. Replays can outgrow the queue, so it waits for the writer, but
+// only up to ReplayTimeout: a connection that stalls is dropped rather than
+// holding up the lobby.
+func (ls *LobbyService) replay(lobby *models.Lobby, client *models.Client, msg models.Message) bool {
+	timer := time.NewTimer(config.ReplayTimeout)
+	defer timer.Stop()
+
+	select {
+	case client.Send <- msg:
+		return true
+	case <-timer.C:
+		log.Printf("❌ Replay to %s stalled for %s, dropping the connection", client.Email, config.ReplayTimeout)
+		if lobby.DetachClient(client) {
+			client.CloseWith(models.CloseTooSlow, "Too slow to receive the history")
 		}
-	}()
+		return false
+	}
+}
+
+// missedMessages returns the chat messages after lastSeq, falling back to the
+// store when the gap reaches past the in-memory history.
+func (ls *LobbyService) missedMessages(lobby *models.Lobby, lastSeq int64) []models.Message {
+	history := lobby.GetMessageHistory()
+	if !lobby.IsHistoryTruncated() || (len(history) > 0 && history[0].Seq <= lastSeq+1) {
+		return lobby.GetMessageHistorySince(lastSeq)
+	}
+
+	redisMessages, err := ls.store.GetMessagesSince(lobby.ID, lastSeq)
+	if err != nil {
+		log.Printf("⚠️ Failed to load missed messages from the store: %v", err)
+		return lobby.GetMessageHistorySince(lastSeq)
+	}
 
-	log.Printf("✅ Join message queued for broadcast")
+	missed := make([]models.Message, 0, len(redisMessages))
+	for _, redisMsg := range redisMessages {
+		missed = append(missed, fromRedisMessage(redisMsg))
+	}
+	return missed
+}
+
+// GetHistoryPage returns up to limit chat messages older than beforeSeq
+// (or the newest ones when beforeSeq is 0), oldest first. It is served from
+// the in-memory history when that covers the page and otherwise pages
+// backwards through the store.
+func (ls *LobbyService) GetHistoryPage(lobby *models.Lobby, beforeSeq int64, limit int) ([]models.Message, error) {
+	page := make([]models.Message, 0, limit)
+	for _, msg := range lobby.GetMessageHistory() {
+		if beforeSeq == 0 || msg.Seq < beforeSeq {
+			page = append(page, msg)
+		}
+	}
+	if len(page) >= limit || !lobby.IsHistoryTruncated() {
+		return page[max(0, len(page)-limit):], nil
+	}
+
+	// Walk the stored queue from the newest end in chunks until the page is full
+	page = page[:0]
+	chunk := int64(limit)
+	for offset := int64(0); len(page) < limit; offset += chunk {
+		redisMessages, err := ls.store.GetMessagesRange(lobby.ID, -(offset + chunk), -(offset + 1))
+		if err != nil {
+			return nil, err
+		}
+
+		older := make([]models.Message, 0, len(redisMessages))
+		for _, redisMsg := range redisMessages {
+			if beforeSeq == 0 || redisMsg.Seq < beforeSeq {
+				older = append(older, fromRedisMessage(redisMsg))
+			}
+		}
+		page = append(older, page...)
+
+		if int64(len(redisMessages)) < chunk {
+			break
+		}
+	}
+	return page[max(0, len(page)-limit):], nil
+}
+
+func fromRedisMessage(redisMsg models.RedisMessage) models.Message {
+	msg := models.Message{
+		Type:        models.MessageTypeChat,
+		Username:    redisMsg.Username,
+		Content:     redisMsg.Content,
+		LobbyID:     redisMsg.LobbyID,
+		Seq:         redisMsg.Seq,
+		IsBot:       redisMsg.IsBot,
+		Emoji:       redisMsg.Emoji,
+		MessageID:   redisMsg.MessageID,
+		ClientMsgID: redisMsg.ClientMsgID,
+		Format:      redisMsg.Format,
+		Timestamp:   redisMsg.Timestamp,
+		ExpireAt:    redisMsg.ExpireAt,
+	}
+	if redisMsg.ParentMessageID != 0 {
+		msg.Type = models.MessageTypeReply
+		msg.ParentMessageID = redisMsg.ParentMessageID
+	}
+	if redisMsg.Idea {
+		msg.Type = models.MessageTypeIdea
+	}
+	if redisMsg.SystemAction != "" {
+		systemAction := redisMsg.SystemAction
+		msg.Type = models.MessageTypeSystemAction
+		msg.SystemAction = &systemAction
+	}
+	return msg
 }
 
 func (ls *LobbyService) handleUnregister(client *models.Client) {
@@ -208,40 +689,87 @@ func (ls *LobbyService) handleUnregister(client *models.Client) {
 		return
 	}
 
-	// Remove client and mark user as inactive
-	lobby.RemoveClient(client.Email)
-	close(client.Send)
+	// Only close Send if this connection wasn't already dropped (slow client,
+	// kick, lobby end or replaced by a reconnect)
+	if lobby.DetachClient(client) {
+		client.CloseWith(models.CloseNormal, "")
+		ls.refreshPresence(lobby)
+	}
+
+	// Kicked users were already announced; reconnected ones are still here
+	if !lobby.IsUserInLobby(client.Email) {
+		return
+	}
+	if _, reconnected := lobby.GetAllClients()[client.Email]; reconnected {
+		return
+	}
+
+	// A flaky network gets the grace window to reconnect in before anyone
+	// is told the member left
+	if config.ReconnectGrace > 0 {
+		lobby.HoldSeat(client.Email, time.Now())
+		log.Printf("⏳ [%s] Client disconnected from lobby %s: %s, holding their seat for %s", client.RequestID, client.LobbyID, client.Email, config.ReconnectGrace)
+		return
+	}
+
 	lobby.MarkUserInactive(client.Email)
+	log.Printf("👋 [%s] Client disconnected from lobby %s: %s (%d/%d remaining)", client.RequestID, client.LobbyID, client.Email, lobby.GetConnectedClientCount(), lobby.MaxUsers)
+	ls.announceDeparture(lobby, client.Email)
+}
 
+// announceDepartures announces the members whose seat was held for
+// ReconnectGrace without them reconnecting.
+func (ls *LobbyService) announceDepartures(lobby *models.Lobby, now time.Time) {
+	for _, email := range lobby.TakeDepartures(config.ReconnectGrace, now) {
+		log.Printf("👋 %s didn't reconnect to lobby %s within %s (%d/%d remaining)", email, lobby.ID, config.ReconnectGrace, lobby.GetConnectedClientCount(), lobby.MaxUsers)
+		ls.announceDeparture(lobby, email)
+	}
+}
+
+// announceDeparture tells the lobby a member marked inactive has left.
+func (ls *LobbyService) announceDeparture(lobby *models.Lobby, email string) {
+	ls.saveLobby(lobby)
 	connectedCount := lobby.GetConnectedClientCount()
 
-	log.Printf("👋 Client disconnected from lobby %s: %s (%d/%d remaining)", client.LobbyID, client.Email, connectedCount, config.MaxUsersPerLobby)
+	// The session is over once everyone has left a started lobby
+	if connectedCount == 0 && lobby.IsWebSocketStarted() {
+		ls.webhookService.Emit(models.WebhookEventLobbyEnded, lobby, map[string][]string{"members": lobby.GetMemberEmails()})
+	}
+
+	if lobby.GetSystemEvents() == models.SystemEventsDigest {
+		lobby.NoteRosterChange(email, false)
+		return
+	}
 
 	// Broadcast user left to remaining clients
 	userLeftAction := models.SystemActionUserLeft
 	leaveMsg := models.Message{
 		Type:         models.MessageTypeSystemAction,
 		SystemAction: &userLeftAction,
-		Username:     client.Email,
-		Content:      fmt.Sprintf("%s left the chat", client.Email),
-		LobbyID:      client.LobbyID,
+		Username:     email,
+		Content:      fmt.Sprintf("%s left the chat", email),
+		LobbyID:      lobby.ID,
 		UserCount:    lobby.GetActiveUserCount(),
-		MaxUsers:     config.MaxUsersPerLobby,
+		MaxUsers:     lobby.MaxUsers,
 		UserList:     lobby.GetActiveUserList(),
+		Roles:        lobby.GetRoles(),
+		Guests:       lobby.GetGuests(),
 		Timestamp:    time.Now(),
 	}
 
-	// NON-BLOCKING send
-	go func() {
-		ls.Broadcast <- BroadcastMessage{
-			LobbyID: client.LobbyID,
-			Message: leaveMsg,
-		}
-	}()
+	ls.handleBroadcast(BroadcastMessage{
+		LobbyID: lobby.ID,
+		Message: leaveMsg,
+	})
 }
 
 func (ls *LobbyService) handleBroadcast(broadcastMsg BroadcastMessage) {
 	log.Printf("📣 handleBroadcast called for lobby: %s, type: %s", broadcastMsg.LobbyID, broadcastMsg.Message.Type)
+	ctx, span := telemetry.Tracer.Start(trace.ContextWithSpanContext(context.Background(), broadcastMsg.Trace), "lobby.broadcast", trace.WithAttributes(
+		attribute.String("lobby.id", broadcastMsg.LobbyID),
+		attribute.String("message.type", string(broadcastMsg.Message.Type)),
+	))
+	defer span.End()
 
 	lobby := ls.GetLobby(broadcastMsg.LobbyID)
 	if lobby == nil {
@@ -249,34 +777,228 @@ func (ls *LobbyService) handleBroadcast(broadcastMsg BroadcastMessage) {
 		return
 	}
 
-	// Store message in history if it's a chat message
-	if broadcastMsg.Message.Type == models.MessageTypeChat {
-		lobby.AddMessageToHistory(broadcastMsg.Message)
+	chat := isChat(broadcastMsg.Message)
+
+	// A retransmitted chat message is acked again but not sent twice
+	if chat && ls.ackDuplicate(lobby, broadcastMsg.Message) {
+		return
+	}
 
-		// Push to Redis
-		err := ls.redisService.PushMessage(
-			broadcastMsg.Message.Username,
-			broadcastMsg.Message.Content,
-			broadcastMsg.Message.LobbyID,
-			broadcastMsg.Message.Timestamp,
-		)
+	// A whisper goes only to a channel its sender is in
+	var whisper *models.Channel
+	if broadcastMsg.Message.ChannelID != "" {
+		channel, err := whisperChannel(lobby, broadcastMsg.Message)
 		if err != nil {
-			log.Printf("⚠️ Failed to push message to Redis: %v", err)
+			ls.rejectWhisper(lobby, broadcastMsg.Message, err)
+			return
+		}
+		whisper = &channel
+	}
+
+	// Read-only and slow mode hold chat back before anything else
+	if chat && !lobby.Internal && ls.heldBySettings(lobby, broadcastMsg.Message) {
+		return
+	}
+
+	// So does the lobby's quota, while it cools down after a flood
+	if chat && !lobby.Internal && ls.heldByQuota(lobby, broadcastMsg.Message) {
+		return
+	}
+
+	// So do mutes and the spam detector
+	if chat && !lobby.Internal && ls.heldForSpam(lobby, broadcastMsg.Message) {
+		return
+	}
+
+	// Moderate chat before it is sequenced, persisted or delivered
+	if chat && !lobby.Internal {
+		verdict := ls.moderationService.Moderate(broadcastMsg.Message.Username, broadcastMsg.Message.Content)
+		if verdict.Blocked {
+			ls.audit(lobby, models.AuditMessageBlocked, models.AuditActorSystem, broadcastMsg.Message.Username, map[string]string{"reason": verdict.Reason})
+			ls.bounceModerated(lobby, broadcastMsg.Message, verdict.Reason)
+			return
+		}
+		broadcastMsg.Message.Content = verdict.Content
+	}
+
+	if broadcastMsg.Message.Type == models.MessageTypeReply {
+		if _, err := ls.threadParent(lobby, broadcastMsg.Message.ParentMessageID); err != nil {
+			ls.rejectReply(lobby, broadcastMsg.Message, err)
+			return
+		}
+	}
+
+	// Stamp every broadcast with the lobby's next sequence number
+	ls.sequence(lobby, &broadcastMsg.Message)
+
+	if chat {
+		broadcastMsg.Message.Content = sanitizeContent(broadcastMsg.Message.Format, broadcastMsg.Message.Content)
+		members := lobby.GetMemberEmails()
+		if whisper != nil {
+			members = whisper.Members
+		}
+		broadcastMsg.Message.Mentions = resolveMentions(broadcastMsg.Message.Content, members, ls.profileService.Get)
+		broadcastMsg.Message.Emoji = customEmoji(broadcastMsg.Message.Content, ls.EmojiPack(lobby))
+		if whisper == nil {
+			broadcastMsg.Message.ExpireAt = expiry(lobby, time.Now())
 		}
 	}
 
+	// Whispers aren't kept in history or the store, but still get an ID
+	// so retransmits are deduplicated
+	if whisper != nil {
+		broadcastMsg.Message.MessageID = messageID(ls.ids, broadcastMsg.Message)
+		ls.rememberSent(lobby, broadcastMsg.Message)
+	}
+
+	// Store message in history if it's a chat message or an announcement
+	if (chat || isAnnouncement(broadcastMsg.Message)) && !lobby.Internal && whisper == nil {
+		broadcastMsg.Message.MessageID = messageID(ls.ids, broadcastMsg.Message)
+		lobby.AddMessageToHistory(broadcastMsg.Message)
+
+		// Persist to the store
+		persistCtx, persist := telemetry.Tracer.Start(ctx, "lobby.persist")
+		if err := ls.store.PushMessage(persistCtx, broadcastMsg.Message); err != nil {
+			log.Printf("⚠️ Failed to persist message: %v", err)
+			persist.RecordError(err)
+			persist.SetStatus(codes.Error, err.Error())
+		}
+		persist.End()
+		if broadcastMsg.Message.Type == models.MessageTypeReply {
+			broadcastMsg.Message.ThreadCount = lobby.AddReply(broadcastMsg.Message.ParentMessageID)
+			ls.saveLobby(lobby)
+		}
+		if broadcastMsg.Message.Type == models.MessageTypeIdea {
+			ls.rankIdea(lobby, broadcastMsg.Message.Seq, 0)
+		}
+		if chat {
+			ls.rememberSent(lobby, broadcastMsg.Message)
+			ls.rates.Record(lobby.ID, time.Now())
+			ls.enrichLinks(lobby, broadcastMsg.Message)
+			ls.webhookService.Emit(models.WebhookEventMessageSent, lobby, broadcastMsg.Message)
+		}
+		ls.fillInboxes(lobby, broadcastMsg.Message)
+	}
+
+	// A reliable lobby keeps what it sends until each member acks it
+	ls.retainDelivered(lobby, broadcastMsg.Message, whisper)
+
 	// Broadcast to all connected clients in this lobby
 	clients := lobby.GetAllClients()
 	log.Printf("📤 Broadcasting to %d clients in lobby %s", len(clients), broadcastMsg.LobbyID)
+	span.SetAttributes(attribute.Int64("message.seq", broadcastMsg.Message.Seq), attribute.String("message.id", broadcastMsg.Message.MessageID))
+	_, fanOut := telemetry.Tracer.Start(ctx, "lobby.fan_out", trace.WithAttributes(attribute.Int("fan_out.recipients", len(clients))))
+	dropped := 0
 
 	for email, client := range clients {
+		if whisper != nil && !whisper.HasMember(email) {
+			continue
+		}
+		// A connection catching up gets the message after its history
+		if client.Replay != nil {
+			if !ls.holdForReplay(lobby, client, broadcastMsg.Message) {
+				dropped++
+			}
+			continue
+		}
 		select {
 		case client.Send <- broadcastMsg.Message:
 			log.Printf("✅ Message delivered to: %s", email)
 		default:
 			log.Printf("❌ Failed to deliver message to: %s (channel full or closed)", email)
-			lobby.RemoveClient(email)
-			close(client.Send)
+			dropped++
+			if lobby.DetachClient(client) {
+				client.CloseWith(models.CloseTooSlow, "Too slow to keep up with the lobby")
+			}
+		}
+	}
+	fanOut.SetAttributes(attribute.Int("fan_out.dropped", dropped))
+	fanOut.End()
+
+	if chat {
+		ls.ack(lobby, broadcastMsg.Message)
+	}
+	ls.notifyMentions(lobby, broadcastMsg.Message)
+}
+
+// bounceModerated tells the sender their message was blocked.
+func (ls *LobbyService) bounceModerated(lobby *models.Lobby, msg models.Message, reason string) {
+	log.Printf("🧹 Blocked message from %s in lobby %s: %s", msg.Username, lobby.ID, reason)
+
+	client, connected := lobby.GetAllClients()[msg.Username]
+	if !connected {
+		return
+	}
+
+	moderatedAction := models.SystemActionModerated
+	select {
+	case client.Send <- models.Message{
+		Type:         models.MessageTypeSystemAction,
+		SystemAction: &moderatedAction,
+		Username:     msg.Username,
+		Content:      reason,
+		LobbyID:      lobby.ID,
+		Timestamp:    time.Now(),
+	}:
+	default:
+		log.Printf("❌ Failed to deliver moderation notice to: %s", msg.Username)
+	}
+}
+
+// notifyMentions sends a mention system action to every connected client
+// named in the message's Mentions.
+func (ls *LobbyService) notifyMentions(lobby *models.Lobby, msg models.Message) {
+	if len(msg.Mentions) == 0 {
+		return
+	}
+
+	clients := lobby.GetAllClients()
+	for _, email := range msg.Mentions {
+		client, connected := clients[email]
+		if !connected || email == msg.Username {
+			continue
+		}
+
+		mentionAction := models.SystemActionMention
+		mentionMsg := models.Message{
+			Type:         models.MessageTypeSystemAction,
+			SystemAction: &mentionAction,
+			Username:     msg.Username,
+			Content:      fmt.Sprintf("%s mentioned you: %s", msg.Username, msg.Content),
+			LobbyID:      msg.LobbyID,
+			Mentions:     []string{email},
+			Seq:          msg.Seq,
+			Timestamp:    time.Now(),
+		}
+
+		select {
+		case client.Send <- mentionMsg:
+			log.Printf("🔔 Mention delivered to: %s", email)
+		default:
+			log.Printf("❌ Failed to deliver mention to: %s", email)
+		}
+	}
+}
+
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@([A-Za-z0-9._%+\-]+(?:@[A-Za-z0-9.\-]+)?)`)
+
+// resolveMentions matches @tokens against lobby members, by full email, by
+// the part before the @ or by display name with its spaces removed.
+func resolveMentions(content string, members []string, profile func(email string) models.Profile) []string {
+	var mentions []string
+	seen := make(map[string]bool)
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		token := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		for _, email := range members {
+			lower := strings.ToLower(email)
+			localPart, _, _ := strings.Cut(lower, "@")
+			displayName := strings.ToLower(strings.Join(strings.Fields(profile(email).DisplayName), ""))
+			if (token == lower || token == localPart || token == displayName) && !seen[email] {
+				seen[email] = true
+				mentions = append(mentions, email)
+			}
 		}
 	}
+	return mentions
 }
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+var ErrUnknownSession = errors.New("no live or archived session with this ID")
+
+// SessionSummary describes one session of a follow-up chain.
+type SessionSummary struct {
+	ID        string    `json:"id"`
+	ParentID  string    `json:"parent_id,omitempty"`
+	FollowUps []string  `json:"follow_ups,omitempty"`
+	Members   []string  `json:"members"`
+	Pinned    int       `json:"pinned"`
+	CreatedAt time.Time `json:"created_at"`
+	EndedAt   time.Time `json:"ended_at,omitzero"`
+	Live      bool      `json:"live"`
+}
+
+// CreateFollowUpLobby starts a new session of the tenant linked to
+// parentID. The parent's pinned messages are carried over as imported
+// context. Idle sessions of the tenant, the parent included, are archived so
+// new logins land in the follow-up; it fails while any session has active
+// users.
+func (ls *LobbyService) CreateFollowUpLobby(tenantID, parentID string) (*models.Lobby, error) {
+	parent, err := ls.FindSession(parentID)
+	if err != nil {
+		return nil, err
+	}
+	if parent.TenantID != tenantID {
+		return nil, ErrUnknownSession
+	}
+
+	ls.mu.Lock()
+	var idle []*models.Lobby
+	for _, lobby := range ls.lobbies {
+		if lobby.Internal || lobby.Topic != "" || lobby.TenantID != tenantID {
+			continue
+		}
+		if lobby.GetActiveUserCount() > 0 {
+			ls.mu.Unlock()
+			return nil, ErrSessionInProgress
+		}
+		idle = append(idle, lobby)
+	}
+
+	lobbyID := ls.newLobbyIDLocked()
+	lobby := models.NewLobby(lobbyID, ls.maxUsers, ls.historyLimit)
+	lobby.TenantID = tenantID
+	lobby.ParentID = parentID
+	ls.lobbies[lobbyID] = lobby
+	ls.mu.Unlock()
+
+	for _, idleLobby := range idle {
+		ls.archiveLobby(idleLobby)
+	}
+	if err := ls.linkFollowUp(parentID, lobbyID); err != nil {
+		log.Printf("⚠️ Failed to link follow-up %s to %s: %v", lobbyID, parentID, err)
+	}
+
+	pinned, err := ls.pinnedMessages(parent)
+	if err != nil {
+		log.Printf("⚠️ Failed to load pinned messages of %s: %v", parentID, err)
+	} else if len(pinned) > 0 {
+		if err := ls.ImportTranscript(lobby, parentID, pinned); err != nil {
+			log.Printf("⚠️ Failed to carry over pinned messages of %s: %v", parentID, err)
+		}
+	}
+
+	ls.saveLobby(lobby)
+	ls.webhookService.Emit(models.WebhookEventLobbyCreated, lobby, map[string]string{"parent_id": parentID})
+	ls.audit(lobby, models.AuditLobbyCreated, models.AuditActorSystem, "", map[string]string{"parent_id": parentID})
+	ls.mailerService.Invite(ls.productName(tenantID), lobbyID, parentID, parent.Members)
+	log.Printf("🔗 Created follow-up lobby %s of %s (tenant: %s, %d pinned carried over)", lobbyID, parentID, tenantID, len(pinned))
+	return lobby, nil
+}
+
+// newLobbyIDLocked returns lobby- and an ID from ls.ids that no live,
+// scheduled or archived session uses. The caller holds ls.mu.
+func (ls *LobbyService) newLobbyIDLocked() string {
+	for {
+		candidate := "lobby-" + ls.ids.NewID()
+		if _, live := ls.lobbies[candidate]; live {
+			continue
+		}
+		if _, scheduled := ls.scheduled[candidate]; scheduled {
+			continue
+		}
+		if _, err := ls.store.Get(ls.archiveKey(candidate)); err != nil {
+			return candidate
+		}
+	}
+}
+
+// FindSession returns the record of a live or archived session.
+func (ls *LobbyService) FindSession(lobbyID string) (models.LobbyRecord, error) {
+	if lobby := ls.GetLobby(lobbyID); lobby != nil && !lobby.Internal {
+		return lobby.Record(), nil
+	}
+
+	recordJSON, err := ls.store.Get(ls.archiveKey(lobbyID))
+	if errors.Is(err, ErrKeyNotFound) {
+		return models.LobbyRecord{}, ErrUnknownSession
+	}
+	if err != nil {
+		return models.LobbyRecord{}, err
+	}
+
+	var record models.LobbyRecord
+	err = json.Unmarshal([]byte(recordJSON), &record)
+	return record, err
+}
+
+// SessionChain returns the sessions from the first of the chain down to
+// lobbyID, oldest first.
+func (ls *LobbyService) SessionChain(lobbyID string) ([]SessionSummary, error) {
+	var chain []SessionSummary
+	seen := make(map[string]bool)
+	for id := lobbyID; id != "" && !seen[id]; {
+		seen[id] = true
+		record, err := ls.FindSession(id)
+		if errors.Is(err, ErrUnknownSession) && len(chain) > 0 {
+			// An ancestor that was never archived ends the chain
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		chain = append([]SessionSummary{ls.summarize(record)}, chain...)
+		id = record.ParentID
+	}
+	return chain, nil
+}
+
+func (ls *LobbyService) summarize(record models.LobbyRecord) SessionSummary {
+	return SessionSummary{
+		ID:        record.ID,
+		ParentID:  record.ParentID,
+		FollowUps: record.FollowUps,
+		Members:   record.Members,
+		Pinned:    len(record.Pinned),
+		CreatedAt: record.CreatedAt,
+		EndedAt:   record.EndedAt,
+		Live:      record.EndedAt.IsZero(),
+	}
+}
+
+// archiveLobby removes a finished session from the live lobbies and the
+// registry, keeping its record so follow-ups can still reach it, and saves
+// its summary.
+func (ls *LobbyService) archiveLobby(lobby *models.Lobby) {
+	ls.mu.Lock()
+	delete(ls.lobbies, lobby.ID)
+	ls.stopWorkerLocked(lobby.ID)
+	ls.mu.Unlock()
+	ls.spamDetector.Forget(lobby.ID, "")
+	ls.rates.Forget(lobby.ID)
+	for _, email := range lobby.GetMemberEmails() {
+		ls.dropInbox(lobby.ID, email)
+	}
+
+	record := lobby.Record()
+	record.EndedAt = time.Now()
+	if err := ls.saveArchive(record); err != nil {
+		log.Printf("⚠️ Failed to archive lobby %s: %v", lobby.ID, err)
+	}
+	ls.saveSummary(record)
+	ls.archiveTranscript(record)
+	if err := ls.store.DeleteLobby(lobby.ID); err != nil {
+		log.Printf("⚠️ Failed to delete lobby %s from the registry: %v", lobby.ID, err)
+	}
+	ls.audit(lobby, models.AuditLobbyArchived, models.AuditActorSystem, "", nil)
+	log.Printf("🗄️ Archived lobby %s", lobby.ID)
+}
+
+// linkFollowUp records childID as a follow-up of the live or archived
+// session parentID.
+func (ls *LobbyService) linkFollowUp(parentID, childID string) error {
+	if lobby := ls.GetLobby(parentID); lobby != nil {
+		lobby.AddFollowUp(childID)
+		ls.saveLobby(lobby)
+		return nil
+	}
+
+	record, err := ls.FindSession(parentID)
+	if err != nil {
+		return err
+	}
+	record.FollowUps = append(record.FollowUps, childID)
+	return ls.saveArchive(record)
+}
+
+func (ls *LobbyService) saveArchive(record models.LobbyRecord) error {
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return ls.store.SetWithTTL(ls.archiveKey(record.ID), recordJSON, 0)
+}
+
+func (ls *LobbyService) archiveKey(lobbyID string) string {
+	return ls.store.Key("lobby:%s:archive", lobbyID)
+}
+
+// pinnedMessages loads the pinned messages of a session from the store.
+func (ls *LobbyService) pinnedMessages(record models.LobbyRecord) ([]models.RedisMessage, error) {
+	if len(record.Pinned) == 0 {
+		return nil, nil
+	}
+
+	pinnedSeqs := make(map[int64]bool, len(record.Pinned))
+	for _, seq := range record.Pinned {
+		pinnedSeqs[seq] = true
+	}
+
+	messages, err := ls.store.GetMessages(record.ID)
+	if err != nil {
+		return nil, err
+	}
+	pinned := make([]models.RedisMessage, 0, len(record.Pinned))
+	for _, msg := range messages {
+		if pinnedSeqs[msg.Seq] {
+			pinned = append(pinned, msg)
+		}
+	}
+	return pinned, nil
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var ErrInvalidSettings = errors.New("invalid lobby settings")
+
+// UpdateSettings validates and applies a lobby's new settings, then
+// announces them with a settings_changed system action.
+func (ls *LobbyService) UpdateSettings(ctx context.Context, lobby *models.Lobby, actor string, settings models.LobbySettings) (models.LobbySettings, error) {
+	if err := validateSettings(lobby, settings); err != nil {
+		return models.LobbySettings{}, err
+	}
+	previous := lobby.GetSettings()
+	if settings == previous {
+		return settings, nil
+	}
+
+	lobby.ApplySettings(settings)
+	ls.saveLobby(lobby)
+	log.Printf("⚙️ %s changed the settings of lobby %s: %+v", actor, lobby.ID, settings)
+	logOverCapacity(lobby)
+	ls.audit(lobby, models.AuditSettingsChanged, actor, "", settings)
+
+	notice := ls.settingsNotice(lobby, actor, fmt.Sprintf("%s changed the lobby settings", actor))
+	if err := ls.Broadcast(ctx, BroadcastMessage{LobbyID: lobby.ID, Message: notice}); err != nil && !errors.Is(err, ErrLobbyNotFound) {
+		log.Printf("⚠️ Failed to announce the settings of lobby %s: %v", lobby.ID, err)
+	}
+	if settings.MaxUsers > previous.MaxUsers {
+		ls.admitWaiting(lobby.TenantID)
+	}
+	return settings, nil
+}
+
+// setSlowMode changes the slow mode cooldown from a set_slow_mode frame.
+func (ls *LobbyService) setSlowMode(lobby *models.Lobby, actor string, seconds int) error {
+	settings := lobby.GetSettings()
+	if settings.SlowModeSeconds == seconds {
+		return nil
+	}
+	settings.SlowModeSeconds = seconds
+	if err := validateSettings(lobby, settings); err != nil {
+		return err
+	}
+	lobby.ApplySettings(settings)
+
+	content := fmt.Sprintf("%s turned slow mode off", actor)
+	if seconds > 0 {
+		content = fmt.Sprintf("%s turned slow mode on: one message every %d seconds", actor, seconds)
+	}
+	ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: ls.settingsNotice(lobby, actor, content)})
+	return nil
+}
+
+// settingsNotice builds the settings_changed system action.
+func (ls *LobbyService) settingsNotice(lobby *models.Lobby, actor, content string) models.Message {
+	settings := lobby.GetSettings()
+	notice := ls.systemMessage(lobby, models.SystemActionSettings, actor, content)
+	notice.Settings = &settings
+	return notice
+}
+
+// logOverCapacity notes a lobby shrunk below its member count. Nobody is
+// removed; the lobby just takes no one new until members leave.
+func logOverCapacity(lobby *models.Lobby) {
+	count, maxUsers := lobby.GetUserCount(), lobby.GetSettings().MaxUsers
+	if count > maxUsers {
+		log.Printf("📉 Lobby %s is over capacity (%d/%d users), new joins wait", lobby.ID, count, maxUsers)
+	}
+}
+
+func validateSettings(lobby *models.Lobby, settings models.LobbySettings) error {
+	switch {
+	case settings.MaxUsers < 1 || settings.MaxUsers > config.MaxUsersLimit:
+		return fmt.Errorf("%w: max_users must be between 1 and %d", ErrInvalidSettings, config.MaxUsersLimit)
+	case settings.HistoryLimit < 1 || settings.HistoryLimit > config.MaxHistoryLimit:
+		return fmt.Errorf("%w: history_limit must be between 1 and %d", ErrInvalidSettings, config.MaxHistoryLimit)
+	case settings.SlowModeSeconds < 0 || settings.SlowModeSeconds > config.MaxSlowModeSeconds:
+		return fmt.Errorf("%w: slow_mode_seconds must be between 0 and %d", ErrInvalidSettings, config.MaxSlowModeSeconds)
+	case settings.RetentionSeconds < 0 || settings.RetentionMessages < 0:
+		return fmt.Errorf("%w: retention_seconds and retention_messages must not be negative", ErrInvalidSettings)
+	case settings.EphemeralSeconds < 0 || settings.EphemeralSeconds > config.MaxEphemeralSeconds:
+		return fmt.Errorf("%w: ephemeral_seconds must be between 0 and %d", ErrInvalidSettings, config.MaxEphemeralSeconds)
+	}
+	return nil
+}
+
+// heldBySettings applies the read-only and slow mode settings to a chat
+// message, telling the sender if it was held back. Owners, moderators and
+// bots, which post through the API, are held to neither.
+func (ls *LobbyService) heldBySettings(lobby *models.Lobby, msg models.Message) bool {
+	if msg.IsBot || roleRank(lobby.GetUserRole(msg.Username)) > 0 {
+		return false
+	}
+	client, connected := lobby.GetAllClients()[msg.Username]
+
+	if lobby.IsReadOnly() {
+		log.Printf("⚙️ Chat from %s in read-only lobby %s rejected", msg.Username, lobby.ID)
+		if connected {
+			ls.replyError(client, models.ErrorReadOnly, "This lobby is read-only")
+		}
+		return true
+	}
+
+	wait := lobby.HoldChat(msg.Username, time.Now())
+	if wait <= 0 {
+		return false
+	}
+	log.Printf("🐢 Chat from %s in lobby %s held back by slow mode for %s", msg.Username, lobby.ID, wait)
+	if connected {
+		slowModeAction := models.SystemActionSlowMode
+		select {
+		case client.Send <- models.Message{
+			Type:            models.MessageTypeSystemAction,
+			SystemAction:    &slowModeAction,
+			Code:            models.ErrorRateLimited,
+			Content:         fmt.Sprintf("Slow mode is on, you can send another message in %s", wait.Round(time.Second)),
+			LobbyID:         lobby.ID,
+			SlowModeSeconds: lobby.GetSettings().SlowModeSeconds,
+			RetryAfterMs:    wait.Milliseconds(),
+			Timestamp:       time.Now(),
+		}:
+		default:
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"encoding/json"
+	"errors"
+	"log"
+)
+
+var ErrNoSummary = errors.New("no summary for this lobby")
+
+// saveSummary builds the summary of an ended session from its archived
+// record, stored transcript and idea ranking, keeps it without expiry and
+// mails the participants its action items and links to it.
+func (ls *LobbyService) saveSummary(record models.LobbyRecord) {
+	transcript, err := ls.store.GetMessages(record.ID)
+	if err != nil {
+		log.Printf("⚠️ Failed to load the transcript of %s for its summary: %v", record.ID, err)
+		return
+	}
+
+	summary := models.LobbySummary{
+		LobbyID:         record.ID,
+		TenantID:        record.TenantID,
+		ParentID:        record.ParentID,
+		Participants:    record.Members,
+		StartedAt:       record.CreatedAt,
+		EndedAt:         record.EndedAt,
+		DurationSeconds: int64(record.EndedAt.Sub(record.CreatedAt).Seconds()),
+		MessagesByUser:  make(map[string]int),
+		ActionItems:     record.ActionItems,
+		Transcript:      transcript,
+	}
+	if summary.ActionItems == nil {
+		summary.ActionItems = []models.ActionItem{}
+	}
+	if summary.Participants == nil {
+		summary.Participants = []string{}
+	}
+	if summary.Transcript == nil {
+		summary.Transcript = []models.RedisMessage{}
+	}
+	for _, msg := range transcript {
+		if msg.SystemAction != "" {
+			continue
+		}
+		summary.MessageCount++
+		summary.MessagesByUser[msg.Username]++
+	}
+	if summary.Ideas, err = ls.endedIdeas(record.ID, transcript); err != nil {
+		log.Printf("⚠️ Failed to rank the ideas of %s for its summary: %v", record.ID, err)
+		summary.Ideas = []models.Idea{}
+	}
+
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		log.Printf("⚠️ Failed to encode the summary of %s: %v", record.ID, err)
+		return
+	}
+	if err := ls.store.SetWithTTL(ls.summaryKey(record.ID), summaryJSON, 0); err != nil {
+		log.Printf("⚠️ Failed to save the summary of %s: %v", record.ID, err)
+		return
+	}
+	ls.mailerService.TranscriptReady(ls.productName(record.TenantID), record.ID, summary.Participants, summary.ActionItems)
+	log.Printf("📝 Saved the summary of lobby %s (%d participants, %d messages)", record.ID, len(summary.Participants), summary.MessageCount)
+}
+
+// GetSummary returns the summary of an ended session, ErrNoSummary while it
+// is live or if it never existed.
+func (ls *LobbyService) GetSummary(lobbyID string) (models.LobbySummary, error) {
+	var summary models.LobbySummary
+	raw, err := ls.store.Get(ls.summaryKey(lobbyID))
+	if errors.Is(err, ErrKeyNotFound) {
+		return summary, ErrNoSummary
+	}
+	if err != nil {
+		return summary, err
+	}
+	err = json.Unmarshal([]byte(raw), &summary)
+	return summary, err
+}
+
+func (ls *LobbyService) summaryKey(lobbyID string) string {
+	return ls.store.Key("lobby:%s:summary", lobbyID)
+}
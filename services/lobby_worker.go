@@ -0,0 +1,347 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrLobbyNotFound = errors.New("lobby not found")
+
+// lobbyWorker serializes the events of one lobby in its own goroutine, so
+// a slow broadcast or a blocked welcome in one lobby never delays another.
+type lobbyWorker struct {
+	register   chan *models.Client
+	unregister chan *models.Client
+	broadcast  chan BroadcastMessage
+	commands   chan LobbyCommand
+	probe      chan chan struct{}
+	disconnect chan chan []*models.Client
+	done       chan struct{}
+}
+
+// worker returns the worker of a live lobby, starting it on first use, or
+// nil if the lobby doesn't exist.
+func (ls *LobbyService) worker(lobbyID string) *lobbyWorker {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if worker, running := ls.workers[lobbyID]; running {
+		return worker
+	}
+	if _, exists := ls.lobbies[lobbyID]; !exists {
+		return nil
+	}
+
+	worker := &lobbyWorker{
+		register:   make(chan *models.Client),
+		unregister: make(chan *models.Client),
+		broadcast:  make(chan BroadcastMessage),
+		commands:   make(chan LobbyCommand),
+		probe:      make(chan chan struct{}),
+		disconnect: make(chan chan []*models.Client),
+		done:       make(chan struct{}),
+	}
+	ls.workers[lobbyID] = worker
+	go ls.runWorker(lobbyID, worker)
+	return worker
+}
+
+// stopWorkerLocked ends the goroutine of a lobby leaving the live set. The
+// caller holds ls.mu.
+func (ls *LobbyService) stopWorkerLocked(lobbyID string) {
+	if worker, running := ls.workers[lobbyID]; running {
+		delete(ls.workers, lobbyID)
+		close(worker.done)
+	}
+}
+
+func (ls *LobbyService) runWorker(lobbyID string, worker *lobbyWorker) {
+	log.Printf("🧵 Lobby worker started: %s", lobbyID)
+	digest := time.NewTicker(config.RosterDigestInterval)
+	defer digest.Stop()
+	presence := time.NewTicker(config.PresenceCheckInterval)
+	defer presence.Stop()
+	delivery := time.NewTicker(config.DeliveryCheckInterval)
+	defer delivery.Stop()
+	budget := time.NewTicker(config.BudgetCheckInterval)
+	defer budget.Stop()
+	var idle <-chan time.Time
+	if config.IdleTimeout > 0 {
+		idleTicker := time.NewTicker(config.IdleCheckInterval)
+		defer idleTicker.Stop()
+		idle = idleTicker.C
+	}
+	var departures <-chan time.Time
+	if config.ReconnectGrace > 0 {
+		departureTicker := time.NewTicker(config.ReconnectCheckInterval)
+		defer departureTicker.Stop()
+		departures = departureTicker.C
+	}
+	var expire <-chan time.Time
+	if config.ExpiryInterval > 0 {
+		expireTicker := time.NewTicker(config.ExpiryInterval)
+		defer expireTicker.Stop()
+		expire = expireTicker.C
+	}
+	var prune <-chan time.Time
+	if config.RetentionInterval > 0 {
+		pruneTicker := time.NewTicker(config.RetentionInterval)
+		defer pruneTicker.Stop()
+		prune = pruneTicker.C
+	}
+
+	for {
+		select {
+		case client := <-worker.register:
+			ls.handleRegister(client)
+
+		case client := <-worker.unregister:
+			ls.handleUnregister(client)
+
+		case broadcastMsg := <-worker.broadcast:
+			ls.handleBroadcast(broadcastMsg)
+
+		case cmd := <-worker.commands:
+			ls.handleCommand(cmd)
+
+		case reply := <-worker.probe:
+			close(reply)
+
+		case reply := <-worker.disconnect:
+			var closed []*models.Client
+			if lobby := ls.GetLobby(lobbyID); lobby != nil {
+				closed = ls.disconnectClients(lobby)
+			}
+			reply <- closed
+
+		case <-digest.C:
+			if lobby := ls.GetLobby(lobbyID); lobby != nil {
+				ls.sendRosterDigest(lobby)
+			}
+
+		case <-presence.C:
+			if lobby := ls.GetLobby(lobbyID); lobby != nil {
+				ls.refreshPresence(lobby)
+			}
+
+		case now := <-delivery.C:
+			if lobby := ls.GetLobby(lobbyID); lobby != nil {
+				ls.redeliver(lobby, now)
+			}
+
+		case <-budget.C:
+			if lobby := ls.GetLobby(lobbyID); lobby != nil {
+				ls.checkBudget(lobby)
+			}
+
+		case <-idle:
+			if lobby := ls.GetLobby(lobbyID); lobby != nil && !lobby.Internal {
+				ls.releaseIdleUsers(lobby)
+			}
+
+		case now := <-departures:
+			if lobby := ls.GetLobby(lobbyID); lobby != nil {
+				ls.announceDepartures(lobby, now)
+			}
+
+		case now := <-expire:
+			if lobby := ls.GetLobby(lobbyID); lobby != nil && !lobby.Internal {
+				ls.expireMessages(lobby, now, lobby.GetSettings().EphemeralSeconds > 0)
+			}
+
+		case now := <-prune:
+			if lobby := ls.GetLobby(lobbyID); lobby != nil && !lobby.Internal {
+				ls.pruneHistory(lobby, now)
+				// Also catches stored messages that outlived ephemeral mode
+				ls.expireMessages(lobby, now, true)
+			}
+
+		case <-worker.done:
+			log.Printf("🧵 Lobby worker stopped: %s", lobbyID)
+			return
+		}
+	}
+}
+
+// Register hands a connected client to its lobby's worker, which sends the
+// welcome and history. A client of an unknown lobby is disconnected.
+func (ls *LobbyService) Register(client *models.Client) {
+	worker := ls.worker(client.LobbyID)
+	if worker == nil {
+		log.Printf("❌ Lobby not found: %s", client.LobbyID)
+		// Its writer closes the connection, if it has one
+		client.CloseWith(models.ErrorNotFound.CloseCode(), ErrLobbyNotFound.Error())
+		return
+	}
+
+	select {
+	case worker.register <- client:
+	case <-worker.done:
+		client.CloseWith(models.CloseLobbyEnded, "The lobby ended")
+	}
+}
+
+// DisconnectAll closes every connection, telling clients the server is
+// going away, for a shutdown. It waits until the close frames were written,
+// or ctx ends. Members keep their seats to reconnect once the server is
+// back.
+func (ls *LobbyService) DisconnectAll(ctx context.Context) {
+	ls.mu.RLock()
+	workers := make([]*lobbyWorker, 0, len(ls.workers))
+	for _, worker := range ls.workers {
+		workers = append(workers, worker)
+	}
+	ls.mu.RUnlock()
+
+	var closed []*models.Client
+	for _, worker := range workers {
+		// Buffered, so a worker never blocks on a caller that gave up
+		reply := make(chan []*models.Client, 1)
+		select {
+		case worker.disconnect <- reply:
+		case <-worker.done:
+			continue
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case clients := <-reply:
+			closed = append(closed, clients...)
+		case <-worker.done:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	for _, client := range closed {
+		select {
+		case <-client.Gone():
+		case <-ctx.Done():
+			return
+		}
+	}
+	log.Printf("👋 Disconnected %d connections for shutdown", len(closed))
+}
+
+// disconnectClients closes the connections of a lobby for a shutdown and
+// returns those with a writer of their own to wait for. Lobbies joined on
+// another lobby's connection, and GraphQL feeds, have none.
+func (ls *LobbyService) disconnectClients(lobby *models.Lobby) []*models.Client {
+	var closed []*models.Client
+	for _, client := range lobby.GetAllClients() {
+		if lobby.DetachClient(client) {
+			client.CloseWith(models.CloseGoingAway, "The server is restarting")
+			if client.Conn != nil && client.Rooms != nil {
+				closed = append(closed, client)
+			}
+		}
+	}
+	return closed
+}
+
+// Unregister tells the client's lobby the connection is gone.
+func (ls *LobbyService) Unregister(client *models.Client) {
+	worker := ls.worker(client.LobbyID)
+	if worker == nil {
+		return
+	}
+
+	select {
+	case worker.unregister <- client:
+	case <-worker.done:
+	}
+}
+
+// Broadcast queues a message for its lobby, waiting until the lobby's
+// worker accepts it or ctx ends.
+func (ls *LobbyService) Broadcast(ctx context.Context, broadcastMsg BroadcastMessage) error {
+	if !broadcastMsg.Trace.IsValid() {
+		broadcastMsg.Trace = trace.SpanContextFromContext(ctx)
+	}
+	worker := ls.worker(broadcastMsg.LobbyID)
+	if worker == nil {
+		log.Printf("❌ Lobby not found in broadcast: %s", broadcastMsg.LobbyID)
+		return ErrLobbyNotFound
+	}
+
+	select {
+	case worker.broadcast <- broadcastMsg:
+		return nil
+	case <-worker.done:
+		return ErrLobbyNotFound
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SendCommand queues a lobby command from one of its clients.
+func (ls *LobbyService) SendCommand(cmd LobbyCommand) {
+	worker := ls.worker(cmd.Client.LobbyID)
+	if worker == nil {
+		return
+	}
+
+	select {
+	case worker.commands <- cmd:
+	case <-worker.done:
+	}
+}
+
+// EndLobby ends a live lobby on behalf of actor, who the caller already
+// checked may end it, waiting until its worker accepts the command or ctx
+// ends.
+func (ls *LobbyService) EndLobby(ctx context.Context, lobbyID, actor string) error {
+	worker := ls.worker(lobbyID)
+	if worker == nil {
+		return ErrLobbyNotFound
+	}
+
+	cmd := LobbyCommand{
+		LobbyID: lobbyID,
+		Actor:   actor,
+		Frame:   models.Message{Type: models.MessageTypeEndLobby, Username: actor, LobbyID: lobbyID, Timestamp: time.Now()},
+	}
+	select {
+	case worker.commands <- cmd:
+		return nil
+	case <-worker.done:
+		return ErrLobbyNotFound
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Ping checks that every running lobby worker picks up work before ctx
+// expires.
+func (ls *LobbyService) Ping(ctx context.Context) error {
+	ls.mu.RLock()
+	workers := make(map[string]*lobbyWorker, len(ls.workers))
+	for lobbyID, worker := range ls.workers {
+		workers[lobbyID] = worker
+	}
+	ls.mu.RUnlock()
+
+	for lobbyID, worker := range workers {
+		reply := make(chan struct{})
+		select {
+		case worker.probe <- reply:
+		case <-worker.done:
+			continue
+		case <-ctx.Done():
+			return errors.New("lobby " + lobbyID + " is not responding")
+		}
+
+		select {
+		case <-reply:
+		case <-ctx.Done():
+			return errors.New("lobby " + lobbyID + " is not responding")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestLobbyService wires a LobbyService to store the way server.NewHub
+// does, with no external integrations.
+func newTestLobbyService(tb testing.TB, store Store) *LobbyService {
+	ids := &ULIDs{}
+	metrics := NewMetricsService(nil)
+	profiles := NewProfileService(store)
+	attachments := NewAttachmentService(store, tb.TempDir(), tb.TempDir(), 1<<20, NoopScanner{})
+	audit := NewAuditService(store, "")
+	ls := NewLobbyService(store, NewBrandingService(store), NewWebhookService(store, metrics), NewModerationService(nil, metrics), profiles, NewEmojiService(store, attachments), NewMailerService(NoopMailer{}, ""), audit, NewArchiveService(nil, ""), ids, 5, 100)
+	tb.Cleanup(func() {
+		ls.mu.Lock()
+		defer ls.mu.Unlock()
+		for lobbyID := range ls.workers {
+			ls.stopWorkerLocked(lobbyID)
+		}
+	})
+	return ls
+}
+
+// slowStore takes delay to persist each message of one lobby, as a store
+// under load or a large fan-out would.
+type slowStore struct {
+	Store
+	lobbyID string
+	delay   time.Duration
+}
+
+func (s *slowStore) PushMessage(ctx context.Context, msg models.Message) error {
+	if msg.LobbyID == s.lobbyID {
+		time.Sleep(s.delay)
+	}
+	return s.Store.PushMessage(ctx, msg)
+}
+
+func announcement(lobbyID string, n int) BroadcastMessage {
+	action := models.SystemActionAnnouncement
+	return BroadcastMessage{LobbyID: lobbyID, Message: models.Message{
+		Type:         models.MessageTypeSystemAction,
+		SystemAction: &action,
+		Content:      fmt.Sprintf("announcement %d", n),
+		LobbyID:      lobbyID,
+		Timestamp:    time.Now(),
+	}}
+}
+
+// BenchmarkBroadcastWithSlowLobby measures how long a broadcast to one of
+// several lobbies takes to be picked up while another lobby is kept busy
+// persisting slowly. With a worker per lobby it stays in microseconds;
+// single_loop replays the old single Run loop, where every broadcast waits
+// behind the slow lobby's.
+func BenchmarkBroadcastWithSlowLobby(b *testing.B) {
+	const fastLobbies = 8
+	const slowDelay = time.Millisecond
+
+	modes := []struct {
+		name string
+		// dispatch returns how broadcasts reach their lobby and a stop func
+		dispatch func(ls *LobbyService) (func(BroadcastMessage), func())
+	}{
+		{"workers", func(ls *LobbyService) (func(BroadcastMessage), func()) {
+			return func(msg BroadcastMessage) {
+				if err := ls.Broadcast(context.Background(), msg); err != nil {
+					b.Error(err)
+				}
+			}, func() {}
+		}},
+		{"single_loop", func(ls *LobbyService) (func(BroadcastMessage), func()) {
+			events := make(chan BroadcastMessage)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for msg := range events {
+					ls.handleBroadcast(msg)
+				}
+			}()
+			return func(msg BroadcastMessage) { events <- msg }, func() { close(events); <-done }
+		}},
+	}
+
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			store := &slowStore{Store: NewMemoryStore("bench", &ULIDs{}), delay: slowDelay}
+			ls := newTestLobbyService(b, store)
+			slow := ls.GetOrCreateLobby("slow")
+			store.lobbyID = slow.ID
+			fast := make([]string, fastLobbies)
+			for i := range fast {
+				fast[i] = ls.GetOrCreateLobby(fmt.Sprintf("fast-%d", i)).ID
+			}
+			send, stop := mode.dispatch(ls)
+
+			// The slow lobby is busy for the whole run
+			quit := make(chan struct{})
+			var busy sync.WaitGroup
+			busy.Add(1)
+			go func() {
+				defer busy.Done()
+				for n := 0; ; n++ {
+					select {
+					case <-quit:
+						return
+					default:
+						send(announcement(slow.ID, n))
+					}
+				}
+			}()
+
+			var next sync.Mutex
+			n := 0
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					next.Lock()
+					i := n
+					n++
+					next.Unlock()
+					send(announcement(fast[i%fastLobbies], i))
+				}
+			})
+			b.StopTimer()
+
+			close(quit)
+			busy.Wait()
+			stop()
+		})
+	}
+}
+
+// TestSlowLobbyDoesNotDelayOthers backs the benchmark with a bound: while
+// one lobby takes a long time per broadcast, another still picks its
+// broadcasts up at once.
+func TestSlowLobbyDoesNotDelayOthers(t *testing.T) {
+	store := &slowStore{Store: NewMemoryStore("test", &ULIDs{}), delay: 200 * time.Millisecond}
+	ls := newTestLobbyService(t, store)
+	slow := ls.GetOrCreateLobby("slow")
+	store.lobbyID = slow.ID
+	fast := ls.GetOrCreateLobby("fast")
+
+	// The first broadcast occupies the slow lobby's worker, the second
+	// waits for it
+	for n := range 2 {
+		go ls.Broadcast(context.Background(), announcement(slow.ID, n))
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	for n := range 10 {
+		if err := ls.Broadcast(context.Background(), announcement(fast.ID, n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("10 broadcasts to a fast lobby took %v while another lobby was slow", elapsed)
+	}
+}
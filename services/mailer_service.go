@@ -0,0 +1,184 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Mail is one plain-text email.
+type Mail struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email.
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+// NewMailer returns the mailer selected by config.MailerBackend.
+func NewMailer() Mailer {
+	switch config.MailerBackend {
+	case "smtp":
+		return &SMTPMailer{addr: config.SMTPAddr, username: config.SMTPUsername, password: config.SMTPPassword, from: config.MailFrom}
+	case "log":
+		return LogMailer{}
+	default:
+		return NoopMailer{}
+	}
+}
+
+// NoopMailer drops every mail; used when no mailer is configured.
+type NoopMailer struct{}
+
+func (NoopMailer) Send(mail Mail) error {
+	return nil
+}
+
+// LogMailer writes mail to the log instead of sending it, for development.
+type LogMailer struct{}
+
+func (LogMailer) Send(mail Mail) error {
+	log.Printf("✉️ Mail to %s: %s\n%s", strings.Join(mail.To, ", "), mail.Subject, mail.Body)
+	return nil
+}
+
+// SMTPMailer sends mail through an SMTP relay, authenticating with PLAIN
+// when a username is set.
+type SMTPMailer struct {
+	addr     string
+	username string
+	password string
+	from     string
+}
+
+func (sm *SMTPMailer) Send(mail Mail) error {
+	var auth smtp.Auth
+	if sm.username != "" {
+		host, _, err := net.SplitHostPort(sm.addr)
+		if err != nil {
+			return fmt.Errorf("smtp address: %w", err)
+		}
+		auth = smtp.PlainAuth("", sm.username, sm.password, host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", sm.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(mail.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mail.Subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+
+	return smtp.SendMail(sm.addr, auth, sm.from, mail.To, []byte(msg.String()))
+}
+
+// MailerService writes the lobby notification emails and sends them from a
+// queue, so lobby workers never wait on the mail server. Each recipient
+// gets a mail of their own.
+type MailerService struct {
+	mailer  Mailer
+	baseURL string
+	queue   chan Mail
+}
+
+// NewMailerService sends through mailer, linking to the server at baseURL.
+func NewMailerService(mailer Mailer, baseURL string) *MailerService {
+	return &MailerService{
+		mailer:  mailer,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		queue:   make(chan Mail, config.MailQueueSize),
+	}
+}
+
+// Run sends queued mail and blocks.
+func (ms *MailerService) Run() {
+	for mail := range ms.queue {
+		if err := ms.mailer.Send(mail); err != nil {
+			log.Printf("⚠️ Failed to send %q to %s: %v", mail.Subject, strings.Join(mail.To, ", "), err)
+		}
+	}
+}
+
+// Invite tells members of an earlier session that its follow-up is open.
+func (ms *MailerService) Invite(productName, lobbyID, parentID string, to []string) {
+	ms.sendEach(to, Mail{
+		Subject: fmt.Sprintf("You're invited to a follow-up session on %s", productName),
+		Body: fmt.Sprintf("Session %s, which you took part in, has a follow-up: %s.\n\nLog in at %s/ to join it.\n",
+			parentID, lobbyID, ms.baseURL),
+	})
+}
+
+// LobbyStarting tells the members of a lobby that every seat is taken.
+func (ms *MailerService) LobbyStarting(productName, lobbyID string, to []string) {
+	ms.sendEach(to, Mail{
+		Subject: fmt.Sprintf("Your %s lobby is starting", productName),
+		Body: fmt.Sprintf("All %d seats of lobby %s are taken and the session is about to start.\n\nJoin at %s/\n",
+			len(to), lobbyID, ms.baseURL),
+	})
+}
+
+// ScheduledOpen tells the participants of a scheduled session it is open.
+func (ms *MailerService) ScheduledOpen(productName, lobbyID string, to []string) {
+	ms.sendEach(to, Mail{
+		Subject: fmt.Sprintf("Your scheduled %s session is open", productName),
+		Body: fmt.Sprintf("Session %s, which you were invited to, is open now.\n\nLog in at %s/ to join it.\n",
+			lobbyID, ms.baseURL),
+	})
+}
+
+// TranscriptReady sends the participants of an ended session its action
+// items, if any, and links to its summary and transcript.
+func (ms *MailerService) TranscriptReady(productName, lobbyID string, to []string, actionItems []models.ActionItem) {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Session %s has ended.\n\n", lobbyID)
+	if len(actionItems) > 0 {
+		body.WriteString("Action items:\n")
+		for _, item := range actionItems {
+			fmt.Fprintf(&body, "- %s (%s)\n", item.Content, item.Username)
+		}
+		fmt.Fprintf(&body, "As CSV: %s/api/lobbies/%s/actions?format=csv\n\n", ms.baseURL, lobbyID)
+	}
+	fmt.Fprintf(&body, "Summary: %s/api/lobbies/%s/summary\nTranscript: %s/api/lobbies/%s/export?format=txt\n",
+		ms.baseURL, lobbyID, ms.baseURL, lobbyID)
+	ms.sendEach(to, Mail{
+		Subject: fmt.Sprintf("Your %s session has ended", productName),
+		Body:    body.String(),
+	})
+}
+
+// MagicLink mails an account the one-time link that logs it in.
+func (ms *MailerService) MagicLink(to, token string) {
+	ms.sendEach([]string{to}, Mail{
+		Subject: fmt.Sprintf("Your %s login link", config.DefaultProductName),
+		Body: fmt.Sprintf("Open this link to log in. It works once, for %s:\n\n%s/?magic=%s\n\nIf you didn't ask for it, ignore this mail.\n",
+			config.MagicLinkTTL, ms.baseURL, token),
+	})
+}
+
+// PasswordReset mails an account the link that sets a new password.
+func (ms *MailerService) PasswordReset(to, token string) {
+	ms.sendEach([]string{to}, Mail{
+		Subject: fmt.Sprintf("Reset your %s password", config.DefaultProductName),
+		Body: fmt.Sprintf("Open this link to choose a new password. It works once, for %s:\n\n%s/?reset=%s\n\nIf you didn't ask for it, ignore this mail; your password stays as it is.\n",
+			config.PasswordResetTTL, ms.baseURL, token),
+	})
+}
+
+func (ms *MailerService) sendEach(to []string, mail Mail) {
+	for _, recipient := range to {
+		mail.To = []string{recipient}
+		select {
+		case ms.queue <- mail:
+		default:
+			log.Printf("⚠️ Mail queue full, dropping %q to %s", mail.Subject, recipient)
+		}
+	}
+}
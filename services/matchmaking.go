@@ -0,0 +1,430 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidTags  = fmt.Errorf("tags must be at most %d of letters, digits and dashes, up to %d characters each", config.MaxInterestTags, config.MaxInterestTagLength)
+	ErrUnknownMatch = errors.New("unknown or expired matchmaking ticket")
+)
+
+var interestTagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// NormalizeTags lowercases and dedupes the interest tags of a login,
+// keeping the order the user gave them in, which is their preference.
+func NormalizeTags(tags []string) ([]string, error) {
+	if len(tags) > config.MaxInterestTags {
+		return nil, ErrInvalidTags
+	}
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if len(tag) > config.MaxInterestTagLength || !interestTagPattern.MatchString(tag) {
+			return nil, ErrInvalidTags
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+// MatchStatus is where a user looking for a lobby of their topics stands:
+// waiting for others sharing a tag until Deadline, admitted to a lobby, of
+// a Topic or the tenant's own, or in the waiting queue for the tenant's
+// lobby with QueueTicket.
+type MatchStatus struct {
+	Ticket   string    `json:"ticket"`
+	Email    string    `json:"email"`
+	Tags     []string  `json:"tags"`
+	Deadline time.Time `json:"deadline,omitzero"`
+	Admitted bool      `json:"admitted"`
+	LobbyID  string    `json:"lobby_id,omitempty"`
+	// Topic is the tag the user was matched on, empty after falling back
+	Topic       string `json:"topic,omitempty"`
+	Queued      bool   `json:"queued,omitempty"`
+	QueueTicket string `json:"queue_ticket,omitempty"`
+	Position    int    `json:"position,omitempty"`
+	// Error says why falling back to the tenant's lobby failed
+	Error string `json:"error,omitempty"`
+	// ReconnectToken and ConnectTicket are issued to an admitted user, see
+	// LoginResponse
+	ReconnectToken string `json:"reconnect_token,omitempty"`
+	ConnectTicket  string `json:"connect_ticket,omitempty"`
+}
+
+// Topic is an interest tag with its open lobbies and how full they are.
+type Topic struct {
+	Tag     string `json:"tag"`
+	Lobbies int    `json:"lobbies"`
+	Users   int    `json:"users"`
+	Seats   int    `json:"seats"`
+	// Percent is how much of Seats is taken
+	Percent int `json:"percent"`
+	// Waiting counts the users looking for a match who named the tag
+	Waiting int `json:"waiting"`
+}
+
+type matchEntry struct {
+	ticket   string
+	email    string
+	tenantID string
+	tags     []string
+	deadline time.Time
+	lastSeen time.Time
+	// done is set once the entry left the pool, with where it went
+	done   bool
+	status MatchStatus
+}
+
+// MatchmakingService groups users who log in with interest tags into
+// lobbies of a shared topic. A login joins an open lobby of one of its
+// tags if there is one; otherwise it waits in the tenant's pool, and once
+// MatchmakingMinUsers users there share a tag they get a new lobby of that
+// topic together. Whoever waits longer than MatchmakingTimeout falls back
+// to the tenant's lobby like a login without tags.
+type MatchmakingService struct {
+	lobbyService *LobbyService
+	mu           sync.Mutex
+	// pools holds the users still waiting, per tenant in arrival order
+	pools   map[string][]*matchEntry
+	tickets map[string]*matchEntry
+}
+
+func NewMatchmakingService(lobbyService *LobbyService) *MatchmakingService {
+	return &MatchmakingService{
+		lobbyService: lobbyService,
+		pools:        make(map[string][]*matchEntry),
+		tickets:      make(map[string]*matchEntry),
+	}
+}
+
+// Run seats waiting users in topic lobbies whose seats freed up, and falls
+// back those who waited too long, every MatchmakingCheckInterval.
+func (ms *MatchmakingService) Run() {
+	ticker := time.NewTicker(config.MatchmakingCheckInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		ms.check(now)
+	}
+}
+
+// Join looks for a lobby of one of tags for a user without a seat. The
+// returned status is admitted if the user got one at once.
+func (ms *MatchmakingService) Join(email, tenantID string, tags []string) (MatchStatus, error) {
+	// Invitees of a scheduled session wait for it to open
+	if scheduled, invited := ms.lobbyService.scheduledFor(email, tenantID); invited {
+		return MatchStatus{}, &NotStartedError{Lobby: scheduled}
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	ms.expireLocked(now)
+	for _, entry := range ms.pools[tenantID] {
+		if entry.email == email {
+			entry.tags = tags
+			entry.lastSeen = now
+			ms.placeLocked(entry)
+			return ms.statusLocked(entry), nil
+		}
+	}
+	if len(ms.pools[tenantID]) >= config.MaxQueueLength {
+		return MatchStatus{}, ErrQueueFull
+	}
+
+	ticket, err := GenerateToken()
+	if err != nil {
+		return MatchStatus{}, err
+	}
+	entry := &matchEntry{
+		ticket:   ticket,
+		email:    email,
+		tenantID: tenantID,
+		tags:     tags,
+		deadline: now.Add(config.MatchmakingTimeout),
+		lastSeen: now,
+	}
+	ms.pools[tenantID] = append(ms.pools[tenantID], entry)
+	ms.tickets[ticket] = entry
+	ms.placeLocked(entry)
+	if !entry.done {
+		log.Printf("🧲 %s is looking for a lobby on %s", email, strings.Join(tags, ", "))
+	}
+	return ms.statusLocked(entry), nil
+}
+
+// Status reports where a ticket stands, which keeps it alive.
+func (ms *MatchmakingService) Status(ticket string) (MatchStatus, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	ms.expireLocked(now)
+	entry, exists := ms.tickets[ticket]
+	if !exists {
+		return MatchStatus{}, ErrUnknownMatch
+	}
+	entry.lastSeen = now
+	return ms.statusLocked(entry), nil
+}
+
+// Leave stops looking for a match.
+func (ms *MatchmakingService) Leave(ticket string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	entry, exists := ms.tickets[ticket]
+	if !exists {
+		return ErrUnknownMatch
+	}
+	ms.removeLocked(entry)
+	return nil
+}
+
+// Topics lists the tenant's topics, those with an open lobby or waiting
+// users, busiest first.
+func (ms *MatchmakingService) Topics(tenantID string) []Topic {
+	topics := make(map[string]*Topic)
+	topic := func(tag string) *Topic {
+		if topics[tag] == nil {
+			topics[tag] = &Topic{Tag: tag}
+		}
+		return topics[tag]
+	}
+	for _, lobby := range ms.lobbyService.topicLobbies(tenantID) {
+		entry := topic(lobby.Topic)
+		entry.Lobbies++
+		entry.Users += lobby.GetUserCount()
+		entry.Seats += lobby.MaxUsers
+	}
+
+	ms.mu.Lock()
+	for _, entry := range ms.pools[tenantID] {
+		for _, tag := range entry.tags {
+			topic(tag).Waiting++
+		}
+	}
+	ms.mu.Unlock()
+
+	list := make([]Topic, 0, len(topics))
+	for _, entry := range topics {
+		if entry.Seats > 0 {
+			entry.Percent = min(100, entry.Users*100/entry.Seats)
+		}
+		list = append(list, *entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Users+list[i].Waiting != list[j].Users+list[j].Waiting {
+			return list[i].Users+list[i].Waiting > list[j].Users+list[j].Waiting
+		}
+		return list[i].Tag < list[j].Tag
+	})
+	return list
+}
+
+func (ms *MatchmakingService) check(now time.Time) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.expireLocked(now)
+	for _, pool := range ms.pools {
+		for _, entry := range append([]*matchEntry(nil), pool...) {
+			if entry.done {
+				continue
+			}
+			ms.placeLocked(entry)
+			if !entry.done && now.After(entry.deadline) {
+				ms.fallBackLocked(entry)
+			}
+		}
+	}
+}
+
+// placeLocked seats a waiting user in the open lobby of their tags that
+// has the most users, or else opens a lobby for the tag the most waiting
+// users share, once there are enough of them. Ties go to the tag the user
+// named first.
+func (ms *MatchmakingService) placeLocked(entry *matchEntry) {
+	// Logged in without tags since, or reconnecting
+	if lobby := ms.lobbyService.FindLobbyByUserEmail(entry.email); lobby != nil {
+		ms.admitLocked(entry, lobby)
+		return
+	}
+
+	var best *models.Lobby
+	for _, tag := range entry.tags {
+		for _, lobby := range ms.lobbyService.topicLobbies(entry.tenantID) {
+			if lobby.Topic != tag || !lobby.CanAcceptNewUsers() || lobby.IsBanned(entry.email) {
+				continue
+			}
+			if best == nil || lobby.GetUserCount() > best.GetUserCount() {
+				best = lobby
+			}
+		}
+	}
+	if best != nil && ms.lobbyService.seatIn(entry.email, best) == nil {
+		ms.admitLocked(entry, best)
+		return
+	}
+
+	var topic string
+	var group []*matchEntry
+	for _, tag := range entry.tags {
+		var sharing []*matchEntry
+		for _, waiting := range ms.pools[entry.tenantID] {
+			if !waiting.done && hasTag(waiting.tags, tag) {
+				sharing = append(sharing, waiting)
+			}
+		}
+		if len(sharing) > len(group) {
+			topic, group = tag, sharing
+		}
+	}
+	if len(group) < config.MatchmakingMinUsers {
+		return
+	}
+
+	lobby := ms.lobbyService.openTopicLobby(entry.tenantID, topic)
+	for _, member := range group {
+		if seated := ms.lobbyService.FindLobbyByUserEmail(member.email); seated != nil {
+			ms.admitLocked(member, seated)
+		} else if ms.lobbyService.seatIn(member.email, lobby) == nil {
+			ms.admitLocked(member, lobby)
+		}
+	}
+}
+
+// fallBackLocked gives up matching a user, seating them in the tenant's
+// lobby or putting them in line for it.
+func (ms *MatchmakingService) fallBackLocked(entry *matchEntry) {
+	lobby, _, err := ms.lobbyService.JoinLobby(entry.email, entry.tenantID)
+	switch {
+	case err == nil:
+		log.Printf("🧲 No match for %s, falling back to lobby %s", entry.email, lobby.ID)
+		ms.admitLocked(entry, lobby)
+		return
+	case errors.Is(err, ErrLobbyFull) || errors.Is(err, ErrSessionInProgress):
+		var queued QueueStatus
+		if queued, err = ms.lobbyService.Enqueue(entry.email, entry.tenantID); err == nil {
+			entry.status.Queued = true
+			entry.status.QueueTicket = queued.Ticket
+			entry.status.Position = queued.Position
+		}
+	}
+	if err != nil {
+		log.Printf("🧲 %s could not fall back to the tenant's lobby: %v", entry.email, err)
+		entry.status.Error = err.Error()
+	}
+	ms.finishLocked(entry)
+}
+
+func (ms *MatchmakingService) admitLocked(entry *matchEntry, lobby *models.Lobby) {
+	entry.status.Admitted = true
+	entry.status.LobbyID = lobby.ID
+	entry.status.Topic = lobby.Topic
+	if lobby.Topic != "" {
+		log.Printf("🧲 Matched %s into lobby %s on %s", entry.email, lobby.ID, lobby.Topic)
+	}
+	ms.finishLocked(entry)
+}
+
+// finishLocked takes an entry out of the pool; its ticket reports where it
+// went until it is no longer checked.
+func (ms *MatchmakingService) finishLocked(entry *matchEntry) {
+	entry.done = true
+	ms.dropFromPoolLocked(entry)
+}
+
+func (ms *MatchmakingService) statusLocked(entry *matchEntry) MatchStatus {
+	status := entry.status
+	status.Ticket = entry.ticket
+	status.Email = entry.email
+	status.Tags = entry.tags
+	if !entry.done {
+		status.Deadline = entry.deadline
+	}
+	return status
+}
+
+// expireLocked drops the tickets not checked within QueueTicketTTL.
+func (ms *MatchmakingService) expireLocked(now time.Time) {
+	for _, entry := range ms.tickets {
+		if now.Sub(entry.lastSeen) > config.QueueTicketTTL {
+			ms.removeLocked(entry)
+		}
+	}
+}
+
+func (ms *MatchmakingService) removeLocked(entry *matchEntry) {
+	delete(ms.tickets, entry.ticket)
+	ms.dropFromPoolLocked(entry)
+}
+
+func (ms *MatchmakingService) dropFromPoolLocked(entry *matchEntry) {
+	pool := ms.pools[entry.tenantID]
+	for i, waiting := range pool {
+		if waiting == entry {
+			ms.pools[entry.tenantID] = append(pool[:i:i], pool[i+1:]...)
+			break
+		}
+	}
+	if len(ms.pools[entry.tenantID]) == 0 {
+		delete(ms.pools, entry.tenantID)
+	}
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, candidate := range tags {
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// openTopicLobby creates a lobby of a topic for matchmaking to seat users
+// in.
+func (ls *LobbyService) openTopicLobby(tenantID, topic string) *models.Lobby {
+	ls.mu.Lock()
+	lobbyID := ls.newLobbyIDLocked()
+	lobby := models.NewLobby(lobbyID, ls.maxUsers, ls.historyLimit)
+	lobby.TenantID = tenantID
+	lobby.Topic = topic
+	ls.lobbies[lobbyID] = lobby
+	ls.mu.Unlock()
+
+	ls.saveLobby(lobby)
+	ls.webhookService.Emit(models.WebhookEventLobbyCreated, lobby, nil)
+	ls.audit(lobby, models.AuditLobbyCreated, models.AuditActorSystem, "", nil)
+	log.Printf("🆕 Created lobby %s on %s (tenant: %s)", lobbyID, topic, tenantID)
+	return lobby
+}
+
+// topicLobbies returns the tenant's live topic lobbies.
+func (ls *LobbyService) topicLobbies(tenantID string) []*models.Lobby {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	var lobbies []*models.Lobby
+	for _, lobby := range ls.lobbies {
+		if lobby.Topic != "" && !lobby.Internal && lobby.TenantID == tenantID {
+			lobbies = append(lobbies, lobby)
+		}
+	}
+	return lobbies
+}
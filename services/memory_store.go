@@ -0,0 +1,219 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps everything in the process, for tests
+// and local development without Redis. Nothing survives a restart, and
+// hubs sharing a process don't share it.
+type MemoryStore struct {
+	namespace string
+	ids       IDGenerator
+
+	mu       sync.Mutex
+	kv       map[string]expiringValue
+	messages map[string][]models.RedisMessage
+	lobbies  map[string]models.LobbyRecord
+}
+
+// NewMemoryStore gives messages stored without an ID one from ids.
+func NewMemoryStore(namespace string, ids IDGenerator) *MemoryStore {
+	log.Printf("⚠️ Using the in-memory store: lobbies, messages and sessions are lost on restart")
+	return &MemoryStore{
+		namespace: namespace,
+		ids:       ids,
+		kv:        make(map[string]expiringValue),
+		messages:  make(map[string][]models.RedisMessage),
+		lobbies:   make(map[string]models.LobbyRecord),
+	}
+}
+
+func (ms *MemoryStore) Key(format string, args ...interface{}) string {
+	return ms.namespace + ":" + fmt.Sprintf(format, args...)
+}
+
+func (ms *MemoryStore) PushMessage(_ context.Context, msg models.Message) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.messages[msg.LobbyID] = append(ms.messages[msg.LobbyID], toRedisMessage(msg, ms.ids))
+	return nil
+}
+
+func (ms *MemoryStore) GetMessages(lobbyID string) ([]models.RedisMessage, error) {
+	return ms.GetMessagesRange(lobbyID, 0, -1)
+}
+
+func (ms *MemoryStore) GetMessagesRange(lobbyID string, start, stop int64) ([]models.RedisMessage, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	stored := ms.messages[lobbyID]
+	from, to := lrangeBounds(int64(len(stored)), start, stop)
+	if from == to {
+		return nil, nil
+	}
+	// Copied, so callers can't reach into the store
+	return append([]models.RedisMessage(nil), stored[from:to]...), nil
+}
+
+func (ms *MemoryStore) GetMessagesSince(lobbyID string, seq int64) ([]models.RedisMessage, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var missed []models.RedisMessage
+	for _, msg := range ms.messages[lobbyID] {
+		if msg.Seq > seq {
+			missed = append(missed, msg)
+		}
+	}
+	return missed, nil
+}
+
+func (ms *MemoryStore) TrimMessages(lobbyID string, keep int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	stored, exists := ms.messages[lobbyID]
+	if !exists {
+		return nil
+	}
+	if drop := int64(len(stored)) - max(keep, 0); drop > 0 {
+		ms.messages[lobbyID] = append([]models.RedisMessage(nil), stored[drop:]...)
+	}
+	return nil
+}
+
+func (ms *MemoryStore) DeleteMessages(lobbyID string, messageIDs []string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	stored, exists := ms.messages[lobbyID]
+	if !exists {
+		return nil
+	}
+	ms.messages[lobbyID] = slices.DeleteFunc(slices.Clone(stored), func(msg models.RedisMessage) bool {
+		return slices.Contains(messageIDs, msg.MessageID)
+	})
+	return nil
+}
+
+func (ms *MemoryStore) MessageLobbies() ([]string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	lobbyIDs := make([]string, 0, len(ms.messages))
+	for lobbyID := range ms.messages {
+		lobbyIDs = append(lobbyIDs, lobbyID)
+	}
+	sort.Strings(lobbyIDs)
+	return lobbyIDs, nil
+}
+
+// SetWithTTL stores value at key; a zero ttl never expires. Expired keys are
+// dropped lazily on the next Get.
+func (ms *MemoryStore) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.kv[key] = newExpiringValue(value, ttl)
+	return nil
+}
+
+func (ms *MemoryStore) Get(key string) (string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	entry, exists := ms.kv[key]
+	if !exists {
+		return "", ErrKeyNotFound
+	}
+	if entry.expired() {
+		delete(ms.kv, key)
+		return "", ErrKeyNotFound
+	}
+	return entry.Value, nil
+}
+
+func (ms *MemoryStore) Take(key string) (string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	entry, exists := ms.kv[key]
+	delete(ms.kv, key)
+	if !exists || entry.expired() {
+		return "", ErrKeyNotFound
+	}
+	return entry.Value, nil
+}
+
+func (ms *MemoryStore) Delete(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.kv, key)
+	return nil
+}
+
+func (ms *MemoryStore) SaveLobby(record models.LobbyRecord) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.lobbies[record.ID] = record
+	return nil
+}
+
+func (ms *MemoryStore) LoadLobbies() ([]models.LobbyRecord, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	records := make([]models.LobbyRecord, 0, len(ms.lobbies))
+	for _, record := range ms.lobbies {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+	return records, nil
+}
+
+func (ms *MemoryStore) DeleteLobby(lobbyID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.lobbies, lobbyID)
+	return nil
+}
+
+func (ms *MemoryStore) SetScore(key, member string, score float64) error {
+	return setJSONScore(ms, key, member, score)
+}
+
+func (ms *MemoryStore) TopScores(key string, n int) ([]ScoredMember, error) {
+	return topJSONScores(ms, key, n)
+}
+
+func (ms *MemoryStore) AppendStream(key, entry string, maxLen int64) error {
+	return appendJSONStream(ms, key, entry, maxLen)
+}
+
+func (ms *MemoryStore) ReadStream(key string, since, until time.Time) ([]string, error) {
+	return readJSONStream(ms, key, since, until)
+}
+
+// Inboxes are kept as the other JSON stores keep them, unsealed: they
+// never leave the process.
+func (ms *MemoryStore) PushInbox(key string, msg models.Message, maxLen int64) error {
+	return pushJSONInbox(ms, nil, ms.ids, key, msg, maxLen)
+}
+
+func (ms *MemoryStore) DrainInbox(key string) ([]models.RedisMessage, error) {
+	return drainJSONInbox(ms, nil, key)
+}
+
+// Degraded is always false: there is no backend to lose.
+func (ms *MemoryStore) Degraded() bool {
+	return false
+}
+
+// Ping always answers.
+func (ms *MemoryStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (ms *MemoryStore) Close() {}
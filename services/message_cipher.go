@@ -0,0 +1,148 @@
+package services
+
+import (
+	"bytes"
+	"chat-integrated/config"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnknownMessageKey = errors.New("stored message is sealed with an unknown key")
+
+// sealedPrefix starts every encrypted entry, which reads
+// "enc:<key ID>:<base64 nonce and ciphertext>". Entries without it are
+// plaintext JSON from before encryption was turned on.
+const sealedPrefix = "enc:"
+
+// MessageKey is one AES-256 key and the ID stored entries name it by.
+type MessageKey struct {
+	ID  string
+	Key []byte
+}
+
+// KeyProvider supplies the message keys, the first of which seals new
+// entries; the others only open entries sealed before a rotation. A KMS
+// client can implement it to keep keys out of the environment.
+type KeyProvider interface {
+	MessageKeys() ([]MessageKey, error)
+}
+
+// EnvKeyProvider reads config.MessageEncryptionKeys, a comma separated list
+// of "id:base64 key".
+type EnvKeyProvider struct{}
+
+func (EnvKeyProvider) MessageKeys() ([]MessageKey, error) {
+	var keys []MessageKey
+	for _, entry := range strings.Split(config.MessageEncryptionKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, found := strings.Cut(entry, ":")
+		if !found || id == "" {
+			return nil, fmt.Errorf("message key %q must be id:base64", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("message key %s: %w", id, err)
+		}
+		keys = append(keys, MessageKey{ID: id, Key: key})
+	}
+	return keys, nil
+}
+
+// MessageCipher seals stored messages with AES-256-GCM. A nil cipher
+// leaves them in plaintext.
+type MessageCipher struct {
+	currentID string
+	aeads     map[string]cipher.AEAD
+}
+
+// NewMessageCipher builds a cipher from the provider's keys, or returns nil
+// if it has none.
+func NewMessageCipher(provider KeyProvider) (*MessageCipher, error) {
+	keys, err := provider.MessageKeys()
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+
+	mc := &MessageCipher{currentID: keys[0].ID, aeads: make(map[string]cipher.AEAD)}
+	for _, key := range keys {
+		if strings.Contains(key.ID, ":") {
+			return nil, fmt.Errorf("message key ID %q must not contain ':'", key.ID)
+		}
+		if _, duplicate := mc.aeads[key.ID]; duplicate {
+			return nil, fmt.Errorf("message key ID %q is listed twice", key.ID)
+		}
+		if len(key.Key) != 32 {
+			return nil, fmt.Errorf("message key %s must be 32 bytes, got %d", key.ID, len(key.Key))
+		}
+		block, err := aes.NewCipher(key.Key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		mc.aeads[key.ID] = aead
+	}
+	return mc, nil
+}
+
+// KeyID is the ID of the key that seals new entries.
+func (mc *MessageCipher) KeyID() string {
+	return mc.currentID
+}
+
+// Seal encrypts a stored message. The key ID is authenticated with it, so an
+// entry can't be relabelled to another key.
+func (mc *MessageCipher) Seal(plaintext []byte) ([]byte, error) {
+	if mc == nil {
+		return plaintext, nil
+	}
+
+	aead := mc.aeads[mc.currentID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(mc.currentID))
+
+	out := make([]byte, 0, len(sealedPrefix)+len(mc.currentID)+1+base64.StdEncoding.EncodedLen(len(sealed)))
+	out = append(out, sealedPrefix...)
+	out = append(out, mc.currentID...)
+	out = append(out, ':')
+	return base64.StdEncoding.AppendEncode(out, sealed), nil
+}
+
+// Open decrypts an entry written by Seal with any known key. Plaintext
+// entries are returned as they are.
+func (mc *MessageCipher) Open(entry []byte) ([]byte, error) {
+	rest, sealed := bytes.CutPrefix(entry, []byte(sealedPrefix))
+	if !sealed {
+		return entry, nil
+	}
+	if mc == nil {
+		return nil, ErrUnknownMessageKey
+	}
+
+	id, encoded, found := bytes.Cut(rest, []byte(":"))
+	aead, known := mc.aeads[string(id)]
+	if !found || !known {
+		return nil, fmt.Errorf("%w %q", ErrUnknownMessageKey, id)
+	}
+	data, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("sealed message is truncated")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], id)
+}
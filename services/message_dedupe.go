@@ -0,0 +1,87 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+// sentMessage is what a lobby remembers of a chat message sent with a
+// ClientMsgID, to ack a retransmission of it.
+type sentMessage struct {
+	MessageID string `json:"message_id"`
+	Seq       int64  `json:"seq"`
+}
+
+// ackDuplicate acks a chat message whose ClientMsgID its sender already
+// used in the lobby within config.ClientMsgIDTTL, and reports whether it
+// was one. A store that can't tell lets the message through.
+func (ls *LobbyService) ackDuplicate(lobby *models.Lobby, msg models.Message) bool {
+	if msg.ClientMsgID == "" {
+		return false
+	}
+	raw, err := ls.store.Get(ls.clientMsgKey(lobby.ID, msg.Username, msg.ClientMsgID))
+	if err != nil {
+		if !errors.Is(err, ErrKeyNotFound) {
+			log.Printf("⚠️ Failed to check client message %s from %s: %v", msg.ClientMsgID, msg.Username, err)
+		}
+		return false
+	}
+	var sent sentMessage
+	if err := json.Unmarshal([]byte(raw), &sent); err != nil {
+		return false
+	}
+
+	log.Printf("🔁 Duplicate message %s from %s in lobby %s, already stored as %s", msg.ClientMsgID, msg.Username, lobby.ID, sent.MessageID)
+	msg.MessageID = sent.MessageID
+	msg.Seq = sent.Seq
+	ls.ack(lobby, msg)
+	return true
+}
+
+// rememberSent records a stored chat message's ClientMsgID.
+func (ls *LobbyService) rememberSent(lobby *models.Lobby, msg models.Message) {
+	if msg.ClientMsgID == "" {
+		return
+	}
+	sentJSON, err := json.Marshal(sentMessage{MessageID: msg.MessageID, Seq: msg.Seq})
+	if err != nil {
+		return
+	}
+	if err := ls.store.SetWithTTL(ls.clientMsgKey(lobby.ID, msg.Username, msg.ClientMsgID), sentJSON, config.ClientMsgIDTTL); err != nil {
+		log.Printf("⚠️ Failed to remember client message %s from %s: %v", msg.ClientMsgID, msg.Username, err)
+	}
+}
+
+// ack tells the sender of msg the MessageID and Seq its ClientMsgID got.
+func (ls *LobbyService) ack(lobby *models.Lobby, msg models.Message) {
+	if msg.ClientMsgID == "" {
+		return
+	}
+	client, connected := lobby.GetAllClients()[msg.Username]
+	if !connected {
+		return
+	}
+
+	ackAction := models.SystemActionAck
+	select {
+	case client.Send <- models.Message{
+		Type:         models.MessageTypeSystemAction,
+		SystemAction: &ackAction,
+		LobbyID:      lobby.ID,
+		MessageID:    msg.MessageID,
+		ClientMsgID:  msg.ClientMsgID,
+		Seq:          msg.Seq,
+		Timestamp:    time.Now(),
+	}:
+	default:
+		log.Printf("❌ Failed to deliver ack to: %s", msg.Username)
+	}
+}
+
+func (ls *LobbyService) clientMsgKey(lobbyID, email, clientMsgID string) string {
+	return ls.store.Key("lobby:%s:client_msg:%s:%s", lobbyID, email, clientMsgID)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type MetricsService struct {
+	counters map[string]float64
+	gauges   map[string]float64
+	labels   string
+	mu       sync.RWMutex
+}
+
+// NewMetricsService creates a registry whose metrics all carry the given
+// constant labels (e.g. {"hub": "staging"}).
+func NewMetricsService(labels map[string]string) *MetricsService {
+	return &MetricsService{
+		counters: make(map[string]float64),
+		gauges:   make(map[string]float64),
+		labels:   formatLabels(labels),
+	}
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", key, labels[key]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (ms *MetricsService) IncCounter(name string) {
+	ms.AddCounter(name, 1)
+}
+
+func (ms *MetricsService) AddCounter(name string, value float64) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.counters[name] += value
+}
+
+func (ms *MetricsService) SetGauge(name string, value float64) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.gauges[name] = value
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition format.
+func (ms *MetricsService) WritePrometheus(w io.Writer) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	writeFamily(w, "counter", ms.counters, ms.labels)
+	writeFamily(w, "gauge", ms.gauges, ms.labels)
+}
+
+func writeFamily(w io.Writer, metricType string, values map[string]float64, labels string) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+		fmt.Fprintf(w, "%s%s %g\n", name, labels, values[name])
+	}
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"bytes"
+	"chat-integrated/config"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ModerationVerdict is the outcome of running a message through a filter.
+// Content is the possibly masked text to broadcast instead.
+type ModerationVerdict struct {
+	Blocked bool
+	Content string
+	Reason  string
+}
+
+// ModerationFilter is one step of the moderation chain.
+type ModerationFilter interface {
+	Name() string
+	Filter(content string) (ModerationVerdict, error)
+}
+
+// NewModerationFilters builds the filter chain from config: word list, then
+// regex rules, then the external moderation API.
+func NewModerationFilters() ([]ModerationFilter, error) {
+	var filters []ModerationFilter
+
+	if config.ModerationWords != "" {
+		filters = append(filters, NewWordListFilter(strings.Split(config.ModerationWords, ",")))
+	}
+	if config.ModerationRulesFile != "" {
+		rules, err := loadModerationRules(config.ModerationRulesFile)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, rules)
+	}
+	if config.ModerationAPIURL != "" {
+		filters = append(filters, &HTTPModerationFilter{
+			url:    config.ModerationAPIURL,
+			client: &http.Client{Timeout: config.ModerationTimeout},
+		})
+	}
+	return filters, nil
+}
+
+// WordListFilter masks listed words (whole words, case-insensitive) with
+// asterisks.
+type WordListFilter struct {
+	pattern *regexp.Regexp
+}
+
+func NewWordListFilter(words []string) *WordListFilter {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	return &WordListFilter{pattern: regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)}
+}
+
+func (wf *WordListFilter) Name() string {
+	return "wordlist"
+}
+
+func (wf *WordListFilter) Filter(content string) (ModerationVerdict, error) {
+	masked := wf.pattern.ReplaceAllStringFunc(content, func(word string) string {
+		return strings.Repeat("*", len([]rune(word)))
+	})
+	return ModerationVerdict{Content: masked}, nil
+}
+
+// ModerationRule is a regex that either masks its matches or blocks the
+// whole message.
+type ModerationRule struct {
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"` // "mask" or "block"
+	Reason  string `json:"reason,omitempty"`
+
+	compiled *regexp.Regexp
+}
+
+// RegexFilter applies ModerationRules in order.
+type RegexFilter struct {
+	rules []ModerationRule
+}
+
+func loadModerationRules(path string) (*RegexFilter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []ModerationRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid moderation rules %s: %w", path, err)
+	}
+	for i := range rules {
+		if rules[i].Action != "mask" && rules[i].Action != "block" {
+			return nil, fmt.Errorf("moderation rule %q: action must be mask or block", rules[i].Pattern)
+		}
+		if rules[i].compiled, err = regexp.Compile(rules[i].Pattern); err != nil {
+			return nil, fmt.Errorf("moderation rule %q: %w", rules[i].Pattern, err)
+		}
+	}
+
+	log.Printf("🧹 Loaded %d moderation rules from %s", len(rules), path)
+	return &RegexFilter{rules: rules}, nil
+}
+
+func (rf *RegexFilter) Name() string {
+	return "regex"
+}
+
+func (rf *RegexFilter) Filter(content string) (ModerationVerdict, error) {
+	for _, rule := range rf.rules {
+		if !rule.compiled.MatchString(content) {
+			continue
+		}
+		if rule.Action == "block" {
+			return ModerationVerdict{Blocked: true, Content: content, Reason: rule.Reason}, nil
+		}
+		content = rule.compiled.ReplaceAllStringFunc(content, func(match string) string {
+			return strings.Repeat("*", len([]rune(match)))
+		})
+	}
+	return ModerationVerdict{Content: content}, nil
+}
+
+// HTTPModerationFilter asks an external API, which receives
+// {"content": "..."} and answers {"flagged": bool, "reason": "..."}.
+type HTTPModerationFilter struct {
+	url    string
+	client *http.Client
+}
+
+func (hf *HTTPModerationFilter) Name() string {
+	return "http"
+}
+
+func (hf *HTTPModerationFilter) Filter(content string) (ModerationVerdict, error) {
+	body, err := json.Marshal(map[string]string{"content": content})
+	if err != nil {
+		return ModerationVerdict{}, err
+	}
+
+	resp, err := hf.client.Post(hf.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return ModerationVerdict{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ModerationVerdict{}, fmt.Errorf("moderation API returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Flagged bool   `json:"flagged"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return ModerationVerdict{}, err
+	}
+	return ModerationVerdict{Blocked: result.Flagged, Content: content, Reason: result.Reason}, nil
+}
+
+// ModerationService runs chat messages through the filter chain and counts
+// violations (masked or blocked messages) per user.
+type ModerationService struct {
+	filters        []ModerationFilter
+	metricsService *MetricsService
+	violations     map[string]int
+	mu             sync.Mutex
+}
+
+func NewModerationService(filters []ModerationFilter, metricsService *MetricsService) *ModerationService {
+	return &ModerationService{
+		filters:        filters,
+		metricsService: metricsService,
+		violations:     make(map[string]int),
+	}
+}
+
+// Moderate runs content through every filter. A failing filter is skipped
+// so an unavailable moderation API doesn't stop the chat.
+func (ms *ModerationService) Moderate(email, content string) ModerationVerdict {
+	verdict := ModerationVerdict{Content: content}
+	for _, filter := range ms.filters {
+		result, err := filter.Filter(verdict.Content)
+		if err != nil {
+			ms.metricsService.IncCounter("moderation_errors_total")
+			log.Printf("⚠️ Moderation filter %s failed: %v", filter.Name(), err)
+			continue
+		}
+		if result.Blocked {
+			if result.Reason == "" {
+				result.Reason = "Message blocked by " + filter.Name() + " filter"
+			}
+			ms.recordViolation(email, "moderation_blocked_total")
+			return result
+		}
+		verdict.Content = result.Content
+	}
+
+	if verdict.Content != content {
+		ms.recordViolation(email, "moderation_masked_total")
+	}
+	return verdict
+}
+
+func (ms *ModerationService) recordViolation(email, metric string) {
+	ms.metricsService.IncCounter(metric)
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.violations[email]++
+	log.Printf("🧹 Moderation violation by %s (%d total)", email, ms.violations[email])
+}
+
+// Violations returns a copy of the per-user violation counts.
+func (ms *ModerationService) Violations() map[string]int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	violations := make(map[string]int, len(ms.violations))
+	for email, count := range ms.violations {
+		violations[email] = count
+	}
+	return violations
+}
@@ -0,0 +1,466 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsFetchBatch is how many stored messages one history read pulls at once
+const natsFetchBatch = 256
+
+// natsToken matches the names usable as a subject token, stream or bucket
+// name as they are
+var natsToken = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// NATSService is a Store on NATS JetStream, for deployments that run NATS
+// instead of Redis. Messages are published to the subject
+// "<namespace>.lobby.<id>.messages" of one stream and history is read back
+// through a short-lived ordered consumer; keys and the lobby registry are
+// key-value buckets.
+type NATSService struct {
+	conn      *nats.Conn
+	js        jetstream.JetStream
+	ctx       context.Context
+	stream    jetstream.Stream
+	kv        jetstream.KeyValue
+	lobbies   jetstream.KeyValue
+	namespace string
+	cipher    *MessageCipher
+	ids       IDGenerator
+}
+
+// NewNATSService connects to the NATS server at url and creates the
+// namespace's stream and buckets if they don't exist yet. Messages are
+// sealed with cipher unless it is nil, and those without an ID get one from
+// ids.
+func NewNATSService(url, namespace string, cipher *MessageCipher, ids IDGenerator) *NATSService {
+	conn, err := nats.Connect(url,
+		nats.Name(config.ServiceName),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			log.Printf("⚠️ Disconnected from NATS: %v", err)
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			log.Printf("🔌 Reconnected to NATS at %s", conn.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		log.Fatalf("❌ Failed to connect to NATS at %s: %v", url, err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		log.Fatalf("❌ Failed to open JetStream: %v", err)
+	}
+
+	ns := &NATSService{
+		conn:      conn,
+		js:        js,
+		ctx:       context.Background(),
+		namespace: namespace,
+		cipher:    cipher,
+		ids:       ids,
+	}
+	ctx, cancel := context.WithTimeout(ns.ctx, config.NATSTimeout)
+	defer cancel()
+
+	name := natsName(namespace)
+	ns.stream, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        name + "_messages",
+		Description: "Chat messages of each lobby",
+		Subjects:    []string{ns.subject("*")},
+		Storage:     jetstream.FileStorage,
+	})
+	if err != nil {
+		log.Fatalf("❌ Failed to create the NATS message stream: %v", err)
+	}
+	ns.kv, err = js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{Bucket: name + "_kv", Storage: jetstream.FileStorage})
+	if err != nil {
+		log.Fatalf("❌ Failed to create the NATS key-value bucket: %v", err)
+	}
+	ns.lobbies, err = js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{Bucket: name + "_lobbies", Storage: jetstream.FileStorage})
+	if err != nil {
+		log.Fatalf("❌ Failed to create the NATS lobby registry: %v", err)
+	}
+
+	log.Printf("✅ Connected to NATS JetStream at %s", conn.ConnectedUrl())
+	return ns
+}
+
+func (ns *NATSService) Key(format string, args ...interface{}) string {
+	return ns.namespace + ":" + fmt.Sprintf(format, args...)
+}
+
+// subject is where the messages of a lobby are published; "*" matches
+// every lobby.
+func (ns *NATSService) subject(lobbyID string) string {
+	if lobbyID != "*" {
+		lobbyID = natsName(lobbyID)
+	}
+	return natsName(ns.namespace) + ".lobby." + lobbyID + ".messages"
+}
+
+// natsName keeps a name that NATS accepts in subjects, streams and buckets
+// and hex encodes any other.
+func natsName(name string) string {
+	if natsToken.MatchString(name) {
+		return name
+	}
+	return "x" + hex.EncodeToString([]byte(name))
+}
+
+// natsUnname reverses natsName. Only names NATS doesn't accept are hex
+// encoded, so a decoded name that it does accept was never encoded.
+func natsUnname(name string) string {
+	encoded, ok := strings.CutPrefix(name, "x")
+	if !ok {
+		return name
+	}
+	decoded, err := hex.DecodeString(encoded)
+	if err != nil || natsToken.Match(decoded) {
+		return name
+	}
+	return string(decoded)
+}
+
+// bucketKey encodes a store key, which may hold ':' or '@', into the
+// characters bucket keys allow.
+func bucketKey(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func (ns *NATSService) PushMessage(_ context.Context, msg models.Message) error {
+	msgJSON, err := json.Marshal(toRedisMessage(msg, ns.ids))
+	if err != nil {
+		return err
+	}
+	if msgJSON, err = ns.cipher.Seal(msgJSON); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ns.ctx, config.NATSTimeout)
+	defer cancel()
+	_, err = ns.js.Publish(ctx, ns.subject(msg.LobbyID), msgJSON)
+	return err
+}
+
+func (ns *NATSService) GetMessages(lobbyID string) ([]models.RedisMessage, error) {
+	return ns.GetMessagesRange(lobbyID, 0, -1)
+}
+
+func (ns *NATSService) GetMessagesRange(lobbyID string, start, stop int64) ([]models.RedisMessage, error) {
+	raw, err := ns.readMessages(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := lrangeBounds(int64(len(raw)), start, stop)
+	var messages []models.RedisMessage
+	for _, entry := range raw[from:to] {
+		msgJSON, err := ns.cipher.Open(entry.data)
+		if err != nil {
+			log.Printf("⚠️ Failed to decrypt message: %v", err)
+			continue
+		}
+		var msg models.RedisMessage
+		if err := json.Unmarshal(msgJSON, &msg); err != nil {
+			log.Printf("⚠️ Failed to unmarshal message: %v", err)
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// natsEntry is a stored message with its sequence number in the stream.
+type natsEntry struct {
+	seq  uint64
+	data []byte
+}
+
+// readMessages replays the lobby's subject from the start of the stream. It
+// reads as many messages as the stream held for the subject when it began,
+// so one published meanwhile is left for the next read.
+func (ns *NATSService) readMessages(lobbyID string) ([]natsEntry, error) {
+	ctx, cancel := context.WithTimeout(ns.ctx, config.NATSTimeout)
+	defer cancel()
+
+	subject := ns.subject(lobbyID)
+	info, err := ns.stream.Info(ctx, jetstream.WithSubjectFilter(subject))
+	if err != nil {
+		return nil, err
+	}
+	count := int(info.State.Subjects[subject])
+	if count == 0 {
+		return nil, nil
+	}
+
+	consumer, err := ns.stream.OrderedConsumer(ctx, jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{subject},
+		DeliverPolicy:  jetstream.DeliverAllPolicy,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if info := consumer.CachedInfo(); info != nil {
+			ns.stream.DeleteConsumer(ns.ctx, info.Name)
+		}
+	}()
+
+	raw := make([]natsEntry, 0, count)
+	for len(raw) < count {
+		batch, err := consumer.Fetch(min(count-len(raw), natsFetchBatch), jetstream.FetchContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		for msg := range batch.Messages() {
+			entry := natsEntry{data: msg.Data()}
+			if meta, err := msg.Metadata(); err == nil {
+				entry.seq = meta.Sequence.Stream
+			}
+			raw = append(raw, entry)
+		}
+		if err := batch.Error(); err != nil {
+			return nil, err
+		}
+	}
+	return raw, nil
+}
+
+func (ns *NATSService) GetMessagesSince(lobbyID string, seq int64) ([]models.RedisMessage, error) {
+	messages, err := ns.GetMessages(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var missed []models.RedisMessage
+	for _, msg := range messages {
+		if msg.Seq > seq {
+			missed = append(missed, msg)
+		}
+	}
+	return missed, nil
+}
+
+// TrimMessages purges the lobby's subject, keeping its newest keep
+// messages.
+func (ns *NATSService) TrimMessages(lobbyID string, keep int64) error {
+	ctx, cancel := context.WithTimeout(ns.ctx, config.NATSTimeout)
+	defer cancel()
+	opts := []jetstream.StreamPurgeOpt{jetstream.WithPurgeSubject(ns.subject(lobbyID))}
+	if keep > 0 {
+		opts = append(opts, jetstream.WithPurgeKeep(uint64(keep)))
+	}
+	return ns.stream.Purge(ctx, opts...)
+}
+
+// DeleteMessages deletes the messages from the stream by their sequence
+// numbers.
+func (ns *NATSService) DeleteMessages(lobbyID string, messageIDs []string) error {
+	raw, err := ns.readMessages(lobbyID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ns.ctx, config.NATSTimeout)
+	defer cancel()
+	for _, entry := range raw {
+		if entry.seq == 0 || !slices.Contains(messageIDs, storedMessageID(ns.cipher, entry.data)) {
+			continue
+		}
+		if err := ns.stream.DeleteMsg(ctx, entry.seq); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MessageLobbies lists the lobbies whose subjects hold messages.
+func (ns *NATSService) MessageLobbies() ([]string, error) {
+	ctx, cancel := context.WithTimeout(ns.ctx, config.NATSTimeout)
+	defer cancel()
+	info, err := ns.stream.Info(ctx, jetstream.WithSubjectFilter(ns.subject("*")))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, suffix := natsName(ns.namespace)+".lobby.", ".messages"
+	lobbyIDs := make([]string, 0, len(info.State.Subjects))
+	for subject := range info.State.Subjects {
+		lobbyIDs = append(lobbyIDs, natsUnname(strings.TrimSuffix(strings.TrimPrefix(subject, prefix), suffix)))
+	}
+	return lobbyIDs, nil
+}
+
+// SetWithTTL stores value at key; a zero ttl never expires. Expired keys are
+// dropped lazily on the next Get.
+func (ns *NATSService) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	entryJSON, err := json.Marshal(newExpiringValue(value, ttl))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ns.ctx, config.NATSTimeout)
+	defer cancel()
+	_, err = ns.kv.Put(ctx, bucketKey(key), entryJSON)
+	return err
+}
+
+func (ns *NATSService) Get(key string) (string, error) {
+	ctx, cancel := context.WithTimeout(ns.ctx, config.NATSTimeout)
+	defer cancel()
+	kvEntry, err := ns.kv.Get(ctx, bucketKey(key))
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return "", ErrKeyNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var entry expiringValue
+	if err := json.Unmarshal(kvEntry.Value(), &entry); err != nil {
+		return "", err
+	}
+	if entry.expired() {
+		ns.Delete(key)
+		return "", ErrKeyNotFound
+	}
+	return entry.Value, nil
+}
+
+// Take deletes the key only at the revision it read, so a caller that lost
+// the race to another Take finds it gone.
+func (ns *NATSService) Take(key string) (string, error) {
+	ctx, cancel := context.WithTimeout(ns.ctx, config.NATSTimeout)
+	defer cancel()
+	kvEntry, err := ns.kv.Get(ctx, bucketKey(key))
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return "", ErrKeyNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	err = ns.kv.Delete(ctx, bucketKey(key), jetstream.LastRevision(kvEntry.Revision()))
+	if errors.Is(err, jetstream.ErrKeyRevisionMismatch) {
+		return "", ErrKeyNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var entry expiringValue
+	if err := json.Unmarshal(kvEntry.Value(), &entry); err != nil {
+		return "", err
+	}
+	if entry.expired() {
+		return "", ErrKeyNotFound
+	}
+	return entry.Value, nil
+}
+
+func (ns *NATSService) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(ns.ctx, config.NATSTimeout)
+	defer cancel()
+	return ns.kv.Delete(ctx, bucketKey(key))
+}
+
+func (ns *NATSService) SetScore(key, member string, score float64) error {
+	return setJSONScore(ns, key, member, score)
+}
+
+func (ns *NATSService) TopScores(key string, n int) ([]ScoredMember, error) {
+	return topJSONScores(ns, key, n)
+}
+
+func (ns *NATSService) AppendStream(key, entry string, maxLen int64) error {
+	return appendJSONStream(ns, key, entry, maxLen)
+}
+
+func (ns *NATSService) ReadStream(key string, since, until time.Time) ([]string, error) {
+	return readJSONStream(ns, key, since, until)
+}
+
+func (ns *NATSService) PushInbox(key string, msg models.Message, maxLen int64) error {
+	return pushJSONInbox(ns, ns.cipher, ns.ids, key, msg, maxLen)
+}
+
+func (ns *NATSService) DrainInbox(key string) ([]models.RedisMessage, error) {
+	return drainJSONInbox(ns, ns.cipher, key)
+}
+
+func (ns *NATSService) SaveLobby(record models.LobbyRecord) error {
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ns.ctx, config.NATSTimeout)
+	defer cancel()
+	_, err = ns.lobbies.Put(ctx, bucketKey(record.ID), recordJSON)
+	return err
+}
+
+func (ns *NATSService) LoadLobbies() ([]models.LobbyRecord, error) {
+	ctx, cancel := context.WithTimeout(ns.ctx, config.NATSTimeout)
+	defer cancel()
+	keys, err := ns.lobbies.Keys(ctx)
+	if errors.Is(err, jetstream.ErrNoKeysFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []models.LobbyRecord
+	for _, key := range keys {
+		kvEntry, err := ns.lobbies.Get(ctx, key)
+		if err != nil {
+			log.Printf("⚠️ Failed to load lobby record %s: %v", key, err)
+			continue
+		}
+		var record models.LobbyRecord
+		if err := json.Unmarshal(kvEntry.Value(), &record); err != nil {
+			log.Printf("⚠️ Failed to unmarshal lobby record %s: %v", key, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (ns *NATSService) DeleteLobby(lobbyID string) error {
+	ctx, cancel := context.WithTimeout(ns.ctx, config.NATSTimeout)
+	defer cancel()
+	return ns.lobbies.Delete(ctx, bucketKey(lobbyID))
+}
+
+// Ping asks JetStream for the account's usage, which needs a live
+// connection and a responsive server.
+func (ns *NATSService) Ping(ctx context.Context) error {
+	_, err := ns.js.AccountInfo(ctx)
+	return err
+}
+
+// Degraded reports whether the connection is down while the client
+// reconnects.
+func (ns *NATSService) Degraded() bool {
+	return !ns.conn.IsConnected()
+}
+
+func (ns *NATSService) Close() {
+	if err := ns.conn.Drain(); err != nil {
+		ns.conn.Close()
+	}
+}
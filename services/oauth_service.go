@@ -0,0 +1,189 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownProvider  = errors.New("unknown or disabled OAuth provider")
+	ErrEmailNotVerified = errors.New("provider did not return a verified email")
+)
+
+type OAuthProvider struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	Scopes       []string
+	// fetchEmail returns the verified email for an access token
+	fetchEmail func(ctx context.Context, client *http.Client, accessToken string) (string, error)
+}
+
+type OAuthService struct {
+	providers       map[string]*OAuthProvider
+	httpClient      *http.Client
+	redirectBaseURL string
+}
+
+// NewOAuthService builds the enabled providers; callbacks are expected under
+// redirectBaseURL + "/auth/{provider}/callback".
+func NewOAuthService(redirectBaseURL string) *OAuthService {
+	providers := make(map[string]*OAuthProvider)
+
+	if config.GoogleClientID != "" {
+		providers["google"] = &OAuthProvider{
+			Name:         "google",
+			ClientID:     config.GoogleClientID,
+			ClientSecret: config.GoogleClientSecret,
+			AuthURL:      "https://accounts.google.com/o/oauth2/v2/auth",
+			TokenURL:     "https://oauth2.googleapis.com/token",
+			Scopes:       []string{"openid", "email"},
+			fetchEmail:   fetchGoogleEmail,
+		}
+	}
+
+	if config.GitHubClientID != "" {
+		providers["github"] = &OAuthProvider{
+			Name:         "github",
+			ClientID:     config.GitHubClientID,
+			ClientSecret: config.GitHubClientSecret,
+			AuthURL:      "https://github.com/login/oauth/authorize",
+			TokenURL:     "https://github.com/login/oauth/access_token",
+			Scopes:       []string{"user:email"},
+			fetchEmail:   fetchGitHubEmail,
+		}
+	}
+
+	return &OAuthService{
+		providers:       providers,
+		httpClient:      &http.Client{Timeout: 10 * time.Second},
+		redirectBaseURL: redirectBaseURL,
+	}
+}
+
+func (oas *OAuthService) GetProvider(name string) (*OAuthProvider, error) {
+	provider, exists := oas.providers[name]
+	if !exists {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+func (oas *OAuthService) EnabledProviders() []string {
+	names := make([]string, 0, len(oas.providers))
+	for name := range oas.providers {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (oas *OAuthService) AuthCodeURL(provider *OAuthProvider, state string) string {
+	params := url.Values{}
+	params.Set("client_id", provider.ClientID)
+	params.Set("redirect_uri", oas.redirectURL(provider))
+	params.Set("response_type", "code")
+	params.Set("scope", strings.Join(provider.Scopes, " "))
+	params.Set("state", state)
+	return provider.AuthURL + "?" + params.Encode()
+}
+
+// Exchange trades an authorization code for an access token and resolves
+// the user's verified email with it.
+func (oas *OAuthService) Exchange(ctx context.Context, provider *OAuthProvider, code string) (string, error) {
+	form := url.Values{}
+	form.Set("client_id", provider.ClientID)
+	form.Set("client_secret", provider.ClientSecret)
+	form.Set("code", code)
+	form.Set("grant_type", "authorization_code")
+	form.Set("redirect_uri", oas.redirectURL(provider))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := doJSON(oas.httpClient, req, &token); err != nil {
+		return "", fmt.Errorf("token exchange: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("token exchange: %s", token.Error)
+	}
+
+	return provider.fetchEmail(ctx, oas.httpClient, token.AccessToken)
+}
+
+func doJSON(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, req.URL.Host)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func fetchGoogleEmail(ctx context.Context, client *http.Client, accessToken string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://openidconnect.googleapis.com/v1/userinfo", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	var userInfo struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := doJSON(client, req, &userInfo); err != nil {
+		return "", err
+	}
+	if userInfo.Email == "" || !userInfo.EmailVerified {
+		return "", ErrEmailNotVerified
+	}
+	return userInfo.Email, nil
+}
+
+func fetchGitHubEmail(ctx context.Context, client *http.Client, accessToken string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/user/emails", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := doJSON(client, req, &emails); err != nil {
+		return "", err
+	}
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			return email.Email, nil
+		}
+	}
+	return "", ErrEmailNotVerified
+}
+
+func (oas *OAuthService) redirectURL(provider *OAuthProvider) string {
+	return fmt.Sprintf("%s/auth/%s/callback", oas.redirectBaseURL, provider.Name)
+}
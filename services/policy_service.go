@@ -0,0 +1,169 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Action is a privileged operation, named "<area>.<verb>" so policies can
+// grant a whole area with "<area>.*".
+type Action string
+
+const (
+	ActionAdminConfig      Action = "admin.config"
+	ActionAdminBranding    Action = "admin.branding"
+	ActionAdminWebhooks    Action = "admin.webhooks"
+	ActionAdminBots        Action = "admin.bots"
+	ActionAdminAPIKeys     Action = "admin.api_keys"
+	ActionAdminModeration  Action = "admin.moderation"
+	ActionAdminAnnounce    Action = "admin.announce"
+	ActionAdminEmoji       Action = "admin.emoji"
+	ActionAdminAudit       Action = "admin.audit"
+	ActionAdminDashboard   Action = "admin.dashboard"
+	ActionLobbySearch      Action = "lobby.search"
+	ActionLobbyExport      Action = "lobby.export"
+	ActionLobbyHistory     Action = "lobby.history"
+	ActionLobbyImport      Action = "lobby.import"
+	ActionLobbyCreate      Action = "lobby.create"
+	ActionLobbySessions    Action = "lobby.sessions"
+	ActionLobbyTop         Action = "lobby.top"
+	ActionLobbyIdeas       Action = "lobby.ideas"
+	ActionLobbyThreads     Action = "lobby.threads"
+	ActionLobbyEmoji       Action = "lobby.emoji"
+	ActionLobbySettings    Action = "lobby.settings"
+	ActionLobbySummary     Action = "lobby.summary"
+	ActionLobbyArchive     Action = "lobby.archive"
+	ActionLobbySchedule    Action = "lobby.schedule"
+	ActionLobbyUnread      Action = "lobby.unread"
+	ActionLobbyPresence    Action = "lobby.presence"
+	ActionLobbyJoin        Action = "lobby.join"
+	ActionLobbyActions     Action = "lobby.actions"
+	ActionLobbyStatus      Action = "lobby.status"
+	ActionAttachmentUpload Action = "attachment.upload"
+	ActionAttachmentDelete Action = "attachment.delete"
+	ActionBotPost          Action = "bot.post"
+	ActionMessageSend      Action = "message.send"
+	ActionMessageReact     Action = "message.react"
+	ActionMessageVote      Action = "message.vote"
+	ActionPresenceUpdate   Action = "presence.update"
+	ActionMessageRead      Action = "message.read"
+
+	// Lobby management, granted by lobby role rather than connection role
+	ActionManageEnd          Action = "manage.end"
+	ActionManageKick         Action = "manage.kick"
+	ActionManagePin          Action = "manage.pin"
+	ActionManageMaxUsers     Action = "manage.max_users"
+	ActionManageRoles        Action = "manage.roles"
+	ActionManageSystemEvents Action = "manage.system_events"
+	ActionManageGuests       Action = "manage.guests"
+	ActionManageEmoji        Action = "manage.emoji"
+	ActionManageBudget       Action = "manage.budget"
+	ActionManageSettings     Action = "manage.settings"
+	ActionManageSlowMode     Action = "manage.slow_mode"
+	ActionManageInvites      Action = "manage.invites"
+	ActionManageActions      Action = "manage.actions"
+	ActionManageImport       Action = "manage.import"
+)
+
+// DefaultPolicy lets logged-in users, guests and user API keys read lobbies,
+// those they are members of, and upload attachments; anonymous callers get
+// nothing of it, and the admin API needs the admin key or an admin API key.
+func DefaultPolicy() map[models.Role][]Action {
+	return map[models.Role][]Action{
+		models.RoleAdmin:     {"*"},
+		models.RoleBot:       {ActionBotPost},
+		models.RoleUser:      {ActionMessageSend, ActionMessageReact, ActionMessageVote, ActionMessageRead, ActionPresenceUpdate, "lobby.*", "attachment.*"},
+		models.RoleAnonymous: {},
+		models.RoleGuest:     {ActionMessageSend, ActionMessageReact, ActionMessageVote, ActionMessageRead, ActionPresenceUpdate, "lobby.*"},
+
+		models.RoleOwner:       {"manage.*"},
+		models.RoleModerator:   {ActionManageKick, ActionManagePin, ActionManageSlowMode, ActionManageInvites, ActionManageActions},
+		models.RoleParticipant: {},
+	}
+}
+
+// PolicyService answers role × action questions from a grant matrix.
+type PolicyService struct {
+	grants map[models.Role][]Action
+}
+
+func NewPolicyService(grants map[models.Role][]Action) *PolicyService {
+	return &PolicyService{grants: grants}
+}
+
+// LoadPolicy reads a JSON grant matrix such as
+// {"user": ["message.send", "lobby.*"], "admin": ["*"]}. An empty path
+// returns DefaultPolicy.
+func LoadPolicy(path string) (map[models.Role][]Action, error) {
+	if path == "" {
+		return DefaultPolicy(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var grants map[models.Role][]Action
+	if err := json.Unmarshal(data, &grants); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+
+	log.Printf("🛡️ Loaded authorization policy from %s (%d roles)", path, len(grants))
+	return grants, nil
+}
+
+func (ps *PolicyService) Allowed(role models.Role, action Action) bool {
+	for _, grant := range ps.grants[role] {
+		if grant == "*" || grant == action {
+			return true
+		}
+		if area, ok := strings.CutSuffix(string(grant), ".*"); ok && strings.HasPrefix(string(action), area+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// FrameAction maps an incoming WebSocket frame type to the action it needs.
+// Frames without a type are chat messages.
+func FrameAction(frameType models.MessageType) Action {
+	switch frameType {
+	case "", models.MessageTypeChat, models.MessageTypeReply, models.MessageTypeIdea,
+		models.MessageTypeChannelCreate, models.MessageTypeChannelLeave:
+		return ActionMessageSend
+	case models.MessageTypeEndLobby:
+		return ActionManageEnd
+	case models.MessageTypeKick:
+		return ActionManageKick
+	case models.MessageTypePin, models.MessageTypeUnpin:
+		return ActionManagePin
+	case models.MessageTypeSetMaxUsers:
+		return ActionManageMaxUsers
+	case models.MessageTypeSetRole:
+		return ActionManageRoles
+	case models.MessageTypeSetSystemEvents:
+		return ActionManageSystemEvents
+	case models.MessageTypeSetGuestAccess:
+		return ActionManageGuests
+	case models.MessageTypeSetBudget:
+		return ActionManageBudget
+	case models.MessageTypeSetSlowMode:
+		return ActionManageSlowMode
+	case models.MessageTypeReact:
+		return ActionMessageReact
+	case models.MessageTypeVote:
+		return ActionMessageVote
+	case models.MessageTypeMessageRead:
+		return ActionMessageRead
+	case models.MessageTypeVisibility, models.MessageTypePing, models.MessageTypeHistoryAck, models.MessageTypeDeliveryAck:
+		return ActionPresenceUpdate
+	case models.MessageTypeJoin, models.MessageTypeLeave:
+		return ActionLobbyJoin
+	default:
+		return Action("frame." + string(frameType))
+	}
+}
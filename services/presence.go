@@ -0,0 +1,68 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// setVisibility applies a client's visibility hint. Showing the tab again
+// brings an away user back online right away; hiding it only turns them
+// away after AwayAfterHidden, on a later presence check.
+func (ls *LobbyService) setVisibility(lobby *models.Lobby, actor string, visibility models.Visibility) error {
+	if visibility != models.VisibilityVisible && visibility != models.VisibilityHidden {
+		return fmt.Errorf("visibility must be %s or %s", models.VisibilityVisible, models.VisibilityHidden)
+	}
+	lobby.SetHidden(actor, visibility == models.VisibilityHidden, time.Now())
+	ls.refreshPresence(lobby)
+	return nil
+}
+
+// refreshPresence broadcasts a presence_changed for every user who went
+// away or came back.
+func (ls *LobbyService) refreshPresence(lobby *models.Lobby) {
+	changes := lobby.RefreshPresence(config.AwayAfterHidden, time.Now())
+	users := make([]string, 0, len(changes))
+	for email := range changes {
+		users = append(users, email)
+	}
+	sort.Strings(users)
+
+	for _, email := range users {
+		presence := changes[email]
+		log.Printf("👀 %s is now %s in lobby %s", email, presence, lobby.ID)
+		presenceMsg := ls.systemMessage(lobby, models.SystemActionPresence, email, fmt.Sprintf("%s is %s", email, presence))
+		presenceMsg.Target = email
+		presenceMsg.Presence = presence
+		ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: presenceMsg})
+	}
+}
+
+// releaseIdleUsers frees the seats of users who have had no connection for
+// IdleTimeout, so users turned away from a full lobby can log in to it.
+func (ls *LobbyService) releaseIdleUsers(lobby *models.Lobby) {
+	released, newOwner := lobby.ReleaseIdleUsers(config.IdleTimeout, time.Now())
+	if len(released) == 0 {
+		return
+	}
+
+	for _, email := range released {
+		log.Printf("⌛ Released the seat of %s in lobby %s after %s idle", email, lobby.ID, config.IdleTimeout)
+		ls.dropInbox(lobby.ID, email)
+		timedOutMsg := ls.systemMessage(lobby, models.SystemActionUserTimedOut, email, fmt.Sprintf("%s was away too long and left the lobby", email))
+		timedOutMsg.Target = email
+		ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: timedOutMsg})
+	}
+	if newOwner != "" {
+		log.Printf("🎖️ %s is the new owner of lobby %s", newOwner, lobby.ID)
+		roleMsg := ls.systemMessage(lobby, models.SystemActionRoleChange, newOwner, fmt.Sprintf("%s is now the owner", newOwner))
+		roleMsg.Target = newOwner
+		roleMsg.Role = models.RoleOwner
+		ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: roleMsg})
+	}
+	ls.saveLobby(lobby)
+	ls.admitWaiting(lobby.TenantID)
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ProbeService periodically drives a full login → connect → broadcast round
+// trip through the server's own WebSocket endpoint and records the latency.
+type ProbeService struct {
+	lobbyService   *LobbyService
+	sessionService *SessionService
+	metricsService *MetricsService
+	wsURL          string
+}
+
+// NewProbeService probes the WebSocket endpoint at wsURL, which must be
+// served by the same hub as lobbyService.
+func NewProbeService(lobbyService *LobbyService, sessionService *SessionService, metricsService *MetricsService, wsURL string) *ProbeService {
+	return &ProbeService{
+		lobbyService:   lobbyService,
+		sessionService: sessionService,
+		metricsService: metricsService,
+		wsURL:          wsURL,
+	}
+}
+
+func (ps *ProbeService) Run() {
+	ticker := time.NewTicker(config.ProbeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		latency, err := ps.probe()
+		if err != nil {
+			log.Printf("❌ Probe failed: %v", err)
+			ps.metricsService.IncCounter("chat_probe_failures_total")
+			continue
+		}
+
+		log.Printf("🩺 Probe round trip: %s", latency)
+		ps.metricsService.IncCounter("chat_probe_success_total")
+		ps.metricsService.SetGauge("chat_probe_latency_seconds", latency.Seconds())
+	}
+}
+
+func (ps *ProbeService) probe() (time.Duration, error) {
+	// Log the probe user into its dedicated lobby
+	lobby := ps.lobbyService.GetOrCreateInternalLobby(config.ProbeLobbyID)
+	lobby.AddUser(config.ProbeEmail)
+	ticket, err := ps.sessionService.CreateConnectTicket(config.ProbeEmail, lobby.ID)
+	if err != nil {
+		return 0, fmt.Errorf("connect ticket: %w", err)
+	}
+
+	query := url.Values{}
+	query.Set("ticket", ticket)
+	query.Set("last_seq", fmt.Sprintf("%d", lobby.GetLastSeq()))
+
+	dialer := websocket.Dialer{HandshakeTimeout: config.ProbeTimeout}
+	if strings.HasPrefix(ps.wsURL, "wss://localhost") {
+		// The loopback probe can't match a certificate issued for the
+		// public host name
+		dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	conn, _, err := dialer.Dial(ps.wsURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(config.ProbeTimeout)
+	conn.SetReadDeadline(deadline)
+	conn.SetWriteDeadline(deadline)
+
+	// The client is only registered once the welcome message arrives
+	if err := waitFor(conn, func(msg models.Message) bool {
+		return msg.SystemAction != nil && *msg.SystemAction == models.SystemActionWelcome
+	}); err != nil {
+		return 0, err
+	}
+
+	content := fmt.Sprintf("probe-%d", time.Now().UnixNano())
+	sentAt := time.Now()
+	if err := conn.WriteJSON(models.Message{Content: content}); err != nil {
+		return 0, fmt.Errorf("write: %w", err)
+	}
+
+	// Wait for our own message to come back through the broadcast path
+	if err := waitFor(conn, func(msg models.Message) bool {
+		return msg.Type == models.MessageTypeChat && msg.Content == content
+	}); err != nil {
+		return 0, err
+	}
+	return time.Since(sentAt), nil
+}
+
+func waitFor(conn *websocket.Conn, match func(models.Message) bool) error {
+	for {
+		var msg models.Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+		if match(msg) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidDisplayName = fmt.Errorf("display_name must be 1-%d printable characters without @ or <>\"'", config.MaxDisplayNameLength)
+	ErrInvalidAvatarURL   = fmt.Errorf("avatar_url must be an absolute http(s) URL of at most %d characters", config.MaxAvatarURLLength)
+)
+
+// ProfileService keeps the display names and avatars users show to other
+// lobby members, and the member IDs that stand in for their emails.
+type ProfileService struct {
+	store Store
+	key   []byte
+
+	mu    sync.RWMutex
+	cache map[string]models.Profile
+}
+
+func NewProfileService(store Store) *ProfileService {
+	key := []byte(config.MemberIDSecret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+		log.Printf("⚠️ MEMBER_ID_SECRET not set, member IDs will change on restart")
+	}
+	return &ProfileService{
+		store: store,
+		key:   key,
+		cache: make(map[string]models.Profile),
+	}
+}
+
+// MemberID is the stable handle other members see for email. It is keyed
+// with the server's secret, so it can't be reversed by hashing guesses.
+func (ps *ProfileService) MemberID(email string) string {
+	mac := hmac.New(sha256.New, ps.key)
+	mac.Write([]byte(email))
+	return "m_" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// DefaultProfile shows a user without a profile by the part of their email
+// before the @; guest and bot names have none and are shown as they are.
+func DefaultProfile(email string) models.Profile {
+	name, _, _ := strings.Cut(email, "@")
+	return models.Profile{DisplayName: name}
+}
+
+// Get returns the user's profile, or the default if they have none or the
+// store is unavailable.
+func (ps *ProfileService) Get(email string) models.Profile {
+	ps.mu.RLock()
+	profile, cached := ps.cache[email]
+	ps.mu.RUnlock()
+	if cached {
+		return profile
+	}
+
+	profileJSON, err := ps.store.Get(ps.profileKey(email))
+	if err != nil {
+		if err != ErrKeyNotFound {
+			log.Printf("⚠️ Failed to load profile for %s: %v", email, err)
+			return DefaultProfile(email)
+		}
+		profile = DefaultProfile(email)
+	} else if err := json.Unmarshal([]byte(profileJSON), &profile); err != nil {
+		log.Printf("⚠️ Invalid profile stored for %s: %v", email, err)
+		profile = DefaultProfile(email)
+	}
+
+	ps.mu.Lock()
+	ps.cache[email] = profile
+	ps.mu.Unlock()
+	return profile
+}
+
+// SetProfile validates and stores the user's profile. A ttl of 0 keeps it
+// until it is replaced; guest profiles expire with the guest's session.
+func (ps *ProfileService) SetProfile(email string, profile models.Profile, ttl time.Duration) (models.Profile, error) {
+	profile.DisplayName = strings.TrimSpace(profile.DisplayName)
+	if !validDisplayName(profile.DisplayName) {
+		return profile, ErrInvalidDisplayName
+	}
+	if profile.AvatarURL != "" {
+		parsed, err := url.Parse(profile.AvatarURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || len(profile.AvatarURL) > config.MaxAvatarURLLength {
+			return profile, ErrInvalidAvatarURL
+		}
+		// Stored re-encoded, so clients get a URL that can't close the
+		// attribute it is put in
+		profile.AvatarURL = parsed.String()
+		if strings.ContainsAny(profile.AvatarURL, htmlSpecials) {
+			return profile, ErrInvalidAvatarURL
+		}
+	}
+
+	profile.UpdatedAt = time.Now()
+	profileJSON, err := json.Marshal(profile)
+	if err != nil {
+		return profile, err
+	}
+	if err := ps.store.SetWithTTL(ps.profileKey(email), profileJSON, ttl); err != nil {
+		log.Printf("❌ Failed to save profile for %s: %v", email, err)
+		return profile, err
+	}
+
+	ps.mu.Lock()
+	ps.cache[email] = profile
+	ps.mu.Unlock()
+	log.Printf("🪪 Profile updated for %s: %q", email, profile.DisplayName)
+	return profile, nil
+}
+
+// htmlSpecials are the characters that could break out of the markup a
+// client puts a profile in.
+const htmlSpecials = `<>"'`
+
+func validDisplayName(name string) bool {
+	length := utf8.RuneCountInString(name)
+	if length == 0 || length > config.MaxDisplayNameLength {
+		return false
+	}
+	// An @ would let a display name pass for an email or a mention, and
+	// markup characters have no place in a name
+	if strings.Contains(name, "@") || strings.ContainsAny(name, htmlSpecials) {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ps *ProfileService) profileKey(email string) string {
+	return ps.store.Key("profile:%s", email)
+}
+
+// PublicMessage is the form of msg sent to a lobby's clients: every user it
+// names appears under their member ID, with their profile in Profiles, and
+// members' emails in system notices are replaced by display names.
+func (ls *LobbyService) PublicMessage(msg models.Message) models.Message {
+	names := ls.newPublicNames()
+
+	if msg.Username != "" {
+		msg.Username = names.ID(msg.Username)
+		msg.DisplayName = names.Profiles[msg.Username].DisplayName
+		msg.AvatarURL = names.Profiles[msg.Username].AvatarURL
+	}
+	msg.Target = names.ID(msg.Target)
+	msg.UserList = names.IDs(msg.UserList)
+	msg.Mentions = names.IDs(msg.Mentions)
+	msg.Joined = names.IDs(msg.Joined)
+	msg.Left = names.IDs(msg.Left)
+	msg.Guests = names.IDs(msg.Guests)
+	msg.Away = names.IDs(msg.Away)
+	if msg.Roles != nil {
+		roles := make(map[string]models.Role, len(msg.Roles))
+		for email, role := range msg.Roles {
+			roles[names.ID(email)] = role
+		}
+		msg.Roles = roles
+	}
+	publicChannel := func(channel models.Channel) models.Channel {
+		channel.Members = names.IDs(channel.Members)
+		channel.CreatedBy = names.ID(channel.CreatedBy)
+		return channel
+	}
+	if msg.Channel != nil {
+		channel := publicChannel(*msg.Channel)
+		msg.Channel = &channel
+	}
+	if msg.Channels != nil {
+		channels := make([]models.Channel, len(msg.Channels))
+		for i, channel := range msg.Channels {
+			channels[i] = publicChannel(channel)
+		}
+		msg.Channels = channels
+	}
+	msg.ActionItems = names.ActionItems(msg.ActionItems)
+	if msg.EmojiPack != nil {
+		// Who added an emoji is for the REST API, not the lobby
+		pack := make(map[string]models.Emoji, len(msg.EmojiPack))
+		for name, emoji := range msg.EmojiPack {
+			emoji.CreatedBy = ""
+			pack[name] = emoji
+		}
+		msg.EmojiPack = pack
+	}
+	if len(names.Profiles) > 0 {
+		msg.Profiles = names.Profiles
+	}
+	if msg.History != nil {
+		msg.History = ls.PublicMessages(msg.History)
+	}
+
+	if msg.Type == models.MessageTypeSystemAction && msg.Content != "" {
+		if lobby := ls.GetLobby(msg.LobbyID); lobby != nil {
+			names.know(lobby.GetMemberEmails())
+		}
+		msg.Content = redactEmails(msg.Content, names.names)
+	}
+	return msg
+}
+
+// PublicMessages is PublicMessage for each of msgs.
+func (ls *LobbyService) PublicMessages(msgs []models.Message) []models.Message {
+	if msgs == nil {
+		return nil
+	}
+	public := make([]models.Message, len(msgs))
+	for i, msg := range msgs {
+		public[i] = ls.PublicMessage(msg)
+	}
+	return public
+}
+
+// redactEmails replaces each email in content with its display name,
+// longest first so an email is never cut short by one it ends with.
+func redactEmails(content string, names map[string]string) string {
+	emails := make([]string, 0, len(names))
+	for email := range names {
+		if strings.Contains(email, "@") {
+			emails = append(emails, email)
+		}
+	}
+	if len(emails) == 0 {
+		return content
+	}
+	sort.Slice(emails, func(i, j int) bool { return len(emails[i]) > len(emails[j]) })
+
+	pairs := make([]string, 0, 2*len(emails))
+	for _, email := range emails {
+		pairs = append(pairs, email, names[email])
+	}
+	return strings.NewReplacer(pairs...).Replace(content)
+}
+
+// MemberID returns the ID a member is shown to other clients by.
+func (ls *LobbyService) MemberID(email string) string {
+	return ls.profileService.MemberID(email)
+}
+
+// memberEmail resolves a member ID named by a client back to the member's
+// email. Emails are accepted as they are, for clients that still send them.
+func (ls *LobbyService) memberEmail(lobby *models.Lobby, target string) string {
+	if !strings.HasPrefix(target, "m_") {
+		return target
+	}
+	for _, email := range lobby.GetMemberEmails() {
+		if ls.profileService.MemberID(email) == target {
+			return email
+		}
+	}
+	return target
+}
+
+// UpdateProfile saves a user's profile and, if they are in a lobby, tells
+// the other members, whose clients pick it up from the notice's Profiles.
+func (ls *LobbyService) UpdateProfile(ctx context.Context, email string, profile models.Profile, ttl time.Duration) (models.Profile, error) {
+	saved, err := ls.profileService.SetProfile(email, profile, ttl)
+	if err != nil {
+		return saved, err
+	}
+
+	lobby := ls.FindLobbyByUserEmail(email)
+	if lobby == nil {
+		return saved, nil
+	}
+	notice := ls.systemMessage(lobby, models.SystemActionProfile, email, fmt.Sprintf("%s updated their profile", email))
+	if err := ls.Broadcast(ctx, BroadcastMessage{LobbyID: lobby.ID, Message: notice}); err != nil && !errors.Is(err, ErrLobbyNotFound) {
+		log.Printf("⚠️ Failed to announce profile change of %s: %v", email, err)
+	}
+	return saved, nil
+}
+
+// Profile returns the profile the user shows to other lobby members.
+func (ls *LobbyService) Profile(email string) models.Profile {
+	return ls.profileService.Get(email)
+}
@@ -0,0 +1,165 @@
+package services
+
+import "chat-integrated/models"
+
+// PublicNames names users by member ID, the way clients see them, for the
+// frames and REST answers that would otherwise carry their emails. It
+// collects the profiles of those it named in Profiles; one is used per
+// answer.
+type PublicNames struct {
+	ps       *ProfileService
+	Profiles map[string]models.Profile
+	// names maps emails to display names, for redacting system notices
+	names map[string]string
+}
+
+func (ls *LobbyService) newPublicNames() *PublicNames {
+	return &PublicNames{
+		ps:       ls.profileService,
+		Profiles: make(map[string]models.Profile),
+		names:    make(map[string]string),
+	}
+}
+
+// PublicNames returns the names of a REST answer about the lobby or ended
+// session lobbyID, whose members' emails are redacted from system notices.
+func (ls *LobbyService) PublicNames(lobbyID string) *PublicNames {
+	names := ls.newPublicNames()
+	if record, err := ls.FindSession(lobbyID); err == nil {
+		names.know(record.Members)
+	}
+	return names
+}
+
+// ID returns email's member ID, or "" for no email.
+func (pn *PublicNames) ID(email string) string {
+	if email == "" {
+		return ""
+	}
+	id := pn.ps.MemberID(email)
+	if _, seen := pn.Profiles[id]; !seen {
+		profile := pn.ps.Get(email)
+		pn.Profiles[id] = profile
+		pn.names[email] = profile.DisplayName
+	}
+	return id
+}
+
+// IDs returns the member IDs of emails, keeping a nil list nil.
+func (pn *PublicNames) IDs(emails []string) []string {
+	if emails == nil {
+		return nil
+	}
+	ids := make([]string, len(emails))
+	for i, email := range emails {
+		ids[i] = pn.ID(email)
+	}
+	return ids
+}
+
+// DisplayName returns the name email is shown by.
+func (pn *PublicNames) DisplayName(email string) string {
+	return pn.Profiles[pn.ID(email)].DisplayName
+}
+
+// know adds emails to those redacted from system notices.
+func (pn *PublicNames) know(emails []string) {
+	for _, email := range emails {
+		if _, named := pn.names[email]; !named {
+			pn.names[email] = pn.ps.Get(email).DisplayName
+		}
+	}
+}
+
+// Records names the senders of stored messages by member ID and redacts
+// the emails in their system notices.
+func (pn *PublicNames) Records(msgs []models.RedisMessage) []models.RedisMessage {
+	if msgs == nil {
+		return nil
+	}
+	public := make([]models.RedisMessage, len(msgs))
+	for i, msg := range msgs {
+		msg.Username = pn.ID(msg.Username)
+		if msg.SystemAction != "" && msg.Content != "" {
+			msg.Content = redactEmails(msg.Content, pn.names)
+		}
+		public[i] = msg
+	}
+	return public
+}
+
+// ActionItems names the authors and taggers of action items by member ID.
+func (pn *PublicNames) ActionItems(items []models.ActionItem) []models.ActionItem {
+	if items == nil {
+		return nil
+	}
+	public := make([]models.ActionItem, len(items))
+	for i, item := range items {
+		item.Username = pn.ID(item.Username)
+		item.TaggedBy = pn.ID(item.TaggedBy)
+		public[i] = item
+	}
+	return public
+}
+
+// Ideas names the authors of idea cards by member ID.
+func (pn *PublicNames) Ideas(ideas []models.Idea) []models.Idea {
+	if ideas == nil {
+		return nil
+	}
+	public := make([]models.Idea, len(ideas))
+	for i, idea := range ideas {
+		idea.Username = pn.ID(idea.Username)
+		public[i] = idea
+	}
+	return public
+}
+
+// Summary names the participants of an ended session by member ID.
+func (pn *PublicNames) Summary(summary models.LobbySummary) models.LobbySummary {
+	summary.Participants = pn.IDs(summary.Participants)
+	if summary.MessagesByUser != nil {
+		counts := make(map[string]int, len(summary.MessagesByUser))
+		for email, count := range summary.MessagesByUser {
+			counts[pn.ID(email)] = count
+		}
+		summary.MessagesByUser = counts
+	}
+	summary.ActionItems = pn.ActionItems(summary.ActionItems)
+	summary.Ideas = pn.Ideas(summary.Ideas)
+	summary.Transcript = pn.Records(summary.Transcript)
+	return summary
+}
+
+// Sessions names the members of a session chain by member ID.
+func (pn *PublicNames) Sessions(chain []SessionSummary) []SessionSummary {
+	public := make([]SessionSummary, len(chain))
+	for i, session := range chain {
+		session.Members = pn.IDs(session.Members)
+		public[i] = session
+	}
+	return public
+}
+
+// TopMessages names the senders of ranked messages by member ID.
+func (pn *PublicNames) TopMessages(top []TopMessage) []TopMessage {
+	public := make([]TopMessage, len(top))
+	for i, msg := range top {
+		msg.Username = pn.ID(msg.Username)
+		public[i] = msg
+	}
+	return public
+}
+
+// SearchResults names the senders of search matches and their context by
+// member ID.
+func (pn *PublicNames) SearchResults(results []SearchResult) []SearchResult {
+	public := make([]SearchResult, len(results))
+	for i, result := range results {
+		result.Match = pn.Records([]models.RedisMessage{result.Match})[0]
+		result.Before = pn.Records(result.Before)
+		result.After = pn.Records(result.After)
+		public[i] = result
+	}
+	return public
+}
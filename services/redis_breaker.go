@@ -0,0 +1,146 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrStoreUnavailable is returned while the Redis circuit breaker is open.
+var ErrStoreUnavailable = errors.New("store unavailable: redis circuit breaker is open")
+
+// bufferedPush is a message write held back during a Redis outage.
+type bufferedPush struct {
+	key   string
+	value []byte
+}
+
+// redisBreaker opens after RedisBreakerThreshold consecutive failed calls,
+// or on the first failed message write. While it is open calls fail fast,
+// message writes are buffered in memory and a background loop pings Redis
+// with exponential backoff, draining the buffer once Redis answers.
+type redisBreaker struct {
+	mu       sync.Mutex
+	failures int
+	open     bool
+	buffered []bufferedPush
+}
+
+// call runs a Redis command through the circuit breaker.
+func (rs *RedisService) call(command func() error) error {
+	if rs.Degraded() {
+		return ErrStoreUnavailable
+	}
+
+	err := command()
+	if err == nil || errors.Is(err, redis.Nil) {
+		rs.breaker.mu.Lock()
+		rs.breaker.failures = 0
+		rs.breaker.mu.Unlock()
+		return err
+	}
+
+	rs.breaker.mu.Lock()
+	rs.breaker.failures++
+	tripped := rs.breaker.failures >= config.RedisBreakerThreshold
+	rs.breaker.mu.Unlock()
+	if tripped {
+		rs.trip(err)
+	}
+	return err
+}
+
+// Degraded reports whether the breaker is open and writes are buffered.
+func (rs *RedisService) Degraded() bool {
+	rs.breaker.mu.Lock()
+	defer rs.breaker.mu.Unlock()
+	return rs.breaker.open
+}
+
+// trip opens the breaker and starts reconnecting, unless it is already open.
+func (rs *RedisService) trip(cause error) {
+	rs.breaker.mu.Lock()
+	defer rs.breaker.mu.Unlock()
+
+	if rs.breaker.open {
+		return
+	}
+	rs.breaker.open = true
+	log.Printf("🔌 Redis circuit breaker open, entering degraded mode: %v", cause)
+	go rs.reconnect()
+}
+
+// bufferPush holds a message write until Redis is back. A failed write opens
+// the breaker right away so later writes queue behind it and stay in order.
+// When the buffer is full the oldest write is dropped.
+func (rs *RedisService) bufferPush(key string, value []byte, cause error) {
+	rs.trip(cause)
+
+	rs.breaker.mu.Lock()
+	defer rs.breaker.mu.Unlock()
+
+	if len(rs.breaker.buffered) >= config.RedisOutageBuffer {
+		rs.breaker.buffered = rs.breaker.buffered[1:]
+		log.Printf("⚠️ Redis outage buffer full (%d), dropped the oldest message", config.RedisOutageBuffer)
+	}
+	rs.breaker.buffered = append(rs.breaker.buffered, bufferedPush{key: key, value: value})
+}
+
+// reconnect pings Redis with exponential backoff until it answers and the
+// buffered writes are drained, then closes the breaker.
+func (rs *RedisService) reconnect() {
+	delay := config.RedisRetryBaseDelay
+	for {
+		select {
+		case <-rs.done:
+			return
+		case <-time.After(delay):
+		}
+
+		err := rs.client.Ping(rs.ctx).Err()
+		if err == nil {
+			err = rs.drainBuffered()
+		}
+		if err == nil {
+			log.Printf("✅ Redis is back, circuit breaker closed")
+			return
+		}
+
+		delay = min(2*delay, config.RedisRetryMaxDelay)
+		log.Printf("🔌 Redis still unavailable, retrying in %s: %v", delay, err)
+	}
+}
+
+// drainBuffered writes the buffered messages in order. Writes buffered
+// while draining are picked up too; the breaker closes once none are left.
+func (rs *RedisService) drainBuffered() error {
+	for {
+		rs.breaker.mu.Lock()
+		batch := rs.breaker.buffered
+		rs.breaker.buffered = nil
+		if len(batch) == 0 {
+			rs.breaker.open = false
+			rs.breaker.failures = 0
+			rs.breaker.mu.Unlock()
+			return nil
+		}
+		rs.breaker.mu.Unlock()
+
+		log.Printf("📤 Draining %d buffered messages to Redis", len(batch))
+		for i, push := range batch {
+			if err := rs.client.RPush(rs.ctx, push.key, push.value).Err(); err != nil {
+				rs.breaker.mu.Lock()
+				rs.breaker.buffered = append(batch[i:], rs.breaker.buffered...)
+				if overflow := len(rs.breaker.buffered) - config.RedisOutageBuffer; overflow > 0 {
+					rs.breaker.buffered = rs.breaker.buffered[overflow:]
+				}
+				rs.breaker.mu.Unlock()
+				return err
+			}
+		}
+	}
+}
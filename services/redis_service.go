@@ -7,76 +7,137 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisService struct {
-	client *redis.Client
-	ctx    context.Context
+	client    redis.UniversalClient
+	cluster   bool
+	failover  bool
+	ctx       context.Context
+	namespace string
+	cipher    *MessageCipher
+	ids       IDGenerator
+	breaker   redisBreaker
+	done      chan struct{}
 }
 
-func NewRedisService() *RedisService {
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     config.RedisAddr,
-		Password: "",
-		DB:       config.RedisDB,
-	})
-
-	ctx := context.Background()
-	_, err := rdb.Ping(ctx).Result()
+// NewRedisService connects to Redis as topology says, retrying with
+// exponential backoff. All keys are prefixed with namespace so several hubs
+// can share one Redis database. If Redis is still down after
+// RedisConnectAttempts the service starts in degraded mode and keeps
+// reconnecting in the background. Messages are sealed with cipher unless it
+// is nil, and those without an ID get one from ids.
+func NewRedisService(topology RedisTopology, namespace string, cipher *MessageCipher, ids IDGenerator) (*RedisService, error) {
+	rdb, err := topology.newClient()
 	if err != nil {
-		log.Fatalf("❌ Failed to connect to Redis: %v", err)
+		return nil, err
+	}
+	if config.Tracing {
+		rdb.AddHook(redisTracing{addr: topology.addr()})
 	}
+	rs := &RedisService{
+		client:    rdb,
+		cluster:   topology.Mode == "cluster",
+		failover:  topology.Mode == "sentinel" || topology.Mode == "cluster",
+		ctx:       context.Background(),
+		namespace: namespace,
+		cipher:    cipher,
+		ids:       ids,
+		done:      make(chan struct{}),
+	}
+
+	delay := config.RedisRetryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := rdb.Ping(rs.ctx).Err()
+		if err == nil {
+			log.Printf("✅ Connected to Redis successfully (%s)", topology)
+			return rs, nil
+		}
+		if attempt == config.RedisConnectAttempts {
+			log.Printf("⚠️ Failed to connect to Redis after %d attempts: %v", attempt, err)
+			rs.trip(err)
+			return rs, nil
+		}
 
-	log.Println("✅ Connected to Redis successfully")
-	return &RedisService{
-		client: rdb,
-		ctx:    ctx,
+		log.Printf("🔌 Redis not reachable (attempt %d/%d), retrying in %s: %v", attempt, config.RedisConnectAttempts, delay, err)
+		time.Sleep(delay)
+		delay = min(2*delay, config.RedisRetryMaxDelay)
 	}
 }
 
-func (rs *RedisService) PushMessage(username, content, lobbyID string, timestamp time.Time) error {
-	messageID := fmt.Sprintf("msg_%s_%d_%s", lobbyID, timestamp.Unix(), username)
-
-	redisMsg := models.RedisMessage{
-		Username:  username,
-		Content:   content,
-		LobbyID:   lobbyID,
-		Timestamp: timestamp,
-		MessageID: messageID,
+// Key builds a namespaced key, e.g. Key("lobby:%s:messages", id). On a
+// cluster the lobby ID of per-lobby keys is a hash tag.
+func (rs *RedisService) Key(format string, args ...interface{}) string {
+	if rs.cluster {
+		format = hashTagged(format)
 	}
+	return rs.namespace + ":" + fmt.Sprintf(format, args...)
+}
 
-	msgJSON, err := json.Marshal(redisMsg)
+func (rs *RedisService) PushMessage(ctx context.Context, msg models.Message) error {
+	msgJSON, err := json.Marshal(toRedisMessage(msg, rs.ids))
 	if err != nil {
 		log.Printf("❌ Failed to marshal message to JSON: %v", err)
 		return err
 	}
+	if msgJSON, err = rs.cipher.Seal(msgJSON); err != nil {
+		log.Printf("❌ Failed to encrypt message: %v", err)
+		return err
+	}
 
-	// Push to lobby-specific queue
-	queueKey := fmt.Sprintf("chat:lobby:%s:messages", lobbyID)
-	err = rs.client.RPush(rs.ctx, queueKey, msgJSON).Err()
+	// Push to lobby-specific queue; during an outage the message waits in
+	// the breaker's buffer and counts as stored
+	queueKey := rs.Key("lobby:%s:messages", msg.LobbyID)
+	err = rs.call(func() error {
+		return rs.retryFailover(func() error {
+			return rs.client.RPush(ctx, queueKey, msgJSON).Err()
+		})
+	})
 	if err != nil {
-		log.Printf("❌ Failed to push message to Redis: %v", err)
-		return err
+		log.Printf("⚠️ Redis unavailable, buffered message for lobby %s: %v", msg.LobbyID, err)
+		rs.bufferPush(queueKey, msgJSON, err)
+		return nil
 	}
 
-	log.Printf("✅ Message pushed to Redis queue [%s]: %s - %s", lobbyID, username, content)
+	log.Printf("✅ Message pushed to Redis queue [%s]: %s - %s", msg.LobbyID, msg.Username, msg.Content)
 	return nil
 }
 
 func (rs *RedisService) GetMessages(lobbyID string) ([]models.RedisMessage, error) {
-	queueKey := fmt.Sprintf("chat:lobby:%s:messages", lobbyID)
-	messages, err := rs.client.LRange(rs.ctx, queueKey, 0, -1).Result()
+	return rs.GetMessagesRange(lobbyID, 0, -1)
+}
+
+// GetMessagesRange returns the stored messages between the LRANGE indexes
+// start and stop; negative indexes count from the newest message.
+func (rs *RedisService) GetMessagesRange(lobbyID string, start, stop int64) ([]models.RedisMessage, error) {
+	queueKey := rs.Key("lobby:%s:messages", lobbyID)
+	var messages []string
+	err := rs.call(func() error {
+		return rs.retryFailover(func() (err error) {
+			messages, err = rs.client.LRange(rs.ctx, queueKey, start, stop).Result()
+			return err
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	var redisMessages []models.RedisMessage
 	for _, msgStr := range messages {
+		msgJSON, err := rs.cipher.Open([]byte(msgStr))
+		if err != nil {
+			log.Printf("⚠️ Failed to decrypt message: %v", err)
+			continue
+		}
 		var msg models.RedisMessage
-		if err := json.Unmarshal([]byte(msgStr), &msg); err != nil {
+		if err := json.Unmarshal(msgJSON, &msg); err != nil {
 			log.Printf("⚠️ Failed to unmarshal message: %v", err)
 			continue
 		}
@@ -86,6 +147,268 @@ func (rs *RedisService) GetMessages(lobbyID string) ([]models.RedisMessage, erro
 	return redisMessages, nil
 }
 
+// GetMessagesSince returns the stored messages of a lobby with a sequence
+// number greater than seq.
+func (rs *RedisService) GetMessagesSince(lobbyID string, seq int64) ([]models.RedisMessage, error) {
+	messages, err := rs.GetMessages(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var missed []models.RedisMessage
+	for _, msg := range messages {
+		if msg.Seq > seq {
+			missed = append(missed, msg)
+		}
+	}
+	return missed, nil
+}
+
+func (rs *RedisService) TrimMessages(lobbyID string, keep int64) error {
+	queueKey := rs.Key("lobby:%s:messages", lobbyID)
+	return rs.call(func() error {
+		if keep <= 0 {
+			return rs.client.Del(rs.ctx, queueKey).Err()
+		}
+		return rs.client.LTrim(rs.ctx, queueKey, -keep, -1).Err()
+	})
+}
+
+// DeleteMessages removes each entry of the lobby's list holding one of the
+// messages with LREM, so messages pushed meanwhile are kept.
+func (rs *RedisService) DeleteMessages(lobbyID string, messageIDs []string) error {
+	queueKey := rs.Key("lobby:%s:messages", lobbyID)
+	return rs.call(func() error {
+		entries, err := rs.client.LRange(rs.ctx, queueKey, 0, -1).Result()
+		if err != nil {
+			return err
+		}
+		pipe := rs.client.Pipeline()
+		for _, entry := range entries {
+			if slices.Contains(messageIDs, storedMessageID(rs.cipher, []byte(entry))) {
+				pipe.LRem(rs.ctx, queueKey, 1, entry)
+			}
+		}
+		if pipe.Len() == 0 {
+			return nil
+		}
+		_, err = pipe.Exec(rs.ctx)
+		return err
+	})
+}
+
+// MessageLobbies scans for the keys of the lobbies' message lists, on
+// every master of a cluster.
+func (rs *RedisService) MessageLobbies() ([]string, error) {
+	prefix, suffix := rs.Key("lobby:"), ":messages"
+	var mu sync.Mutex
+	var lobbyIDs []string
+	scan := func(ctx context.Context, client *redis.Client) error {
+		iter := client.Scan(ctx, 0, prefix+"*"+suffix, 100).Iterator()
+		for iter.Next(ctx) {
+			id := strings.TrimSuffix(strings.TrimPrefix(iter.Val(), prefix), suffix)
+			mu.Lock()
+			lobbyIDs = append(lobbyIDs, strings.Trim(id, "{}"))
+			mu.Unlock()
+		}
+		return iter.Err()
+	}
+	err := rs.call(func() error {
+		lobbyIDs = nil
+		if cluster, ok := rs.client.(*redis.ClusterClient); ok {
+			return cluster.ForEachMaster(rs.ctx, scan)
+		}
+		return scan(rs.ctx, rs.client.(*redis.Client))
+	})
+	return lobbyIDs, err
+}
+
+func (rs *RedisService) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return rs.call(func() error {
+		return rs.client.Set(rs.ctx, key, value, ttl).Err()
+	})
+}
+
+// Get returns the value stored at key, or ErrKeyNotFound if it does not exist.
+func (rs *RedisService) Get(key string) (string, error) {
+	var value string
+	err := rs.call(func() (err error) {
+		value, err = rs.client.Get(rs.ctx, key).Result()
+		return err
+	})
+	if err == redis.Nil {
+		return "", ErrKeyNotFound
+	}
+	return value, err
+}
+
+// Take reads and deletes the key in one GETDEL.
+func (rs *RedisService) Take(key string) (string, error) {
+	var value string
+	err := rs.call(func() (err error) {
+		value, err = rs.client.GetDel(rs.ctx, key).Result()
+		return err
+	})
+	if err == redis.Nil {
+		return "", ErrKeyNotFound
+	}
+	return value, err
+}
+
+func (rs *RedisService) Delete(key string) error {
+	return rs.call(func() error {
+		return rs.client.Del(rs.ctx, key).Err()
+	})
+}
+
+func (rs *RedisService) SetScore(key, member string, score float64) error {
+	return rs.call(func() error {
+		return rs.client.ZAdd(rs.ctx, key, redis.Z{Score: score, Member: member}).Err()
+	})
+}
+
+// TopScores reads the set with ZREVRANGE, which ranks equal scores by
+// member in reverse.
+func (rs *RedisService) TopScores(key string, n int) ([]ScoredMember, error) {
+	var ranked []redis.Z
+	err := rs.call(func() (err error) {
+		ranked, err = rs.client.ZRevRangeWithScores(rs.ctx, key, 0, int64(n)-1).Result()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	members := make([]ScoredMember, 0, len(ranked))
+	for _, z := range ranked {
+		member, _ := z.Member.(string)
+		members = append(members, ScoredMember{Member: member, Score: z.Score})
+	}
+	return members, nil
+}
+
+// AppendStream adds the entry with XADD, trimming approximately to maxLen.
+func (rs *RedisService) AppendStream(key, entry string, maxLen int64) error {
+	return rs.call(func() error {
+		return rs.client.XAdd(rs.ctx, &redis.XAddArgs{
+			Stream: key,
+			MaxLen: maxLen,
+			Approx: true,
+			Values: map[string]interface{}{"entry": entry},
+		}).Err()
+	})
+}
+
+// ReadStream reads the entries with XRANGE; stream IDs start with the
+// millisecond they were added.
+func (rs *RedisService) ReadStream(key string, since, until time.Time) ([]string, error) {
+	start, stop := "-", "+"
+	if !since.IsZero() {
+		start = strconv.FormatInt(since.UnixMilli(), 10)
+	}
+	if !until.IsZero() {
+		stop = strconv.FormatInt(until.UnixMilli(), 10)
+	}
+	var messages []redis.XMessage
+	err := rs.call(func() (err error) {
+		messages, err = rs.client.XRange(rs.ctx, key, start, stop).Result()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]string, 0, len(messages))
+	for _, message := range messages {
+		if entry, ok := message.Values["entry"].(string); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// PushInbox appends the sealed message with RPUSH and caps the list with
+// LTRIM.
+func (rs *RedisService) PushInbox(key string, msg models.Message, maxLen int64) error {
+	msgJSON, err := json.Marshal(toRedisMessage(msg, rs.ids))
+	if err != nil {
+		return err
+	}
+	if msgJSON, err = rs.cipher.Seal(msgJSON); err != nil {
+		return err
+	}
+	return rs.call(func() error {
+		_, err := rs.client.TxPipelined(rs.ctx, func(pipe redis.Pipeliner) error {
+			pipe.RPush(rs.ctx, key, msgJSON)
+			if maxLen > 0 {
+				pipe.LTrim(rs.ctx, key, -maxLen, -1)
+			}
+			return nil
+		})
+		return err
+	})
+}
+
+// DrainInbox reads and deletes the list in one MULTI, so no message pushed
+// meanwhile is lost.
+func (rs *RedisService) DrainInbox(key string) ([]models.RedisMessage, error) {
+	var entries *redis.StringSliceCmd
+	err := rs.call(func() error {
+		_, err := rs.client.TxPipelined(rs.ctx, func(pipe redis.Pipeliner) error {
+			entries = pipe.LRange(rs.ctx, key, 0, -1)
+			pipe.Del(rs.ctx, key)
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return openInbox(rs.cipher, entries.Val()), nil
+}
+
+// SaveLobby stores the lobby record in the namespace's lobby registry hash.
+func (rs *RedisService) SaveLobby(record models.LobbyRecord) error {
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return rs.call(func() error {
+		return rs.client.HSet(rs.ctx, rs.Key("lobbies"), record.ID, recordJSON).Err()
+	})
+}
+
+func (rs *RedisService) LoadLobbies() ([]models.LobbyRecord, error) {
+	var entries map[string]string
+	err := rs.call(func() (err error) {
+		entries, err = rs.client.HGetAll(rs.ctx, rs.Key("lobbies")).Result()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]models.LobbyRecord, 0, len(entries))
+	for id, recordJSON := range entries {
+		var record models.LobbyRecord
+		if err := json.Unmarshal([]byte(recordJSON), &record); err != nil {
+			log.Printf("⚠️ Failed to unmarshal lobby record %s: %v", id, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (rs *RedisService) DeleteLobby(lobbyID string) error {
+	return rs.call(func() error {
+		return rs.client.HDel(rs.ctx, rs.Key("lobbies"), lobbyID).Err()
+	})
+}
+
+func (rs *RedisService) Ping(ctx context.Context) error {
+	return rs.client.Ping(ctx).Err()
+}
+
 func (rs *RedisService) Close() {
+	close(rs.done)
 	rs.client.Close()
 }
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisTopology says how RedisService reaches Redis. Mode is "single" for
+// the server at Addr, "sentinel" for the master named MasterName, found
+// through the sentinels at Addrs, or "cluster" for the cluster whose seed
+// nodes are Addrs. A cluster has no databases, so DB is ignored there.
+type RedisTopology struct {
+	Mode       string
+	Addr       string
+	DB         int
+	MasterName string
+	Addrs      []string
+}
+
+// SplitAddrs reads a comma separated address list, e.g. from
+// REDIS_SENTINEL_ADDRS.
+func SplitAddrs(list string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// newClient opens the client for the topology. It doesn't wait for Redis
+// to answer.
+func (t RedisTopology) newClient() (redis.UniversalClient, error) {
+	switch t.Mode {
+	case "single", "":
+		return redis.NewClient(&redis.Options{Addr: t.Addr, DB: t.DB}), nil
+	case "sentinel":
+		if t.MasterName == "" || len(t.Addrs) == 0 {
+			return nil, errors.New("sentinel mode needs a master name and sentinel addresses")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{MasterName: t.MasterName, SentinelAddrs: t.Addrs, DB: t.DB}), nil
+	case "cluster":
+		if len(t.Addrs) == 0 {
+			return nil, errors.New("cluster mode needs node addresses")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{Addrs: t.Addrs}), nil
+	default:
+		return nil, fmt.Errorf("unknown Redis mode %q (expected single, sentinel or cluster)", t.Mode)
+	}
+}
+
+// addr is the address spans and logs name: the server, or the first
+// sentinel or seed node.
+func (t RedisTopology) addr() string {
+	if t.Mode == "sentinel" || t.Mode == "cluster" {
+		return t.Addrs[0]
+	}
+	return t.Addr
+}
+
+// String describes the topology for logs.
+func (t RedisTopology) String() string {
+	switch t.Mode {
+	case "sentinel":
+		return fmt.Sprintf("master %s via %d sentinels", t.MasterName, len(t.Addrs))
+	case "cluster":
+		return fmt.Sprintf("cluster of %d seed nodes", len(t.Addrs))
+	default:
+		return t.Addr
+	}
+}
+
+// hashTagged wraps the lobby ID of a per-lobby key format in a hash tag,
+// so a cluster keeps every key of a lobby on one slot:
+// "lobby:%s:messages" becomes "lobby:{%s}:messages".
+func hashTagged(format string) string {
+	if rest, ok := strings.CutPrefix(format, "lobby:%s"); ok {
+		return "lobby:{%s}" + rest
+	}
+	return format
+}
+
+// failoverErrorPrefixes are the replies of a Redis that refused a command
+// while a master is being replaced or a cluster is resharding.
+var failoverErrorPrefixes = []string{"READONLY", "LOADING", "MASTERDOWN", "CLUSTERDOWN", "TRYAGAIN"}
+
+// isFailoverError reports whether err means the command didn't run
+// because of a failover: Redis refused it, or no connection could be made.
+// Such a command is safe to send again, even a write.
+func isFailoverError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		for _, prefix := range failoverErrorPrefixes {
+			if strings.HasPrefix(redisErr.Error(), prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retryFailover runs command again while it fails with a failover error,
+// backing off from RedisFailoverRetryDelay, up to RedisFailoverRetries
+// times. A sentinel failover or a cluster slot migration then costs a few
+// seconds instead of tripping the breaker. A single server has nothing to
+// fail over to, so its commands aren't retried.
+func (rs *RedisService) retryFailover(command func() error) error {
+	delay := config.RedisFailoverRetryDelay
+	err := command()
+	for attempt := 1; rs.failover && attempt <= config.RedisFailoverRetries && isFailoverError(err); attempt++ {
+		log.Printf("🔁 Redis failing over (retry %d/%d in %s): %v", attempt, config.RedisFailoverRetries, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+		err = command()
+	}
+	return err
+}
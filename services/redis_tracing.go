@@ -0,0 +1,66 @@
+package services
+
+import (
+	"chat-integrated/telemetry"
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redisTracing is the go-redis hook that runs every command and pipeline
+// in a client span, named after the command. Keys and values are left out
+// of the span: they carry emails and message content.
+type redisTracing struct {
+	addr string
+}
+
+func (rt redisTracing) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (rt redisTracing) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := rt.start(ctx, strings.ToUpper(cmd.Name()))
+		defer span.End()
+		err := next(ctx, cmd)
+		recordRedisError(span, err)
+		return err
+	}
+}
+
+func (rt redisTracing) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := rt.start(ctx, "PIPELINE")
+		defer span.End()
+		span.SetAttributes(attribute.Int("db.operation.batch.size", len(cmds)))
+		err := next(ctx, cmds)
+		recordRedisError(span, err)
+		return err
+	}
+}
+
+func (rt redisTracing) start(ctx context.Context, operation string) (context.Context, trace.Span) {
+	host, port, _ := net.SplitHostPort(rt.addr)
+	return telemetry.Tracer.Start(ctx, "redis "+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system.name", "redis"),
+		attribute.String("db.operation.name", operation),
+		attribute.String("server.address", host),
+		attribute.String("server.port", port),
+	))
+}
+
+// recordRedisError marks the span failed, except for a missing key, which
+// is an answer rather than a failure.
+func recordRedisError(span trace.Span, err error) {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
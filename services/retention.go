@@ -0,0 +1,149 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"errors"
+	"log"
+	"time"
+)
+
+// retention returns how long and how many of the newest messages a lobby
+// keeps, from its overrides or the server defaults; 0 keeps them all.
+func retention(seconds, messages int) (time.Duration, int) {
+	maxAge, maxMessages := config.RetentionMaxAge, config.RetentionMaxMessages
+	if seconds > 0 {
+		maxAge = time.Duration(seconds) * time.Second
+	}
+	if messages > 0 {
+		maxMessages = messages
+	}
+	return maxAge, maxMessages
+}
+
+// pruneHistory applies a live lobby's retention to its stored and in-memory
+// history. It runs on the lobby's worker, so no message is pushed between
+// reading the history and trimming it.
+func (ls *LobbyService) pruneHistory(lobby *models.Lobby, now time.Time) {
+	settings := lobby.GetSettings()
+	maxAge, maxMessages := retention(settings.RetentionSeconds, settings.RetentionMessages)
+	if maxAge <= 0 && maxMessages <= 0 {
+		return
+	}
+
+	stored, err := ls.pruneStored(lobby.ID, maxAge, maxMessages, now)
+	if err != nil {
+		log.Printf("⚠️ Failed to prune the stored history of lobby %s: %v", lobby.ID, err)
+	}
+	var cutoff time.Time
+	if maxAge > 0 {
+		cutoff = now.Add(-maxAge)
+	}
+	inMemory := lobby.PruneHistory(cutoff, maxMessages)
+	if stored > 0 || inMemory > 0 {
+		ls.auditPrune(lobby.ID, stored, inMemory, maxAge, maxMessages)
+	}
+}
+
+// RunRetention prunes the stored messages of ended sessions every
+// config.RetentionInterval, by their archived settings or the server
+// defaults. Live lobbies prune their own history on their workers.
+func (ls *LobbyService) RunRetention() {
+	if config.RetentionInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(config.RetentionInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		lobbyIDs, err := ls.store.MessageLobbies()
+		if err != nil {
+			log.Printf("⚠️ Failed to list stored lobbies for retention: %v", err)
+			continue
+		}
+		for _, lobbyID := range lobbyIDs {
+			if ls.GetLobby(lobbyID) != nil {
+				continue
+			}
+			record, err := ls.FindSession(lobbyID)
+			if err != nil && !errors.Is(err, ErrUnknownSession) {
+				log.Printf("⚠️ Failed to load the archived settings of lobby %s: %v", lobbyID, err)
+				continue
+			}
+
+			if record.EphemeralSeconds > 0 {
+				ls.expireArchived(lobbyID, now)
+			}
+
+			maxAge, maxMessages := retention(record.RetentionSeconds, record.RetentionMessages)
+			stored, err := ls.pruneStored(lobbyID, maxAge, maxMessages, now)
+			if err != nil {
+				log.Printf("⚠️ Failed to prune the stored history of lobby %s: %v", lobbyID, err)
+				continue
+			}
+			if stored > 0 {
+				ls.auditPrune(lobbyID, stored, 0, maxAge, maxMessages)
+			}
+		}
+	}
+}
+
+// expireArchived deletes the stored messages of a session that ended in
+// ephemeral mode once they expire.
+func (ls *LobbyService) expireArchived(lobbyID string, now time.Time) {
+	expired, err := ls.expiredStored(lobbyID, now)
+	if err != nil || len(expired) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(expired))
+	for _, msg := range expired {
+		ids = append(ids, msg.MessageID)
+	}
+	if err := ls.store.DeleteMessages(lobbyID, ids); err != nil {
+		log.Printf("⚠️ Failed to delete %d expired messages of lobby %s: %v", len(ids), lobbyID, err)
+		return
+	}
+	log.Printf("⏳ %d messages expired in ended session %s", len(ids), lobbyID)
+}
+
+// pruneStored trims a lobby's stored messages to the newest maxMessages
+// sent within maxAge of now, returning how many it removed.
+func (ls *LobbyService) pruneStored(lobbyID string, maxAge time.Duration, maxMessages int, now time.Time) (int, error) {
+	if maxAge <= 0 && maxMessages <= 0 {
+		return 0, nil
+	}
+	stored, err := ls.store.GetMessages(lobbyID)
+	if err != nil {
+		return 0, err
+	}
+
+	keep := len(stored)
+	if maxMessages > 0 {
+		keep = min(keep, maxMessages)
+	}
+	if maxAge > 0 {
+		cutoff := now.Add(-maxAge)
+		for keep > 0 && stored[len(stored)-keep].Timestamp.Before(cutoff) {
+			keep--
+		}
+	}
+	if keep == len(stored) {
+		return 0, nil
+	}
+	return len(stored) - keep, ls.store.TrimMessages(lobbyID, int64(keep))
+}
+
+// auditPrune records a pruning pass in the audit log.
+func (ls *LobbyService) auditPrune(lobbyID string, stored, inMemory int, maxAge time.Duration, maxMessages int) {
+	ls.auditService.Record(models.AuditEvent{
+		Action:  models.AuditHistoryPruned,
+		LobbyID: lobbyID,
+		Actor:   models.AuditActorSystem,
+		Details: map[string]interface{}{
+			"stored":       stored,
+			"memory":       inMemory,
+			"max_age":      maxAge.String(),
+			"max_messages": maxMessages,
+		},
+	})
+}
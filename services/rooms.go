@@ -0,0 +1,151 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"errors"
+	"log"
+	"time"
+)
+
+var (
+	ErrNoRooms       = errors.New("this connection can't join other lobbies")
+	ErrNotJoined     = errors.New("you haven't joined that lobby")
+	ErrGuestRoom     = errors.New("guests can only follow the lobbies they are in")
+	ErrLeaveOwnLobby = errors.New("you can't leave the lobby of your connection")
+	ErrNotMember     = errors.New("you aren't a member of that lobby; join it with an invite")
+)
+
+// JoinRoom attaches a connection to another lobby its user is a member
+// of, so one connection can follow several, as a facilitator follows the
+// rooms of a workshop. Seats are only taken at login, where the tenant,
+// queue and schedule are checked, or with an invite (JoinRoomInvited); a
+// user who isn't a member gets ErrNotMember. The lobby's welcome and
+// history follow on the connection as for a new one.
+func (ls *LobbyService) JoinRoom(conn *models.Client, lobbyID string) error {
+	if conn.Rooms == nil {
+		return ErrNoRooms
+	}
+	if lobbyID == conn.LobbyID || conn.Rooms.Get(lobbyID) != nil {
+		return nil
+	}
+
+	lobby := ls.GetLobby(lobbyID)
+	if lobby == nil || lobby.Internal {
+		return ErrLobbyNotFound
+	}
+	if !lobby.IsUserInLobby(conn.Email) {
+		if conn.Role == models.RoleGuest {
+			return ErrGuestRoom
+		}
+		return ErrNotMember
+	}
+
+	room := &models.Client{
+		Email:         conn.Email,
+		LobbyID:       lobby.ID,
+		Conn:          conn.Conn,
+		Send:          make(chan models.Message, 256),
+		JoinedAt:      time.Now(),
+		Role:          conn.Role,
+		Profile:       conn.Profile,
+		Encoding:      conn.Encoding,
+		RequestID:     conn.RequestID,
+		RemoteIP:      conn.RemoteIP,
+		UserAgent:     conn.UserAgent,
+		BatchedReplay: conn.BatchedReplay,
+		BatchedWrites: conn.BatchedWrites,
+	}
+	if !conn.Rooms.Add(room) {
+		return nil
+	}
+	go forwardRoom(conn.Rooms, room)
+	ls.Register(room)
+	log.Printf("🚪 [%s] %s joined lobby %s on the connection of lobby %s", conn.RequestID, conn.Email, lobby.ID, conn.LobbyID)
+	return nil
+}
+
+// JoinRoomInvited seats the connection's user in the lobby of an invite,
+// as JoinInvited does at login, and joins it. A ban still keeps them out,
+// and guests can only follow the lobbies they are in.
+func (ls *LobbyService) JoinRoomInvited(conn *models.Client, invite models.Invite, redeem func() error) error {
+	if conn.Rooms == nil {
+		return ErrNoRooms
+	}
+	if conn.Role == models.RoleGuest {
+		return ErrGuestRoom
+	}
+	lobby := ls.GetLobby(invite.LobbyID)
+	if lobby == nil || lobby.Internal {
+		return ErrLobbyNotFound
+	}
+
+	if !lobby.IsUserInLobby(conn.Email) {
+		if lobby.IsBanned(conn.Email) {
+			log.Printf("🚫 Kicked user tried to rejoin lobby %s with an invite: %s", lobby.ID, conn.Email)
+			return ErrKickedFromLobby
+		}
+		if err := redeem(); err != nil {
+			return err
+		}
+		lobby.AddUser(conn.Email)
+		ls.saveLobby(lobby)
+		ls.worker(lobby.ID)
+		ls.audit(lobby, models.AuditInviteRedeemed, conn.Email, "", map[string]string{
+			"invite_id":  invite.ID,
+			"created_by": invite.CreatedBy,
+		})
+		log.Printf("🎟️ %s took a seat in lobby %s with invite %s from lobby %s", conn.Email, lobby.ID, invite.ID, conn.LobbyID)
+	}
+	return ls.JoinRoom(conn, lobby.ID)
+}
+
+// LeaveRoom detaches a connection from a lobby it joined. The user keeps
+// their seat, as after a disconnect.
+func (ls *LobbyService) LeaveRoom(conn *models.Client, lobbyID string) error {
+	if lobbyID == conn.LobbyID {
+		return ErrLeaveOwnLobby
+	}
+	room := ls.Room(conn, lobbyID)
+	if room == nil || !conn.Rooms.Remove(room) {
+		return ErrNotJoined
+	}
+	ls.Unregister(room)
+	log.Printf("🚪 [%s] %s left lobby %s on the connection of lobby %s", conn.RequestID, conn.Email, lobbyID, conn.LobbyID)
+	return nil
+}
+
+// LeaveRooms detaches a closing connection from every lobby it joined.
+func (ls *LobbyService) LeaveRooms(conn *models.Client) {
+	if conn.Rooms == nil {
+		return
+	}
+	for _, room := range conn.Rooms.Close() {
+		ls.Unregister(room)
+	}
+}
+
+// Room returns the connection's client in a lobby: the connection itself
+// for its own lobby or an empty ID, the joined lobby's client, or nil if it
+// didn't join the lobby.
+func (ls *LobbyService) Room(conn *models.Client, lobbyID string) *models.Client {
+	if lobbyID == "" || lobbyID == conn.LobbyID {
+		return conn
+	}
+	if conn.Rooms == nil {
+		return nil
+	}
+	return conn.Rooms.Get(lobbyID)
+}
+
+// forwardRoom hands a joined lobby's frames to the connection's writer
+// until the lobby drops the room's client or the connection goes away.
+func forwardRoom(rooms *models.Rooms, room *models.Client) {
+	defer rooms.Remove(room)
+	for msg := range room.Send {
+		select {
+		case rooms.Out <- msg:
+		case <-rooms.Done:
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"errors"
+	"testing"
+)
+
+// connection is a user's connection to their own lobby, able to follow
+// others.
+func connection(t *testing.T, ls *LobbyService, email, tenantID string, role models.Role) *models.Client {
+	t.Helper()
+	lobby, _, err := ls.JoinLobby(email, tenantID)
+	if err != nil {
+		t.Fatalf("JoinLobby(%s, %s) = %v", email, tenantID, err)
+	}
+	conn := &models.Client{Email: email, LobbyID: lobby.ID, Role: role, Rooms: models.NewRooms()}
+	t.Cleanup(func() { ls.LeaveRooms(conn) })
+	return conn
+}
+
+func TestJoinRoomNeedsMembershipOrInvite(t *testing.T) {
+	store := NewMemoryStore("test", &ULIDs{})
+	ls := newTestLobbyService(t, store)
+	invites := NewInviteService(store, NewAuditService(store, ""))
+
+	alice := connection(t, ls, "alice@acme.test", "acme", models.RoleUser)
+	mallory := connection(t, ls, "mallory@evil.test", "evil", models.RoleUser)
+	bob := connection(t, ls, "bob@evil.test", "evil", models.RoleUser)
+	if alice.LobbyID == mallory.LobbyID {
+		t.Fatalf("tenants share lobby %s", alice.LobbyID)
+	}
+
+	// A lobby of another tenant can't be followed into without a seat
+	if err := ls.JoinRoom(mallory, alice.LobbyID); !errors.Is(err, ErrNotMember) {
+		t.Errorf("JoinRoom by a non-member = %v, want %v", err, ErrNotMember)
+	}
+	if ls.GetLobby(alice.LobbyID).IsUserInLobby(mallory.Email) {
+		t.Fatal("a refused join took a seat")
+	}
+
+	guest := &models.Client{Email: "curious-otter-42", LobbyID: mallory.LobbyID, Role: models.RoleGuest, Rooms: models.NewRooms()}
+	if err := ls.JoinRoom(guest, alice.LobbyID); !errors.Is(err, ErrGuestRoom) {
+		t.Errorf("JoinRoom by a guest = %v, want %v", err, ErrGuestRoom)
+	}
+
+	// An invite from the lobby takes a seat and follows it, once
+	lobby := ls.GetLobby(alice.LobbyID)
+	invite, _, err := invites.Create(lobby, alice.Email, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redeem := func() error { return invites.Redeem(invite) }
+	if err := ls.JoinRoomInvited(mallory, invite, redeem); err != nil {
+		t.Fatalf("JoinRoomInvited = %v", err)
+	}
+	if ls.Room(mallory, alice.LobbyID) == nil || !ls.GetLobby(alice.LobbyID).IsUserInLobby(mallory.Email) {
+		t.Error("the invited user wasn't seated in and following the lobby")
+	}
+	if err := ls.JoinRoomInvited(bob, invite, redeem); !errors.Is(err, ErrInviteUsed) {
+		t.Errorf("JoinRoomInvited with a spent invite = %v, want %v", err, ErrInviteUsed)
+	}
+
+	// Once seated, a member follows the lobby again without the invite
+	if err := ls.LeaveRoom(mallory, alice.LobbyID); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.JoinRoom(mallory, alice.LobbyID); err != nil {
+		t.Errorf("JoinRoom by a member = %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3Backend archives to a bucket through the S3 API, signing requests with
+// AWS Signature Version 4. Objects are addressed path style, as
+// <endpoint>/<bucket>/<key>, which AWS, GCS and MinIO all serve.
+type S3Backend struct {
+	endpoint        *url.URL
+	region          string
+	bucket          string
+	accessKeyID     string
+	secretAccessKey string
+	client          *http.Client
+}
+
+func NewS3Backend(endpoint, region, bucket, accessKeyID, secretAccessKey string) (*S3Backend, error) {
+	if bucket == "" || accessKeyID == "" || secretAccessKey == "" {
+		return nil, fmt.Errorf("the s3 archive backend needs ARCHIVE_BUCKET, ARCHIVE_ACCESS_KEY_ID and ARCHIVE_SECRET_ACCESS_KEY")
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	parsed, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, fmt.Errorf("invalid ARCHIVE_ENDPOINT %q", endpoint)
+	}
+	return &S3Backend{
+		endpoint:        parsed,
+		region:          region,
+		bucket:          bucket,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		client:          &http.Client{},
+	}, nil
+}
+
+func (sb *S3Backend) Name() string {
+	return "s3"
+}
+
+func (sb *S3Backend) Bucket() string {
+	return sb.bucket
+}
+
+// Put uploads body as the object key.
+func (sb *S3Backend) Put(ctx context.Context, key, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, sb.objectURL(key).String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	payloadHash := sha256.Sum256(body)
+	sb.sign(req, hex.EncodeToString(payloadHash[:]), time.Now())
+
+	resp, err := sb.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("s3 answered %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// PresignGet returns a URL that downloads the object key, as filename, for
+// ttl without credentials.
+func (sb *S3Backend) PresignGet(key, filename string, ttl time.Duration) (string, error) {
+	target := sb.objectURL(key)
+	query := url.Values{}
+	query.Set("response-content-disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	target.RawQuery = query.Encode()
+	return sb.presign(http.MethodGet, target, ttl, time.Now()), nil
+}
+
+func (sb *S3Backend) objectURL(key string) *url.URL {
+	target := *sb.endpoint
+	target.Path = target.Path + "/" + sb.bucket + "/" + key
+	target.RawPath = sigV4EscapePath(target.Path)
+	return &target
+}
+
+// sign adds the Authorization header of req, signing its headers, its host
+// and payloadHash.
+func (sb *S3Backend) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format(sigV4TimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	canonicalHeaders, signedHeaders := sigV4Headers(headers)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		sigV4EscapePath(req.URL.Path),
+		sigV4Query(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := sb.scope(now)
+	signature := sb.signature(now, scope, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", sigV4Algorithm, sb.accessKeyID, scope, signedHeaders, signature))
+}
+
+// presign returns target with the query parameters that let it be used for
+// method until ttl from now.
+func (sb *S3Backend) presign(method string, target *url.URL, ttl time.Duration, now time.Time) string {
+	scope := sb.scope(now)
+	query := target.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", sb.accessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", now.UTC().Format(sigV4TimeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalHeaders, signedHeaders := sigV4Headers(map[string]string{"host": target.Host})
+	canonicalRequest := strings.Join([]string{
+		method,
+		sigV4EscapePath(target.Path),
+		sigV4Query(query),
+		canonicalHeaders,
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	presigned := *target
+	presigned.RawQuery = sigV4Query(query) + "&X-Amz-Signature=" + sb.signature(now, scope, canonicalRequest)
+	return presigned.String()
+}
+
+func (sb *S3Backend) scope(now time.Time) string {
+	return now.UTC().Format("20060102") + "/" + sb.region + "/s3/aws4_request"
+}
+
+func (sb *S3Backend) signature(now time.Time, scope, canonicalRequest string) string {
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		now.UTC().Format(sigV4TimeFormat),
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+sb.secretAccessKey), now.UTC().Format("20060102"))
+	for _, part := range []string{sb.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// sigV4Headers returns the canonical headers, one "name:value" line each in
+// name order, and the list of their names.
+func sigV4Headers(headers map[string]string) (string, string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + strings.Join(strings.Fields(headers[name]), " ") + "\n")
+	}
+	return canonical.String(), strings.Join(names, ";")
+}
+
+// sigV4Query returns the query sorted by name with names and values
+// escaped as SigV4 wants them.
+func sigV4Query(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(name, true)+"="+sigV4Escape(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func sigV4EscapePath(path string) string {
+	return sigV4Escape(path, false)
+}
+
+// sigV4Escape percent-encodes everything but the unreserved characters of
+// RFC 3986, and slashes unless escapeSlash.
+func sigV4Escape(value string, escapeSlash bool) string {
+	var escaped strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '.', b == '_', b == '~':
+			escaped.WriteByte(b)
+		case b == '/' && !escapeSlash:
+			escaped.WriteByte(b)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	return escaped.String()
+}
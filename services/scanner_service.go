@@ -0,0 +1,143 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"chat-integrated/config"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type ScanResult struct {
+	Clean     bool   `json:"clean"`
+	Signature string `json:"signature,omitempty"`
+	Scanner   string `json:"scanner"`
+}
+
+// Scanner inspects an uploaded file before it becomes downloadable.
+type Scanner interface {
+	Scan(path string) (ScanResult, error)
+}
+
+// NewScanner returns the scanner selected by config.ScannerBackend.
+func NewScanner() Scanner {
+	switch config.ScannerBackend {
+	case "clamav":
+		return &ClamAVScanner{addr: config.ClamAVAddr, timeout: config.ScanTimeout}
+	case "http":
+		return &HTTPScanner{url: config.ScannerURL, client: &http.Client{Timeout: config.ScanTimeout}}
+	default:
+		return NoopScanner{}
+	}
+}
+
+// NoopScanner accepts every file; used when no scanner is configured.
+type NoopScanner struct{}
+
+func (NoopScanner) Scan(path string) (ScanResult, error) {
+	return ScanResult{Clean: true, Scanner: "none"}, nil
+}
+
+// ClamAVScanner streams files to a clamd daemon using the INSTREAM command.
+type ClamAVScanner struct {
+	addr    string
+	timeout time.Duration
+}
+
+const clamAVChunkSize = 64 * 1024
+
+func (cs *ClamAVScanner) Scan(path string) (ScanResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	defer file.Close()
+
+	conn, err := net.DialTimeout("tcp", cs.addr, cs.timeout)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("clamd dial: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(cs.timeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return ScanResult{}, err
+	}
+
+	// Each chunk is prefixed with its length; a zero length ends the stream
+	chunk := make([]byte, clamAVChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := file.Read(chunk)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(append(size, chunk[:n]...)); err != nil {
+				return ScanResult{}, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return ScanResult{}, readErr
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return ScanResult{}, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString('\x00')
+	if err != nil && err != io.EOF {
+		return ScanResult{}, err
+	}
+	reply = strings.TrimRight(reply, "\x00\n")
+
+	// Replies look like "stream: OK" or "stream: Eicar-Signature FOUND"
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return ScanResult{Clean: true, Scanner: "clamav"}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return ScanResult{Clean: false, Signature: signature, Scanner: "clamav"}, nil
+	default:
+		return ScanResult{}, fmt.Errorf("clamd: %s", reply)
+	}
+}
+
+// HTTPScanner posts the file to an external scanning API that answers with
+// a JSON ScanResult.
+type HTTPScanner struct {
+	url    string
+	client *http.Client
+}
+
+func (hs *HTTPScanner) Scan(path string) (ScanResult, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ScanResult{}, err
+	}
+
+	resp, err := hs.client.Post(hs.url, "application/octet-stream", bytes.NewReader(content))
+	if err != nil {
+		return ScanResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ScanResult{}, fmt.Errorf("scanner returned status %d", resp.StatusCode)
+	}
+
+	var result ScanResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return ScanResult{}, err
+	}
+	result.Scanner = "http"
+	return result, nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"strings"
+	"time"
+)
+
+type SearchQuery struct {
+	Text          string
+	CaseSensitive bool
+	Sender        string
+	From          time.Time
+	To            time.Time
+	Context       int
+}
+
+type SearchResult struct {
+	Match  models.RedisMessage   `json:"match"`
+	Before []models.RedisMessage `json:"before"`
+	After  []models.RedisMessage `json:"after"`
+}
+
+// SearchService searches a lobby's persisted history. The store keeps the queue
+// after a lobby ends, so archived lobbies are searchable by ID as well.
+type SearchService struct {
+	store Store
+}
+
+func NewSearchService(store Store) *SearchService {
+	return &SearchService{
+		store: store,
+	}
+}
+
+func (ss *SearchService) Search(lobbyID string, query SearchQuery) ([]SearchResult, error) {
+	messages, err := ss.store.GetMessages(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+
+	needle := query.Text
+	if !query.CaseSensitive {
+		needle = strings.ToLower(needle)
+	}
+
+	results := make([]SearchResult, 0)
+	for i, msg := range messages {
+		if !matches(msg, needle, query) {
+			continue
+		}
+
+		before := messages[max(0, i-query.Context):i]
+		after := messages[i+1 : min(len(messages), i+1+query.Context)]
+		results = append(results, SearchResult{
+			Match:  msg,
+			Before: before,
+			After:  after,
+		})
+	}
+	return results, nil
+}
+
+func matches(msg models.RedisMessage, needle string, query SearchQuery) bool {
+	if query.Sender != "" && !strings.EqualFold(msg.Username, query.Sender) {
+		return false
+	}
+	if !query.From.IsZero() && msg.Timestamp.Before(query.From) {
+		return false
+	}
+	if !query.To.IsZero() && msg.Timestamp.After(query.To) {
+		return false
+	}
+
+	content, username := msg.Content, msg.Username
+	if !query.CaseSensitive {
+		content, username = strings.ToLower(content), strings.ToLower(username)
+	}
+	return strings.Contains(content, needle) || strings.Contains(username, needle)
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"errors"
+	"log"
+	"strconv"
+)
+
+// sequence stamps msg with the lobby's next sequence number. It runs on the
+// lobby's worker before the message is persisted or fanned out, so the
+// store, the history ring and every client see messages in Seq order.
+//
+// Numbers are reserved in the store config.SeqReserveBlock at a time before
+// they are handed out. System actions carry a Seq but are never stored, so
+// without the reservation a restart could resume below a number clients
+// already hold as their last_seq and their next replay would skip messages.
+func (ls *LobbyService) sequence(lobby *models.Lobby, msg *models.Message) {
+	seq, reserve := lobby.NextSeq()
+	if reserve && !lobby.Internal {
+		ceiling := seq + config.SeqReserveBlock - 1
+		if err := ls.store.SetWithTTL(ls.seqKey(lobby.ID), ceiling, 0); err != nil {
+			log.Printf("⚠️ Failed to reserve sequence numbers of lobby %s: %v", lobby.ID, err)
+		} else {
+			lobby.ReserveSeqs(ceiling)
+		}
+	}
+	msg.Seq = seq
+}
+
+// resumeSeq continues a restored lobby's numbering above the last block it
+// reserved; the block's unused numbers are skipped.
+func (ls *LobbyService) resumeSeq(lobby *models.Lobby) error {
+	ceilingStr, err := ls.store.Get(ls.seqKey(lobby.ID))
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ceiling, err := strconv.ParseInt(ceilingStr, 10, 64)
+	if err != nil {
+		return err
+	}
+	lobby.ResumeSeq(ceiling)
+	return nil
+}
+
+func (ls *LobbyService) seqKey(lobbyID string) string {
+	return ls.store.Key("lobby:%s:seq", lobbyID)
+}
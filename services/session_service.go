@@ -0,0 +1,299 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrInvalidTicket   = errors.New("connect ticket is invalid, expired or already used")
+)
+
+type SessionService struct {
+	store Store
+	// mu guards the read-modify-write of the per-user session indexes
+	mu sync.Mutex
+}
+
+func NewSessionService(store Store) *SessionService {
+	return &SessionService{
+		store: store,
+	}
+}
+
+// CreateSession stores a server-side session for a verified email and
+// returns it with its opaque token.
+func (ss *SessionService) CreateSession(email, provider string) (*models.Session, error) {
+	return ss.createSession(email, provider, config.SessionTTL, false)
+}
+
+// CreateGuestSession stores a short-lived session for a guest display name.
+func (ss *SessionService) CreateGuestSession(name string) (*models.Session, error) {
+	return ss.createSession(name, "guest", config.GuestSessionTTL, true)
+}
+
+func (ss *SessionService) createSession(email, provider string, ttl time.Duration, guest bool) (*models.Session, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		Token:     token,
+		Email:     email,
+		Provider:  provider,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		Guest:     guest,
+	}
+
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ss.store.SetWithTTL(ss.sessionKey(token), sessionJSON, ttl); err != nil {
+		log.Printf("❌ Failed to store session for %s: %v", email, err)
+		return nil, err
+	}
+	if err := ss.indexSession(email, token); err != nil {
+		log.Printf("⚠️ Failed to index session of %s: %v", email, err)
+	}
+
+	log.Printf("🔐 Session created for: %s (via %s)", email, provider)
+	return session, nil
+}
+
+func (ss *SessionService) GetSession(token string) (*models.Session, error) {
+	if token == "" {
+		return nil, ErrSessionNotFound
+	}
+
+	sessionJSON, err := ss.store.Get(ss.sessionKey(token))
+	if err == ErrKeyNotFound {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session models.Session
+	if err := json.Unmarshal([]byte(sessionJSON), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (ss *SessionService) DeleteSession(token string) error {
+	return ss.store.Delete(ss.sessionKey(token))
+}
+
+func (ss *SessionService) sessionKey(token string) string {
+	return ss.store.Key("session:%s", token)
+}
+
+// SessionID is the handle a session is listed and revoked by, so its token
+// is never shown again.
+func SessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ListSessions returns the live sessions of email.
+func (ss *SessionService) ListSessions(email string) ([]models.Session, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	tokens, err := ss.sessionIndex(email)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]models.Session, 0, len(tokens))
+	for _, token := range tokens {
+		session, err := ss.GetSession(token)
+		if errors.Is(err, ErrSessionNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, nil
+}
+
+// RevokeSession logs out the session of email with the given SessionID.
+func (ss *SessionService) RevokeSession(email, id string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	tokens, err := ss.sessionIndex(email)
+	if err != nil {
+		return err
+	}
+	for i, token := range tokens {
+		if SessionID(token) != id {
+			continue
+		}
+		if err := ss.DeleteSession(token); err != nil {
+			return err
+		}
+		return ss.saveSessionIndex(email, append(tokens[:i], tokens[i+1:]...))
+	}
+	return ErrSessionNotFound
+}
+
+// RevokeSessions logs out every session of email.
+func (ss *SessionService) RevokeSessions(email string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	tokens, err := ss.sessionIndex(email)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if err := ss.DeleteSession(token); err != nil {
+			return err
+		}
+	}
+	log.Printf("🔐 Revoked %d sessions of %s", len(tokens), email)
+	return ss.store.Delete(ss.indexKey(email))
+}
+
+func (ss *SessionService) indexSession(email, token string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	tokens, err := ss.sessionIndex(email)
+	if err != nil {
+		return err
+	}
+	// Drop the sessions that expired since
+	live := tokens[:0]
+	for _, indexed := range tokens {
+		if _, err := ss.store.Get(ss.sessionKey(indexed)); err == nil {
+			live = append(live, indexed)
+		}
+	}
+	return ss.saveSessionIndex(email, append(live, token))
+}
+
+func (ss *SessionService) sessionIndex(email string) ([]string, error) {
+	indexJSON, err := ss.store.Get(ss.indexKey(email))
+	if err == ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tokens []string
+	err = json.Unmarshal([]byte(indexJSON), &tokens)
+	return tokens, err
+}
+
+func (ss *SessionService) saveSessionIndex(email string, tokens []string) error {
+	indexJSON, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	// No session outlives SessionTTL, and neither does the index
+	return ss.store.SetWithTTL(ss.indexKey(email), indexJSON, config.SessionTTL)
+}
+
+func (ss *SessionService) indexKey(email string) string {
+	return ss.store.Key("user_sessions:%s", email)
+}
+
+// CreateReconnectToken issues the opaque token an email login presents on
+// /ws, so only the client that took the seat can connect to it.
+func (ss *SessionService) CreateReconnectToken(email string) (string, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	if err := ss.store.SetWithTTL(ss.reconnectKey(token), []byte(email), config.ReconnectTokenTTL); err != nil {
+		log.Printf("❌ Failed to store reconnect token for %s: %v", email, err)
+		return "", err
+	}
+	return token, nil
+}
+
+// ReconnectEmail returns the email a reconnect token was issued to.
+func (ss *SessionService) ReconnectEmail(token string) (string, error) {
+	if token == "" {
+		return "", ErrSessionNotFound
+	}
+	email, err := ss.store.Get(ss.reconnectKey(token))
+	if err == ErrKeyNotFound {
+		return "", ErrSessionNotFound
+	}
+	return email, err
+}
+
+func (ss *SessionService) DeleteReconnectToken(token string) error {
+	return ss.store.Delete(ss.reconnectKey(token))
+}
+
+func (ss *SessionService) reconnectKey(token string) string {
+	return ss.store.Key("reconnect:%s", token)
+}
+
+// CreateConnectTicket issues the one-time ticket email opens /ws to lobbyID
+// with. It lasts ConnectTicketTTL.
+func (ss *SessionService) CreateConnectTicket(email, lobbyID string) (string, error) {
+	ticket, err := GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	ticketJSON, err := json.Marshal(models.ConnectTicket{Email: email, LobbyID: lobbyID})
+	if err != nil {
+		return "", err
+	}
+	if err := ss.store.SetWithTTL(ss.ticketKey(ticket), ticketJSON, config.ConnectTicketTTL); err != nil {
+		log.Printf("❌ Failed to store connect ticket for %s: %v", email, err)
+		return "", err
+	}
+	return ticket, nil
+}
+
+// RedeemConnectTicket uses up a connect ticket and returns what it was
+// issued for. Of two connections presenting the same ticket only one gets
+// it.
+func (ss *SessionService) RedeemConnectTicket(ticket string) (models.ConnectTicket, error) {
+	if ticket == "" {
+		return models.ConnectTicket{}, ErrInvalidTicket
+	}
+	ticketJSON, err := ss.store.Take(ss.ticketKey(ticket))
+	if err == ErrKeyNotFound {
+		return models.ConnectTicket{}, ErrInvalidTicket
+	}
+	if err != nil {
+		return models.ConnectTicket{}, err
+	}
+	var redeemed models.ConnectTicket
+	if err := json.Unmarshal([]byte(ticketJSON), &redeemed); err != nil {
+		return models.ConnectTicket{}, err
+	}
+	return redeemed, nil
+}
+
+func (ss *SessionService) ticketKey(ticket string) string {
+	return ss.store.Key("connect_ticket:%s", ticket)
+}
+
+// GenerateToken returns a random 256-bit hex token.
+func GenerateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
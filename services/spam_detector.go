@@ -0,0 +1,169 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// SpamVerdict is what SpamDetector makes of a chat message. Reason is empty
+// for a message that isn't spam; otherwise Strikes counts the sender's
+// offences, this one included.
+type SpamVerdict struct {
+	Reason  string
+	Strikes int
+}
+
+// SpamDetector looks past slow mode at what each sender posted in the last
+// config.SpamWindow: the same message over and over, lots of links or
+// mentions of many members. It is safe for concurrent use.
+type SpamDetector struct {
+	mu      sync.Mutex
+	senders map[string]*spamRecord
+}
+
+type spamRecord struct {
+	recent     []spamSample
+	strikes    int
+	lastStrike time.Time
+}
+
+type spamSample struct {
+	at       time.Time
+	content  string
+	links    int
+	mentions int
+}
+
+func NewSpamDetector() *SpamDetector {
+	return &SpamDetector{senders: make(map[string]*spamRecord)}
+}
+
+// Check records a chat message of a lobby's member, mentioning mentions
+// members, and judges it. A message that is spam gives its sender a fresh
+// start on the window, so only more spam earns the next strike.
+func (sd *SpamDetector) Check(lobbyID, email, content string, mentions int, now time.Time) SpamVerdict {
+	if config.SpamWindow <= 0 {
+		return SpamVerdict{}
+	}
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	key := lobbyID + "/" + email
+	record, exists := sd.senders[key]
+	if !exists {
+		record = &spamRecord{}
+		sd.senders[key] = record
+	}
+	if record.strikes > 0 && now.Sub(record.lastStrike) > config.SpamStrikeTTL {
+		record.strikes = 0
+	}
+
+	kept := record.recent[:0]
+	for _, sample := range record.recent {
+		if now.Sub(sample.at) < config.SpamWindow {
+			kept = append(kept, sample)
+		}
+	}
+	sample := spamSample{
+		at:       now,
+		content:  strings.ToLower(strings.Join(strings.Fields(content), " ")),
+		links:    len(linkPattern.FindAllStringIndex(content, -1)),
+		mentions: mentions,
+	}
+	record.recent = append(kept, sample)
+
+	duplicates, links, mentioned := 0, 0, 0
+	for _, recent := range record.recent {
+		if recent.content == sample.content {
+			duplicates++
+		}
+		links += recent.links
+		mentioned += recent.mentions
+	}
+
+	var reason string
+	switch {
+	case config.SpamMaxDuplicates > 0 && duplicates > config.SpamMaxDuplicates:
+		reason = "repeating the same message"
+	case config.SpamMaxLinks > 0 && links > config.SpamMaxLinks:
+		reason = "posting too many links"
+	case config.SpamMaxMentions > 0 && mentioned > config.SpamMaxMentions:
+		reason = "mentioning too many members"
+	default:
+		return SpamVerdict{}
+	}
+
+	record.recent = nil
+	record.strikes++
+	record.lastStrike = now
+	return SpamVerdict{Reason: reason, Strikes: record.strikes}
+}
+
+// Forget drops what the detector knows of a lobby's member, or of all its
+// members for an empty email.
+func (sd *SpamDetector) Forget(lobbyID, email string) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	if email != "" {
+		delete(sd.senders, lobbyID+"/"+email)
+		return
+	}
+	for key := range sd.senders {
+		if strings.HasPrefix(key, lobbyID+"/") {
+			delete(sd.senders, key)
+		}
+	}
+}
+
+// heldForSpam drops chat from muted senders and runs the rest through the
+// spam detector. The first strike warns the sender, the second mutes them
+// and the third kicks them. Owners, moderators and bots are exempt.
+func (ls *LobbyService) heldForSpam(lobby *models.Lobby, msg models.Message) bool {
+	if msg.IsBot || roleRank(lobby.GetUserRole(msg.Username)) > 0 {
+		return false
+	}
+	client, connected := lobby.GetAllClients()[msg.Username]
+	now := time.Now()
+
+	if wait := lobby.MutedFor(msg.Username, now); wait > 0 {
+		if connected {
+			ls.replyError(client, models.ErrorMuted, fmt.Sprintf("You are muted for another %s", max(wait.Round(time.Second), time.Second)))
+		}
+		return true
+	}
+
+	mentions := len(resolveMentions(msg.Content, lobby.GetMemberEmails(), ls.profileService.Get))
+	verdict := ls.spamDetector.Check(lobby.ID, msg.Username, msg.Content, mentions, now)
+	if verdict.Reason == "" {
+		return false
+	}
+	log.Printf("🚯 Chat from %s in lobby %s is spam (%s), strike %d", msg.Username, lobby.ID, verdict.Reason, verdict.Strikes)
+	details := map[string]string{"reason": verdict.Reason}
+
+	switch verdict.Strikes {
+	case 1:
+		ls.audit(lobby, models.AuditSpamWarned, models.AuditActorSystem, msg.Username, details)
+		if connected {
+			ls.replyError(client, models.ErrorSpam, fmt.Sprintf("Your message was dropped for %s; keep it up and you will be muted", verdict.Reason))
+		}
+	case 2:
+		lobby.Mute(msg.Username, now.Add(config.SpamMuteDuration))
+		ls.audit(lobby, models.AuditMemberMuted, models.AuditActorSystem, msg.Username, map[string]interface{}{"reason": verdict.Reason, "duration": config.SpamMuteDuration.String()})
+		muteMsg := ls.systemMessage(lobby, models.SystemActionMuted, models.AuditActorSystem, fmt.Sprintf("%s was muted for %s for %s", msg.Username, config.SpamMuteDuration, verdict.Reason))
+		muteMsg.Target = msg.Username
+		muteMsg.RetryAfterMs = config.SpamMuteDuration.Milliseconds()
+		ls.handleBroadcast(BroadcastMessage{LobbyID: lobby.ID, Message: muteMsg})
+	default:
+		ls.audit(lobby, models.AuditMemberKicked, models.AuditActorSystem, msg.Username, details)
+		ls.removeMember(lobby, models.AuditActorSystem, msg.Username)
+	}
+	return true
+}
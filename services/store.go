@@ -0,0 +1,377 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// ErrKeyNotFound is returned by Store.Get when the key does not exist or
+// has expired.
+var ErrKeyNotFound = errors.New("key not found")
+
+// Broker carries the chat messages of each lobby and keeps them in order for
+// history. Messages reach a lobby's connections through its worker; brokers
+// that publish, like NATS, also make them available to other subscribers on
+// the lobby's subject.
+type Broker interface {
+	// PushMessage stores msg; ctx carries the trace of its broadcast
+	PushMessage(ctx context.Context, msg models.Message) error
+	GetMessages(lobbyID string) ([]models.RedisMessage, error)
+	// GetMessagesRange uses LRANGE index semantics: negative indexes count
+	// from the newest message
+	GetMessagesRange(lobbyID string, start, stop int64) ([]models.RedisMessage, error)
+	GetMessagesSince(lobbyID string, seq int64) ([]models.RedisMessage, error)
+	// TrimMessages keeps the newest keep messages of a lobby and removes the
+	// rest, like LTRIM key -keep -1; keep 0 removes them all
+	TrimMessages(lobbyID string, keep int64) error
+	// DeleteMessages removes the stored messages of a lobby with the given
+	// MessageIDs, wherever they are in its history
+	DeleteMessages(lobbyID string, messageIDs []string) error
+	// MessageLobbies lists the lobbies, live or archived, with stored
+	// messages
+	MessageLobbies() ([]string, error)
+}
+
+// LobbyRegistry persists lobby metadata so lobbies survive a restart.
+type LobbyRegistry interface {
+	SaveLobby(record models.LobbyRecord) error
+	LoadLobbies() ([]models.LobbyRecord, error)
+	// DeleteLobby drops an ended lobby from the registry; its messages stay
+	DeleteLobby(lobbyID string) error
+}
+
+// Ranking keeps sorted sets of scored members, like Redis' ZADD and
+// ZREVRANGE. Delete removes a whole set.
+type Ranking interface {
+	// SetScore adds member to the set at key, or moves it to score
+	SetScore(key, member string, score float64) error
+	// TopScores returns the n highest scored members of the set at key,
+	// all of them for n <= 0. Equal scores rank the greater member first.
+	TopScores(key string, n int) ([]ScoredMember, error)
+}
+
+// Stream keeps append-only logs, like Redis streams' XADD and XRANGE.
+type Stream interface {
+	// AppendStream adds an entry to the stream at key, dropping its oldest
+	// entries beyond maxLen; maxLen 0 keeps them all
+	AppendStream(key, entry string, maxLen int64) error
+	// ReadStream returns the entries added to the stream at key between
+	// since and until, oldest first. A zero time leaves its end open
+	ReadStream(key string, since, until time.Time) ([]string, error)
+}
+
+// Inbox keeps capped lists of the messages members missed while offline,
+// sealed like stored messages.
+type Inbox interface {
+	// PushInbox appends msg to the inbox at key, dropping its oldest
+	// messages beyond maxLen
+	PushInbox(key string, msg models.Message, maxLen int64) error
+	// DrainInbox returns the messages of the inbox at key, oldest first,
+	// and empties it
+	DrainInbox(key string) ([]models.RedisMessage, error)
+}
+
+// ScoredMember is a member of a sorted set with its score.
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// Store is the persistence backend of a hub: Redis, NATS JetStream, or an
+// embedded Bolt file for single-binary deployments.
+type Store interface {
+	Broker
+	LobbyRegistry
+	Ranking
+	Stream
+	Inbox
+
+	// Key builds a namespaced key, e.g. Key("session:%s", token)
+	Key(format string, args ...interface{}) string
+	SetWithTTL(key string, value interface{}, ttl time.Duration) error
+	Get(key string) (string, error)
+	// Take returns the value at key and deletes it, so of several callers
+	// racing for a key only one gets it
+	Take(key string) (string, error)
+	Delete(key string) error
+	// Ping checks the backend answers before ctx expires
+	Ping(ctx context.Context) error
+	// Degraded reports whether the backend is riding out an outage, e.g.
+	// buffering writes while Redis is down
+	Degraded() bool
+	Close()
+}
+
+// toRedisMessage converts a chat message into its stored form. A message
+// that has no MessageID yet gets one from ids.
+func toRedisMessage(msg models.Message, ids IDGenerator) models.RedisMessage {
+	redisMsg := models.RedisMessage{
+		Username:  msg.Username,
+		Content:   msg.Content,
+		LobbyID:   msg.LobbyID,
+		Timestamp: msg.Timestamp,
+		MessageID: messageID(ids, msg),
+		Seq:       msg.Seq,
+		IsBot:     msg.IsBot,
+	}
+	if msg.SystemAction != nil {
+		redisMsg.SystemAction = *msg.SystemAction
+	}
+	redisMsg.ParentMessageID = msg.ParentMessageID
+	redisMsg.Idea = msg.Type == models.MessageTypeIdea
+	redisMsg.Emoji = msg.Emoji
+	redisMsg.ClientMsgID = msg.ClientMsgID
+	redisMsg.Format = msg.Format
+	redisMsg.ExpireAt = msg.ExpireAt
+	return redisMsg
+}
+
+// storedMessageID opens a stored message just far enough to read its
+// MessageID, or returns "" if it can't be read.
+func storedMessageID(cipher *MessageCipher, entry []byte) string {
+	msgJSON, err := cipher.Open(entry)
+	if err != nil {
+		return ""
+	}
+	var stored struct {
+		MessageID string `json:"message_id"`
+	}
+	if json.Unmarshal(msgJSON, &stored) != nil {
+		return ""
+	}
+	return stored.MessageID
+}
+
+// messageID is the server's ID of a stored message: msg_ and an ID from
+// ids, unless the message already has one.
+func messageID(ids IDGenerator, msg models.Message) string {
+	if msg.MessageID != "" {
+		return msg.MessageID
+	}
+	return "msg_" + ids.NewID()
+}
+
+// lrangeBounds converts LRANGE style start/stop indexes into slice bounds
+// for a list of length n.
+func lrangeBounds(n, start, stop int64) (int64, int64) {
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	start = max(start, 0)
+	stop = min(stop, n-1)
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop + 1
+}
+
+// expiringValue is a key's value in stores without native expiry. Expired
+// values are dropped lazily on the next Get.
+type expiringValue struct {
+	Value     string    `json:"value"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+func newExpiringValue(value interface{}, ttl time.Duration) expiringValue {
+	entry := expiringValue{}
+	switch v := value.(type) {
+	case string:
+		entry.Value = v
+	case []byte:
+		entry.Value = string(v)
+	default:
+		entry.Value = fmt.Sprint(v)
+	}
+	if ttl > 0 {
+		entry.ExpiresAt = time.Now().Add(ttl)
+	}
+	return entry
+}
+
+func (ev expiringValue) expired() bool {
+	return !ev.ExpiresAt.IsZero() && time.Now().After(ev.ExpiresAt)
+}
+
+// setJSONScore implements Ranking.SetScore for stores without sorted sets,
+// keeping each set at its key as a JSON object of scores. Sets are only
+// written by their lobby's worker, so the read and write don't race.
+func setJSONScore(store Store, key, member string, score float64) error {
+	scores, err := jsonScores(store, key)
+	if err != nil {
+		return err
+	}
+	scores[member] = score
+	scoresJSON, err := json.Marshal(scores)
+	if err != nil {
+		return err
+	}
+	return store.SetWithTTL(key, scoresJSON, 0)
+}
+
+// topJSONScores implements Ranking.TopScores on the sets of setJSONScore.
+func topJSONScores(store Store, key string, n int) ([]ScoredMember, error) {
+	scores, err := jsonScores(store, key)
+	if err != nil {
+		return nil, err
+	}
+	ranked := make([]ScoredMember, 0, len(scores))
+	for member, score := range scores {
+		ranked = append(ranked, ScoredMember{Member: member, Score: score})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Member > ranked[j].Member
+	})
+	if n > 0 && n < len(ranked) {
+		ranked = ranked[:n]
+	}
+	return ranked, nil
+}
+
+// pushJSONInbox implements Inbox.PushInbox for stores without lists,
+// keeping each inbox at its key as a JSON array of sealed messages. Callers
+// serialize their pushes to an inbox.
+func pushJSONInbox(store Store, cipher *MessageCipher, ids IDGenerator, key string, msg models.Message, maxLen int64) error {
+	entries, err := jsonInbox(store, key)
+	if err != nil {
+		return err
+	}
+	msgJSON, err := json.Marshal(toRedisMessage(msg, ids))
+	if err != nil {
+		return err
+	}
+	if msgJSON, err = cipher.Seal(msgJSON); err != nil {
+		return err
+	}
+	entries = append(entries, string(msgJSON))
+	if maxLen > 0 && int64(len(entries)) > maxLen {
+		entries = entries[int64(len(entries))-maxLen:]
+	}
+	entriesJSON, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return store.SetWithTTL(key, entriesJSON, 0)
+}
+
+// drainJSONInbox implements Inbox.DrainInbox on the inboxes of
+// pushJSONInbox.
+func drainJSONInbox(store Store, cipher *MessageCipher, key string) ([]models.RedisMessage, error) {
+	entries, err := jsonInbox(store, key)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	if err := store.Delete(key); err != nil {
+		return nil, err
+	}
+	return openInbox(cipher, entries), nil
+}
+
+func jsonInbox(store Store, key string) ([]string, error) {
+	var entries []string
+	entriesJSON, err := store.Get(key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entries, json.Unmarshal([]byte(entriesJSON), &entries)
+}
+
+// openInbox decodes the sealed messages of an inbox, skipping those it
+// can't.
+func openInbox(cipher *MessageCipher, entries []string) []models.RedisMessage {
+	messages := make([]models.RedisMessage, 0, len(entries))
+	for _, entry := range entries {
+		msgJSON, err := cipher.Open([]byte(entry))
+		if err != nil {
+			log.Printf("⚠️ Failed to decrypt inbox message: %v", err)
+			continue
+		}
+		var msg models.RedisMessage
+		if err := json.Unmarshal(msgJSON, &msg); err != nil {
+			log.Printf("⚠️ Failed to unmarshal inbox message: %v", err)
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// streamEntry is an entry of a stream kept by appendJSONStream.
+type streamEntry struct {
+	Time  time.Time `json:"time"`
+	Entry string    `json:"entry"`
+}
+
+// appendJSONStream implements Stream.AppendStream for stores without
+// streams, keeping each stream at its key as a JSON array. Callers
+// serialize their appends to a stream.
+func appendJSONStream(store Store, key, entry string, maxLen int64) error {
+	entries, err := jsonStream(store, key)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, streamEntry{Time: time.Now(), Entry: entry})
+	if maxLen > 0 && int64(len(entries)) > maxLen {
+		entries = entries[int64(len(entries))-maxLen:]
+	}
+	entriesJSON, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return store.SetWithTTL(key, entriesJSON, 0)
+}
+
+// readJSONStream implements Stream.ReadStream on the streams of
+// appendJSONStream.
+func readJSONStream(store Store, key string, since, until time.Time) ([]string, error) {
+	entries, err := jsonStream(store, key)
+	if err != nil {
+		return nil, err
+	}
+	read := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if (!since.IsZero() && entry.Time.Before(since)) || (!until.IsZero() && entry.Time.After(until)) {
+			continue
+		}
+		read = append(read, entry.Entry)
+	}
+	return read, nil
+}
+
+func jsonStream(store Store, key string) ([]streamEntry, error) {
+	var entries []streamEntry
+	entriesJSON, err := store.Get(key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entries, json.Unmarshal([]byte(entriesJSON), &entries)
+}
+
+func jsonScores(store Store, key string) (map[string]float64, error) {
+	scores := make(map[string]float64)
+	scoresJSON, err := store.Get(key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return scores, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return scores, json.Unmarshal([]byte(scoresJSON), &scores)
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"errors"
+	"log"
+)
+
+var (
+	ErrParentNotFound = errors.New("the message you replied to doesn't exist")
+	ErrNestedReply    = errors.New("replies can only answer chat messages and ideas")
+)
+
+// isChat reports whether msg is user chat, either top-level or a reply.
+func isChat(msg models.Message) bool {
+	return msg.Type == models.MessageTypeChat || msg.Type == models.MessageTypeReply || msg.Type == models.MessageTypeIdea
+}
+
+// threadParent returns the message a reply answers, which must be a
+// top-level chat message of the lobby; threads are one level deep.
+func (ls *LobbyService) threadParent(lobby *models.Lobby, parentSeq int64) (models.Message, error) {
+	parent, err := ls.messageBySeq(lobby, parentSeq)
+	if err != nil {
+		return models.Message{}, ErrParentNotFound
+	}
+	if parent.Type != models.MessageTypeChat && parent.Type != models.MessageTypeIdea {
+		return models.Message{}, ErrNestedReply
+	}
+	return parent, nil
+}
+
+// rejectReply tells the sender their reply was not posted.
+func (ls *LobbyService) rejectReply(lobby *models.Lobby, msg models.Message, err error) {
+	log.Printf("🧵 Reply from %s to seq %d in lobby %s rejected: %v", msg.Username, msg.ParentMessageID, lobby.ID, err)
+	if client, connected := lobby.GetAllClients()[msg.Username]; connected {
+		ls.replyError(client, ErrorCodeOf(err), err.Error())
+	}
+}
+
+// GetThread returns a chat message and its replies, oldest first, reading
+// the replies from the store.
+func (ls *LobbyService) GetThread(lobby *models.Lobby, parentSeq int64) (models.Message, []models.Message, error) {
+	parent, err := ls.threadParent(lobby, parentSeq)
+	if err != nil {
+		return models.Message{}, nil, err
+	}
+
+	// Replies always come after their parent
+	stored, err := ls.store.GetMessagesSince(lobby.ID, parentSeq)
+	if err != nil {
+		return models.Message{}, nil, err
+	}
+	replies := make([]models.Message, 0)
+	for _, storedMsg := range stored {
+		if storedMsg.ParentMessageID == parentSeq {
+			replies = append(replies, fromRedisMessage(storedMsg))
+		}
+	}
+	return parent, replies, nil
+}
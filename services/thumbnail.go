@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+)
+
+const (
+	ThumbnailMaxDimension = 256
+	thumbnailMaxPixels    = 40_000_000
+	thumbnailJPEGQuality  = 80
+)
+
+var errImageTooLarge = errors.New("image dimensions too large for thumbnailing")
+
+// generateThumbnail decodes the image at src and writes a JPEG scaled to fit
+// within ThumbnailMaxDimension to dst. Re-encoding drops all metadata,
+// including EXIF.
+func generateThumbnail(src, dst string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Check dimensions before decoding to avoid decompression bombs
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return err
+	}
+	if cfg.Width*cfg.Height > thumbnailMaxPixels {
+		return errImageTooLarge
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	return jpeg.Encode(out, scaleToFit(img, ThumbnailMaxDimension), &jpeg.Options{Quality: thumbnailJPEGQuality})
+}
+
+// scaleToFit downsamples img with a box filter so neither side exceeds
+// maxDim. Images already small enough are only flattened onto RGBA.
+func scaleToFit(img image.Image, maxDim int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	dstW, dstH := srcW, srcH
+	if srcW > maxDim || srcH > maxDim {
+		if srcW >= srcH {
+			dstW, dstH = maxDim, max(1, srcH*maxDim/srcW)
+		} else {
+			dstW, dstH = max(1, srcW*maxDim/srcH), maxDim
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/dstW)
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+pr, g+pg, b+pb, a+pa
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
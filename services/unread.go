@@ -0,0 +1,103 @@
+package services
+
+import (
+	"chat-integrated/models"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrUnknownMessage = errors.New("no message with this message_id")
+
+// ReadStatus is where a user stopped reading a lobby and how many messages
+// of others came after.
+type ReadStatus struct {
+	LastReadMessageID string    `json:"last_read_message_id,omitempty"`
+	LastReadSeq       int64     `json:"last_read_seq"`
+	Unread            int       `json:"unread"`
+	UpdatedAt         time.Time `json:"updated_at,omitzero"`
+}
+
+// markRead moves email's last-read pointer in the lobby to the stored
+// message with messageID. An older message than the one already read leaves
+// it where it is.
+func (ls *LobbyService) markRead(lobby *models.Lobby, email, messageID string) error {
+	msg, err := ls.messageByID(lobby, messageID)
+	if err != nil {
+		return err
+	}
+
+	current, err := ls.lastRead(lobby.ID, email)
+	if err != nil {
+		return err
+	}
+	if msg.Seq <= current.LastReadSeq {
+		return nil
+	}
+
+	pointerJSON, err := json.Marshal(ReadStatus{LastReadMessageID: messageID, LastReadSeq: msg.Seq, UpdatedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	return ls.store.SetWithTTL(ls.readKey(lobby.ID, email), pointerJSON, 0)
+}
+
+// GetReadStatus returns email's last-read pointer in the lobby with the
+// number of messages by other users after it.
+func (ls *LobbyService) GetReadStatus(lobby *models.Lobby, email string) (ReadStatus, error) {
+	status, err := ls.lastRead(lobby.ID, email)
+	if err != nil {
+		return status, err
+	}
+	for _, msg := range ls.missedMessages(lobby, status.LastReadSeq) {
+		if msg.Username != email {
+			status.Unread++
+		}
+	}
+	return status, nil
+}
+
+// lastRead loads email's pointer, which is zero before they mark anything
+// read.
+func (ls *LobbyService) lastRead(lobbyID, email string) (ReadStatus, error) {
+	var status ReadStatus
+	raw, err := ls.store.Get(ls.readKey(lobbyID, email))
+	if errors.Is(err, ErrKeyNotFound) {
+		return status, nil
+	}
+	if err != nil {
+		return status, err
+	}
+	err = json.Unmarshal([]byte(raw), &status)
+	status.Unread = 0
+	return status, err
+}
+
+// messageByID finds a stored message of the lobby, in the in-memory
+// history or else in the store.
+func (ls *LobbyService) messageByID(lobby *models.Lobby, messageID string) (models.Message, error) {
+	if messageID == "" {
+		return models.Message{}, ErrUnknownMessage
+	}
+	for _, msg := range lobby.GetMessageHistory() {
+		if msg.MessageID == messageID {
+			return msg, nil
+		}
+	}
+	if lobby.IsHistoryTruncated() {
+		stored, err := ls.store.GetMessages(lobby.ID)
+		if err != nil {
+			return models.Message{}, err
+		}
+		for _, storedMsg := range stored {
+			if storedMsg.MessageID == messageID {
+				return fromRedisMessage(storedMsg), nil
+			}
+		}
+	}
+	return models.Message{}, ErrUnknownMessage
+}
+
+func (ls *LobbyService) readKey(lobbyID, email string) string {
+	return ls.store.Key("lobby:%s:read:%s", lobbyID, email)
+}
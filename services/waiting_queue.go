@@ -0,0 +1,260 @@
+package services
+
+import (
+	"chat-integrated/config"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull     = fmt.Errorf("at most %d users can wait for a seat", config.MaxQueueLength)
+	ErrUnknownTicket = errors.New("unknown or expired queue ticket")
+)
+
+// QueueStatus is a waiting user's place in line, or the lobby they were let
+// into once a seat freed up.
+type QueueStatus struct {
+	Ticket   string `json:"ticket"`
+	Email    string `json:"email"`
+	Position int    `json:"position,omitempty"`
+	Waiting  int    `json:"waiting"`
+	Admitted bool   `json:"admitted"`
+	LobbyID  string `json:"lobby_id,omitempty"`
+	// ReconnectToken and ConnectTicket are issued to an admitted user, see
+	// LoginResponse
+	ReconnectToken string `json:"reconnect_token,omitempty"`
+	ConnectTicket  string `json:"connect_ticket,omitempty"`
+}
+
+type queueEntry struct {
+	ticket   string
+	email    string
+	tenantID string
+	// lobbyID is set once the user is admitted
+	lobbyID  string
+	lastSeen time.Time
+}
+
+// WaitingQueue holds, per tenant, the users who logged in while the lobby
+// was full, in the order they arrived. Tickets are the only handle on an
+// entry, so nobody else can read or give up a user's place.
+type WaitingQueue struct {
+	mu      sync.Mutex
+	lines   map[string][]*queueEntry
+	tickets map[string]*queueEntry
+}
+
+func NewWaitingQueue() *WaitingQueue {
+	return &WaitingQueue{
+		lines:   make(map[string][]*queueEntry),
+		tickets: make(map[string]*queueEntry),
+	}
+}
+
+// Enqueue puts email at the back of the tenant's line, or returns its
+// current place if it is already waiting.
+func (q *WaitingQueue) Enqueue(email, tenantID string) (QueueStatus, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	q.expireLocked(now)
+	for _, entry := range q.lines[tenantID] {
+		if entry.email == email {
+			entry.lastSeen = now
+			return q.statusLocked(entry), nil
+		}
+	}
+	if len(q.lines[tenantID]) >= config.MaxQueueLength {
+		return QueueStatus{}, ErrQueueFull
+	}
+
+	ticket, err := GenerateToken()
+	if err != nil {
+		return QueueStatus{}, err
+	}
+	entry := &queueEntry{ticket: ticket, email: email, tenantID: tenantID, lastSeen: now}
+	q.lines[tenantID] = append(q.lines[tenantID], entry)
+	q.tickets[ticket] = entry
+	return q.statusLocked(entry), nil
+}
+
+// Status reports the place of a ticket, which keeps it in line.
+func (q *WaitingQueue) Status(ticket string) (QueueStatus, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	q.expireLocked(now)
+	entry, exists := q.tickets[ticket]
+	if !exists {
+		return QueueStatus{}, ErrUnknownTicket
+	}
+	entry.lastSeen = now
+	return q.statusLocked(entry), nil
+}
+
+// Leave gives up a ticket's place.
+func (q *WaitingQueue) Leave(ticket string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, exists := q.tickets[ticket]
+	if !exists {
+		return ErrUnknownTicket
+	}
+	q.removeLocked(entry)
+	return nil
+}
+
+// Tenant returns the tenant a ticket waits for.
+func (q *WaitingQueue) Tenant(ticket string) (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, exists := q.tickets[ticket]
+	if !exists {
+		return "", false
+	}
+	return entry.tenantID, true
+}
+
+// Waiting returns how many users wait for a seat in the tenant.
+func (q *WaitingQueue) Waiting(tenantID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.lines[tenantID])
+}
+
+// Lines returns how many users wait for a seat in each tenant that has a
+// line.
+func (q *WaitingQueue) Lines() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	lines := make(map[string]int, len(q.lines))
+	for tenantID, line := range q.lines {
+		if len(line) > 0 {
+			lines[tenantID] = len(line)
+		}
+	}
+	return lines
+}
+
+// Admit seats the users at the front of the tenant's line until seat
+// reports the lobby is full, and returns those it let in. Users seat
+// refuses for another reason, like a ban, lose their place. The queue
+// stays locked meanwhile, so users are admitted in order.
+func (q *WaitingQueue) Admit(tenantID string, seat func(email string) (string, error)) []QueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.expireLocked(time.Now())
+	var admitted []QueueStatus
+	for len(q.lines[tenantID]) > 0 {
+		entry := q.lines[tenantID][0]
+		lobbyID, err := seat(entry.email)
+		if errors.Is(err, ErrLobbyFull) || errors.Is(err, ErrSessionInProgress) {
+			break
+		}
+		if err != nil {
+			log.Printf("🚫 %s could not be admitted from the queue: %v", entry.email, err)
+			q.removeLocked(entry)
+			continue
+		}
+
+		q.lines[tenantID] = q.lines[tenantID][1:]
+		entry.lobbyID = lobbyID
+		admitted = append(admitted, q.statusLocked(entry))
+	}
+	if len(q.lines[tenantID]) == 0 {
+		delete(q.lines, tenantID)
+	}
+	return admitted
+}
+
+func (q *WaitingQueue) statusLocked(entry *queueEntry) QueueStatus {
+	status := QueueStatus{
+		Ticket:   entry.ticket,
+		Email:    entry.email,
+		Waiting:  len(q.lines[entry.tenantID]),
+		Admitted: entry.lobbyID != "",
+		LobbyID:  entry.lobbyID,
+	}
+	for i, waiting := range q.lines[entry.tenantID] {
+		if waiting == entry {
+			status.Position = i + 1
+			break
+		}
+	}
+	return status
+}
+
+// expireLocked drops the tickets not checked within QueueTicketTTL: users
+// who gave up waiting, and admitted users who already saw their lobby.
+func (q *WaitingQueue) expireLocked(now time.Time) {
+	for _, entry := range q.tickets {
+		if now.Sub(entry.lastSeen) > config.QueueTicketTTL {
+			q.removeLocked(entry)
+		}
+	}
+}
+
+func (q *WaitingQueue) removeLocked(entry *queueEntry) {
+	delete(q.tickets, entry.ticket)
+	line := q.lines[entry.tenantID]
+	for i, waiting := range line {
+		if waiting == entry {
+			q.lines[entry.tenantID] = append(line[:i:i], line[i+1:]...)
+			break
+		}
+	}
+	if len(q.lines[entry.tenantID]) == 0 {
+		delete(q.lines, entry.tenantID)
+	}
+}
+
+// Enqueue puts a user who found the tenant's lobby full in line for the
+// next free seat.
+func (ls *LobbyService) Enqueue(email, tenantID string) (QueueStatus, error) {
+	status, err := ls.queue.Enqueue(email, tenantID)
+	if err == nil {
+		log.Printf("🎟️ %s is number %d in the queue of tenant %s", email, status.Position, tenantID)
+	}
+	return status, err
+}
+
+// CheckQueue reports a ticket's place, letting in whoever's turn it is
+// first in case a seat freed up unannounced.
+func (ls *LobbyService) CheckQueue(ticket string) (QueueStatus, error) {
+	if tenantID, exists := ls.queue.Tenant(ticket); exists {
+		ls.admitWaiting(tenantID)
+	}
+	return ls.queue.Status(ticket)
+}
+
+// LeaveQueue gives up a ticket's place in line.
+func (ls *LobbyService) LeaveQueue(ticket string) error {
+	return ls.queue.Leave(ticket)
+}
+
+// admitWaiting gives the free seats of a tenant to the users waiting for
+// one. Admitted users hold their seat like any logged in user, so the idle
+// timeout passes it on if they never connect.
+func (ls *LobbyService) admitWaiting(tenantID string) {
+	if ls.queue.Waiting(tenantID) == 0 {
+		return
+	}
+	admitted := ls.queue.Admit(tenantID, func(email string) (string, error) {
+		lobby, err := ls.seatUser(email, tenantID)
+		if err != nil {
+			return "", err
+		}
+		return lobby.ID, nil
+	})
+	for _, status := range admitted {
+		log.Printf("🎟️ Admitted %s from the queue into lobby %s", status.Email, status.LobbyID)
+	}
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"bytes"
+	"chat-integrated/config"
+	"chat-integrated/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrWebhookNotFound   = errors.New("webhook not found")
+	ErrInvalidWebhookURL = errors.New("webhook url must be an absolute http(s) URL")
+	ErrUnknownEvent      = errors.New("unknown webhook event")
+)
+
+var webhookEvents = []string{
+	models.WebhookEventMessageSent,
+	models.WebhookEventUserJoined,
+	models.WebhookEventLobbyCreated,
+	models.WebhookEventLobbyEnded,
+	models.WebhookEventActionItems,
+}
+
+type webhookDelivery struct {
+	webhook models.Webhook
+	event   models.WebhookEvent
+}
+
+// WebhookService POSTs chat events to registered URLs. Deliveries are queued
+// and sent by a small worker pool with exponential backoff; each body is
+// signed with the webhook's secret as "sha256=<hex HMAC>".
+type WebhookService struct {
+	store          Store
+	metricsService *MetricsService
+	httpClient     *http.Client
+	webhooks       map[string]models.Webhook
+	queue          chan webhookDelivery
+	mu             sync.RWMutex
+}
+
+func NewWebhookService(store Store, metricsService *MetricsService) *WebhookService {
+	ws := &WebhookService{
+		store:          store,
+		metricsService: metricsService,
+		httpClient:     &http.Client{Timeout: config.WebhookTimeout},
+		webhooks:       make(map[string]models.Webhook),
+		queue:          make(chan webhookDelivery, config.WebhookQueueSize),
+	}
+	ws.load()
+	return ws
+}
+
+// Run starts the delivery workers and blocks.
+func (ws *WebhookService) Run() {
+	var wg sync.WaitGroup
+	for i := 0; i < config.WebhookWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range ws.queue {
+				ws.deliver(delivery)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Register validates and saves a webhook, generating its ID and, if none
+// was given, its signing secret.
+func (ws *WebhookService) Register(webhook models.Webhook) (models.Webhook, error) {
+	parsed, err := url.Parse(webhook.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return models.Webhook{}, ErrInvalidWebhookURL
+	}
+	for _, event := range webhook.Events {
+		if !slices.Contains(webhookEvents, event) {
+			return models.Webhook{}, fmt.Errorf("%w: %s", ErrUnknownEvent, event)
+		}
+	}
+
+	if webhook.ID, err = GenerateToken(); err != nil {
+		return models.Webhook{}, err
+	}
+	webhook.ID = webhook.ID[:16]
+	if webhook.Secret == "" {
+		if webhook.Secret, err = GenerateToken(); err != nil {
+			return models.Webhook{}, err
+		}
+	}
+	webhook.CreatedAt = time.Now()
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.webhooks[webhook.ID] = webhook
+	if err := ws.saveLocked(); err != nil {
+		delete(ws.webhooks, webhook.ID)
+		return models.Webhook{}, err
+	}
+	log.Printf("🪝 Registered webhook %s → %s (lobby: %q)", webhook.ID, webhook.URL, webhook.LobbyID)
+	return webhook, nil
+}
+
+// List returns the registered webhooks without their secrets.
+func (ws *WebhookService) List() []models.Webhook {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	webhooks := make([]models.Webhook, 0, len(ws.webhooks))
+	for _, webhook := range ws.webhooks {
+		webhook.Secret = ""
+		webhooks = append(webhooks, webhook)
+	}
+	slices.SortFunc(webhooks, func(a, b models.Webhook) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return webhooks
+}
+
+func (ws *WebhookService) Delete(id string) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if _, exists := ws.webhooks[id]; !exists {
+		return ErrWebhookNotFound
+	}
+	delete(ws.webhooks, id)
+
+	log.Printf("🗑️ Deleted webhook %s", id)
+	return ws.saveLocked()
+}
+
+// Emit queues an event for every webhook subscribed to it. It never blocks;
+// events are dropped when the queue is full.
+func (ws *WebhookService) Emit(event string, lobby *models.Lobby, data interface{}) {
+	if lobby.Internal {
+		return
+	}
+
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	payload := models.WebhookEvent{
+		Event:     event,
+		LobbyID:   lobby.ID,
+		TenantID:  lobby.TenantID,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+	for _, webhook := range ws.webhooks {
+		if webhook.LobbyID != "" && webhook.LobbyID != lobby.ID {
+			continue
+		}
+		if len(webhook.Events) > 0 && !slices.Contains(webhook.Events, event) {
+			continue
+		}
+
+		payload.ID = fmt.Sprintf("%s-%d", webhook.ID, time.Now().UnixNano())
+		select {
+		case ws.queue <- webhookDelivery{webhook: webhook, event: payload}:
+		default:
+			ws.metricsService.IncCounter("webhook_dropped_total")
+			log.Printf("⚠️ Webhook queue full, dropping %s for %s", event, webhook.ID)
+		}
+	}
+}
+
+func (ws *WebhookService) deliver(delivery webhookDelivery) {
+	body, err := json.Marshal(delivery.event)
+	if err != nil {
+		log.Printf("❌ Failed to marshal webhook event: %v", err)
+		return
+	}
+
+	backoff := config.WebhookInitialBackoff
+	for attempt := 1; attempt <= config.WebhookMaxAttempts; attempt++ {
+		start := time.Now()
+		err = ws.post(delivery.webhook, delivery.event.Event, body)
+		ws.metricsService.AddCounter("webhook_delivery_seconds_total", time.Since(start).Seconds())
+		if err == nil {
+			ws.metricsService.IncCounter("webhook_deliveries_total")
+			return
+		}
+
+		log.Printf("⚠️ Webhook %s delivery of %s failed (attempt %d/%d): %v", delivery.webhook.ID, delivery.event.Event, attempt, config.WebhookMaxAttempts, err)
+		if attempt < config.WebhookMaxAttempts {
+			ws.metricsService.IncCounter("webhook_retries_total")
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	ws.metricsService.IncCounter("webhook_failures_total")
+	log.Printf("❌ Webhook %s gave up on %s", delivery.webhook.ID, delivery.event.ID)
+}
+
+func (ws *WebhookService) post(webhook models.Webhook, event string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(config.WebhookEventHeader, event)
+	req.Header.Set(config.WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, body))
+
+	resp, err := ws.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignWebhookPayload returns the signature header value receivers verify.
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (ws *WebhookService) load() {
+	webhooksJSON, err := ws.store.Get(ws.store.Key("webhooks"))
+	if err != nil {
+		if err != ErrKeyNotFound {
+			log.Printf("⚠️ Failed to load webhooks: %v", err)
+		}
+		return
+	}
+
+	var webhooks []models.Webhook
+	if err := json.Unmarshal([]byte(webhooksJSON), &webhooks); err != nil {
+		log.Printf("⚠️ Invalid webhooks stored: %v", err)
+		return
+	}
+	for _, webhook := range webhooks {
+		ws.webhooks[webhook.ID] = webhook
+	}
+}
+
+// saveLocked persists the registrations; ws.mu must be held.
+func (ws *WebhookService) saveLocked() error {
+	webhooks := make([]models.Webhook, 0, len(ws.webhooks))
+	for _, webhook := range ws.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+
+	webhooksJSON, err := json.Marshal(webhooks)
+	if err != nil {
+		return err
+	}
+	return ws.store.SetWithTTL(ws.store.Key("webhooks"), webhooksJSON, 0)
+}
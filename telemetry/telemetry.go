@@ -0,0 +1,58 @@
+// Package telemetry sets up OpenTelemetry tracing. Spans are started with
+// Tracer, which does nothing until Setup installs an exporter, so
+// instrumented code costs next to nothing with tracing off.
+package telemetry
+
+import (
+	"chat-integrated/config"
+	"context"
+	"log"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer starts the server's spans. It goes through the global provider,
+// so it picks up the one Setup installs.
+var Tracer trace.Tracer = otel.Tracer("chat-integrated")
+
+// Setup exports traces over OTLP/HTTP when config.Tracing is set, and
+// returns what flushes and stops the exporter. The exporter and sampler
+// read the standard OTEL_EXPORTER_OTLP_* and OTEL_TRACES_SAMPLER variables;
+// OTEL_SERVICE_NAME overrides the service name.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	if !config.Tracing {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(config.ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	log.Printf("🔭 Exporting traces over OTLP")
+	return provider.Shutdown, nil
+}
+
+// Extract returns ctx carrying the trace context of carrier's headers, so
+// a span started from it continues the caller's trace.
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}